@@ -0,0 +1,239 @@
+// Command scenario generates load-test scenarios for ebuse that can be fed to
+// vegeta (JSON targets) or k6 (a standalone script).
+//
+//	go run ./benchmark/scenario -format vegeta -n 10000 | vegeta attack -format=json -lazy -rate 500 -duration 60s | vegeta report
+//	go run ./benchmark/scenario -format k6 > ebuse.js && k6 run --vus 50 --duration 60s ebuse.js
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// request is one HTTP call in the generated scenario
+type request struct {
+	Name   string
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// vegetaTarget is vegeta's JSON target format
+type vegetaTarget struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Body   []byte              `json:"body,omitempty"`
+	Header map[string][]string `json:"header"`
+}
+
+func main() {
+	baseURL := flag.String("url", envOr("API_URL", "http://localhost:8080"), "Target server URL")
+	apiKey := flag.String("key", envOr("API_KEY", "my-secret-key"), "API key")
+	format := flag.String("format", "vegeta", "Output format: vegeta or k6")
+	n := flag.Int("n", 10000, "Number of vegeta targets to generate")
+	mix := flag.String("mix", "save=60,batch=20,stream=10,checkpoint=10", "Weighted request mix")
+	batchSize := flag.Int("batch-size", 100, "Events per batch request")
+	payloadBytes := flag.Int("payload-bytes", 128, "Approximate event payload size")
+	seed := flag.Int64("seed", 1, "Random seed for reproducible scenarios")
+	flag.Parse()
+
+	weights, err := parseMix(*mix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	gen := &generator{
+		rng:          rand.New(rand.NewSource(*seed)),
+		batchSize:    *batchSize,
+		payloadBytes: *payloadBytes,
+	}
+
+	switch *format {
+	case "vegeta":
+		err = writeVegeta(gen, weights, *n, *baseURL, *apiKey)
+	case "k6":
+		err = writeK6(gen, weights, *baseURL, *apiKey)
+	default:
+		err = fmt.Errorf("unknown format: %s", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type weight struct {
+	name   string
+	weight int
+}
+
+func parseMix(mix string) ([]weight, error) {
+	var weights []weight
+	for _, part := range strings.Split(mix, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry: %q", part)
+		}
+		w, err := strconv.Atoi(value)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for %s: %q", name, value)
+		}
+		switch name {
+		case "save", "batch", "stream", "load", "position", "checkpoint":
+		default:
+			return nil, fmt.Errorf("unknown request kind: %s", name)
+		}
+		weights = append(weights, weight{name, w})
+	}
+	return weights, nil
+}
+
+type generator struct {
+	rng          *rand.Rand
+	batchSize    int
+	payloadBytes int
+}
+
+func (g *generator) event() map[string]any {
+	return map[string]any{
+		"type":      "LoadTestEvent",
+		"data":      map[string]any{"id": g.rng.Int63(), "payload": strings.Repeat("x", g.payloadBytes)},
+		"timestamp": time.Now().UTC(),
+	}
+}
+
+func (g *generator) request(kind string) request {
+	switch kind {
+	case "save":
+		body, _ := json.Marshal(g.event())
+		return request{kind, "POST", "/events", body}
+	case "batch":
+		events := make([]map[string]any, g.batchSize)
+		for i := range events {
+			events[i] = g.event()
+		}
+		body, _ := json.Marshal(events)
+		return request{kind, "POST", "/events/batch", body}
+	case "stream":
+		return request{kind, "GET", "/events/stream?from=1&batch_size=1000", nil}
+	case "load":
+		return request{kind, "GET", "/events?from=1&to=1000", nil}
+	case "position":
+		return request{kind, "GET", "/position", nil}
+	default: // checkpoint
+		body := []byte(fmt.Sprintf(`{"position":%d}`, g.rng.Intn(1000000)))
+		return request{kind, "POST", fmt.Sprintf("/subscriptions/loadtest-%d/position", g.rng.Intn(10)), body}
+	}
+}
+
+func (g *generator) pick(weights []weight) string {
+	total := 0
+	for _, w := range weights {
+		total += w.weight
+	}
+	r := g.rng.Intn(max(total, 1))
+	for _, w := range weights {
+		if r < w.weight {
+			return w.name
+		}
+		r -= w.weight
+	}
+	return weights[len(weights)-1].name
+}
+
+func writeVegeta(gen *generator, weights []weight, n int, baseURL, apiKey string) error {
+	enc := json.NewEncoder(os.Stdout)
+	for i := 0; i < n; i++ {
+		req := gen.request(gen.pick(weights))
+		target := vegetaTarget{
+			Method: req.Method,
+			URL:    baseURL + req.Path,
+			Body:   req.Body,
+			Header: map[string][]string{
+				"X-API-Key":    {apiKey},
+				"Content-Type": {"application/json"},
+			},
+		}
+		if err := enc.Encode(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var k6Script = template.Must(template.New("k6").Parse(`// Generated by ebuse benchmark/scenario
+import http from 'k6/http';
+import { check } from 'k6';
+
+const BASE_URL = __ENV.API_URL || {{.BaseURL}};
+const API_KEY = __ENV.API_KEY || {{.APIKey}};
+const params = { headers: { 'X-API-Key': API_KEY, 'Content-Type': 'application/json' } };
+
+const requests = {{.Requests}};
+const weights = {{.Weights}};
+const total = weights.reduce((sum, w) => sum + w[1], 0);
+
+function pick() {
+  let r = Math.random() * total;
+  for (const [name, weight] of weights) {
+    if (r < weight) return name;
+    r -= weight;
+  }
+  return weights[weights.length - 1][0];
+}
+
+export default function () {
+  const name = pick();
+  const req = requests[name];
+  const res = http.request(req.method, BASE_URL + req.path, req.body, Object.assign({ tags: { name } }, params));
+  check(res, { 'status is 2xx': (r) => r.status >= 200 && r.status < 300 });
+}
+`))
+
+func writeK6(gen *generator, weights []weight, baseURL, apiKey string) error {
+	type k6Request struct {
+		Method string  `json:"method"`
+		Path   string  `json:"path"`
+		Body   *string `json:"body"`
+	}
+
+	requests := make(map[string]k6Request)
+	var pairs [][2]any
+	for _, w := range weights {
+		req := gen.request(w.name)
+		var body *string
+		if req.Body != nil {
+			s := string(req.Body)
+			body = &s
+		}
+		requests[w.name] = k6Request{req.Method, req.Path, body}
+		pairs = append(pairs, [2]any{w.name, w.weight})
+	}
+
+	quote := func(v any) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	return k6Script.Execute(os.Stdout, map[string]string{
+		"BaseURL":  quote(baseURL),
+		"APIKey":   quote(apiKey),
+		"Requests": quote(requests),
+		"Weights":  quote(pairs),
+	})
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,223 @@
+// Command ebuse-diff compares two event logs by position and checksum and
+// reports the events that are missing, extra or different, to prove a
+// migration or restore complete.
+//
+//	ebuse-diff -primary old.db -replica https://events.example.com -replica-key KEY
+//	ebuse-diff -primary export.ndjson -replica /var/lib/ebuse/acme
+//
+// Each side is a server URL, a SQLite file, a Pebble directory or an NDJSON
+// export from GET /events/export. The primary is the reference: the store
+// migrated from, or the export restored from. Local stores must not be in
+// use by a running server.
+//
+// The report is printed as JSON. The exit status is 1 if the logs differ.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/antientropy"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/pkg/client"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run compares the logs and returns the exit status, after the deferred
+// cleanup of temporary stores
+func run() int {
+	primarySpec := flag.String("primary", "", "Reference log: server URL, SQLite file, Pebble directory or NDJSON export")
+	primaryKey := flag.String("primary-key", "", "API key, if -primary is a server")
+	replicaSpec := flag.String("replica", "", "Log to check against -primary, in any form -primary accepts")
+	replicaKey := flag.String("replica-key", "", "API key, if -replica is a server (default: -primary-key)")
+	from := flag.Int64("from", 1, "First position to compare")
+	to := flag.Int64("to", 0, "Last position to compare (default: the lower head)")
+	leaf := flag.Int64("leaf", antientropy.DefaultLeafSize, "Stop splitting ranges of this many positions")
+	maxEvents := flag.Int("max-events", 1000, "Differing events to list at most")
+	timeout := flag.Duration("timeout", time.Hour, "Overall timeout")
+	flag.Parse()
+
+	if *primarySpec == "" || *replicaSpec == "" {
+		fmt.Fprintln(os.Stderr, "-primary and -replica are required")
+		return 2
+	}
+	if *replicaKey == "" {
+		*replicaKey = *primaryKey
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	primary, closePrimary, err := openLog(ctx, *primarySpec, *primaryKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "primary: %v\n", err)
+		return 1
+	}
+	defer closePrimary()
+	replica, closeReplica, err := openLog(ctx, *replicaSpec, *replicaKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replica: %v\n", err)
+		return 1
+	}
+	defer closeReplica()
+
+	result, err := diff(ctx, primary, replica, antientropy.Options{From: *from, To: *to, LeafSize: *leaf}, *maxEvents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compare: %v\n", err)
+		return 1
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(result)
+
+	if !result.Identical {
+		return 1
+	}
+	return 0
+}
+
+// eventLog is one side of the comparison
+type eventLog interface {
+	antientropy.Digester
+	antientropy.Loader
+}
+
+// result is the report printed
+type result struct {
+	*antientropy.Report
+	Events      []antientropy.EventDiff `json:"events"`
+	Truncated   bool                    `json:"events_truncated,omitempty"` // More than -max-events differ
+	MissingTail *antientropy.Range      `json:"missing_tail,omitempty"`     // Past the replica's head
+	ExtraTail   *antientropy.Range      `json:"extra_tail,omitempty"`       // Past the primary's head
+	Identical   bool                    `json:"identical"`
+}
+
+// diff finds the diverged ranges, then the events that differ in them.
+// Without an explicit end, positions past the lower head are reported as a
+// tail range rather than event by event.
+func diff(ctx context.Context, primary, replica eventLog, opts antientropy.Options, maxEvents int) (*result, error) {
+	report, err := antientropy.Diff(ctx, primary, replica, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := &result{Report: report, Events: []antientropy.EventDiff{}}
+
+	for _, r := range report.Diverged {
+		if len(res.Events) >= maxEvents {
+			res.Truncated = true
+			break
+		}
+		diffs, err := antientropy.Compare(ctx, primary, replica, []antientropy.Range{r})
+		if err != nil {
+			return nil, err
+		}
+		res.Events = append(res.Events, diffs...)
+	}
+	if len(res.Events) > maxEvents {
+		res.Events, res.Truncated = res.Events[:maxEvents], true
+	}
+
+	if opts.To == 0 {
+		switch {
+		case report.PrimaryHead > report.ReplicaHead:
+			res.MissingTail = &antientropy.Range{From: report.ReplicaHead + 1, To: report.PrimaryHead}
+		case report.ReplicaHead > report.PrimaryHead:
+			res.ExtraTail = &antientropy.Range{From: report.PrimaryHead + 1, To: report.ReplicaHead}
+		}
+	}
+	res.Identical = len(report.Diverged) == 0 && res.MissingTail == nil && res.ExtraTail == nil
+	return res, nil
+}
+
+// localLog is a store opened by this process
+type localLog struct {
+	store.EventStore
+}
+
+func (l localLog) Digest(ctx context.Context, from, to int64, chunks int) (int64, []store.RangeDigest, error) {
+	return antientropy.StoreDigester{Store: l.EventStore}.Digest(ctx, from, to, chunks)
+}
+
+// openLog opens the log at spec and returns it with a function releasing it
+func openLog(ctx context.Context, spec, apiKey string) (eventLog, func(), error) {
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		return client.New(spec, apiKey), func() {}, nil
+	}
+
+	info, err := os.Stat(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var st store.EventStore
+	switch ext := filepath.Ext(spec); {
+	case info.IsDir():
+		st, err = store.NewPebbleStore(spec)
+	case ext == ".ndjson" || ext == ".jsonl":
+		return openExport(ctx, spec)
+	default:
+		st, err = store.NewSQLiteStore(spec)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return localLog{st}, func() { st.Close() }, nil
+}
+
+// openExport imports an NDJSON export into a temporary Pebble store, keeping
+// the positions and gaps of the export
+func openExport(ctx context.Context, path string) (eventLog, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "ebuse-diff-")
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := store.NewPebbleStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	release := func() {
+		st.Close()
+		os.RemoveAll(dir)
+	}
+
+	dec := json.NewDecoder(f)
+	batch := make([]*store.StoredEvent, 0, 1000)
+	for line := 1; ; line++ {
+		var event store.StoredEvent
+		err := dec.Decode(&event)
+		if err == nil {
+			batch = append(batch, &event)
+		} else if !errors.Is(err, io.EOF) {
+			release()
+			return nil, nil, fmt.Errorf("%s: event %d: %w", path, line, err)
+		}
+		if len(batch) == cap(batch) || err != nil && len(batch) > 0 {
+			if err := st.ImportEvents(ctx, batch); err != nil {
+				release()
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			batch = batch[:0]
+		}
+		if err != nil {
+			return localLog{st}, release, nil
+		}
+	}
+}
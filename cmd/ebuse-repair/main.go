@@ -0,0 +1,137 @@
+// Command ebuse-repair compares a replica with its primary using range
+// digests and optionally re-ships the ranges that diverged.
+//
+//	ebuse-repair -primary http://a:8080 -primary-key KEY \
+//	    -replica http://b:8080 -replica-key KEY \
+//	    -replica-admin-key ADMIN -tenant acme -repair
+//
+// The report is printed as JSON. The exit status is 1 if divergence remains.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jilio/ebuse/internal/antientropy"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/pkg/client"
+)
+
+func main() {
+	primaryURL := flag.String("primary", "", "Primary server URL")
+	primaryKey := flag.String("primary-key", "", "API key on the primary")
+	replicaURL := flag.String("replica", "", "Replica server URL")
+	replicaKey := flag.String("replica-key", "", "API key on the replica (default: -primary-key)")
+	adminKey := flag.String("replica-admin-key", "", "Admin key on the replica, required with -repair")
+	tenant := flag.String("tenant", "", "Tenant name on the replica, for multi-tenant servers")
+	from := flag.Int64("from", 1, "First position to compare")
+	to := flag.Int64("to", 0, "Last position to compare (default: the lower head)")
+	leaf := flag.Int64("leaf", antientropy.DefaultLeafSize, "Stop splitting ranges of this many positions")
+	repair := flag.Bool("repair", false, "Overwrite diverged ranges on the replica with the primary's events")
+	timeout := flag.Duration("timeout", 10*time.Minute, "Overall timeout")
+	flag.Parse()
+
+	if *primaryURL == "" || *replicaURL == "" {
+		fmt.Fprintln(os.Stderr, "-primary and -replica are required")
+		os.Exit(2)
+	}
+	if *repair && *adminKey == "" {
+		fmt.Fprintln(os.Stderr, "-repair requires -replica-admin-key")
+		os.Exit(2)
+	}
+	if *replicaKey == "" {
+		*replicaKey = *primaryKey
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	primary := client.New(*primaryURL, *primaryKey)
+	replica := client.New(*replicaURL, *replicaKey)
+	opts := antientropy.Options{From: *from, To: *to, LeafSize: *leaf}
+
+	report, err := antientropy.Diff(ctx, primary, replica, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compare: %v\n", err)
+		os.Exit(1)
+	}
+
+	result := struct {
+		*antientropy.Report
+		Repaired int                 `json:"repaired,omitempty"`
+		After    *antientropy.Report `json:"after_repair,omitempty"`
+	}{Report: report}
+
+	if *repair && len(report.Diverged) > 0 {
+		target := &remoteRepairer{baseURL: *replicaURL, adminKey: *adminKey, tenant: *tenant}
+		result.Repaired, err = antientropy.Repair(ctx, primary, target, report.Diverged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "repair: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Verify, so a repair that did not stick is not reported as success
+		opts.To = report.Checked.To
+		if result.After, err = antientropy.Diff(ctx, primary, replica, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(result)
+
+	remaining := report.Diverged
+	if result.After != nil {
+		remaining = result.After.Diverged
+	}
+	if len(remaining) > 0 {
+		os.Exit(1)
+	}
+}
+
+// remoteRepairer posts repairs to a replica's /admin/repair endpoint
+type remoteRepairer struct {
+	baseURL  string
+	adminKey string
+	tenant   string
+}
+
+func (r *remoteRepairer) ReplaceEvents(ctx context.Context, events []*store.StoredEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+
+	endpoint := r.baseURL + "/admin/repair"
+	if r.tenant != "" {
+		endpoint += "?tenant=" + url.QueryEscape(r.tenant)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Key", r.adminKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(msg))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// jobGroup runs the background jobs of one tenant: its mirror, archiver,
+// pruner and backups. They share a context, so the jobs of a removed tenant
+// can be stopped, and waited for, before its store is closed.
+type jobGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// run runs job in a goroutine until the group is stopped
+func (g *jobGroup) run(job func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		job(g.ctx)
+	}()
+}
+
+// jobGroups holds the job groups of the running tenants by store, so a
+// tenant removed and added again has the jobs of its old and new store
+// apart
+type jobGroups struct {
+	ctx context.Context // Ends every group, at shutdown
+
+	mu     sync.Mutex
+	groups map[store.EventStore]*jobGroup
+}
+
+func newJobGroups(ctx context.Context) *jobGroups {
+	return &jobGroups{ctx: ctx, groups: make(map[store.EventStore]*jobGroup)}
+}
+
+// add returns a new group for the jobs using st
+func (j *jobGroups) add(st store.EventStore) *jobGroup {
+	ctx, cancel := context.WithCancel(j.ctx)
+	g := &jobGroup{ctx: ctx, cancel: cancel}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.groups[st] = g
+	return g
+}
+
+// stop stops the jobs using st and waits for them to return
+func (j *jobGroups) stop(st store.EventStore) {
+	j.mu.Lock()
+	g, ok := j.groups[st]
+	delete(j.groups, st)
+	j.mu.Unlock()
+
+	if ok {
+		g.cancel()
+		g.wg.Wait()
+	}
+}
+
+// wait waits until the jobs of every group have returned, once the context
+// of the groups is done, or until ctx is done
+func (j *jobGroups) wait(ctx context.Context) error {
+	j.mu.Lock()
+	groups := make([]*jobGroup, 0, len(j.groups))
+	for _, g := range j.groups {
+		groups = append(groups, g)
+	}
+	j.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, g := range groups {
+			g.wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
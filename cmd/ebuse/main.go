@@ -2,40 +2,294 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/jilio/ebuse"
+	"github.com/jilio/ebuse/internal/archive"
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/jwtauth"
+	"github.com/jilio/ebuse/internal/logging"
+	"github.com/jilio/ebuse/internal/mirror"
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/ratelimit"
+	"github.com/jilio/ebuse/internal/redis"
+	"github.com/jilio/ebuse/internal/retention"
 	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/systemd"
+	"github.com/jilio/ebuse/internal/throttle"
+	"github.com/jilio/ebuse/internal/token"
+	"github.com/jilio/ebuse/pkg/client"
 	"github.com/jilio/ebuse/pkg/server"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Postgres stores and TENANTS_DB_DRIVER=pgx
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(runSimulate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
+		os.Exit(runRewrite(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "subscriptions" {
+		os.Exit(runSubscriptions(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		os.Exit(runRestore(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// Parse command-line flags
 	configPath := flag.String("config", "", "Path to tenants.yaml for multi-tenant mode")
+	tenantsDB := flag.String("tenants-db", "", "Control-plane database with tenant definitions (default: TENANTS_DB)")
 	flag.Parse()
 
+	// Load configuration from environment
+	config := ebuse.LoadConfigFromEnv()
+
 	// Setup structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	logger, logCloser, err := logging.New(logging.Config{
+		Output:       config.LogOutput,
+		Format:       config.LogFormat,
+		Level:        config.LogLevel,
+		MaxSizeMB:    config.LogMaxSizeMB,
+		MaxBackups:   config.LogMaxBackups,
+		AccessSample: config.LogAccessSample,
+
+		AuditExport:     config.AuditExport,
+		AuditExportAuth: config.AuditExportAuth,
+		AuditBuffer:     config.AuditExportBuffer,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
 	slog.SetDefault(logger)
 
 	slog.Info("Starting ebuse server")
 
-	// Load configuration from environment
-	config := ebuse.LoadConfigFromEnv()
-
 	var httpHandler http.Handler
+	var wrapListener func(net.Listener) net.Listener
+	var drainer interface {
+		Drain()
+		WaitStreams(ctx context.Context) error
+	}
+	var checkStores func(ctx context.Context) error // Liveness check for the systemd watchdog
+
+	if *tenantsDB == "" {
+		*tenantsDB = config.TenantsDB
+	}
+
+	// Mirrors, archivers, pruners and backups run until shutdown, or until
+	// their tenant is removed
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs := newJobGroups(jobsCtx)
+	mirrors := make(map[string]*mirror.Mirror)
+	archivers := make(map[string]*archive.Archiver)
+	pruners := make(map[string]*retention.Pruner)
+	backups := make(map[string]*backup.Scheduler)
+	defaultRetention := retention.Policy{MaxAge: config.RetentionMaxAge, MaxEvents: int64(config.RetentionMaxEvents)}
+	if config.HydrateFromArchive && config.ArchiveURL == "" {
+		slog.Error("HYDRATE_FROM_ARCHIVE needs ARCHIVE_URL")
+		os.Exit(1)
+	}
+	var archiveStore blob.Store
+	if config.ArchiveURL != "" {
+		if archiveStore, err = blob.Open(config.ArchiveURL); err != nil {
+			slog.Error("Failed to open archive store", "error", err)
+			os.Exit(1)
+		}
+	}
+	var backupStore blob.Store
+	if config.BackupURL != "" {
+		if backupStore, err = blob.Open(config.BackupURL); err != nil {
+			slog.Error("Failed to open backup store", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	probeNets, err := server.ParseProbeNets(config.ProbeCIDRs)
+	if err != nil {
+		slog.Error("Invalid PROBE_CIDRS", "error", err)
+		os.Exit(1)
+	}
+	trustedProxies, err := server.ParseTrustedProxies(config.TrustedProxyCIDRs)
+	if err != nil {
+		slog.Error("Invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+	corsOrigins, err := server.ParseCORSOrigins(config.CORSAllowedOrigins)
+	if err != nil {
+		slog.Error("Invalid CORS_ALLOWED_ORIGINS", "error", err)
+		os.Exit(1)
+	}
+
+	// Replicas sharing a Redis enforce tenant rate limits together
+	var rateLimitStore ratelimit.Store
+	if config.RateLimitRedisURL != "" {
+		client, err := redis.New(config.RateLimitRedisURL)
+		if err != nil {
+			slog.Error("Invalid RATE_LIMIT_REDIS_URL", "error", err)
+			os.Exit(1)
+		}
+		defer client.Close()
+		rateLimitStore = ratelimit.NewRedis(client)
+	}
+
+	// Replicas on a shared store wake each other's streams on writes
+	var appendBroker fanout.Broker
+	if config.FanoutRedisURL != "" {
+		client, err := redis.New(config.FanoutRedisURL)
+		if err != nil {
+			slog.Error("Invalid FANOUT_REDIS_URL", "error", err)
+			os.Exit(1)
+		}
+		defer client.Close()
+		appendBroker = fanout.NewRedis(client)
+	}
+
+	// Exports, replay jobs and archiving share one window and read rate
+	var historyWindow *throttle.Window
+	if config.HistoryWindow != "" {
+		historyWindow, err = throttle.ParseWindow(config.HistoryWindow, nil)
+		if err != nil {
+			slog.Error("Invalid HISTORY_WINDOW", "error", err)
+			os.Exit(1)
+		}
+	}
+	history := throttle.New(historyWindow, config.HistoryRateLimit)
+
+	// Service accounts exchange their secret for short-lived tokens at /token
+	var tokenSigner *token.Signer
+	var serviceAccounts *token.Accounts
+	if config.TokenSecret != "" {
+		tokenSigner, err = token.NewSigner(strings.Split(config.TokenSecret, ",")...)
+		if err != nil {
+			slog.Error("Invalid TOKEN_SECRET", "error", err)
+			os.Exit(1)
+		}
+		serviceAccounts, err = token.OpenAccounts(config.ServiceAccountsFile)
+		if err != nil {
+			slog.Error("Failed to open service accounts", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Clients may authenticate with JWTs from an existing identity provider
+	var jwtAuth *jwtauth.Verifier
+	if config.JWTJWKSURL != "" || config.JWTSecret != "" {
+		jwtAuth, err = jwtauth.New(jwtauth.Config{
+			JWKSURL:     config.JWTJWKSURL,
+			Secrets:     commaList(config.JWTSecret),
+			Issuer:      config.JWTIssuer,
+			Audience:    config.JWTAudience,
+			TenantClaim: config.JWTTenantClaim,
+		}, nil)
+		if err != nil {
+			slog.Error("Invalid JWT authentication settings", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Admins log in at an OpenID Connect provider instead of sharing ADMIN_KEY
+	var adminLogin *server.AdminLogin
+	if config.OIDCIssuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		adminLogin, err = server.NewAdminLogin(ctx, server.AdminLoginConfig{
+			Issuer:         config.OIDCIssuer,
+			ClientID:       config.OIDCClientID,
+			ClientSecret:   config.OIDCClientSecret,
+			RedirectURL:    config.OIDCRedirectURL,
+			GroupsClaim:    config.OIDCGroupsClaim,
+			OwnerGroups:    commaList(config.OIDCOwnerGroups),
+			OperatorGroups: commaList(config.OIDCOperatorGroups),
+			ViewerGroups:   commaList(config.OIDCViewerGroups),
+			SessionSecrets: strings.Split(config.AdminSessionSecret, ","),
+			SessionTTL:     config.AdminSessionTTL,
+		})
+		cancel()
+		if err != nil {
+			slog.Error("Failed to set up admin login", "issuer", config.OIDCIssuer, "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Check if running in multi-tenant mode
-	if *configPath != "" {
-		slog.Info("Running in multi-tenant mode", "config_file", *configPath)
-		tenantsConfig, err := ebuse.LoadTenantsConfig(*configPath)
+	if *configPath != "" || *tenantsDB != "" {
+		slog.Info("Running in multi-tenant mode",
+			"config_file", *configPath,
+			"tenants_db", *tenantsDB)
+
+		// Loading resolves ${vault:...} / ${aws:...} references, so calling it
+		// again picks up rotated secrets
+		readTenants := func() (*ebuse.TenantsConfig, error) {
+			if *tenantsDB == "" {
+				return ebuse.LoadTenantsConfig(*configPath)
+			}
+			registry, err := ebuse.OpenTenantRegistry(config.TenantsDBDriver, *tenantsDB)
+			if err != nil {
+				return nil, err
+			}
+			defer registry.Close()
+			return registry.Load(context.Background(), *configPath)
+		}
+		loadTenants := func() (*ebuse.TenantsConfig, error) {
+			tenantsConfig, err := readTenants()
+			if err != nil {
+				return nil, err
+			}
+			if tenantsConfig.WALCheckpointMB == 0 {
+				tenantsConfig.WALCheckpointMB = config.WALCheckpointMB
+			}
+			if tenantsConfig.WALCheckInterval == 0 {
+				tenantsConfig.WALCheckInterval = config.WALCheckInterval
+			}
+			if tenantsConfig.AnalyzeInterval == 0 {
+				tenantsConfig.AnalyzeInterval = config.AnalyzeInterval
+			}
+			if tenantsConfig.AnalyzeAfterRows == 0 {
+				tenantsConfig.AnalyzeAfterRows = config.AnalyzeAfterRows
+			}
+			if !tenantsConfig.StrictPositions {
+				tenantsConfig.StrictPositions = config.StrictPositions
+			}
+			if tenantsConfig.RecoveryScanEvents == 0 {
+				tenantsConfig.RecoveryScanEvents = config.RecoveryScanEvents
+			}
+			if tenantsConfig.PostgresDSN == "" {
+				tenantsConfig.PostgresDSN = config.PostgresDSN
+			}
+			if tenantsConfig.PostgresMaxConns == 0 {
+				tenantsConfig.PostgresMaxConns = config.PostgresMaxConns
+			}
+			if config.HydrateFromArchive {
+				tenantsConfig.Prepare = func(name string, st store.EventStore) error {
+					return hydrate(name, st, blob.WithPrefix(archiveStore, name))
+				}
+			}
+			return tenantsConfig, nil
+		}
+
+		tenantsConfig, err := loadTenants()
 		if err != nil {
 			slog.Error("Failed to load tenants config", "error", err)
 			os.Exit(1)
@@ -47,22 +301,261 @@ func main() {
 			os.Exit(1)
 		}
 		defer tenantManager.Close()
+		checkStores = func(ctx context.Context) error {
+			for _, name := range tenantManager.GetAllTenants() {
+				if st, ok := tenantManager.GetStoreByName(name); ok {
+					if _, err := st.GetPosition(ctx); err != nil {
+						return fmt.Errorf("tenant %s: %w", name, err)
+					}
+				}
+			}
+			return nil
+		}
+
+		// Reloading the tenants is set up once the server runs
+		var reloadTenants func()
+		var specs server.TenantSpecStore
+		if *tenantsDB != "" {
+			specs = &tenantSpecs{driver: config.TenantsDBDriver, dsn: *tenantsDB, base: tenantsConfig, applied: func() { reloadTenants() }}
+		}
+
+		retentionPolicies, err := tenantsConfig.RetentionPolicies(defaultRetention)
+		if err != nil {
+			slog.Error("Failed to resolve tenant retention policies", "error", err)
+			os.Exit(1)
+		}
+		// startTenantJobs starts the background jobs of a tenant served here
+		startTenantJobs := func(tenant ebuse.TenantConfig, st store.EventStore, policies map[string]retention.Policy) (server.TenantJobs, error) {
+			var started server.TenantJobs
+			tier, err := openTier(st, blob.WithPrefix(archiveStore, tenant.Name), config)
+			if err != nil {
+				return started, fmt.Errorf("open cold tier: %w", err)
+			}
+			group := jobs.add(st)
+			if tenant.Mirror != nil {
+				started.Mirror = startMirror(group, tenant.Name, st, tenant.Mirror.URL, tenant.Mirror.APIKey)
+			}
+			if archiveStore != nil {
+				started.Archiver = startArchiver(group, tenant.Name, st, blob.WithPrefix(archiveStore, tenant.Name), history, config)
+			}
+			if policy, ok := policies[tenant.Name]; ok || tier != nil {
+				started.Pruner = startPruner(group, tenant.Name, st, policy, started.Archiver, tier, config)
+			}
+			if backupStore != nil {
+				started.Backups = startBackups(group, tenant.Name, st, blob.WithPrefix(backupStore, tenant.Name), config)
+			}
+			return started, nil
+		}
+		for _, tenant := range tenantsConfig.Tenants {
+			st, local := tenantManager.GetStoreByName(tenant.Name)
+			if !local {
+				continue // Mirrors, archivers, pruners and backups run on the node owning the tenant
+			}
+			started, err := startTenantJobs(tenant, st, retentionPolicies)
+			if err != nil {
+				slog.Error("Failed to start background jobs", "tenant", tenant.Name, "error", err)
+				os.Exit(1)
+			}
+			if started.Mirror != nil {
+				mirrors[tenant.Name] = started.Mirror
+			}
+			if started.Archiver != nil {
+				archivers[tenant.Name] = started.Archiver
+			}
+			if started.Pruner != nil {
+				pruners[tenant.Name] = started.Pruner
+			}
+			if started.Backups != nil {
+				backups[tenant.Name] = started.Backups
+			}
+		}
+
+		pipelines, err := tenantsConfig.Pipelines()
+		if err != nil {
+			slog.Error("Failed to build write pipelines", "error", err)
+			os.Exit(1)
+		}
+		rateLimits, err := tenantsConfig.RateLimits(config.TenantRateLimit)
+		if err != nil {
+			slog.Error("Failed to resolve tenant rate limits", "error", err)
+			os.Exit(1)
+		}
+		rateBursts, err := tenantsConfig.RateBursts(config.TenantRateBurst)
+		if err != nil {
+			slog.Error("Failed to resolve tenant rate bursts", "error", err)
+			os.Exit(1)
+		}
+		tenantCORSOrigins, err := tenantsConfig.CORSOrigins()
+		if err != nil {
+			slog.Error("Failed to resolve tenant CORS origins", "error", err)
+			os.Exit(1)
+		}
 
 		tenants := tenantManager.GetAllTenants()
 		slog.Info("Initialized multi-tenant mode",
 			"tenant_count", len(tenantsConfig.Tenants),
 			"tenants", tenants,
 			"data_dir", tenantsConfig.DataDir)
+		if len(tenantsConfig.Shards) > 0 {
+			slog.Info("Sharded mode, proxying other tenants to their nodes",
+				"node", tenantsConfig.Node,
+				"local_tenants", len(tenants),
+				"shards", len(tenantsConfig.Shards))
+		}
+
+		// Databases live in data_dir unless every tenant defaults to a
+		// server or memory backend
+		dataDir := tenantsConfig.DataDir
+		if tenantsConfig.StoreBackend == "postgres" || tenantsConfig.StoreBackend == "memory" {
+			dataDir = ""
+		}
 
 		serverConfig := &server.Config{
-			RateLimit:  config.RateLimit,
-			RateBurst:  config.RateBurst,
-			EnableGzip: config.EnableGzip,
+			RateLimit:      config.RateLimit,
+			RateBurst:      config.RateBurst,
+			EnableGzip:     config.EnableGzip,
+			RecordMetadata: config.RecordMetadata,
+
+			MaxStreamsPerTenant: config.MaxStreamsPerTenant,
+			AdminKey:            config.AdminKey,
+			AdminOperatorKey:    config.AdminOperatorKey,
+			AdminViewerKey:      config.AdminViewerKey,
+			MaxInFlight:         config.MaxInFlight,
+
+			ProbeNets:       probeNets,
+			HealthAdminAuth: config.HealthAdminAuth,
+			DataDir:         dataDir,
+			DebugCapture:    config.DebugCapture,
+
+			AuthLockoutThreshold:       config.AuthLockoutThreshold,
+			AuthLockoutBase:            config.AuthLockoutBase,
+			AuthLockoutMax:             config.AuthLockoutMax,
+			AuthLockoutPrefixThreshold: config.AuthLockoutPrefixThreshold,
+			TrustedProxies:             trustedProxies,
+
+			CORSOrigins: corsOrigins,
+			CORSHeaders: commaList(config.CORSAllowedHeaders),
+			CORSMethods: commaList(config.CORSAllowedMethods),
+			CORSMaxAge:  config.CORSMaxAge,
+
+			TenantCORSOrigins: tenantCORSOrigins,
+
+			Mirrors:   mirrors,
+			Archivers: archivers,
+			Pruners:   pruners,
+			Backups:   backups,
+			Pipelines: pipelines,
+
+			TenantRateLimits: rateLimits,
+			TenantRateBursts: rateBursts,
+			RateLimitStore:   rateLimitStore,
+			AppendBroker:     appendBroker,
+
+			HistoryThrottle: history,
+
+			TokenSigner:     tokenSigner,
+			ServiceAccounts: serviceAccounts,
+			TokenTTL:        config.TokenTTL,
+
+			JWTAuth: jwtAuth,
+
+			AdminLogin: adminLogin,
+
+			TenantSpecs: specs,
+			SandboxDir:  filepath.Join(tenantsConfig.DataDir, ".sandboxes"), // Beside the tenants, so Pebble clones can hard-link
+			StagingDir:  filepath.Join(tenantsConfig.DataDir, ".imports"),
 		}
 
 		srv := server.NewMultiTenant(tenantManager, serverConfig)
 		defer srv.Close()
+
+		// A removed tenant's store is closed once its jobs and streams have
+		// stopped
+		tenantManager.OnRemove(func(name string, st store.EventStore) {
+			jobs.stop(st)
+			if _, readded := tenantManager.GetStoreByName(name); !readded {
+				srv.SetTenantJobs(name, nil)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+			defer cancel()
+			if err := srv.EndStreams(ctx, name); err != nil {
+				slog.Warn("Streams of removed tenant still active", "tenant", name, "error", err)
+			}
+		})
+
+		// SIGHUP re-reads the tenants, adding and removing tenants and
+		// rotating API keys, e.g. after rotating them in a secret manager; so
+		// do applying a tenant spec and, with TENANTS_WATCH_INTERVAL, editing
+		// the tenants file. Added tenants get their background jobs at once;
+		// their CORS origins apply from the next restart.
+		var reloadMu sync.Mutex
+		reloadTenants = func() {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+
+			reloaded, err := loadTenants()
+			var newPipelines map[string]*pipeline.Pipeline
+			var newRateLimits, newRateBursts map[string]int
+			var newPolicies map[string]retention.Policy
+			if err == nil {
+				newPipelines, err = reloaded.Pipelines()
+			}
+			if err == nil {
+				newPolicies, err = reloaded.RetentionPolicies(defaultRetention)
+			}
+			if err == nil {
+				newRateLimits, err = reloaded.RateLimits(config.TenantRateLimit)
+			}
+			if err == nil {
+				newRateBursts, err = reloaded.RateBursts(config.TenantRateBurst)
+			}
+			if err != nil {
+				slog.Error("Failed to reload tenants, keeping current tenants", logging.AuditKey, true, "error", err)
+				return
+			}
+
+			// Added tenants must not take a write before their pipeline applies
+			if err := srv.SetTenantSettings(newPipelines, newRateLimits, newRateBursts); err != nil {
+				slog.Warn("Tenant rate limits not reloaded", "error", err)
+			}
+			changes, err := tenantManager.Reload(reloaded)
+			if err != nil {
+				srv.SetTenantSettings(pipelines, rateLimits, rateBursts)
+				slog.Error("Failed to reload tenants, keeping current tenants", logging.AuditKey, true, "error", err)
+				return
+			}
+			pipelines, rateLimits, rateBursts = newPipelines, newRateLimits, newRateBursts
+			for _, tenant := range reloaded.Tenants {
+				if !slices.Contains(changes.Added, tenant.Name) {
+					continue
+				}
+				if st, local := tenantManager.GetStoreByName(tenant.Name); local {
+					started, err := startTenantJobs(tenant, st, newPolicies)
+					if err != nil {
+						slog.Error("Failed to start background jobs of added tenant", "tenant", tenant.Name, "error", err)
+						continue
+					}
+					srv.SetTenantJobs(tenant.Name, &started)
+				}
+			}
+			slog.Info("Reloaded tenants", logging.AuditKey, true,
+				"added", changes.Added,
+				"removed", changes.Removed,
+				"rotated", changes.Rotated)
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				reloadTenants()
+			}
+		}()
+		if config.TenantsWatch > 0 && *configPath != "" {
+			go watchFile(jobsCtx, *configPath, config.TenantsWatch, reloadTenants)
+		}
 		httpHandler = srv
+		wrapListener = srv.Listener
+		drainer = srv
 	} else {
 		// Single-tenant mode
 		if config.APIKey == "" {
@@ -70,26 +563,166 @@ func main() {
 			os.Exit(1)
 		}
 
-		slog.Info("Running in single-tenant mode", "db_path", config.DBPath)
+		var eventStore store.EventStore
+		var dataDir string // Checked for writability by /readyz
+		switch config.StoreBackend {
+		case "memory":
+			slog.Warn("Running in single-tenant mode with an in-memory store; events are lost on exit", "store_backend", "memory")
+			eventStore = store.NewMemoryStore()
+		case "postgres":
+			slog.Info("Running in single-tenant mode", "store_backend", "postgres", "schema", config.PostgresSchema)
+			if config.PostgresDSN == "" {
+				slog.Error("POSTGRES_DSN must be set for STORE_BACKEND=postgres")
+				os.Exit(1)
+			}
+
+			db, err := store.OpenPostgres(config.PostgresDSN, config.PostgresMaxConns)
+			if err != nil {
+				slog.Error("Failed to connect to postgres", "error", err)
+				os.Exit(1)
+			}
+			defer db.Close()
+			pgStore, err := store.NewPostgresStore(db, config.PostgresSchema)
+			if err != nil {
+				slog.Error("Failed to create store", "error", err, "schema", config.PostgresSchema)
+				os.Exit(1)
+			}
+			eventStore = pgStore
+		default:
+			slog.Info("Running in single-tenant mode", "db_path", config.DBPath)
+			dataDir = filepath.Dir(config.DBPath)
+
+			// Create SQLite store
+			sqliteStore, err := store.NewSQLiteStore(config.DBPath)
+			if err != nil {
+				slog.Error("Failed to create store", "error", err, "db_path", config.DBPath)
+				os.Exit(1)
+			}
+			sqliteStore.StartWALMonitor(config.WALCheckInterval, int64(config.WALCheckpointMB)<<20)
+			sqliteStore.StartMaintenance(config.AnalyzeInterval, int64(config.AnalyzeAfterRows))
+			sqliteStore.SetStrictPositions(config.StrictPositions)
+			eventStore = sqliteStore
+		}
+		defer eventStore.Close()
+		checkStores = func(ctx context.Context) error {
+			_, err := eventStore.GetPosition(ctx)
+			return err
+		}
+
+		if err := ebuse.RecoverStore("default", eventStore, config.RecoveryScanEvents); err != nil {
+			slog.Error("Failed to recover store", "error", err)
+			os.Exit(1)
+		}
+
+		// Hydration restores the archived positions, so a start position
+		// only applies to a store the archive left empty
+		if config.HydrateFromArchive {
+			if err := hydrate("default", eventStore, archiveStore); err != nil {
+				slog.Error("Failed to hydrate store from archive", "tenant", "default", "error", err)
+				os.Exit(1)
+			}
+		}
 
-		// Create SQLite store
-		sqliteStore, err := store.NewSQLiteStore(config.DBPath)
+		// A store rebuilt after a migration continues the old positions
+		if started, err := store.StartAt(context.Background(), eventStore, config.StartPosition); err != nil {
+			slog.Error("Failed to set start position", "error", err, "position", config.StartPosition)
+			os.Exit(1)
+		} else if started {
+			slog.Info("Fresh store starts after position", "position", config.StartPosition)
+		}
+		group := jobs.add(eventStore)
+		if config.MirrorURL != "" {
+			mirrors["default"] = startMirror(group, "default", eventStore, config.MirrorURL, config.MirrorAPIKey)
+		}
+		if archiveStore != nil {
+			archivers["default"] = startArchiver(group, "default", eventStore, archiveStore, history, config)
+		}
+		tier, err := openTier(eventStore, archiveStore, config)
 		if err != nil {
-			slog.Error("Failed to create store", "error", err, "db_path", config.DBPath)
+			slog.Error("Failed to open cold tier", "tenant", "default", "error", err)
 			os.Exit(1)
 		}
-		defer sqliteStore.Close()
+		if defaultRetention.Enabled() || tier != nil {
+			pruners["default"] = startPruner(group, "default", eventStore, defaultRetention, archivers["default"], tier, config)
+		}
+		if backupStore != nil {
+			backups["default"] = startBackups(group, "default", eventStore, backupStore, config)
+		}
+
+		pipelines := make(map[string]*pipeline.Pipeline)
+		if config.PipelineConfig != "" {
+			p, err := pipeline.Load(config.PipelineConfig)
+			if err != nil {
+				slog.Error("Failed to load write pipeline", "error", err, "path", config.PipelineConfig)
+				os.Exit(1)
+			}
+			pipelines["default"] = p
+		}
+		rateLimits := make(map[string]int)
+		rateBursts := make(map[string]int)
+		if config.TenantRateLimit > 0 {
+			rateLimits["default"] = config.TenantRateLimit
+		}
+		if config.TenantRateBurst > 0 {
+			rateBursts["default"] = config.TenantRateBurst
+		}
 
 		// Create server with configuration
 		serverConfig := &server.Config{
-			RateLimit:  config.RateLimit,
-			RateBurst:  config.RateBurst,
-			EnableGzip: config.EnableGzip,
+			RateLimit:      config.RateLimit,
+			RateBurst:      config.RateBurst,
+			EnableGzip:     config.EnableGzip,
+			RecordMetadata: config.RecordMetadata,
+
+			MaxStreamsPerTenant: config.MaxStreamsPerTenant,
+			AdminKey:            config.AdminKey,
+			AdminOperatorKey:    config.AdminOperatorKey,
+			AdminViewerKey:      config.AdminViewerKey,
+			MaxInFlight:         config.MaxInFlight,
+
+			ProbeNets:       probeNets,
+			HealthAdminAuth: config.HealthAdminAuth,
+			DataDir:         dataDir,
+			DebugCapture:    config.DebugCapture,
+
+			AuthLockoutThreshold:       config.AuthLockoutThreshold,
+			AuthLockoutBase:            config.AuthLockoutBase,
+			AuthLockoutMax:             config.AuthLockoutMax,
+			AuthLockoutPrefixThreshold: config.AuthLockoutPrefixThreshold,
+			TrustedProxies:             trustedProxies,
+
+			CORSOrigins: corsOrigins,
+			CORSHeaders: commaList(config.CORSAllowedHeaders),
+			CORSMethods: commaList(config.CORSAllowedMethods),
+			CORSMaxAge:  config.CORSMaxAge,
+
+			Mirrors:   mirrors,
+			Archivers: archivers,
+			Pruners:   pruners,
+			Backups:   backups,
+			Pipelines: pipelines,
+
+			TenantRateLimits: rateLimits,
+			TenantRateBursts: rateBursts,
+			RateLimitStore:   rateLimitStore,
+			AppendBroker:     appendBroker,
+
+			HistoryThrottle: history,
+
+			TokenSigner:     tokenSigner,
+			ServiceAccounts: serviceAccounts,
+			TokenTTL:        config.TokenTTL,
+
+			JWTAuth: jwtAuth,
+
+			AdminLogin: adminLogin,
 		}
 
-		srv := server.NewWithConfig(sqliteStore, serverConfig, config.APIKey)
+		srv := server.NewWithStore(eventStore, serverConfig, config.APIKey)
 		defer srv.Close()
 		httpHandler = srv
+		wrapListener = srv.Listener
+		drainer = srv
 	}
 
 	// Create HTTP server
@@ -100,6 +733,34 @@ func main() {
 		WriteTimeout: config.WriteTimeout,
 		IdleTimeout:  config.IdleTimeout,
 	}
+	tlsConfig, err := setupTLS(jobsCtx, config)
+	if err != nil {
+		slog.Error("Failed to set up TLS", "error", err)
+		os.Exit(1)
+	}
+	httpServer.TLSConfig = tlsConfig
+
+	// Use the socket passed by systemd socket activation, if any
+	activated, err := systemd.Listeners()
+	if err != nil {
+		slog.Error("Failed to use activated socket", "error", err)
+		os.Exit(1)
+	}
+	var ln net.Listener
+	if len(activated) > 0 {
+		ln = activated[0]
+		for _, extra := range activated[1:] {
+			slog.Warn("Ignoring extra activated socket", "addr", extra.Addr().String())
+			extra.Close()
+		}
+		slog.Info("Using socket-activated listener", "addr", ln.Addr().String())
+	} else {
+		ln, err = net.Listen("tcp", httpServer.Addr)
+		if err != nil {
+			slog.Error("Failed to listen", "error", err, "addr", httpServer.Addr)
+			os.Exit(1)
+		}
+	}
 
 	// Start server in goroutine
 	go func() {
@@ -109,28 +770,204 @@ func main() {
 			"rate_burst", config.RateBurst,
 			"gzip_enabled", config.EnableGzip,
 			"read_timeout", config.ReadTimeout,
-			"write_timeout", config.WriteTimeout)
+			"write_timeout", config.WriteTimeout,
+			"tls", tlsConfig != nil)
 
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		serve := httpServer.Serve
+		if tlsConfig != nil {
+			serve = func(ln net.Listener) error { return httpServer.ServeTLS(ln, "", "") }
+		}
+		if err := serve(wrapListener(ln)); err != nil && err != http.ErrServerClosed {
 			slog.Error("Server failed", "error", err)
 			os.Exit(1)
 		}
 	}()
 
+	if _, err := systemd.Notify("READY=1\nSTATUS=Serving on " + ln.Addr().String()); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
+
+	// Ping the systemd watchdog only while the stores respond, so a deadlock
+	// gets the service restarted
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		slog.Info("Systemd watchdog enabled", "interval", interval)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				err := checkStores(ctx)
+				cancel()
+				if err != nil {
+					slog.Warn("Skipping watchdog ping, store check failed", "error", err)
+					continue
+				}
+				systemd.Notify("WATCHDOG=1")
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
 
 	slog.Info("Received shutdown signal", "signal", sig.String())
+	systemd.Notify("STOPPING=1")
+
+	// A second signal skips the graceful shutdown
+	go func() {
+		sig := <-quit
+		slog.Warn("Received second shutdown signal, exiting", "signal", sig.String())
+		os.Exit(1)
+	}()
+
+	// Fail health checks, end streams at a batch boundary and close idle
+	// keep-alive connections, so traffic moves to other replicas
+	drainer.Drain()
+	httpServer.SetKeepAlivesEnabled(false)
+	stopJobs()
+
+	// In Kubernetes, endpoints are removed concurrently with SIGTERM; keep
+	// serving until load balancers have stopped routing here
+	if config.DrainDelay > 0 {
+		slog.Info("Draining before shutdown", "drain_delay", config.DrainDelay)
+		time.Sleep(config.DrainDelay)
+	}
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
 	defer cancel()
 
+	if err := drainer.WaitStreams(ctx); err != nil {
+		slog.Warn("Streams still active at shutdown", "error", err)
+	}
+
 	if err := httpServer.Shutdown(ctx); err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	} else {
 		slog.Info("Server stopped gracefully")
 	}
+
+	// Stores are closed once the jobs using them have returned
+	if err := jobs.wait(ctx); err != nil {
+		slog.Warn("Background jobs still running at shutdown", "error", err)
+	}
+}
+
+// startMirror pushes st's events to a remote server until group stops. The
+// client is created without retries: the mirror resumes from the remote head
+// after a failure, which never duplicates a batch whose response was lost.
+func startMirror(group *jobGroup, name string, st store.EventStore, url, apiKey string) *mirror.Mirror {
+	m := mirror.New(st, client.New(url, apiKey), mirror.Config{Name: name})
+	group.run(func(ctx context.Context) { m.Run(ctx) })
+	slog.Info("Mirroring enabled", "tenant", name, "target", url)
+	return m
+}
+
+// hydrate restores st from the archive before it serves requests,
+// resuming a hydration cut short; callers fail rather than serve partial
+// history
+func hydrate(name string, st store.EventStore, bs blob.Store) error {
+	start := time.Now()
+	head, err := archive.Hydrate(context.Background(), bs, st)
+	switch {
+	case errors.Is(err, archive.ErrNotEmpty):
+		return nil
+	case err != nil:
+		return err
+	}
+	slog.Info("Hydrated store from archive", "tenant", name, "position", head, "duration", time.Since(start))
+	return nil
+}
+
+// startArchiver rolls st's closed position ranges into segment files until
+// group stops
+func startArchiver(group *jobGroup, name string, st store.EventStore, bs blob.Store, history *throttle.Throttle, config *ebuse.ProductionConfig) *archive.Archiver {
+	a := archive.New(st, bs, archive.Config{
+		Name:          name,
+		SegmentEvents: int64(config.ArchiveSegmentEvents),
+		Interval:      config.ArchiveInterval,
+		Throttle:      history,
+	})
+	group.run(a.Run)
+	slog.Info("Archival enabled", "tenant", name, "segment_events", config.ArchiveSegmentEvents)
+	return a
+}
+
+// openTier sets up reads of st's events moved to the archive, or returns
+// nil when TIER_AFTER or archiving is off
+func openTier(st store.EventStore, bs blob.Store, config *ebuse.ProductionConfig) (*archive.Tier, error) {
+	if config.TierAfter <= 0 || config.ArchiveURL == "" {
+		return nil, nil
+	}
+	return archive.OpenTier(context.Background(), st, bs)
+}
+
+// startPruner deletes the events st's retention policy no longer keeps
+// until group stops. With archiving, only archived events are pruned. With
+// a tier, events older than TIER_AFTER are pruned too, and pruned events
+// are moved to it rather than deleted.
+func startPruner(group *jobGroup, name string, st store.EventStore, policy retention.Policy, archiver *archive.Archiver, tier *archive.Tier, config *ebuse.ProductionConfig) *retention.Pruner {
+	cfg := retention.Config{Name: name, Policy: policy, Interval: config.RetentionInterval}
+	if archiver != nil {
+		cfg.Floor = func() int64 { return archiver.Status().ArchivedPosition + 1 }
+	}
+	if tier != nil {
+		if cfg.Policy.MaxAge == 0 || cfg.Policy.MaxAge > config.TierAfter {
+			cfg.Policy.MaxAge = config.TierAfter
+		}
+		cfg.Delete = tier.DeleteBefore
+	}
+	p := retention.New(st, cfg)
+	group.run(p.Run)
+	slog.Info("Retention enabled", "tenant", name, "max_age", cfg.Policy.MaxAge, "max_events", cfg.Policy.MaxEvents, "tiered", tier != nil)
+	return p
+}
+
+// startBackups writes a backup of st to bs every BACKUP_INTERVAL, keeping
+// the newest BACKUP_KEEP, until group stops
+func startBackups(group *jobGroup, name string, st store.EventStore, bs blob.Store, config *ebuse.ProductionConfig) *backup.Scheduler {
+	b := backup.New(st, bs, backup.Config{
+		Name:     name,
+		Interval: config.BackupInterval,
+		Keep:     config.BackupKeep,
+	})
+	group.run(b.Run)
+	slog.Info("Scheduled backups enabled", "tenant", name, "interval", config.BackupInterval, "keep", config.BackupKeep)
+	return b
+}
+
+// watchFile calls changed whenever the modification time or size of the
+// file at path changes, checking every interval until ctx is done
+func watchFile(ctx context.Context, path string, interval time.Duration, changed func()) {
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Being replaced; look again next time
+		}
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+			last = info
+			changed()
+		}
+	}
+}
+
+// commaList splits a comma-separated setting, dropping empty entries
+func commaList(s string) []string {
+	var list []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
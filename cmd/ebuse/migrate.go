@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/jilio/ebuse"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// runMigrate implements `ebuse migrate`, which copies every event and
+// subscription position of a store into a new store of another backend,
+// at the same positions, and prints a JSON report. Changing STORE_BACKEND
+// alone opens an empty store; migrate while the server is stopped, then
+// point it at the new store. A failed migration removes the new SQLite or
+// Pebble store; a Postgres schema must be empty and is left as it is.
+//
+//	ebuse migrate -from sqlite://data/events.db -to pebble://data/events
+//	ebuse migrate -from pebble://data/acme -to postgres://ebuse@db/ebuse -schema acme
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", "", "Store to read: sqlite://<file>, pebble://<dir> or a Postgres URL")
+	to := flags.String("to", "", "Store to create, in the same forms")
+	schema := flags.String("schema", "", "Schema of a Postgres store (default: POSTGRES_SCHEMA)")
+	batchSize := flags.Int("batch-size", 1000, "Events read and written at a time")
+	verify := flags.Bool("verify", true, "Compare digests of both stores afterwards")
+	flags.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "-from and -to are required")
+		return 2
+	}
+	if *schema == "" {
+		*schema = ebuse.LoadConfigFromEnv().PostgresSchema
+	}
+
+	src, err := openStoreURL(*from, *schema, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open %s: %v\n", *from, err)
+		return 1
+	}
+	defer src.close()
+
+	dst, err := openStoreURL(*to, *schema, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create %s: %v\n", *to, err)
+		return 1
+	}
+	failed := true
+	defer func() {
+		dst.close()
+		if failed && dst.path != "" {
+			os.RemoveAll(dst.path)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	migration, err := store.Migrate(ctx, src.EventStore, dst.EventStore, *batchSize, *verify)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	failed = false
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.SetEscapeHTML(false)
+	out.Encode(map[string]any{"from": src.backend, "to": dst.backend, "migration": migration})
+	return 0
+}
+
+// urlStore is a store opened by openStoreURL
+type urlStore struct {
+	store.EventStore
+	backend string
+	path    string // SQLite file or Pebble directory; empty for Postgres
+	close   func()
+}
+
+// openStoreURL opens the store named by a sqlite://, pebble:// or Postgres
+// URL. With create, SQLite and Pebble stores must not exist yet.
+func openStoreURL(u, schema string, create bool) (*urlStore, error) {
+	scheme, path, ok := strings.Cut(u, "://")
+	if !ok || path == "" {
+		return nil, errors.New("expected sqlite://<file>, pebble://<dir> or postgres://...")
+	}
+	s := &urlStore{backend: scheme}
+	var err error
+	switch scheme {
+	case "sqlite", "pebble":
+		_, statErr := os.Stat(path)
+		if create && !errors.Is(statErr, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+		if !create && statErr != nil {
+			return nil, statErr
+		}
+		if scheme == "sqlite" {
+			s.EventStore, err = store.NewSQLiteStore(path)
+		} else {
+			s.EventStore, err = store.NewPebbleStore(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if create {
+			s.path = path
+		}
+		s.close = func() { s.EventStore.Close() }
+	case "postgres", "postgresql":
+		db, err := store.OpenPostgres(u, 0)
+		if err != nil {
+			return nil, err
+		}
+		if s.EventStore, err = store.NewPostgresStore(db, schema); err != nil {
+			db.Close()
+			return nil, err
+		}
+		s.backend = "postgres"
+		s.close = func() { s.EventStore.Close(); db.Close() }
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", scheme)
+	}
+	return s, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jilio/ebuse"
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/blob"
+)
+
+// runRestore implements `ebuse restore`, which restores a backup into a
+// fresh store for a tenant and prints a JSON report. The backup is a file
+// or a key (or "latest") under BACKUP_URL, in the tenant's prefix with
+// -config. The store goes where the server looks for the tenant: its path
+// in tenants.yaml, or DB_PATH for the single-tenant "default". It must not
+// exist, so restore while the server is stopped after moving the old store
+// aside, or into a tenant the server does not have yet.
+//
+//	ebuse restore -from latest -tenant acme -config tenants.yaml
+//	ebuse restore -from ebuse-20260301T020000Z.tar.zst -position 1207
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	from := flags.String("from", "", "Backup file, or key under BACKUP_URL (\"latest\" for the newest)")
+	tenant := flags.String("tenant", "default", "Tenant to restore")
+	configPath := flags.String("config", "", "tenants.yaml of a multi-tenant server")
+	to := flags.String("to", "", "Store to create instead of the tenant's: SQLite file if it ends in .db, otherwise a Pebble directory")
+	position := flags.Int64("position", 0, "Head position the backup must have, e.g. its X-Ebuse-Position (0 = not checked)")
+	allowGaps := flags.Bool("allow-gaps", false, "Accept missing positions between the first event and the head")
+	flags.Parse(args)
+
+	if *from == "" {
+		fmt.Fprintln(os.Stderr, "-from is required")
+		return 2
+	}
+	config := ebuse.LoadConfigFromEnv()
+
+	// Where the server would open the tenant's store
+	var backend, path string
+	var bs blob.Store
+	var err error
+	switch {
+	case *configPath != "":
+		tenants, err := ebuse.LoadTenantsConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if backend, path, err = tenants.StorePath(*tenant); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if path == "" && *to == "" {
+			fmt.Fprintf(os.Stderr, "tenant %s uses the %s backend, which has no backups to restore\n", *tenant, backend)
+			return 2
+		}
+	case *tenant != "default":
+		fmt.Fprintln(os.Stderr, "-config is required for tenants other than default")
+		return 2
+	case config.StoreBackend == "postgres" || config.StoreBackend == "memory":
+		if *to == "" {
+			fmt.Fprintf(os.Stderr, "STORE_BACKEND=%s has no backups to restore\n", config.StoreBackend)
+			return 2
+		}
+	default:
+		backend, path = "sqlite", config.DBPath
+	}
+	if *to != "" {
+		backend, path = "pebble", *to
+		if filepath.Ext(*to) == ".db" {
+			backend = "sqlite"
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var r io.ReadCloser
+	if _, statErr := os.Stat(*from); statErr == nil {
+		r, err = os.Open(*from)
+	} else {
+		if config.BackupURL == "" {
+			fmt.Fprintf(os.Stderr, "%s is not a file and BACKUP_URL is not set\n", *from)
+			return 2
+		}
+		if bs, err = blob.Open(config.BackupURL); err == nil {
+			if *configPath != "" {
+				bs = blob.WithPrefix(bs, *tenant)
+			}
+			r, err = openBackup(ctx, bs, *from)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open backup %s: %v\n", *from, err)
+		return 1
+	}
+	defer r.Close()
+
+	restored, err := backup.Restore(ctx, r, path, backup.Expect{Backend: backend, Position: *position, AllowGaps: *allowGaps})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		if errors.Is(err, backup.ErrVerify) && len(restored.Gaps) > 0 {
+			fmt.Fprintln(os.Stderr, "rerun with -allow-gaps to accept the missing positions")
+		}
+		return 1
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.SetEscapeHTML(false)
+	out.Encode(map[string]any{"tenant": *tenant, "from": *from, "restored": restored})
+	return 0
+}
+
+// openBackup opens the backup stored under key in bs, or the newest one
+// for "latest"
+func openBackup(ctx context.Context, bs blob.Store, key string) (io.ReadCloser, error) {
+	if key == "latest" {
+		var err error
+		if key, err = backup.Latest(ctx, bs); err != nil {
+			return nil, err
+		}
+	}
+	return bs.Get(ctx, key)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jilio/ebuse/internal/rewrite"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// runRewrite implements `ebuse rewrite`, which copies a store into a new
+// one, applying the transformations of a rules file, and prints a JSON
+// report. Positions are preserved, so the new store can replace the old one
+// once the server is stopped. The source must not be in use by a server; a
+// failed rewrite removes the new store.
+//
+//	ebuse rewrite -from data/acme.db -to data/acme-v2.db -rules rewrite.yaml
+//	ebuse rewrite -from data/acme -rules rewrite.yaml -dry-run
+func runRewrite(args []string) int {
+	flags := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	from := flags.String("from", "", "Store to read: SQLite file or Pebble directory")
+	to := flags.String("to", "", "Store to create: SQLite file if it ends in .db, otherwise a Pebble directory")
+	rules := flags.String("rules", "", "YAML file with the rewrite rules")
+	subscriptions := flags.String("subscriptions", "", "Subscription IDs whose positions to copy, comma-separated")
+	batchSize := flags.Int("batch-size", rewrite.DefaultBatchSize, "Events read and written at a time")
+	dryRun := flags.Bool("dry-run", false, "Apply the rules and report without writing a store")
+	flags.Parse(args)
+
+	if *from == "" || *rules == "" || *to == "" && !*dryRun {
+		fmt.Fprintln(os.Stderr, "-from, -rules and -to (or -dry-run) are required")
+		return 2
+	}
+	rw, err := rewrite.Load(*rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	src, err := openLocalStore(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open %s: %v\n", *from, err)
+		return 1
+	}
+	defer src.Close()
+
+	var dst store.EventStore
+	var importer store.Importer
+	if !*dryRun {
+		if _, err := os.Stat(*to); !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "%s already exists\n", *to)
+			return 1
+		}
+		if filepath.Ext(*to) == ".db" {
+			dst, err = store.NewSQLiteStore(*to)
+		} else {
+			dst, err = store.NewPebbleStore(*to)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "create %s: %v\n", *to, err)
+			return 1
+		}
+		importer = dst.(store.Importer)
+	}
+	failed := true
+	defer func() {
+		if dst == nil {
+			return
+		}
+		dst.Close()
+		if failed {
+			os.RemoveAll(*to)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := rewrite.Copy(ctx, src, importer, rw, *batchSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rewrite: %v\n", err)
+		return 1
+	}
+
+	// Positions are unchanged, so consumers resume where they left off
+	if dst != nil {
+		for _, id := range commaList(*subscriptions) {
+			position, err := src.LoadSubscriptionPosition(ctx, id)
+			if err == nil {
+				err = dst.SaveSubscriptionPosition(ctx, id, position)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "copy subscription %s: %v\n", id, err)
+				return 1
+			}
+		}
+	}
+
+	failed = false
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.SetEscapeHTML(false)
+	out.Encode(report)
+	return 0
+}
+
+// openLocalStore opens the SQLite file or Pebble directory at path
+func openLocalStore(path string) (store.EventStore, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return store.NewPebbleStore(path)
+	}
+	return store.NewSQLiteStore(path)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jilio/ebuse/internal/simulate"
+	"github.com/jilio/ebuse/pkg/client"
+)
+
+// runSimulate implements `ebuse simulate`, which writes a generated workload
+// to a running server and prints a JSON report. The exit status is 1 if any
+// request failed.
+//
+//	ebuse simulate -url http://localhost:8080 -key KEY -rate 500 -duration 1m
+//	ebuse simulate -tenants alice=KEY1,bob=KEY2 -arrival bursty -batch-size 50
+func runSimulate(args []string) int {
+	defaultURL := os.Getenv("API_URL")
+	if defaultURL == "" {
+		defaultURL = "http://localhost:8080"
+	}
+
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	baseURL := flags.String("url", defaultURL, "Target server URL")
+	apiKey := flags.String("key", os.Getenv("API_KEY"), "API key of the target tenant")
+	tenants := flags.String("tenants", "", "Tenants to write to as name=key,... (instead of -key)")
+	types := flags.String("types", "", "Event mix as type:weight:bytes,... (default: an order-processing mix)")
+	rate := flags.Float64("rate", simulate.DefaultRate, "Mean events per second per tenant")
+	arrival := flags.String("arrival", simulate.Poisson, "Arrival distribution: constant, poisson or bursty")
+	burst := flags.Int("burst", simulate.DefaultBurst, "Events per burst with -arrival bursty")
+	batchSize := flags.Int("batch-size", 1, "Events per request (more than 1 uses /events/batch)")
+	streams := flags.Int("streams", 1000, "Stream IDs events are spread over (0 = no streams)")
+	duration := flags.Duration("duration", time.Minute, "How long to generate events (0 = until -events or interrupted)")
+	events := flags.Int("events", 0, "Events per tenant (0 = until -duration)")
+	concurrency := flags.Int("concurrency", simulate.DefaultConcurrency, "Requests in flight per tenant")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "Random seed, to repeat a workload")
+	flags.Parse(args)
+
+	opts := simulate.Options{
+		Rate:        *rate,
+		Arrival:     *arrival,
+		Burst:       *burst,
+		BatchSize:   *batchSize,
+		Streams:     *streams,
+		Duration:    *duration,
+		Events:      *events,
+		Concurrency: *concurrency,
+		Seed:        *seed,
+	}
+	var err error
+	if opts.Types, err = parseEventTypes(*types); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	keys := map[string]string{"default": *apiKey}
+	if *tenants != "" {
+		keys = make(map[string]string)
+		for _, pair := range commaList(*tenants) {
+			name, key, ok := strings.Cut(pair, "=")
+			if !ok || name == "" || key == "" {
+				fmt.Fprintf(os.Stderr, "invalid tenant %q, want name=key\n", pair)
+				return 2
+			}
+			keys[name] = key
+		}
+	}
+	// Sorted, so a seed repeats each tenant's workload
+	var targets []simulate.Target
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		remote := client.New(*baseURL, keys[name], client.WithUserAgent("ebuse-simulate"), client.WithMaxConnsPerHost(*concurrency*len(keys)))
+		targets = append(targets, simulate.Target{Name: name, Saver: remote})
+	}
+
+	// Interrupting ends the run early and still reports
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := simulate.Run(ctx, targets, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(report)
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// parseEventTypes parses an event mix of type:weight:bytes entries; weight
+// and bytes default to 1 and 256
+func parseEventTypes(s string) ([]simulate.EventType, error) {
+	var types []simulate.EventType
+	for _, entry := range commaList(s) {
+		parts := strings.Split(entry, ":")
+		eventType := simulate.EventType{Name: parts[0], Weight: 1, PayloadSize: 256}
+		if len(parts) > 3 || eventType.Name == "" {
+			return nil, fmt.Errorf("invalid event type %q, want type:weight:bytes", entry)
+		}
+		var err error
+		if len(parts) > 1 {
+			if eventType.Weight, err = strconv.Atoi(parts[1]); err != nil || eventType.Weight < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", entry)
+			}
+		}
+		if len(parts) > 2 {
+			if eventType.PayloadSize, err = strconv.Atoi(parts[2]); err != nil || eventType.PayloadSize < 0 {
+				return nil, fmt.Errorf("invalid size in %q", entry)
+			}
+		}
+		types = append(types, eventType)
+	}
+	return types, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/pkg/client"
+)
+
+// checkpoints is the JSON document `ebuse subscriptions` reads and writes,
+// the same one GET /subscriptions returns
+type checkpoints struct {
+	Subscriptions map[string]int64 `json:"subscriptions"`
+}
+
+// checkpointStore is the part of a store or server client the command uses
+type checkpointStore interface {
+	store.SubscriptionLister
+	SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error
+}
+
+// runSubscriptions implements `ebuse subscriptions export|import`, which
+// copy the positions of all subscriptions out of a store and into another,
+// so consumers resume where they left off after the events are migrated.
+// Stores are server URLs (with -key) or SQLite files and Pebble directories
+// not in use by a server.
+//
+//	ebuse subscriptions export -store https://events.example.com -key KEY > checkpoints.json
+//	ebuse subscriptions import -store data/acme-v2.db -file checkpoints.json
+func runSubscriptions(args []string) int {
+	if len(args) == 0 || args[0] != "export" && args[0] != "import" {
+		fmt.Fprintln(os.Stderr, "usage: ebuse subscriptions export|import -store URL|PATH [-key KEY] [-file FILE]")
+		return 2
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("subscriptions "+command, flag.ExitOnError)
+	spec := flags.String("store", "", "Server URL, SQLite file or Pebble directory")
+	apiKey := flags.String("key", "", "API key, if -store is a server")
+	file := flags.String("file", "", "File to write or read the positions (default: stdout or stdin)")
+	flags.Parse(args[1:])
+
+	if *spec == "" {
+		fmt.Fprintln(os.Stderr, "-store is required")
+		return 2
+	}
+
+	var st checkpointStore
+	if strings.HasPrefix(*spec, "http://") || strings.HasPrefix(*spec, "https://") {
+		st = client.New(*spec, *apiKey, client.WithUserAgent("ebuse-subscriptions"))
+	} else {
+		local, err := openLocalStore(*spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open %s: %v\n", *spec, err)
+			return 1
+		}
+		defer local.Close()
+		var ok bool
+		if st, ok = local.(checkpointStore); !ok {
+			fmt.Fprintf(os.Stderr, "%s cannot list subscriptions\n", *spec)
+			return 1
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if command == "export" {
+		positions, err := st.Subscriptions(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return 1
+		}
+		out := io.Writer(os.Stdout)
+		if *file != "" {
+			f, err := os.Create(*file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(checkpoints{Subscriptions: positions}); err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	var doc checkpoints
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		fmt.Fprintf(os.Stderr, "parse positions: %v\n", err)
+		return 2
+	}
+
+	var err error
+	if remote, ok := st.(*client.HTTPClient); ok {
+		err = remote.ImportSubscriptions(ctx, doc.Subscriptions)
+	} else {
+		for _, id := range slices.Sorted(maps.Keys(doc.Subscriptions)) {
+			if err = st.SaveSubscriptionPosition(ctx, id, doc.Subscriptions[id]); err != nil {
+				err = fmt.Errorf("%s: %w", id, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "imported %d subscription positions\n", len(doc.Subscriptions))
+	return 0
+}
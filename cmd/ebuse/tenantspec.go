@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jilio/ebuse"
+	"github.com/jilio/ebuse/pkg/server"
+)
+
+// tenantSpecs serves /admin/tenants/spec from the tenant registry
+type tenantSpecs struct {
+	driver, dsn string
+	base        *ebuse.TenantsConfig // Global settings specs are validated against
+	applied     func()               // Called after a spec changed the registry
+}
+
+func (t *tenantSpecs) Spec(ctx context.Context) ([]server.TenantSpec, error) {
+	registry, err := ebuse.OpenTenantRegistry(t.driver, t.dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer registry.Close()
+
+	tenants, err := registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spec := make([]server.TenantSpec, 0, len(tenants))
+	for _, tenant := range tenants {
+		spec = append(spec, server.TenantSpec{
+			Name:         tenant.Name,
+			APIKey:       tenant.APIKey,
+			Template:     tenant.Template,
+			StoreBackend: tenant.StoreBackend,
+			Shard:        tenant.Shard,
+		})
+	}
+	return spec, nil
+}
+
+func (t *tenantSpecs) Reconcile(ctx context.Context, spec []server.TenantSpec, dryRun bool) ([]server.TenantSpecChange, error) {
+	registry, err := ebuse.OpenTenantRegistry(t.driver, t.dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer registry.Close()
+
+	desired := make([]ebuse.TenantConfig, 0, len(spec))
+	for _, tenant := range spec {
+		desired = append(desired, ebuse.TenantConfig{
+			Name:           tenant.Name,
+			APIKey:         tenant.APIKey,
+			Template:       tenant.Template,
+			TenantSettings: ebuse.TenantSettings{StoreBackend: tenant.StoreBackend},
+			Shard:          tenant.Shard,
+		})
+	}
+	changes, err := registry.Reconcile(ctx, t.base, desired, dryRun)
+	if errors.Is(err, ebuse.ErrInvalidTenantSpec) {
+		// Both errors read "invalid tenant spec"; keep the message once
+		return nil, fmt.Errorf("%w%s", server.ErrInvalidTenantSpec, strings.TrimPrefix(err.Error(), ebuse.ErrInvalidTenantSpec.Error()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && len(changes) > 0 {
+		t.applied()
+	}
+
+	result := make([]server.TenantSpecChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, server.TenantSpecChange{Action: change.Action, Tenant: change.Tenant, Fields: change.Fields})
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jilio/ebuse"
+	"github.com/jilio/ebuse/internal/acme"
+	"github.com/jilio/ebuse/internal/logging"
+	"github.com/jilio/ebuse/internal/tlscert"
+)
+
+// setupTLS returns the TLS configuration of the listener, or nil to serve
+// plain HTTP. Certificate files are re-read on SIGHUP; ACME certificates
+// are obtained and renewed in the background until ctx ends.
+func setupTLS(ctx context.Context, config *ebuse.ProductionConfig) (*tls.Config, error) {
+	files := config.TLSCertFile != "" || config.TLSKeyFile != ""
+	domains := commaList(config.ACMEDomains)
+	switch {
+	case files && len(domains) > 0:
+		return nil, errors.New("set either TLS_CERT_FILE and TLS_KEY_FILE or ACME_DOMAINS, not both")
+	case files && (config.TLSCertFile == "" || config.TLSKeyFile == ""):
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	switch {
+	case files:
+		reloader, err := tlscert.NewReloader(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = reloader.GetCertificate
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					slog.Error("Failed to reload TLS certificate, keeping current certificate", logging.AuditKey, true, "error", err)
+					continue
+				}
+				slog.Info("Reloaded TLS certificate", logging.AuditKey, true, "expires", reloader.Certificate().Leaf.NotAfter)
+			}
+		}()
+
+	case len(domains) > 0:
+		directoryURL := config.ACMEDirectoryURL
+		if directoryURL == "" {
+			directoryURL = acme.LetsEncrypt
+		}
+		manager, err := acme.NewManager(directoryURL, config.ACMEEmail, config.ACMECacheDir, domains)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+		go manager.Run(ctx)
+
+	default:
+		return nil, nil
+	}
+	return tlsConfig, nil
+}
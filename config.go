@@ -14,20 +14,125 @@ type ProductionConfig struct {
 	WriteTimeout      time.Duration
 	IdleTimeout       time.Duration
 	ShutdownTimeout   time.Duration
+	DrainDelay        time.Duration // Keep serving after SIGTERM while load balancers deregister the replica
+
+	// TLS (empty = plain HTTP, e.g. behind a terminating proxy)
+	TLSCertFile       string // PEM certificate chain, re-read on SIGHUP
+	TLSKeyFile        string // PEM private key of TLSCertFile
+	ACMEDomains       string // Comma-separated domains to obtain a certificate for from an ACME CA instead
+	ACMEEmail         string // Contact for the CA's expiry notices
+	ACMEDirectoryURL  string // CA directory (empty = Let's Encrypt)
+	ACMECacheDir      string // Directory keeping the ACME account key and certificate
 
 	// Database
 	DBPath            string
-	StoreBackend      string  // "sqlite" or "pebble"
+	StoreBackend      string  // "sqlite", "pebble", "postgres" or "memory" (single-tenant: "sqlite" unless "postgres" or "memory")
+	PostgresDSN       string        // Connection string for the postgres backend
+	PostgresSchema    string        // Schema holding the single-tenant store
+	PostgresMaxConns  int           // Connection pool size
+	WALCheckpointMB   int           // SQLite WAL size that triggers a TRUNCATE checkpoint (0 = disabled)
+	WALCheckInterval  time.Duration // How often the SQLite WAL size is checked
+	AnalyzeInterval   time.Duration // How often SQLite planner statistics are refreshed (0 = disabled)
+	AnalyzeAfterRows  int           // Refresh statistics early after this many writes (0 = disabled)
+	StrictPositions   bool          // SQLite assigns dense positions itself instead of AUTOINCREMENT
+	StartPosition     int64         // Head of a fresh store; its first event gets the next position (0 = start at 1)
+	RecoveryScanEvents int         // Newest events checked after an unclean shutdown (0 = none)
+	TenantsDB         string        // Control-plane database holding tenant definitions (multi-tenant)
+	TenantsDBDriver   string        // database/sql driver for TenantsDB
+	TenantsWatch      time.Duration // How often the tenants config file is checked for changes (0 = on SIGHUP only)
 
 	// Rate Limiting
 	RateLimit         int
 	RateBurst         int
+	MaxStreamsPerTenant int // Concurrent /events/stream requests per tenant (0 = unlimited)
+	TenantRateLimit   int    // Requests per second per tenant across all replicas (0 = unlimited; tenants.yaml rate_limit overrides)
+	TenantRateBurst   int    // Requests one second of a tenant may reach after idling (0 = the limit; tenants.yaml rate_burst overrides)
+	RateLimitRedisURL string // Redis shared by the replicas for tenant rate limit counts (empty = count per replica)
+	MaxInFlight       int // In-flight requests before reads/admin traffic is shed (0 = disabled)
+	ProbeCIDRs        string // Comma-separated networks whose probe and /metrics requests skip rate limiting and shedding
+	HealthAdminAuth   bool   // /readyz and /health require an admin key
+	DebugCapture      int    // Failed requests kept for /admin/debug/recent-errors (0 = disabled)
+	AuthLockoutThreshold int           // Failed authentications from one address before it is locked out (0 = disabled)
+	AuthLockoutBase      time.Duration // First lockout, doubled by every further one
+	AuthLockoutMax       time.Duration // Longest lockout
+	AuthLockoutPrefixThreshold int    // Failed authentications with one key prefix before it is locked out (0 = disabled)
+	TrustedProxyCIDRs          string // Comma-separated proxies whose X-Forwarded-For names the client for lockouts
 
 	// Features
 	EnableGzip        bool
+	RecordMetadata    bool // Store X-Ebuse-Meta-* headers on events
+	PipelineConfig    string // YAML file with write-time allow, deny, strip, PII and enrich rules (single-tenant)
+	FanoutRedisURL    string // Redis whose pub/sub wakes streams on every replica when events are written (empty = this replica only)
+
+	// Mirroring (single-tenant; tenants configure `mirror` in tenants.yaml)
+	MirrorURL         string // Remote ebuse server that receives a copy of every event
+	MirrorAPIKey      string
+
+	// Archival of closed position ranges to segment files
+	ArchiveURL           string        // Blob store URL (directory, s3://, gs://, azblob://); empty disables archival
+	ArchiveSegmentEvents int           // Positions per segment
+	ArchiveInterval      time.Duration // Delay between checks for closed ranges
+	HydrateFromArchive   bool          // Restore stores from the archive on boot, before any start position
+	TierAfter            time.Duration // Move archived events this old out of the store, still readable (0 = keep)
+
+	// Exports, replay jobs and archiving of history
+	HistoryWindow    string // Daily window they run in, e.g. "02:00-05:00" in server local time (empty = any time)
+	HistoryRateLimit int    // Events per second they read together on this replica (0 = unlimited)
+
+	// Retention (tenants override it with `retention` in tenants.yaml)
+	RetentionMaxAge    time.Duration // Prune events appended longer ago (0 = keep)
+	RetentionMaxEvents int           // Keep this many positions up to the head (0 = all)
+	RetentionInterval  time.Duration // Delay between prunes
+
+	// Scheduled backups (POST /admin/backup works without them)
+	BackupURL      string        // Blob store URL (directory, s3://, gs://, azblob://); empty disables them
+	BackupInterval time.Duration // Delay between backups
+	BackupKeep     int           // Backups kept per store; older ones are deleted
+
+	// Logging
+	LogOutput         string  // "stdout", "stderr", "syslog", "syslog://host:port" or a file path
+	LogFormat         string  // "json" or "text"
+	LogLevel          string  // "debug", "info", "warn" or "error"
+	LogMaxSizeMB      int     // Size at which a log file is rotated (0 = never)
+	LogMaxBackups     int     // Rotated log files kept
+	LogAccessSample   float64 // Fraction of successful request log lines written
+	AuditExport       string  // SIEM receiving audit records: https://..., syslog://host:port or syslog+tcp://host:port (empty = none)
+	AuditExportAuth   string  // Authorization header for an HTTP AuditExport
+	AuditExportBuffer int     // Audit records held while the SIEM is unreachable
 
 	// API
 	APIKey            string
+	AdminKey          string // Enables /admin endpoints with the owner role when set
+	AdminOperatorKey  string // Key for /admin endpoints with the operator role
+	AdminViewerKey    string // Key for /admin endpoints with the viewer role
+	TokenSecret       string        // Comma-separated HMAC secrets for /token, newest first (empty = tokens disabled)
+	TokenTTL          time.Duration // Lifetime of issued tokens
+	ServiceAccountsFile string      // JSON file holding service accounts (empty = in memory)
+
+	// JWT authentication (identity provider tokens)
+	JWTJWKSURL     string // JWKS URL of the issuer's RS256/ES256 keys
+	JWTSecret      string // Comma-separated HS256 secrets shared with the issuer, instead of JWTJWKSURL
+	JWTIssuer      string // Required iss claim (empty = not checked)
+	JWTAudience    string // Required aud claim (empty = not checked)
+	JWTTenantClaim string // Claim naming the tenant
+
+	// CORS (browser clients)
+	CORSAllowedOrigins string        // Comma-separated origins: "*", https://app.example.com or https://*.example.com (empty = CORS disabled)
+	CORSAllowedHeaders string        // Comma-separated request headers browsers may send (empty = server defaults)
+	CORSAllowedMethods string        // Comma-separated methods browsers may use (empty = server defaults)
+	CORSMaxAge         time.Duration // How long browsers cache preflight responses
+
+	// Admin login (OpenID Connect)
+	OIDCIssuer        string        // Provider URL; enables login for /admin endpoints when set
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string        // Public URL of /admin/callback
+	OIDCGroupsClaim   string        // ID token claim listing the user's groups
+	OIDCOwnerGroups   string        // Comma-separated groups granted the owner role
+	OIDCOperatorGroups string       // Comma-separated groups granted the operator role
+	OIDCViewerGroups  string        // Comma-separated groups granted the read-only viewer role
+	AdminSessionSecret string       // Comma-separated HMAC secrets for session cookies, newest first
+	AdminSessionTTL   time.Duration // Lifetime of an admin login
 }
 
 // LoadConfigFromEnv loads configuration from environment variables with production defaults
@@ -39,20 +144,125 @@ func LoadConfigFromEnv() *ProductionConfig {
 		WriteTimeout:    parseDuration("WRITE_TIMEOUT", 60*time.Second),
 		IdleTimeout:     parseDuration("IDLE_TIMEOUT", 120*time.Second),
 		ShutdownTimeout: parseDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		DrainDelay:      parseDuration("DRAIN_DELAY", 0),
+
+		// TLS defaults
+		TLSCertFile:      os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:       os.Getenv("TLS_KEY_FILE"),
+		ACMEDomains:      os.Getenv("ACME_DOMAINS"),
+		ACMEEmail:        os.Getenv("ACME_EMAIL"),
+		ACMEDirectoryURL: os.Getenv("ACME_DIRECTORY_URL"),
+		ACMECacheDir:     getEnv("ACME_CACHE_DIR", "acme"),
 
 		// Database defaults
 		DBPath:          getEnv("DB_PATH", "events.db"),
 		StoreBackend:    getEnv("STORE_BACKEND", "pebble"),
+		PostgresDSN:      os.Getenv("POSTGRES_DSN"),
+		PostgresSchema:   getEnv("POSTGRES_SCHEMA", "public"),
+		PostgresMaxConns: parseInt("POSTGRES_MAX_CONNS", 10),
+		WALCheckpointMB:  parseInt("WAL_CHECKPOINT_MB", 512),
+		WALCheckInterval: parseDuration("WAL_CHECK_INTERVAL", 30*time.Second),
+		AnalyzeInterval:  parseDuration("ANALYZE_INTERVAL", time.Hour),
+		AnalyzeAfterRows: parseInt("ANALYZE_AFTER_ROWS", 100000),
+		StrictPositions:  parseBool("STRICT_POSITIONS", false),
+		StartPosition:    int64(parseInt("START_POSITION", 0)),
+		RecoveryScanEvents: parseInt("RECOVERY_SCAN_EVENTS", 10000),
+		TenantsDB:        os.Getenv("TENANTS_DB"),
+		TenantsDBDriver:  getEnv("TENANTS_DB_DRIVER", "sqlite"),
+		TenantsWatch:     parseDuration("TENANTS_WATCH_INTERVAL", 0),
 
-		// Rate limiting defaults (per IP)
+		// Rate limiting defaults (per API key, or per IP without one)
 		RateLimit:       parseInt("RATE_LIMIT", 100),
 		RateBurst:       parseInt("RATE_BURST", 200),
+		MaxStreamsPerTenant: parseInt("MAX_STREAMS_PER_TENANT", 0),
+		TenantRateLimit:   parseInt("TENANT_RATE_LIMIT", 0),
+		TenantRateBurst:   parseInt("TENANT_RATE_BURST", 0),
+		RateLimitRedisURL: os.Getenv("RATE_LIMIT_REDIS_URL"),
+		MaxInFlight:     parseInt("MAX_IN_FLIGHT", 0),
+		ProbeCIDRs:      os.Getenv("PROBE_CIDRS"),
+		HealthAdminAuth: parseBool("HEALTH_ADMIN_AUTH", false),
+		DebugCapture:    parseInt("DEBUG_CAPTURE", 0),
+		AuthLockoutThreshold: parseInt("AUTH_LOCKOUT_THRESHOLD", 10),
+		AuthLockoutBase:      parseDuration("AUTH_LOCKOUT_BASE", time.Minute),
+		AuthLockoutMax:       parseDuration("AUTH_LOCKOUT_MAX", time.Hour),
+		AuthLockoutPrefixThreshold: parseInt("AUTH_LOCKOUT_PREFIX_THRESHOLD", 0),
+		TrustedProxyCIDRs:          os.Getenv("TRUSTED_PROXIES"),
 
 		// Features
 		EnableGzip:      parseBool("ENABLE_GZIP", true),
+		RecordMetadata:  parseBool("RECORD_METADATA", false),
+		PipelineConfig:  os.Getenv("PIPELINE_CONFIG"),
+		FanoutRedisURL:  os.Getenv("FANOUT_REDIS_URL"),
+
+		// Mirroring
+		MirrorURL:       os.Getenv("MIRROR_URL"),
+		MirrorAPIKey:    os.Getenv("MIRROR_API_KEY"),
+
+		// Archival
+		ArchiveURL:           os.Getenv("ARCHIVE_URL"),
+		ArchiveSegmentEvents: parseInt("ARCHIVE_SEGMENT_EVENTS", 100000),
+		ArchiveInterval:      parseDuration("ARCHIVE_INTERVAL", 5*time.Minute),
+		HydrateFromArchive:   parseBool("HYDRATE_FROM_ARCHIVE", false),
+		TierAfter:            parseDuration("TIER_AFTER", 0),
+
+		// History jobs
+		HistoryWindow:    os.Getenv("HISTORY_WINDOW"),
+		HistoryRateLimit: parseInt("HISTORY_RATE_LIMIT", 0),
+
+		// Retention
+		RetentionMaxAge:    parseDuration("RETENTION_MAX_AGE", 0),
+		RetentionMaxEvents: parseInt("RETENTION_MAX_EVENTS", 0),
+		RetentionInterval:  parseDuration("RETENTION_INTERVAL", 10*time.Minute),
+
+		// Backups
+		BackupURL:      os.Getenv("BACKUP_URL"),
+		BackupInterval: parseDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupKeep:     parseInt("BACKUP_KEEP", 7),
+
+		// Logging
+		LogOutput:       getEnv("LOG_OUTPUT", "stdout"),
+		LogFormat:       getEnv("LOG_FORMAT", "json"),
+		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		LogMaxSizeMB:    parseInt("LOG_MAX_SIZE_MB", 100),
+		LogMaxBackups:   parseInt("LOG_MAX_BACKUPS", 5),
+		LogAccessSample: parseFloat("LOG_ACCESS_SAMPLE", 1),
+		AuditExport:       os.Getenv("AUDIT_EXPORT"),
+		AuditExportAuth:   os.Getenv("AUDIT_EXPORT_AUTH"),
+		AuditExportBuffer: parseInt("AUDIT_EXPORT_BUFFER", 10000),
 
 		// Required
 		APIKey:          os.Getenv("API_KEY"),
+		AdminKey:        os.Getenv("ADMIN_KEY"),
+		AdminOperatorKey: os.Getenv("ADMIN_OPERATOR_KEY"),
+		AdminViewerKey:   os.Getenv("ADMIN_VIEWER_KEY"),
+		TokenSecret:     os.Getenv("TOKEN_SECRET"),
+		TokenTTL:        parseDuration("TOKEN_TTL", 15*time.Minute),
+		ServiceAccountsFile: os.Getenv("SERVICE_ACCOUNTS_FILE"),
+
+		// JWT authentication defaults (disabled)
+		JWTJWKSURL:     os.Getenv("JWT_JWKS_URL"),
+		JWTSecret:      os.Getenv("JWT_SECRET"),
+		JWTIssuer:      os.Getenv("JWT_ISSUER"),
+		JWTAudience:    os.Getenv("JWT_AUDIENCE"),
+		JWTTenantClaim: getEnv("JWT_TENANT_CLAIM", "tenant"),
+
+		// CORS defaults (disabled)
+		CORSAllowedOrigins: os.Getenv("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedHeaders: os.Getenv("CORS_ALLOWED_HEADERS"),
+		CORSAllowedMethods: os.Getenv("CORS_ALLOWED_METHODS"),
+		CORSMaxAge:         parseDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		// Admin login
+		OIDCIssuer:        os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCOwnerGroups:   os.Getenv("OIDC_OWNER_GROUPS"),
+		OIDCOperatorGroups: os.Getenv("OIDC_OPERATOR_GROUPS"),
+		OIDCViewerGroups:  os.Getenv("OIDC_VIEWER_GROUPS"),
+		AdminSessionSecret: os.Getenv("ADMIN_SESSION_SECRET"),
+		AdminSessionTTL:   parseDuration("ADMIN_SESSION_TTL", 8*time.Hour),
 	}
 }
 
@@ -81,6 +291,15 @@ func parseInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func parseFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
 func parseBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if b, err := strconv.ParseBool(value); err == nil {
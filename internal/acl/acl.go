@@ -0,0 +1,66 @@
+// Package acl limits API keys to some streams of their tenant. Patterns are
+// exact stream IDs or prefixes ending in "*", e.g. "billing/*"; "*" alone
+// matches every stream.
+package acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ACL lists the streams a key may read and append to
+type ACL struct {
+	read    []string
+	appends []string
+}
+
+// New returns the ACL of read and append patterns. A key without patterns
+// of a kind may not do that at all.
+func New(read, appends []string) (*ACL, error) {
+	for _, pattern := range concat(read, appends) {
+		if err := validate(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return &ACL{read: read, appends: appends}, nil
+}
+
+func concat(a, b []string) []string {
+	return append(append([]string(nil), a...), b...)
+}
+
+func validate(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty stream pattern")
+	}
+	if i := strings.IndexByte(pattern, '*'); i >= 0 && i != len(pattern)-1 {
+		return fmt.Errorf("stream pattern %q: '*' is only allowed at the end", pattern)
+	}
+	return nil
+}
+
+// CanRead reports whether the key may read streamID
+func (a *ACL) CanRead(streamID string) bool {
+	return match(a.read, streamID)
+}
+
+// CanAppend reports whether the key may append to streamID
+func (a *ACL) CanAppend(streamID string) bool {
+	return match(a.appends, streamID)
+}
+
+func match(patterns []string, streamID string) bool {
+	if streamID == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(streamID, prefix) {
+				return true
+			}
+		} else if pattern == streamID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package acl
+
+import "testing"
+
+func TestACL(t *testing.T) {
+	a, err := New([]string{"billing/*", "shared"}, []string{"billing/invoices/*"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		stream       string
+		read, append bool
+	}{
+		{"billing/invoices/7", true, true},
+		{"billing/customers/1", true, false},
+		{"shared", true, false},
+		{"shared/1", false, false},
+		{"orders/42", false, false},
+		{"", false, false},
+	} {
+		if got := a.CanRead(tc.stream); got != tc.read {
+			t.Errorf("CanRead(%q) = %v, expected %v", tc.stream, got, tc.read)
+		}
+		if got := a.CanAppend(tc.stream); got != tc.append {
+			t.Errorf("CanAppend(%q) = %v, expected %v", tc.stream, got, tc.append)
+		}
+	}
+
+	all, _ := New([]string{"*"}, nil)
+	if !all.CanRead("anything") || all.CanAppend("anything") {
+		t.Error("Expected * to match every stream for reads only")
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"", "billing/*/invoices", "*billing"} {
+		if _, err := New([]string{pattern}, nil); err == nil {
+			t.Errorf("Expected an error for %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+// Package acme obtains TLS certificates from an ACME certificate authority
+// such as Let's Encrypt (RFC 8555). Domains are validated with the
+// tls-alpn-01 challenge (RFC 8737), answered by the server's own TLS
+// listener, so no second port or DNS access is needed.
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LetsEncrypt is the directory of Let's Encrypt's production CA
+const LetsEncrypt = "https://acme-v02.api.letsencrypt.org/directory"
+
+// ALPNProto is the ALPN protocol of tls-alpn-01 validation requests; TLS
+// servers must list it in NextProtos
+const ALPNProto = "acme-tls/1"
+
+// idPeACMEIdentifier is the certificate extension carrying the tls-alpn-01
+// key authorization digest
+var idPeACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// Error is a problem document returned by the CA
+type Error struct {
+	Status int    `json:"status"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Type, e.Detail)
+}
+
+// Client talks to one CA with one account key
+type Client struct {
+	DirectoryURL string            // Default LetsEncrypt
+	Key          *ecdsa.PrivateKey // P-256 account key
+	Email        string            // Contact address for expiry notices (optional)
+	HTTPClient   *http.Client      // Default: 30 second timeout
+
+	mu    sync.Mutex
+	dir   *directory
+	kid   string   // Account URL, once registered
+	nonce []string // Unused replay nonces
+}
+
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type order struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+	Error          *Error   `json:"error"`
+}
+
+type authorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []struct {
+		Type   string `json:"type"`
+		URL    string `json:"url"`
+		Token  string `json:"token"`
+		Status string `json:"status"`
+		Error  *Error `json:"error"`
+	} `json:"challenges"`
+}
+
+// Obtain orders a certificate for domains, signed over certKey's public key,
+// and returns its DER chain, leaf first. present is called with the
+// tls-alpn-01 challenge certificate of each domain, which the server must
+// return to handshakes offering ALPNProto until Obtain returns.
+func (c *Client) Obtain(ctx context.Context, domains []string, certKey crypto.Signer, present func(domain string, cert *tls.Certificate)) ([][]byte, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("acme: no domains")
+	}
+	if err := c.register(ctx); err != nil {
+		return nil, err
+	}
+
+	identifiers := make([]map[string]string, len(domains))
+	for i, domain := range domains {
+		identifiers[i] = map[string]string{"type": "dns", "value": domain}
+	}
+	var o order
+	resp, err := c.post(ctx, c.dir.NewOrder, map[string]any{"identifiers": identifiers}, &o)
+	if err != nil {
+		return nil, fmt.Errorf("acme: new order: %w", err)
+	}
+	orderURL := resp.Header.Get("Location")
+
+	for _, authzURL := range o.Authorizations {
+		if err := c.authorize(ctx, authzURL, present); err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return nil, fmt.Errorf("acme: create CSR: %w", err)
+	}
+	if _, err := c.post(ctx, o.Finalize, map[string]string{"csr": b64(csr)}, &o); err != nil {
+		return nil, fmt.Errorf("acme: finalize: %w", err)
+	}
+	for o.Status != "valid" {
+		if o.Status == "invalid" {
+			return nil, fmt.Errorf("acme: order invalid: %v", o.Error)
+		}
+		if err := sleep(ctx, time.Second); err != nil {
+			return nil, err
+		}
+		if _, err := c.post(ctx, orderURL, nil, &o); err != nil {
+			return nil, fmt.Errorf("acme: poll order: %w", err)
+		}
+	}
+
+	resp, err = c.post(ctx, o.Certificate, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("acme: download certificate: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("acme: download certificate: %w", err)
+	}
+	var chain [][]byte
+	for block, rest := pem.Decode(body); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, block.Bytes)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("acme: CA returned no certificate")
+	}
+	return chain, nil
+}
+
+// authorize completes the tls-alpn-01 challenge of one authorization
+func (c *Client) authorize(ctx context.Context, authzURL string, present func(string, *tls.Certificate)) error {
+	var authz authorization
+	if _, err := c.post(ctx, authzURL, nil, &authz); err != nil {
+		return fmt.Errorf("acme: authorization: %w", err)
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	domain := authz.Identifier.Value
+
+	challengeURL := ""
+	for _, ch := range authz.Challenges {
+		if ch.Type != "tls-alpn-01" {
+			continue
+		}
+		cert, err := newChallengeCert(domain, ch.Token+"."+c.thumbprint())
+		if err != nil {
+			return err
+		}
+		present(domain, cert)
+		challengeURL = ch.URL
+	}
+	if challengeURL == "" {
+		return fmt.Errorf("acme: %s: CA offers no tls-alpn-01 challenge", domain)
+	}
+
+	resp, err := c.post(ctx, challengeURL, struct{}{}, nil)
+	if err != nil {
+		return fmt.Errorf("acme: %s: accept challenge: %w", domain, err)
+	}
+	resp.Body.Close()
+	for authz.Status != "valid" {
+		if authz.Status == "invalid" {
+			for _, ch := range authz.Challenges {
+				if ch.Error != nil {
+					return fmt.Errorf("acme: %s: validation failed: %w", domain, ch.Error)
+				}
+			}
+			return fmt.Errorf("acme: %s: validation failed", domain)
+		}
+		if err := sleep(ctx, time.Second); err != nil {
+			return err
+		}
+		if _, err := c.post(ctx, authzURL, nil, &authz); err != nil {
+			return fmt.Errorf("acme: %s: poll authorization: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// register creates the account, or looks up the existing one of c.Key
+func (c *Client) register(ctx context.Context) error {
+	c.mu.Lock()
+	registered := c.kid != ""
+	c.mu.Unlock()
+	if registered {
+		return nil
+	}
+	if err := c.discover(ctx); err != nil {
+		return err
+	}
+
+	account := map[string]any{"termsOfServiceAgreed": true}
+	if c.Email != "" {
+		account["contact"] = []string{"mailto:" + c.Email}
+	}
+	resp, err := c.post(ctx, c.dir.NewAccount, account, nil)
+	if err != nil {
+		return fmt.Errorf("acme: register account: %w", err)
+	}
+	resp.Body.Close()
+	c.mu.Lock()
+	c.kid = resp.Header.Get("Location")
+	c.mu.Unlock()
+	return nil
+}
+
+// discover fetches the directory
+func (c *Client) discover(ctx context.Context) error {
+	if c.dir != nil {
+		return nil
+	}
+	url := c.DirectoryURL
+	if url == "" {
+		url = LetsEncrypt
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("acme: directory: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme: directory: %s returned %d", url, resp.StatusCode)
+	}
+	var dir directory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return fmt.Errorf("acme: directory: %w", err)
+	}
+	if dir.NewNonce == "" || dir.NewAccount == "" || dir.NewOrder == "" {
+		return errors.New("acme: incomplete directory")
+	}
+	c.dir = &dir
+	return nil
+}
+
+// post sends a JWS-signed request; a nil payload is a POST-as-GET. The
+// response body is decoded into result when set, and otherwise left open
+// for the caller. A rejected nonce is retried once with a fresh one.
+func (c *Client) post(ctx context.Context, url string, payload, result any) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := c.sign(ctx, url, payload)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/jose+json")
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+			c.mu.Lock()
+			c.nonce = append(c.nonce, nonce)
+			c.mu.Unlock()
+		}
+
+		if resp.StatusCode >= 400 {
+			problem := &Error{Status: resp.StatusCode}
+			json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(problem)
+			resp.Body.Close()
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && attempt == 0 {
+				continue
+			}
+			return nil, problem
+		}
+		if result != nil {
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+				return nil, fmt.Errorf("decode response: %w", err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// sign wraps payload in a flattened JWS signed with the account key
+func (c *Client) sign(ctx context.Context, url string, payload any) ([]byte, error) {
+	nonce, err := c.takeNonce(ctx)
+	if err != nil {
+		return nil, err
+	}
+	protected := map[string]any{"alg": "ES256", "nonce": nonce, "url": url}
+	c.mu.Lock()
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = c.jwk()
+	}
+	c.mu.Unlock()
+
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	encodedPayload := ""
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		encodedPayload = b64(data)
+	}
+	signingInput := b64(header) + "." + encodedPayload
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, c.Key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   encodedPayload,
+		"signature": b64(signature),
+	})
+}
+
+// takeNonce returns a saved replay nonce or fetches a new one
+func (c *Client) takeNonce(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if n := len(c.nonce); n > 0 {
+		nonce := c.nonce[n-1]
+		c.nonce = c.nonce[:n-1]
+		c.mu.Unlock()
+		return nonce, nil
+	}
+	c.mu.Unlock()
+
+	if err := c.discover(ctx); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.dir.NewNonce, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("acme: new nonce: %w", err)
+	}
+	resp.Body.Close()
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("acme: CA returned no nonce")
+	}
+	return nonce, nil
+}
+
+// jwk is the public account key as a JSON Web Key, with members in the
+// lexicographic order the thumbprint needs
+func (c *Client) jwk() map[string]string {
+	return map[string]string{
+		"crv": "P-256",
+		"kty": "EC",
+		"x":   b64(c.Key.X.FillBytes(make([]byte, 32))),
+		"y":   b64(c.Key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// thumbprint is the RFC 7638 thumbprint of the account key
+func (c *Client) thumbprint() string {
+	data, _ := json.Marshal(c.jwk()) // Maps marshal with sorted keys
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// newChallengeCert returns the self-signed certificate answering the
+// tls-alpn-01 challenge of domain with keyAuth
+func newChallengeCert(domain, keyAuth string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(keyAuth))
+	value, err := asn1.Marshal(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		DNSNames:     []string{domain},
+		ExtraExtensions: []pkix.Extension{
+			{Id: idPeACMEIdentifier, Critical: true, Value: value},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// sleep waits for d, or returns ctx's error if it ends first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,336 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCA is an ACME server that validates tls-alpn-01 challenges by asking
+// validate for the certificate a handshake to the domain would get
+type fakeCA struct {
+	*httptest.Server
+	t        *testing.T
+	validate func(domain string) (*tls.Certificate, error)
+
+	mu         sync.Mutex
+	caKey      *ecdsa.PrivateKey
+	caCert     *x509.Certificate
+	nonces     map[string]bool
+	badNonce   bool                        // Reject the next nonce once
+	accounts   map[string]*ecdsa.PublicKey // By kid
+	thumbprint string
+	authz      map[string]string // Status by domain
+	tokens     map[string]string // By domain
+	order      map[string]any
+	issued     int
+}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(der)
+
+	ca := &fakeCA{
+		t:        t,
+		caKey:    caKey,
+		caCert:   caCert,
+		nonces:   make(map[string]bool),
+		badNonce: true,
+		accounts: make(map[string]*ecdsa.PublicKey),
+		authz:    make(map[string]string),
+		tokens:   make(map[string]string),
+	}
+	ca.Server = httptest.NewServer(http.HandlerFunc(ca.serve))
+	t.Cleanup(ca.Close)
+	return ca
+}
+
+func (ca *fakeCA) newNonce(w http.ResponseWriter) {
+	nonce := fmt.Sprintf("n%d", len(ca.nonces)+1)
+	ca.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+func (ca *fakeCA) problem(w http.ResponseWriter, status int, kind, detail string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Status: status, Type: "urn:ietf:params:acme:error:" + kind, Detail: detail})
+}
+
+func (ca *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.newNonce(w)
+
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(directory{NewNonce: ca.URL + "/nonce", NewAccount: ca.URL + "/account", NewOrder: ca.URL + "/order"})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+
+	// Every other request is a JWS signed by the account
+	var jws struct{ Protected, Payload, Signature string }
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		ca.problem(w, 400, "malformed", err.Error())
+		return
+	}
+	var protected struct {
+		Alg, Nonce, URL, Kid string
+		JWK                  map[string]string
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	json.Unmarshal(header, &protected)
+	if !ca.nonces[protected.Nonce] || ca.badNonce {
+		ca.badNonce = false
+		ca.problem(w, 400, "badNonce", "stale nonce")
+		return
+	}
+	delete(ca.nonces, protected.Nonce)
+	if protected.URL != ca.URL+r.URL.Path {
+		ca.problem(w, 401, "unauthorized", "url mismatch")
+		return
+	}
+
+	key := ca.accounts[protected.Kid]
+	if r.URL.Path == "/account" {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.JWK["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.JWK["y"])
+		key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if key == nil || protected.Alg != "ES256" || len(sig) != 64 ||
+		!ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		ca.problem(w, 401, "unauthorized", "bad signature")
+		return
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+
+	switch path := r.URL.Path; {
+	case path == "/account":
+		kid := ca.URL + "/account/1"
+		ca.accounts[kid] = key
+		canonical := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, protected.JWK["x"], protected.JWK["y"])
+		sum := sha256.Sum256([]byte(canonical))
+		ca.thumbprint = base64.RawURLEncoding.EncodeToString(sum[:])
+		w.Header().Set("Location", kid)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+
+	case path == "/order":
+		var req struct{ Identifiers []struct{ Value string } }
+		json.Unmarshal(payload, &req)
+		var authzs []string
+		for _, id := range req.Identifiers {
+			ca.authz[id.Value] = "pending"
+			ca.tokens[id.Value] = "token-" + id.Value
+			authzs = append(authzs, ca.URL+"/authz/"+id.Value)
+		}
+		ca.order = map[string]any{"status": "pending", "authorizations": authzs, "finalize": ca.URL + "/finalize"}
+		w.Header().Set("Location", ca.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ca.order)
+
+	case path == "/order/1":
+		json.NewEncoder(w).Encode(ca.order)
+
+	case strings.HasPrefix(path, "/authz/"):
+		domain := strings.TrimPrefix(path, "/authz/")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     ca.authz[domain],
+			"identifier": map[string]string{"type": "dns", "value": domain},
+			"challenges": []map[string]string{
+				{"type": "http-01", "url": ca.URL + "/chall-http/" + domain, "token": "unused"},
+				{"type": "tls-alpn-01", "url": ca.URL + "/chall/" + domain, "token": ca.tokens[domain]},
+			},
+		})
+
+	case strings.HasPrefix(path, "/chall/"):
+		domain := strings.TrimPrefix(path, "/chall/")
+		ca.authz[domain] = "invalid"
+		if cert, err := ca.validate(domain); err == nil && ca.checkChallenge(cert, domain) {
+			ca.authz[domain] = "valid"
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": ca.authz[domain]})
+
+	case path == "/finalize":
+		for domain, status := range ca.authz {
+			if status != "valid" {
+				ca.problem(w, 403, "orderNotReady", domain+" is not authorized")
+				return
+			}
+		}
+		var req struct{ CSR string }
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || csr.CheckSignature() != nil {
+			ca.problem(w, 400, "badCSR", "invalid CSR")
+			return
+		}
+		ca.issued++
+		leaf, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(int64(ca.issued + 1)),
+			Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}, ca.caCert, csr.PublicKey, ca.caKey)
+		if err != nil {
+			ca.t.Error(err)
+		}
+		ca.order["status"] = "valid"
+		ca.order["certificate"] = ca.URL + "/cert"
+		ca.order["leaf"] = leaf
+		json.NewEncoder(w).Encode(map[string]any{"status": "processing"})
+
+	case path == "/cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.order["leaf"].([]byte)})
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.caCert.Raw})
+
+	default:
+		ca.problem(w, 404, "malformed", "unknown resource")
+	}
+}
+
+// checkChallenge verifies a tls-alpn-01 certificate as RFC 8737 requires
+func (ca *fakeCA) checkChallenge(cert *tls.Certificate, domain string) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != domain {
+		return false
+	}
+	want := sha256.Sum256([]byte(ca.tokens[domain] + "." + ca.thumbprint))
+	for _, ext := range leaf.Extensions {
+		var got []byte
+		if ext.Id.Equal(idPeACMEIdentifier) && ext.Critical {
+			if _, err := asn1.Unmarshal(ext.Value, &got); err == nil && bytes.Equal(got, want[:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestManager(t *testing.T) {
+	ca := newFakeCA(t)
+	cacheDir := t.TempDir()
+	domains := []string{"events.example.com", "ebuse.example.com"}
+
+	m, err := NewManager(ca.URL+"/directory", "ops@example.com", cacheDir, domains)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	ca.validate = func(domain string) (*tls.Certificate, error) {
+		return m.GetCertificate(&tls.ClientHelloInfo{ServerName: domain, SupportedProtos: []string{ALPNProto}})
+	}
+
+	// Nothing is served before the certificate is issued
+	hello := &tls.ClientHelloInfo{ServerName: "events.example.com", SupportedProtos: []string{"h2", "http/1.1"}}
+	if _, err := m.GetCertificate(hello); err == nil {
+		t.Error("Expected no certificate before issuance")
+	}
+	if !m.renewAt().IsZero() {
+		t.Error("Expected a missing certificate to be due")
+	}
+
+	if err := m.Renew(context.Background()); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	cert, err := m.GetCertificate(hello)
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	if cert.Leaf.VerifyHostname("ebuse.example.com") != nil || len(cert.Certificate) != 2 {
+		t.Errorf("Expected a chain for both domains, got %v", cert.Leaf.DNSNames)
+	}
+	if until := time.Until(m.renewAt()); until < 59*24*time.Hour || until > 61*24*time.Hour {
+		t.Errorf("Expected renewal 30 days before expiry, in %v", until)
+	}
+
+	// Challenges end with the order
+	if _, err := ca.validate("events.example.com"); err == nil {
+		t.Error("Expected no challenge after issuance")
+	}
+
+	// A restart serves the cached certificate with the same account
+	if _, err := os.Stat(filepath.Join(cacheDir, "events.example.com.pem")); err != nil {
+		t.Fatalf("Expected the certificate to be cached: %v", err)
+	}
+	restarted, err := NewManager(ca.URL+"/directory", "ops@example.com", cacheDir, domains)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if again, err := restarted.GetCertificate(hello); err != nil || !again.Leaf.Equal(cert.Leaf) {
+		t.Errorf("Expected the cached certificate, got %v", err)
+	}
+	if restarted.client.thumbprint() != m.client.thumbprint() {
+		t.Error("Expected the cached account key")
+	}
+
+	// A cached certificate that does not cover the domains is not used
+	other, err := NewManager(ca.URL+"/directory", "", cacheDir, []string{"events.example.com", "new.example.com"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if _, err := other.GetCertificate(hello); err == nil {
+		t.Error("Expected a certificate missing a domain to be ignored")
+	}
+}
+
+func TestManagerValidationFails(t *testing.T) {
+	ca := newFakeCA(t)
+	m, err := NewManager(ca.URL+"/directory", "", t.TempDir(), []string{"events.example.com"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	// The CA reaches some other server
+	ca.validate = func(domain string) (*tls.Certificate, error) {
+		return newChallengeCert(domain, "someone-else")
+	}
+	if err := m.Renew(context.Background()); err == nil || !strings.Contains(err.Error(), "validation failed") {
+		t.Errorf("Expected validation to fail, got %v", err)
+	}
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "events.example.com"}); err == nil {
+		t.Error("Expected no certificate")
+	}
+}
@@ -0,0 +1,215 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRenewBefore is how long before expiry certificates are renewed
+const DefaultRenewBefore = 30 * 24 * time.Hour
+
+// Retry delays after failed attempts to obtain a certificate
+const (
+	minRetry = time.Minute
+	maxRetry = time.Hour
+)
+
+// Manager keeps a certificate for a set of domains: it serves the cached
+// one, obtains one when there is none and renews it before it expires. Its
+// GetCertificate also answers the CA's tls-alpn-01 validation handshakes.
+type Manager struct {
+	client      *Client
+	domains     []string
+	cacheDir    string
+	RenewBefore time.Duration // Default DefaultRenewBefore
+
+	cert       atomic.Pointer[tls.Certificate]
+	mu         sync.Mutex
+	challenges map[string]*tls.Certificate // By domain, while validating
+}
+
+// NewManager returns a Manager for domains at the CA of directoryURL.
+// cacheDir, created if needed, keeps the account key and the certificate
+// between restarts, which also avoids the CA's rate limits.
+func NewManager(directoryURL, email, cacheDir string, domains []string) (*Manager, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("acme: no domains")
+	}
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("acme: create cache: %w", err)
+	}
+	key, err := loadAccountKey(filepath.Join(cacheDir, "account.key"))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		client:     &Client{DirectoryURL: directoryURL, Key: key, Email: email},
+		domains:    domains,
+		cacheDir:   cacheDir,
+		challenges: make(map[string]*tls.Certificate),
+	}
+	if cert, err := tls.LoadX509KeyPair(m.certPath(), m.certPath()); err == nil && m.covers(cert.Leaf) {
+		m.cert.Store(&cert)
+	}
+	return m, nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate returning the managed
+// certificate, or the challenge certificate to validation handshakes
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if slices.Contains(hello.SupportedProtos, ALPNProto) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if cert, ok := m.challenges[hello.ServerName]; ok {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("acme: no challenge pending for %q", hello.ServerName)
+	}
+	if cert := m.cert.Load(); cert != nil {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("acme: certificate for %s not issued yet", m.domains[0])
+}
+
+// Run obtains the certificate if needed and renews it until ctx ends.
+// Failures are logged and retried with backoff while the current
+// certificate, if any, keeps being served.
+func (m *Manager) Run(ctx context.Context) {
+	retry := minRetry
+	for {
+		wait := time.Until(m.renewAt())
+		if wait <= 0 {
+			if err := m.Renew(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Failed to obtain TLS certificate", "domains", m.domains, "error", err, "retry_in", retry)
+				wait, retry = retry, min(retry*2, maxRetry)
+			} else {
+				retry = minRetry
+				wait = time.Until(m.renewAt())
+			}
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
+
+// Renew obtains a new certificate, caches and serves it
+func (m *Manager) Renew(ctx context.Context) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		m.mu.Lock()
+		clear(m.challenges)
+		m.mu.Unlock()
+	}()
+	chain, err := m.client.Obtain(ctx, m.domains, key, func(domain string, cert *tls.Certificate) {
+		m.mu.Lock()
+		m.challenges[domain] = cert
+		m.mu.Unlock()
+	})
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for _, der := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return fmt.Errorf("acme: issued certificate: %w", err)
+	}
+	if err := os.WriteFile(m.certPath(), data, 0600); err != nil {
+		slog.Warn("Failed to cache TLS certificate", "path", m.certPath(), "error", err)
+	}
+	m.cert.Store(&cert)
+	slog.Info("Obtained TLS certificate", "domains", m.domains, "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// renewAt returns when the current certificate is due for renewal: ahead of
+// expiry by RenewBefore, or a third of its lifetime for short-lived ones
+func (m *Manager) renewAt() time.Time {
+	cert := m.cert.Load()
+	if cert == nil {
+		return time.Time{}
+	}
+	before := m.RenewBefore
+	if before <= 0 {
+		before = DefaultRenewBefore
+	}
+	before = min(before, cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)/3)
+	return cert.Leaf.NotAfter.Add(-before)
+}
+
+// covers reports whether leaf is valid for all managed domains
+func (m *Manager) covers(leaf *x509.Certificate) bool {
+	if leaf == nil {
+		return false
+	}
+	for _, domain := range m.domains {
+		if leaf.VerifyHostname(domain) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) certPath() string {
+	return filepath.Join(m.cacheDir, m.domains[0]+".pem")
+}
+
+// loadAccountKey reads the account key at path, or creates it
+func loadAccountKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, fmt.Errorf("acme: save account key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("acme: read account key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("acme: %s holds no PEM key", path)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("acme: parse account key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,237 @@
+// Package antientropy finds and repairs events that differ between a
+// primary and a replica, catching silent replication bugs.
+//
+// Ranges are compared Merkle-style: both sides digest the same range split
+// into Fanout parts, and only parts whose digests differ are split further,
+// down to LeafSize events. Finding a handful of bad events in a large log
+// therefore costs a few requests per level instead of shipping the log.
+package antientropy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Digester returns digests of [from, to] split into chunks ranges, and its
+// head position; *client.HTTPClient implements it, StoreDigester wraps stores
+type Digester interface {
+	Digest(ctx context.Context, from, to int64, chunks int) (int64, []store.RangeDigest, error)
+}
+
+// StoreDigester digests a local store
+type StoreDigester struct {
+	Store store.EventStore
+}
+
+func (d StoreDigester) Digest(ctx context.Context, from, to int64, chunks int) (int64, []store.RangeDigest, error) {
+	head, err := d.Store.GetPosition(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	ranges, err := store.DigestRanges(ctx, d.Store, from, to, chunks)
+	return head, ranges, err
+}
+
+// Defaults for Options fields left at zero
+const (
+	DefaultFanout   = 16
+	DefaultLeafSize = 100
+)
+
+// Options bounds and tunes a comparison
+type Options struct {
+	From     int64 // First position to compare (default 1)
+	To       int64 // Last position to compare (default: the lower of both heads)
+	Fanout   int   // Parts each diverged range is split into
+	LeafSize int64 // Ranges of at most this many positions are reported, not split
+}
+
+// Range is an inclusive range of positions
+type Range struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Report is the outcome of a comparison
+type Report struct {
+	PrimaryHead int64   `json:"primary_head"`
+	ReplicaHead int64   `json:"replica_head"`
+	Checked     Range   `json:"checked"`
+	Diverged    []Range `json:"diverged"`
+	Requests    int     `json:"requests"` // Digest requests per side
+}
+
+// Diff compares primary and replica and reports the ranges that differ.
+// Positions past the lower head are not compared; a replica that is behind
+// is lagging rather than diverged.
+func Diff(ctx context.Context, primary, replica Digester, opts Options) (*Report, error) {
+	if opts.From < 1 {
+		opts.From = 1
+	}
+	if opts.Fanout < 2 {
+		opts.Fanout = DefaultFanout
+	}
+	if opts.LeafSize < 1 {
+		opts.LeafSize = DefaultLeafSize
+	}
+
+	// A one-chunk digest of the first position doubles as a head lookup
+	primaryHead, _, err := primary.Digest(ctx, 1, 1, 1)
+	if err != nil {
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+	replicaHead, _, err := replica.Digest(ctx, 1, 1, 1)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+
+	to := opts.To
+	if to == 0 {
+		to = min(primaryHead, replicaHead)
+	}
+	report := &Report{
+		PrimaryHead: primaryHead,
+		ReplicaHead: replicaHead,
+		Checked:     Range{From: opts.From, To: to},
+		Diverged:    []Range{},
+		Requests:    1,
+	}
+	if to < opts.From {
+		return report, nil
+	}
+
+	d := &differ{primary: primary, replica: replica, opts: opts, report: report}
+	if err := d.compare(ctx, opts.From, to); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type differ struct {
+	primary, replica Digester
+	opts             Options
+	report           *Report
+}
+
+// compare digests [from, to] on both sides and descends into the parts
+// that differ
+func (d *differ) compare(ctx context.Context, from, to int64) error {
+	chunks := d.opts.Fanout
+	if to-from+1 <= d.opts.LeafSize {
+		chunks = 1
+	}
+
+	_, want, err := d.primary.Digest(ctx, from, to, chunks)
+	if err != nil {
+		return fmt.Errorf("primary digest %d-%d: %w", from, to, err)
+	}
+	_, got, err := d.replica.Digest(ctx, from, to, chunks)
+	if err != nil {
+		return fmt.Errorf("replica digest %d-%d: %w", from, to, err)
+	}
+	d.report.Requests++
+	if len(want) != len(got) {
+		return fmt.Errorf("digest %d-%d: primary returned %d ranges, replica %d", from, to, len(want), len(got))
+	}
+
+	for i := range want {
+		if want[i] == got[i] {
+			continue
+		}
+		part := Range{From: want[i].From, To: want[i].To}
+		if part.To-part.From+1 <= d.opts.LeafSize {
+			d.report.Diverged = append(d.report.Diverged, part)
+			continue
+		}
+		if err := d.compare(ctx, part.From, part.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Loader reads events from the primary; stores and *client.HTTPClient
+// implement it
+type Loader interface {
+	Load(ctx context.Context, from, to int64) ([]*store.StoredEvent, error)
+}
+
+// Kinds of EventDiff
+const (
+	Missing  = "missing"  // Only the primary has the position
+	Extra    = "extra"    // Only the replica has the position
+	Mismatch = "mismatch" // Both have it with different checksums
+)
+
+// EventDiff is a position at which primary and replica differ
+type EventDiff struct {
+	Position        int64  `json:"position"`
+	Kind            string `json:"kind"`
+	PrimaryChecksum string `json:"primary_checksum,omitempty"` // store.EventChecksum
+	ReplicaChecksum string `json:"replica_checksum,omitempty"`
+}
+
+// Compare loads the events of each range from both sides and reports the
+// positions that differ, in position order. Ranges should be the small
+// ones Diff reports, since each is loaded whole.
+func Compare(ctx context.Context, primary, replica Loader, ranges []Range) ([]EventDiff, error) {
+	diffs := []EventDiff{}
+	for _, r := range ranges {
+		want, err := primary.Load(ctx, r.From, r.To)
+		if err != nil {
+			return nil, fmt.Errorf("load %d-%d from primary: %w", r.From, r.To, err)
+		}
+		got, err := replica.Load(ctx, r.From, r.To)
+		if err != nil {
+			return nil, fmt.Errorf("load %d-%d from replica: %w", r.From, r.To, err)
+		}
+
+		// Both are in position order with gaps where events are missing
+		i, j := 0, 0
+		for i < len(want) || j < len(got) {
+			switch {
+			case j == len(got) || i < len(want) && want[i].Position < got[j].Position:
+				diffs = append(diffs, EventDiff{Position: want[i].Position, Kind: Missing, PrimaryChecksum: store.EventChecksum(want[i])})
+				i++
+			case i == len(want) || got[j].Position < want[i].Position:
+				diffs = append(diffs, EventDiff{Position: got[j].Position, Kind: Extra, ReplicaChecksum: store.EventChecksum(got[j])})
+				j++
+			default:
+				if a, b := store.EventChecksum(want[i]), store.EventChecksum(got[j]); a != b {
+					diffs = append(diffs, EventDiff{Position: want[i].Position, Kind: Mismatch, PrimaryChecksum: a, ReplicaChecksum: b})
+				}
+				i++
+				j++
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// repairBatch matches the server's limit for /admin/repair
+const repairBatch = 1000
+
+// Repair copies the events of each range from the primary onto the replica,
+// overwriting them at their positions. Returns the number of events written.
+func Repair(ctx context.Context, primary Loader, replica store.Repairer, ranges []Range) (int, error) {
+	repaired := 0
+	for _, r := range ranges {
+		for from := r.From; from <= r.To; from += repairBatch {
+			to := min(from+repairBatch-1, r.To)
+			events, err := primary.Load(ctx, from, to)
+			if err != nil {
+				return repaired, fmt.Errorf("load %d-%d from primary: %w", from, to, err)
+			}
+			if len(events) == 0 {
+				continue
+			}
+			if err := replica.ReplaceEvents(ctx, events); err != nil {
+				return repaired, fmt.Errorf("repair %d-%d: %w", from, to, err)
+			}
+			repaired += len(events)
+		}
+	}
+	return repaired, nil
+}
@@ -0,0 +1,132 @@
+package antientropy
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func newStore(t *testing.T, name string) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func saveEvents(t *testing.T, st store.EventStore, n int) {
+	t.Helper()
+	for i := range n {
+		data, _ := json.Marshal(map[string]int{"n": i})
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: "Created", Data: data, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestDiffAndRepair(t *testing.T) {
+	ctx := context.Background()
+	primary := newStore(t, "primary")
+	replica := newStore(t, "replica")
+	saveEvents(t, primary, 2000)
+	saveEvents(t, replica, 1990)
+
+	// Corrupt two events far apart on the replica
+	corrupt := []*store.StoredEvent{
+		{Position: 150, Type: "Created", Data: json.RawMessage(`{"n":-1}`), Timestamp: time.Now()},
+		{Position: 1700, Type: "Deleted", Data: json.RawMessage(`{"n":1699}`), Timestamp: time.Now()},
+	}
+	if err := replica.ReplaceEvents(ctx, corrupt); err != nil {
+		t.Fatalf("ReplaceEvents failed: %v", err)
+	}
+
+	report, err := Diff(ctx, StoreDigester{primary}, StoreDigester{replica}, Options{LeafSize: 50})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if report.PrimaryHead != 2000 || report.ReplicaHead != 1990 || report.Checked.To != 1990 {
+		t.Errorf("Expected heads 2000/1990 checked through 1990, got %+v", report)
+	}
+	if len(report.Diverged) != 2 {
+		t.Fatalf("Expected 2 diverged ranges, got %+v", report.Diverged)
+	}
+	for i, pos := range []int64{150, 1700} {
+		r := report.Diverged[i]
+		if pos < r.From || pos > r.To || r.To-r.From+1 > 50 {
+			t.Errorf("Expected a leaf range around %d, got %+v", pos, r)
+		}
+	}
+
+	repaired, err := Repair(ctx, primary, replica, report.Diverged)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if repaired == 0 {
+		t.Error("Expected events to be repaired")
+	}
+
+	report, err = Diff(ctx, StoreDigester{primary}, StoreDigester{replica}, Options{LeafSize: 50})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(report.Diverged) != 0 {
+		t.Errorf("Expected no divergence after repair, got %+v", report.Diverged)
+	}
+	if head, _ := replica.GetPosition(ctx); head != 1990 {
+		t.Errorf("Expected repair to leave the replica head at 1990, got %d", head)
+	}
+}
+
+func TestDiff_Empty(t *testing.T) {
+	report, err := Diff(context.Background(), StoreDigester{newStore(t, "a")}, StoreDigester{newStore(t, "b")}, Options{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(report.Diverged) != 0 {
+		t.Errorf("Expected nothing to compare, got %+v", report.Diverged)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	event := func(position int64, data string) *store.StoredEvent {
+		return &store.StoredEvent{Position: position, Type: "Created", Data: json.RawMessage(data), Timestamp: time.Now()}
+	}
+
+	// The replica lost 2, has 3 the primary never had and a different 4;
+	// whitespace in 1 does not count
+	primary, replica := store.NewMemoryStore(), store.NewMemoryStore()
+	primary.ImportEvents(ctx, []*store.StoredEvent{event(1, `{"n":1}`), event(2, `{}`), event(4, `{"n":4}`), event(5, `{}`)})
+	replica.ImportEvents(ctx, []*store.StoredEvent{event(1, `{ "n": 1 }`), event(3, `{}`), event(4, `{"n":-4}`), event(5, `{}`)})
+
+	report, err := Diff(ctx, StoreDigester{primary}, StoreDigester{replica}, Options{LeafSize: 2})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	diffs, err := Compare(ctx, primary, replica, report.Diverged)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+
+	want := []struct {
+		position int64
+		kind     string
+	}{{2, Missing}, {3, Extra}, {4, Mismatch}}
+	if len(diffs) != len(want) {
+		t.Fatalf("Expected %d differences, got %+v", len(want), diffs)
+	}
+	for i, w := range want {
+		if diffs[i].Position != w.position || diffs[i].Kind != w.kind {
+			t.Errorf("Expected %s at %d, got %+v", w.kind, w.position, diffs[i])
+		}
+	}
+	if diffs[2].PrimaryChecksum == "" || diffs[2].PrimaryChecksum == diffs[2].ReplicaChecksum {
+		t.Errorf("Expected differing checksums, got %+v", diffs[2])
+	}
+}
@@ -0,0 +1,355 @@
+// Package archive rolls closed position ranges of a store into
+// zstd-compressed NDJSON segment files, for cheap long-term storage and
+// offline processing of history.
+//
+// Segments and a manifest are written to a blob store:
+//
+//	manifest.json
+//	segments/00000000000000000001-00000000000000100000.ndjson.zst
+//	segments/00000000000000100001-00000000000000200000.ndjson.zst
+//
+// The manifest lists every segment with its position range, event count,
+// time range and SHA-256, and is only updated after a segment was written.
+// It is the archive's checkpoint: after a crash the archiver rewrites the
+// segment that was in flight.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ManifestKey is the manifest's key in the blob store
+const ManifestKey = "manifest.json"
+
+// ErrCorrupt is returned by ReadSegment when a segment doesn't match its
+// manifest entry
+var ErrCorrupt = errors.New("archive segment is corrupt")
+
+// Defaults for Config fields left at zero
+const (
+	DefaultSegmentEvents = 100_000
+	DefaultInterval      = 5 * time.Minute
+)
+
+// Segment describes one segment file
+type Segment struct {
+	Key            string    `json:"key"`
+	From           int64     `json:"from"`
+	To             int64     `json:"to"`
+	Count          int64     `json:"count"` // Less than To-From+1 if the store has gaps
+	FirstTimestamp time.Time `json:"first_timestamp,omitzero"`
+	LastTimestamp  time.Time `json:"last_timestamp,omitzero"`
+	Bytes          int64     `json:"bytes"`  // Compressed size
+	SHA256         string    `json:"sha256"` // Of the compressed file
+}
+
+// Manifest lists the archived segments in position order
+type Manifest struct {
+	Version  int       `json:"version"`
+	Segments []Segment `json:"segments"`
+}
+
+// Next returns the first position not yet archived
+func (m *Manifest) Next() int64 {
+	if len(m.Segments) == 0 {
+		return 1
+	}
+	return m.Segments[len(m.Segments)-1].To + 1
+}
+
+// LoadManifest reads the manifest, or returns an empty one for a new archive
+func LoadManifest(ctx context.Context, bs blob.Store) (*Manifest, error) {
+	r, err := bs.Get(ctx, ManifestKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return &Manifest{Version: 1, Segments: []Segment{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	defer r.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// errSegmentDone stops the stream once a segment's last position was read
+var errSegmentDone = errors.New("segment complete")
+
+// segmentKey names segments so that lexical order is position order
+func segmentKey(from, to int64) string {
+	return fmt.Sprintf("segments/%020d-%020d.ndjson.zst", from, to)
+}
+
+// Config tunes an archiver
+type Config struct {
+	Name          string        // Used in logs, e.g. the tenant name
+	SegmentEvents int64         // Positions per segment
+	Interval      time.Duration // Delay between checks for closed ranges
+
+	// Throttle keeps archiving within a window and read rate, shared with
+	// exports and replays (nil = unlimited). Segments are only started
+	// while the window is open.
+	Throttle *throttle.Throttle
+}
+
+// Status is a snapshot of an archiver's progress
+type Status struct {
+	ArchivedPosition int64     `json:"archived_position"` // Last position in a segment
+	Segments         int       `json:"segments"`
+	Bytes            int64     `json:"bytes"` // Total compressed size
+	LastError        string    `json:"last_error,omitempty"`
+	LastSuccess      time.Time `json:"last_success,omitzero"`
+}
+
+// Archiver writes closed position ranges of a store to segment files
+type Archiver struct {
+	st     store.EventStore
+	bs     blob.Store
+	config Config
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns an archiver from st to bs; call Run to start it
+func New(st store.EventStore, bs blob.Store, config Config) *Archiver {
+	if config.SegmentEvents <= 0 {
+		config.SegmentEvents = DefaultSegmentEvents
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	return &Archiver{st: st, bs: bs, config: config}
+}
+
+// Status returns the archiver's current progress
+func (a *Archiver) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.status
+}
+
+// Run archives closed ranges every Interval until ctx is done. Failures are
+// logged and retried on the next tick.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.ArchiveClosed(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Archiving failed", "archive", a.config.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchiveClosed writes a segment for every complete range of SegmentEvents
+// positions up to the store's head that is not archived yet, and returns
+// how many segments were written
+func (a *Archiver) ArchiveClosed(ctx context.Context) (int, error) {
+	written, err := a.archiveClosed(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.status.LastError = err.Error()
+	} else {
+		a.status.LastError = ""
+		a.status.LastSuccess = time.Now()
+	}
+	return written, err
+}
+
+func (a *Archiver) archiveClosed(ctx context.Context) (int, error) {
+	manifest, err := LoadManifest(ctx, a.bs)
+	if err != nil {
+		return 0, err
+	}
+	a.setManifest(manifest)
+
+	head, err := a.st.GetPosition(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get position: %w", err)
+	}
+
+	written := 0
+	for from := manifest.Next(); from+a.config.SegmentEvents-1 <= head; from = manifest.Next() {
+		to := from + a.config.SegmentEvents - 1
+
+		// Outside the window, the next tick within it carries on
+		if a.config.Throttle.Closed() > 0 {
+			break
+		}
+
+		// Archived history must survive a crash of the store
+		if syncer, ok := a.st.(store.Syncer); ok {
+			if err := syncer.Sync(ctx, to); err != nil {
+				return written, fmt.Errorf("sync: %w", err)
+			}
+		}
+
+		segment, err := a.writeSegment(ctx, from, to)
+		if err != nil {
+			return written, err
+		}
+		if segment.Count != to-from+1 {
+			slog.Warn("Archived segment has gaps", "archive", a.config.Name, "from", from, "to", to, "events", segment.Count)
+		}
+
+		manifest.Segments = append(manifest.Segments, *segment)
+		if err := a.putManifest(ctx, manifest); err != nil {
+			return written, err
+		}
+		a.setManifest(manifest)
+		written++
+	}
+	return written, nil
+}
+
+// writeSegment compresses the events in [from, to] into a segment file
+func (a *Archiver) writeSegment(ctx context.Context, from, to int64) (*Segment, error) {
+	segment := &Segment{Key: segmentKey(from, to), From: from, To: to}
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("create zstd writer: %w", err)
+	}
+
+	err = a.st.LoadStream(ctx, from, 1000, func(batch []*store.StoredEvent) error {
+		if err := a.config.Throttle.Pace(ctx, len(batch)); err != nil {
+			return err
+		}
+		for _, event := range batch {
+			if event.Position > to {
+				return errSegmentDone
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := zw.Write(append(data, '\n')); err != nil {
+				return err
+			}
+
+			if segment.Count == 0 {
+				segment.FirstTimestamp = event.Timestamp
+			}
+			segment.LastTimestamp = event.Timestamp
+			segment.Count++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSegmentDone) {
+		zw.Close()
+		return nil, fmt.Errorf("read events %d-%d: %w", from, to, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress segment: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	segment.SHA256 = hex.EncodeToString(sum[:])
+	segment.Bytes = int64(buf.Len())
+
+	if err := a.bs.Put(ctx, segment.Key, &buf, segment.Bytes); err != nil {
+		return nil, fmt.Errorf("write segment %s: %w", segment.Key, err)
+	}
+	return segment, nil
+}
+
+func (a *Archiver) putManifest(ctx context.Context, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := a.bs.Put(ctx, ManifestKey, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// setManifest updates the status from the manifest
+func (a *Archiver) setManifest(manifest *Manifest) {
+	var total int64
+	for _, segment := range manifest.Segments {
+		total += segment.Bytes
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.ArchivedPosition = manifest.Next() - 1
+	a.status.Segments = len(manifest.Segments)
+	a.status.Bytes = total
+}
+
+// ReadSegment calls fn for every event of a segment in position order,
+// after verifying the file against its manifest entry
+func ReadSegment(ctx context.Context, bs blob.Store, segment Segment, fn func(*store.StoredEvent) error) error {
+	r, err := bs.Get(ctx, segment.Key)
+	if err != nil {
+		return fmt.Errorf("open segment %s: %w", segment.Key, err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("read segment %s: %w", segment.Key, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != segment.SHA256 {
+		return fmt.Errorf("%w: %s checksum mismatch", ErrCorrupt, segment.Key)
+	}
+
+	zr, err := zstd.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("open zstd stream: %w", err)
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	var count int64
+	for {
+		var event store.StoredEvent
+		if err := dec.Decode(&event); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorrupt, segment.Key, err)
+		}
+		if event.Position < segment.From || event.Position > segment.To {
+			return fmt.Errorf("%w: %s holds position %d", ErrCorrupt, segment.Key, event.Position)
+		}
+		count++
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	if count != segment.Count {
+		return fmt.Errorf("%w: %s holds %d events, manifest says %d", ErrCorrupt, segment.Key, count, segment.Count)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
+)
+
+func newStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func saveEvents(t *testing.T, st store.EventStore, n int) {
+	t.Helper()
+	for i := range n {
+		data, _ := json.Marshal(map[string]int{"n": i})
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: "Created", Data: data, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestArchiveClosed(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	dir := t.TempDir()
+	bs, err := blob.NewLocal(dir)
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	saveEvents(t, st, 25)
+
+	a := New(st, bs, Config{Name: "test", SegmentEvents: 10})
+	written, err := a.ArchiveClosed(ctx)
+	if err != nil {
+		t.Fatalf("ArchiveClosed failed: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("Expected 2 closed segments, got %d", written)
+	}
+
+	// Open ranges wait until they are complete
+	if written, _ := a.ArchiveClosed(ctx); written != 0 {
+		t.Errorf("Expected nothing new to archive, got %d segments", written)
+	}
+	saveEvents(t, st, 5)
+	if written, _ := New(st, bs, Config{SegmentEvents: 10}).ArchiveClosed(ctx); written != 1 {
+		t.Errorf("Expected the third segment once closed, got %d segments", written)
+	}
+
+	manifest, err := LoadManifest(ctx, bs)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if len(manifest.Segments) != 3 || manifest.Next() != 31 {
+		t.Fatalf("Expected 3 segments through position 30, got %+v", manifest)
+	}
+	second := manifest.Segments[1]
+	if second.From != 11 || second.To != 20 || second.Count != 10 || second.FirstTimestamp.IsZero() || second.Bytes == 0 {
+		t.Errorf("Unexpected segment entry: %+v", second)
+	}
+
+	var positions []int64
+	err = ReadSegment(ctx, bs, second, func(event *store.StoredEvent) error {
+		positions = append(positions, event.Position)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadSegment failed: %v", err)
+	}
+	if len(positions) != 10 || positions[0] != 11 || positions[9] != 20 {
+		t.Errorf("Expected positions 11-20, got %v", positions)
+	}
+
+	if status := a.Status(); status.ArchivedPosition != 20 || status.Segments != 2 || status.LastError != "" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestArchiveClosed_Window(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	bs, err := blob.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	saveEvents(t, st, 20)
+
+	window := func(start, end time.Duration) *throttle.Throttle {
+		now := time.Now()
+		w, err := throttle.ParseWindow(now.Add(start).Format("15:04")+"-"+now.Add(end).Format("15:04"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return throttle.New(w, 0)
+	}
+
+	// Closed ranges wait for the window
+	closed := New(st, bs, Config{SegmentEvents: 10, Throttle: window(time.Hour, 2*time.Hour)})
+	if written, err := closed.ArchiveClosed(ctx); err != nil || written != 0 {
+		t.Errorf("ArchiveClosed outside the window = %d, %v", written, err)
+	}
+	open := New(st, bs, Config{SegmentEvents: 10, Throttle: window(-time.Hour, time.Hour)})
+	if written, err := open.ArchiveClosed(ctx); err != nil || written != 2 {
+		t.Errorf("ArchiveClosed within the window = %d, %v", written, err)
+	}
+}
+
+func TestReadSegment_Corrupt(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	dir := t.TempDir()
+	bs, _ := blob.NewLocal(dir)
+	saveEvents(t, st, 10)
+
+	if _, err := New(st, bs, Config{SegmentEvents: 10}).ArchiveClosed(ctx); err != nil {
+		t.Fatalf("ArchiveClosed failed: %v", err)
+	}
+	manifest, _ := LoadManifest(ctx, bs)
+	segment := manifest.Segments[0]
+
+	path := filepath.Join(dir, filepath.FromSlash(segment.Key))
+	data, _ := os.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	err := ReadSegment(ctx, bs, segment, func(*store.StoredEvent) error { return nil })
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestHydrate(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	bs, _ := blob.NewLocal(t.TempDir())
+	saveEvents(t, source, 2500)
+
+	if _, err := New(source, bs, Config{SegmentEvents: 1000}).ArchiveClosed(ctx); err != nil {
+		t.Fatalf("ArchiveClosed failed: %v", err)
+	}
+
+	replacement := newStore(t)
+	head, err := Hydrate(ctx, bs, replacement)
+	if err != nil {
+		t.Fatalf("Hydrate failed: %v", err)
+	}
+	if head != 2000 {
+		t.Errorf("Expected the two closed segments restored, got head %d", head)
+	}
+
+	want, _ := source.Load(ctx, 1500, 1500)
+	got, _ := replacement.Load(ctx, 1500, 1500)
+	if len(got) != 1 || string(got[0].Data) != string(want[0].Data) {
+		t.Errorf("Expected position 1500 restored exactly, got %+v", got)
+	}
+
+	if _, err := Hydrate(ctx, bs, replacement); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Expected ErrNotEmpty for a store with events, got %v", err)
+	}
+}
+
+func TestHydrate_Resumes(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	bs, _ := blob.NewLocal(t.TempDir())
+	saveEvents(t, source, 2500)
+
+	if _, err := New(source, bs, Config{SegmentEvents: 1000}).ArchiveClosed(ctx); err != nil {
+		t.Fatalf("ArchiveClosed failed: %v", err)
+	}
+
+	// A hydration cut short partway through the first segment
+	partial := newStore(t)
+	events, _ := source.Load(ctx, 1, 1200)
+	if err := partial.ImportEvents(ctx, events); err != nil {
+		t.Fatalf("ImportEvents failed: %v", err)
+	}
+	head, err := Hydrate(ctx, bs, partial)
+	if err != nil {
+		t.Fatalf("Hydrate failed: %v", err)
+	}
+	if head != 2000 {
+		t.Errorf("Expected hydration resumed to 2000, got head %d", head)
+	}
+	want, _ := source.Load(ctx, 1201, 1201)
+	got, _ := partial.Load(ctx, 1201, 1201)
+	if len(got) != 1 || string(got[0].Data) != string(want[0].Data) {
+		t.Errorf("Expected position 1201 restored exactly, got %+v", got)
+	}
+
+	// A store with history of its own is left alone
+	other := newStore(t)
+	if err := other.Save(ctx, &store.StoredEvent{Type: "Other", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := Hydrate(ctx, bs, other); err == nil || errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Expected a mismatch error for a store with other history, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ErrNotEmpty is returned by Hydrate for stores already holding every
+// archived event
+var ErrNotEmpty = errors.New("store is not empty")
+
+// hydrateBatch is the number of events imported per transaction
+const hydrateBatch = 1000
+
+// Hydrate restores a store from the archive, keeping every event at its
+// archived position, and returns the restored head. Only archived segments
+// are restored; events after the last closed range were never archived and
+// must come from elsewhere, e.g. a mirror.
+//
+// Each batch commits on its own, so a hydration cut short leaves the store
+// holding a prefix of the archive. Hydrate resumes after the store's head,
+// once the event there matches its archived copy, and returns ErrNotEmpty
+// only when the head is at or past the last archived position.
+func Hydrate(ctx context.Context, bs blob.Store, st store.EventStore) (int64, error) {
+	importer, ok := st.(store.Importer)
+	if !ok {
+		return 0, errors.New("store does not support importing events")
+	}
+
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get position: %w", err)
+	}
+
+	manifest, err := LoadManifest(ctx, bs)
+	if err != nil {
+		return 0, err
+	}
+	if head != 0 && head >= manifest.Next()-1 {
+		return 0, ErrNotEmpty
+	}
+
+	// A resumed store must end in the archive's history, not its own
+	var last *store.StoredEvent
+	if head != 0 {
+		events, err := st.Load(ctx, head, head)
+		if err != nil {
+			return 0, fmt.Errorf("load head: %w", err)
+		}
+		if len(events) == 1 {
+			last = events[0]
+		}
+	}
+	matched := head == 0
+
+	batch := make([]*store.StoredEvent, 0, hydrateBatch)
+	flush := func() error {
+		if err := importer.ImportEvents(ctx, batch); err != nil {
+			return fmt.Errorf("import events: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, segment := range manifest.Segments {
+		if segment.To < head {
+			continue
+		}
+		err := ReadSegment(ctx, bs, segment, func(event *store.StoredEvent) error {
+			switch {
+			case event.Position < head:
+				return nil
+			case event.Position == head:
+				matched = last != nil && last.Type == event.Type && bytes.Equal(last.Data, event.Data)
+				return nil
+			case !matched:
+				return fmt.Errorf("store head %d does not match the archive", head)
+			}
+			batch = append(batch, event)
+			if len(batch) < hydrateBatch {
+				return nil
+			}
+			return flush()
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if !matched {
+		return 0, fmt.Errorf("store head %d does not match the archive", head)
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	if syncer, ok := st.(store.Syncer); ok {
+		if err := syncer.Sync(ctx, -1); err != nil {
+			return 0, fmt.Errorf("sync: %w", err)
+		}
+	}
+	return st.GetPosition(ctx)
+}
@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// TierKey is the key of the tier's boundary in the blob store
+const TierKey = "tier.json"
+
+// tierCacheSegments is how many decoded segments a tier keeps in memory,
+// so paging through cold events doesn't download a segment per page
+const tierCacheSegments = 2
+
+// tierState is the tier's boundary as stored under TierKey
+type tierState struct {
+	Before int64 `json:"before"` // Events before this position are read from segments
+}
+
+// Tier moves archived events out of a store and serves them back from
+// their segments, fetched on demand. It implements store.ColdTier: once it
+// is set on a store, Load and LoadStream read positions before the boundary
+// from the archive.
+type Tier struct {
+	st     store.EventStore
+	bs     blob.Store
+	before atomic.Int64
+
+	mu       sync.Mutex
+	manifest *Manifest
+	cache    []cachedSegment // Most recently used last
+}
+
+// cachedSegment holds the decoded events of a segment
+type cachedSegment struct {
+	key    string
+	events []*store.StoredEvent
+}
+
+// OpenTier returns the tier of st archived in bs, with the boundary the
+// last DeleteBefore left, and sets it on st
+func OpenTier(ctx context.Context, st store.EventStore, bs blob.Store) (*Tier, error) {
+	tiered, ok := st.(store.Tiered)
+	if !ok {
+		return nil, errors.New("store does not support a cold tier")
+	}
+	manifest, err := LoadManifest(ctx, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tier{st: st, bs: bs, manifest: manifest}
+	r, err := bs.Get(ctx, TierKey)
+	switch {
+	case errors.Is(err, blob.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("read tier: %w", err)
+	default:
+		var state tierState
+		err := json.NewDecoder(r).Decode(&state)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode tier: %w", err)
+		}
+		t.before.Store(state.Before)
+	}
+	tiered.SetColdTier(t)
+	return t, nil
+}
+
+// Before implements store.ColdTier
+func (t *Tier) Before() int64 {
+	return t.before.Load()
+}
+
+// DeleteBefore moves the events before position to the archive: they are
+// deleted from the store and read from their segments from then on. Only
+// archived events are moved, so position is capped at the first one not
+// archived yet.
+func (t *Tier) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	manifest, err := LoadManifest(ctx, t.bs)
+	if err != nil {
+		return 0, err
+	}
+	position = min(position, manifest.Next())
+
+	t.mu.Lock()
+	t.manifest = manifest
+	t.mu.Unlock()
+
+	// The boundary is moved before deleting, so no read finds a position
+	// in neither place
+	if position > t.before.Load() {
+		data, err := json.Marshal(tierState{Before: position})
+		if err != nil {
+			return 0, fmt.Errorf("encode tier: %w", err)
+		}
+		if err := t.bs.Put(ctx, TierKey, bytes.NewReader(data), int64(len(data))); err != nil {
+			return 0, fmt.Errorf("write tier: %w", err)
+		}
+		t.before.Store(position)
+	}
+	return t.st.DeleteBefore(ctx, position)
+}
+
+// Scan implements store.ColdTier, downloading the segments of [from, to]
+// that aren't cached
+func (t *Tier) Scan(ctx context.Context, from, to int64, fn func(*store.StoredEvent) error) error {
+	t.mu.Lock()
+	segments := t.manifest.Segments
+	t.mu.Unlock()
+
+	// Segments are in position order, so the first one that may hold from
+	// is found by binary search
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].To >= from })
+	for ; i < len(segments) && segments[i].From <= to; i++ {
+		events, err := t.segment(ctx, segments[i])
+		if err != nil {
+			return err
+		}
+		start := sort.Search(len(events), func(j int) bool { return events[j].Position >= from })
+		for _, event := range events[start:] {
+			if event.Position > to {
+				return nil
+			}
+			// Callers own the events they get
+			copied := *event
+			if err := fn(&copied); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// segment returns the events of segment, from the cache or the archive
+func (t *Tier) segment(ctx context.Context, segment Segment) ([]*store.StoredEvent, error) {
+	t.mu.Lock()
+	for i, cached := range t.cache {
+		if cached.key == segment.Key {
+			t.cache = append(append(t.cache[:i:i], t.cache[i+1:]...), cached)
+			t.mu.Unlock()
+			return cached.events, nil
+		}
+	}
+	t.mu.Unlock()
+
+	events := make([]*store.StoredEvent, 0, segment.Count)
+	err := ReadSegment(ctx, t.bs, segment, func(event *store.StoredEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, cached := range t.cache {
+		if cached.key == segment.Key {
+			return cached.events, nil // Fetched concurrently
+		}
+	}
+	if len(t.cache) == tierCacheSegments {
+		t.cache = t.cache[1:]
+	}
+	t.cache = append(t.cache, cachedSegment{key: segment.Key, events: events})
+	return events, nil
+}
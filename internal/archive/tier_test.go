@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestTier(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	bs, err := blob.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	saveEvents(t, st, 25)
+	if _, err := New(st, bs, Config{SegmentEvents: 10}).ArchiveClosed(ctx); err != nil {
+		t.Fatalf("ArchiveClosed failed: %v", err)
+	}
+
+	tier, err := OpenTier(ctx, st, bs)
+	if err != nil {
+		t.Fatalf("OpenTier failed: %v", err)
+	}
+	// Only the two archived segments can move
+	deleted, err := tier.DeleteBefore(ctx, 100)
+	if err != nil || deleted != 20 {
+		t.Fatalf("DeleteBefore = %d, %v, want 20", deleted, err)
+	}
+	if tier.Before() != 21 {
+		t.Errorf("Before = %d, want 21", tier.Before())
+	}
+
+	// Reads span the archive and the store
+	events, err := st.Load(ctx, 1, -1)
+	if err != nil || len(events) != 25 {
+		t.Fatalf("Load returned %d events, %v", len(events), err)
+	}
+	for i, event := range events {
+		if event.Position != int64(i+1) {
+			t.Fatalf("Event %d has position %d", i, event.Position)
+		}
+	}
+	if events, _ := st.Load(ctx, 8, 12); len(events) != 5 || events[0].Position != 8 {
+		t.Errorf("Load(8, 12) returned %d events", len(events))
+	}
+	var streamed []int64
+	err = st.LoadStream(ctx, 15, 4, func(batch []*store.StoredEvent) error {
+		for _, event := range batch {
+			streamed = append(streamed, event.Position)
+		}
+		return nil
+	})
+	if err != nil || len(streamed) != 11 || streamed[0] != 15 || streamed[10] != 25 {
+		t.Errorf("LoadStream from 15 = %v, %v", streamed, err)
+	}
+
+	// The boundary survives a restart
+	reopened, err := OpenTier(ctx, newStore(t), bs)
+	if err != nil || reopened.Before() != 21 {
+		t.Errorf("Reopened tier has Before %d, %v", reopened.Before(), err)
+	}
+}
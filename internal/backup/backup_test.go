@@ -0,0 +1,248 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/klauspost/compress/zstd"
+)
+
+func saveEvents(t *testing.T, st store.EventStore, n int) {
+	t.Helper()
+	for i := range n {
+		data, _ := json.Marshal(map[string]int{"n": i})
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: "Created", Data: data}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+// unpack extracts a backup into dir
+func unpack(t *testing.T, r io.Reader, dir string) {
+	t.Helper()
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		t.Fatalf("Failed to open zstd: %v", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		path := filepath.Join(dir, header.Name)
+		if header.Typeflag == tar.TypeDir {
+			os.MkdirAll(path, 0o755)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		data, _ := io.ReadAll(tr)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	stores := map[string]struct {
+		open    func() (store.EventStore, error)
+		restore func(dir string) (store.EventStore, error)
+	}{
+		"sqlite": {
+			open: func() (store.EventStore, error) { return store.NewSQLiteStore(dir + "/events.db") },
+			restore: func(dir string) (store.EventStore, error) {
+				return store.NewSQLiteStore(dir + "/events.db")
+			},
+		},
+		"pebble": {
+			open:    func() (store.EventStore, error) { return store.NewPebbleStore(dir + "/pebble") },
+			restore: func(dir string) (store.EventStore, error) { return store.NewPebbleStore(dir) },
+		},
+	}
+	for name, tc := range stores {
+		t.Run(name, func(t *testing.T) {
+			st, err := tc.open()
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			defer st.Close()
+			saveEvents(t, st, 20)
+
+			snap, err := Take(ctx, st)
+			if err != nil {
+				t.Fatalf("Take failed: %v", err)
+			}
+			defer snap.Close()
+			if snap.Position != 20 {
+				t.Errorf("Position = %d, want 20", snap.Position)
+			}
+			// Writes after the snapshot are not in it
+			saveEvents(t, st, 5)
+
+			var buf bytes.Buffer
+			n, err := snap.WriteTo(&buf)
+			if err != nil || n != int64(buf.Len()) {
+				t.Fatalf("WriteTo = %d, %v; wrote %d bytes", n, err, buf.Len())
+			}
+
+			restoredDir := t.TempDir()
+			unpack(t, &buf, restoredDir)
+			restored, err := tc.restore(restoredDir)
+			if err != nil {
+				t.Fatalf("Failed to open restored store: %v", err)
+			}
+			defer restored.Close()
+			events, err := restored.Load(ctx, 1, -1)
+			if err != nil || len(events) != 20 {
+				t.Fatalf("Restored store has %d events, %v", len(events), err)
+			}
+		})
+	}
+}
+
+func TestSnapshot_Memory(t *testing.T) {
+	if _, err := Take(context.Background(), store.NewMemoryStore()); !errors.Is(err, ErrNoFiles) {
+		t.Errorf("Take of a memory store = %v, want ErrNoFiles", err)
+	}
+}
+
+func TestScheduler_Rotation(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	bs, err := blob.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	saveEvents(t, st, 3)
+
+	sched := New(st, bs, Config{Name: "test", Keep: 2})
+	now := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	sched.now = func() time.Time { return now }
+	for range 4 {
+		now = now.Add(time.Hour)
+		if _, err := sched.Backup(ctx); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+	}
+
+	status := sched.Status()
+	if status.LastKey != "ebuse-20260301T060000Z.tar.zst" || status.LastPosition != 3 || status.LastBytes == 0 {
+		t.Errorf("Status = %+v", status)
+	}
+	keys, err := bs.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "ebuse-20260301T050000Z.tar.zst" || keys[1] != status.LastKey {
+		t.Errorf("Kept backups %v, want the newest 2", keys)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	var imported []*store.StoredEvent
+	for _, position := range []int64{3, 4, 7} {
+		imported = append(imported, &store.StoredEvent{Position: position, Type: "Created", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+	}
+	if err := st.ImportEvents(ctx, imported); err != nil {
+		t.Fatalf("ImportEvents failed: %v", err)
+	}
+	st.SaveSubscriptionPosition(ctx, "projector", 4)
+
+	snap, err := Take(ctx, st)
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	defer snap.Close()
+	var buf bytes.Buffer
+	if _, err := snap.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	packed := buf.Bytes()
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "acme.db")
+	tests := map[string]Expect{
+		"positions 5-6 are missing":   {},
+		"head is not the expected":    {Position: 8, AllowGaps: true},
+		"backend is not the tenant's": {Backend: "pebble"},
+	}
+	for name, expect := range tests {
+		if _, err := Restore(ctx, bytes.NewReader(packed), dst, expect); !errors.Is(err, ErrVerify) {
+			t.Errorf("%s: expected ErrVerify, got %v", name, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("%s: failed restore left %v behind", name, entries)
+		}
+	}
+
+	restored, err := Restore(ctx, bytes.NewReader(packed), dst, Expect{Backend: "sqlite", Position: 7, AllowGaps: true})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.Head != 7 || restored.First != 3 || len(restored.Gaps) != 1 || restored.Subscriptions["projector"] != 4 {
+		t.Errorf("Restored = %+v", restored)
+	}
+	if _, err := Restore(ctx, bytes.NewReader(packed), dst, Expect{AllowGaps: true}); err == nil {
+		t.Error("Expected restoring over an existing store to fail")
+	}
+
+	reopened, err := store.NewSQLiteStore(dst)
+	if err != nil {
+		t.Fatalf("Failed to open restored store: %v", err)
+	}
+	defer reopened.Close()
+	if events, err := reopened.Load(ctx, 1, -1); err != nil || len(events) != 3 {
+		t.Errorf("Restored store has %d events, %v", len(events), err)
+	}
+}
+
+func TestRestore_SubscriptionAhead(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewPebbleStore(filepath.Join(t.TempDir(), "pebble"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveEvents(t, st, 3)
+	// The consumer read events the backup does not have
+	st.SaveSubscriptionPosition(ctx, "projector", 5)
+
+	snap, err := Take(ctx, st)
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	defer snap.Close()
+	var buf bytes.Buffer
+	snap.WriteTo(&buf)
+
+	if _, err := Restore(ctx, &buf, filepath.Join(t.TempDir(), "acme"), Expect{Backend: "pebble"}); !errors.Is(err, ErrVerify) {
+		t.Errorf("Expected ErrVerify, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrVerify is wrapped by Restore when the restored store fails a check;
+// nothing is left at the destination
+var ErrVerify = errors.New("restored store failed verification")
+
+// maxRestoreGaps bounds the gaps listed in a Restored
+const maxRestoreGaps = 100
+
+// Expect is what a restored store must match. Zero fields are not checked.
+type Expect struct {
+	Backend   string // "sqlite" or "pebble"
+	Position  int64  // Head position, e.g. the X-Ebuse-Position of the backup
+	AllowGaps bool   // Accept missing positions between the first event and the head
+}
+
+// Restored describes a store restored by Restore
+type Restored struct {
+	Backend       string           `json:"backend"`
+	Path          string           `json:"path"`
+	Head          int64            `json:"head"`
+	First         int64            `json:"first_position,omitempty"` // Positions before it were pruned or skipped by a start position
+	Gaps          []store.Gap      `json:"gaps"`                     // Up to maxRestoreGaps after First
+	Subscriptions map[string]int64 `json:"subscriptions,omitempty"`
+}
+
+// Restore unpacks the backup read from r into a new store at dst, which
+// must not exist, and checks its position continuity: positions must be
+// dense from the first event to the head unless expect allows gaps, the
+// head must match expect, and no subscription may have read past the head,
+// since the positions after it will be handed out again. The store is
+// unpacked next to dst and moved into place only once it passes, so a
+// failed restore leaves nothing behind.
+func Restore(ctx context.Context, r io.Reader, dst string, expect Expect) (Restored, error) {
+	restored := Restored{Path: dst}
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		return restored, fmt.Errorf("%s already exists; move it aside to restore over it", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return restored, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".restore-")
+	if err != nil {
+		return restored, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := extract(r, tmp); err != nil {
+		return restored, fmt.Errorf("unpack backup: %w", err)
+	}
+
+	// SQLite backups hold the clone's events.db, Pebble backups the
+	// checkpoint's files
+	src := filepath.Join(tmp, "events.db")
+	if info, err := os.Stat(src); err == nil && !info.IsDir() {
+		restored.Backend = "sqlite"
+	} else if _, err := os.Stat(filepath.Join(tmp, "CURRENT")); err == nil {
+		restored.Backend, src = "pebble", tmp
+	} else {
+		return restored, errors.New("backup holds neither a SQLite nor a Pebble store")
+	}
+	if expect.Backend != "" && expect.Backend != restored.Backend {
+		return restored, fmt.Errorf("%w: backup holds a %s store, %s expects %s", ErrVerify, restored.Backend, dst, expect.Backend)
+	}
+
+	var st store.EventStore
+	if restored.Backend == "sqlite" {
+		st, err = store.NewSQLiteStore(src)
+	} else {
+		st, err = store.NewPebbleStore(src)
+	}
+	if err != nil {
+		return restored, fmt.Errorf("open restored store: %w", err)
+	}
+	err = verify(ctx, st, expect, &restored)
+	if closeErr := st.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return restored, err
+	}
+
+	if restored.Backend == "sqlite" {
+		err = os.Rename(src, dst)
+	} else {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		return restored, fmt.Errorf("move restored store into place: %w", err)
+	}
+	return restored, nil
+}
+
+// verify checks the position continuity of a restored store
+func verify(ctx context.Context, st store.EventStore, expect Expect, restored *Restored) error {
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		return err
+	}
+	restored.Head = head
+	if expect.Position != 0 && head != expect.Position {
+		return fmt.Errorf("%w: head is %d, expected %d", ErrVerify, head, expect.Position)
+	}
+
+	// Positions before the first event were pruned or never written, and
+	// are not gaps
+	if restored.First, err = store.FirstPosition(ctx, st); err != nil {
+		return fmt.Errorf("find first position: %w", err)
+	}
+	gaps, err := store.FindGaps(ctx, st, 1, head, maxRestoreGaps+1)
+	if err != nil {
+		return fmt.Errorf("find gaps: %w", err)
+	}
+	restored.Gaps = append([]store.Gap{}, gaps[:min(len(gaps), maxRestoreGaps)]...)
+	if len(gaps) > 0 && !expect.AllowGaps {
+		return fmt.Errorf("%w: positions %d-%d are missing (and %d more gaps)", ErrVerify, gaps[0].From, gaps[0].To, len(gaps)-1)
+	}
+
+	if lister, ok := store.As[store.SubscriptionLister](st); ok {
+		if restored.Subscriptions, err = lister.Subscriptions(ctx); err != nil {
+			return fmt.Errorf("list subscriptions: %w", err)
+		}
+		for id, position := range restored.Subscriptions {
+			if position > head {
+				return fmt.Errorf("%w: subscription %s is at position %d, past the head %d", ErrVerify, id, position, head)
+			}
+		}
+	}
+	return nil
+}
+
+// extract unpacks a zstd-compressed tar into dir, refusing entries that
+// would land outside it
+func extract(r io.Reader, dir string) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("entry %q is outside the store", header.Name)
+		}
+		path := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(path, tr); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("entry %q is not a file or directory", header.Name)
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Latest returns the key of the newest backup in bs
+func Latest(ctx context.Context, bs blob.Store) (string, error) {
+	keys, err := list(ctx, bs)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", errors.New("no backups found")
+	}
+	return keys[len(keys)-1], nil
+}
+
+// list returns the keys of the backups in bs, oldest first
+func list(ctx context.Context, bs blob.Store) ([]string, error) {
+	keys, err := bs.List(ctx, "ebuse-")
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	return slices.DeleteFunc(keys, func(key string) bool { return !strings.HasSuffix(key, keySuffix) }), nil
+}
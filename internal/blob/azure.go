@@ -0,0 +1,195 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// azureVersion is the Blob service REST API version requests are made with
+const azureVersion = "2021-08-06"
+
+// AzureConfig configures an Azure Blob Storage container
+type AzureConfig struct {
+	Account   string
+	Container string
+	SASToken  string // Shared access signature with read/write/list/delete on the container
+	Endpoint  string // Optional: service endpoint, e.g. for Azurite
+}
+
+// Azure stores blobs as block blobs in a container. Objects are written
+// with a single Put Blob call, which Azure limits to 5000 MiB.
+type Azure struct {
+	client    *http.Client
+	base      *url.URL
+	container string
+	sas       url.Values
+}
+
+// NewAzure returns a store for the configured container
+func NewAzure(config AzureConfig) (*Azure, error) {
+	if config.Account == "" || config.Container == "" {
+		return nil, fmt.Errorf("azblob: account and container must be set")
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(config.SASToken, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("azblob: parse SAS token: %w", err)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", config.Account)
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("azblob: parse endpoint: %w", err)
+	}
+
+	return &Azure{
+		client:    &http.Client{},
+		base:      base,
+		container: config.Container,
+		sas:       sas,
+	}, nil
+}
+
+// request builds a request for key (or the container when key is empty)
+// authorized by the SAS token
+func (a *Azure) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	u := *a.base
+	setObjectPath(&u, strings.Trim(a.base.Path, "/"), a.container, key)
+
+	q := url.Values{}
+	for k, v := range a.sas {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	return req, nil
+}
+
+func (a *Azure) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	body, size, err := bufferBody(r, size)
+	if err != nil {
+		return err
+	}
+
+	req, err := a.request(ctx, http.MethodPut, key, nil, body, size)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "put", key); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (a *Azure) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	req, err := a.request(ctx, http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "get", key); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// enumerationResults is the part of a List Blobs response we use
+type enumerationResults struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (a *Azure) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		req, err := a.request(ctx, http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		if err := checkResponse(resp, "list", prefix); err != nil {
+			return nil, err
+		}
+
+		var result enumerationResults
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list %s: decode response: %w", prefix, err)
+		}
+
+		for _, b := range result.Blobs.Blob {
+			keys = append(keys, b.Name)
+		}
+		if result.NextMarker == "" {
+			return keys, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (a *Azure) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	req, err := a.request(ctx, http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "delete", key); err != nil && !isNotFound(err) {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAzure is an in-memory Blob service endpoint for one container
+func fakeAzure(container string) *httptest.Server {
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sig") != "secret-sig" || r.Header.Get("x-ms-version") == "" {
+			http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+			return
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+container), "/")
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case name == "" && q.Get("comp") == "list":
+			var names []string
+			for n := range blobs {
+				if strings.HasPrefix(n, q.Get("prefix")) {
+					names = append(names, n)
+				}
+			}
+			sort.Strings(names)
+
+			start := 0
+			if marker := q.Get("marker"); marker != "" {
+				start = sort.SearchStrings(names, marker)
+			}
+			type blob struct{ Name string }
+			var result struct {
+				XMLName xml.Name `xml:"EnumerationResults"`
+				Blobs   struct {
+					Blob []blob
+				}
+				NextMarker string
+			}
+			if start < len(names) {
+				result.Blobs.Blob = append(result.Blobs.Blob, blob{names[start]})
+			}
+			if start+1 < len(names) {
+				result.NextMarker = names[start+1]
+			}
+			xml.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				http.Error(w, "MissingRequiredHeader", http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			blobs[name] = data
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet:
+			data, ok := blobs[name]
+			if !ok {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			if _, ok := blobs[name]; !ok {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+			delete(blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "UnsupportedHttpVerb", http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestAzure(t *testing.T) {
+	srv := fakeAzure("events")
+	defer srv.Close()
+
+	s, err := NewAzure(AzureConfig{
+		Account:   "account",
+		Container: "events",
+		SASToken:  "?sv=2021-08-06&sig=secret-sig",
+		Endpoint:  srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewAzure failed: %v", err)
+	}
+	testStore(t, s)
+}
@@ -0,0 +1,248 @@
+// Package blob abstracts the object storage that backups, archival and
+// exports write to, so those subsystems share one implementation per
+// provider instead of each handling cloud APIs themselves.
+//
+// Destinations are configured as URLs:
+//
+//	/var/backups/ebuse                 local directory (also file:///...)
+//	s3://bucket/prefix                 AWS S3 (AWS_* credentials, ?region=)
+//	s3://bucket/prefix?endpoint=URL    S3-compatible (MinIO, R2, ...), path-style
+//	gs://bucket/prefix                 Google Cloud Storage via HMAC keys
+//	azblob://account/container/prefix  Azure Blob Storage with a SAS token
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/sigv4"
+)
+
+// ErrNotFound is returned by Get for keys that don't exist
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a flat key/value object store. Keys are slash-separated paths.
+type Store interface {
+	// Put writes r under key, replacing any existing object. size is the
+	// length of r, or -1 if unknown (the object is then buffered in memory).
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns the keys starting with prefix, in lexical order
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Open returns the store for a destination URL
+func Open(rawURL string) (Store, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("empty blob store URL")
+	}
+	if !strings.Contains(rawURL, "://") {
+		return NewLocal(rawURL)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse blob store URL: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	q := u.Query()
+
+	var store Store
+	switch u.Scheme {
+	case "file":
+		return NewLocal(u.Path)
+
+	case "s3":
+		creds, err := sigv4.CredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		region := q.Get("region")
+		if region == "" {
+			region = sigv4.RegionFromEnv()
+		}
+		if region == "" {
+			return nil, fmt.Errorf("s3: region must be set (?region= or AWS_REGION)")
+		}
+		store, err = NewS3(S3Config{
+			Bucket:      u.Host,
+			Region:      region,
+			Endpoint:    q.Get("endpoint"),
+			Credentials: creds,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "gs":
+		creds := sigv4.Credentials{
+			AccessKey: os.Getenv("GCS_HMAC_ACCESS_KEY"),
+			SecretKey: os.Getenv("GCS_HMAC_SECRET"),
+		}
+		if creds.AccessKey == "" || creds.SecretKey == "" {
+			return nil, fmt.Errorf("gs: GCS_HMAC_ACCESS_KEY and GCS_HMAC_SECRET must be set")
+		}
+		store, err = NewS3(S3Config{
+			Bucket:      u.Host,
+			Region:      "auto",
+			Endpoint:    "https://storage.googleapis.com",
+			Credentials: creds,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "azblob":
+		// azblob://account/container/prefix
+		container, rest, _ := strings.Cut(prefix, "/")
+		prefix = rest
+		sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+		if sas == "" {
+			return nil, fmt.Errorf("azblob: AZURE_STORAGE_SAS_TOKEN must be set")
+		}
+		store, err = NewAzure(AzureConfig{
+			Account:   u.Host,
+			Container: container,
+			SASToken:  sas,
+			Endpoint:  q.Get("endpoint"),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported blob store scheme %q", u.Scheme)
+	}
+
+	return WithPrefix(store, prefix), nil
+}
+
+// validKey rejects keys that could escape a directory or prefix
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
+
+// prefixed stores every key under a fixed prefix
+type prefixed struct {
+	Store
+	prefix string
+}
+
+// WithPrefix scopes s to keys under prefix, which is hidden from callers
+func WithPrefix(s Store, prefix string) Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	return &prefixed{Store: s, prefix: prefix + "/"}
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return p.Store.Put(ctx, p.prefix+key, r, size)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	return p.Store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := p.Store.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, p.prefix)
+	}
+	return keys, nil
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return p.Store.Delete(ctx, p.prefix+key)
+}
+
+// bufferBody returns a reader of known size, buffering r if size is unknown
+func bufferBody(r io.Reader, size int64) (io.Reader, int64, error) {
+	if size >= 0 {
+		return r, size, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("buffer blob: %w", err)
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// escapeKey escapes each path segment of a key for use in a URL
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(url.QueryEscape(part), "+", "%20")
+	}
+	return strings.Join(parts, "/")
+}
+
+// setObjectPath points u at the joined segments (bucket, key, ...), keeping
+// the escaped form so signatures computed over the path match what is sent
+func setObjectPath(u *url.URL, segments ...string) {
+	var plain, raw strings.Builder
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		plain.WriteString("/" + segment)
+		raw.WriteString("/" + escapeKey(segment))
+	}
+	u.Path = plain.String()
+	u.RawPath = raw.String()
+	if u.Path == "" {
+		u.Path, u.RawPath = "/", ""
+	}
+}
+
+// checkResponse turns non-2xx responses into errors, closing the body
+func checkResponse(resp *http.Response, op, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", op, key, ErrNotFound)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s: status %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
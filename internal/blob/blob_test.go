@@ -0,0 +1,153 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testStore runs the behavior every Store must share
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	put := func(key, data string, size int64) {
+		t.Helper()
+		if err := s.Put(ctx, key, strings.NewReader(data), size); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+	}
+	get := func(key string) string {
+		t.Helper()
+		r, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", key, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", key, err)
+		}
+		return string(data)
+	}
+
+	put("backups/2024/01.ndjson", "first", 5)
+	put("backups/2024/02 march+.ndjson", "second", -1) // unknown size, awkward characters
+	put("archive/segment-1", "segment", 7)
+	put("backups/2024/01.ndjson", "replaced", 8)
+
+	if got := get("backups/2024/01.ndjson"); got != "replaced" {
+		t.Errorf("Expected replaced object, got %q", got)
+	}
+	if got := get("backups/2024/02 march+.ndjson"); got != "second" {
+		t.Errorf("Expected second object, got %q", got)
+	}
+
+	keys, err := s.List(ctx, "backups/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	want := []string{"backups/2024/01.ndjson", "backups/2024/02 march+.ndjson"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+
+	if _, err := s.Get(ctx, "backups/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := s.Delete(ctx, "archive/segment-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete(ctx, "archive/segment-1"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	if keys, _ := s.List(ctx, "archive/"); len(keys) != 0 {
+		t.Errorf("Expected no archive keys after delete, got %v", keys)
+	}
+
+	for _, key := range []string{"", "/abs", "../escape", "a/../../b", "dir/"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("Expected invalid key %q to be rejected", key)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	testStore(t, s)
+}
+
+func TestWithPrefix(t *testing.T) {
+	dir := t.TempDir()
+	base, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	testStore(t, WithPrefix(base, "/tenants/alice/"))
+
+	// Objects live below the prefix in the underlying store
+	keys, err := base.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "tenants/alice/") {
+			t.Errorf("Expected key under prefix, got %s", key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, url := range []string{filepath.Join(dir, "plain"), "file://" + filepath.Join(dir, "file")} {
+		s, err := Open(url)
+		if err != nil {
+			t.Fatalf("Open %s failed: %v", url, err)
+		}
+		if _, ok := s.(*Local); !ok {
+			t.Errorf("Expected local store for %s, got %T", url, s)
+		}
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := Open("s3://bucket/prefix"); err == nil {
+		t.Error("Expected error without a region")
+	}
+	s, err := Open("s3://bucket/prefix?region=eu-west-1")
+	if err != nil {
+		t.Fatalf("Open s3 failed: %v", err)
+	}
+	if p, ok := s.(*prefixed); !ok || p.prefix != "prefix/" {
+		t.Errorf("Expected prefixed s3 store, got %T", s)
+	}
+
+	t.Setenv("GCS_HMAC_ACCESS_KEY", "")
+	if _, err := Open("gs://bucket"); err == nil {
+		t.Error("Expected error without GCS HMAC keys")
+	}
+
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "sv=2021-08-06&sig=abc")
+	s, err = Open("azblob://account/container/backups")
+	if err != nil {
+		t.Fatalf("Open azblob failed: %v", err)
+	}
+	if p, ok := s.(*prefixed); !ok || p.prefix != "backups/" {
+		t.Errorf("Expected prefixed azure store, got %T", s)
+	}
+
+	if _, err := Open("ftp://host/path"); err == nil {
+		t.Error("Expected error for unsupported scheme")
+	}
+}
@@ -0,0 +1,183 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jilio/ebuse/internal/sigv4"
+)
+
+// S3Config configures an S3 or S3-compatible bucket
+type S3Config struct {
+	Bucket      string
+	Region      string
+	Endpoint    string // Optional: S3-compatible endpoint, addressed path-style
+	Credentials sigv4.Credentials
+}
+
+// S3 stores blobs in an S3 bucket. Objects are written with a single PUT,
+// which S3 limits to 5 GiB.
+type S3 struct {
+	client   *http.Client
+	base     *url.URL
+	bucket   string // Path segment for path-style endpoints, empty for virtual-hosted
+	region   string
+	creds    sigv4.Credentials
+	signTime func() time.Time
+}
+
+// NewS3 returns a store for the configured bucket
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket must be set")
+	}
+
+	s := &S3{
+		client:   &http.Client{},
+		region:   config.Region,
+		creds:    config.Credentials,
+		signTime: time.Now,
+	}
+
+	if config.Endpoint != "" {
+		base, err := url.Parse(config.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("s3: parse endpoint: %w", err)
+		}
+		s.base = base
+		s.bucket = config.Bucket
+	} else {
+		s.base = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", config.Bucket, config.Region)}
+	}
+	return s, nil
+}
+
+// request builds a signed request for key (or the bucket when key is empty)
+func (s *S3) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	u := *s.base
+	setObjectPath(&u, s.bucket, key)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	sigv4.Sign(req, sigv4.UnsignedPayload, s.creds, s.region, "s3", s.signTime())
+	return req, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	body, size, err := bufferBody(r, size)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(ctx, http.MethodPut, key, nil, body, size)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "put", key); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "get", key); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response we use
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := s.request(ctx, http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		if err := checkResponse(resp, "list", prefix); err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list %s: decode response: %w", prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	if err := checkResponse(resp, "delete", key); err != nil && !isNotFound(err) {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
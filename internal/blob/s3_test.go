@@ -0,0 +1,119 @@
+package blob
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/sigv4"
+)
+
+// fakeS3 is an in-memory, path-style S3 endpoint for a single bucket
+func fakeS3(bucket string) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			r.Header.Get("X-Amz-Content-Sha256") != sigv4.UnsignedPayload {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/"+bucket)
+		key := strings.TrimPrefix(path, "/")
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case key == "" && r.Method == http.MethodGet:
+			if r.URL.Query().Get("list-type") != "2" {
+				http.Error(w, "InvalidArgument", http.StatusBadRequest)
+				return
+			}
+			prefix := r.URL.Query().Get("prefix")
+			var keys []string
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			// One key per page exercises continuation
+			start := 0
+			if token := r.URL.Query().Get("continuation-token"); token != "" {
+				start = sort.SearchStrings(keys, token)
+			}
+			var result struct {
+				XMLName               xml.Name `xml:"ListBucketResult"`
+				IsTruncated           bool
+				NextContinuationToken string `xml:",omitempty"`
+				Contents              []struct{ Key string }
+			}
+			if start < len(keys) {
+				result.Contents = append(result.Contents, struct{ Key string }{keys[start]})
+			}
+			if start+1 < len(keys) {
+				result.IsTruncated = true
+				result.NextContinuationToken = keys[start+1]
+			}
+			xml.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodPut:
+			if r.ContentLength < 0 {
+				http.Error(w, "MissingContentLength", http.StatusLengthRequired)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			objects[key] = data
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestS3(t *testing.T) {
+	srv := fakeS3("events")
+	defer srv.Close()
+
+	s, err := NewS3(S3Config{
+		Bucket:      "events",
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: sigv4.Credentials{AccessKey: "AKID", SecretKey: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	testStore(t, s)
+}
+
+func TestS3VirtualHostedURL(t *testing.T) {
+	s, err := NewS3(S3Config{Bucket: "events", Region: "eu-west-1"})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	req, err := s.request(t.Context(), http.MethodGet, "a b/c", nil, nil, 0)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if got := req.URL.String(); got != "https://events.s3.eu-west-1.amazonaws.com/a%20b/c" {
+		t.Errorf("Unexpected URL: %s", got)
+	}
+}
@@ -0,0 +1,197 @@
+// Package fanout wakes readers waiting for a tenant's new events as soon as
+// events are appended: on this replica directly, and on all other replicas
+// through a Broker. Notifications carry no events; readers load them from
+// their store, so a lost notification only delays delivery until the
+// reader's next poll.
+package fanout
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Broker carries notifications between the replicas of a deployment
+type Broker interface {
+	// Publish sends messages to every subscriber, this replica's included
+	Publish(ctx context.Context, messages []string) error
+
+	// Subscribe calls fn with every message published until ctx is done or
+	// the connection to the broker breaks
+	Subscribe(ctx context.Context, fn func(message string)) error
+}
+
+// Reconnect delays after the subscription to the broker breaks
+const (
+	minResubscribe = time.Second
+	maxResubscribe = 30 * time.Second
+)
+
+// Hub tracks the readers waiting for appends, per tenant. A nil Hub never
+// wakes anyone.
+type Hub struct {
+	broker Broker
+	origin string // Identifies this replica's messages, which it skips
+
+	mu      sync.Mutex
+	waiting map[string]chan struct{} // Closed on the tenant's next append
+	pending map[string]bool          // Tenants to publish
+	failing bool                     // The broker is unreachable; logged once per outage
+
+	kick   chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewHub returns a hub notifying this replica only (broker nil), or all
+// replicas through broker
+func NewHub(broker Broker) *Hub {
+	var id [8]byte
+	rand.Read(id[:])
+	h := &Hub{
+		broker:  broker,
+		origin:  hex.EncodeToString(id[:]),
+		waiting: make(map[string]chan struct{}),
+		pending: make(map[string]bool),
+		kick:    make(chan struct{}, 1),
+		cancel:  func() {},
+	}
+
+	if broker != nil {
+		var ctx context.Context
+		ctx, h.cancel = context.WithCancel(context.Background())
+		h.done.Add(2)
+		go h.publishLoop(ctx)
+		go h.subscribeLoop(ctx)
+	}
+	return h
+}
+
+// Wait returns a channel that is closed on tenant's next append. Get it
+// before reading the position, so an append in between isn't missed.
+func (h *Hub) Wait(tenant string) <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := h.waiting[tenant]
+	if ch == nil {
+		ch = make(chan struct{})
+		h.waiting[tenant] = ch
+	}
+	return ch
+}
+
+// Notify wakes tenant's readers after an append, here and, in the
+// background, on the other replicas
+func (h *Hub) Notify(tenant string) {
+	if h == nil {
+		return
+	}
+	h.wake(tenant)
+	if h.broker == nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.pending[tenant] = true
+	h.mu.Unlock()
+	select {
+	case h.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops exchanging notifications with the broker
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.cancel()
+	h.done.Wait()
+}
+
+func (h *Hub) wake(tenant string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ch := h.waiting[tenant]; ch != nil {
+		close(ch)
+		delete(h.waiting, tenant)
+	}
+}
+
+// publishLoop publishes pending tenants; appends that arrive while a
+// publish is in flight are coalesced into the next one
+func (h *Hub) publishLoop(ctx context.Context) {
+	defer h.done.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.kick:
+		}
+
+		h.mu.Lock()
+		messages := make([]string, 0, len(h.pending))
+		for tenant := range h.pending {
+			messages = append(messages, h.origin+" "+tenant)
+		}
+		clear(h.pending)
+		h.mu.Unlock()
+		if len(messages) == 0 {
+			continue
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, time.Second)
+		err := h.broker.Publish(publishCtx, messages)
+		cancel()
+		h.report(err)
+	}
+}
+
+// subscribeLoop wakes readers on appends of other replicas, resubscribing
+// with backoff while the broker is unreachable
+func (h *Hub) subscribeLoop(ctx context.Context) {
+	defer h.done.Done()
+	delay := minResubscribe
+	for {
+		err := h.broker.Subscribe(ctx, func(message string) {
+			delay = minResubscribe
+			h.report(nil)
+			origin, tenant, ok := strings.Cut(message, " ")
+			if ok && origin != h.origin {
+				h.wake(tenant)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		h.report(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxResubscribe)
+	}
+}
+
+// report logs the broker becoming unreachable, and reachable again
+func (h *Hub) report(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case err != nil && !h.failing:
+		slog.Warn("Append notifications unavailable, streams fall back to polling", "error", err)
+		h.failing = true
+	case err == nil && h.failing:
+		slog.Info("Append notifications available again")
+		h.failing = false
+	}
+}
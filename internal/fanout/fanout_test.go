@@ -0,0 +1,103 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBroker delivers messages to the hubs of one test
+type memoryBroker struct {
+	mu   sync.Mutex
+	subs []func(string)
+}
+
+func (m *memoryBroker) Publish(ctx context.Context, messages []string) error {
+	m.mu.Lock()
+	subs := append([]func(string){}, m.subs...)
+	m.mu.Unlock()
+	for _, message := range messages {
+		for _, fn := range subs {
+			fn(message)
+		}
+	}
+	return nil
+}
+
+func (m *memoryBroker) Subscribe(ctx context.Context, fn func(string)) error {
+	m.mu.Lock()
+	m.subs = append(m.subs, fn)
+	m.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// subscribers returns how many hubs are subscribed
+func (m *memoryBroker) subscribers() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs)
+}
+
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHub_Local(t *testing.T) {
+	h := NewHub(nil)
+	defer h.Close()
+
+	alice, bob := h.Wait("alice"), h.Wait("bob")
+	if h.Wait("alice") != alice {
+		t.Error("Expected waiters of one tenant to share a channel")
+	}
+	h.Notify("alice")
+	if !closed(alice) || closed(bob) {
+		t.Error("Expected only alice's waiters to be woken")
+	}
+	if closed(h.Wait("alice")) {
+		t.Error("Expected a fresh channel after the wake")
+	}
+
+	var none *Hub
+	none.Notify("alice")
+	if none.Wait("alice") != nil {
+		t.Error("Expected a nil hub to never wake")
+	}
+	none.Close()
+}
+
+func TestHub_Broker(t *testing.T) {
+	broker := &memoryBroker{}
+	a, b := NewHub(broker), NewHub(broker)
+	defer a.Close()
+	defer b.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for broker.subscribers() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Hubs did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	remote := b.Wait("alice")
+	a.Notify("alice")
+	local := a.Wait("alice") // After the local wake; only an echo could close it
+
+	select {
+	case <-remote:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the append to reach the other replica")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if closed(local) {
+		t.Error("Expected a replica to skip its own notifications")
+	}
+}
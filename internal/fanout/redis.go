@@ -0,0 +1,35 @@
+package fanout
+
+import (
+	"context"
+
+	"github.com/jilio/ebuse/internal/redis"
+)
+
+// redisChannel is the pub/sub channel shared by all replicas
+const redisChannel = "ebuse:appends"
+
+// Redis is a Broker on Redis pub/sub
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Broker on client
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Publish implements Broker with one pipelined PUBLISH per message
+func (r *Redis) Publish(ctx context.Context, messages []string) error {
+	cmds := make([][]string, len(messages))
+	for i, message := range messages {
+		cmds[i] = []string{"PUBLISH", redisChannel, message}
+	}
+	_, err := r.client.Do(ctx, cmds...)
+	return err
+}
+
+// Subscribe implements Broker
+func (r *Redis) Subscribe(ctx context.Context, fn func(message string)) error {
+	return r.client.Subscribe(ctx, redisChannel, fn)
+}
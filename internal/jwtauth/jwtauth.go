@@ -0,0 +1,161 @@
+// Package jwtauth verifies bearer JWTs issued by an existing identity
+// provider and tells which tenant they grant access to. Tokens are signed
+// with RS256 or ES256 keys published at a JWKS URL, or with HS256 and a
+// secret shared with the issuer.
+package jwtauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/oidc"
+)
+
+// ErrInvalid is returned for tokens that fail verification
+var ErrInvalid = errors.New("invalid JWT")
+
+// MinSecretBytes is the shortest HS256 secret accepted
+const MinSecretBytes = 32
+
+// DefaultTenantClaim names the claim holding the tenant when none is set
+const DefaultTenantClaim = "tenant"
+
+// clockSkew is how far token times may be off the local clock
+const clockSkew = time.Minute
+
+// Config selects the keys tokens are checked with and the claims they must
+// carry. Exactly one of JWKSURL and Secrets is set.
+type Config struct {
+	JWKSURL     string   // RS256 and ES256 keys of the issuer
+	Secrets     []string // HS256 secrets shared with the issuer, newest first
+	Issuer      string   // Required iss claim (empty = not checked)
+	Audience    string   // Required in the aud claim (empty = not checked)
+	TenantClaim string   // Claim naming the tenant (default: DefaultTenantClaim)
+}
+
+// Identity is who a verified token was issued to
+type Identity struct {
+	Subject string
+	Tenant  string
+}
+
+// Verifier verifies tokens against a Config
+type Verifier struct {
+	config  Config
+	keys    *oidc.KeySet
+	secrets [][]byte
+}
+
+// New returns a Verifier for config. JWKS keys are fetched with client on
+// first use and again when a token names an unknown key.
+func New(config Config, client *http.Client) (*Verifier, error) {
+	if (config.JWKSURL == "") == (len(config.Secrets) == 0) {
+		return nil, errors.New("exactly one of a JWKS URL and a secret is required")
+	}
+	if config.TenantClaim == "" {
+		config.TenantClaim = DefaultTenantClaim
+	}
+	v := &Verifier{config: config}
+	if config.JWKSURL != "" {
+		v.keys = oidc.NewKeySet(config.JWKSURL, client)
+	}
+	for _, secret := range config.Secrets {
+		if len(secret) < MinSecretBytes {
+			return nil, fmt.Errorf("JWT secret must be at least %d bytes", MinSecretBytes)
+		}
+		v.secrets = append(v.secrets, []byte(secret))
+	}
+	return v, nil
+}
+
+// Looks reports whether s has the shape of a JWT, to tell tokens from API
+// keys before verifying them
+func Looks(s string) bool {
+	return strings.HasPrefix(s, "eyJ") && strings.Count(s, ".") == 2
+}
+
+// Verify checks the signature, issuer, audience and lifetime of token at
+// now and returns its identity. Tokens without an expiry are rejected, so
+// credentials handed out this way are always short-lived.
+func (v *Verifier) Verify(ctx context.Context, token string, now time.Time) (Identity, error) {
+	claims, err := v.claims(ctx, token)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if iss, _ := claims["iss"].(string); v.config.Issuer != "" && iss != v.config.Issuer {
+		return Identity{}, fmt.Errorf("%w: issuer %q", ErrInvalid, iss)
+	}
+	if v.config.Audience != "" && !slices.Contains(oidc.Strings(claims, "aud"), v.config.Audience) {
+		return Identity{}, fmt.Errorf("%w: audience does not include %q", ErrInvalid, v.config.Audience)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: no expiry", ErrInvalid)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return Identity{}, fmt.Errorf("%w: expired", ErrInvalid)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return Identity{}, fmt.Errorf("%w: not valid yet", ErrInvalid)
+	}
+
+	tenant, _ := claims[v.config.TenantClaim].(string)
+	if tenant == "" {
+		return Identity{}, fmt.Errorf("%w: no %q claim", ErrInvalid, v.config.TenantClaim)
+	}
+	subject, _ := claims["sub"].(string)
+	return Identity{Subject: subject, Tenant: tenant}, nil
+}
+
+// claims checks the signature of token and returns its claims
+func (v *Verifier) claims(ctx context.Context, token string) (map[string]any, error) {
+	if v.keys != nil {
+		claims, err := v.keys.Verify(ctx, token)
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return claims, err
+	}
+
+	signed, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalid
+	}
+	payload, sig, ok := strings.Cut(sig, ".")
+	if !ok {
+		return nil, ErrInvalid
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := oidc.DecodeSegment(signed, &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalid
+	}
+	got, err := oidc.DecodeBase64(sig)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	signed += "." + payload
+	valid := slices.ContainsFunc(v.secrets, func(secret []byte) bool {
+		h := hmac.New(sha256.New, secret)
+		h.Write([]byte(signed))
+		return hmac.Equal(got, h.Sum(nil))
+	})
+	if !valid {
+		return nil, ErrInvalid
+	}
+
+	var claims map[string]any
+	if err := oidc.DecodeSegment(payload, &claims); err != nil {
+		return nil, ErrInvalid
+	}
+	return claims, nil
+}
@@ -0,0 +1,147 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+// sign returns a JWT with claims, signed with HS256 and secret or, if key
+// is set, with RS256 and key
+func sign(t *testing.T, claims map[string]any, secret string, key *rsa.PrivateKey) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	alg := "HS256"
+	if key != nil {
+		alg = "RS256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": "k1"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(header) + "." + b64(payload)
+
+	var sig []byte
+	if key != nil {
+		digest := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(signed))
+		sig = h.Sum(nil)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestVerifySecret(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	v, err := New(Config{Secrets: []string{"a-newer-secret-of-at-least-32-bytes", secret}, Issuer: "https://idp", Audience: "ebuse"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() map[string]any {
+		return map[string]any{"iss": "https://idp", "aud": []string{"other", "ebuse"}, "sub": "svc-1", "tenant": "acme", "exp": now.Add(time.Hour).Unix()}
+	}
+
+	id, err := v.Verify(ctx, sign(t, valid(), secret, nil), now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if id != (Identity{Subject: "svc-1", Tenant: "acme"}) {
+		t.Errorf("Verify() = %+v", id)
+	}
+
+	tests := []struct {
+		name   string
+		change func(map[string]any)
+		secret string
+	}{
+		{"wrong secret", func(map[string]any) {}, "another-secret-of-at-least-32-bytes"},
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://other" }, secret},
+		{"wrong audience", func(c map[string]any) { c["aud"] = "other" }, secret},
+		{"expired", func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, secret},
+		{"no expiry", func(c map[string]any) { delete(c, "exp") }, secret},
+		{"not valid yet", func(c map[string]any) { c["nbf"] = now.Add(2 * time.Minute).Unix() }, secret},
+		{"no tenant", func(c map[string]any) { delete(c, "tenant") }, secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.change(claims)
+			if _, err := v.Verify(ctx, sign(t, claims, tt.secret, nil), now); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Verify() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+
+	// Tokens within the clock skew are accepted
+	claims := valid()
+	claims["exp"] = now.Add(-30 * time.Second).Unix()
+	if _, err := v.Verify(ctx, sign(t, claims, secret, nil), now); err != nil {
+		t.Errorf("Verify() of token just expired error = %v", err)
+	}
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "k1", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+		}})
+	}))
+	defer idp.Close()
+
+	v, err := New(Config{JWKSURL: idp.URL, TenantClaim: "org"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	claims := map[string]any{"sub": "user-1", "org": "acme", "exp": now.Add(time.Hour).Unix()}
+
+	id, err := v.Verify(ctx, sign(t, claims, "", key), now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if id.Tenant != "acme" {
+		t.Errorf("Tenant = %q, want acme", id.Tenant)
+	}
+
+	// HS256 tokens are not accepted by a JWKS verifier, whatever the secret
+	if _, err := v.Verify(ctx, sign(t, claims, secret, nil), now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify() of HS256 token error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, config := range []Config{
+		{},
+		{JWKSURL: "https://idp/keys", Secrets: []string{secret}},
+		{Secrets: []string{"short"}},
+	} {
+		if _, err := New(config, nil); err == nil {
+			t.Errorf("New(%+v) succeeded", config)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditKey marks audit records: lines logged with AuditKey=true are
+// security relevant and, with Config.AuditExport, also sent off the host.
+// The attribute must be passed with the record, not through Logger.With.
+const AuditKey = "audit"
+
+// Audit export defaults. Records wait in a buffer of DefaultAuditBuffer
+// while the endpoint is unreachable; beyond that the oldest are dropped.
+const (
+	DefaultAuditBuffer = 10000
+
+	auditBatch       = 100
+	auditSendTimeout = 5 * time.Second
+	minAuditRetry    = time.Second
+	maxAuditRetry    = 30 * time.Second
+)
+
+// errAuditRejected is returned by sinks for batches the endpoint refused;
+// they are dropped instead of retried
+var errAuditRejected = errors.New("audit records rejected")
+
+// auditSink delivers batches of JSON records to the export endpoint
+type auditSink interface {
+	send(ctx context.Context, records [][]byte) error
+	io.Closer
+}
+
+// openAuditSink returns the sink of an AuditExport URL
+func openAuditSink(rawURL, auth string) (auditSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit export URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpAuditSink{url: rawURL, auth: auth, client: &http.Client{Timeout: auditSendTimeout}}, nil
+	case "syslog":
+		return &syslogAuditSink{network: "udp", addr: u.Host}, nil
+	case "syslog+tcp":
+		return &syslogAuditSink{network: "tcp", addr: u.Host}, nil
+	default:
+		return nil, fmt.Errorf("invalid audit export URL %q, expected http(s)://, syslog:// or syslog+tcp://", rawURL)
+	}
+}
+
+// httpAuditSink POSTs batches as NDJSON, e.g. to a SIEM's HTTP collector
+type httpAuditSink struct {
+	url    string
+	auth   string // Authorization header, e.g. "Splunk <token>"
+	client *http.Client
+}
+
+func (s *httpAuditSink) send(ctx context.Context, records [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(bytes.Join(records, nil)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.auth != "" {
+		req.Header.Set("Authorization", s.auth)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		// Sending the same batch again would not help
+		return fmt.Errorf("%w: audit endpoint returned %d", errAuditRejected, resp.StatusCode)
+	default:
+		return fmt.Errorf("audit endpoint returned %d", resp.StatusCode)
+	}
+}
+
+func (s *httpAuditSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// syslogAuditSink sends each record as one syslog message, dialing again
+// after failures
+type syslogAuditSink struct {
+	network, addr string
+	w             io.WriteCloser
+}
+
+func (s *syslogAuditSink) send(ctx context.Context, records [][]byte) error {
+	if s.w == nil {
+		w, err := dialSyslog(s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.w = w
+	}
+	for _, record := range records {
+		if _, err := s.w.Write(record); err != nil {
+			s.w.Close()
+			s.w = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogAuditSink) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Close()
+}
+
+// auditExporter buffers audit records, one per Write, and sends them to
+// its sink in the background, retrying with backoff until they are
+// delivered or pushed out of the full buffer
+type auditExporter struct {
+	sink auditSink
+	size int
+
+	mu      sync.Mutex
+	queue   [][]byte
+	dropped int  // Records pushed out since the last report
+	failing bool // Logged once per outage
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newAuditExporter(sink auditSink, size int) *auditExporter {
+	if size <= 0 {
+		size = DefaultAuditBuffer
+	}
+	e := &auditExporter{
+		sink: sink,
+		size: size,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Write queues one record; slog handlers write each record in one call
+func (e *auditExporter) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	e.push(bytes.Clone(p))
+	e.mu.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// push appends records, dropping the oldest beyond size. Must be called
+// with mu held.
+func (e *auditExporter) push(records ...[]byte) {
+	e.queue = append(e.queue, records...)
+	if over := len(e.queue) - e.size; over > 0 {
+		e.queue = e.queue[over:]
+		e.dropped += over
+	}
+}
+
+func (e *auditExporter) run() {
+	defer close(e.done)
+	retry := minAuditRetry
+	for {
+		select {
+		case <-e.stop:
+			e.flush()
+			return
+		case <-e.wake:
+		}
+
+		for e.flush() != nil {
+			select {
+			case <-e.stop:
+				e.flush()
+				return
+			case <-time.After(retry):
+			}
+			retry = min(2*retry, maxAuditRetry)
+		}
+		retry = minAuditRetry
+	}
+}
+
+// flush sends queued records in batches until the queue is empty or a send
+// fails; failed batches go back to the front of the queue
+func (e *auditExporter) flush() error {
+	for {
+		e.mu.Lock()
+		n := min(len(e.queue), auditBatch)
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), auditSendTimeout)
+		err := e.sink.send(ctx, batch)
+		cancel()
+
+		if errors.Is(err, errAuditRejected) {
+			slog.Error("Audit export rejected records, dropping them", "error", err, "dropped", len(batch))
+			continue
+		}
+
+		e.mu.Lock()
+		if err != nil {
+			e.queue = append(batch, e.queue...)
+			e.push()
+		}
+		dropped, failing := e.dropped, e.failing
+		e.dropped = 0
+		e.failing = err != nil
+		queued := len(e.queue)
+		e.mu.Unlock()
+
+		// Not audit records themselves, so these never come back here
+		if dropped > 0 {
+			slog.Warn("Audit export buffer full, dropped oldest records", "dropped", dropped)
+		}
+		switch {
+		case err != nil && !failing:
+			slog.Warn("Audit export failed, retrying", "error", err, "queued", queued)
+		case err == nil && failing:
+			slog.Info("Audit export recovered")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close sends what is queued, trying once more, and closes the sink
+func (e *auditExporter) Close() error {
+	close(e.stop)
+	<-e.done
+	return e.sink.Close()
+}
+
+// auditTee passes records to its Handler and copies audit records to
+// audit, whatever the Handler's level
+type auditTee struct {
+	slog.Handler
+	audit slog.Handler
+}
+
+// Enabled implements slog.Handler
+func (t *auditTee) Enabled(ctx context.Context, level slog.Level) bool {
+	return t.Handler.Enabled(ctx, level) || t.audit.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (t *auditTee) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	if t.Handler.Enabled(ctx, record.Level) {
+		err = t.Handler.Handle(ctx, record)
+	}
+	if isAudit(record) {
+		err = errors.Join(err, t.audit.Handle(ctx, record.Clone()))
+	}
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (t *auditTee) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &auditTee{Handler: t.Handler.WithAttrs(attrs), audit: t.audit.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (t *auditTee) WithGroup(name string) slog.Handler {
+	return &auditTee{Handler: t.Handler.WithGroup(name), audit: t.audit.WithGroup(name)}
+}
+
+func isAudit(record slog.Record) bool {
+	audit := false
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == AuditKey {
+			audit = attr.Value.Kind() == slog.KindBool && attr.Value.Bool()
+			return false
+		}
+		return true
+	})
+	return audit
+}
+
+// newAuditHandler returns the JSON handler writing audit records to
+// exporter, tagged with the host they come from
+func newAuditHandler(exporter io.Writer) slog.Handler {
+	host, _ := os.Hostname()
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	return slog.NewJSONHandler(exporter, opts).WithAttrs([]slog.Attr{
+		slog.String("service", "ebuse"),
+		slog.String("host", strings.ToLower(host)),
+	})
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuditExport_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]any
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var record map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("Bad record %q: %v", scanner.Text(), err)
+			}
+			received = append(received, record)
+		}
+	}))
+	defer srv.Close()
+
+	logger, closer, err := New(Config{
+		Output:          "stderr",
+		Level:           "error",
+		AccessSample:    1,
+		AuditExport:     srv.URL,
+		AuditExportAuth: "Bearer secret",
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Audit records are exported below the logger's level; others are not
+	logger = logger.With("tenant", "acme")
+	logger.Info("Authentication failed", AuditKey, true)
+	logger.Info("HTTP request")
+	logger.Warn("Events repaired", "events", 2, AuditKey, true)
+
+	// The first attempt fails and is retried after a second
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	closer.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 exported records, got %v", received)
+	}
+	if received[0]["msg"] != "Authentication failed" || received[0]["tenant"] != "acme" || received[0]["service"] != "ebuse" {
+		t.Errorf("Unexpected first record %v", received[0])
+	}
+	if received[1]["msg"] != "Events repaired" || received[1]["events"] != float64(2) {
+		t.Errorf("Unexpected second record %v", received[1])
+	}
+}
+
+// failingSink fails every send until it is told to accept
+type failingSink struct {
+	mu       sync.Mutex
+	accept   bool
+	received []string
+}
+
+func (s *failingSink) send(ctx context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.accept {
+		return net.ErrClosed
+	}
+	for _, record := range records {
+		s.received = append(s.received, string(record))
+	}
+	return nil
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestAuditExporter_DropsOldest(t *testing.T) {
+	sink := &failingSink{}
+	e := newAuditExporter(sink, 3)
+	for _, record := range []string{"1", "2", "3", "4", "5"} {
+		e.Write([]byte(record))
+	}
+
+	// Close tries a last time
+	sink.mu.Lock()
+	sink.accept = true
+	sink.mu.Unlock()
+	e.Close()
+
+	if got := strings.Join(sink.received, ","); got != "3,4,5" {
+		t.Errorf("Expected the newest 3 records, got %q", got)
+	}
+}
+
+func TestAuditExport_Syslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	logger, closer, err := New(Config{
+		Output:       "stderr",
+		AccessSample: 1,
+		AuditExport:  "syslog://" + conn.LocalAddr().String(),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer closer.Close()
+	logger.Warn("Authentication failed", AuditKey, true)
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if got := string(buf[:n]); !strings.Contains(got, "ebuse") || !strings.Contains(got, `"msg":"Authentication failed"`) {
+		t.Errorf("Unexpected syslog message %q", got)
+	}
+}
+
+func TestNew_InvalidAuditExport(t *testing.T) {
+	if _, _, err := New(Config{AuditExport: "ftp://siem"}); err == nil {
+		t.Error("Expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,118 @@
+// Package logging builds the server's slog logger from its configuration:
+// where logs go (stdout, stderr, a rotated file or syslog), their format
+// and level, and how many access log lines are kept.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// AccessMessage is the message of the per-request access log line, the one
+// thinned out by Config.AccessSample
+const AccessMessage = "HTTP request"
+
+// Config describes the server's logging
+type Config struct {
+	// Output is "stdout", "stderr", "syslog" (the local daemon),
+	// "syslog://host:port" (a remote daemon over UDP) or a file path
+	Output string
+	Format string // "json" or "text"
+	Level  string // "debug", "info", "warn" or "error"
+
+	// Files are rotated once they reach MaxSizeMB, keeping MaxBackups old
+	// files as <path>.1 (newest) to <path>.<MaxBackups>
+	MaxSizeMB  int
+	MaxBackups int
+
+	// AccessSample is the fraction of successful access log lines written,
+	// from 0 to 1. Failed requests and other lines are always written.
+	AccessSample float64
+
+	// AuditExport additionally sends audit records (see AuditKey) to a SIEM:
+	// "https://..." posts them as NDJSON with AuditExportAuth as the
+	// Authorization header, "syslog://host:port" and "syslog+tcp://host:port"
+	// send them to a syslog collector. Up to AuditBuffer records wait while
+	// the endpoint is unreachable.
+	AuditExport     string
+	AuditExportAuth string
+	AuditBuffer     int
+}
+
+// New returns the logger described by config. Close the returned closer
+// once nothing logs anymore.
+func New(config Config) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if config.Level != "" {
+		if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level %q", config.Level)
+		}
+	}
+
+	out, closer, err := open(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch config.Format {
+	case "", "json":
+		handler = slog.NewJSONHandler(out, opts)
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("invalid log format %q, expected json or text", config.Format)
+	}
+
+	if config.AccessSample < 1 {
+		handler = newSampler(handler, config.AccessSample)
+	}
+
+	if config.AuditExport != "" {
+		sink, err := openAuditSink(config.AuditExport, config.AuditExportAuth)
+		if err != nil {
+			closer.Close()
+			return nil, nil, err
+		}
+		exporter := newAuditExporter(sink, config.AuditBuffer)
+		handler = &auditTee{Handler: handler, audit: newAuditHandler(exporter)}
+		closer = closers{exporter, closer}
+	}
+	return slog.New(handler), closer, nil
+}
+
+// closers closes all of its closers in order
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// open returns the writer of config.Output
+func open(config Config) (io.Writer, io.Closer, error) {
+	switch output := config.Output; {
+	case output == "" || output == "stdout":
+		return os.Stdout, io.NopCloser(nil), nil
+	case output == "stderr":
+		return os.Stderr, io.NopCloser(nil), nil
+	case output == "syslog":
+		w, err := dialSyslog("", "")
+		return w, w, err
+	case strings.HasPrefix(output, "syslog://"):
+		w, err := dialSyslog("udp", strings.TrimPrefix(output, "syslog://"))
+		return w, w, err
+	default:
+		f, err := openRotating(output, int64(config.MaxSizeMB)<<20, config.MaxBackups)
+		return f, f, err
+	}
+}
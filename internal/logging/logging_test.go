@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Level: "loud"},
+		{Format: "xml"},
+		{Output: filepath.Join(t.TempDir(), "missing", "ebuse.log")},
+	} {
+		if _, _, err := New(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestNew_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ebuse.log")
+	logger, closer, err := New(Config{Output: path, Format: "text", Level: "warn", AccessSample: 1})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "n", 1)
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if got := string(data); strings.Contains(got, "hidden") || !strings.Contains(got, "level=WARN msg=shown n=1") {
+		t.Errorf("Unexpected log %q", got)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ebuse.log")
+	f, err := openRotating(path, 10, 2)
+	if err != nil {
+		t.Fatalf("openRotating failed: %v", err)
+	}
+	defer f.Close()
+
+	// Every write fills a file, so each one after the first rotates
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", filepath.Base(name), want, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups, got %v", err)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newSampler(slog.NewTextHandler(&buf, nil), 0.25)).With("route", "/events")
+
+	for range 8 {
+		logger.Info(AccessMessage, "status", 200)
+	}
+	logger.Info(AccessMessage, "status", 503)
+	logger.Info("Other line")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 2 of 8 successful requests, the failed one and the other line, got %q", buf.String())
+	}
+	if !strings.Contains(lines[2], "status=503") || !strings.Contains(lines[3], "Other line") {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// sampler is a slog.Handler that writes only a fraction of the successful
+// access log lines. It keeps an even share of them by counting rather than
+// drawing random numbers, so a rate of 0.1 writes every tenth line.
+type sampler struct {
+	slog.Handler
+	rate float64
+	seen *atomic.Uint64 // Shared by the handlers derived with WithAttrs
+}
+
+func newSampler(handler slog.Handler, rate float64) *sampler {
+	return &sampler{Handler: handler, rate: max(rate, 0), seen: new(atomic.Uint64)}
+}
+
+// Handle implements slog.Handler
+func (s *sampler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Message == AccessMessage && record.Level <= slog.LevelInfo && !failed(record) {
+		n := s.seen.Add(1)
+		if uint64(float64(n)*s.rate) == uint64(float64(n-1)*s.rate) {
+			return nil
+		}
+	}
+	return s.Handler.Handle(ctx, record)
+}
+
+// failed reports whether an access log line is for a 4xx or 5xx response
+func failed(record slog.Record) bool {
+	status := int64(0)
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "status" && attr.Value.Kind() == slog.KindInt64 {
+			status = attr.Value.Int64()
+			return false
+		}
+		return true
+	})
+	return status >= 400
+}
+
+// WithAttrs implements slog.Handler
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{Handler: s.Handler.WithAttrs(attrs), rate: s.rate, seen: s.seen}
+}
+
+// WithGroup implements slog.Handler
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{Handler: s.Handler.WithGroup(name), rate: s.rate, seen: s.seen}
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+// dialSyslog connects to the syslog daemon at addr over network, or to the
+// local one when both are empty. Records are sent at info priority; their
+// level is part of the formatted record.
+func dialSyslog(network, addr string) (io.WriteCloser, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, "ebuse")
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return w, nil
+}
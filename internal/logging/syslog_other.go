@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog fails: there is no syslog on this platform
+func dialSyslog(network, addr string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
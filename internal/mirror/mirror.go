@@ -0,0 +1,284 @@
+// Package mirror pushes a store's events to a remote ebuse server, keeping
+// an asynchronous copy of a tenant in another region for disaster recovery.
+//
+// The mirror keeps no checkpoint of its own: the target must be dedicated to
+// the mirror, which imports every event at its source position, so the
+// target's head is exactly the last mirrored position. Gaps in the source,
+// from rolled back writes, a start position or retention, stay gaps on the
+// target. After any failure the mirror re-reads that head and continues
+// from there, which makes retries safe even when a push succeeded but its
+// response was lost.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ErrDiverged is returned by Run when the target holds events that don't
+// match the source, e.g. because something else writes to it. The mirror
+// stops rather than guess which side is right.
+var ErrDiverged = errors.New("mirror target has diverged from the source")
+
+// Target is the remote side of a mirror; *client.HTTPClient implements it,
+// with the target tenant's main API key. ImportEvents saves events at the
+// positions they carry and refuses positions at or before the head, so a
+// batch whose first attempt landed is never saved twice.
+type Target interface {
+	GetPosition(ctx context.Context) (int64, error)
+	Load(ctx context.Context, from, to int64) ([]*store.StoredEvent, error)
+	ImportEvents(ctx context.Context, events []*store.StoredEvent) error
+}
+
+// Defaults for Config fields left at zero
+const (
+	DefaultBatchSize    = 500
+	DefaultPollInterval = time.Second
+	DefaultMaxBackoff   = time.Minute
+
+	maxBatchSize   = 1000 // Server limit for /events/batch
+	initialBackoff = time.Second
+)
+
+// errBatchLoaded stops the source stream after one batch
+var errBatchLoaded = errors.New("batch loaded")
+
+// Config tunes a mirror
+type Config struct {
+	Name         string        // Used in logs, e.g. the tenant name
+	BatchSize    int           // Events per push
+	PollInterval time.Duration // Delay between checks for new events once caught up
+	MaxBackoff   time.Duration // Upper bound for the delay between retries
+}
+
+// Status is a snapshot of a mirror's progress
+type Status struct {
+	SourcePosition   int64     `json:"source_position"`
+	MirroredPosition int64     `json:"mirrored_position"`
+	LagEvents        int64     `json:"lag_events"`
+	LagSeconds       float64   `json:"lag_seconds"` // Age of the oldest event not yet mirrored
+	Failures         int64     `json:"failures"`    // Consecutive failed attempts
+	LastError        string    `json:"last_error,omitempty"`
+	LastSuccess      time.Time `json:"last_success,omitzero"`
+}
+
+// Mirror copies events from a source store to a target
+type Mirror struct {
+	source store.EventStore
+	target Target
+	config Config
+
+	mu           sync.Mutex
+	status       Status
+	pendingSince time.Time // Timestamp of the oldest unmirrored event, zero when caught up
+}
+
+// New returns a mirror from source to target; call Run to start it
+func New(source store.EventStore, target Target, config Config) *Mirror {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	config.BatchSize = min(config.BatchSize, maxBatchSize)
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Mirror{source: source, target: target, config: config}
+}
+
+// Status returns the mirror's current progress
+func (m *Mirror) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status
+	if !m.pendingSince.IsZero() {
+		status.LagSeconds = time.Since(m.pendingSince).Seconds()
+	}
+	return status
+}
+
+// Run mirrors events until ctx is done, retrying failures with exponential
+// backoff. It only returns early with ErrDiverged.
+func (m *Mirror) Run(ctx context.Context) error {
+	for {
+		err := m.run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		m.mu.Lock()
+		m.status.Failures++
+		m.status.LastError = err.Error()
+		failures := m.status.Failures
+		m.mu.Unlock()
+
+		if errors.Is(err, ErrDiverged) {
+			slog.Error("Mirror stopped", "mirror", m.config.Name, "error", err)
+			return err
+		}
+
+		backoff := min(initialBackoff<<min(failures-1, 16), m.config.MaxBackoff)
+		slog.Warn("Mirror failed, retrying", "mirror", m.config.Name, "error", err, "backoff", backoff)
+		if !sleep(ctx, backoff) {
+			return nil
+		}
+	}
+}
+
+// run resumes from the target's head and pushes batches until an error
+func (m *Mirror) run(ctx context.Context) error {
+	mirrored, err := m.target.GetPosition(ctx)
+	if err != nil {
+		return fmt.Errorf("get target position: %w", err)
+	}
+	if err := m.verify(ctx, mirrored); err != nil {
+		return err
+	}
+
+	for {
+		head, err := m.source.GetPosition(ctx)
+		if err != nil {
+			return fmt.Errorf("get source position: %w", err)
+		}
+		if mirrored > head {
+			return fmt.Errorf("%w: target is at %d, source at %d", ErrDiverged, mirrored, head)
+		}
+		m.progress(head, mirrored)
+
+		if mirrored == head {
+			m.caughtUp()
+			if !sleep(ctx, m.config.PollInterval) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		// Streamed rather than loaded by range, so gaps of any size are
+		// skipped in one read
+		var events []*store.StoredEvent
+		err = m.source.LoadStream(ctx, mirrored+1, m.config.BatchSize, func(batch []*store.StoredEvent) error {
+			for _, event := range batch {
+				if event.Position <= head {
+					events = append(events, event)
+				}
+			}
+			return errBatchLoaded
+		})
+		if err != nil && !errors.Is(err, errBatchLoaded) {
+			return fmt.Errorf("load events: %w", err)
+		}
+		if len(events) == 0 {
+			mirrored = head // Nothing but gaps up to the head
+			m.succeeded(head, mirrored)
+			continue
+		}
+		m.pending(events[0].Timestamp)
+
+		if err := m.target.ImportEvents(ctx, events); err != nil {
+			return fmt.Errorf("push events %d-%d: %w", events[0].Position, events[len(events)-1].Position, err)
+		}
+
+		mirrored = events[len(events)-1].Position
+		m.succeeded(head, mirrored)
+	}
+}
+
+// verify checks that the last mirrored event matches the source, so a
+// target that was written to by something else is not silently extended.
+// An event the source has pruned since cannot be checked and is trusted.
+func (m *Mirror) verify(ctx context.Context, mirrored int64) error {
+	if mirrored == 0 {
+		return nil
+	}
+
+	local, err := m.source.Load(ctx, mirrored, mirrored)
+	if err != nil {
+		return fmt.Errorf("load source event %d: %w", mirrored, err)
+	}
+	remote, err := m.target.Load(ctx, mirrored, mirrored)
+	if err != nil {
+		return fmt.Errorf("load target event %d: %w", mirrored, err)
+	}
+	if len(local) == 0 {
+		return nil
+	}
+	if len(remote) != 1 || !sameEvent(local[0], remote[0]) {
+		return fmt.Errorf("%w: event %d differs", ErrDiverged, mirrored)
+	}
+	return nil
+}
+
+// sameEvent compares type and payload; data is compared compacted, since it
+// is re-encoded on the way to the target
+func sameEvent(a, b *store.StoredEvent) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a.Data) != nil || json.Compact(&cb, b.Data) != nil {
+		return bytes.Equal(a.Data, b.Data)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+func (m *Mirror) progress(head, mirrored int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setPositions(head, mirrored)
+}
+
+func (m *Mirror) pending(since time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pendingSince = since
+}
+
+func (m *Mirror) caughtUp() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pendingSince = time.Time{}
+	m.setSuccess()
+}
+
+func (m *Mirror) succeeded(head, mirrored int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setPositions(head, mirrored)
+	m.setSuccess()
+}
+
+// setPositions and setSuccess must be called with mu held
+func (m *Mirror) setPositions(head, mirrored int64) {
+	m.status.SourcePosition = head
+	m.status.MirroredPosition = mirrored
+	m.status.LagEvents = head - mirrored
+}
+
+func (m *Mirror) setSuccess() {
+	m.status.Failures = 0
+	m.status.LastError = ""
+	m.status.LastSuccess = time.Now()
+}
+
+// sleep waits for d and reports false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,175 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func newStore(t *testing.T, name string) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func saveEvents(t *testing.T, st store.EventStore, n int, typ string) {
+	t.Helper()
+	for i := range n {
+		data, _ := json.Marshal(map[string]int{"n": i})
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: typ, Data: data, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+// lossyTarget saves batches but reports the first lossy pushes as failed, as
+// if the response had been lost
+type lossyTarget struct {
+	Target
+	lossy atomic.Int32
+}
+
+func (l *lossyTarget) ImportEvents(ctx context.Context, events []*store.StoredEvent) error {
+	if err := l.Target.ImportEvents(ctx, events); err != nil {
+		return err
+	}
+	if l.lossy.Add(-1) >= 0 {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	source := newStore(t, "source")
+	target := &lossyTarget{Target: newStore(t, "target")}
+	target.lossy.Store(1)
+	saveEvents(t, source, 25, "Created")
+
+	m := New(source, target, Config{Name: "test", BatchSize: 10, PollInterval: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	waitFor(t, "initial catch-up", func() bool { return m.Status().MirroredPosition == 25 })
+
+	// A lost response is resolved from the target's head, not by resending
+	head, _ := target.GetPosition(context.Background())
+	if head != 25 {
+		t.Fatalf("Expected target at 25 without duplicates, got %d", head)
+	}
+
+	saveEvents(t, source, 3, "Updated")
+	waitFor(t, "new events", func() bool { return m.Status().MirroredPosition == 28 })
+
+	status := m.Status()
+	if status.LagEvents != 0 || status.LagSeconds != 0 || status.Failures != 0 || status.LastSuccess.IsZero() {
+		t.Errorf("Expected a caught-up status, got %+v", status)
+	}
+
+	events, err := target.Load(context.Background(), 26, 28)
+	if err != nil || len(events) != 3 || events[0].Type != "Updated" {
+		t.Errorf("Expected mirrored events 26-28, got %d events, %v", len(events), err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected Run to stop cleanly, got %v", err)
+	}
+}
+
+func TestMirror_Diverged(t *testing.T) {
+	source := newStore(t, "source")
+	target := newStore(t, "target")
+	saveEvents(t, source, 3, "Created")
+	saveEvents(t, target, 3, "SomethingElse")
+
+	m := New(source, target, Config{PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.Run(ctx); !errors.Is(err, ErrDiverged) {
+		t.Fatalf("Expected ErrDiverged, got %v", err)
+	}
+	if m.Status().LastError == "" {
+		t.Error("Expected the error in the status")
+	}
+}
+
+func TestMirror_Lag(t *testing.T) {
+	source := newStore(t, "source")
+	saveEvents(t, source, 1, "Created")
+
+	m := New(source, &lossyTarget{Target: newStore(t, "target")}, Config{})
+	m.pending(time.Now().Add(-time.Minute))
+
+	if lag := m.Status().LagSeconds; lag < 59 {
+		t.Errorf("Expected lag of about a minute, got %.1fs", lag)
+	}
+	m.caughtUp()
+	if lag := m.Status().LagSeconds; lag != 0 {
+		t.Errorf("Expected no lag once caught up, got %.1fs", lag)
+	}
+}
+
+func TestMirror_Gaps(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t, "source")
+	target := newStore(t, "target")
+	if _, err := store.StartAt(ctx, source, 100); err != nil {
+		t.Fatalf("StartAt failed: %v", err)
+	}
+	saveEvents(t, source, 5, "Created")
+
+	run := func(want int64) {
+		t.Helper()
+		m := New(source, target, Config{BatchSize: 2, PollInterval: 10 * time.Millisecond})
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- m.Run(ctx) }()
+		waitFor(t, "catch-up", func() bool { return m.Status().MirroredPosition == want })
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Expected Run to stop cleanly, got %v", err)
+		}
+	}
+
+	// Positions before the source's start are skipped, not diverged from
+	run(105)
+	events, _ := target.Load(ctx, 1, 200)
+	if len(events) != 5 || events[0].Position != 101 || events[4].Position != 105 {
+		t.Fatalf("Expected positions 101-105 on the target, got %d events", len(events))
+	}
+
+	// Retention may prune the last mirrored event and what follows it
+	saveEvents(t, source, 3, "Updated")
+	if _, err := source.DeleteBefore(ctx, 108); err != nil {
+		t.Fatalf("DeleteBefore failed: %v", err)
+	}
+	run(108)
+	events, _ = target.Load(ctx, 106, 200)
+	if len(events) != 1 || events[0].Position != 108 || events[0].Type != "Updated" {
+		t.Errorf("Expected only position 108 mirrored after the pruned range, got %+v", events)
+	}
+}
@@ -0,0 +1,221 @@
+// Package oidc is a minimal OpenID Connect relying party: it discovers a
+// provider, builds authorization code requests with PKCE and exchanges the
+// returned code for a verified ID token. ID tokens signed with RS256 or
+// ES256 are checked against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for ID tokens that fail verification
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// clockSkew is how far token times may be off the local clock
+const clockSkew = time.Minute
+
+// Config identifies the relying party at the provider
+type Config struct {
+	Issuer       string // Provider URL, e.g. https://accounts.example.com
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // Where the provider sends the browser back with the code
+	Scopes       []string // Requested in addition to "openid" (default: profile email groups)
+}
+
+// Provider is a discovered OpenID Connect provider
+type Provider struct {
+	config Config
+	client *http.Client
+
+	authURL  string
+	tokenURL string
+	keys     *KeySet
+}
+
+// Discover fetches the provider's configuration from
+// <issuer>/.well-known/openid-configuration
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	issuer := strings.TrimSuffix(config.Issuer, "/")
+
+	var doc struct {
+		Issuer   string `json:"issuer"`
+		AuthURL  string `json:"authorization_endpoint"`
+		TokenURL string `json:"token_endpoint"`
+		JWKSURL  string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("discover %s: %w", issuer, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discover %s: provider reports issuer %q", issuer, doc.Issuer)
+	}
+	if doc.AuthURL == "" || doc.TokenURL == "" || doc.JWKSURL == "" {
+		return nil, fmt.Errorf("discover %s: incomplete provider configuration", issuer)
+	}
+
+	config.Issuer = doc.Issuer
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"profile", "email", "groups"}
+	}
+	return &Provider{
+		config:   config,
+		client:   client,
+		authURL:  doc.AuthURL,
+		tokenURL: doc.TokenURL,
+		keys:     NewKeySet(doc.JWKSURL, client),
+	}, nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to for login.
+// state and nonce are echoed back in the redirect and the ID token;
+// verifier is the PKCE code verifier later passed to Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + query.Encode()
+}
+
+// Exchange redeems an authorization code and returns the claims of the
+// verified ID token, whose nonce must match
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (map[string]any, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("token request: provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if result.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return p.Verify(ctx, result.IDToken, nonce, time.Now())
+}
+
+// Verify checks an ID token's signature, issuer, audience, expiry and nonce
+// and returns its claims
+func (p *Provider) Verify(ctx context.Context, idToken, nonce string, now time.Time) (map[string]any, error) {
+	claims, err := p.keys.Verify(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidToken, iss)
+	}
+	if !audienceContains(claims["aud"], p.config.ClientID) {
+		return nil, fmt.Errorf("%w: audience does not include the client", ErrInvalidToken)
+	}
+	exp, _ := claims["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// Strings returns a claim holding a string or a list of strings, such as
+// groups, as a list
+func Strings(claims map[string]any, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// RandomString returns a random URL-safe string for states, nonces and
+// PKCE verifiers
+func RandomString() string {
+	var b [32]byte
+	rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// audienceContains reports whether an aud claim, a string or a list,
+// includes clientID
+func audienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		return slices.Contains(v, any(clientID))
+	}
+	return false
+}
+
+// getJSON fetches url and decodes its JSON body into v
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
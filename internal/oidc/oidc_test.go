@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an identity provider that issues ID tokens for one code
+type fakeProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	alg    string         // Signing algorithm of issued tokens
+	claims map[string]any // Claims of the next ID token
+
+	challenge string // PKCE challenge of the last authorization request
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{rsaKey: rsaKey, ecKey: ecKey, alg: "RS256"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if id != "ebuse" || secret != "client-secret" || r.FormValue("code") != "the-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != p.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, p.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// sign returns an ID token with claims, signed with p.alg
+func (p *fakeProvider) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	kid := map[string]string{"RS256": "rsa", "ES256": "ec"}[p.alg]
+	header, _ := json.Marshal(map[string]string{"alg": p.alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	if p.alg == "ES256" {
+		r, s, err := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestLogin(t *testing.T) {
+	idp := newFakeProvider(t)
+	ctx := context.Background()
+
+	provider, err := Discover(ctx, Config{
+		Issuer:       idp.URL,
+		ClientID:     "ebuse",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://ebuse.example.com/admin/callback",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	verifier, nonce := RandomString(), RandomString()
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if authURL.Path != "/authorize" || query.Get("state") != "state-1" || query.Get("scope") != "openid profile email groups" || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("Unexpected authorization URL: %s", authURL)
+	}
+	idp.challenge = query.Get("code_challenge")
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		idp.alg = alg
+		idp.claims = map[string]any{
+			"iss":    idp.URL,
+			"aud":    []string{"ebuse", "other"},
+			"sub":    "u-1",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"nonce":  nonce,
+			"groups": []string{"ops", "eng"},
+		}
+		claims, err := provider.Exchange(ctx, "the-code", verifier, nonce)
+		if err != nil {
+			t.Fatalf("%s: Exchange failed: %v", alg, err)
+		}
+		if groups := Strings(claims, "groups"); claims["sub"] != "u-1" || len(groups) != 2 || groups[0] != "ops" {
+			t.Errorf("%s: unexpected claims %v", alg, claims)
+		}
+	}
+
+	// A wrong PKCE verifier is refused by the provider
+	if _, err := provider.Exchange(ctx, "the-code", "other-verifier", nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected the provider to refuse the verifier, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp := newFakeProvider(t)
+	ctx := context.Background()
+	provider, err := Discover(ctx, Config{Issuer: idp.URL, ClientID: "ebuse"}, nil)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{"iss": idp.URL, "aud": "ebuse", "sub": "u-1", "exp": now.Add(time.Hour).Unix(), "nonce": "n"}
+	}
+	if _, err := provider.Verify(ctx, idp.sign(t, valid()), "n", now); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	for name, mutate := range map[string]func(map[string]any){
+		"wrong issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c map[string]any) { c["aud"] = "other" },
+		"expired":        func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"wrong nonce":    func(c map[string]any) { c["nonce"] = "replayed" },
+	} {
+		claims := valid()
+		mutate(claims)
+		if _, err := provider.Verify(ctx, idp.sign(t, claims), "n", now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	// Tampered payloads and unsigned tokens
+	token := idp.sign(t, valid())
+	parts := strings.Split(token, ".")
+	claims := valid()
+	claims["sub"] = "admin"
+	payload, _ := json.Marshal(claims)
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + parts[1] + "."
+	for _, bad := range []string{forged, unsigned, "not-a-token"} {
+		if _, err := provider.Verify(ctx, bad, "n", now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken for %.20s..., got %v", bad, err)
+		}
+	}
+}
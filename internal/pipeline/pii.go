@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PII rule actions
+const (
+	ActionMask   = "mask"
+	ActionReject = "reject"
+)
+
+// DefaultMask replaces PII matches when a rule sets no Mask
+const DefaultMask = "[REDACTED]"
+
+// builtinPII are the patterns available as PIIRule.Builtin
+var builtinPII = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"card":  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), // Luhn-checked
+}
+
+// PIIRule masks or rejects string and number values of the event data
+// matching a builtin or custom pattern
+type PIIRule struct {
+	Name    string   `yaml:"name,omitempty"`    // Used in audit records; defaults to Builtin
+	Builtin string   `yaml:"builtin,omitempty"` // "email" or "card"
+	Pattern string   `yaml:"pattern,omitempty"` // Regular expression, instead of Builtin
+	Fields  []string `yaml:"fields,omitempty"`  // Dotted paths to scan, including their children; empty scans all values
+	Action  string   `yaml:"action,omitempty"`  // "mask" (default) or "reject"
+	Mask    string   `yaml:"mask,omitempty"`    // Replacement for masked matches
+}
+
+// AuditRecord describes an action a PII rule took on an event. It never
+// contains the matched values.
+type AuditRecord struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"` // "masked" or "rejected"
+	Path    string `json:"path"`   // Dotted path of the value, array elements by index
+	Matches int    `json:"matches"`
+}
+
+type piiRule struct {
+	name    string
+	pattern *regexp.Regexp
+	luhn    bool
+	fields  [][]string
+	reject  bool
+	mask    string
+}
+
+func newPIIRule(i int, rule PIIRule) (*piiRule, error) {
+	r := &piiRule{name: rule.Name, mask: rule.Mask}
+	switch {
+	case rule.Builtin != "" && rule.Pattern != "":
+		return nil, fmt.Errorf("pii rule %d: set builtin or pattern, not both", i)
+	case rule.Builtin != "":
+		r.pattern = builtinPII[rule.Builtin]
+		if r.pattern == nil {
+			return nil, fmt.Errorf("pii rule %d: unknown builtin %q", i, rule.Builtin)
+		}
+		r.luhn = rule.Builtin == "card"
+		if r.name == "" {
+			r.name = rule.Builtin
+		}
+	case rule.Pattern != "":
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pii rule %d: %w", i, err)
+		}
+		r.pattern = pattern
+	default:
+		return nil, fmt.Errorf("pii rule %d: needs builtin or pattern", i)
+	}
+	if r.name == "" {
+		r.name = fmt.Sprintf("pii-%d", i)
+	}
+
+	switch rule.Action {
+	case "", ActionMask:
+	case ActionReject:
+		r.reject = true
+	default:
+		return nil, fmt.Errorf("pii rule %d: unknown action %q", i, rule.Action)
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+
+	for _, field := range rule.Fields {
+		if !validPath(field) {
+			return nil, fmt.Errorf("pii rule %d: invalid field %q", i, field)
+		}
+		r.fields = append(r.fields, strings.Split(field, "."))
+	}
+	return r, nil
+}
+
+// scan masks matches in data, returning the updated value and a record per
+// value that matched. Rejecting rules leave data unchanged.
+func (r *piiRule) scan(data any) (any, []AuditRecord) {
+	var records []AuditRecord
+	var walk func(value any, path, fieldPath []string) any
+	walk = func(value any, path, fieldPath []string) any {
+		switch v := value.(type) {
+		case map[string]any:
+			for k, child := range v {
+				v[k] = walk(child, append(path, k), append(fieldPath, k))
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child, append(path, strconv.Itoa(i)), fieldPath)
+			}
+		case string:
+			if !r.inScope(fieldPath) {
+				return v
+			}
+			matches := 0
+			masked := r.pattern.ReplaceAllStringFunc(v, func(match string) string {
+				if r.luhn && !luhnValid(match) {
+					return match
+				}
+				matches++
+				return r.mask
+			})
+			if matches == 0 {
+				return v
+			}
+			action := "masked"
+			if r.reject {
+				action = "rejected"
+			} else {
+				value = masked
+			}
+			records = append(records, AuditRecord{Rule: r.name, Action: action, Path: strings.Join(path, "."), Matches: matches})
+		case json.Number:
+			// Numbers are scanned as written, e.g. card numbers sent
+			// unquoted, and a masked number becomes a string
+			if masked := walk(string(v), path, fieldPath); masked != any(string(v)) {
+				return masked
+			}
+		}
+		return value
+	}
+	return walk(data, nil, nil), records
+}
+
+// inScope reports whether a value at fieldPath (array indices omitted) is
+// covered by the rule's fields
+func (r *piiRule) inScope(fieldPath []string) bool {
+	if len(r.fields) == 0 {
+		return true
+	}
+	for _, field := range r.fields {
+		if len(fieldPath) >= len(field) && slices.Equal(fieldPath[:len(field)], field) {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by
+// card numbers
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
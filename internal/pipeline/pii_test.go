@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestApply_PII(t *testing.T) {
+	p, err := New(Config{PII: []PIIRule{
+		{Builtin: "email", Fields: []string{"customer"}},
+		{Builtin: "card", Mask: "[card]"},
+		{Name: "ssn", Pattern: `\b\d{3}-\d{2}-\d{4}\b`, Action: ActionReject},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	event := &store.StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(`{
+		"customer": {"email": "ann@example.com", "notes": ["call bob@example.com"]},
+		"support": "help@example.com",
+		"payment": "4111 1111 1111 1111",
+		"reference": "4111 1111 1111 1112"
+	}`)}
+	audit, err := p.Apply(event, Request{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var data map[string]any
+	json.Unmarshal(event.Data, &data)
+	customer := data["customer"].(map[string]any)
+	if customer["email"] != DefaultMask || customer["notes"].([]any)[0] != "call "+DefaultMask {
+		t.Errorf("Expected emails under customer masked, got %v", customer)
+	}
+	if data["support"] != "help@example.com" {
+		t.Errorf("Expected emails outside the rule's fields kept, got %v", data["support"])
+	}
+	if data["payment"] != "[card]" || data["reference"] != "4111 1111 1111 1112" {
+		t.Errorf("Expected only the Luhn-valid card masked, got %v and %v", data["payment"], data["reference"])
+	}
+
+	paths := make(map[string]AuditRecord)
+	for _, record := range audit {
+		paths[record.Path] = record
+	}
+	if len(audit) != 3 || paths["customer.notes.0"].Rule != "email" || paths["payment"].Action != "masked" {
+		t.Errorf("Unexpected audit records: %+v", audit)
+	}
+
+	rejected := &store.StoredEvent{Type: "Signup", Data: json.RawMessage(`{"ssn":"123-45-6789"}`)}
+	audit, err = p.Apply(rejected, Request{})
+	if !errors.Is(err, ErrDenied) {
+		t.Fatalf("Expected the SSN to be rejected, got %v", err)
+	}
+	if len(audit) != 1 || audit[0].Action != "rejected" || audit[0].Path != "ssn" {
+		t.Errorf("Expected a rejection record, got %+v", audit)
+	}
+	if string(rejected.Data) != `{"ssn":"123-45-6789"}` {
+		t.Errorf("Expected rejected data untouched, got %s", rejected.Data)
+	}
+}
+
+func TestApply_PIINumbers(t *testing.T) {
+	p, err := New(Config{PII: []PIIRule{{Builtin: "card"}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	event := &store.StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(`{"card":4111111111111111,"amount":1250}`)}
+	audit, err := p.Apply(event, Request{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if string(event.Data) != `{"amount":1250,"card":"`+DefaultMask+`"}` {
+		t.Errorf("Expected the numeric card masked and other numbers kept, got %s", event.Data)
+	}
+	if len(audit) != 1 || audit[0].Path != "card" || audit[0].Action != "masked" {
+		t.Errorf("Unexpected audit records: %+v", audit)
+	}
+}
+
+func TestNew_InvalidPII(t *testing.T) {
+	for name, rule := range map[string]PIIRule{
+		"no pattern":       {},
+		"both":             {Builtin: "email", Pattern: "x"},
+		"unknown builtin":  {Builtin: "phone"},
+		"bad regexp":       {Pattern: "("},
+		"unknown action":   {Builtin: "email", Action: "drop"},
+		"malformed fields": {Builtin: "email", Fields: []string{"a."}},
+	} {
+		if _, err := New(Config{PII: []PIIRule{rule}}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	for number, want := range map[string]bool{
+		"4111111111111111":    true,
+		"4111-1111-1111-1111": true,
+		"5500 0000 0000 0004": true,
+		"4111111111111112":    false,
+		"1234567890123":       false,
+	} {
+		if got := luhnValid(number); got != want {
+			t.Errorf("luhnValid(%q) = %v, want %v", number, got, want)
+		}
+	}
+}
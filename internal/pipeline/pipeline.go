@@ -0,0 +1,335 @@
+// Package pipeline applies write-time policies to events before they are
+// stored: an allowlist limits the accepted event types and their sizes, deny
+// rules reject events, strip rules remove fields from their data, PII rules
+// mask or reject values matching sensitive patterns and enrichment adds
+// server-side metadata.
+//
+// A pipeline is configured in YAML:
+//
+//	allow:
+//	  - type: OrderPlaced
+//	    max_bytes: 65536
+//	  - type: "User*"
+//	  - type: "Debug*"
+//	deny:
+//	  - type: "Debug*"
+//	    reason: debug events are not accepted in production
+//	  - field: card.number
+//	    reason: raw card numbers must be tokenized
+//	strip: [password, user.ssn]
+//	pii:
+//	  - builtin: email
+//	    fields: [customer]
+//	  - builtin: card
+//	    action: reject
+//	enrich:
+//	  server_time: true
+//	  tenant: true
+//	  request_id: true
+//
+// The allowlist and deny rules run first and see the event as sent, then
+// fields are stripped, then PII rules run in order, then metadata is added.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Metadata keys set by enrichment; they overwrite values sent by the client
+const (
+	MetadataServerTime = "server-time"
+	MetadataTenant     = "tenant"
+	MetadataRequestID  = "request-id"
+)
+
+// ErrDenied is wrapped by the errors of events rejected by the allowlist, a
+// deny rule or a PII rule
+var ErrDenied = errors.New("event denied by policy")
+
+// Config describes a pipeline
+type Config struct {
+	Allow  []TypeRule   `yaml:"allow,omitempty"` // When set, only matching event types are accepted
+	Deny   []Rule       `yaml:"deny,omitempty"`
+	Strip  []string     `yaml:"strip,omitempty"` // Dotted paths into the event data, e.g. "user.ssn"
+	PII    []PIIRule    `yaml:"pii,omitempty"`
+	Enrich EnrichConfig `yaml:"enrich,omitempty"`
+}
+
+// TypeRule allows events whose type matches Type (exact, or a prefix when
+// it ends in "*") and, when MaxBytes is set, whose data is at most MaxBytes
+// long. The first rule matching an event's type applies, so list specific
+// types before prefixes.
+type TypeRule struct {
+	Type     string `yaml:"type"`
+	MaxBytes int    `yaml:"max_bytes,omitempty"`
+}
+
+// Rule rejects events whose type matches Type (exact, or a prefix when it
+// ends in "*") and whose data has Field (equal to Equals, when set). Empty
+// Type or Field match any event.
+type Rule struct {
+	Type   string `yaml:"type,omitempty"`
+	Field  string `yaml:"field,omitempty"`
+	Equals string `yaml:"equals,omitempty"`
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// EnrichConfig selects the metadata added to every event
+type EnrichConfig struct {
+	ServerTime bool `yaml:"server_time,omitempty"` // Time the server received the event (RFC 3339, UTC)
+	Tenant     bool `yaml:"tenant,omitempty"`
+	RequestID  bool `yaml:"request_id,omitempty"`
+}
+
+// Request carries the request attributes available to enrichment
+type Request struct {
+	Tenant    string
+	RequestID string
+	Time      time.Time
+}
+
+// Pipeline applies a Config to events. A nil Pipeline leaves events as they are.
+type Pipeline struct {
+	allow     []TypeRule
+	deny      []Rule
+	strip     [][]string
+	pii       []*piiRule
+	enrich    EnrichConfig
+	needsData bool // Whether any rule or strip path looks into the data
+}
+
+// New validates config and returns its pipeline
+func New(config Config) (*Pipeline, error) {
+	p := &Pipeline{enrich: config.Enrich}
+	for i, rule := range config.Allow {
+		if rule.Type == "" {
+			return nil, fmt.Errorf("allow rule %d: needs a type", i)
+		}
+		if rule.MaxBytes < 0 {
+			return nil, fmt.Errorf("allow rule %d: max_bytes cannot be negative", i)
+		}
+		p.allow = append(p.allow, rule)
+	}
+	for i, rule := range config.Deny {
+		if rule.Type == "" && rule.Field == "" {
+			return nil, fmt.Errorf("deny rule %d: needs type or field", i)
+		}
+		if rule.Equals != "" && rule.Field == "" {
+			return nil, fmt.Errorf("deny rule %d: equals needs field", i)
+		}
+		if rule.Field != "" {
+			p.needsData = true
+		}
+		p.deny = append(p.deny, rule)
+	}
+	for _, path := range config.Strip {
+		if !validPath(path) {
+			return nil, fmt.Errorf("strip: invalid path %q", path)
+		}
+		p.strip = append(p.strip, strings.Split(path, "."))
+		p.needsData = true
+	}
+	for i, rule := range config.PII {
+		r, err := newPIIRule(i, rule)
+		if err != nil {
+			return nil, err
+		}
+		p.pii = append(p.pii, r)
+		p.needsData = true
+	}
+	return p, nil
+}
+
+// validPath reports whether path is a well-formed dotted path
+func validPath(path string) bool {
+	return path != "" && !strings.Contains(path, "..") && !strings.HasPrefix(path, ".") && !strings.HasSuffix(path, ".")
+}
+
+// Load reads a pipeline config from a YAML file
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pipeline config: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse pipeline config: %w", err)
+	}
+	return New(config)
+}
+
+// Apply runs the pipeline on event, modifying it in place, and returns a
+// record of every PII rule action. Errors for denied events wrap ErrDenied.
+func (p *Pipeline) Apply(event *store.StoredEvent, req Request) ([]AuditRecord, error) {
+	if p == nil {
+		return nil, nil
+	}
+	if err := p.checkAllowed(event); err != nil {
+		return nil, err
+	}
+
+	var data any
+	if p.needsData && len(event.Data) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(event.Data))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			return nil, fmt.Errorf("decode event data: %w", err)
+		}
+	}
+
+	for _, rule := range p.deny {
+		if rule.matches(event.Type, data) {
+			reason := rule.Reason
+			if reason == "" {
+				reason = "matches a deny rule"
+			}
+			return nil, fmt.Errorf("%w: %s", ErrDenied, reason)
+		}
+	}
+
+	modified := false
+	for _, path := range p.strip {
+		if remove(data, path) {
+			modified = true
+		}
+	}
+
+	var audit []AuditRecord
+	for _, rule := range p.pii {
+		var records []AuditRecord
+		data, records = rule.scan(data)
+		audit = append(audit, records...)
+		if len(records) == 0 {
+			continue
+		}
+		if rule.reject {
+			return audit, fmt.Errorf("%w: %s found in %s", ErrDenied, rule.name, records[0].Path)
+		}
+		modified = true
+	}
+
+	if modified {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return audit, fmt.Errorf("encode event data: %w", err)
+		}
+		event.Data = encoded
+	}
+
+	p.addMetadata(event, req)
+	return audit, nil
+}
+
+func (p *Pipeline) addMetadata(event *store.StoredEvent, req Request) {
+	set := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[key] = value
+	}
+	if p.enrich.ServerTime {
+		set(MetadataServerTime, req.Time.UTC().Format(time.RFC3339Nano))
+	}
+	if p.enrich.Tenant {
+		set(MetadataTenant, req.Tenant)
+	}
+	if p.enrich.RequestID {
+		set(MetadataRequestID, req.RequestID)
+	}
+}
+
+// checkAllowed rejects events the allowlist does not cover
+func (p *Pipeline) checkAllowed(event *store.StoredEvent) error {
+	if len(p.allow) == 0 {
+		return nil
+	}
+	for _, rule := range p.allow {
+		if !MatchType(rule.Type, event.Type) {
+			continue
+		}
+		if rule.MaxBytes > 0 && len(event.Data) > rule.MaxBytes {
+			return fmt.Errorf("%w: %s data is %d bytes, max %d", ErrDenied, event.Type, len(event.Data), rule.MaxBytes)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: event type %q is not allowed", ErrDenied, event.Type)
+}
+
+// MatchType reports whether eventType matches pattern, exactly or as a
+// prefix when pattern ends in "*"
+func MatchType(pattern, eventType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return eventType == pattern
+}
+
+// matches reports whether an event with the given type and decoded data
+// is denied by the rule
+func (r Rule) matches(eventType string, data any) bool {
+	if r.Type != "" && !MatchType(r.Type, eventType) {
+		return false
+	}
+	if r.Field == "" {
+		return true
+	}
+
+	value, ok := lookup(data, strings.Split(r.Field, "."))
+	if !ok {
+		return false
+	}
+	if r.Equals == "" {
+		return true
+	}
+	if s, isString := value.(string); isString {
+		return s == r.Equals
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded) == r.Equals
+}
+
+// lookup returns the value at path in decoded JSON data
+func lookup(data any, path []string) (any, bool) {
+	for _, key := range path {
+		object, ok := data.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if data, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// remove deletes the value at path from decoded JSON data and reports
+// whether it was present
+func remove(data any, path []string) bool {
+	parent, ok := lookup(data, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+	object, ok := parent.(map[string]any)
+	if !ok {
+		return false
+	}
+	key := path[len(path)-1]
+	if _, ok := object[key]; !ok {
+		return false
+	}
+	delete(object, key)
+	return true
+}
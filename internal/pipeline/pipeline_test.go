@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestApply(t *testing.T) {
+	p, err := New(Config{
+		Deny: []Rule{
+			{Type: "Debug*", Reason: "no debug events"},
+			{Field: "card.number"},
+			{Type: "OrderPlaced", Field: "status", Equals: "test"},
+		},
+		Strip:  []string{"password", "user.ssn"},
+		Enrich: EnrichConfig{ServerTime: true, Tenant: true, RequestID: true},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	req := Request{Tenant: "alice", RequestID: "req-1", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	denied := []*store.StoredEvent{
+		{Type: "DebugPing", Data: json.RawMessage(`{}`)},
+		{Type: "PaymentMade", Data: json.RawMessage(`{"card":{"number":"4111"}}`)},
+		{Type: "OrderPlaced", Data: json.RawMessage(`{"status":"test"}`)},
+	}
+	for _, event := range denied {
+		if _, err := p.Apply(event, req); !errors.Is(err, ErrDenied) {
+			t.Errorf("Expected %s to be denied, got %v", event.Data, err)
+		}
+	}
+
+	event := &store.StoredEvent{
+		Type:     "OrderPlaced",
+		Data:     json.RawMessage(`{"status":"paid","amount":12.50,"password":"x","user":{"id":7,"ssn":"123"}}`),
+		Metadata: map[string]string{"tenant": "spoofed", "caller": "web"},
+	}
+	if _, err := p.Apply(event, req); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if string(event.Data) != `{"amount":12.50,"status":"paid","user":{"id":7}}` {
+		t.Errorf("Expected password and user.ssn stripped, got %s", event.Data)
+	}
+	want := map[string]string{
+		"caller":           "web",
+		MetadataTenant:     "alice",
+		MetadataRequestID:  "req-1",
+		MetadataServerTime: "2026-01-02T03:04:05Z",
+	}
+	for k, v := range want {
+		if event.Metadata[k] != v {
+			t.Errorf("Expected metadata %s=%q, got %q", k, v, event.Metadata[k])
+		}
+	}
+
+	// Events without the stripped fields keep their data byte for byte
+	untouched := &store.StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(`{"b":1, "a":2}`)}
+	p.Apply(untouched, req)
+	if string(untouched.Data) != `{"b":1, "a":2}` {
+		t.Errorf("Expected data unchanged, got %s", untouched.Data)
+	}
+
+	var nilPipeline *Pipeline
+	if _, err := nilPipeline.Apply(event, req); err != nil {
+		t.Errorf("Expected a nil pipeline to accept events, got %v", err)
+	}
+}
+
+func TestApply_Allowlist(t *testing.T) {
+	p, err := New(Config{
+		Allow: []TypeRule{
+			{Type: "UserAvatarSet", MaxBytes: 16},
+			{Type: "User*"},
+			{Type: "OrderPlaced", MaxBytes: 32},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		eventType string
+		data      string
+		allowed   bool
+	}{
+		{"UserCreated", `{"name":"a very long name indeed"}`, true},
+		{"UserAvatarSet", `{"url":"x"}`, true},
+		{"UserAvatarSet", `{"url":"https://example.com/a.png"}`, false}, // First matching rule applies
+		{"OrderPlaced", `{"id":1}`, true},
+		{"OrderPlaced", `{"id":1,"items":["a","b","c","d","e"]}`, false},
+		{"OrderPlacedV2", `{}`, false},
+		{"Unknown", `{}`, false},
+	} {
+		event := &store.StoredEvent{Type: tc.eventType, Data: json.RawMessage(tc.data)}
+		_, err := p.Apply(event, Request{})
+		if tc.allowed && err != nil {
+			t.Errorf("%s %s: expected to be allowed, got %v", tc.eventType, tc.data, err)
+		}
+		if !tc.allowed && !errors.Is(err, ErrDenied) {
+			t.Errorf("%s %s: expected ErrDenied, got %v", tc.eventType, tc.data, err)
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, config := range map[string]Config{
+		"allow w/o type":   {Allow: []TypeRule{{MaxBytes: 10}}},
+		"negative size":    {Allow: []TypeRule{{Type: "A", MaxBytes: -1}}},
+		"empty rule":       {Deny: []Rule{{Reason: "everything"}}},
+		"equals w/o field": {Deny: []Rule{{Type: "A", Equals: "x"}}},
+		"empty strip path": {Strip: []string{""}},
+		"malformed strip":  {Strip: []string{"user..ssn"}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	os.WriteFile(path, []byte("deny:\n  - type: Debug*\nstrip: [password]\nenrich:\n  tenant: true\n"), 0o644)
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(p.deny) != 1 || len(p.strip) != 1 || !p.enrich.Tenant {
+		t.Errorf("Unexpected pipeline: %+v", p)
+	}
+}
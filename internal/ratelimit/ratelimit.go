@@ -0,0 +1,195 @@
+// Package ratelimit enforces request limits in one-second windows. Counts
+// are kept in memory and, with a Store, shared with the other replicas of a
+// deployment, so a limit holds for the deployment as a whole.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultSyncInterval is how often counts are exchanged with the Store.
+// Replicas learn about each other's requests this late, so a deployment can
+// admit up to one interval of traffic from every other replica beyond the
+// limit.
+const DefaultSyncInterval = 100 * time.Millisecond
+
+// Store holds the request counts of all replicas
+type Store interface {
+	// Add adds counts to the counters of their keys, which expire after ttl,
+	// and returns the new totals
+	Add(ctx context.Context, counts map[string]int64, ttl time.Duration) (map[string]int64, error)
+}
+
+// windowID names the window of one key in one second
+type windowID struct {
+	key   string
+	start int64 // Unix second
+}
+
+// counter returns the name of the window's counter in the store
+func (id windowID) counter() string {
+	return id.key + ":" + strconv.FormatInt(id.start, 10)
+}
+
+// window counts one key's requests in one second
+type window struct {
+	local  int64 // Admitted here and not yet added to the store
+	shared int64 // Store total at the last sync, including our requests
+}
+
+// Limiter admits requests per key up to a limit per second
+type Limiter struct {
+	store Store
+	now   func() time.Time
+
+	mu      sync.Mutex
+	windows map[windowID]*window
+	span    int64 // Seconds of windows kept, the longest burst span asked for
+	failing bool  // The last sync failed; logged once per outage
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a limiter counting in memory only (store nil), or sharing
+// counts through store every syncInterval
+func New(store Store, syncInterval time.Duration) *Limiter {
+	l := &Limiter{
+		store:   store,
+		now:     time.Now,
+		windows: make(map[windowID]*window),
+		span:    1,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				l.sync()
+			}
+		}
+	}()
+	return l
+}
+
+// Allow admits one request for key if fewer than limit were admitted in
+// the current second, by this replica and, as of the last sync, all others
+func (l *Limiter) Allow(key string, limit int) bool {
+	return l.AllowBurst(key, limit, limit)
+}
+
+// AllowBurst is Allow for a key that may send up to burst requests in one
+// second, as long as it stays within limit per second on average over the
+// ceil(burst/limit) seconds up to the current one. A key idle for that long
+// can therefore send a burst at once.
+func (l *Limiter) AllowBurst(key string, limit, burst int) bool {
+	current := l.now().Unix()
+	span := int64(1)
+	if burst > limit {
+		span = int64((burst + limit - 1) / limit)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.span = max(l.span, span)
+
+	id := windowID{key: key, start: current}
+	w := l.windows[id]
+	if w == nil {
+		w = &window{}
+		l.windows[id] = w
+	}
+	if w.shared+w.local >= int64(max(burst, limit)) {
+		return false
+	}
+	if span > 1 {
+		var total int64
+		for start := current - span + 1; start <= current; start++ {
+			if w := l.windows[windowID{key: key, start: start}]; w != nil {
+				total += w.shared + w.local
+			}
+		}
+		if total >= int64(limit)*span {
+			return false
+		}
+	}
+	w.local++
+	return true
+}
+
+// sync adds the requests admitted since the last sync to the store, picks
+// up the totals of all replicas and forgets windows that are over
+func (l *Limiter) sync() {
+	current := l.now().Unix()
+
+	l.mu.Lock()
+	span := l.span
+	flushed := make(map[windowID]int64, len(l.windows))
+	for id, w := range l.windows {
+		// Windows are kept for the longest burst span, the last one beyond
+		// it until its requests reach the store; older counters have expired
+		// there anyway
+		if id.start <= current-span && (w.local == 0 || l.store == nil || id.start < current-span) {
+			delete(l.windows, id)
+			continue
+		}
+		flushed[id] = w.local
+	}
+	l.mu.Unlock()
+
+	if l.store == nil || len(flushed) == 0 {
+		return
+	}
+
+	counts := make(map[string]int64, len(flushed))
+	for id, n := range flushed {
+		counts[id.counter()] = n
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	totals, err := l.store.Add(ctx, counts, time.Duration(span+1)*time.Second)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err != nil {
+		// Until the store is back every replica enforces the limit alone
+		if !l.failing {
+			slog.Warn("Rate limit counters unavailable, limiting per replica", "error", err)
+			l.failing = true
+		}
+		return
+	}
+	if l.failing {
+		slog.Info("Rate limit counters available again")
+		l.failing = false
+	}
+
+	for id, n := range flushed {
+		if w := l.windows[id]; w != nil {
+			w.local -= n
+			w.shared = totals[id.counter()]
+		}
+	}
+}
+
+// Close stops syncing with the store
+func (l *Limiter) Close() {
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+	<-l.done
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store shared by the limiters of a test
+type memoryStore struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	err    error
+}
+
+func (m *memoryStore) Add(ctx context.Context, counts map[string]int64, ttl time.Duration) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	totals := make(map[string]int64, len(counts))
+	for key, n := range counts {
+		m.counts[key] += n
+		totals[key] = m.counts[key]
+	}
+	return totals, nil
+}
+
+// newTestLimiter returns a limiter whose clock is set by the test and
+// which only syncs when the test calls sync
+func newTestLimiter(t *testing.T, store Store, now *time.Time) *Limiter {
+	t.Helper()
+	l := New(store, time.Hour)
+	l.now = func() time.Time { return *now }
+	t.Cleanup(l.Close)
+	return l
+}
+
+func TestLimiter_Local(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newTestLimiter(t, nil, &now)
+
+	for i := range 3 {
+		if !l.Allow("alice", 3) {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+	if l.Allow("alice", 3) {
+		t.Error("Fourth request in one second should be rejected")
+	}
+	if !l.Allow("bob", 3) {
+		t.Error("Keys should be limited separately")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow("alice", 3) {
+		t.Error("A new second should admit requests again")
+	}
+
+	l.sync()
+	if len(l.windows) != 1 {
+		t.Errorf("Expected past windows to be dropped, have %d", len(l.windows))
+	}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newTestLimiter(t, nil, &now)
+
+	// 2 per second on average over 3 seconds, up to 6 at once
+	admitted := 0
+	for range 8 {
+		if l.AllowBurst("alice", 2, 6) {
+			admitted++
+		}
+	}
+	if admitted != 6 {
+		t.Fatalf("Expected a burst of 6, admitted %d", admitted)
+	}
+
+	// The burst is used up until it leaves the span
+	now = now.Add(time.Second)
+	if l.AllowBurst("alice", 2, 6) {
+		t.Error("Expected no requests right after the burst")
+	}
+	now = now.Add(2 * time.Second)
+	l.sync()
+	admitted = 0
+	for range 8 {
+		if l.AllowBurst("alice", 2, 6) {
+			admitted++
+		}
+	}
+	if admitted != 6 {
+		t.Errorf("Expected another burst of 6 once the span passed, admitted %d", admitted)
+	}
+	if len(l.windows) > 3 {
+		t.Errorf("Expected windows to be kept for the span only, have %d", len(l.windows))
+	}
+}
+
+func TestLimiter_Shared(t *testing.T) {
+	store := &memoryStore{counts: make(map[string]int64)}
+	now := time.Unix(1000, 0)
+	a := newTestLimiter(t, store, &now)
+	b := newTestLimiter(t, store, &now)
+
+	for range 3 {
+		a.Allow("alice", 5)
+	}
+	a.sync()
+	b.Allow("alice", 5)
+	b.sync() // Learns about a's requests
+
+	admitted := 0
+	for range 5 {
+		if b.Allow("alice", 5) {
+			admitted++
+		}
+	}
+	if admitted != 1 {
+		t.Errorf("Expected 1 request left across replicas, b admitted %d", admitted)
+	}
+
+	b.sync()
+	a.sync()
+	if a.Allow("alice", 5) {
+		t.Error("Expected a to see the limit reached after syncing")
+	}
+	if got := store.counts["alice:1000"]; got != 5 {
+		t.Errorf("Expected 5 requests in the store, got %d", got)
+	}
+
+	// Requests admitted in the last second are synced after it ends
+	a.windows = make(map[windowID]*window)
+	a.Allow("bob", 5)
+	now = now.Add(time.Second)
+	a.sync()
+	if got := store.counts["bob:1000"]; got != 1 {
+		t.Errorf("Expected the last second's request to reach the store, got %d", got)
+	}
+}
+
+func TestLimiter_StoreFailure(t *testing.T) {
+	store := &memoryStore{counts: make(map[string]int64), err: errors.New("connection refused")}
+	now := time.Unix(1000, 0)
+	l := newTestLimiter(t, store, &now)
+
+	l.Allow("alice", 2)
+	l.sync()
+
+	// The replica keeps limiting on its own count, and flushes it later
+	l.Allow("alice", 2)
+	if l.Allow("alice", 2) {
+		t.Error("Expected the local count to be enforced while the store is down")
+	}
+	store.err = nil
+	l.sync()
+	if got := store.counts["alice:1000"]; got != 2 {
+		t.Errorf("Expected 2 requests flushed after recovery, got %d", got)
+	}
+}
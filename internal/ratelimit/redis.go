@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/redis"
+)
+
+// redisPrefix namespaces the counters in a Redis shared with other uses
+const redisPrefix = "ebuse:ratelimit:"
+
+// Redis is a Store keeping counters in Redis, with one pipelined INCRBY and
+// EXPIRE per counter
+type Redis struct {
+	client interface {
+		Do(ctx context.Context, cmds ...[]string) ([]any, error)
+	}
+}
+
+// NewRedis returns a Store on client
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Add implements Store
+func (r *Redis) Add(ctx context.Context, counts map[string]int64, ttl time.Duration) (map[string]int64, error) {
+	keys := make([]string, 0, len(counts))
+	var cmds [][]string
+	seconds := strconv.Itoa(max(int(ttl.Seconds()), 1))
+	for key, n := range counts {
+		keys = append(keys, key)
+		cmds = append(cmds,
+			[]string{"INCRBY", redisPrefix + key, strconv.FormatInt(n, 10)},
+			[]string{"EXPIRE", redisPrefix + key, seconds})
+	}
+
+	replies, err := r.client.Do(ctx, cmds...)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]int64, len(keys))
+	for i, key := range keys {
+		total, ok := replies[2*i].(int64)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected INCRBY reply %v", replies[2*i])
+		}
+		totals[key] = total
+	}
+	return totals, nil
+}
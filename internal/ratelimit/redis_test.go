@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers INCRBY and EXPIRE from memory
+type fakeRedis struct {
+	counters map[string]int64
+	expires  map[string]string
+}
+
+func (f *fakeRedis) Do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	replies := make([]any, len(cmds))
+	for i, cmd := range cmds {
+		switch cmd[0] {
+		case "INCRBY":
+			n, _ := strconv.ParseInt(cmd[2], 10, 64)
+			f.counters[cmd[1]] += n
+			replies[i] = f.counters[cmd[1]]
+		case "EXPIRE":
+			f.expires[cmd[1]] = cmd[2]
+			replies[i] = int64(1)
+		}
+	}
+	return replies, nil
+}
+
+func TestRedis_Add(t *testing.T) {
+	f := &fakeRedis{counters: make(map[string]int64), expires: make(map[string]string)}
+	r := &Redis{client: f}
+
+	ctx := context.Background()
+	if _, err := r.Add(ctx, map[string]int64{"alice:1000": 3, "bob:1000": 1}, 2*time.Second); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	totals, err := r.Add(ctx, map[string]int64{"alice:1000": 2}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(totals) != 1 || totals["alice:1000"] != 5 {
+		t.Errorf("Expected total 5, got %v", totals)
+	}
+	if f.expires["ebuse:ratelimit:alice:1000"] != "2" || f.counters["ebuse:ratelimit:bob:1000"] != 1 {
+		t.Errorf("Expected prefixed counters expiring after 2s, got %v and %v", f.counters, f.expires)
+	}
+}
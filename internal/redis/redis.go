@@ -0,0 +1,316 @@
+// Package redis is a minimal Redis client: pipelined commands over one
+// shared connection, and pub/sub subscriptions on connections of their own.
+// It speaks RESP2, so it also works with compatible servers such as Valkey.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeouts of the client. Commands without a context deadline get
+// DefaultCommandTimeout; subscriptions ping the server every PingInterval
+// and are considered broken when no reply arrives within another.
+const (
+	DefaultCommandTimeout = time.Second
+	PingInterval          = 15 * time.Second
+
+	dialTimeout = 2 * time.Second
+)
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Client sends commands to one server. The shared connection is opened on
+// first use and re-established after errors.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config // nil for plain TCP
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// conn is one connection with its reply reader
+type conn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+// New parses a redis:// or rediss:// (TLS) URL, e.g.
+// redis://:password@redis:6379/0. Nothing is dialed yet.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis URL: %w", err)
+	}
+
+	c := &Client{addr: u.Host}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("unsupported redis URL scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends cmds in one write and returns their replies: strings, int64s,
+// []any or nil. The first error reply fails the call.
+func (c *Client) Do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		cn, err := c.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = cn
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultCommandTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	replies, err := c.conn.roundTrip(cmds)
+	if replyErr := Error(""); err != nil && !errors.As(err, &replyErr) {
+		// Replies could no longer be matched to commands
+		c.conn.Close()
+		c.conn = nil
+	}
+	return replies, err
+}
+
+// Subscribe calls fn with every message published to channel until ctx is
+// done or the connection breaks, and returns why. Messages published while
+// no subscription is open are lost.
+func (c *Client) Subscribe(ctx context.Context, channel string, fn func(message string)) error {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+
+	cn.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := cn.roundTrip([][]string{{"SUBSCRIBE", channel}}); err != nil {
+		return err
+	}
+
+	// Pings keep idle subscriptions from being dropped silently, and close
+	// unblocks the reader when ctx ends
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				cn.Close()
+				return
+			case <-ticker.C:
+				cn.SetWriteDeadline(time.Now().Add(dialTimeout))
+				if _, err := cn.Write(encode([][]string{{"PING"}})); err != nil {
+					cn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		cn.SetReadDeadline(time.Now().Add(2 * PingInterval))
+		reply, err := readReply(cn.br)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		// ["message", channel, payload]; pongs are ["pong", ""]
+		if items, ok := reply.([]any); ok && len(items) == 3 && items[0] == "message" {
+			if payload, ok := items[2].(string); ok {
+				fn(payload)
+			}
+		}
+	}
+}
+
+// Close closes the shared connection; subscriptions end with their context
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// dial connects, authenticates and selects the database
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var nc net.Conn
+	var err error
+	if c.tls != nil {
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	cn := &conn{Conn: nc, br: bufio.NewReader(nc)}
+
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		cn.SetDeadline(time.Now().Add(dialTimeout))
+		if _, err := cn.roundTrip(setup); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// roundTrip writes cmds and reads one reply per command
+func (cn *conn) roundTrip(cmds [][]string) ([]any, error) {
+	if _, err := cn.Write(encode(cmds)); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	replies := make([]any, len(cmds))
+	var failed error
+	for i := range cmds {
+		reply, err := readReply(cn.br)
+		if err != nil {
+			return nil, err
+		}
+		if replyErr, ok := reply.(Error); ok && failed == nil {
+			// Keep reading, so the connection stays usable
+			failed = fmt.Errorf("redis: %s: %w", cmds[i][0], replyErr)
+		}
+		replies[i] = reply
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return replies, nil
+}
+
+// encode writes cmds as RESP arrays of bulk strings
+func encode(cmds [][]string) []byte {
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(cmd)), 10)
+		buf = append(buf, "\r\n"...)
+		for _, arg := range cmd {
+			buf = append(buf, '$')
+			buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+			buf = append(buf, "\r\n"...)
+			buf = append(buf, arg...)
+			buf = append(buf, "\r\n"...)
+		}
+	}
+	return buf
+}
+
+// readReply reads one RESP reply: a string, Error, int64, []any or nil
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
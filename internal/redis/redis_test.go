@@ -0,0 +1,208 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the commands the client sends from memory
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu          sync.Mutex
+	counters    map[string]int64
+	selected    string
+	subscribers map[string][]net.Conn
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, counters: make(map[string]int64), subscribers: make(map[string][]net.Conn)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// url returns the server's URL with password and database
+func (f *fakeRedis) url(password string, db int) string {
+	return fmt.Sprintf("redis://:%s@%s/%d", password, f.ln.Addr(), db)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readReply(br)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		f.mu.Lock()
+		var out string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[len(args)-1] == f.password
+			out = "+OK\r\n"
+			if !authed {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required\r\n"
+		case args[0] == "SELECT":
+			f.selected = args[1]
+			out = "+OK\r\n"
+		case args[0] == "INCRBY":
+			n, _ := strconv.ParseInt(args[2], 10, 64)
+			f.counters[args[1]] += n
+			out = fmt.Sprintf(":%d\r\n", f.counters[args[1]])
+		case args[0] == "PUBLISH":
+			for _, sub := range f.subscribers[args[1]] {
+				fmt.Fprintf(sub, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2])
+			}
+			out = fmt.Sprintf(":%d\r\n", len(f.subscribers[args[1]]))
+		case args[0] == "SUBSCRIBE":
+			f.subscribers[args[1]] = append(f.subscribers[args[1]], conn)
+			out = fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := io.WriteString(conn, out); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	f := startFakeRedis(t, "s3cret")
+
+	c, err := New(f.url("s3cret", 2))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	replies, err := c.Do(ctx, []string{"INCRBY", "a", "3"}, []string{"INCRBY", "a", "2"})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if len(replies) != 2 || replies[1] != int64(5) {
+		t.Errorf("Expected replies 3 and 5, got %v", replies)
+	}
+
+	// Error replies fail the call but leave the connection usable
+	_, err = c.Do(ctx, []string{"NOPE"}, []string{"INCRBY", "a", "1"})
+	var replyErr Error
+	if !errors.As(err, &replyErr) || !strings.Contains(err.Error(), "NOPE") {
+		t.Errorf("Expected an error reply for NOPE, got %v", err)
+	}
+	if replies, err := c.Do(ctx, []string{"INCRBY", "a", "1"}); err != nil || replies[0] != int64(7) {
+		t.Errorf("Expected 7 on the same connection, got %v (%v)", replies, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.selected != "2" {
+		t.Errorf("Expected database 2, got %q", f.selected)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	f := startFakeRedis(t, "s3cret")
+
+	c, err := New(f.url("wrong", 0))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Do(context.Background(), []string{"INCRBY", "k", "1"}); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected an auth error, got %v", err)
+	}
+
+	// A server that went away is redialed on the next call
+	url := f.url("s3cret", 0)
+	f.ln.Close()
+	c, _ = New(url)
+	if _, err := c.Do(context.Background(), []string{"INCRBY", "k", "1"}); err == nil {
+		t.Error("Expected an error without a server")
+	}
+
+	for _, bad := range []string{"http://redis:6379", "redis://redis/db"} {
+		if _, err := New(bad); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	f := startFakeRedis(t, "")
+
+	c, err := New(f.url("", 0))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Subscribe(ctx, "news", func(message string) { messages <- message })
+	}()
+
+	// Publish until the subscription is open
+	deadline := time.After(5 * time.Second)
+	for received := false; !received; {
+		if _, err := c.Do(context.Background(), []string{"PUBLISH", "news", "hello"}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		select {
+		case message := <-messages:
+			if message != "hello" {
+				t.Fatalf("Expected hello, got %q", message)
+			}
+			received = true
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("No message received")
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the subscription to end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package retention prunes the events of a store that fell out of its
+// retention policy: those older than a maximum age, and those beyond a
+// maximum number of positions behind the head.
+package retention
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// DefaultInterval is the delay between prunes when Config leaves it zero
+const DefaultInterval = 10 * time.Minute
+
+// Policy limits which events a store keeps; zero fields are unlimited
+type Policy struct {
+	MaxAge    time.Duration `yaml:"max_age,omitempty"`    // Prune events appended longer ago
+	MaxEvents int64         `yaml:"max_events,omitempty"` // Keep this many positions up to the head
+}
+
+// Enabled reports whether p prunes anything
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxEvents > 0
+}
+
+// Validate rejects negative limits
+func (p Policy) Validate() error {
+	if p.MaxAge < 0 {
+		return errors.New("max_age must not be negative")
+	}
+	if p.MaxEvents < 0 {
+		return errors.New("max_events must not be negative")
+	}
+	return nil
+}
+
+// Config tunes a pruner
+type Config struct {
+	Name     string // Used in logs, e.g. the tenant name
+	Policy   Policy
+	Interval time.Duration // Delay between prunes
+
+	// Floor returns the first position that must be kept whatever the
+	// policy, e.g. the first one not archived yet (nil = no floor)
+	Floor func() int64
+
+	// Delete deletes the events before a position, e.g. moving them to a
+	// cold tier (nil = the store's DeleteBefore)
+	Delete func(ctx context.Context, position int64) (int64, error)
+}
+
+// Status is a snapshot of a pruner's progress
+type Status struct {
+	PrunedBefore int64     `json:"pruned_before"` // Events before this position were deleted
+	Deleted      int64     `json:"deleted"`       // Events deleted since the server started
+	LastError    string    `json:"last_error,omitempty"`
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+}
+
+// Pruner deletes the events of a store that its policy no longer keeps
+type Pruner struct {
+	st     store.EventStore
+	config Config
+	now    func() time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns a pruner of st; call Run to start it
+func New(st store.EventStore, config Config) *Pruner {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Delete == nil {
+		config.Delete = st.DeleteBefore
+	}
+	return &Pruner{st: st, config: config, now: time.Now}
+}
+
+// Status returns the pruner's current progress
+func (p *Pruner) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Run prunes every Interval until ctx is done. Failures are logged and
+// retried on the next tick.
+func (p *Pruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Pruning failed", "retention", p.config.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the events the policy no longer keeps and returns how many
+// were deleted
+func (p *Pruner) Prune(ctx context.Context) (int64, error) {
+	deleted, before, err := p.prune(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Deleted += deleted
+	if err != nil {
+		p.status.LastError = err.Error()
+		return deleted, err
+	}
+	p.status.PrunedBefore = max(p.status.PrunedBefore, before)
+	p.status.LastError = ""
+	p.status.LastSuccess = p.now()
+	return deleted, nil
+}
+
+// prune returns the events deleted and the position they were deleted
+// before
+func (p *Pruner) prune(ctx context.Context) (int64, int64, error) {
+	head, err := p.st.GetPosition(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	policy := p.config.Policy
+
+	var before int64
+	if policy.MaxEvents > 0 {
+		before = head - policy.MaxEvents + 1
+	}
+	if policy.MaxAge > 0 {
+		// Positions already pruned need not be searched again
+		from := max(p.Status().PrunedBefore, 1)
+		at, err := store.PositionAtFrom(ctx, p.st, p.now().Add(-policy.MaxAge), from)
+		if err != nil {
+			return 0, 0, err
+		}
+		// Events written without a timestamp have no age and never expire
+		unstamped, err := firstUnstamped(ctx, p.st, from, at)
+		if err != nil {
+			return 0, 0, err
+		}
+		before = max(before, min(at+1, unstamped))
+	}
+	if p.config.Floor != nil {
+		before = min(before, p.config.Floor())
+	}
+	if before <= max(p.Status().PrunedBefore, 1) {
+		return 0, 0, nil
+	}
+
+	deleted, err := p.config.Delete(ctx, before)
+	if err != nil {
+		return deleted, 0, err
+	}
+	if deleted > 0 {
+		slog.Info("Pruned events", "retention", p.config.Name, "before", before, "events", deleted)
+	}
+	return deleted, before, nil
+}
+
+// errStop ends a scan early
+var errStop = errors.New("stop")
+
+// firstUnstamped returns the position of the first event in [from, to]
+// with a zero timestamp, or to+1 if there is none
+func firstUnstamped(ctx context.Context, st store.EventStore, from, to int64) (int64, error) {
+	unstamped := to + 1
+	if to < from {
+		return unstamped, nil
+	}
+	err := st.LoadStream(ctx, from, 1000, func(events []*store.StoredEvent) error {
+		for _, event := range events {
+			if event.Position > to {
+				return errStop
+			}
+			if event.Timestamp.IsZero() {
+				unstamped = event.Position
+				return errStop
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return 0, err
+	}
+	return unstamped, nil
+}
@@ -0,0 +1,139 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// saveEvents appends one event per minute from start
+func saveEvents(t *testing.T, st store.EventStore, start time.Time, n int) {
+	t.Helper()
+	for i := range n {
+		event := &store.StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		if err := st.Save(context.Background(), event); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+// first returns the position of the first stored event
+func first(t *testing.T, st store.EventStore) int64 {
+	t.Helper()
+	events, err := st.Load(context.Background(), 1, -1)
+	if err != nil || len(events) == 0 {
+		t.Fatalf("Load failed: %d events, %v", len(events), err)
+	}
+	return events[0].Position
+}
+
+func TestPrune_MaxEvents(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	saveEvents(t, st, time.Now(), 25)
+
+	p := New(st, Config{Name: "test", Policy: Policy{MaxEvents: 10}})
+	deleted, err := p.Prune(ctx)
+	if err != nil || deleted != 15 {
+		t.Fatalf("Prune = %d, %v, want 15", deleted, err)
+	}
+	if got := first(t, st); got != 16 {
+		t.Errorf("First position = %d, want 16", got)
+	}
+	if status := p.Status(); status.PrunedBefore != 16 || status.Deleted != 15 || status.LastSuccess.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	// Nothing more until the log grows
+	if deleted, _ := p.Prune(ctx); deleted != 0 {
+		t.Errorf("Second prune deleted %d events", deleted)
+	}
+	saveEvents(t, st, time.Now(), 3)
+	if deleted, _ := p.Prune(ctx); deleted != 3 {
+		t.Errorf("Expected 3 more events pruned, got %d", deleted)
+	}
+}
+
+func TestPrune_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	saveEvents(t, st, start, 60)
+
+	p := New(st, Config{Name: "test", Policy: Policy{MaxAge: 30 * time.Minute}})
+	p.now = func() time.Time { return start.Add(time.Hour) }
+	// Events stamped 9:00 to 9:30 are at least 30 minutes old
+	if deleted, err := p.Prune(ctx); err != nil || deleted != 31 {
+		t.Fatalf("Prune = %d, %v, want 31", deleted, err)
+	}
+	if got := first(t, st); got != 32 {
+		t.Errorf("First position = %d, want 32", got)
+	}
+
+	// Later, the search resumes after the pruned positions
+	p.now = func() time.Time { return start.Add(80 * time.Minute) }
+	if deleted, err := p.Prune(ctx); err != nil || deleted != 20 {
+		t.Errorf("Prune = %d, %v, want 20", deleted, err)
+	}
+}
+
+func TestPrune_Unstamped(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	saveEvents(t, st, start, 5)
+	// Fresh events written without a timestamp
+	for range 10 {
+		if err := st.Save(ctx, &store.StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	saveEvents(t, st, start.Add(10*time.Minute), 5)
+
+	p := New(st, Config{Name: "test", Policy: Policy{MaxAge: 30 * 24 * time.Hour}})
+	p.now = func() time.Time { return start.Add(60 * 24 * time.Hour) }
+	// Only the stamped events before the first unstamped one expire
+	if deleted, err := p.Prune(ctx); err != nil || deleted != 5 {
+		t.Fatalf("Prune = %d, %v, want 5", deleted, err)
+	}
+	if got := first(t, st); got != 6 {
+		t.Errorf("First position = %d, want 6", got)
+	}
+	if deleted, err := p.Prune(ctx); err != nil || deleted != 0 {
+		t.Errorf("Prune = %d, %v, want 0", deleted, err)
+	}
+}
+
+func TestPrune_Floor(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	saveEvents(t, st, time.Now(), 25)
+
+	archived := int64(0)
+	p := New(st, Config{Name: "test", Policy: Policy{MaxEvents: 5}, Floor: func() int64 { return archived + 1 }})
+	if deleted, _ := p.Prune(ctx); deleted != 0 {
+		t.Errorf("Pruned %d events before any were archived", deleted)
+	}
+	archived = 10
+	if deleted, _ := p.Prune(ctx); deleted != 10 {
+		t.Errorf("Expected the 10 archived events pruned, got %d", deleted)
+	}
+	if got := first(t, st); got != 11 {
+		t.Errorf("First position = %d, want 11", got)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	if (Policy{}).Enabled() {
+		t.Error("Empty policy should be disabled")
+	}
+	if !(Policy{MaxAge: time.Hour}).Enabled() || !(Policy{MaxEvents: 1}).Enabled() {
+		t.Error("Policies with a limit should be enabled")
+	}
+	if (Policy{MaxAge: -time.Hour}).Validate() == nil || (Policy{MaxEvents: -1}).Validate() == nil {
+		t.Error("Negative limits should be rejected")
+	}
+}
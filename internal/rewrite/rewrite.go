@@ -0,0 +1,206 @@
+// Package rewrite copies an event log into a new store while transforming
+// its events, for one-time schema cleanups that upcasters cannot cover:
+// renaming event types, stripping or masking fields and re-encoding data.
+// Positions, timestamps, streams and metadata are kept, so subscriptions
+// and clients tracking positions carry over unchanged.
+//
+// Rules are configured in YAML:
+//
+//	rules:
+//	  - type: OrderCreated
+//	    rename: OrderPlaced
+//	  - type: "User*"
+//	    strip: [password, address.legacy_zip]
+//	    pii:
+//	      - builtin: email
+//	  - reencode: true
+//	    metadata:
+//	      rewritten: "2026-10"
+//
+// Every rule whose type matches applies, in order, and sees the event as
+// left by the rules before it; a rule without a type matches all events.
+// Within a rule, fields are stripped and masked (as by a write pipeline's
+// strip and pii settings) before the type is renamed.
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// DefaultBatchSize is how many events are read and imported at a time
+const DefaultBatchSize = 1000
+
+// Config describes a rewrite
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule transforms the events whose type matches Type (exact, or a prefix
+// when it ends in "*")
+type Rule struct {
+	Type     string             `yaml:"type,omitempty"`     // Empty = all events
+	Rename   string             `yaml:"rename,omitempty"`   // New event type
+	Strip    []string           `yaml:"strip,omitempty"`    // Dotted paths into the event data
+	PII      []pipeline.PIIRule `yaml:"pii,omitempty"`      // Masking rules; reject actions fail the rewrite
+	Reencode bool               `yaml:"reencode,omitempty"` // Re-encode data as compact JSON with sorted keys
+	Metadata map[string]string  `yaml:"metadata,omitempty"` // Metadata set on the event; empty values remove keys
+}
+
+// Rewriter applies a Config to events
+type Rewriter struct {
+	rules []rule
+}
+
+type rule struct {
+	Rule
+	fields *pipeline.Pipeline // Strip and PII settings (nil = none)
+}
+
+// New validates config and returns its rewriter
+func New(config Config) (*Rewriter, error) {
+	if len(config.Rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	rw := &Rewriter{}
+	for i, r := range config.Rules {
+		if r.Rename == "" && len(r.Strip) == 0 && len(r.PII) == 0 && !r.Reencode && len(r.Metadata) == 0 {
+			return nil, fmt.Errorf("rule %d: needs rename, strip, pii, reencode or metadata", i)
+		}
+		compiled := rule{Rule: r}
+		if len(r.Strip) > 0 || len(r.PII) > 0 {
+			p, err := pipeline.New(pipeline.Config{Strip: r.Strip, PII: r.PII})
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			compiled.fields = p
+		}
+		rw.rules = append(rw.rules, compiled)
+	}
+	return rw, nil
+}
+
+// Load reads a rewrite config from a YAML file
+func Load(path string) (*Rewriter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rewrite config: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse rewrite config: %w", err)
+	}
+	return New(config)
+}
+
+// Apply runs the rules on event, modifying it in place, and reports
+// whether it changed
+func (rw *Rewriter) Apply(event *store.StoredEvent) (bool, error) {
+	before, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range rw.rules {
+		if r.Type != "" && !pipeline.MatchType(r.Type, event.Type) {
+			continue
+		}
+		if _, err := r.fields.Apply(event, pipeline.Request{}); err != nil {
+			return false, err
+		}
+		if r.Reencode && len(event.Data) > 0 {
+			if event.Data, err = reencode(event.Data); err != nil {
+				return false, err
+			}
+		}
+		for key, value := range r.Metadata {
+			switch {
+			case value != "":
+				if event.Metadata == nil {
+					event.Metadata = make(map[string]string)
+				}
+				event.Metadata[key] = value
+			case event.Metadata != nil:
+				delete(event.Metadata, key)
+			}
+		}
+		if len(event.Metadata) == 0 {
+			event.Metadata = nil
+		}
+		if r.Rename != "" {
+			event.Type = r.Rename
+		}
+	}
+
+	after, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(before, after), nil
+}
+
+// reencode decodes JSON data and encodes it again compactly, with object
+// keys sorted and numbers kept exactly as written
+func reencode(data json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decode event data: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// Report summarizes a rewrite
+type Report struct {
+	Events  int64            `json:"events"`  // Events copied
+	Changed int64            `json:"changed"` // Events the rules changed
+	Renamed map[string]int64 `json:"renamed"` // Renamed events by "Old -> New"
+	Head    int64            `json:"head"`    // Last position copied
+}
+
+// Copy reads every event of src, applies rw and imports the results into
+// dst at their original positions. dst must be empty. With a nil dst,
+// nothing is written, for a dry run.
+func Copy(ctx context.Context, src store.EventStore, dst store.Importer, rw *Rewriter, batchSize int) (*Report, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	report := &Report{Renamed: make(map[string]int64)}
+	err := src.LoadStream(ctx, 1, batchSize, func(events []*store.StoredEvent) error {
+		if len(events) == 0 {
+			return nil
+		}
+		for _, event := range events {
+			oldType := event.Type
+			changed, err := rw.Apply(event)
+			if err != nil {
+				return fmt.Errorf("position %d: %w", event.Position, err)
+			}
+			if changed {
+				report.Changed++
+			}
+			if event.Type != oldType {
+				report.Renamed[oldType+" -> "+event.Type]++
+			}
+		}
+		if dst != nil {
+			if err := dst.ImportEvents(ctx, events); err != nil {
+				return err
+			}
+		}
+		report.Events += int64(len(events))
+		report.Head = events[len(events)-1].Position
+		return nil
+	})
+	return report, err
+}
@@ -0,0 +1,137 @@
+package rewrite
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestApply(t *testing.T) {
+	rw, err := New(Config{Rules: []Rule{
+		{Type: "OrderCreated", Rename: "OrderPlaced"},
+		{Type: "Order*", Strip: []string{"card"}, Metadata: map[string]string{"schema": "2", "legacy": ""}},
+		{Type: "User*", PII: []pipeline.PIIRule{{Builtin: "email"}}},
+		{Reencode: true},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	event := &store.StoredEvent{
+		Position: 3,
+		Type:     "OrderCreated",
+		Data:     json.RawMessage(`{ "total": 12.50, "card": "4111", "id": 7 }`),
+		Metadata: map[string]string{"legacy": "yes"},
+		StreamID: "order-7",
+	}
+	changed, err := rw.Apply(event)
+	if err != nil || !changed {
+		t.Fatalf("Apply() = %v, %v", changed, err)
+	}
+	if event.Type != "OrderPlaced" {
+		t.Errorf("Type = %q, want OrderPlaced", event.Type)
+	}
+	// Later rules see the renamed type
+	if string(event.Data) != `{"id":7,"total":12.50}` {
+		t.Errorf("Data = %s", event.Data)
+	}
+	if len(event.Metadata) != 1 || event.Metadata["schema"] != "2" {
+		t.Errorf("Metadata = %v", event.Metadata)
+	}
+	if event.Position != 3 || event.StreamID != "order-7" {
+		t.Errorf("Position and stream changed: %+v", event)
+	}
+
+	event = &store.StoredEvent{Type: "UserRegistered", Data: json.RawMessage(`{"email":"ann@example.com"}`)}
+	if _, err := rw.Apply(event); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if string(event.Data) == `{"email":"ann@example.com"}` {
+		t.Errorf("Expected the email masked, got %s", event.Data)
+	}
+
+	// Events already in shape are reported unchanged
+	event = &store.StoredEvent{Type: "Other", Data: json.RawMessage(`{"a":1}`)}
+	if changed, err := rw.Apply(event); err != nil || changed {
+		t.Errorf("Apply() of a clean event = %v, %v", changed, err)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, config := range map[string]Config{
+		"no rules":     {},
+		"empty rule":   {Rules: []Rule{{Type: "OrderPlaced"}}},
+		"invalid path": {Rules: []Rule{{Strip: []string{"a..b"}}}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: New succeeded", name)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemoryStore()
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// Positions 1, 2 and 5: the gap must survive the copy
+	err := src.ImportEvents(ctx, []*store.StoredEvent{
+		{Position: 1, Type: "OrderCreated", Data: json.RawMessage(`{"id":1}`), Timestamp: ts, StreamID: "order-1", StreamVersion: 1},
+		{Position: 2, Type: "OrderShipped", Data: json.RawMessage(`{"id":1}`), Timestamp: ts, StreamID: "order-1", StreamVersion: 2},
+		{Position: 5, Type: "OrderCreated", Data: json.RawMessage(`{"id":2}`), Timestamp: ts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rewrite.yaml")
+	os.WriteFile(path, []byte("rules:\n  - type: OrderCreated\n    rename: OrderPlaced\n"), 0644)
+	rw, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// A dry run writes nothing but reports the same
+	dry, err := Copy(ctx, src, nil, rw, 2)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	dst, err := store.NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	report, err := Copy(ctx, src, dst, rw, 2)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if report.Events != 3 || report.Changed != 2 || report.Head != 5 || report.Renamed["OrderCreated -> OrderPlaced"] != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	if dry.Events != report.Events || dry.Changed != report.Changed {
+		t.Errorf("dry run report = %+v, want %+v", dry, report)
+	}
+
+	events, err := dst.Load(ctx, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[2].Position != 5 {
+		t.Fatalf("Expected positions 1, 2 and 5, got %d events", len(events))
+	}
+	if events[0].Type != "OrderPlaced" || events[1].Type != "OrderShipped" || events[2].Type != "OrderPlaced" {
+		t.Errorf("Types = %s, %s, %s", events[0].Type, events[1].Type, events[2].Type)
+	}
+	if !events[1].Timestamp.Equal(ts) || events[1].StreamID != "order-1" || events[1].StreamVersion != 2 {
+		t.Errorf("Event 2 lost its timestamp or stream: %+v", events[1])
+	}
+	if pos, _ := dst.GetPosition(ctx); pos != 5 {
+		t.Errorf("Head = %d, want 5", pos)
+	}
+}
@@ -0,0 +1,229 @@
+// Package schema infers a JSON Schema from sample payloads, so event types
+// written without one can be documented from what is actually stored.
+// Every sample widens the schema: a field gets each JSON type it was seen
+// with, and it is required only if every sampled object had it.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of inferred schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Bounds that keep payloads with generated keys or deep nesting from
+// growing the schema without end
+const (
+	maxProperties = 500 // Per object; further keys are counted in Dropped
+	maxDepth      = 32  // Values nested deeper are not described
+)
+
+// typeOrder is the order JSON types are listed in
+var typeOrder = []string{"null", "boolean", "integer", "number", "string", "array", "object"}
+
+// Field summarizes one field of the sampled objects
+type Field struct {
+	Path     string   `json:"path"`    // Dotted, with [] for array items, e.g. lines[].sku
+	Types    []string `json:"types"`   // JSON types seen
+	Present  int64    `json:"present"` // Objects holding the field
+	Optional bool     `json:"optional"`
+}
+
+// Inferrer accumulates samples; create one with New
+type Inferrer struct {
+	root    *node
+	samples int64
+	dropped int64
+}
+
+// node describes the values seen at one place in the samples
+type node struct {
+	types     map[string]int64
+	objects   int64 // Values that were objects
+	props     map[string]*node
+	items     *node // Array elements
+	strings   int64
+	dateTimes int64 // Strings in RFC 3339 format
+}
+
+func newNode() *node {
+	return &node{types: make(map[string]int64)}
+}
+
+// New returns an Inferrer without samples
+func New() *Inferrer {
+	return &Inferrer{root: newNode()}
+}
+
+// Add widens the schema by one payload, which must be JSON
+func (in *Inferrer) Add(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	in.samples++
+	in.add(in.root, value, 0)
+	return nil
+}
+
+func (in *Inferrer) add(n *node, value any, depth int) {
+	switch v := value.(type) {
+	case nil:
+		n.types["null"]++
+	case bool:
+		n.types["boolean"]++
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			n.types["number"]++
+		} else {
+			n.types["integer"]++
+		}
+	case string:
+		n.types["string"]++
+		n.strings++
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			n.dateTimes++
+		}
+	case []any:
+		n.types["array"]++
+		if depth >= maxDepth {
+			return
+		}
+		for _, item := range v {
+			if n.items == nil {
+				n.items = newNode()
+			}
+			in.add(n.items, item, depth+1)
+		}
+	case map[string]any:
+		n.types["object"]++
+		n.objects++
+		if depth >= maxDepth {
+			return
+		}
+		if n.props == nil {
+			n.props = make(map[string]*node)
+		}
+		for key, item := range v {
+			prop, ok := n.props[key]
+			if !ok {
+				if len(n.props) >= maxProperties {
+					in.dropped++
+					continue
+				}
+				prop = newNode()
+				n.props[key] = prop
+			}
+			in.add(prop, item, depth+1)
+		}
+	}
+}
+
+// Samples returns the number of payloads added
+func (in *Inferrer) Samples() int64 {
+	return in.samples
+}
+
+// Dropped returns the number of object keys left out of the schema because
+// an object already had maxProperties fields
+func (in *Inferrer) Dropped() int64 {
+	return in.dropped
+}
+
+// Schema returns the inferred schema, titled title
+func (in *Inferrer) Schema(title string) map[string]any {
+	schema := in.root.schema()
+	schema["$schema"] = Draft
+	if title != "" {
+		schema["title"] = title
+	}
+	return schema
+}
+
+func (n *node) schema() map[string]any {
+	schema := make(map[string]any)
+	switch types := n.typeNames(); len(types) {
+	case 0:
+	case 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+	if n.strings > 0 && n.dateTimes == n.strings {
+		schema["format"] = "date-time"
+	}
+	if n.items != nil {
+		schema["items"] = n.items.schema()
+	}
+	if n.props != nil {
+		props := make(map[string]any, len(n.props))
+		var required []string
+		for key, prop := range n.props {
+			props[key] = prop.schema()
+			if prop.present() == n.objects {
+				required = append(required, key)
+			}
+		}
+		schema["properties"] = props
+		if len(required) > 0 {
+			slices.Sort(required)
+			schema["required"] = required
+		}
+	}
+	return schema
+}
+
+// typeNames lists the JSON types seen, integers folded into numbers once
+// both were seen
+func (n *node) typeNames() []string {
+	var names []string
+	for _, name := range typeOrder {
+		if n.types[name] > 0 && !(name == "integer" && n.types["number"] > 0) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// present is the number of values seen
+func (n *node) present() int64 {
+	var count int64
+	for _, c := range n.types {
+		count += c
+	}
+	return count
+}
+
+// Fields lists every field of the sampled objects by path
+func (in *Inferrer) Fields() []Field {
+	var fields []Field
+	in.root.fields("", &fields)
+	slices.SortFunc(fields, func(a, b Field) int { return strings.Compare(a.Path, b.Path) })
+	return fields
+}
+
+func (n *node) fields(prefix string, fields *[]Field) {
+	for key, prop := range n.props {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		*fields = append(*fields, Field{
+			Path:     path,
+			Types:    prop.typeNames(),
+			Present:  prop.present(),
+			Optional: prop.present() < n.objects,
+		})
+		prop.fields(path, fields)
+	}
+	if n.items != nil {
+		n.items.fields(prefix+"[]", fields)
+	}
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	in := New()
+	for _, sample := range []string{
+		`{"id": 1, "total": 9, "customer": {"email": "a@example.com"}, "lines": [{"sku": "A", "qty": 1}], "placed_at": "2026-03-01T12:00:00Z"}`,
+		`{"id": 2, "total": 9.5, "customer": {"email": null}, "lines": [{"sku": "B", "qty": 2, "gift": true}], "placed_at": "2026-03-02T12:00:00Z", "coupon": "SPRING"}`,
+	} {
+		if err := in.Add([]byte(sample)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := in.Add([]byte(`{"id":`)); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+	if in.Samples() != 2 {
+		t.Errorf("Samples = %d, want 2", in.Samples())
+	}
+
+	data, _ := json.Marshal(in.Schema("OrderPlaced"))
+	var got map[string]any
+	json.Unmarshal(data, &got)
+	var want map[string]any
+	json.Unmarshal([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "OrderPlaced",
+		"type": "object",
+		"required": ["customer", "id", "lines", "placed_at", "total"],
+		"properties": {
+			"id": {"type": "integer"},
+			"total": {"type": "number"},
+			"coupon": {"type": "string"},
+			"placed_at": {"type": "string", "format": "date-time"},
+			"customer": {"type": "object", "required": ["email"], "properties": {"email": {"type": ["null", "string"]}}},
+			"lines": {"type": "array", "items": {
+				"type": "object",
+				"required": ["qty", "sku"],
+				"properties": {"sku": {"type": "string"}, "qty": {"type": "integer"}, "gift": {"type": "boolean"}}
+			}}
+		}
+	}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Schema = %s", data)
+	}
+
+	fields := in.Fields()
+	paths := make([]string, len(fields))
+	for i, field := range fields {
+		paths[i] = field.Path
+	}
+	if want := []string{"coupon", "customer", "customer.email", "id", "lines", "lines[].gift", "lines[].qty", "lines[].sku", "placed_at", "total"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Field paths = %v, want %v", paths, want)
+	}
+	if coupon := fields[0]; !coupon.Optional || coupon.Present != 1 {
+		t.Errorf("coupon = %+v, want optional and present once", coupon)
+	}
+	if total := fields[9]; total.Optional || !reflect.DeepEqual(total.Types, []string{"number"}) {
+		t.Errorf("total = %+v, want required number", total)
+	}
+}
+
+func TestInfer_MaxProperties(t *testing.T) {
+	in := New()
+	object := make(map[string]int)
+	for i := range maxProperties + 10 {
+		object[fmt.Sprintf("k%d", i)] = i
+	}
+	data, _ := json.Marshal(object)
+	in.Add(data)
+	if len(in.Fields()) != maxProperties || in.Dropped() != 10 {
+		t.Errorf("Kept %d fields and dropped %d, want %d and 10", len(in.Fields()), in.Dropped(), maxProperties)
+	}
+}
@@ -0,0 +1,52 @@
+package sigv4
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testCreds = Credentials{
+	AccessKey: "AKIDEXAMPLE",
+	SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var testTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSign(t *testing.T) {
+	// "get-vanilla" from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	Sign(req, PayloadHash(nil), testCreds, "us-east-1", "service", testTime)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("unexpected Authorization header:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSignQuery(t *testing.T) {
+	// "get-vanilla-query-order-key-case" from the test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	Sign(req, PayloadHash(nil), testCreds, "us-east-1", "service", testTime)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("unexpected Authorization header:\n got %s\nwant %s", got, want)
+	}
+	if req.URL.RawQuery != "Param1=value1&Param2=value2" {
+		t.Errorf("expected canonical query to be sent, got %s", req.URL.RawQuery)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	got := canonicalQuery(url.Values{"prefix": {"a b/c~*"}, "list-type": {"2"}})
+	want := "list-type=2&prefix=a%20b%2Fc~%2A"
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
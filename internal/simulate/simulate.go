@@ -0,0 +1,362 @@
+// Package simulate generates event workloads for capacity tests and demo
+// environments: events of weighted types with varying payload sizes, spread
+// over streams and written to one or more tenants at a target rate.
+//
+// Arrivals are open-loop: events are generated on schedule whether or not
+// earlier requests have finished, so a slow server shows up as latency and
+// a falling event rate rather than being hidden by the generator waiting.
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Arrival distributions
+const (
+	Constant = "constant" // Evenly spaced events
+	Poisson  = "poisson"  // Independent events, exponentially distributed gaps
+	Bursty   = "bursty"   // Bursts of Options.Burst events with Poisson gaps
+)
+
+// Defaults for Options fields left at zero
+const (
+	DefaultRate        = 100
+	DefaultBurst       = 50
+	DefaultConcurrency = 8
+)
+
+// maxSamples bounds the latencies kept for percentiles
+const maxSamples = 10000
+
+// EventType is one type of the generated mix
+type EventType struct {
+	Name        string
+	Weight      int // Relative frequency
+	PayloadSize int // Mean size of the data in bytes; sizes vary by ±50%
+}
+
+// DefaultTypes is an order-processing mix
+var DefaultTypes = []EventType{
+	{Name: "OrderPlaced", Weight: 40, PayloadSize: 512},
+	{Name: "PaymentCaptured", Weight: 30, PayloadSize: 256},
+	{Name: "OrderShipped", Weight: 20, PayloadSize: 384},
+	{Name: "OrderCancelled", Weight: 5, PayloadSize: 256},
+	{Name: "PaymentFailed", Weight: 5, PayloadSize: 1024},
+}
+
+// Options describe a workload
+type Options struct {
+	Types       []EventType   // Event mix (default DefaultTypes)
+	Rate        float64       // Mean events per second per tenant
+	Arrival     string        // Constant, Poisson or Bursty (default Poisson)
+	Burst       int           // Events per burst with Bursty arrivals
+	BatchSize   int           // Events per request; 1 or less saves events one by one
+	Streams     int           // Stream IDs events are spread over (0 = events have no stream)
+	Duration    time.Duration // How long to generate events (0 = until Events or ctx ends)
+	Events      int           // Events per tenant (0 = until Duration or ctx ends)
+	Concurrency int           // Requests in flight per tenant
+	Seed        int64         // Seeds the generator, so workloads can be repeated
+}
+
+// Saver receives generated events; *client.HTTPClient and stores implement it
+type Saver interface {
+	Save(ctx context.Context, event *store.StoredEvent) error
+	SaveBatch(ctx context.Context, events []*store.StoredEvent) error
+}
+
+// Target is a tenant events are written to
+type Target struct {
+	Name  string
+	Saver Saver
+}
+
+// Latency summarizes request latencies in milliseconds
+type Latency struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// TenantReport counts what was written to one tenant
+type TenantReport struct {
+	Events   int64            `json:"events"`   // Events saved
+	Requests int64            `json:"requests"` // Requests sent, including failed ones
+	Errors   int64            `json:"errors"`   // Failed requests
+	Types    map[string]int64 `json:"types"`    // Events saved by type
+}
+
+// Report is the outcome of a run
+type Report struct {
+	Seconds         float64                  `json:"duration_seconds"`
+	Events          int64                    `json:"events"`
+	Requests        int64                    `json:"requests"`
+	Errors          int64                    `json:"errors"`
+	EventsPerSecond float64                  `json:"events_per_second"`
+	Latency         Latency                  `json:"latency_ms"`
+	Tenants         map[string]*TenantReport `json:"tenants"`
+	FirstError      string                   `json:"first_error,omitempty"`
+}
+
+// Run writes the workload to every target until it has sent opts.Events
+// events per target, opts.Duration has passed or ctx ends, then waits for
+// requests in flight and reports. Failed requests are counted, not retried.
+func Run(ctx context.Context, targets []Target, opts Options) (*Report, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+	if len(opts.Types) == 0 {
+		opts.Types = DefaultTypes
+	}
+	total := 0
+	for _, t := range opts.Types {
+		if t.Name == "" || t.Weight < 0 || t.PayloadSize < 0 {
+			return nil, fmt.Errorf("invalid event type %q", t.Name)
+		}
+		total += t.Weight
+	}
+	if total == 0 {
+		return nil, errors.New("event types have no weight")
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = DefaultRate
+	}
+	switch opts.Arrival {
+	case "":
+		opts.Arrival = Poisson
+	case Constant, Poisson, Bursty:
+	default:
+		return nil, fmt.Errorf("unknown arrival distribution %q", opts.Arrival)
+	}
+	if opts.Burst < 1 {
+		opts.Burst = DefaultBurst
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	// Generation stops at the deadline; requests in flight may finish
+	genCtx := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		genCtx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	rec := &recorder{rng: rand.New(rand.NewSource(opts.Seed)), report: &Report{Tenants: make(map[string]*TenantReport, len(targets))}}
+	for _, target := range targets {
+		rec.report.Tenants[target.Name] = &TenantReport{Types: make(map[string]int64)}
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		jobs := make(chan []*store.StoredEvent, opts.Concurrency)
+		for range opts.Concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for events := range jobs {
+					rec.send(ctx, target, events)
+				}
+			}()
+		}
+		gen := &generator{
+			opts:   opts,
+			total:  total,
+			tenant: target.Name,
+			rng:    rand.New(rand.NewSource(opts.Seed + int64(i))),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(jobs)
+			gen.run(genCtx, start, jobs)
+		}()
+	}
+	wg.Wait()
+
+	report := rec.report
+	report.Seconds = time.Since(start).Seconds()
+	if report.Seconds > 0 {
+		report.EventsPerSecond = float64(report.Events) / report.Seconds
+	}
+	report.Latency = percentiles(rec.samples)
+	return report, nil
+}
+
+// generator produces the events of one tenant on schedule
+type generator struct {
+	opts   Options
+	total  int // Sum of type weights
+	tenant string
+	rng    *rand.Rand
+	seq    int64 // Events generated so far
+}
+
+// run sends batches of events to jobs at the arrival times of the workload
+func (g *generator) run(ctx context.Context, start time.Time, jobs chan<- []*store.StoredEvent) {
+	next := start
+	var batch []*store.StoredEvent
+	for g.opts.Events == 0 || g.seq < int64(g.opts.Events) {
+		// Sleep until the next arrival unless behind schedule, which catches
+		// up at high rates where sleeps overshoot
+		if wait := time.Until(next); wait > time.Millisecond {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		n := 1
+		if g.opts.Arrival == Bursty {
+			n = g.opts.Burst
+		}
+		for ; n > 0 && (g.opts.Events == 0 || g.seq < int64(g.opts.Events)); n-- {
+			batch = append(batch, g.event())
+			if len(batch) >= g.opts.BatchSize {
+				select {
+				case jobs <- batch:
+				case <-ctx.Done():
+					return
+				}
+				batch = nil
+			}
+		}
+		next = next.Add(g.gap())
+	}
+	if len(batch) > 0 {
+		jobs <- batch
+	}
+}
+
+// gap returns the time to the next arrival
+func (g *generator) gap() time.Duration {
+	mean := float64(time.Second) / g.opts.Rate
+	switch g.opts.Arrival {
+	case Constant:
+		return time.Duration(mean)
+	case Bursty:
+		return time.Duration(g.rng.ExpFloat64() * mean * float64(g.opts.Burst))
+	default:
+		return time.Duration(g.rng.ExpFloat64() * mean)
+	}
+}
+
+// event returns the next event, of a type drawn by weight
+func (g *generator) event() *store.StoredEvent {
+	g.seq++
+	r := g.rng.Intn(g.total)
+	eventType := g.opts.Types[len(g.opts.Types)-1]
+	for _, t := range g.opts.Types {
+		if r < t.Weight {
+			eventType = t
+			break
+		}
+		r -= t.Weight
+	}
+
+	data := map[string]any{
+		"seq":    g.seq,
+		"tenant": g.tenant,
+		"amount": float64(g.rng.Intn(100000)) / 100,
+	}
+	event := &store.StoredEvent{Type: eventType.Name, Timestamp: time.Now().UTC()}
+	if g.opts.Streams > 0 {
+		event.StreamID = fmt.Sprintf("sim-%d", g.rng.Intn(g.opts.Streams))
+		data["stream"] = event.StreamID
+	}
+	if eventType.PayloadSize > 0 {
+		// Random letters pad the data to its size without compressing away
+		size := eventType.PayloadSize/2 + g.rng.Intn(eventType.PayloadSize+1)
+		base, _ := json.Marshal(data)
+		if pad := size - len(base) - len(`,"padding":""`); pad > 0 {
+			letters := make([]byte, pad)
+			for i := range letters {
+				letters[i] = 'a' + byte(g.rng.Intn(26))
+			}
+			data["padding"] = string(letters)
+		}
+	}
+	event.Data, _ = json.Marshal(data)
+	return event
+}
+
+// recorder collects the outcome of requests
+type recorder struct {
+	mu      sync.Mutex
+	rng     *rand.Rand // Picks latency samples
+	report  *Report
+	samples []time.Duration
+	seen    int64 // Latencies offered as samples
+}
+
+// send saves events to target and records the outcome
+func (r *recorder) send(ctx context.Context, target Target, events []*store.StoredEvent) {
+	start := time.Now()
+	var err error
+	if len(events) == 1 {
+		err = target.Saver.Save(ctx, events[0])
+	} else {
+		err = target.Saver.SaveBatch(ctx, events)
+	}
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant := r.report.Tenants[target.Name]
+	tenant.Requests++
+	r.report.Requests++
+	if err != nil {
+		tenant.Errors++
+		r.report.Errors++
+		if r.report.FirstError == "" {
+			r.report.FirstError = strings.TrimSpace(fmt.Sprintf("%s: %v", target.Name, err))
+		}
+		return
+	}
+	tenant.Events += int64(len(events))
+	r.report.Events += int64(len(events))
+	for _, event := range events {
+		tenant.Types[event.Type]++
+	}
+
+	// Reservoir sampling keeps a uniform sample of all latencies
+	r.seen++
+	if len(r.samples) < maxSamples {
+		r.samples = append(r.samples, latency)
+	} else if i := r.rng.Int63n(r.seen); i < maxSamples {
+		r.samples[i] = latency
+	}
+}
+
+// percentiles summarizes latency samples
+func percentiles(samples []time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+	slices.Sort(samples)
+	at := func(p float64) float64 {
+		d := samples[min(int(p*float64(len(samples))), len(samples)-1)]
+		return float64(d.Microseconds()) / 1000
+	}
+	return Latency{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: at(1)}
+}
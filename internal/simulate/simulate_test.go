@@ -0,0 +1,110 @@
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// failingSaver refuses every write
+type failingSaver struct{}
+
+func (failingSaver) Save(ctx context.Context, event *store.StoredEvent) error {
+	return errors.New("unavailable")
+}
+
+func (failingSaver) SaveBatch(ctx context.Context, events []*store.StoredEvent) error {
+	return errors.New("unavailable")
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := store.NewMemoryStore(), store.NewMemoryStore()
+	defer alice.Close()
+	defer bob.Close()
+
+	opts := Options{Rate: 100000, Arrival: Bursty, Burst: 7, BatchSize: 10, Streams: 5, Events: 203, Seed: 42}
+	report, err := Run(ctx, []Target{{"alice", alice}, {"bob", bob}}, opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Events != 406 || report.Errors != 0 || report.Requests != 42 {
+		t.Errorf("Expected 406 events in 42 requests, got %+v", report)
+	}
+	if report.Latency.Max <= 0 || report.EventsPerSecond <= 0 {
+		t.Errorf("Expected latencies and a rate, got %+v", report)
+	}
+
+	events, err := alice.Load(ctx, 1, 1000)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(events) != 203 {
+		t.Fatalf("Expected 203 events for alice, got %d", len(events))
+	}
+	known := make(map[string]EventType)
+	for _, eventType := range DefaultTypes {
+		known[eventType.Name] = eventType
+	}
+	for _, event := range events {
+		eventType, ok := known[event.Type]
+		if !ok || event.StreamID == "" {
+			t.Fatalf("Unexpected event %+v", event)
+		}
+		var data map[string]any
+		if err := json.Unmarshal(event.Data, &data); err != nil || data["tenant"] != "alice" {
+			t.Fatalf("Unexpected data %s", event.Data)
+		}
+		if size := len(event.Data); size > eventType.PayloadSize*3/2+8 {
+			t.Errorf("%s: %d bytes of data, more than 1.5 times %d", event.Type, size, eventType.PayloadSize)
+		}
+	}
+
+	// The same seed generates the same mix
+	again, err := Run(ctx, []Target{{"alice", store.NewMemoryStore()}, {"bob", store.NewMemoryStore()}}, opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for name, tenant := range report.Tenants {
+		for eventType, n := range tenant.Types {
+			if again.Tenants[name].Types[eventType] != n {
+				t.Errorf("%s: expected %d %s events again, got %d", name, n, eventType, again.Tenants[name].Types[eventType])
+			}
+		}
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	st := store.NewMemoryStore()
+	defer st.Close()
+
+	// Poisson arrivals at 200/s for 300ms are about 60 events
+	report, err := Run(context.Background(), []Target{{"default", st}}, Options{Rate: 200, Duration: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Events < 20 || report.Events > 150 || report.Seconds > 2 {
+		t.Errorf("Expected about 60 events in 300ms, got %d in %.2fs", report.Events, report.Seconds)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	report, err := Run(context.Background(), []Target{{"down", failingSaver{}}}, Options{Rate: 10000, Arrival: Constant, Events: 20})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Errors != 20 || report.Events != 0 || report.FirstError != "down: unavailable" {
+		t.Errorf("Expected 20 failed requests, got %+v", report)
+	}
+
+	if _, err := Run(context.Background(), []Target{{"down", failingSaver{}}}, Options{Arrival: "weekly"}); err == nil {
+		t.Error("Expected an unknown arrival distribution to be refused")
+	}
+	if _, err := Run(context.Background(), nil, Options{}); err == nil {
+		t.Error("Expected a run without targets to be refused")
+	}
+}
@@ -0,0 +1,275 @@
+// Package staging imports history in two phases. Events are first staged
+// in a temporary store, chunk by chunk, and every chunk is validated in
+// full before any of it is kept: each line must be a complete event, with
+// positions increasing and stream versions consecutive, and a chunk whose
+// CRC-32C does not match the one sent with it is rejected whole. Publishing
+// then checks the staged history against the target store and imports it
+// atomically, so an import that fails or is abandoned never leaves half a
+// history behind.
+//
+// Staged chunks use the NDJSON format of /events/export, whose checksum
+// trailer covers the same bytes as the staging checksum, so an export can
+// be uploaded in pieces and published against the trailer of the original.
+package staging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Errors wrapped by Stage and Publish
+var (
+	ErrInvalid  = errors.New("invalid import")                  // A chunk or the staged history is rejected; nothing was kept
+	ErrConflict = errors.New("import conflicts with the store") // The target store moved past the staged history
+	ErrClosed   = errors.New("staging area closed")
+)
+
+// maxLineBytes bounds a single NDJSON line
+const maxLineBytes = 16 << 20
+
+// publishBatch is the number of staged events passed to the target store at
+// a time during Publish
+const publishBatch = 1000
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Status summarizes what an Area holds
+type Status struct {
+	Events   int64  `json:"events"`
+	Bytes    int64  `json:"bytes"`
+	First    int64  `json:"first_position,omitempty"`
+	Last     int64  `json:"last_position,omitempty"`
+	Checksum string `json:"checksum"` // CRC-32C of every staged byte, as 8 hex digits
+}
+
+// Expect is what the staged history must match to be published. Zero
+// fields are not checked.
+type Expect struct {
+	Events   int64
+	Checksum string
+}
+
+// streamRange is the first and last staged version of a stream
+type streamRange struct {
+	first, last int64
+}
+
+// Area stages one import. It is safe for concurrent use; chunks are staged
+// one at a time.
+type Area struct {
+	dir string
+	st  *store.PebbleStore
+
+	mu      sync.Mutex
+	closed  bool
+	status  Status
+	crc     uint32
+	streams map[string]streamRange
+}
+
+// New creates an empty staging area in dir, which must not exist yet
+func New(dir string) (*Area, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("staging directory %s already exists", dir)
+	}
+	st, err := store.NewPebbleStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	a := &Area{dir: dir, st: st, streams: make(map[string]streamRange)}
+	a.status.Checksum = fmt.Sprintf("%08x", a.crc)
+	return a, nil
+}
+
+// Status returns what the area holds so far
+func (a *Area) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.status
+}
+
+// Stage validates an NDJSON chunk and keeps its events, or none of them if
+// any line is invalid. A non-empty checksum is the CRC-32C of the chunk as
+// 8 hex digits.
+func (a *Area) Stage(ctx context.Context, chunk []byte, checksum string) (Status, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return a.status, ErrClosed
+	}
+
+	if sum := fmt.Sprintf("%08x", crc32.Checksum(chunk, castagnoli)); checksum != "" && checksum != sum {
+		return a.status, fmt.Errorf("%w: chunk checksum is %s, expected %s", ErrInvalid, sum, checksum)
+	}
+
+	// Lines are checked against a copy of the state, adopted once the chunk
+	// is staged
+	status := a.status
+	streams := make(map[string]streamRange)
+	var events []*store.StoredEvent
+
+	scanner := bufio.NewScanner(bytes.NewReader(chunk))
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		event, err := decodeEvent(data)
+		if err == nil {
+			err = a.check(event, status.Last, streams)
+		}
+		if err != nil {
+			return a.status, fmt.Errorf("%w: line %d: %v", ErrInvalid, line, err)
+		}
+		if status.First == 0 {
+			status.First = event.Position
+		}
+		status.Last = event.Position
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return a.status, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if err := a.st.ImportEvents(ctx, events); err != nil {
+		return a.status, fmt.Errorf("stage events: %w", err)
+	}
+	a.crc = crc32.Update(a.crc, castagnoli, chunk)
+	status.Events += int64(len(events))
+	status.Bytes += int64(len(chunk))
+	status.Checksum = fmt.Sprintf("%08x", a.crc)
+	a.status = status
+	maps.Copy(a.streams, streams)
+	return a.status, nil
+}
+
+// decodeEvent parses one line into an event carrying all required fields
+func decodeEvent(data []byte) (*store.StoredEvent, error) {
+	var event store.StoredEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("not an event: %v", err)
+	}
+	switch {
+	case event.Position <= 0:
+		return nil, errors.New("missing position")
+	case event.Type == "":
+		return nil, fmt.Errorf("position %d: missing type", event.Position)
+	case len(event.Data) == 0:
+		return nil, fmt.Errorf("position %d: missing data", event.Position)
+	case event.Timestamp.IsZero():
+		return nil, fmt.Errorf("position %d: missing timestamp", event.Position)
+	}
+	return &event, nil
+}
+
+// check validates an event's position and stream version against the
+// staged history, recording its stream in streams
+func (a *Area) check(event *store.StoredEvent, last int64, streams map[string]streamRange) error {
+	if event.Position <= last {
+		return fmt.Errorf("position %d is not after %d", event.Position, last)
+	}
+	if event.StreamID == "" {
+		if event.StreamVersion != 0 {
+			return fmt.Errorf("position %d: stream_version without stream_id", event.Position)
+		}
+		return nil
+	}
+	if err := store.ValidateStreamID(event.StreamID); err != nil {
+		return fmt.Errorf("position %d: %v", event.Position, err)
+	}
+	r, ok := streams[event.StreamID]
+	if !ok {
+		r, ok = a.streams[event.StreamID]
+	}
+	switch {
+	case event.StreamVersion <= 0:
+		return fmt.Errorf("position %d: missing stream_version", event.Position)
+	case ok && event.StreamVersion != r.last+1:
+		return fmt.Errorf("position %d: stream %q at version %d, expected %d", event.Position, event.StreamID, event.StreamVersion, r.last+1)
+	case !ok:
+		r.first = event.StreamVersion
+	}
+	r.last = event.StreamVersion
+	streams[event.StreamID] = r
+	return nil
+}
+
+// Publish imports the staged history into dst atomically, after checking
+// it against expect and checking that it continues dst: its first position
+// must be past dst's head and each stream must pick up at dst's version.
+// A failed Publish leaves dst and the area as they were.
+func (a *Area) Publish(ctx context.Context, dst store.EventStore, expect Expect) (Status, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return a.status, ErrClosed
+	}
+
+	status := a.status
+	switch {
+	case status.Events == 0:
+		return status, fmt.Errorf("%w: nothing staged", ErrInvalid)
+	case expect.Events != 0 && expect.Events != status.Events:
+		return status, fmt.Errorf("%w: %d events staged, expected %d", ErrInvalid, status.Events, expect.Events)
+	case expect.Checksum != "" && expect.Checksum != status.Checksum:
+		return status, fmt.Errorf("%w: staged checksum is %s, expected %s", ErrInvalid, status.Checksum, expect.Checksum)
+	}
+
+	head, err := dst.GetPosition(ctx)
+	if err != nil {
+		return status, fmt.Errorf("get position: %w", err)
+	}
+	if status.First <= head {
+		return status, fmt.Errorf("%w: staged history starts at position %d, store is at %d", ErrConflict, status.First, head)
+	}
+	if len(a.streams) > 0 {
+		index, ok := store.As[store.StreamIndex](dst)
+		if !ok {
+			return status, errors.New("store does not index streams")
+		}
+		for id, r := range a.streams {
+			version, err := index.StreamVersion(ctx, id)
+			if err != nil {
+				return status, fmt.Errorf("get stream version: %w", err)
+			}
+			if r.first != version+1 {
+				return status, fmt.Errorf("%w: stream %q starts at version %d, store is at %d", ErrConflict, id, r.first, version)
+			}
+		}
+	}
+
+	err = store.ImportAll(ctx, dst, func(fn func([]*store.StoredEvent) error) error {
+		return a.st.LoadStream(ctx, 1, publishBatch, fn)
+	})
+	if err != nil {
+		return status, fmt.Errorf("publish: %w", err)
+	}
+	return status, nil
+}
+
+// Close removes the area and its staged events, once a Stage or Publish in
+// progress has finished
+func (a *Area) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	err := a.st.Close()
+	if rmErr := os.RemoveAll(a.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package staging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ndjson encodes events as staged chunks are
+func ndjson(t *testing.T, events ...*store.StoredEvent) []byte {
+	t.Helper()
+	var b strings.Builder
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+func event(position int64, streamID string, version int64) *store.StoredEvent {
+	return &store.StoredEvent{
+		Position:      position,
+		Type:          "Created",
+		Data:          json.RawMessage(`{}`),
+		Timestamp:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		StreamID:      streamID,
+		StreamVersion: version,
+	}
+}
+
+func newArea(t *testing.T) *Area {
+	t.Helper()
+	a, err := New(filepath.Join(t.TempDir(), "staging"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func TestStage(t *testing.T) {
+	ctx := context.Background()
+	a := newArea(t)
+
+	first := ndjson(t, event(1, "", 0), event(2, "orders/1", 1))
+	sum := fmt.Sprintf("%08x", crc32.Checksum(first, castagnoli))
+	if _, err := a.Stage(ctx, first, sum); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	// A chunk with one bad line is rejected whole
+	tests := map[string][]byte{
+		"position not after the staged ones": ndjson(t, event(3, "", 0), event(2, "", 0)),
+		"stream version skipped":             ndjson(t, event(3, "", 0), event(4, "orders/1", 3)),
+		"missing type":                       []byte(`{"position":3,"data":{},"timestamp":"2026-03-01T00:00:00Z"}`),
+		"not JSON":                           []byte("position 3\n"),
+	}
+	for name, chunk := range tests {
+		if _, err := a.Stage(ctx, chunk, ""); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+		}
+	}
+	second := ndjson(t, event(5, "orders/1", 2))
+	if _, err := a.Stage(ctx, second, "00000000"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for a wrong checksum, got %v", err)
+	}
+
+	status, err := a.Stage(ctx, second, "")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	whole := fmt.Sprintf("%08x", crc32.Checksum(append(first, second...), castagnoli))
+	if status.Events != 3 || status.First != 1 || status.Last != 5 || status.Checksum != whole {
+		t.Errorf("Status = %+v, want 3 events from 1 to 5 with checksum %s", status, whole)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	dst, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer dst.Close()
+	// The store already holds version 1 of the stream
+	if err := dst.Save(ctx, &store.StoredEvent{Type: "Created", Data: json.RawMessage(`{}`), StreamID: "orders/1"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	a := newArea(t)
+	chunk := ndjson(t, event(2, "orders/1", 2), event(3, "", 0), event(5, "orders/1", 3))
+	status, err := a.Stage(ctx, chunk, "")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	if _, err := a.Publish(ctx, dst, Expect{Events: 4}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for the wrong count, got %v", err)
+	}
+	if _, err := a.Publish(ctx, dst, Expect{Checksum: "00000000"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for the wrong checksum, got %v", err)
+	}
+
+	// A write after staging moves the store past the staged history
+	dst.Save(ctx, &store.StoredEvent{Type: "Late", Data: json.RawMessage(`{}`)})
+	if _, err := a.Publish(ctx, dst, Expect{}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if head, _ := dst.GetPosition(ctx); head != 2 {
+		t.Fatalf("Failed publish changed the head to %d", head)
+	}
+
+	// Staged again past the late write, the import continues the stream
+	a = newArea(t)
+	chunk = ndjson(t, event(3, "orders/1", 2), event(4, "", 0), event(6, "orders/1", 3))
+	if status, err = a.Stage(ctx, chunk, ""); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := a.Publish(ctx, dst, Expect{Events: 3, Checksum: status.Checksum}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	events, err := dst.Load(ctx, 1, -1)
+	if err != nil || len(events) != 5 || events[4].Position != 6 || events[4].StreamVersion != 3 {
+		t.Fatalf("Store holds %d events after publishing, %v", len(events), err)
+	}
+	if version, _ := dst.StreamVersion(ctx, "orders/1"); version != 3 {
+		t.Errorf("Stream version = %d, want 3", version)
+	}
+}
+
+func TestPublish_StreamConflict(t *testing.T) {
+	ctx := context.Background()
+	dst := store.NewMemoryStore()
+	a := newArea(t)
+	// The store has no events of the stream, so version 2 cannot follow
+	if _, err := a.Stage(ctx, ndjson(t, event(1, "orders/1", 2)), ""); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := a.Publish(ctx, dst, Expect{}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxAggregateGroups limits the groups one aggregation may return
+const MaxAggregateGroups = 10000
+
+// ErrTooManyGroups is returned by AggregateEvents when a query would return
+// more than MaxAggregateGroups groups
+var ErrTooManyGroups = errors.New("too many groups")
+
+// errAggregateDone stops the scan of an aggregation past its last position
+var errAggregateDone = errors.New("aggregate done")
+
+// AggregateQuery selects the events to summarize and how to group them
+type AggregateQuery struct {
+	From, To     int64     // Positions, inclusive; To -1 = up to the head
+	Since, Until time.Time // Timestamps in [Since, Until); zero = open
+	Filter       Filter
+	GroupByType  bool
+	Bucket       time.Duration // Group by timestamps truncated to multiples of Bucket since the Unix epoch (0 = no buckets)
+}
+
+// Aggregate summarizes the events of an AggregateQuery. Groups are sorted by
+// bucket, then type, and only present when the query groups.
+type Aggregate struct {
+	Count        int64            `json:"count"`
+	MinTimestamp time.Time        `json:"min_timestamp,omitzero"`
+	MaxTimestamp time.Time        `json:"max_timestamp,omitzero"`
+	Groups       []AggregateGroup `json:"groups,omitempty"`
+}
+
+// AggregateGroup summarizes the events of one type and/or bucket
+type AggregateGroup struct {
+	Type         string    `json:"type,omitempty"`
+	Bucket       time.Time `json:"bucket,omitzero"`
+	Count        int64     `json:"count"`
+	MinTimestamp time.Time `json:"min_timestamp"`
+	MaxTimestamp time.Time `json:"max_timestamp"`
+}
+
+// Aggregator is implemented by stores that compute aggregates in their
+// backend instead of returning every event. They may return
+// errors.ErrUnsupported for queries they cannot compute, such as type
+// patterns or metadata filters, which AggregateEvents then scans for.
+type Aggregator interface {
+	Aggregate(ctx context.Context, q AggregateQuery) (*Aggregate, error)
+}
+
+// AggregateEvents computes q on st, through its Aggregator if it has one
+// and otherwise by streaming the matching events
+func AggregateEvents(ctx context.Context, st EventStore, q AggregateQuery) (*Aggregate, error) {
+	if err := q.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	if q.Bucket < 0 {
+		return nil, fmt.Errorf("invalid bucket %v", q.Bucket)
+	}
+	from, to, ok := loadRange(q.From, q.To)
+	_, _, inTime := timeRange(q.Since, q.Until)
+	if !ok || !inTime {
+		return &Aggregate{}, nil
+	}
+	q.From, q.To = from, to
+
+	if aggregator, ok := As[Aggregator](st); ok {
+		result, err := aggregator.Aggregate(ctx, q)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return result, err
+		}
+	}
+
+	lo, hi, _ := timeRange(q.Since, q.Until)
+	groups := newAggregateGroups(q)
+	err := StreamFiltered(ctx, st, q.Filter, q.From, DefaultStreamBatchSize, func(events []*StoredEvent) error {
+		for _, event := range events {
+			if q.To != -1 && event.Position > q.To {
+				return errAggregateDone
+			}
+			if t := unixNano(event.Timestamp); t >= lo && t <= hi {
+				if err := groups.add(event.Type, t, 1, t, t); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errAggregateDone) {
+		return nil, err
+	}
+	return groups.result(), nil
+}
+
+// aggregateKey identifies a group; unused parts are left zero
+type aggregateKey struct {
+	typ    string
+	bucket int64 // Start in nanoseconds since the Unix epoch
+}
+
+// aggregateGroups collects groups keyed by type and bucket
+type aggregateGroups struct {
+	query  AggregateQuery
+	groups map[aggregateKey]*aggregateTotals
+}
+
+type aggregateTotals struct {
+	count    int64
+	min, max int64 // Nanoseconds since the Unix epoch
+}
+
+func newAggregateGroups(q AggregateQuery) *aggregateGroups {
+	return &aggregateGroups{query: q, groups: make(map[aggregateKey]*aggregateTotals)}
+}
+
+// add counts count events of typ, the first of them at t, stamped between
+// lo and hi
+func (g *aggregateGroups) add(typ string, t, count, lo, hi int64) error {
+	var key aggregateKey
+	if g.query.GroupByType {
+		key.typ = typ
+	}
+	if g.query.Bucket > 0 {
+		key.bucket = bucketStart(t, g.query.Bucket)
+	}
+	totals, ok := g.groups[key]
+	if !ok {
+		if len(g.groups) == MaxAggregateGroups {
+			return fmt.Errorf("%w: more than %d, use a larger bucket or a narrower range", ErrTooManyGroups, MaxAggregateGroups)
+		}
+		totals = &aggregateTotals{min: lo, max: hi}
+		g.groups[key] = totals
+	}
+	totals.count += count
+	totals.min = min(totals.min, lo)
+	totals.max = max(totals.max, hi)
+	return nil
+}
+
+// result returns the totals of all groups and the groups themselves
+func (g *aggregateGroups) result() *Aggregate {
+	result := &Aggregate{}
+	keys := slices.SortedFunc(maps.Keys(g.groups), func(a, b aggregateKey) int {
+		return cmp.Or(cmp.Compare(a.bucket, b.bucket), cmp.Compare(a.typ, b.typ))
+	})
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, key := range keys {
+		totals := g.groups[key]
+		result.Count += totals.count
+		lo, hi = min(lo, totals.min), max(hi, totals.max)
+		if !g.query.GroupByType && g.query.Bucket == 0 {
+			continue
+		}
+		group := AggregateGroup{
+			Type:         key.typ,
+			Count:        totals.count,
+			MinTimestamp: time.Unix(0, totals.min).UTC(),
+			MaxTimestamp: time.Unix(0, totals.max).UTC(),
+		}
+		if g.query.Bucket > 0 {
+			group.Bucket = time.Unix(0, key.bucket).UTC()
+		}
+		result.Groups = append(result.Groups, group)
+	}
+	if result.Count > 0 {
+		result.MinTimestamp = time.Unix(0, lo).UTC()
+		result.MaxTimestamp = time.Unix(0, hi).UTC()
+	}
+	return result
+}
+
+// bucketStart truncates t to a multiple of bucket, rounding down for times
+// before the epoch too; the earliest bucket starts at math.MinInt64
+func bucketStart(t int64, bucket time.Duration) int64 {
+	b := int64(bucket)
+	start := t - ((t%b)+b)%b
+	if start > t {
+		return math.MinInt64
+	}
+	return start
+}
+
+// Aggregate implements Aggregator with one GROUP BY query over the time_ns
+// index, for filters of exact types
+func (s *SQLiteStore) Aggregate(ctx context.Context, q AggregateQuery) (*Aggregate, error) {
+	if !q.Filter.Empty() && !q.Filter.ExactTypes() {
+		return nil, errors.ErrUnsupported
+	}
+	lo, hi, _ := timeRange(q.Since, q.Until)
+	to := q.To
+	if to == -1 {
+		to = math.MaxInt64
+	}
+
+	// Every group reports a sample timestamp to bucket it by
+	query := "SELECT type, MIN(time_ns), COUNT(*), MIN(time_ns), MAX(time_ns) FROM events WHERE position >= ? AND position <= ? AND time_ns >= ? AND time_ns <= ?"
+	args := []any{q.From, to, lo, hi}
+	if len(q.Filter.Types) > 0 {
+		query += " AND type IN (?" + strings.Repeat(", ?", len(q.Filter.Types)-1) + ")"
+		for _, typ := range q.Filter.Types {
+			args = append(args, typ)
+		}
+	}
+	var group []string
+	if q.GroupByType {
+		group = append(group, "type")
+	}
+	if q.Bucket > 0 {
+		b := strconv.FormatInt(int64(q.Bucket), 10)
+		group = append(group, "time_ns - ((time_ns % "+b+") + "+b+") % "+b)
+	}
+	if len(group) > 0 {
+		query += " GROUP BY " + strings.Join(group, ", ")
+	}
+	query += " LIMIT " + strconv.Itoa(MaxAggregateGroups+1)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result *Aggregate
+	err := s.busy.retryBusy(ctx, func() error {
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("aggregate events: %w", err)
+		}
+		defer rows.Close()
+
+		groups := newAggregateGroups(q)
+		for rows.Next() {
+			var typ sql.NullString
+			var sample, lo, hi sql.NullInt64
+			var count int64
+			if err := rows.Scan(&typ, &sample, &count, &lo, &hi); err != nil {
+				return fmt.Errorf("scan aggregate: %w", err)
+			}
+			if count == 0 {
+				continue // The single row of an ungrouped query without events
+			}
+			if err := groups.add(typ.String, sample.Int64, count, lo.Int64, hi.Int64); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate aggregate: %w", err)
+		}
+		result = groups.result()
+		return nil
+	})
+	return result, err
+}
+
+// Aggregate implements Aggregator with one GROUP BY query, for filters of
+// exact types. Buckets are computed in microseconds, the precision of
+// Postgres timestamps.
+func (s *PostgresStore) Aggregate(ctx context.Context, q AggregateQuery) (*Aggregate, error) {
+	if !q.Filter.Empty() && !q.Filter.ExactTypes() {
+		return nil, errors.ErrUnsupported
+	}
+	to := q.To
+	if to == -1 {
+		to = math.MaxInt64
+	}
+
+	conds := []string{"position >= $1", "position <= $2"}
+	args := []any{q.From, to}
+	if !q.Since.IsZero() {
+		args = append(args, q.Since)
+		conds = append(conds, "timestamp >= $"+strconv.Itoa(len(args)))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, q.Until)
+		conds = append(conds, "timestamp < $"+strconv.Itoa(len(args)))
+	}
+	if len(q.Filter.Types) > 0 {
+		args = append(args, q.Filter.Types)
+		conds = append(conds, "type = ANY($"+strconv.Itoa(len(args))+")")
+	}
+
+	micros := "(EXTRACT(EPOCH FROM timestamp) * 1000000)::BIGINT"
+	query := "SELECT MIN(type), MIN(" + micros + "), COUNT(*), MIN(" + micros + "), MAX(" + micros + ") FROM " + s.schema + ".events WHERE " + strings.Join(conds, " AND ")
+	var group []string
+	if q.GroupByType {
+		group = append(group, "type")
+	}
+	if q.Bucket > 0 {
+		b := strconv.FormatInt(q.Bucket.Microseconds(), 10)
+		if b == "0" {
+			return nil, errors.ErrUnsupported
+		}
+		group = append(group, "FLOOR("+micros+" / "+b+"::NUMERIC)")
+	}
+	if len(group) > 0 {
+		query += " GROUP BY " + strings.Join(group, ", ")
+	}
+	query += " LIMIT " + strconv.Itoa(MaxAggregateGroups+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate events: %w", err)
+	}
+	defer rows.Close()
+
+	groups := newAggregateGroups(q)
+	for rows.Next() {
+		var typ sql.NullString
+		var sample, lo, hi sql.NullInt64
+		var count int64
+		if err := rows.Scan(&typ, &sample, &count, &lo, &hi); err != nil {
+			return nil, fmt.Errorf("scan aggregate: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		if err := groups.add(typ.String, sample.Int64*1000, count, lo.Int64*1000, hi.Int64*1000); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate aggregate: %w", err)
+	}
+	return groups.result(), nil
+}
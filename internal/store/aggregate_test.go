@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregateEvents(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/aggregate.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/aggregate")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	events := []struct {
+		typ    string
+		offset time.Duration
+	}{
+		{"OrderPlaced", 0},
+		{"OrderPlaced", 10 * time.Minute},
+		{"OrderShipped", 50 * time.Minute},
+		{"OrderPlaced", 70 * time.Minute},
+		{"UserCreated", 2*time.Hour + 5*time.Minute},
+		{"OrderShipped", -30 * time.Minute},
+	}
+
+	// summary renders an aggregate as "count min-max [groups]" in minutes
+	// relative to base
+	summary := func(a *Aggregate) string {
+		minutes := func(t time.Time) int { return int(t.Sub(base).Minutes()) }
+		var groups []string
+		for _, g := range a.Groups {
+			bucket := ""
+			if !g.Bucket.IsZero() {
+				bucket = fmt.Sprintf("@%d", minutes(g.Bucket))
+			}
+			groups = append(groups, fmt.Sprintf("%s%s:%d(%d-%d)", g.Type, bucket, g.Count, minutes(g.MinTimestamp), minutes(g.MaxTimestamp)))
+		}
+		if a.Count == 0 {
+			return "0 " + fmt.Sprint(groups)
+		}
+		return fmt.Sprintf("%d %d-%d %v", a.Count, minutes(a.MinTimestamp), minutes(a.MaxTimestamp), groups)
+	}
+
+	tests := []struct {
+		name string
+		q    AggregateQuery
+		want string
+	}{
+		{"all", AggregateQuery{From: 1, To: -1}, "6 -30-125 []"},
+		{"by type", AggregateQuery{From: 1, To: -1, GroupByType: true},
+			"6 -30-125 [OrderPlaced:3(0-70) OrderShipped:2(-30-50) UserCreated:1(125-125)]"},
+		{"hourly", AggregateQuery{From: 1, To: -1, Bucket: time.Hour},
+			"6 -30-125 [@-60:1(-30--30) @0:3(0-50) @60:1(70-70) @120:1(125-125)]"},
+		{"hourly by type", AggregateQuery{From: 0, To: 4, Bucket: time.Hour, GroupByType: true},
+			"4 0-70 [OrderPlaced@0:2(0-10) OrderShipped@0:1(50-50) OrderPlaced@60:1(70-70)]"},
+		{"exact types", AggregateQuery{From: 1, To: -1, Filter: Filter{Types: []string{"OrderShipped"}}}, "2 -30-50 []"},
+		{"pattern", AggregateQuery{From: 1, To: -1, Filter: Filter{Types: []string{"Order*"}}, GroupByType: true},
+			"5 -30-70 [OrderPlaced:3(0-70) OrderShipped:2(-30-50)]"},
+		{"time range", AggregateQuery{From: 1, To: -1, Since: base, Until: base.Add(time.Hour)}, "3 0-50 []"},
+		{"empty", AggregateQuery{From: 1, To: -1, Filter: Filter{Types: []string{"Missing"}}, GroupByType: true}, "0 []"},
+		{"empty range", AggregateQuery{From: 5, To: 4}, "0 []"},
+	}
+	for _, st := range []EventStore{sqliteStore, pebbleStore, NewMemoryStore()} {
+		for _, e := range events {
+			event := &StoredEvent{Type: e.typ, Data: json.RawMessage(`{}`), Timestamp: base.Add(e.offset)}
+			if err := st.Save(ctx, event); err != nil {
+				t.Fatalf("%T: save failed: %v", st, err)
+			}
+		}
+		for _, tt := range tests {
+			result, err := AggregateEvents(ctx, st, tt.q)
+			if err != nil {
+				t.Fatalf("%T %s: AggregateEvents failed: %v", st, tt.name, err)
+			}
+			if got := summary(result); got != tt.want {
+				t.Errorf("%T %s:\n got %s\nwant %s", st, tt.name, got, tt.want)
+			}
+		}
+	}
+
+	_, err = AggregateEvents(ctx, NewMemoryStore(), AggregateQuery{Bucket: -time.Second})
+	if err == nil || !strings.Contains(err.Error(), "bucket") {
+		t.Errorf("Expected a negative bucket to fail, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// testContiguousBatches saves batches from concurrent writers and checks
+// that every batch got a range of its own
+func testContiguousBatches(t *testing.T, st EventStore) {
+	t.Helper()
+	ctx := context.Background()
+	const writers, batches, size = 8, 10, 100
+
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range batches {
+				events := make([]*StoredEvent, size)
+				for i := range events {
+					events[i] = &StoredEvent{Type: "Concurrent", Data: json.RawMessage(`{}`)}
+				}
+				if err := st.SaveBatch(ctx, events); err != nil {
+					t.Errorf("SaveBatch failed: %v", err)
+					return
+				}
+				for i, event := range events {
+					if event.Position != events[0].Position+int64(i) {
+						t.Errorf("Batch from %d interleaved: position %d at index %d", events[0].Position, event.Position, i)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if head, _ := st.GetPosition(ctx); head != writers*batches*size {
+		t.Errorf("Head = %d, want %d", head, writers*batches*size)
+	}
+}
+
+func TestSaveBatch_Contiguous(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]func(t *testing.T) EventStore{
+		"sqlite": func(t *testing.T) EventStore {
+			st, err := NewSQLiteStore(dir + "/batch.db")
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			return st
+		},
+		"pebble": func(t *testing.T) EventStore {
+			st, err := NewPebbleStore(dir + "/batch")
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			return st
+		},
+		"memory": func(t *testing.T) EventStore { return NewMemoryStore() },
+		"postgres": func(t *testing.T) EventStore {
+			st, _, _ := newTestPostgresStore(t)
+			return st
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			defer st.Close()
+			testContiguousBatches(t, st)
+		})
+	}
+}
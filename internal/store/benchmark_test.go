@@ -12,6 +12,7 @@ import (
 func BenchmarkSave(b *testing.B) {
 	dbPath := "bench_save.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -41,6 +42,7 @@ func BenchmarkSaveBatch(b *testing.B) {
 		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
 			dbPath := fmt.Sprintf("bench_batch_%d.db", size)
 			defer os.Remove(dbPath)
+			defer os.Remove(dbPath + ".lock")
 
 			store, err := NewSQLiteStore(dbPath)
 			if err != nil {
@@ -73,6 +75,7 @@ func BenchmarkSaveBatch(b *testing.B) {
 func BenchmarkLoad(b *testing.B) {
 	dbPath := "bench_load.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -92,6 +95,7 @@ func BenchmarkLoad(b *testing.B) {
 		store.Save(ctx, event)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, err := store.Load(ctx, 1, -1)
@@ -104,6 +108,7 @@ func BenchmarkLoad(b *testing.B) {
 func BenchmarkLoadStream(b *testing.B) {
 	dbPath := "bench_stream.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -123,6 +128,7 @@ func BenchmarkLoadStream(b *testing.B) {
 		store.Save(ctx, event)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		err := store.LoadStream(ctx, 1, 1000, func(batch []*StoredEvent) error {
@@ -137,6 +143,7 @@ func BenchmarkLoadStream(b *testing.B) {
 func BenchmarkConcurrentSave(b *testing.B) {
 	dbPath := "bench_concurrent.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -164,6 +171,7 @@ func BenchmarkConcurrentSave(b *testing.B) {
 func BenchmarkGetPosition(b *testing.B) {
 	dbPath := "bench_position.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// SQLite primary result codes for lock contention; extended codes such as
+// SQLITE_BUSY_SNAPSHOT share the low byte
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// Retry policy for busy/locked errors. busy_timeout already waits inside
+// SQLite; these retries cover checkpoint pauses and snapshot conflicts that
+// return SQLITE_BUSY immediately.
+const (
+	busyMaxRetries     = 5
+	busyInitialBackoff = 10 * time.Millisecond
+	busyMaxBackoff     = 500 * time.Millisecond
+)
+
+// BusyStats reports how often SQLite lock contention was retried
+type BusyStats struct {
+	Retries   int64 `json:"retries"`   // Operations retried after SQLITE_BUSY/SQLITE_LOCKED
+	Exhausted int64 `json:"exhausted"` // Operations that still failed after all retries
+}
+
+type busyCounters struct {
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// isBusy reports whether err is a SQLite busy or locked error
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// retryBusy runs op, retrying with bounded exponential backoff while it fails
+// with a busy or locked error
+func (c *busyCounters) retryBusy(ctx context.Context, op func() error) error {
+	backoff := busyInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !isBusy(err) {
+			return err
+		}
+		if attempt == busyMaxRetries {
+			c.exhausted.Add(1)
+			return err
+		}
+
+		c.retries.Add(1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, busyMaxBackoff)
+	}
+}
+
+// BusyStats returns lock contention retry counters
+func (s *SQLiteStore) BusyStats() BusyStats {
+	return BusyStats{
+		Retries:   s.busy.retries.Load(),
+		Exhausted: s.busy.exhausted.Load(),
+	}
+}
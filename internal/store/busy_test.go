@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// busyError produces a genuine SQLITE_BUSY error by writing to a database
+// another connection holds an exclusive lock on
+func busyError(t *testing.T) error {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "busy.db")
+	holder, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer holder.Close()
+	holder.SetMaxOpenConns(1)
+
+	if _, err := holder.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	tx, err := holder.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	writer, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer writer.Close()
+
+	_, err = writer.Exec("INSERT INTO t VALUES (2)")
+	if err == nil {
+		t.Fatal("Expected write to fail while the database is locked")
+	}
+	return err
+}
+
+func TestIsBusy(t *testing.T) {
+	err := busyError(t)
+	if !isBusy(err) {
+		t.Fatalf("Expected %v to be detected as busy", err)
+	}
+	if isBusy(errors.New("database is busy")) {
+		t.Error("Plain errors must not be treated as busy")
+	}
+	if isBusy(nil) {
+		t.Error("nil must not be treated as busy")
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	busyErr := busyError(t)
+	ctx := context.Background()
+
+	t.Run("recovers", func(t *testing.T) {
+		var c busyCounters
+		calls := 0
+		err := c.retryBusy(ctx, func() error {
+			calls++
+			if calls < 3 {
+				return busyErr
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		if calls != 3 || c.retries.Load() != 2 || c.exhausted.Load() != 0 {
+			t.Errorf("Unexpected calls=%d retries=%d exhausted=%d", calls, c.retries.Load(), c.exhausted.Load())
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		var c busyCounters
+		calls := 0
+		err := c.retryBusy(ctx, func() error {
+			calls++
+			return busyErr
+		})
+		if !errors.Is(err, busyErr) {
+			t.Fatalf("Expected busy error, got %v", err)
+		}
+		if calls != busyMaxRetries+1 || c.exhausted.Load() != 1 {
+			t.Errorf("Unexpected calls=%d exhausted=%d", calls, c.exhausted.Load())
+		}
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		var c busyCounters
+		calls := 0
+		other := errors.New("boom")
+		if err := c.retryBusy(ctx, func() error { calls++; return other }); err != other {
+			t.Fatalf("Expected %v, got %v", other, err)
+		}
+		if calls != 1 || c.retries.Load() != 0 {
+			t.Errorf("Unexpected calls=%d retries=%d", calls, c.retries.Load())
+		}
+	})
+
+	t.Run("stops on cancellation", func(t *testing.T) {
+		var c busyCounters
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		calls := 0
+		c.retryBusy(cctx, func() error { calls++; return busyErr })
+		if calls != 1 {
+			t.Errorf("Expected a single attempt after cancellation, got %d", calls)
+		}
+	})
+}
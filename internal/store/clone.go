@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Cloner is implemented by stores that can copy themselves, events and
+// subscription positions, into an independent store at dir, which must not
+// exist yet. Writes to either store are not seen by the other.
+type Cloner interface {
+	Clone(ctx context.Context, dir string) (EventStore, error)
+}
+
+// Clone copies src into a new store at dir: with src's own Clone if it has
+// one, otherwise by importing its events into a Pebble store, which keeps no
+// subscription positions.
+func Clone(ctx context.Context, src EventStore, dir string) (EventStore, error) {
+	if cloner, ok := As[Cloner](src); ok {
+		return cloner.Clone(ctx, dir)
+	}
+
+	dst, err := NewPebbleStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = src.LoadStream(ctx, 1, 0, func(events []*StoredEvent) error {
+		return dst.ImportEvents(ctx, events)
+	})
+	if err != nil {
+		dst.Close()
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("copy events: %w", err)
+	}
+	return dst, nil
+}
+
+// Clone implements Cloner. The checkpoint hard-links Pebble's immutable
+// sstables, so the clone shares their disk space until either store
+// compacts them away.
+func (s *PebbleStore) Clone(ctx context.Context, dir string) (EventStore, error) {
+	if err := s.db.Checkpoint(dir, pebble.WithFlushedWAL()); err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+	clone, err := NewPebbleStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return clone, nil
+}
+
+// Clone implements Cloner with VACUUM INTO, which copies the database
+// without blocking writers; the clone is at dir/events.db
+func (s *SQLiteStore) Clone(ctx context.Context, dir string) (EventStore, error) {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "events.db")
+	err := s.busy.retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path)
+		return err
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("vacuum into clone: %w", err)
+	}
+
+	clone, err := NewSQLiteStore(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s.mu.RLock()
+	clone.SetStrictPositions(s.strict)
+	s.mu.RUnlock()
+	return clone, nil
+}
+
+// Clone implements Cloner with a copy in memory; dir is not used
+func (s *MemoryStore) Clone(ctx context.Context, dir string) (EventStore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, errMemoryClosed
+	}
+	clone := &MemoryStore{
+		events:        make([]*StoredEvent, len(s.events)),
+		position:      s.position,
+		streams:       make(map[string][]int, len(s.streams)),
+		subscriptions: maps.Clone(s.subscriptions),
+		rollups:       maps.Clone(s.rollups),
+	}
+	for i, event := range s.events {
+		clone.events[i] = copyEvent(event)
+	}
+	for streamID, indexes := range s.streams {
+		clone.streams[streamID] = slices.Clone(indexes)
+	}
+	return clone, nil
+}
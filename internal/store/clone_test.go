@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// plainStore hides the Cloner of a store, so Clone falls back to copying
+type plainStore struct{ EventStore }
+
+func TestClone(t *testing.T) {
+	ctx := context.Background()
+	sqliteStore, err := NewSQLiteStore(filepath.Join(t.TempDir(), "src.db"))
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+	pebbleStore, err := NewPebbleStore(filepath.Join(t.TempDir(), "src"))
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	sources := map[string]EventStore{
+		"sqlite":   sqliteStore,
+		"pebble":   pebbleStore,
+		"memory":   NewMemoryStore(),
+		"fallback": plainStore{NewMemoryStore()},
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			for i := range 3 {
+				event := &StoredEvent{Type: "Placed", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: "order-1", StreamVersion: int64(i + 1)}
+				if err := src.Save(ctx, event); err != nil {
+					t.Fatalf("Save failed: %v", err)
+				}
+			}
+			src.SaveSubscriptionPosition(ctx, "projection", 2)
+
+			clone, err := Clone(ctx, src, filepath.Join(t.TempDir(), "clone"))
+			if err != nil {
+				t.Fatalf("Clone failed: %v", err)
+			}
+			defer clone.Close()
+
+			events, err := clone.Load(ctx, 1, -1)
+			if err != nil || len(events) != 3 || events[2].StreamVersion != 3 {
+				t.Fatalf("Expected the 3 events, got %d (%v)", len(events), err)
+			}
+			if position, _ := clone.LoadSubscriptionPosition(ctx, "projection"); name != "fallback" && position != 2 {
+				t.Errorf("Expected subscription position 2, got %d", position)
+			}
+
+			// The stores diverge from here
+			next := &StoredEvent{Type: "Shipped", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: "order-1", StreamVersion: 4}
+			if err := clone.Save(ctx, next); err != nil {
+				t.Fatalf("Save to clone failed: %v", err)
+			}
+			if next.Position != 4 {
+				t.Errorf("Expected the clone to continue at position 4, got %d", next.Position)
+			}
+			if head, _ := src.GetPosition(ctx); head != 3 {
+				t.Errorf("Expected the source to stay at 3, got %d", head)
+			}
+			if err := src.Save(ctx, &StoredEvent{Type: "Cancelled", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+				t.Fatalf("Save to source failed: %v", err)
+			}
+			if events, _ := clone.Load(ctx, 4, 4); len(events) != 1 || events[0].Type != "Shipped" {
+				t.Errorf("Expected the clone's own event at 4, got %v", events)
+			}
+		})
+	}
+}
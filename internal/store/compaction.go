@@ -0,0 +1,181 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCompactionRunning is returned when a manual compaction is already in progress
+var ErrCompactionRunning = errors.New("compaction already running")
+
+// compactionRanges is the number of event key ranges a manual compaction is
+// split into; progress advances once per range
+const compactionRanges = 16
+
+// Compactor is implemented by stores that support operator-triggered
+// compaction, so heavy compactions can be scheduled during off-hours
+type Compactor interface {
+	// StartCompaction compacts the whole keyspace in the background and
+	// returns a channel closed when it finishes
+	StartCompaction() (<-chan struct{}, error)
+	CompactionStatus() CompactionStatus
+}
+
+// CompactionStatus combines LSM compaction metrics with the progress of the
+// current or last manual compaction
+type CompactionStatus struct {
+	// Background compactions
+	Count           int64  `json:"count"`
+	EstimatedDebt   uint64 `json:"estimated_debt_bytes"` // Bytes to compact before the LSM is in shape
+	InProgressBytes int64  `json:"in_progress_bytes"`
+	NumInProgress   int64  `json:"num_in_progress"`
+	L0Files         int64  `json:"l0_files"`
+	DiskSpaceUsage  uint64 `json:"disk_space_usage_bytes"`
+
+	Manual ManualCompaction `json:"manual"`
+}
+
+// ManualCompaction reports the progress of an operator-triggered compaction
+type ManualCompaction struct {
+	Running     bool      `json:"running"`
+	StartedAt   time.Time `json:"started_at,omitzero"`
+	FinishedAt  time.Time `json:"finished_at,omitzero"`
+	RangesTotal int       `json:"ranges_total"`
+	RangesDone  int       `json:"ranges_done"`
+	Progress    float64   `json:"progress"` // 0..1
+	Error       string    `json:"error,omitempty"`
+}
+
+type compactionJob struct {
+	mu     sync.Mutex
+	state  ManualCompaction
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// StartCompaction implements Compactor. The event keyspace is compacted in
+// position ranges so progress can be reported, followed by the remaining
+// subscription and metadata keys.
+func (s *PebbleStore) StartCompaction() (<-chan struct{}, error) {
+	job := &s.compaction
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if job.closed {
+		return nil, fmt.Errorf("store closed")
+	}
+	if job.state.Running {
+		return nil, ErrCompactionRunning
+	}
+
+	ranges := compactionKeyRanges(s.position.Load())
+	started := time.Now()
+	job.state = ManualCompaction{
+		Running:     true,
+		StartedAt:   started,
+		RangesTotal: len(ranges),
+	}
+	done := make(chan struct{})
+
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+		defer close(done)
+
+		err := s.compactRanges(ranges)
+
+		job.mu.Lock()
+		job.state.Running = false
+		job.state.FinishedAt = time.Now()
+		if err != nil {
+			job.state.Error = err.Error()
+		}
+		job.mu.Unlock()
+
+		if err != nil {
+			slog.Error("Manual compaction failed", "error", err)
+			return
+		}
+		slog.Info("Manual compaction finished", "duration_ms", time.Since(started).Milliseconds())
+	}()
+
+	return done, nil
+}
+
+func (s *PebbleStore) compactRanges(ranges [][2][]byte) error {
+	job := &s.compaction
+	for i, r := range ranges {
+		job.mu.Lock()
+		closed := job.closed
+		job.mu.Unlock()
+		if closed {
+			return fmt.Errorf("store closed")
+		}
+
+		if err := s.db.Compact(r[0], r[1], true); err != nil {
+			return fmt.Errorf("compact range %d: %w", i, err)
+		}
+
+		job.mu.Lock()
+		job.state.RangesDone = i + 1
+		job.mu.Unlock()
+	}
+	return nil
+}
+
+// compactionKeyRanges splits the keyspace into [start, end) ranges: event
+// keys up to lastPosition in equal slices, then everything after the events
+func compactionKeyRanges(lastPosition int64) [][2][]byte {
+	var ranges [][2][]byte
+
+	n := int64(compactionRanges)
+	if lastPosition < n {
+		n = 1
+	}
+	step := (lastPosition + n) / n
+	start := []byte{eventPrefix}
+	for i := int64(1); i < n; i++ {
+		end := eventKey(i * step)
+		ranges = append(ranges, [2][]byte{start, end})
+		start = end
+	}
+	ranges = append(ranges, [2][]byte{start, {eventPrefix + 1}})
+
+	// Subscriptions and metadata ("meta:" keys sort after both prefixes)
+	ranges = append(ranges, [2][]byte{{eventPrefix + 1}, {0xff}})
+	return ranges
+}
+
+// CompactionStatus implements Compactor
+func (s *PebbleStore) CompactionStatus() CompactionStatus {
+	m := s.db.Metrics()
+
+	status := CompactionStatus{
+		Count:           m.Compact.Count,
+		EstimatedDebt:   m.Compact.EstimatedDebt,
+		InProgressBytes: m.Compact.InProgressBytes,
+		NumInProgress:   m.Compact.NumInProgress,
+		L0Files:         m.Levels[0].NumFiles,
+		DiskSpaceUsage:  m.DiskSpaceUsage(),
+	}
+
+	s.compaction.mu.Lock()
+	status.Manual = s.compaction.state
+	s.compaction.mu.Unlock()
+
+	if status.Manual.RangesTotal > 0 {
+		status.Manual.Progress = float64(status.Manual.RangesDone) / float64(status.Manual.RangesTotal)
+	}
+	return status
+}
+
+// stopCompaction stops a running manual compaction after its current range
+func (s *PebbleStore) stopCompaction() {
+	s.compaction.mu.Lock()
+	s.compaction.closed = true
+	s.compaction.mu.Unlock()
+	s.compaction.wg.Wait()
+}
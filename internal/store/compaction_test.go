@@ -0,0 +1,68 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestCompactionKeyRanges(t *testing.T) {
+	for _, last := range []int64{0, 5, 1000, 123457} {
+		ranges := compactionKeyRanges(last)
+
+		if !bytes.Equal(ranges[0][0], []byte{eventPrefix}) {
+			t.Errorf("last=%d: ranges must start at the event prefix", last)
+		}
+		for i := 1; i < len(ranges); i++ {
+			if !bytes.Equal(ranges[i-1][1], ranges[i][0]) {
+				t.Errorf("last=%d: gap between range %d and %d", last, i-1, i)
+			}
+		}
+		if end := ranges[len(ranges)-1][1]; !bytes.Equal(end, []byte{0xff}) {
+			t.Errorf("last=%d: ranges must cover metadata keys, end=%x", last, end)
+		}
+	}
+}
+
+func TestPebbleStore_Compaction(t *testing.T) {
+	store, err := NewPebbleStore(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	events := make([]*StoredEvent, 500)
+	for i := range events {
+		events[i] = &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{"n":1}`)}
+	}
+	if err := store.SaveBatch(ctx, events); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	done, err := store.StartCompaction()
+	if err != nil {
+		t.Fatalf("StartCompaction failed: %v", err)
+	}
+	<-done
+
+	manual := store.CompactionStatus().Manual
+	if manual.Running || manual.Error != "" {
+		t.Fatalf("unexpected compaction state: %+v", manual)
+	}
+	if manual.RangesDone != manual.RangesTotal || manual.Progress != 1 {
+		t.Errorf("expected full progress, got %d/%d (%v)", manual.RangesDone, manual.RangesTotal, manual.Progress)
+	}
+
+	loaded, err := store.Load(ctx, 1, int64(len(events)))
+	if err != nil || len(loaded) != len(events) {
+		t.Fatalf("expected %d events after compaction, got %d (%v)", len(events), len(loaded), err)
+	}
+
+	// A new compaction can be started once the previous one finished
+	if done, err = store.StartCompaction(); err != nil {
+		t.Fatalf("second StartCompaction failed: %v", err)
+	}
+	<-done
+}
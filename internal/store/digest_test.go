@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDigestRanges(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/digest.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/digest")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for i := range 10 {
+		// Formatting differences in payloads don't affect digests
+		if err := sqliteStore.Save(ctx, &StoredEvent{Type: "Event", Data: json.RawMessage(`{"n": 1}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		typ := "Event"
+		if i == 6 {
+			typ = "Different" // position 7
+		}
+		if err := pebbleStore.Save(ctx, &StoredEvent{Type: typ, Data: json.RawMessage(`{"n":1}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	a, err := DigestRanges(ctx, sqliteStore, 1, 10, 3)
+	if err != nil {
+		t.Fatalf("DigestRanges failed: %v", err)
+	}
+	b, err := DigestRanges(ctx, pebbleStore, 1, 10, 3)
+	if err != nil {
+		t.Fatalf("DigestRanges failed: %v", err)
+	}
+
+	want := []RangeDigest{{From: 1, To: 4, Count: 4}, {From: 5, To: 8, Count: 4}, {From: 9, To: 10, Count: 2}}
+	if len(a) != len(want) {
+		t.Fatalf("expected %d ranges, got %+v", len(want), a)
+	}
+	for i, w := range want {
+		if a[i].From != w.From || a[i].To != w.To || a[i].Count != w.Count {
+			t.Errorf("range %d: expected %+v, got %+v", i, w, a[i])
+		}
+	}
+	if a[0].Hash != b[0].Hash || a[2].Hash != b[2].Hash {
+		t.Error("expected equal events to have equal digests across backends")
+	}
+	if a[1].Hash == b[1].Hash {
+		t.Error("expected the range containing position 7 to differ")
+	}
+
+	// Ranges past the head are empty but still digested
+	if d, err := DigestRanges(ctx, sqliteStore, 11, 20, 1); err != nil || d[0].Count != 0 {
+		t.Errorf("expected an empty range, got %+v, %v", d, err)
+	}
+	if _, err := DigestRanges(ctx, sqliteStore, 5, 4, 1); err == nil {
+		t.Error("expected error for an inverted range")
+	}
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// appendEventJSON appends the JSON encoding of an event to dst. The output
+// matches json.Marshal(event), but the data payload is copied verbatim since
+// it is already JSON, avoiding a decode/re-encode round trip per event.
+func appendEventJSON(dst []byte, e *StoredEvent) ([]byte, error) {
+	dst = append(dst, `{"position":`...)
+	dst = strconv.AppendInt(dst, e.Position, 10)
+	dst = append(dst, `,"type":`...)
+	dst = appendJSONString(dst, e.Type)
+	dst = append(dst, `,"data":`...)
+	if len(e.Data) == 0 {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, e.Data...)
+	}
+	dst = append(dst, `,"timestamp":"`...)
+	dst = e.Timestamp.AppendFormat(dst, time.RFC3339Nano)
+	dst = append(dst, '"')
+	if len(e.Metadata) > 0 {
+		metadata, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, `,"metadata":`...)
+		dst = append(dst, metadata...)
+	}
+	if e.StreamID != "" {
+		dst = append(dst, `,"stream_id":`...)
+		dst = appendJSONString(dst, e.StreamID)
+		if e.StreamVersion != 0 {
+			dst = append(dst, `,"stream_version":`...)
+			dst = strconv.AppendInt(dst, e.StreamVersion, 10)
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string using the same escaping as
+// encoding/json (including HTML-safe escaping of <, > and &)
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAppendEventJSON_MatchesMarshal(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	events := []*StoredEvent{
+		{Position: 1, Type: "Simple", Data: json.RawMessage(`{"a":1}`), Timestamp: ts},
+		{Position: 2, Type: "Quote\"Back\\slash<>&\n\t\x01", Data: json.RawMessage(`[1,2]`), Timestamp: ts},
+		{Position: 3, Type: "Unicode ü \u2028 \u2029", Data: json.RawMessage(`"x"`), Timestamp: ts.In(time.FixedZone("X", 3600))},
+		{Position: 4, Type: "Metadata", Data: json.RawMessage(`null`), Metadata: map[string]string{"b": "2", "a": "1"}},
+		{Position: 5, Type: "NoData"},
+		{Position: 6, Type: "Stream", Data: json.RawMessage(`{}`), StreamID: "order/<42>", StreamVersion: 3},
+	}
+
+	for _, e := range events {
+		want, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		got, err := appendEventJSON(nil, e)
+		if err != nil {
+			t.Fatalf("appendEventJSON: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("encoding mismatch\n got: %s\nwant: %s", got, want)
+		}
+	}
+}
+
+func TestLoadStreamRaw(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/pebble")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	stores := map[string]interface {
+		EventStore
+		RawStreamer
+	}{
+		"sqlite": sqliteStore,
+		"pebble": pebbleStore,
+	}
+
+	ctx := context.Background()
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 25; i++ {
+				event := &StoredEvent{
+					Type:      "TestEvent",
+					Data:      json.RawMessage(fmt.Sprintf(`{"index":%d}`, i)),
+					Timestamp: time.Now().UTC(),
+				}
+				if i%5 == 0 {
+					event.Metadata = map[string]string{"caller": "test"}
+				}
+				if err := s.Save(ctx, event); err != nil {
+					t.Fatalf("Save failed: %v", err)
+				}
+			}
+
+			var raw []json.RawMessage
+			batches := 0
+			err := s.LoadStreamRaw(ctx, 3, 10, func(batch []json.RawMessage) error {
+				batches++
+				raw = append(raw, batch...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("LoadStreamRaw failed: %v", err)
+			}
+			if len(raw) != 23 {
+				t.Fatalf("expected 23 events, got %d", len(raw))
+			}
+			if batches != 3 {
+				t.Errorf("expected 3 batches, got %d", batches)
+			}
+
+			var decoded []*StoredEvent
+			err = s.LoadStream(ctx, 3, 10, func(batch []*StoredEvent) error {
+				decoded = append(decoded, batch...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("LoadStream failed: %v", err)
+			}
+
+			for i, data := range raw {
+				var got StoredEvent
+				if err := json.Unmarshal(data, &got); err != nil {
+					t.Fatalf("invalid JSON %s: %v", data, err)
+				}
+				want := decoded[i]
+				if got.Position != want.Position || got.Type != want.Type ||
+					!bytes.Equal(got.Data, want.Data) || !got.Timestamp.Equal(want.Timestamp) ||
+					got.Metadata["caller"] != want.Metadata["caller"] {
+					t.Errorf("raw event %d differs: %s vs %+v", i, data, want)
+				}
+			}
+		})
+	}
+}
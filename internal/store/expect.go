@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is wrapped by the errors of appends whose Expectation failed
+var ErrConflict = errors.New("append conflict")
+
+// Expectation guards a conditional append: the append only happens if the
+// log or stream is still where the caller last saw it. Nil fields are not
+// checked, so the zero value appends unconditionally.
+type Expectation struct {
+	Position      *int64 // Head position before the append
+	StreamVersion *int64 // Version of the events' stream before the append; 0 for a new stream
+}
+
+// ConflictError reports a failed Expectation
+type ConflictError struct {
+	StreamID string // Empty when the head position did not match
+	Expected int64
+	Actual   int64
+}
+
+func (e *ConflictError) Error() string {
+	if e.StreamID == "" {
+		return fmt.Sprintf("expected position %d, head is %d", e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("expected stream %q at version %d, stream is at %d", e.StreamID, e.Expected, e.Actual)
+}
+
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
+// ConditionalAppender is implemented by stores that can append events only
+// if an Expectation holds, for optimistic concurrency control
+type ConditionalAppender interface {
+	SaveBatchIf(ctx context.Context, events []*StoredEvent, expect Expectation) error
+}
+
+// streamID returns the stream a StreamVersion expectation applies to. All
+// events must belong to it, since one version cannot guard several streams.
+func (e Expectation) streamID(events []*StoredEvent) (string, error) {
+	if e.StreamVersion == nil {
+		return "", nil
+	}
+	if len(events) == 0 || events[0].StreamID == "" {
+		return "", fmt.Errorf("expected stream version needs events with a stream_id")
+	}
+	for _, event := range events[1:] {
+		if event.StreamID != events[0].StreamID {
+			return "", fmt.Errorf("expected stream version needs all events in one stream")
+		}
+	}
+	return events[0].StreamID, nil
+}
+
+// Validate rejects expectations that cannot apply to events
+func (e Expectation) Validate(events []*StoredEvent) error {
+	_, err := e.streamID(events)
+	return err
+}
+
+// check compares the expectation with the current head and stream version.
+// Stores call it while holding their write lock, before assigning positions.
+func (e Expectation) check(events []*StoredEvent, head func() (int64, error), streamHead func(string) (int64, error)) error {
+	if e.Position != nil {
+		actual, err := head()
+		if err != nil {
+			return err
+		}
+		if actual != *e.Position {
+			return &ConflictError{Expected: *e.Position, Actual: actual}
+		}
+	}
+
+	streamID, err := e.streamID(events)
+	if err != nil || streamID == "" {
+		return err
+	}
+	actual, err := streamHead(streamID)
+	if err != nil {
+		return err
+	}
+	if actual != *e.StreamVersion {
+		return &ConflictError{StreamID: streamID, Expected: *e.StreamVersion, Actual: actual}
+	}
+	return nil
+}
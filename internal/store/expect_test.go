@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveBatchIf(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/expect.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	strictStore, err := NewSQLiteStore(t.TempDir() + "/strict.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer strictStore.Close()
+	strictStore.SetStrictPositions(true)
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/expect")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	event := func(streamID string) *StoredEvent {
+		return &StoredEvent{Type: "Test", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: streamID}
+	}
+	at := func(v int64) *int64 { return &v }
+
+	for _, st := range []interface {
+		EventStore
+		StreamIndex
+		ConditionalAppender
+	}{sqliteStore, strictStore, pebbleStore, NewMemoryStore()} {
+		if err := st.SaveBatchIf(ctx, []*StoredEvent{event("a"), event("")}, Expectation{Position: at(0)}); err != nil {
+			t.Fatalf("%T: expected position 0 to match an empty store: %v", st, err)
+		}
+
+		var conflict *ConflictError
+		err := st.SaveBatchIf(ctx, []*StoredEvent{event("")}, Expectation{Position: at(1)})
+		if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) || conflict.Actual != 2 {
+			t.Fatalf("%T: expected a conflict at head 2, got %v", st, err)
+		}
+
+		err = st.SaveBatchIf(ctx, []*StoredEvent{event("a")}, Expectation{StreamVersion: at(0)})
+		if !errors.As(err, &conflict) || conflict.StreamID != "a" || conflict.Actual != 1 {
+			t.Fatalf("%T: expected a stream conflict at version 1, got %v", st, err)
+		}
+
+		batch := []*StoredEvent{event("a"), event("a")}
+		if err := st.SaveBatchIf(ctx, batch, Expectation{Position: at(2), StreamVersion: at(1)}); err != nil {
+			t.Fatalf("%T: expected both expectations to hold: %v", st, err)
+		}
+		if batch[1].Position != 4 || batch[1].StreamVersion != 3 {
+			t.Errorf("%T: expected position 4 at version 3, got %d at %d", st, batch[1].Position, batch[1].StreamVersion)
+		}
+		if err := st.SaveBatchIf(ctx, []*StoredEvent{event("new")}, Expectation{StreamVersion: at(0)}); err != nil {
+			t.Errorf("%T: expected version 0 to match a new stream: %v", st, err)
+		}
+
+		// Failed appends leave nothing behind
+		if events, _ := st.Load(ctx, 1, 100); len(events) != 5 {
+			t.Errorf("%T: expected 5 events, got %d", st, len(events))
+		}
+
+		for name, events := range map[string][]*StoredEvent{
+			"no stream":     {event("")},
+			"mixed streams": {event("a"), event("b")},
+		} {
+			err := st.SaveBatchIf(ctx, events, Expectation{StreamVersion: at(3)})
+			if err == nil || errors.Is(err, ErrConflict) {
+				t.Errorf("%T: %s: expected a validation error, got %v", st, name, err)
+			}
+		}
+	}
+}
+
+func TestSaveBatchIf_Concurrent(t *testing.T) {
+	st, err := NewPebbleStore(t.TempDir() + "/concurrent")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer st.Close()
+
+	// Writers racing on the same version: exactly one may win
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			zero := int64(0)
+			event := &StoredEvent{Type: "Test", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: "race"}
+			err := st.SaveBatchIf(ctx, []*StoredEvent{event}, Expectation{StreamVersion: &zero})
+			if err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrConflict) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wins != 1 {
+		t.Errorf("expected exactly one winner, got %d", wins)
+	}
+}
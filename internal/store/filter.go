@@ -0,0 +1,196 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// errFilterBatch stops a LoadStream after its first batch
+var errFilterBatch = errors.New("batch read")
+
+// Filter selects the events a consumer reads. Types and metadata values are
+// matched exactly, or as a prefix when they end in "*", so "Order.*" matches
+// every type under Order and a value of "*" only requires the key. An event
+// matches when its type matches one of Types (if any) and, for every key of
+// Metadata, its value for the key matches one of the values.
+type Filter struct {
+	Types    []string            `json:"types,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// Empty reports whether f selects every event
+func (f Filter) Empty() bool {
+	return len(f.Types) == 0 && len(f.Metadata) == 0
+}
+
+// ExactTypes reports whether f only selects events of exact types, which a
+// TypeIndex serves without scanning
+func (f Filter) ExactTypes() bool {
+	return f.indexed() && len(f.Metadata) == 0
+}
+
+// indexed reports whether the types of f can be read through a TypeIndex
+func (f Filter) indexed() bool {
+	return len(f.Types) > 0 && !slices.ContainsFunc(f.Types, isPattern)
+}
+
+// Validate rejects filters the stores do not serve
+func (f Filter) Validate() error {
+	if err := ValidateTypes(f.Types); err != nil {
+		return err
+	}
+	if len(f.Metadata) > MaxFilterTypes {
+		return fmt.Errorf("%d metadata keys requested, max %d", len(f.Metadata), MaxFilterTypes)
+	}
+	for key, values := range f.Metadata {
+		if key == "" {
+			return fmt.Errorf("metadata keys cannot be empty")
+		}
+		if len(values) == 0 || slices.Contains(values, "") {
+			return fmt.Errorf("metadata %q: values cannot be empty", key)
+		}
+	}
+	return nil
+}
+
+// Match reports whether event passes f
+func (f Filter) Match(event *StoredEvent) bool {
+	return f.MatchFields(event.Type, event.Metadata)
+}
+
+// MatchFields is Match for an event's type and metadata, for callers that
+// do not decode whole events
+func (f Filter) MatchFields(typ string, metadata map[string]string) bool {
+	if len(f.Types) > 0 && !slices.ContainsFunc(f.Types, func(pattern string) bool { return matchPattern(pattern, typ) }) {
+		return false
+	}
+	for key, values := range f.Metadata {
+		value, ok := metadata[key]
+		if !ok || !slices.ContainsFunc(values, func(pattern string) bool { return matchPattern(pattern, value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPattern(s string) bool {
+	return strings.HasSuffix(s, "*")
+}
+
+// matchPattern matches s exactly, or by prefix if pattern ends in "*"
+func matchPattern(pattern, s string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(s, prefix)
+	}
+	return pattern == s
+}
+
+// LoadFiltered is Load restricted to the events matching f, counting only
+// those towards MaxLoadEvents. Exact types are read through the TypeIndex
+// of st if it has one; other filters scan the log.
+func LoadFiltered(ctx context.Context, st EventStore, f Filter, from, to int64) ([]*StoredEvent, error) {
+	if index, ok := As[TypeIndex](st); ok && f.ExactTypes() {
+		return index.LoadByTypes(ctx, f.Types, from, to)
+	}
+	return loadLimited(from, to, f.loader(ctx, st))
+}
+
+// StreamFiltered is LoadStream restricted to the events matching f, with
+// batches of up to batchSize matches
+func StreamFiltered(ctx context.Context, st EventStore, f Filter, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	if index, ok := As[TypeIndex](st); ok && f.ExactTypes() {
+		return index.LoadStreamByTypes(ctx, f.Types, from, batchSize, handler)
+	}
+	return streamLimited(from, batchSize, f.loader(ctx, st), handler)
+}
+
+// loader returns a loadTypesFunc reading the events matching f, through the
+// TypeIndex of st when all types are exact and through LoadStream otherwise
+func (f Filter) loader(ctx context.Context, st EventStore) loadTypesFunc {
+	stream := func(from int64, batchSize int, handler func([]*StoredEvent) error) error {
+		return st.LoadStream(ctx, from, batchSize, handler)
+	}
+	if index, ok := As[TypeIndex](st); ok && f.indexed() {
+		stream = func(from int64, batchSize int, handler func([]*StoredEvent) error) error {
+			return index.LoadStreamByTypes(ctx, f.Types, from, batchSize, handler)
+		}
+	}
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		var matched []*StoredEvent
+		chunk := min(limit, DefaultStreamBatchSize)
+		for {
+			// One batch of candidates at a time; LoadStream stops early
+			var batch []*StoredEvent
+			err := stream(from, chunk, func(events []*StoredEvent) error {
+				batch = events
+				return errFilterBatch
+			})
+			if err != nil && !errors.Is(err, errFilterBatch) {
+				return nil, err
+			}
+			for _, event := range batch {
+				if event.Position > to {
+					return matched, nil
+				}
+				if f.Match(event) {
+					if matched = append(matched, event); len(matched) == limit {
+						return matched, nil
+					}
+				}
+			}
+			if len(batch) < chunk {
+				return matched, nil
+			}
+			from = batch[len(batch)-1].Position + 1
+		}
+	}
+}
+
+// loadLimited implements Load semantics on top of load: closed ranges are
+// read whole, open ones up to MaxLoadEvents
+func loadLimited(from, to int64, load loadTypesFunc) ([]*StoredEvent, error) {
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return []*StoredEvent{}, nil
+	}
+
+	limit := math.MaxInt
+	if to == -1 {
+		to, limit = math.MaxInt64, MaxLoadEvents
+	}
+	events, err := load(from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []*StoredEvent{}
+	}
+	return events, nil
+}
+
+// streamLimited implements LoadStream semantics on top of load, one call
+// per batch
+func streamLimited(from int64, batchSize int, load loadTypesFunc, handler func([]*StoredEvent) error) error {
+	from, batchSize = streamStart(from), streamBatchSize(batchSize)
+	for {
+		batch, err := load(from, math.MaxInt64, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := handler(batch); err != nil {
+			return fmt.Errorf("handle batch: %w", err)
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		from = batch[len(batch)-1].Position + 1
+	}
+}
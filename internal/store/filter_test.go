@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	f := Filter{
+		Types:    []string{"Order.*", "Refund"},
+		Metadata: map[string][]string{"region": {"eu-*", "uk"}, "tenant": {"*"}},
+	}
+	tests := []struct {
+		typ      string
+		metadata map[string]string
+		want     bool
+	}{
+		{"Order.Line.Added", map[string]string{"region": "eu-west", "tenant": "a"}, true},
+		{"Refund", map[string]string{"region": "uk", "tenant": ""}, true},
+		{"Order", map[string]string{"region": "uk", "tenant": "a"}, false},
+		{"RefundIssued", map[string]string{"region": "uk", "tenant": "a"}, false},
+		{"Order.Placed", map[string]string{"region": "us", "tenant": "a"}, false},
+		{"Order.Placed", map[string]string{"region": "uk"}, false},
+		{"Order.Placed", nil, false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(&StoredEvent{Type: tt.typ, Metadata: tt.metadata}); got != tt.want {
+			t.Errorf("Match(%s, %v) = %v, want %v", tt.typ, tt.metadata, got, tt.want)
+		}
+	}
+
+	if !(Filter{}).Match(&StoredEvent{Type: "Any"}) {
+		t.Error("Expected an empty filter to match every event")
+	}
+	if (Filter{Types: []string{"Order.*"}}).ExactTypes() || !(Filter{Types: []string{"Order"}}).ExactTypes() {
+		t.Error("ExactTypes() misreports patterns")
+	}
+
+	for _, invalid := range []Filter{
+		{Types: []string{""}},
+		{Metadata: map[string][]string{"": {"a"}}},
+		{Metadata: map[string][]string{"region": {}}},
+		{Metadata: map[string][]string{"region": {"eu", ""}}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", invalid)
+		}
+	}
+}
+
+func TestLoadFiltered(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/filter.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	ctx := context.Background()
+	// The memory store is also read without its type index
+	for _, st := range []EventStore{sqliteStore, NewMemoryStore(), struct{ EventStore }{NewMemoryStore()}} {
+		for i, typ := range []string{"Order.Placed", "Tick", "Order.Paid", "Order.Placed", "Tick", "Order.Paid"} {
+			region := "eu"
+			if i%2 == 1 {
+				region = "us"
+			}
+			event := &StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Metadata: map[string]string{"region": region}, Timestamp: time.Now()}
+			if err := st.Save(ctx, event); err != nil {
+				t.Fatalf("%T: save failed: %v", st, err)
+			}
+		}
+
+		tests := []struct {
+			filter   Filter
+			from, to int64
+			want     string
+		}{
+			{Filter{Types: []string{"Order.*"}}, 1, -1, "[1 3 4 6]"},
+			{Filter{Types: []string{"Order.*"}}, 2, 4, "[3 4]"},
+			{Filter{Types: []string{"Order.Paid", "Tick"}}, 1, -1, "[2 3 5 6]"},
+			{Filter{Metadata: map[string][]string{"region": {"us"}}}, 1, -1, "[2 4 6]"},
+			{Filter{Types: []string{"Order.*"}, Metadata: map[string][]string{"region": {"eu"}}}, 0, -1, "[1 3]"},
+			{Filter{Types: []string{"Unknown.*"}}, 1, -1, "[]"},
+			{Filter{Types: []string{"Order.*"}}, 5, 4, "[]"},
+		}
+		for _, tt := range tests {
+			events, err := LoadFiltered(ctx, st, tt.filter, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("%T: LoadFiltered failed: %v", st, err)
+			}
+			if got := typePositions(events); got != tt.want || events == nil {
+				t.Errorf("%T: %+v in %d-%d: expected %s, got %s", st, tt.filter, tt.from, tt.to, tt.want, got)
+			}
+		}
+
+		var batches []string
+		filter := Filter{Types: []string{"Order.*", "Tick"}, Metadata: map[string][]string{"region": {"us"}}}
+		err := StreamFiltered(ctx, st, filter, 1, 2, func(batch []*StoredEvent) error {
+			batches = append(batches, typePositions(batch))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%T: StreamFiltered failed: %v", st, err)
+		}
+		if got := fmt.Sprint(batches); got != "[[2 4] [6]]" {
+			t.Errorf("%T: expected batches [[2 4] [6]], got %s", st, got)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Gap is a range of positions without events
+type Gap struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// GapFinder is implemented by stores that can find gaps without streaming
+// every event through the caller
+type GapFinder interface {
+	FindGaps(ctx context.Context, from, to int64, limit int) ([]Gap, error)
+}
+
+// errGapsDone stops the stream once the range or the limit is exhausted
+var errGapsDone = errors.New("gaps done")
+
+// FirstPosition returns the position of the oldest event in st, or 0 if it
+// has none. Positions before it were pruned, moved to a cold tier or
+// skipped by a start position.
+func FirstPosition(ctx context.Context, st EventStore) (int64, error) {
+	var first int64
+	err := st.LoadStream(ctx, 1, 1, func(batch []*StoredEvent) error {
+		if len(batch) > 0 {
+			first = batch[0].Position
+		}
+		return errGapsDone
+	})
+	if err != nil && !errors.Is(err, errGapsDone) {
+		return 0, err
+	}
+	return first, nil
+}
+
+// FindGaps returns up to limit ranges of missing positions within
+// [from, to], in position order. Positions before the oldest event are not
+// gaps: they were pruned or skipped by a start position, not lost. Stores
+// without a GapFinder are scanned.
+func FindGaps(ctx context.Context, st EventStore, from, to int64, limit int) ([]Gap, error) {
+	if finder, ok := As[GapFinder](st); ok {
+		return finder.FindGaps(ctx, from, to, limit)
+	}
+
+	first, err := FirstPosition(ctx, st)
+	if err != nil || first == 0 {
+		return nil, err
+	}
+	from = max(from, first)
+
+	var gaps []Gap
+	next := from
+	err = st.LoadStream(ctx, from, 1000, func(batch []*StoredEvent) error {
+		for _, event := range batch {
+			if event.Position > to {
+				return errGapsDone
+			}
+			if event.Position > next {
+				gaps = append(gaps, Gap{From: next, To: event.Position - 1})
+				if len(gaps) >= limit {
+					return errGapsDone
+				}
+			}
+			next = event.Position + 1
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGapsDone) {
+		return nil, err
+	}
+	return gaps, nil
+}
+
+// GapStats describes position gaps seen by a store's write path
+type GapStats struct {
+	Strict       bool      `json:"strict"`            // New positions are assigned densely
+	Detected     int64     `json:"detected"`          // Gaps since the store was opened
+	Missing      int64     `json:"missing_positions"` // Positions skipped by those gaps
+	LastGap      *Gap      `json:"last_gap,omitempty"`
+	LastDetected time.Time `json:"last_detected,omitzero"`
+}
+
+// gapTracker compares written positions with the previous head, so a
+// skipped position is reported the moment it happens rather than when a
+// consumer trips over it
+type gapTracker struct {
+	mu    sync.Mutex
+	head  int64
+	stats GapStats
+}
+
+// observe checks positions written in order after the tracked head
+func (g *gapTracker) observe(events []*StoredEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, event := range events {
+		if event.Position > g.head+1 {
+			gap := Gap{From: g.head + 1, To: event.Position - 1}
+			g.stats.Detected++
+			g.stats.Missing += gap.To - gap.From + 1
+			g.stats.LastGap = &gap
+			g.stats.LastDetected = time.Now()
+			slog.Warn("Position gap detected", "from", gap.From, "to", gap.To)
+		}
+		g.head = max(g.head, event.Position)
+	}
+}
+
+// SetStrictPositions makes the store assign positions as head+1 itself
+// instead of relying on AUTOINCREMENT, and rejects imports that would leave
+// gaps, so positions written from now on are guaranteed to be dense.
+func (s *SQLiteStore) SetStrictPositions(strict bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strict = strict
+}
+
+// GapStats returns gaps detected in written positions
+func (s *SQLiteStore) GapStats() GapStats {
+	s.mu.RLock()
+	strict := s.strict
+	s.mu.RUnlock()
+
+	s.gaps.mu.Lock()
+	defer s.gaps.mu.Unlock()
+	stats := s.gaps.stats
+	stats.Strict = strict
+	return stats
+}
+
+// FindGaps implements GapFinder with a single pass over the primary key.
+// The range starts at the oldest event at the earliest.
+func (s *SQLiteStore) FindGaps(ctx context.Context, from, to int64, limit int) ([]Gap, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var gaps []Gap
+	err := s.busy.retryBusy(ctx, func() error {
+		gaps = nil
+
+		var oldest sql.NullInt64
+		if err := s.db.QueryRowContext(ctx, "SELECT MIN(position) FROM events").Scan(&oldest); err != nil {
+			return fmt.Errorf("find oldest position: %w", err)
+		}
+		if !oldest.Valid {
+			return nil
+		}
+		from := max(from, oldest.Int64)
+
+		// Positions missing before the first event of the range
+		var first sql.NullInt64
+		if err := s.db.QueryRowContext(ctx, "SELECT MIN(position) FROM events WHERE position >= ? AND position <= ?", from, to).Scan(&first); err != nil {
+			return fmt.Errorf("find first position: %w", err)
+		}
+		if !first.Valid {
+			return nil
+		}
+		if first.Int64 > from {
+			gaps = append(gaps, Gap{From: from, To: first.Int64 - 1})
+		}
+
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT position, next FROM (
+				SELECT position, LEAD(position) OVER (ORDER BY position) AS next
+				FROM events WHERE position >= ? AND position <= ?
+			) WHERE next > position + 1 ORDER BY position LIMIT ?`, from, to, limit)
+		if err != nil {
+			return fmt.Errorf("query gaps: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var position, next int64
+			if err := rows.Scan(&position, &next); err != nil {
+				return fmt.Errorf("scan gap: %w", err)
+			}
+			gaps = append(gaps, Gap{From: position + 1, To: next - 1})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gaps[:min(len(gaps), limit)], nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindGaps(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/gaps.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		Importer
+	}{sqliteStore, NewMemoryStore()} {
+		var imported []*StoredEvent
+		for _, position := range []int64{2, 3, 5, 9} {
+			imported = append(imported, &StoredEvent{Position: position, Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+		}
+		if err := st.ImportEvents(ctx, imported); err != nil {
+			t.Fatalf("%T.ImportEvents failed: %v", st, err)
+		}
+
+		gaps, err := FindGaps(ctx, st, 1, 9, 100)
+		if err != nil {
+			t.Fatalf("%T: FindGaps failed: %v", st, err)
+		}
+		if want := []Gap{{4, 4}, {6, 8}}; !reflect.DeepEqual(gaps, want) {
+			t.Errorf("%T: expected gaps %v, got %v", st, want, gaps)
+		}
+		if gaps, _ := FindGaps(ctx, st, 3, 9, 1); !reflect.DeepEqual(gaps, []Gap{{4, 4}}) {
+			t.Errorf("%T: expected only the first gap from 3, got %v", st, gaps)
+		}
+		if gaps, _ := FindGaps(ctx, st, 2, 5, 100); len(gaps) != 1 {
+			t.Errorf("%T: expected 1 gap in 2-5, got %v", st, gaps)
+		}
+	}
+}
+
+func TestFindGaps_StartPosition(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/gaps.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/gaps")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	// The positions skipped by a start position are not missing
+	ctx := context.Background()
+	for _, st := range []EventStore{sqliteStore, pebbleStore, NewMemoryStore()} {
+		if _, err := StartAt(ctx, st, 1000000); err != nil {
+			t.Fatalf("%T: StartAt failed: %v", st, err)
+		}
+		if gaps, err := FindGaps(ctx, st, 1, 1000000, 100); err != nil || len(gaps) != 0 {
+			t.Errorf("%T: expected no gaps in a store without events, got %v, %v", st, gaps, err)
+		}
+		if err := st.Save(ctx, &StoredEvent{Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("%T: Save failed: %v", st, err)
+		}
+		if first, err := FirstPosition(ctx, st); err != nil || first != 1000001 {
+			t.Errorf("%T: expected first position 1000001, got %d, %v", st, first, err)
+		}
+		if gaps, err := FindGaps(ctx, st, 1, 1000001, 100); err != nil || len(gaps) != 0 {
+			t.Errorf("%T: expected no gaps, got %v, %v", st, gaps, err)
+		}
+	}
+}
+
+func TestSQLiteStore_GapDetection(t *testing.T) {
+	st, err := NewSQLiteStore(t.TempDir() + "/gaps.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	save := func() int64 {
+		t.Helper()
+		event := &StoredEvent{Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+		if err := st.Save(ctx, event); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		return event.Position
+	}
+
+	save()
+	save()
+	save()
+
+	// Simulate a position handed out by AUTOINCREMENT whose event never
+	// became visible; the sequence is not reused
+	if _, err := st.db.Exec("DELETE FROM events WHERE position = 3"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	st.gaps.head = 2
+	if position := save(); position != 4 {
+		t.Fatalf("expected AUTOINCREMENT to skip to 4, got %d", position)
+	}
+
+	stats := st.GapStats()
+	if stats.Detected != 1 || stats.Missing != 1 || stats.LastGap == nil || *stats.LastGap != (Gap{3, 3}) || stats.Strict {
+		t.Errorf("expected one gap at 3, got %+v", stats)
+	}
+
+	// Strict mode continues right after the current maximum
+	st.SetStrictPositions(true)
+	if _, err := st.db.Exec("DELETE FROM events WHERE position = 4"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if position := save(); position != 3 {
+		t.Errorf("expected strict mode to write position 3, got %d", position)
+	}
+	batch := []*StoredEvent{{Type: "B", Data: json.RawMessage(`{}`)}, {Type: "C", Data: json.RawMessage(`{}`)}}
+	if err := st.SaveBatch(ctx, batch); err != nil || batch[0].Position != 4 || batch[1].Position != 5 {
+		t.Errorf("expected batch at 4-5, got %d-%d, %v", batch[0].Position, batch[1].Position, err)
+	}
+	if err := st.ImportEvents(ctx, []*StoredEvent{{Position: 7, Type: "D", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Error("expected strict mode to reject an import leaving a gap")
+	}
+	if stats := st.GapStats(); stats.Detected != 1 || !stats.Strict {
+		t.Errorf("expected no new gaps in strict mode, got %+v", stats)
+	}
+}
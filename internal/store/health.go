@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// Thresholds above which StorageHealth reports a warning
+const (
+	healthCompactionDebt = 4 << 30 // Bytes of pending compaction
+	healthWriteAmp       = 30      // Total LSM write amplification
+	healthFreelistRatio  = 0.25    // Share of SQLite pages that are free
+	healthWALBytes       = 1 << 30 // WAL size without a monitor threshold
+)
+
+// HealthReporter is implemented by stores that report the state of their
+// storage engine, so degradation shows up before latency does
+type HealthReporter interface {
+	StorageHealth(ctx context.Context) (StorageHealth, error)
+}
+
+// StorageHealth describes the storage engine of a store. Degraded and
+// Warnings summarize the backend details for alerting.
+type StorageHealth struct {
+	Backend   string        `json:"backend"`
+	DiskBytes int64         `json:"disk_bytes"`
+	Degraded  bool          `json:"degraded"`
+	Warnings  []string      `json:"warnings,omitempty"`
+	Pebble    *PebbleHealth `json:"pebble,omitempty"`
+	SQLite    *SQLiteHealth `json:"sqlite,omitempty"`
+}
+
+func (h *StorageHealth) warn(format string, args ...any) {
+	h.Degraded = true
+	h.Warnings = append(h.Warnings, fmt.Sprintf(format, args...))
+}
+
+// PebbleHealth reports the shape of the LSM tree
+type PebbleHealth struct {
+	Levels         []LevelHealth `json:"levels"`
+	L0Sublevels    int32         `json:"l0_sublevels"`
+	L0StopWrites   int32         `json:"l0_stop_writes"` // Sublevels at which writes stall
+	ReadAmp        int           `json:"read_amp"`
+	WriteAmp       float64       `json:"write_amp"`
+	CompactionDebt uint64        `json:"compaction_debt_bytes"`
+	Compactions    int64         `json:"compactions"`
+	Flushes        int64         `json:"flushes"`
+	MemTableBytes  uint64        `json:"memtable_bytes"`
+	WALBytes       uint64        `json:"wal_bytes"`
+	ObsoleteBytes  uint64        `json:"obsolete_bytes"` // Tables not yet deleted
+}
+
+// LevelHealth reports one level of the LSM tree
+type LevelHealth struct {
+	Level    int     `json:"level"`
+	Files    int64   `json:"files"`
+	Bytes    int64   `json:"bytes"`
+	Score    float64 `json:"score"` // Compaction is due above 1
+	WriteAmp float64 `json:"write_amp"`
+}
+
+// SQLiteHealth reports page usage of the database file and the WAL
+type SQLiteHealth struct {
+	PageSize      int64   `json:"page_size"`
+	Pages         int64   `json:"pages"`
+	FreePages     int64   `json:"free_pages"`
+	FreelistRatio float64 `json:"freelist_ratio"` // Reclaimable with VACUUM
+	WALFrames     int64   `json:"wal_frames"`
+	WALBytes      int64   `json:"wal_bytes"`
+}
+
+// StorageHealth implements HealthReporter
+func (s *PebbleStore) StorageHealth(ctx context.Context) (StorageHealth, error) {
+	m := s.db.Metrics()
+	total := m.Total()
+
+	p := &PebbleHealth{
+		L0Sublevels:    m.Levels[0].Sublevels,
+		L0StopWrites:   pebbleL0StopWrites,
+		ReadAmp:        m.ReadAmp(),
+		WriteAmp:       total.WriteAmp(),
+		CompactionDebt: m.Compact.EstimatedDebt,
+		Compactions:    m.Compact.Count,
+		Flushes:        m.Flush.Count,
+		MemTableBytes:  m.MemTable.Size,
+		WALBytes:       m.WAL.PhysicalSize,
+		ObsoleteBytes:  m.Table.ObsoleteSize,
+	}
+	for i := range m.Levels {
+		level := &m.Levels[i]
+		p.Levels = append(p.Levels, LevelHealth{
+			Level:    i,
+			Files:    level.NumFiles,
+			Bytes:    level.Size,
+			Score:    level.Score,
+			WriteAmp: level.WriteAmp(),
+		})
+	}
+
+	h := StorageHealth{Backend: "pebble", DiskBytes: int64(m.DiskSpaceUsage()), Pebble: p}
+	if p.L0Sublevels >= pebbleL0StopWrites/2 {
+		h.warn("L0 has %d sublevels, writes stall at %d", p.L0Sublevels, pebbleL0StopWrites)
+	}
+	if p.CompactionDebt >= healthCompactionDebt {
+		h.warn("compaction debt is %d bytes", p.CompactionDebt)
+	}
+	if p.WriteAmp >= healthWriteAmp {
+		h.warn("write amplification is %.1f", p.WriteAmp)
+	}
+	return h, nil
+}
+
+// StorageHealth implements HealthReporter
+func (s *SQLiteStore) StorageHealth(ctx context.Context) (StorageHealth, error) {
+	q := &SQLiteHealth{}
+	for _, pragma := range []struct {
+		name string
+		dest *int64
+	}{
+		{"page_size", &q.PageSize},
+		{"page_count", &q.Pages},
+		{"freelist_count", &q.FreePages},
+	} {
+		if err := s.db.QueryRowContext(ctx, "PRAGMA "+pragma.name).Scan(pragma.dest); err != nil {
+			return StorageHealth{}, fmt.Errorf("pragma %s: %w", pragma.name, err)
+		}
+	}
+	if q.Pages > 0 {
+		q.FreelistRatio = float64(q.FreePages) / float64(q.Pages)
+	}
+
+	// A WAL is a 32-byte header followed by frames of a 24-byte header and
+	// one page each
+	var err error
+	if q.WALBytes, err = s.WALSize(); err != nil {
+		return StorageHealth{}, err
+	}
+	if q.WALBytes > 32 && q.PageSize > 0 {
+		q.WALFrames = (q.WALBytes - 32) / (24 + q.PageSize)
+	}
+
+	h := StorageHealth{Backend: "sqlite", DiskBytes: q.Pages*q.PageSize + q.WALBytes, SQLite: q}
+	if q.FreelistRatio >= healthFreelistRatio {
+		h.warn("%.0f%% of database pages are free", q.FreelistRatio*100)
+	}
+	limit := int64(healthWALBytes)
+	if s.wal != nil {
+		limit = 2 * s.wal.threshold
+	}
+	if q.WALBytes >= limit {
+		h.warn("WAL is %d bytes, checkpoints are falling behind", q.WALBytes)
+	}
+	return h, nil
+}
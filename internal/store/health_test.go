@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_StorageHealth(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "health.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	data := json.RawMessage(`{"payload":"` + strings.Repeat("x", 1000) + `"}`)
+	for range 500 {
+		s.Save(ctx, &StoredEvent{Type: "TestEvent", Data: data, Timestamp: time.Now()})
+	}
+
+	health, err := s.StorageHealth(ctx)
+	if err != nil {
+		t.Fatalf("StorageHealth failed: %v", err)
+	}
+	if health.Backend != "sqlite" || health.SQLite == nil || health.SQLite.Pages == 0 || health.SQLite.WALFrames == 0 {
+		t.Fatalf("Expected page and WAL frame counts, got %+v", health.SQLite)
+	}
+	if health.Degraded {
+		t.Errorf("Expected a healthy store, got warnings %v", health.Warnings)
+	}
+
+	// Deleted rows leave free pages behind until VACUUM
+	if _, err := s.db.Exec("DELETE FROM events"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	s.Checkpoint(ctx)
+	health, _ = s.StorageHealth(ctx)
+	if !health.Degraded || health.SQLite.FreelistRatio < healthFreelistRatio {
+		t.Errorf("Expected a freelist warning, got %+v (%v)", health.SQLite, health.Warnings)
+	}
+}
+
+func TestPebbleStore_StorageHealth(t *testing.T) {
+	s, err := NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	for range 100 {
+		s.Save(ctx, &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{"n":1}`), Timestamp: time.Now()})
+	}
+
+	health, err := s.StorageHealth(ctx)
+	if err != nil {
+		t.Fatalf("StorageHealth failed: %v", err)
+	}
+	if health.Backend != "pebble" || health.Pebble == nil || len(health.Pebble.Levels) != 7 {
+		t.Fatalf("Expected per-level metrics, got %+v", health.Pebble)
+	}
+	if health.Pebble.L0StopWrites != pebbleL0StopWrites || health.Pebble.WALBytes == 0 {
+		t.Errorf("Unexpected LSM metrics: %+v", health.Pebble)
+	}
+	if health.Degraded {
+		t.Errorf("Expected a healthy store, got warnings %v", health.Warnings)
+	}
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Importer is implemented by stores that can append events at the positions
+// they carry, for restoring history exactly, e.g. when hydrating a new node
+// from an archive. Positions must be increasing and past the head; gaps are
+// preserved. Each call is atomic, and appends made meanwhile are ordered
+// before or after it, so a live store can import too.
+type Importer interface {
+	ImportEvents(ctx context.Context, events []*StoredEvent) error
+}
+
+// BulkImporter is implemented by stores that can import more events than
+// fit one ImportEvents call atomically. scan passes the events to import to
+// fn in batches, in position order; either all of them are in the store
+// afterwards or, if scan or the import fails, none is. scan may be called
+// more than once, e.g. when a busy transaction is retried.
+type BulkImporter interface {
+	ImportAll(ctx context.Context, scan func(fn func([]*StoredEvent) error) error) error
+}
+
+// ImportAll imports the events scan yields into st atomically, with st's
+// BulkImporter if it has one, otherwise by collecting them for a single
+// ImportEvents call
+func ImportAll(ctx context.Context, st EventStore, scan func(fn func([]*StoredEvent) error) error) error {
+	if bulk, ok := As[BulkImporter](st); ok {
+		return bulk.ImportAll(ctx, scan)
+	}
+	importer, ok := As[Importer](st)
+	if !ok {
+		return fmt.Errorf("store does not support importing events")
+	}
+	var events []*StoredEvent
+	err := scan(func(batch []*StoredEvent) error {
+		events = append(events, batch...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return importer.ImportEvents(ctx, events)
+}
+
+// checkImportPositions rejects events that are not in increasing order
+// after head, with ErrConflict
+func checkImportPositions(events []*StoredEvent, head int64) error {
+	last := head
+	for _, event := range events {
+		if event.Position <= last {
+			return fmt.Errorf("%w: cannot import position %d after %d", ErrConflict, event.Position, last)
+		}
+		last = event.Position
+	}
+	return nil
+}
+
+// importBatches is the scan of ImportAll for a single batch
+func importBatches(events []*StoredEvent) func(fn func([]*StoredEvent) error) error {
+	return func(fn func([]*StoredEvent) error) error {
+		return fn(events)
+	}
+}
+
+// gapMarks collects the positions of a bulk import that gapTracker.observe
+// needs to see, without keeping every event: the first, those on either
+// side of a gap, and the last
+type gapMarks struct {
+	marks []*StoredEvent
+	last  int64
+	count int // Events imported
+}
+
+func (g *gapMarks) add(events []*StoredEvent) {
+	g.count += len(events)
+	for _, event := range events {
+		switch {
+		case g.last == 0:
+			g.marks = append(g.marks, &StoredEvent{Position: event.Position})
+		case event.Position > g.last+1:
+			g.marks = append(g.marks, &StoredEvent{Position: g.last}, &StoredEvent{Position: event.Position})
+		}
+		g.last = event.Position
+	}
+}
+
+// events returns the marks, ending with the last event imported
+func (g *gapMarks) events() []*StoredEvent {
+	if g.last == 0 {
+		return nil
+	}
+	return append(g.marks, &StoredEvent{Position: g.last})
+}
+
+// ErrImportTooLarge is returned by imports a store cannot commit at once;
+// nothing is imported, and the history must be split into smaller imports
+var ErrImportTooLarge = errors.New("import too large")
+
+// maxPebbleImportBytes caps the batch of a Pebble import, which is held in
+// memory until it commits while appends wait
+var maxPebbleImportBytes = 256 << 20
+
+// ImportEvents implements Importer
+func (s *PebbleStore) ImportEvents(ctx context.Context, events []*StoredEvent) error {
+	return s.ImportAll(ctx, importBatches(events))
+}
+
+// ImportAll implements BulkImporter with a single Pebble batch, which holds
+// the whole import in memory until it is committed. Appends wait for it, so
+// imports whose batch grows past maxPebbleImportBytes fail with
+// ErrImportTooLarge.
+func (s *PebbleStore) ImportAll(ctx context.Context, scan func(fn func([]*StoredEvent) error) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head := s.position.Load()
+	last := head
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	rollups := rollupDeltas{}
+	err := scan(func(events []*StoredEvent) error {
+		if err := checkImportPositions(events, last); err != nil {
+			return err
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("marshal event: %w", err)
+			}
+			if err := batch.Set(eventKey(event.Position), data, nil); err != nil {
+				return fmt.Errorf("batch set: %w", err)
+			}
+			if err := setStreamKey(batch, event); err != nil {
+				return err
+			}
+			if err := setTypeKey(batch, event); err != nil {
+				return err
+			}
+			if err := setTimeKey(batch, event); err != nil {
+				return err
+			}
+			rollups.add(event, 1)
+			last = event.Position
+		}
+		if batch.Len() > maxPebbleImportBytes {
+			return fmt.Errorf("%w: over %d MiB up to position %d", ErrImportTooLarge, maxPebbleImportBytes>>20, last)
+		}
+		return ctx.Err()
+	})
+	if err != nil || last == head {
+		return err
+	}
+
+	if err := s.commitWithRollups(batch, rollups, pebble.NoSync); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
+	s.position.Store(last)
+	return nil
+}
+
+// ImportEvents implements Importer
+func (s *SQLiteStore) ImportEvents(ctx context.Context, events []*StoredEvent) error {
+	return s.ImportAll(ctx, importBatches(events))
+}
+
+// ImportAll implements BulkImporter in a single transaction
+func (s *SQLiteStore) ImportAll(ctx context.Context, scan func(fn func([]*StoredEvent) error) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var gaps gapMarks
+	err := s.busy.retryBusy(ctx, func() error {
+		gaps = gapMarks{}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var head sql.NullInt64
+		if err := tx.StmtContext(ctx, s.positionStmt).QueryRowContext(ctx).Scan(&head); err != nil {
+			return fmt.Errorf("get max position: %w", err)
+		}
+		last := head.Int64
+		err = scan(func(events []*StoredEvent) error {
+			if err := checkImportPositions(events, last); err != nil {
+				return err
+			}
+			for _, event := range events {
+				if s.strict && event.Position != last+1 {
+					return fmt.Errorf("strict positions: import would leave a gap before position %d", event.Position)
+				}
+				metadata, err := encodeMetadata(event.Metadata)
+				if err != nil {
+					return err
+				}
+				_, err = tx.ExecContext(ctx,
+					"INSERT INTO events (position, type, data, timestamp, metadata, stream_id, stream_version, time_ns) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+					event.Position, event.Type, event.Data, event.Timestamp, metadata, nullString(event.StreamID), nullInt64(event.StreamVersion), unixNano(event.Timestamp))
+				if err != nil {
+					return fmt.Errorf("import event %d: %w", event.Position, err)
+				}
+				last = event.Position
+			}
+			gaps.add(events)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.gaps.observe(gaps.events())
+	s.noteWrites(gaps.count)
+	return nil
+}
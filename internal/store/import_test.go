@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportEvents(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/import.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/import")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		Importer
+	}{sqliteStore, pebbleStore} {
+		// Position 3 is a gap in the imported history
+		imported := []*StoredEvent{
+			{Position: 1, Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+			{Position: 2, Type: "B", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+			{Position: 4, Type: "C", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+		}
+		if err := st.ImportEvents(ctx, imported); err != nil {
+			t.Fatalf("%T.ImportEvents failed: %v", st, err)
+		}
+		if head, _ := st.GetPosition(ctx); head != 4 {
+			t.Errorf("%T: expected head 4 after import, got %d", st, head)
+		}
+
+		events, err := st.Load(ctx, 1, 4)
+		if err != nil || len(events) != 3 || events[2].Position != 4 || events[2].Type != "C" {
+			t.Fatalf("%T: expected positions 1, 2 and 4, got %d events, %v", st, len(events), err)
+		}
+
+		// New writes continue after the imported head
+		event := &StoredEvent{Type: "D", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+		if err := st.Save(ctx, event); err != nil {
+			t.Fatalf("%T.Save failed: %v", st, err)
+		}
+		if event.Position != 5 {
+			t.Errorf("%T: expected the next save at 5, got %d", st, event.Position)
+		}
+
+		// Imports only append
+		if err := st.ImportEvents(ctx, []*StoredEvent{{Position: 3, Type: "Late", Data: json.RawMessage(`{}`)}}); err == nil {
+			t.Errorf("%T: expected error for a position below the head", st)
+		}
+	}
+}
+
+func TestImportAll_Atomic(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stores := map[string]func() (EventStore, error){
+		"sqlite": func() (EventStore, error) { return NewSQLiteStore(dir + "/import.db") },
+		"pebble": func() (EventStore, error) { return NewPebbleStore(dir + "/import") },
+		"memory": func() (EventStore, error) { return NewMemoryStore(), nil },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			st, err := open()
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			defer st.Close()
+
+			event := func(position int64) *StoredEvent {
+				return &StoredEvent{Position: position, Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+			}
+			// The second batch is out of order, so the first must not be imported either
+			err = ImportAll(ctx, st, func(fn func([]*StoredEvent) error) error {
+				if err := fn([]*StoredEvent{event(1), event(2)}); err != nil {
+					return err
+				}
+				return fn([]*StoredEvent{event(2)})
+			})
+			if err == nil {
+				t.Fatal("expected an error for a position out of order")
+			}
+			if head, _ := st.GetPosition(ctx); head != 0 {
+				t.Errorf("head = %d after a failed import, want 0", head)
+			}
+
+			err = ImportAll(ctx, st, func(fn func([]*StoredEvent) error) error {
+				if err := fn([]*StoredEvent{event(1), event(2)}); err != nil {
+					return err
+				}
+				return fn([]*StoredEvent{event(4)})
+			})
+			if err != nil {
+				t.Fatalf("ImportAll failed: %v", err)
+			}
+			if head, _ := st.GetPosition(ctx); head != 4 {
+				t.Errorf("head = %d after import, want 4", head)
+			}
+		})
+	}
+}
+
+func TestPebbleStore_ImportTooLarge(t *testing.T) {
+	ctx := context.Background()
+	st, err := NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	defer func(limit int) { maxPebbleImportBytes = limit }(maxPebbleImportBytes)
+	maxPebbleImportBytes = 64 << 10
+
+	data := json.RawMessage(`{"padding":"` + strings.Repeat("x", 1000) + `"}`)
+	batches := 0
+	err = st.ImportAll(ctx, func(fn func([]*StoredEvent) error) error {
+		for position := int64(1); position <= 1000; position += 10 {
+			batch := make([]*StoredEvent, 10)
+			for i := range batch {
+				batch[i] = &StoredEvent{Position: position + int64(i), Type: "A", Data: data, Timestamp: time.Now()}
+			}
+			batches++
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrImportTooLarge) {
+		t.Fatalf("expected ErrImportTooLarge, got %v", err)
+	}
+	if batches > 10 {
+		t.Errorf("expected the import stopped once over the cap, scanned %d batches", batches)
+	}
+	if head, _ := st.GetPosition(ctx); head != 0 {
+		t.Errorf("head = %d after a failed import, want 0", head)
+	}
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets
+var latencyBounds = [...]time.Duration{
+	50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Instrumented methods, indexing Instrumented.methods
+const (
+	methodSave = iota
+	methodSaveBatch
+	methodLoad
+	methodLoadByTime
+	methodLoadStream
+	methodGetPosition
+	methodSaveSubscription
+	methodLoadSubscription
+	methodDeleteBefore
+	numMethods
+)
+
+// methodNames name the instrumented methods in Latencies
+var methodNames = [numMethods]string{
+	"save", "save_batch", "load", "load_by_time", "load_stream", "get_position",
+	"save_subscription_position", "load_subscription_position", "delete_before",
+}
+
+// latencyHistogram counts the calls of one method by duration
+type latencyHistogram struct {
+	buckets [len(latencyBounds) + 1]atomic.Int64 // The last counts calls slower than every bound
+	errors  atomic.Int64
+	total   atomic.Int64 // Nanoseconds
+	max     atomic.Int64 // Nanoseconds
+}
+
+func (h *latencyHistogram) observe(d time.Duration, err error) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	if err != nil {
+		h.errors.Add(1)
+	}
+	h.total.Add(int64(d))
+	for {
+		prev := h.max.Load()
+		if int64(d) <= prev || h.max.CompareAndSwap(prev, int64(d)) {
+			break
+		}
+	}
+}
+
+// LatencyBucket counts calls that took at most LE milliseconds, and longer
+// than the previous bucket's bound
+type LatencyBucket struct {
+	LE    float64 `json:"le_ms"`
+	Count int64   `json:"count"`
+}
+
+// LatencyStats summarizes the calls of one store method. Percentiles are
+// the upper bound of the bucket they fall in.
+type LatencyStats struct {
+	Count   int64           `json:"count"`
+	Errors  int64           `json:"errors"`
+	MeanMs  float64         `json:"mean_ms"`
+	P50Ms   float64         `json:"p50_ms"`
+	P99Ms   float64         `json:"p99_ms"`
+	MaxMs   float64         `json:"max_ms"`
+	Buckets []LatencyBucket `json:"buckets"`
+	Slower  int64           `json:"slower"` // Calls slower than the last bucket
+}
+
+func (h *latencyHistogram) stats() LatencyStats {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	stats := LatencyStats{
+		Errors:  h.errors.Load(),
+		MaxMs:   ms(time.Duration(h.max.Load())),
+		Buckets: make([]LatencyBucket, len(latencyBounds)),
+		Slower:  h.buckets[len(latencyBounds)].Load(),
+	}
+	for i, bound := range latencyBounds {
+		stats.Buckets[i] = LatencyBucket{LE: ms(bound), Count: h.buckets[i].Load()}
+		stats.Count += stats.Buckets[i].Count
+	}
+	stats.Count += stats.Slower
+	if stats.Count == 0 {
+		return stats
+	}
+	stats.MeanMs = ms(time.Duration(h.total.Load() / stats.Count))
+
+	percentile := func(q float64) float64 {
+		var seen int64
+		for _, b := range stats.Buckets {
+			if seen += b.Count; float64(seen) >= q*float64(stats.Count) {
+				return b.LE
+			}
+		}
+		return stats.MaxMs
+	}
+	stats.P50Ms, stats.P99Ms = percentile(0.5), percentile(0.99)
+	return stats
+}
+
+// Instrumented is an EventStore recording the latency and errors of every
+// EventStore method it forwards. Optional interfaces of the wrapped store
+// are reached through As, and are not timed.
+type Instrumented struct {
+	st      EventStore
+	methods [numMethods]latencyHistogram
+}
+
+// Instrument wraps st in an Instrumented store
+func Instrument(st EventStore) *Instrumented {
+	return &Instrumented{st: st}
+}
+
+// Unwrap returns the wrapped store
+func (s *Instrumented) Unwrap() EventStore {
+	return s.st
+}
+
+// Latencies returns the latency statistics of each method called so far,
+// by method name
+func (s *Instrumented) Latencies() map[string]LatencyStats {
+	latencies := make(map[string]LatencyStats)
+	for i := range s.methods {
+		if stats := s.methods[i].stats(); stats.Count > 0 {
+			latencies[methodNames[i]] = stats
+		}
+	}
+	return latencies
+}
+
+// observe records a call of method that started at start
+func (s *Instrumented) observe(method int, start time.Time, err error) {
+	s.methods[method].observe(time.Since(start), err)
+}
+
+// Save implements EventStore.Save
+func (s *Instrumented) Save(ctx context.Context, event *StoredEvent) error {
+	start := time.Now()
+	err := s.st.Save(ctx, event)
+	s.observe(methodSave, start, err)
+	return err
+}
+
+// SaveBatch implements EventStore.SaveBatch
+func (s *Instrumented) SaveBatch(ctx context.Context, events []*StoredEvent) error {
+	start := time.Now()
+	err := s.st.SaveBatch(ctx, events)
+	s.observe(methodSaveBatch, start, err)
+	return err
+}
+
+// Load implements EventStore.Load
+func (s *Instrumented) Load(ctx context.Context, from, to int64) ([]*StoredEvent, error) {
+	start := time.Now()
+	events, err := s.st.Load(ctx, from, to)
+	s.observe(methodLoad, start, err)
+	return events, err
+}
+
+// LoadByTime implements EventStore.LoadByTime
+func (s *Instrumented) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error) {
+	start := time.Now()
+	events, err := s.st.LoadByTime(ctx, since, until, from, to)
+	s.observe(methodLoadByTime, start, err)
+	return events, err
+}
+
+// LoadStream implements EventStore.LoadStream. The time spent in handler
+// is not counted, so slow consumers don't show up as a slow store.
+func (s *Instrumented) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	start := time.Now()
+	var handling time.Duration
+	err := s.st.LoadStream(ctx, from, batchSize, func(batch []*StoredEvent) error {
+		handlerStart := time.Now()
+		defer func() { handling += time.Since(handlerStart) }()
+		return handler(batch)
+	})
+	s.methods[methodLoadStream].observe(time.Since(start)-handling, err)
+	return err
+}
+
+// LoadStreamRaw implements RawStreamer if the wrapped store does, timed as
+// LoadStream; otherwise it streams LoadStream's events encoded
+func (s *Instrumented) LoadStreamRaw(ctx context.Context, from int64, batchSize int, handler func([]json.RawMessage) error) error {
+	rs, ok := As[RawStreamer](s.st)
+	if !ok {
+		return s.LoadStream(ctx, from, batchSize, func(batch []*StoredEvent) error {
+			raw := make([]json.RawMessage, 0, len(batch))
+			for _, event := range batch {
+				data, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				raw = append(raw, data)
+			}
+			return handler(raw)
+		})
+	}
+
+	start := time.Now()
+	var handling time.Duration
+	err := rs.LoadStreamRaw(ctx, from, batchSize, func(batch []json.RawMessage) error {
+		handlerStart := time.Now()
+		defer func() { handling += time.Since(handlerStart) }()
+		return handler(batch)
+	})
+	s.methods[methodLoadStream].observe(time.Since(start)-handling, err)
+	return err
+}
+
+// GetPosition implements EventStore.GetPosition
+func (s *Instrumented) GetPosition(ctx context.Context) (int64, error) {
+	start := time.Now()
+	position, err := s.st.GetPosition(ctx)
+	s.observe(methodGetPosition, start, err)
+	return position, err
+}
+
+// SaveSubscriptionPosition implements EventStore.SaveSubscriptionPosition
+func (s *Instrumented) SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error {
+	start := time.Now()
+	err := s.st.SaveSubscriptionPosition(ctx, subscriptionID, position)
+	s.observe(methodSaveSubscription, start, err)
+	return err
+}
+
+// LoadSubscriptionPosition implements EventStore.LoadSubscriptionPosition
+func (s *Instrumented) LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error) {
+	start := time.Now()
+	position, err := s.st.LoadSubscriptionPosition(ctx, subscriptionID)
+	s.observe(methodLoadSubscription, start, err)
+	return position, err
+}
+
+// DeleteBefore implements EventStore.DeleteBefore
+func (s *Instrumented) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	start := time.Now()
+	deleted, err := s.st.DeleteBefore(ctx, position)
+	s.observe(methodDeleteBefore, start, err)
+	return deleted, err
+}
+
+// Close implements EventStore.Close
+func (s *Instrumented) Close() error {
+	return s.st.Close()
+}
+
+// As finds the first store in the chain of stores wrapped by st, starting
+// at st itself, that is a T, like errors.As. Callers looking for optional
+// interfaces use it so they see through wrappers such as Instrumented.
+func As[T any](st EventStore) (T, bool) {
+	for {
+		if t, ok := st.(T); ok {
+			return t, true
+		}
+		wrapper, ok := st.(interface{ Unwrap() EventStore })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		st = wrapper.Unwrap()
+	}
+}
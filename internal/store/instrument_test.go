@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInstrumented(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStore()
+	st := Instrument(mem)
+
+	for range 3 {
+		if err := st.Save(ctx, &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// Time spent handling batches is not the store's
+	err := st.LoadStream(ctx, 1, 1, func([]*StoredEvent) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream failed: %v", err)
+	}
+	mem.Close()
+	if _, err := st.Load(ctx, 1, -1); err == nil {
+		t.Fatal("Expected Load on a closed store to fail")
+	}
+
+	latencies := st.Latencies()
+	if save := latencies["save"]; save.Count != 3 || save.Errors != 0 || len(save.Buckets) != len(latencyBounds) {
+		t.Errorf("Unexpected save latencies: %+v", save)
+	}
+	if stream := latencies["load_stream"]; stream.Count != 1 || stream.MaxMs >= 20 {
+		t.Errorf("LoadStream counted handler time: %+v", stream)
+	}
+	if load := latencies["load"]; load.Count != 1 || load.Errors != 1 {
+		t.Errorf("Unexpected load latencies: %+v", load)
+	}
+	if _, ok := latencies["get_position"]; ok {
+		t.Error("Methods never called should not be listed")
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	for range 98 {
+		h.observe(300*time.Microsecond, nil)
+	}
+	h.observe(40*time.Millisecond, nil)
+	h.observe(20*time.Second, errors.New("timeout"))
+
+	stats := h.stats()
+	if stats.Count != 100 || stats.Errors != 1 || stats.Slower != 1 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if stats.P50Ms != 0.5 || stats.P99Ms != 50 || stats.MaxMs != 20000 {
+		t.Errorf("Unexpected percentiles: p50 %v, p99 %v, max %v", stats.P50Ms, stats.P99Ms, stats.MaxMs)
+	}
+}
+
+func TestAs(t *testing.T) {
+	mem := NewMemoryStore()
+	if got, ok := As[*MemoryStore](Instrument(Instrument(mem))); !ok || got != mem {
+		t.Error("As should find the store through wrappers")
+	}
+	if _, ok := As[*SQLiteStore](Instrument(mem)); ok {
+		t.Error("As found a store that isn't wrapped")
+	}
+}
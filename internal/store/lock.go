@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// ErrLocked is returned when a store is already open in another process (or
+// another store in this process). Two writers on the same data would
+// corrupt it, so the second open fails instead.
+var ErrLocked = errors.New("store is already in use by another process")
+
+// lockPath returns the advisory lock file for a SQLite database, or "" for
+// in-memory databases, which cannot be shared
+func lockPath(dbPath string) string {
+	if dbPath == "" || dbPath == ":memory:" || strings.HasPrefix(dbPath, "file:") {
+		return ""
+	}
+	return dbPath + ".lock"
+}
+
+// lockSQLite takes the single-writer lock for a SQLite database. The lock
+// file is left in place on close: removing it could let two processes lock
+// different files for the same database.
+func lockSQLite(dbPath string) (io.Closer, error) {
+	path := lockPath(dbPath)
+	if path == "" {
+		return nil, nil
+	}
+
+	lock, err := vfs.Default.Lock(path)
+	if err != nil {
+		return nil, lockError(dbPath, err)
+	}
+	return lock, nil
+}
+
+// lockPebble takes Pebble's own directory LOCK before opening, so contention
+// is reported as ErrLocked rather than an opaque pebble error
+func lockPebble(dir string) (*pebble.Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create pebble directory: %w", err)
+	}
+
+	lock, err := pebble.LockDirectory(dir, vfs.Default)
+	if err != nil {
+		return nil, lockError(dir, err)
+	}
+	return lock, nil
+}
+
+// lockError tells failures to create the lock file (permissions, missing
+// directories), which come back as path errors, from lock contention
+func lockError(path string, err error) error {
+	if pathErr := (*fs.PathError)(nil); errors.As(err, &pathErr) {
+		return fmt.Errorf("create lock file for %s: %w", path, err)
+	}
+	return fmt.Errorf("%w: %s (%v); only one ebuse process may use a data directory", ErrLocked, path, err)
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreLock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "events.db")
+
+	first, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if _, err := NewSQLiteStore(dbPath); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked for second open, got %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	second, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Expected reopen after close to succeed, got %v", err)
+	}
+	second.Close()
+}
+
+func TestPebbleStoreLock(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pebble")
+
+	first, err := NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if _, err := NewPebbleStore(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked for second open, got %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	second, err := NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("Expected reopen after close to succeed, got %v", err)
+	}
+	second.Close()
+}
+
+func TestLockFileErrors(t *testing.T) {
+	// A missing parent directory is not contention
+	_, err := NewSQLiteStore(filepath.Join(t.TempDir(), "missing", "events.db"))
+	if err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Expected a non-lock error, got %v", err)
+	}
+
+	if lockPath(":memory:") != "" || lockPath("file::memory:?cache=shared") != "" {
+		t.Error("Expected in-memory databases to skip locking")
+	}
+}
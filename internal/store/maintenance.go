@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// analysisLimit bounds the rows ANALYZE samples per index so statistics stay
+// cheap to refresh on large tables
+const analysisLimit = 1000
+
+// MaintenanceStats describes scheduled ANALYZE/optimize runs
+type MaintenanceStats struct {
+	Runs             int64         `json:"runs"`
+	RowsSinceAnalyze int64         `json:"rows_since_analyze"`
+	LastRun          time.Time     `json:"last_run,omitzero"`
+	LastDuration     time.Duration `json:"last_duration_ns"`
+	LastError        string        `json:"last_error,omitempty"`
+}
+
+// maintenance refreshes query planner statistics on a schedule and after
+// large imports
+type maintenance struct {
+	mu        sync.Mutex
+	afterRows int64
+	written   atomic.Int64
+	trigger   chan struct{}
+	stats     MaintenanceStats
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Analyze refreshes query planner statistics (ANALYZE with a bounded sample
+// size, then PRAGMA optimize) so the covering indexes keep being chosen as
+// the tables grow
+func (s *SQLiteStore) Analyze(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// analysis_limit is per connection, so pin one for the whole run
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	statements := []string{
+		fmt.Sprintf("PRAGMA analysis_limit=%d", analysisLimit),
+		"ANALYZE",
+		"PRAGMA optimize",
+	}
+	for _, stmt := range statements {
+		err := s.busy.retryBusy(ctx, func() error {
+			_, err := conn.ExecContext(ctx, stmt)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("execute %s: %w", stmt, err)
+		}
+	}
+
+	return nil
+}
+
+// StartMaintenance runs Analyze every interval, and early once afterRows
+// events have been written since the last run. Either trigger is disabled
+// when not positive. Maintenance stops when the store is closed.
+func (s *SQLiteStore) StartMaintenance(interval time.Duration, afterRows int64) {
+	if (interval <= 0 && afterRows <= 0) || s.maint != nil {
+		return
+	}
+
+	m := &maintenance{
+		afterRows: afterRows,
+		trigger:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	s.maint = m
+
+	go func() {
+		defer close(m.done)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-tick:
+			case <-m.trigger:
+			}
+			s.runMaintenance(m)
+		}
+	}()
+}
+
+// noteWrites counts written events towards the next row-triggered Analyze
+func (s *SQLiteStore) noteWrites(n int) {
+	m := s.maint
+	if m == nil || m.afterRows <= 0 {
+		return
+	}
+	if m.written.Add(int64(n)) >= m.afterRows {
+		select {
+		case m.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *SQLiteStore) runMaintenance(m *maintenance) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	rows := m.written.Swap(0)
+	start := time.Now()
+	err := s.Analyze(ctx)
+	duration := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.LastRun = start
+	m.stats.LastDuration = duration
+	if err != nil {
+		m.written.Add(rows)
+		m.stats.LastError = err.Error()
+		slog.Error("SQLite maintenance failed", "error", err)
+		return
+	}
+
+	m.stats.Runs++
+	m.stats.LastError = ""
+	slog.Info("SQLite statistics refreshed", "rows_since_analyze", rows, "duration_ms", duration.Milliseconds())
+}
+
+// MaintenanceStats returns scheduled maintenance counters
+func (s *SQLiteStore) MaintenanceStats() MaintenanceStats {
+	if s.maint == nil {
+		return MaintenanceStats{}
+	}
+
+	s.maint.mu.Lock()
+	stats := s.maint.stats
+	s.maint.mu.Unlock()
+	stats.RowsSinceAnalyze = s.maint.written.Load()
+	return stats
+}
+
+// stopMaintenance stops the maintenance goroutine, if running
+func (s *SQLiteStore) stopMaintenance() {
+	if s.maint == nil {
+		return
+	}
+	close(s.maint.stop)
+	<-s.maint.done
+}
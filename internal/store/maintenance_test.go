@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_Analyze(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "analyze.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	s.Save(ctx, &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+
+	if err := s.Analyze(ctx); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'events'").Scan(&n); err != nil {
+		t.Fatalf("Failed to read statistics: %v", err)
+	}
+	if n == 0 {
+		t.Error("Expected statistics for the events table")
+	}
+}
+
+func TestSQLiteStore_MaintenanceAfterRows(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "analyze.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	// Interval disabled: only the row threshold can trigger a run
+	s.StartMaintenance(0, 10)
+
+	ctx := context.Background()
+	events := make([]*StoredEvent, 10)
+	for i := range events {
+		events[i] = &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+	}
+	if err := s.SaveBatch(ctx, events); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.MaintenanceStats().Runs == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Maintenance was not triggered by writes")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := s.MaintenanceStats()
+	if stats.RowsSinceAnalyze != 0 || stats.LastRun.IsZero() || stats.LastError != "" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
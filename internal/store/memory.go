@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+)
+
+// errMemoryClosed is returned by a MemoryStore after Close
+var errMemoryClosed = errors.New("memory store is closed")
+
+// MemoryStore implements EventStore in process memory, for tests and
+// ephemeral servers. Events are lost on Close or exit.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	events        []*StoredEvent // In position order; imports may leave gaps
+	position      int64
+	streams       map[string][]int // Indexes into events, in version order
+	subscriptions map[string]int64
+	rollups       rollupDeltas // Events per minute and type
+	closed        bool
+
+	coldTier // Serves positions moved out of the store
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{streams: make(map[string][]int), subscriptions: make(map[string]int64), rollups: make(rollupDeltas)}
+}
+
+// copyEvent returns a copy of event that shares no maps or buffers with it,
+// so callers cannot modify stored events
+func copyEvent(event *StoredEvent) *StoredEvent {
+	c := *event
+	c.Data = append([]byte(nil), event.Data...)
+	if event.Metadata != nil {
+		c.Metadata = maps.Clone(event.Metadata)
+	}
+	return &c
+}
+
+// Save implements EventStore.Save
+func (s *MemoryStore) Save(ctx context.Context, event *StoredEvent) error {
+	return s.SaveBatch(ctx, []*StoredEvent{event})
+}
+
+// SaveBatch implements EventStore.SaveBatch
+func (s *MemoryStore) SaveBatch(ctx context.Context, events []*StoredEvent) error {
+	return s.SaveBatchIf(ctx, events, Expectation{})
+}
+
+// SaveBatchIf implements ConditionalAppender
+func (s *MemoryStore) SaveBatchIf(ctx context.Context, events []*StoredEvent, expect Expectation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errMemoryClosed
+	}
+	if len(events) == 0 {
+		return expect.Validate(events)
+	}
+	head := func() (int64, error) { return s.position, nil }
+	if err := expect.check(events, head, s.streamHead); err != nil {
+		return err
+	}
+	if err := streamVersions(events, s.streamHead); err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.position++
+		event.Position = s.position
+		s.append(event)
+	}
+	return nil
+}
+
+// append stores a copy of event, indexes it by stream and counts it
+func (s *MemoryStore) append(event *StoredEvent) {
+	if event.StreamID != "" && event.StreamVersion > 0 {
+		s.streams[event.StreamID] = append(s.streams[event.StreamID], len(s.events))
+	}
+	s.events = append(s.events, copyEvent(event))
+	s.rollups.add(event, 1)
+}
+
+// streamHead returns the version of the stream's last event
+func (s *MemoryStore) streamHead(streamID string) (int64, error) {
+	indexes := s.streams[streamID]
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+	return s.events[indexes[len(indexes)-1]].StreamVersion, nil
+}
+
+// ImportEvents implements Importer
+func (s *MemoryStore) ImportEvents(ctx context.Context, events []*StoredEvent) error {
+	return s.ImportAll(ctx, importBatches(events))
+}
+
+// ImportAll implements BulkImporter, appending the events once scan has
+// passed all of them
+func (s *MemoryStore) ImportAll(ctx context.Context, scan func(fn func([]*StoredEvent) error) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errMemoryClosed
+	}
+	var events []*StoredEvent
+	last := s.position
+	err := scan(func(batch []*StoredEvent) error {
+		if err := checkImportPositions(batch, last); err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			last = batch[len(batch)-1].Position
+		}
+		events = append(events, batch...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.append(event)
+	}
+	s.position = last
+	return nil
+}
+
+// index returns the index of the first event at or after position
+func (s *MemoryStore) index(position int64) int {
+	return sort.Search(len(s.events), func(i int) bool {
+		return s.events[i].Position >= position
+	})
+}
+
+// Load implements EventStore.Load
+func (s *MemoryStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent, error) {
+	if tier, ok := s.below(from); ok {
+		return loadCold(ctx, tier, from, to, s.Load)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, errMemoryClosed
+	}
+	events := []*StoredEvent{}
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return events, nil
+	}
+	for i := s.index(from); i < len(s.events); i++ {
+		event := s.events[i]
+		if to == -1 && len(events) == MaxLoadEvents || to != -1 && event.Position > to {
+			break
+		}
+		events = append(events, copyEvent(event))
+	}
+	return events, nil
+}
+
+// LoadStream implements EventStore.LoadStream. The lock is released while
+// handler runs, so handlers may write to the store.
+func (s *MemoryStore) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, sameEvent, handler, s.LoadStream)
+	}
+	batchSize = streamBatchSize(batchSize)
+
+	position := streamStart(from)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		if s.closed {
+			s.mu.RUnlock()
+			return errMemoryClosed
+		}
+		start := s.index(position)
+		end := min(start+batchSize, len(s.events))
+		batch := make([]*StoredEvent, 0, end-start)
+		for _, event := range s.events[start:end] {
+			batch = append(batch, copyEvent(event))
+		}
+		s.mu.RUnlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := handler(batch); err != nil {
+			return fmt.Errorf("handle batch: %w", err)
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		position = batch[len(batch)-1].Position + 1
+	}
+}
+
+// LoadByStream implements StreamIndex
+func (s *MemoryStore) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, errMemoryClosed
+	}
+	indexes := s.streams[streamID]
+	start := sort.Search(len(indexes), func(i int) bool {
+		return s.events[indexes[i]].StreamVersion >= fromVersion
+	})
+	indexes = indexes[start:min(start+streamLimit(limit), len(indexes))]
+
+	var events []*StoredEvent
+	for _, i := range indexes {
+		events = append(events, copyEvent(s.events[i]))
+	}
+	return events, nil
+}
+
+// StreamVersion implements StreamIndex
+func (s *MemoryStore) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, errMemoryClosed
+	}
+	return s.streamHead(streamID)
+}
+
+// GetPosition implements EventStore.GetPosition
+func (s *MemoryStore) GetPosition(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, errMemoryClosed
+	}
+	return s.position, nil
+}
+
+// SaveSubscriptionPosition implements EventStore.SaveSubscriptionPosition
+func (s *MemoryStore) SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errMemoryClosed
+	}
+	s.subscriptions[subscriptionID] = position
+	return nil
+}
+
+// LoadSubscriptionPosition implements EventStore.LoadSubscriptionPosition
+func (s *MemoryStore) LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, errMemoryClosed
+	}
+	return s.subscriptions[subscriptionID], nil
+}
+
+// Close implements EventStore.Close and discards all events
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.events = nil
+	s.streams = nil
+	s.subscriptions = nil
+	s.rollups = nil
+	return nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	st := NewMemoryStore()
+	ctx := context.Background()
+
+	event := &StoredEvent{
+		Type:      "UserCreated",
+		Data:      json.RawMessage(`{"name":"Ada"}`),
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{"source": "test"},
+	}
+	if err := st.Save(ctx, event); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if event.Position != 1 {
+		t.Errorf("expected position 1, got %d", event.Position)
+	}
+
+	batch := make([]*StoredEvent, 2500)
+	for i := range batch {
+		batch[i] = &StoredEvent{Type: "Tick", Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))}
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+	if batch[0].Position != 2 || batch[len(batch)-1].Position != 2501 {
+		t.Errorf("expected positions 2-2501, got %d-%d", batch[0].Position, batch[len(batch)-1].Position)
+	}
+	if head, _ := st.GetPosition(ctx); head != 2501 {
+		t.Errorf("expected head 2501, got %d", head)
+	}
+
+	// Stored events are copies: changing the saved or loaded event does not
+	// change the store
+	event.Metadata["source"] = "changed"
+	events, err := st.Load(ctx, 1, 3)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d, %v", len(events), err)
+	}
+	events[0].Data[2] = 'X'
+	events, _ = st.Load(ctx, 1, 1)
+	if string(events[0].Data) != `{"name":"Ada"}` || events[0].Metadata["source"] != "test" {
+		t.Errorf("stored event was modified through a copy: %+v", events[0])
+	}
+
+	var streamed int
+	err = st.LoadStream(ctx, 1, 1000, func(batch []*StoredEvent) error {
+		for _, e := range batch {
+			streamed++
+			if e.Position != int64(streamed) {
+				return fmt.Errorf("expected position %d, got %d", streamed, e.Position)
+			}
+		}
+		return nil
+	})
+	if err != nil || streamed != 2501 {
+		t.Errorf("expected 2501 streamed events, got %d, %v", streamed, err)
+	}
+
+	if pos, err := st.LoadSubscriptionPosition(ctx, "projector"); err != nil || pos != 0 {
+		t.Errorf("expected 0 for unknown subscription, got %d, %v", pos, err)
+	}
+	if err := st.SaveSubscriptionPosition(ctx, "projector", 20); err != nil {
+		t.Fatalf("SaveSubscriptionPosition failed: %v", err)
+	}
+	if pos, _ := st.LoadSubscriptionPosition(ctx, "projector"); pos != 20 {
+		t.Errorf("expected subscription at 20, got %d", pos)
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := st.Save(ctx, &StoredEvent{Type: "Late"}); err == nil {
+		t.Error("expected error saving to a closed store")
+	}
+}
+
+func TestMemoryStore_ImportEvents(t *testing.T) {
+	st := NewMemoryStore()
+	ctx := context.Background()
+
+	imported := []*StoredEvent{
+		{Position: 1, Type: "A", Data: json.RawMessage(`{}`)},
+		{Position: 4, Type: "B", Data: json.RawMessage(`{}`)},
+	}
+	if err := st.ImportEvents(ctx, imported); err != nil {
+		t.Fatalf("ImportEvents failed: %v", err)
+	}
+	if events, _ := st.Load(ctx, 2, 4); len(events) != 1 || events[0].Type != "B" {
+		t.Errorf("expected only position 4 in 2-4, got %d events", len(events))
+	}
+
+	event := &StoredEvent{Type: "C", Data: json.RawMessage(`{}`)}
+	if err := st.Save(ctx, event); err != nil || event.Position != 5 {
+		t.Errorf("expected the next save at 5, got %d, %v", event.Position, err)
+	}
+	if err := st.ImportEvents(ctx, []*StoredEvent{{Position: 2, Type: "Late"}}); err == nil {
+		t.Error("expected error for a position below the head")
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Migration reports a completed Migrate
+type Migration struct {
+	Events        int64  `json:"events"`
+	Head          int64  `json:"head"`
+	Subscriptions int    `json:"subscriptions"`
+	Digest        string `json:"digest,omitempty"` // Of every event, equal in both stores; empty unless verified
+}
+
+// Migrate copies src into dst, an empty store of any backend: every event
+// at its position, a start position if src has no events after it, and the
+// position of every subscription, so consumers resume in dst where they
+// left off in src. With verify, both stores are digested afterwards and
+// must match. src must not be written to meanwhile.
+func Migrate(ctx context.Context, src, dst EventStore, batchSize int, verify bool) (Migration, error) {
+	var m Migration
+	importer, ok := As[Importer](dst)
+	if !ok {
+		return m, fmt.Errorf("%T cannot import events", dst)
+	}
+	if head, err := dst.GetPosition(ctx); err != nil || head != 0 {
+		if err == nil {
+			err = fmt.Errorf("destination is not empty (position %d)", head)
+		}
+		return m, err
+	}
+	head, err := src.GetPosition(ctx)
+	if err != nil {
+		return m, fmt.Errorf("get source position: %w", err)
+	}
+	m.Head = head
+
+	err = src.LoadStream(ctx, 1, batchSize, func(events []*StoredEvent) error {
+		if len(events) == 0 {
+			return nil
+		}
+		if err := importer.ImportEvents(ctx, events); err != nil {
+			return err
+		}
+		m.Events += int64(len(events))
+		return nil
+	})
+	if err != nil {
+		return m, fmt.Errorf("copy events: %w", err)
+	}
+	// A store started after a position, without events since, keeps it
+	if m.Events == 0 && head > 0 {
+		if _, err := StartAt(ctx, dst, head); err != nil {
+			return m, fmt.Errorf("copy start position: %w", err)
+		}
+	}
+	if got, err := dst.GetPosition(ctx); err != nil || got != head {
+		if err == nil {
+			err = fmt.Errorf("destination is at position %d, the source at %d; was the source written to?", got, head)
+		}
+		return m, err
+	}
+
+	if lister, ok := As[SubscriptionLister](src); ok {
+		positions, err := lister.Subscriptions(ctx)
+		if err != nil {
+			return m, fmt.Errorf("list subscriptions: %w", err)
+		}
+		for id, position := range positions {
+			if err := dst.SaveSubscriptionPosition(ctx, id, position); err != nil {
+				return m, fmt.Errorf("copy subscription %s: %w", id, err)
+			}
+		}
+		m.Subscriptions = len(positions)
+	}
+
+	if verify && m.Events > 0 {
+		want, err := DigestRanges(ctx, src, 1, head, 1)
+		if err != nil {
+			return m, fmt.Errorf("digest source: %w", err)
+		}
+		got, err := DigestRanges(ctx, dst, 1, head, 1)
+		if err != nil {
+			return m, fmt.Errorf("digest destination: %w", err)
+		}
+		if got[0] != want[0] {
+			return m, errors.New("destination does not match the source: digests differ")
+		}
+		m.Digest = got[0].Hash
+	}
+	return m, nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	src, err := NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	for i := range 25 {
+		event := &StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(`{"n":1}`), Timestamp: time.Now()}
+		if i%5 == 0 {
+			event.StreamID, event.StreamVersion = "order-1", int64(i/5+1)
+		}
+		if err := src.Save(ctx, event); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := src.SaveSubscriptionPosition(ctx, "billing", 20); err != nil {
+		t.Fatalf("SaveSubscriptionPosition failed: %v", err)
+	}
+
+	dst, err := NewPebbleStore(t.TempDir() + "/data")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer dst.Close()
+
+	m, err := Migrate(ctx, src, dst, 10, true)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if m.Events != 25 || m.Head != 25 || m.Subscriptions != 1 || m.Digest == "" {
+		t.Errorf("unexpected report %+v", m)
+	}
+	if position, _ := dst.LoadSubscriptionPosition(ctx, "billing"); position != 20 {
+		t.Errorf("expected subscription at 20, got %d", position)
+	}
+	stream, err := dst.LoadByStream(ctx, "order-1", 1, 10)
+	if err != nil || len(stream) != 5 || stream[4].Position != 21 {
+		t.Errorf("expected stream positions to be preserved, got %d events (%v)", len(stream), err)
+	}
+
+	// Migrating into a store with events is refused
+	if _, err := Migrate(ctx, src, dst, 10, false); err == nil {
+		t.Error("expected migrating into a non-empty store to fail")
+	}
+}
+
+func TestMigrate_StartPosition(t *testing.T) {
+	src := NewMemoryStore()
+	dst := NewMemoryStore()
+	ctx := context.Background()
+	if _, err := StartAt(ctx, src, 500); err != nil {
+		t.Fatalf("StartAt failed: %v", err)
+	}
+
+	m, err := Migrate(ctx, src, dst, 10, true)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if m.Events != 0 || m.Head != 500 {
+		t.Errorf("unexpected report %+v", m)
+	}
+	if head, _ := dst.GetPosition(ctx); head != 500 {
+		t.Errorf("expected the start position to be copied, got head %d", head)
+	}
+}
@@ -0,0 +1,45 @@
+package store
+
+// Load and LoadStream behave the same on every backend:
+//
+//   - Bounds are inclusive: Load(ctx, 3, 5) returns positions 3 to 5.
+//   - Positions start at 1, so from <= 0 reads from the first event.
+//   - to == -1 reads up to MaxLoadEvents events starting at from.
+//   - Any other to below from is an empty range.
+//   - Empty results are empty, non-nil slices (JSON "[]", not "null").
+//   - LoadStream uses DefaultStreamBatchSize for batchSize <= 0, never
+//     calls its handler with an empty batch and wraps handler errors.
+//
+// Stores normalize their arguments with loadRange and streamStart instead of
+// interpreting them on their own.
+
+// MaxLoadEvents caps open-ended Load calls (to == -1); use LoadStream to
+// read further
+const MaxLoadEvents = 10000
+
+// DefaultStreamBatchSize is the LoadStream batch size when none is given
+const DefaultStreamBatchSize = 1000
+
+// loadRange normalizes the arguments of Load. It returns the first position
+// to read, the last one (-1 for an open range of up to MaxLoadEvents events)
+// and whether the range can contain events at all.
+func loadRange(from, to int64) (int64, int64, bool) {
+	from = streamStart(from)
+	if to == -1 {
+		return from, -1, true
+	}
+	return from, to, to >= from
+}
+
+// streamStart normalizes the first position of LoadStream
+func streamStart(from int64) int64 {
+	return max(from, 1)
+}
+
+// streamBatchSize normalizes the batch size of LoadStream
+func streamBatchSize(batchSize int) int {
+	if batchSize <= 0 {
+		return DefaultStreamBatchSize
+	}
+	return batchSize
+}
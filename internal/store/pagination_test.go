@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// paginationStores returns every store that runs without external services,
+// each holding events at positions 1 to n
+func paginationStores(t *testing.T, n int) map[string]EventStore {
+	t.Helper()
+
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/pagination.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	t.Cleanup(func() { sqliteStore.Close() })
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/pagination")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	t.Cleanup(func() { pebbleStore.Close() })
+
+	stores := map[string]EventStore{"sqlite": sqliteStore, "pebble": pebbleStore, "memory": NewMemoryStore()}
+	for name, st := range stores {
+		for saved := 0; saved < n; saved += 1000 {
+			batch := make([]*StoredEvent, min(1000, n-saved))
+			for i := range batch {
+				batch[i] = &StoredEvent{Type: "Test", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+			}
+			if err := st.SaveBatch(context.Background(), batch); err != nil {
+				t.Fatalf("%s: SaveBatch failed: %v", name, err)
+			}
+		}
+	}
+	return stores
+}
+
+func TestLoad_Semantics(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		from, to    int64
+		first, last int64 // Expected positions; 0 for an empty result
+	}{
+		{"inclusive bounds", 3, 5, 3, 5},
+		{"single position", 7, 7, 7, 7},
+		{"from zero", 0, 2, 1, 2},
+		{"negative from", -5, 2, 1, 2},
+		{"to past head", 10, 1000, 10, 20},
+		{"open range", 15, -1, 15, 20},
+		{"to at max position", 18, math.MaxInt64, 18, 20},
+		{"open range from zero", 0, -1, 1, 20},
+		{"from past head", 21, 30, 0, 0},
+		{"open range past head", 21, -1, 0, 0},
+		{"to before from", 5, 4, 0, 0},
+		{"negative to", 1, -2, 0, 0},
+	}
+
+	for name, st := range paginationStores(t, 20) {
+		for _, tt := range tests {
+			events, err := st.Load(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("%s: %s: Load failed: %v", name, tt.name, err)
+			}
+			if events == nil {
+				t.Errorf("%s: %s: expected a non-nil slice", name, tt.name)
+			}
+
+			var first, last int64
+			if len(events) > 0 {
+				first, last = events[0].Position, events[len(events)-1].Position
+			}
+			if first != tt.first || last != tt.last || (first != 0 && int64(len(events)) != last-first+1) {
+				t.Errorf("%s: %s: expected %d-%d, got %d events at %d-%d", name, tt.name, tt.first, tt.last, len(events), first, last)
+			}
+		}
+	}
+}
+
+func TestLoad_OpenRangeLimit(t *testing.T) {
+	for name, st := range paginationStores(t, MaxLoadEvents+5) {
+		events, err := st.Load(context.Background(), 3, -1)
+		if err != nil {
+			t.Fatalf("%s: Load failed: %v", name, err)
+		}
+		if len(events) != MaxLoadEvents || events[0].Position != 3 {
+			t.Errorf("%s: expected %d events from 3, got %d", name, MaxLoadEvents, len(events))
+		}
+
+		// Explicit ranges are not capped
+		if events, _ := st.Load(context.Background(), 1, MaxLoadEvents+5); len(events) != MaxLoadEvents+5 {
+			t.Errorf("%s: expected %d events, got %d", name, MaxLoadEvents+5, len(events))
+		}
+	}
+}
+
+func TestLoadStream_Semantics(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		from      int64
+		batchSize int
+		batches   string
+	}{
+		{1, 4, "[1-4 5-8 9-10]"},
+		{0, 5, "[1-5 6-10]"},
+		{-3, 0, "[1-10]"},
+		{8, 1000, "[8-10]"},
+		{11, 2, "[]"},
+	}
+
+	for name, st := range paginationStores(t, 10) {
+		for _, tt := range tests {
+			var batches []string
+			err := st.LoadStream(ctx, tt.from, tt.batchSize, func(batch []*StoredEvent) error {
+				batches = append(batches, fmt.Sprintf("%d-%d", batch[0].Position, batch[len(batch)-1].Position))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("%s: LoadStream failed: %v", name, err)
+			}
+			if got := fmt.Sprint(batches); got != tt.batches {
+				t.Errorf("%s: from %d by %d: expected batches %s, got %s", name, tt.from, tt.batchSize, tt.batches, got)
+			}
+		}
+
+		errStop := errors.New("stop")
+		err := st.LoadStream(ctx, 1, 3, func([]*StoredEvent) error { return errStop })
+		if !errors.Is(err, errStop) {
+			t.Errorf("%s: expected the handler error, got %v", name, err)
+		}
+	}
+}
@@ -5,17 +5,65 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
 )
 
 // PebbleStore implements EventStore using PebbleDB (LSM-tree based key-value store)
 type PebbleStore struct {
 	db       *pebble.DB
-	mu       sync.RWMutex
+	mu       sync.RWMutex // Held shared by appends, exclusively by appends expecting a position
 	position atomic.Int64 // Atomic counter for event positions
+	streamMu sync.Mutex   // Serializes writes of events with a StreamID
+	rollupMu sync.Mutex   // Serializes updates of the throughput rollups
+
+	iterStats  iteratorStats // Accumulated stats of streaming iterators
+	compaction compactionJob // Operator-triggered compaction
+	lock       *pebble.Lock  // Directory lock, released after the db closes
+	recovery   recoveryState // Open marker and the report of Recover
+
+	coldTier // Serves positions moved out of the store
+}
+
+// IteratorStats summarizes the work done by streaming iterators
+type IteratorStats struct {
+	Streams           int64  // Streams served
+	Seeks             int64  // Forward seeks (one per stream when iterators are reused)
+	Steps             int64  // Forward steps across events
+	BlockBytes        uint64 // Bytes of sstable blocks loaded
+	BlockBytesInCache uint64 // Subset of BlockBytes served from the block cache
+	BlockReadDuration time.Duration
+}
+
+type iteratorStats struct {
+	mu    sync.Mutex
+	stats IteratorStats
+}
+
+func (is *iteratorStats) record(s pebble.IteratorStats) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.stats.Streams++
+	is.stats.Seeks += int64(s.ForwardSeekCount[pebble.InterfaceCall])
+	is.stats.Steps += int64(s.ForwardStepCount[pebble.InterfaceCall])
+	is.stats.BlockBytes += s.InternalStats.BlockBytes
+	is.stats.BlockBytesInCache += s.InternalStats.BlockBytesInCache
+	is.stats.BlockReadDuration += s.InternalStats.BlockReadDuration
+}
+
+// IteratorStats returns the accumulated stats of LoadStream iterators
+func (s *PebbleStore) IteratorStats() IteratorStats {
+	s.iterStats.mu.Lock()
+	defer s.iterStats.mu.Unlock()
+	return s.iterStats.stats
 }
 
 // Key prefixes for different data types
@@ -23,8 +71,26 @@ const (
 	eventPrefix        = byte(0x01) // event:<position> -> event data
 	positionKey        = "meta:position"
 	subscriptionPrefix = byte(0x02) // sub:<subscription_id> -> position
+	streamPrefix       = byte(0x03) // stream:<id length><stream_id><version> -> position
+	typePrefix         = byte(0x04) // type:<type length><type><position> -> empty
+	timePrefix         = byte(0x05) // time:<unix nanos><position> -> empty
+	rollupPrefix       = byte(0x06) // rollup:<minute><type> -> count
 )
 
+// typeIndexKey and timeIndexKey are present once every event is in the
+// type and time index
+const (
+	typeIndexKey = "meta:type_index"
+	timeIndexKey = "meta:time_index"
+)
+
+// rollupIndexKey is present once every event is counted in the rollups
+const rollupIndexKey = "meta:rollups"
+
+// pebbleL0StopWrites is the number of L0 sublevels at which Pebble stalls
+// writes until compactions catch up
+const pebbleL0StopWrites = 20
+
 // NewPebbleStore creates a new PebbleDB-based event store
 func NewPebbleStore(dbPath string) (*PebbleStore, error) {
 	opts := &pebble.Options{
@@ -32,7 +98,7 @@ func NewPebbleStore(dbPath string) (*PebbleStore, error) {
 		MemTableSize:                128 << 20, // 128MB memtable (larger buffer)
 		MemTableStopWritesThreshold: 8,         // More memtables before blocking
 		L0CompactionThreshold:       4,         // More files before compaction
-		L0StopWritesThreshold:       20,        // Higher threshold
+		L0StopWritesThreshold:       pebbleL0StopWrites,
 		LBaseMaxBytes:               512 << 20, // 512MB
 		MaxOpenFiles:                1000,
 
@@ -44,20 +110,57 @@ func NewPebbleStore(dbPath string) (*PebbleStore, error) {
 		DisableWAL: false, // Keep WAL for durability
 	}
 
+	// Replays read sstables front to back, so ask the OS to read ahead
+	opts.Local.ReadaheadConfigFn = func() pebble.ReadaheadConfig {
+		return pebble.ReadaheadConfig{
+			Informed:    objstorageprovider.FadviseSequential,
+			Speculative: objstorageprovider.FadviseSequential,
+		}
+	}
+
+	lock, err := lockPebble(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	opts.Lock = lock
+
 	db, err := pebble.Open(dbPath, opts)
 	if err != nil {
+		lock.Close()
 		return nil, fmt.Errorf("open pebble db: %w", err)
 	}
 
 	s := &PebbleStore{
-		db: db,
+		db:   db,
+		lock: lock,
 	}
 
 	// Initialize position counter from existing data
 	if err := s.initializePosition(); err != nil {
 		db.Close()
+		lock.Close()
 		return nil, fmt.Errorf("initialize position: %w", err)
 	}
+	if err := s.indexTypes(); err != nil {
+		db.Close()
+		lock.Close()
+		return nil, fmt.Errorf("build type index: %w", err)
+	}
+	if err := s.buildIndex(timeIndexKey, "time", setTimeKey); err != nil {
+		db.Close()
+		lock.Close()
+		return nil, fmt.Errorf("build time index: %w", err)
+	}
+	if err := s.buildRollups(); err != nil {
+		db.Close()
+		lock.Close()
+		return nil, fmt.Errorf("build rollups: %w", err)
+	}
+	if err := s.recovery.markOpen(filepath.Join(dbPath, pebbleOpenMarker)); err != nil {
+		db.Close()
+		lock.Close()
+		return nil, err
+	}
 
 	return s, nil
 }
@@ -80,8 +183,82 @@ func (s *PebbleStore) initializePosition() error {
 			pos := int64(binary.BigEndian.Uint64(key[1:]))
 			s.position.Store(pos)
 		}
+		return nil
 	}
 
+	// Fresh stores report their start position, if one was set
+	value, closer, err := s.db.Get([]byte(positionKey))
+	if err == pebble.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	s.position.Store(int64(binary.BigEndian.Uint64(value)))
+	return nil
+}
+
+// buildIndex adds every event to an index introduced after the store was
+// written, through set. Stores that have the index are marked with marker.
+func (s *PebbleStore) buildIndex(marker, name string, set func(*pebble.Batch, *StoredEvent) error) error {
+	_, closer, err := s.db.Get([]byte(marker))
+	if err == nil {
+		return closer.Close()
+	}
+	if err != pebble.ErrNotFound {
+		return fmt.Errorf("read %s index marker: %w", name, err)
+	}
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte{eventPrefix},
+		UpperBound: []byte{eventPrefix + 1},
+	})
+	if err != nil {
+		return fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	batch := s.db.NewBatch()
+	defer func() { batch.Close() }()
+
+	indexed := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		// Indexes only need these fields, which spares decoding the data
+		var event struct {
+			Position  int64     `json:"position"`
+			Type      string    `json:"type"`
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			return fmt.Errorf("unmarshal event: %w", err)
+		}
+		if err := set(batch, &StoredEvent{Position: event.Position, Type: event.Type, Timestamp: event.Timestamp}); err != nil {
+			return err
+		}
+		indexed++
+
+		if batch.Count() >= 10000 {
+			if err := batch.Commit(pebble.NoSync); err != nil {
+				return fmt.Errorf("commit %s index: %w", name, err)
+			}
+			batch.Close()
+			batch = s.db.NewBatch()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	if err := batch.Set([]byte(marker), []byte{1}, nil); err != nil {
+		return fmt.Errorf("batch set %s index marker: %w", name, err)
+	}
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return fmt.Errorf("commit %s index: %w", name, err)
+	}
+	if indexed > 0 {
+		slog.Info("Built event "+name+" index", "events", indexed)
+	}
 	return nil
 }
 
@@ -92,6 +269,16 @@ func eventKey(position int64) []byte {
 	return key
 }
 
+// eventUpperBound returns the exclusive iterator bound for events up to
+// position to. Open ranges (to == -1) and math.MaxInt64, where to+1 would
+// overflow, run to the end of the event keyspace.
+func eventUpperBound(to int64) []byte {
+	if to == -1 || to == math.MaxInt64 {
+		return []byte{eventPrefix + 1}
+	}
+	return eventKey(to + 1)
+}
+
 func subscriptionKey(subscriptionID string) []byte {
 	key := make([]byte, 1+len(subscriptionID))
 	key[0] = subscriptionPrefix
@@ -101,6 +288,14 @@ func subscriptionKey(subscriptionID string) []byte {
 
 // Save implements EventStore.Save
 func (s *PebbleStore) Save(ctx context.Context, event *StoredEvent) error {
+	if event.StreamID != "" {
+		return s.SaveBatch(ctx, []*StoredEvent{event})
+	}
+	event.StreamVersion = 0
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	// Assign next position atomically
 	position := s.position.Add(1)
 	event.Position = position
@@ -111,8 +306,23 @@ func (s *PebbleStore) Save(ctx context.Context, event *StoredEvent) error {
 		return fmt.Errorf("marshal event: %w", err)
 	}
 
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	if err := batch.Set(eventKey(position), data, nil); err != nil {
+		return fmt.Errorf("batch set: %w", err)
+	}
+	if err := setTypeKey(batch, event); err != nil {
+		return err
+	}
+	if err := setTimeKey(batch, event); err != nil {
+		return err
+	}
+	rollups := rollupDeltas{}
+	rollups.add(event, 1)
+
 	// Write to PebbleDB (NoSync for performance, WAL provides durability)
-	if err := s.db.Set(eventKey(position), data, pebble.NoSync); err != nil {
+	if err := s.commitWithRollups(batch, rollups, pebble.NoSync); err != nil {
 		return fmt.Errorf("write event: %w", err)
 	}
 
@@ -121,16 +331,49 @@ func (s *PebbleStore) Save(ctx context.Context, event *StoredEvent) error {
 
 // SaveBatch saves multiple events in a single batch for better performance
 func (s *PebbleStore) SaveBatch(ctx context.Context, events []*StoredEvent) error {
+	return s.SaveBatchIf(ctx, events, Expectation{})
+}
+
+// SaveBatchIf implements ConditionalAppender. Checking the head requires
+// that no other append is in flight, so appends expecting a position take
+// the append lock exclusively.
+func (s *PebbleStore) SaveBatchIf(ctx context.Context, events []*StoredEvent, expect Expectation) error {
 	if len(events) == 0 {
-		return nil
+		return expect.Validate(events)
+	}
+
+	if expect.Position != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	// Versions are read from the index, so stream writes must not overlap
+	// between reading the heads and committing
+	if slices.ContainsFunc(events, func(e *StoredEvent) bool { return e.StreamID != "" }) {
+		s.streamMu.Lock()
+		defer s.streamMu.Unlock()
+	}
+	head := func() (int64, error) { return s.position.Load(), nil }
+	if err := expect.check(events, head, s.streamHead); err != nil {
+		return err
+	}
+	if err := streamVersions(events, s.streamHead); err != nil {
+		return err
 	}
 
 	batch := s.db.NewBatch()
 	defer batch.Close()
 
-	for _, event := range events {
-		// Assign next position atomically
-		position := s.position.Add(1)
+	// Reserve the whole range at once, so batches appended concurrently
+	// under the shared lock never interleave
+	first := s.position.Add(int64(len(events))) - int64(len(events)) + 1
+
+	rollups := rollupDeltas{}
+	for i, event := range events {
+		position := first + int64(i)
 		event.Position = position
 
 		// Serialize event
@@ -143,10 +386,20 @@ func (s *PebbleStore) SaveBatch(ctx context.Context, events []*StoredEvent) erro
 		if err := batch.Set(eventKey(position), data, nil); err != nil {
 			return fmt.Errorf("batch set: %w", err)
 		}
+		if err := setStreamKey(batch, event); err != nil {
+			return err
+		}
+		if err := setTypeKey(batch, event); err != nil {
+			return err
+		}
+		if err := setTimeKey(batch, event); err != nil {
+			return err
+		}
+		rollups.add(event, 1)
 	}
 
 	// Commit batch without forcing fsync (WAL provides durability)
-	if err := batch.Commit(pebble.NoSync); err != nil {
+	if err := s.commitWithRollups(batch, rollups, pebble.NoSync); err != nil {
 		return fmt.Errorf("commit batch: %w", err)
 	}
 
@@ -155,18 +408,30 @@ func (s *PebbleStore) SaveBatch(ctx context.Context, events []*StoredEvent) erro
 
 // Load implements EventStore.Load
 func (s *PebbleStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent, error) {
-	var events []*StoredEvent
+	if tier, ok := s.below(from); ok {
+		return loadCold(ctx, tier, from, to, s.Load)
+	}
+	events := []*StoredEvent{}
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return events, nil
+	}
+
+	limit := -1
+	if to == -1 {
+		limit = MaxLoadEvents
+	}
 
 	iter, err := s.db.NewIter(&pebble.IterOptions{
 		LowerBound: eventKey(from),
-		UpperBound: eventKey(to + 1), // Exclusive upper bound
+		UpperBound: eventUpperBound(to),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create iterator: %w", err)
 	}
 	defer iter.Close()
 
-	for iter.First(); iter.Valid(); iter.Next() {
+	for iter.First(); iter.Valid() && len(events) != limit; iter.Next() {
 		var event StoredEvent
 		if err := json.Unmarshal(iter.Value(), &event); err != nil {
 			return nil, fmt.Errorf("unmarshal event: %w", err)
@@ -183,6 +448,62 @@ func (s *PebbleStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent,
 
 // LoadStream implements EventStore.LoadStream for efficient streaming
 func (s *PebbleStore) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, sameEvent, handler, s.LoadStream)
+	}
+	return streamEvents(ctx, s, from, batchSize, func(value []byte) (*StoredEvent, error) {
+		var event StoredEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return nil, fmt.Errorf("unmarshal event: %w", err)
+		}
+		return &event, nil
+	}, handler)
+}
+
+// LoadStreamRaw implements RawStreamer. Pebble values already hold the
+// event's JSON encoding, so they are passed through without decoding.
+func (s *PebbleStore) LoadStreamRaw(ctx context.Context, from int64, batchSize int, handler func([]json.RawMessage) error) error {
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, rawEvent, handler, s.LoadStreamRaw)
+	}
+	return streamEvents(ctx, s, from, batchSize, func(value []byte) (json.RawMessage, error) {
+		// Iterator values are only valid until the next step, so copy
+		return append(json.RawMessage(nil), value...), nil
+	}, handler)
+}
+
+// streamEvents reads events from a single iterator that stays open for the
+// whole stream, so batch boundaries never re-seek. Reading runs one batch
+// ahead of the handler in a separate goroutine, overlapping disk reads and
+// decoding with the handler's work.
+func streamEvents[T any](ctx context.Context, s *PebbleStore, from int64, batchSize int, decode func([]byte) (T, error), handler func([]T) error) error {
+	from, batchSize = streamStart(from), streamBatchSize(batchSize)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan []T, 1)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(batches)
+		readErr <- readBatches(ctx, s, from, batchSize, decode, batches)
+	}()
+
+	for batch := range batches {
+		if err := handler(batch); err != nil {
+			cancel()
+			for range batches {
+				// Drain so the reader can exit
+			}
+			<-readErr
+			return fmt.Errorf("handle batch: %w", err)
+		}
+	}
+
+	return <-readErr
+}
+
+func readBatches[T any](ctx context.Context, s *PebbleStore, from int64, batchSize int, decode func([]byte) (T, error), out chan<- []T) error {
 	iter, err := s.db.NewIter(&pebble.IterOptions{
 		LowerBound: eventKey(from),
 		UpperBound: []byte{eventPrefix + 1},
@@ -190,40 +511,49 @@ func (s *PebbleStore) LoadStream(ctx context.Context, from int64, batchSize int,
 	if err != nil {
 		return fmt.Errorf("create iterator: %w", err)
 	}
-	defer iter.Close()
-
-	batch := make([]*StoredEvent, 0, batchSize)
+	defer func() {
+		s.iterStats.record(iter.Stats())
+		iter.Close()
+	}()
 
-	for iter.First(); iter.Valid(); iter.Next() {
+	send := func(batch []T) error {
 		select {
+		case out <- batch:
+			return nil
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
 		}
+	}
 
-		var event StoredEvent
-		if err := json.Unmarshal(iter.Value(), &event); err != nil {
-			return fmt.Errorf("unmarshal event: %w", err)
+	batch := make([]T, 0, batchSize)
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		batch = append(batch, &event)
+		item, err := decode(iter.Value())
+		if err != nil {
+			return err
+		}
+		batch = append(batch, item)
 
 		if len(batch) >= batchSize {
-			if err := handler(batch); err != nil {
+			if err := send(batch); err != nil {
 				return err
 			}
-			batch = batch[:0] // Reset slice
+			// Handlers may retain batches, so never reuse the backing array
+			batch = make([]T, 0, batchSize)
 		}
 	}
 
-	// Handle remaining events
-	if len(batch) > 0 {
-		if err := handler(batch); err != nil {
-			return err
-		}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
 	}
 
-	return iter.Error()
+	if len(batch) > 0 {
+		return send(batch)
+	}
+	return nil
 }
 
 // GetPosition implements EventStore.GetPosition
@@ -264,5 +594,13 @@ func (s *PebbleStore) LoadSubscriptionPosition(ctx context.Context, subscription
 
 // Close implements EventStore.Close
 func (s *PebbleStore) Close() error {
-	return s.db.Close()
+	s.stopCompaction()
+	err := s.db.Close()
+	if err == nil {
+		err = s.recovery.markClosed()
+	}
+	if lockErr := s.lock.Close(); err == nil {
+		err = lockErr
+	}
+	return err
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 )
 
@@ -142,3 +143,73 @@ func TestPebbleStore_SubscriptionPosition(t *testing.T) {
 		t.Errorf("expected position 0 for non-existent subscription, got %d", pos)
 	}
 }
+
+func TestPebbleStore_LoadStreamRetainedBatches(t *testing.T) {
+	store, err := NewPebbleStore(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		if err := store.Save(ctx, &StoredEvent{Type: "Event", Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	var batches [][]*StoredEvent
+	err = store.LoadStream(ctx, 1, 10, func(batch []*StoredEvent) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream failed: %v", err)
+	}
+
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 batches, got %d", len(batches))
+	}
+	// Retained batches must not be overwritten by later ones
+	if batches[0][0].Position != 1 || batches[1][0].Position != 11 || batches[2][4].Position != 25 {
+		t.Errorf("retained batches were overwritten: %d, %d, %d",
+			batches[0][0].Position, batches[1][0].Position, batches[2][4].Position)
+	}
+
+	stats := store.IteratorStats()
+	if stats.Streams != 1 {
+		t.Errorf("expected 1 stream, got %d", stats.Streams)
+	}
+	if stats.Seeks != 1 {
+		t.Errorf("expected a single seek across all batches, got %d", stats.Seeks)
+	}
+	if stats.Steps < 24 {
+		t.Errorf("expected at least 24 steps, got %d", stats.Steps)
+	}
+}
+
+func TestPebbleStore_LoadStreamHandlerError(t *testing.T) {
+	store, err := NewPebbleStore(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		store.Save(ctx, &StoredEvent{Type: "Event", Data: json.RawMessage(`{}`)})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = store.LoadStream(ctx, 1, 5, func(batch []*StoredEvent) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to be called once, got %d", calls)
+	}
+}
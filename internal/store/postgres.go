@@ -0,0 +1,471 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PostgresDriver is the database/sql driver used for Postgres. The binary
+// registers it by importing github.com/jackc/pgx/v5/stdlib.
+const PostgresDriver = "pgx"
+
+// DefaultPostgresMaxConns is the pool size when OpenPostgres gets zero
+const DefaultPostgresMaxConns = 10
+
+// postgresMigrations brings a schema up to date; migration i+1 is recorded
+// in schema_migrations once applied. Append only.
+var postgresMigrations = [][]string{
+	{
+		`CREATE TABLE %[1]s.events (
+			position BIGINT PRIMARY KEY,
+			type TEXT NOT NULL,
+			data BYTEA NOT NULL,
+			timestamp TIMESTAMPTZ NOT NULL,
+			metadata TEXT
+		)`,
+		`CREATE INDEX events_type_position ON %[1]s.events (type, position)`,
+		`CREATE INDEX events_timestamp ON %[1]s.events (timestamp)`,
+		`CREATE TABLE %[1]s.subscriptions (
+			subscription_id TEXT PRIMARY KEY,
+			position BIGINT NOT NULL
+		)`,
+	},
+	{
+		`ALTER TABLE %[1]s.events ADD COLUMN stream_id TEXT, ADD COLUMN stream_version BIGINT`,
+		`CREATE UNIQUE INDEX events_stream ON %[1]s.events (stream_id, stream_version) WHERE stream_id IS NOT NULL`,
+	},
+	{
+		`CREATE TABLE %[1]s.start_position (head BIGINT NOT NULL)`,
+	},
+	{
+		// Events per minute and type, kept in step with events by a trigger
+		`CREATE TABLE %[1]s.rollups (
+			minute BIGINT NOT NULL,
+			type TEXT NOT NULL,
+			count BIGINT NOT NULL,
+			PRIMARY KEY (minute, type)
+		)`,
+		`CREATE FUNCTION %[1]s.rollup_events() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' THEN
+				UPDATE %[1]s.rollups SET count = count - 1
+					WHERE minute = FLOOR(EXTRACT(EPOCH FROM OLD.timestamp) / 60)::BIGINT AND type = OLD.type;
+			END IF;
+			INSERT INTO %[1]s.rollups (minute, type, count) VALUES (FLOOR(EXTRACT(EPOCH FROM NEW.timestamp) / 60)::BIGINT, NEW.type, 1)
+				ON CONFLICT (minute, type) DO UPDATE SET count = rollups.count + 1;
+			RETURN NULL;
+		END
+		$$`,
+		`CREATE TRIGGER events_rollup AFTER INSERT OR UPDATE OF type, timestamp ON %[1]s.events
+			FOR EACH ROW EXECUTE FUNCTION %[1]s.rollup_events()`,
+		`INSERT INTO %[1]s.rollups (minute, type, count)
+			SELECT FLOOR(EXTRACT(EPOCH FROM timestamp) / 60)::BIGINT, type, COUNT(*) FROM %[1]s.events GROUP BY 1, 2`,
+	},
+}
+
+// PostgresStore implements EventStore on a Postgres schema. Writers are
+// serialized by a transaction-scoped advisory lock, so positions are
+// assigned and become visible in order, without gaps, even with several
+// servers writing to the same schema.
+type PostgresStore struct {
+	db     *sql.DB
+	schema string // Quoted identifier
+
+	saveQuery       string
+	loadQuery       string
+	loadRangeQuery  string
+	positionQuery   string
+	saveSubQuery    string
+	loadSubQuery    string
+	streamQuery     string
+	streamHeadQuery string
+	typesQuery      string
+	lockKey         string // Advisory lock name for writers
+
+	coldTier // Serves positions moved out of the store
+}
+
+// OpenPostgres opens a connection pool for Postgres stores; stores of
+// several tenants can share one pool. maxConns <= 0 uses
+// DefaultPostgresMaxConns.
+func OpenPostgres(dsn string, maxConns int) (*sql.DB, error) {
+	if maxConns <= 0 {
+		maxConns = DefaultPostgresMaxConns
+	}
+
+	db, err := sql.Open(PostgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxLifetime(30 * time.Minute)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	return db, nil
+}
+
+// NewPostgresStore returns a store keeping its events in schema, creating
+// the schema and applying pending migrations first. The store does not own
+// db: Close leaves the pool open for other stores.
+func NewPostgresStore(db *sql.DB, schema string) (*PostgresStore, error) {
+	if schema == "" {
+		return nil, fmt.Errorf("postgres schema cannot be empty")
+	}
+
+	s := &PostgresStore{db: db, schema: quoteIdent(schema), lockKey: "ebuse:" + schema}
+	s.saveQuery = "INSERT INTO " + s.schema + ".events (" + eventColumns + ") VALUES "
+	s.loadQuery = "SELECT " + eventColumns + " FROM " + s.schema + ".events WHERE position >= $1 ORDER BY position LIMIT $2"
+	s.loadRangeQuery = "SELECT " + eventColumns + " FROM " + s.schema + ".events WHERE position >= $1 AND position <= $2 ORDER BY position"
+	s.positionQuery = "SELECT COALESCE(MAX(position), (SELECT MAX(head) FROM " + s.schema + ".start_position), 0) FROM " + s.schema + ".events"
+	s.saveSubQuery = "INSERT INTO " + s.schema + ".subscriptions (subscription_id, position) VALUES ($1, $2) ON CONFLICT (subscription_id) DO UPDATE SET position = EXCLUDED.position"
+	s.loadSubQuery = "SELECT position FROM " + s.schema + ".subscriptions WHERE subscription_id = $1"
+	s.streamQuery = "SELECT " + eventColumns + " FROM " + s.schema + ".events WHERE stream_id = $1 AND stream_version >= $2 ORDER BY stream_version LIMIT $3"
+	s.streamHeadQuery = "SELECT COALESCE(MAX(stream_version), 0) FROM " + s.schema + ".events WHERE stream_id = $1"
+	s.typesQuery = "SELECT " + eventColumns + " FROM " + s.schema + ".events WHERE type = ANY($1) AND position >= $2 AND position <= $3 ORDER BY position LIMIT $4"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := s.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("migrate schema %s: %w", schema, err)
+	}
+	return s, nil
+}
+
+// quoteIdent quotes a Postgres identifier, e.g. a tenant name with hyphens
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// migrate creates the schema and applies pending migrations. Servers
+// starting at the same time wait for each other on an advisory lock.
+func (s *PostgresStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "ebuse-migrate:"+s.lockKey); err != nil {
+		return fmt.Errorf("lock schema: %w", err)
+	}
+
+	setup := []string{
+		"CREATE SCHEMA IF NOT EXISTS " + s.schema,
+		"CREATE TABLE IF NOT EXISTS " + s.schema + ".schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+	}
+	for _, stmt := range setup {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM "+s.schema+".schema_migrations").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for v := version + 1; v <= len(postgresMigrations); v++ {
+		for _, stmt := range postgresMigrations[v-1] {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(stmt, s.schema)); err != nil {
+				return fmt.Errorf("migration %d: %w", v, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+s.schema+".schema_migrations (version) VALUES ($1)", v); err != nil {
+			return fmt.Errorf("record migration %d: %w", v, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Save implements EventStore.Save
+func (s *PostgresStore) Save(ctx context.Context, event *StoredEvent) error {
+	return s.SaveBatch(ctx, []*StoredEvent{event})
+}
+
+// SaveBatch implements EventStore.SaveBatch. Events get consecutive
+// positions after the current head, in one transaction.
+func (s *PostgresStore) SaveBatch(ctx context.Context, events []*StoredEvent) error {
+	return s.SaveBatchIf(ctx, events, Expectation{})
+}
+
+// SaveBatchIf implements ConditionalAppender
+func (s *PostgresStore) SaveBatchIf(ctx context.Context, events []*StoredEvent, expect Expectation) error {
+	if len(events) == 0 {
+		return expect.Validate(events)
+	}
+
+	positions := make([]int64, len(events))
+	err := s.write(ctx, func(tx *sql.Tx, head int64) error {
+		// The writer lock keeps the head and stream heads stable until commit
+		streamHead := func(streamID string) (version int64, err error) {
+			if err := tx.QueryRowContext(ctx, s.streamHeadQuery, streamID).Scan(&version); err != nil {
+				return 0, fmt.Errorf("get stream version: %w", err)
+			}
+			return version, nil
+		}
+		if err := expect.check(events, func() (int64, error) { return head, nil }, streamHead); err != nil {
+			return err
+		}
+		if err := streamVersions(events, streamHead); err != nil {
+			return err
+		}
+		for i := range events {
+			positions[i] = head + int64(i) + 1
+		}
+		return s.insert(ctx, tx, events, positions)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, event := range events {
+		event.Position = positions[i]
+	}
+	return nil
+}
+
+// ImportEvents implements Importer
+func (s *PostgresStore) ImportEvents(ctx context.Context, events []*StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.ImportAll(ctx, importBatches(events))
+}
+
+// ImportAll implements BulkImporter in a single transaction
+func (s *PostgresStore) ImportAll(ctx context.Context, scan func(fn func([]*StoredEvent) error) error) error {
+	return s.write(ctx, func(tx *sql.Tx, head int64) error {
+		return scan(func(events []*StoredEvent) error {
+			if err := checkImportPositions(events, head); err != nil {
+				return err
+			}
+			positions := make([]int64, len(events))
+			for i, event := range events {
+				positions[i] = event.Position
+			}
+			if len(events) > 0 {
+				head = events[len(events)-1].Position
+			}
+			return s.insert(ctx, tx, events, positions)
+		})
+	})
+}
+
+// write runs fn in a transaction holding the writer lock, passing the head
+// position. The lock is held until commit, so positions become visible in
+// order.
+func (s *PostgresStore) write(ctx context.Context, fn func(tx *sql.Tx, head int64) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.lockKey); err != nil {
+		return fmt.Errorf("lock events: %w", err)
+	}
+
+	var head int64
+	if err := tx.QueryRowContext(ctx, s.positionQuery).Scan(&head); err != nil {
+		return fmt.Errorf("get max position: %w", err)
+	}
+
+	if err := fn(tx, head); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// postgresInsertRows bounds the rows per INSERT; Postgres allows at most
+// 65535 parameters per statement
+const postgresInsertRows = 1000
+
+// insert writes events at the given positions with multi-row INSERTs
+func (s *PostgresStore) insert(ctx context.Context, tx *sql.Tx, events []*StoredEvent, positions []int64) error {
+	for start := 0; start < len(events); start += postgresInsertRows {
+		end := min(start+postgresInsertRows, len(events))
+
+		var query strings.Builder
+		query.WriteString(s.saveQuery)
+		args := make([]any, 0, 7*(end-start))
+		for i := start; i < end; i++ {
+			event := events[i]
+			metadata, err := encodeMetadata(event.Metadata)
+			if err != nil {
+				return err
+			}
+			if i > start {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+			args = append(args, positions[i], event.Type, []byte(event.Data), event.Timestamp, metadata,
+				nullString(event.StreamID), nullInt64(event.StreamVersion))
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("insert events: %w", err)
+		}
+	}
+	return nil
+}
+
+// Load implements EventStore.Load. Like the SQLite store, open ranges
+// (to == -1) are capped at 10000 events; use LoadStream for more.
+func (s *PostgresStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent, error) {
+	if tier, ok := s.below(from); ok {
+		return loadCold(ctx, tier, from, to, s.Load)
+	}
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return []*StoredEvent{}, nil
+	}
+
+	var rows *sql.Rows
+	var err error
+	if to == -1 {
+		rows, err = s.db.QueryContext(ctx, s.loadQuery, from, MaxLoadEvents)
+	} else {
+		rows, err = s.db.QueryContext(ctx, s.loadRangeQuery, from, to)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostgresEvents(rows, make([]*StoredEvent, 0, 1000))
+}
+
+// LoadStream implements EventStore.LoadStream
+func (s *PostgresStore) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, sameEvent, handler, s.LoadStream)
+	}
+	batchSize = streamBatchSize(batchSize)
+
+	position := streamStart(from)
+	for {
+		rows, err := s.db.QueryContext(ctx, s.loadQuery, position, batchSize)
+		if err != nil {
+			return fmt.Errorf("query events: %w", err)
+		}
+		batch, err := scanPostgresEvents(rows, make([]*StoredEvent, 0, batchSize))
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := handler(batch); err != nil {
+			return fmt.Errorf("handle batch: %w", err)
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		position = batch[len(batch)-1].Position + 1
+	}
+}
+
+// scanPostgresEvents appends every remaining row to dst
+func scanPostgresEvents(rows *sql.Rows, dst []*StoredEvent) ([]*StoredEvent, error) {
+	for rows.Next() {
+		var event StoredEvent
+		var data []byte
+		var metadata, streamID sql.NullString
+		var version sql.NullInt64
+		if err := rows.Scan(&event.Position, &event.Type, &data, &event.Timestamp, &metadata, &streamID, &version); err != nil {
+			return dst, fmt.Errorf("scan event: %w", err)
+		}
+		event.Data = data
+		event.StreamID, event.StreamVersion = streamID.String, version.Int64
+		if metadata.Valid && metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &event.Metadata); err != nil {
+				return dst, fmt.Errorf("unmarshal metadata: %w", err)
+			}
+		}
+		dst = append(dst, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return dst, fmt.Errorf("iterate events: %w", err)
+	}
+	return dst, nil
+}
+
+// LoadByStream implements StreamIndex
+func (s *PostgresStore) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*StoredEvent, error) {
+	rows, err := s.db.QueryContext(ctx, s.streamQuery, streamID, fromVersion, streamLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("query stream: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostgresEvents(rows, nil)
+}
+
+// StreamVersion implements StreamIndex
+func (s *PostgresStore) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	var version int64
+	if err := s.db.QueryRowContext(ctx, s.streamHeadQuery, streamID).Scan(&version); err != nil {
+		return 0, fmt.Errorf("get stream version: %w", err)
+	}
+	return version, nil
+}
+
+// GetPosition implements EventStore.GetPosition
+func (s *PostgresStore) GetPosition(ctx context.Context) (int64, error) {
+	var position int64
+	if err := s.db.QueryRowContext(ctx, s.positionQuery).Scan(&position); err != nil {
+		return 0, fmt.Errorf("get max position: %w", err)
+	}
+	return position, nil
+}
+
+// SaveSubscriptionPosition implements EventStore.SaveSubscriptionPosition
+func (s *PostgresStore) SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error {
+	if _, err := s.db.ExecContext(ctx, s.saveSubQuery, subscriptionID, position); err != nil {
+		return fmt.Errorf("save subscription position: %w", err)
+	}
+	return nil
+}
+
+// LoadSubscriptionPosition implements EventStore.LoadSubscriptionPosition
+func (s *PostgresStore) LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error) {
+	var position int64
+	err := s.db.QueryRowContext(ctx, s.loadSubQuery, subscriptionID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("load subscription position: %w", err)
+	}
+	return position, nil
+}
+
+// Sync implements Syncer. Postgres acknowledges commits once they are
+// durable (unless synchronous_commit is off), so there is nothing to flush.
+func (s *PostgresStore) Sync(ctx context.Context, upTo int64) error {
+	return nil
+}
+
+// Close implements EventStore.Close. The pool passed to NewPostgresStore
+// stays open; its owner closes it.
+func (s *PostgresStore) Close() error {
+	return nil
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// newTestPostgresStore returns a store in a fresh schema of the database at
+// EBUSE_TEST_POSTGRES_DSN, skipping the test when it is not set
+func newTestPostgresStore(t *testing.T) (*PostgresStore, *sql.DB, string) {
+	t.Helper()
+	dsn := os.Getenv("EBUSE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("EBUSE_TEST_POSTGRES_DSN not set")
+	}
+
+	db, err := OpenPostgres(dsn, 4)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	schema := fmt.Sprintf("ebuse_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + quoteIdent(schema) + " CASCADE")
+		db.Close()
+	})
+
+	st, err := NewPostgresStore(db, schema)
+	if err != nil {
+		t.Fatalf("failed to create postgres store: %v", err)
+	}
+	return st, db, schema
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := map[string]string{
+		"public":      `"public"`,
+		"ebuse_acme":  `"ebuse_acme"`,
+		"my-tenant":   `"my-tenant"`,
+		`evil"; DROP`: `"evil""; DROP"`,
+	}
+	for name, want := range tests {
+		if got := quoteIdent(name); got != want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	st, _, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	if head, err := st.GetPosition(ctx); err != nil || head != 0 {
+		t.Fatalf("expected empty store, got head %d, %v", head, err)
+	}
+
+	event := &StoredEvent{
+		Type:      "UserCreated",
+		Data:      json.RawMessage(`{"name":"Ada"}`),
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{"source": "test"},
+	}
+	if err := st.Save(ctx, event); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if event.Position != 1 {
+		t.Errorf("expected position 1, got %d", event.Position)
+	}
+
+	batch := make([]*StoredEvent, 2500) // Spans several INSERT statements
+	for i := range batch {
+		batch[i] = &StoredEvent{Type: "Tick", Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)), Timestamp: time.Now()}
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+	if batch[0].Position != 2 || batch[len(batch)-1].Position != 2501 {
+		t.Errorf("expected positions 2-2501, got %d-%d", batch[0].Position, batch[len(batch)-1].Position)
+	}
+
+	events, err := st.Load(ctx, 1, 3)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d, %v", len(events), err)
+	}
+	if events[0].Type != "UserCreated" || string(events[0].Data) != `{"name":"Ada"}` || events[0].Metadata["source"] != "test" {
+		t.Errorf("event not stored as saved: %+v", events[0])
+	}
+
+	var streamed int
+	err = st.LoadStream(ctx, 1, 1000, func(batch []*StoredEvent) error {
+		for _, e := range batch {
+			streamed++
+			if e.Position != int64(streamed) {
+				return fmt.Errorf("expected position %d, got %d", streamed, e.Position)
+			}
+		}
+		return nil
+	})
+	if err != nil || streamed != 2501 {
+		t.Errorf("expected 2501 streamed events, got %d, %v", streamed, err)
+	}
+
+	if pos, err := st.LoadSubscriptionPosition(ctx, "projector"); err != nil || pos != 0 {
+		t.Errorf("expected 0 for unknown subscription, got %d, %v", pos, err)
+	}
+	for _, pos := range []int64{10, 20} {
+		if err := st.SaveSubscriptionPosition(ctx, "projector", pos); err != nil {
+			t.Fatalf("SaveSubscriptionPosition failed: %v", err)
+		}
+	}
+	if pos, err := st.LoadSubscriptionPosition(ctx, "projector"); err != nil || pos != 20 {
+		t.Errorf("expected subscription at 20, got %d, %v", pos, err)
+	}
+}
+
+func TestPostgresStore_ConcurrentWriters(t *testing.T) {
+	st, db, schema := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	// A second store on the same schema stands in for another server
+	other, err := NewPostgresStore(db, schema)
+	if err != nil {
+		t.Fatalf("failed to reopen schema: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range []*PostgresStore{st, other} {
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 25 {
+					event := &StoredEvent{Type: "Concurrent", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+					if err := s.Save(ctx, event); err != nil {
+						t.Errorf("Save failed: %v", err)
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	events, err := st.Load(ctx, 1, -1)
+	if err != nil || len(events) != 200 {
+		t.Fatalf("expected 200 events, got %d, %v", len(events), err)
+	}
+	for i, e := range events {
+		if e.Position != int64(i+1) {
+			t.Fatalf("expected gapless positions, got %d at index %d", e.Position, i)
+		}
+	}
+}
+
+func TestPostgresStore_ImportEvents(t *testing.T) {
+	st, _, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	imported := []*StoredEvent{
+		{Position: 1, Type: "A", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+		{Position: 4, Type: "B", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+	}
+	if err := st.ImportEvents(ctx, imported); err != nil {
+		t.Fatalf("ImportEvents failed: %v", err)
+	}
+	event := &StoredEvent{Type: "C", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+	if err := st.Save(ctx, event); err != nil || event.Position != 5 {
+		t.Errorf("expected the next save at 5, got %d, %v", event.Position, err)
+	}
+	if err := st.ImportEvents(ctx, []*StoredEvent{{Position: 2, Type: "Late", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Error("expected error for a position below the head")
+	}
+}
+
+func TestPostgresStore_Streams(t *testing.T) {
+	st, _, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	batch := []*StoredEvent{
+		{Type: "Created", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: "order-1"},
+		{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+		{Type: "Paid", Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: "order-1"},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+	if version, err := st.StreamVersion(ctx, "order-1"); err != nil || version != 2 {
+		t.Errorf("expected version 2, got %d, %v", version, err)
+	}
+	events, err := st.LoadByStream(ctx, "order-1", 2, 0)
+	if err != nil || len(events) != 1 || events[0].Type != "Paid" || events[0].Position != 3 {
+		t.Errorf("expected Paid at position 3, got %d events, %v", len(events), err)
+	}
+}
+
+func TestPostgresStore_Types(t *testing.T) {
+	st, _, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	batch := []*StoredEvent{
+		{Type: "Created", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+		{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+		{Type: "Paid", Data: json.RawMessage(`{}`), Timestamp: time.Now()},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	events, err := st.LoadByTypes(ctx, []string{"Paid", "Created"}, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTypes failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Position != 1 || events[1].Position != 3 {
+		t.Errorf("expected Created and Paid, got %+v", events)
+	}
+}
+
+func TestPostgresStore_Time(t *testing.T) {
+	st, _, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	batch := []*StoredEvent{
+		{Type: "Created", Data: json.RawMessage(`{}`), Timestamp: base.Add(-time.Hour)},
+		{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: base},
+		{Type: "Paid", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Hour)},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	events, err := st.LoadByTime(ctx, base, time.Time{}, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTime failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Position != 2 || events[1].Position != 3 {
+		t.Errorf("expected Tick and Paid, got %+v", events)
+	}
+
+	events, err = st.LoadByTime(ctx, time.Time{}, base, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTime failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Position != 1 {
+		t.Errorf("expected Created, got %+v", events)
+	}
+}
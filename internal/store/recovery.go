@@ -0,0 +1,300 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// maxRecoveryListed bounds the ignored positions listed in a Recovery
+const maxRecoveryListed = 100
+
+// Recoverer is implemented by stores that can tell whether the last process
+// using them closed them cleanly. After an unclean shutdown Recover checks
+// the newest events, fixing what can be rebuilt from the event itself and
+// reporting what cannot.
+type Recoverer interface {
+	// Recover checks up to the last n events if the store was not closed
+	// cleanly, and keeps the report for Recovery. Clean stores are not
+	// scanned.
+	Recover(ctx context.Context, n int64) (Recovery, error)
+	// Recovery returns the report of the last Recover
+	Recovery() Recovery
+}
+
+// Recover runs the recovery scan of st over up to the last n events, if it
+// implements Recoverer. ok is false for stores without one.
+func Recover(ctx context.Context, st EventStore, n int64) (report Recovery, ok bool, err error) {
+	recoverer, ok := As[Recoverer](st)
+	if !ok {
+		return Recovery{}, false, nil
+	}
+	report, err = recoverer.Recover(ctx, n)
+	return report, true, err
+}
+
+// Recovery reports the state a store came back in after it was opened
+type Recovery struct {
+	Unclean          bool      `json:"unclean"`                     // The last process did not close the store
+	Scanned          int64     `json:"scanned"`                     // Events checked, from the head back
+	From             int64     `json:"from,omitempty"`              // Oldest position checked
+	Head             int64     `json:"head"`                        // Position the store came back at
+	Repaired         int64     `json:"repaired"`                    // Records fixed in place
+	Ignored          int64     `json:"ignored"`                     // Records that could not be fixed, left as they are
+	IgnoredPositions []int64   `json:"ignored_positions,omitempty"` // The first maxRecoveryListed of them
+	Missing          int64     `json:"missing_positions"`           // Positions without an event between From and Head
+	Duration         float64   `json:"duration_ms"`
+	RecoveredAt      time.Time `json:"recovered_at,omitzero"`
+}
+
+// ignore records an event that could not be fixed
+func (r *Recovery) ignore(position int64) {
+	r.Ignored++
+	if len(r.IgnoredPositions) < maxRecoveryListed {
+		r.IgnoredPositions = append(r.IgnoredPositions, position)
+	}
+}
+
+// recoveryState tracks the open marker of a store: a file that exists while
+// the store is open, so finding it on open means the last process using the
+// store crashed or was killed before closing it
+type recoveryState struct {
+	marker  string // Empty for stores without files
+	unclean bool
+
+	mu     sync.Mutex
+	report Recovery
+}
+
+// pebbleOpenMarker is the open marker in a Pebble directory. Backups skip
+// files ending in .open, like lock files.
+const pebbleOpenMarker = "ebuse.open"
+
+// sqliteOpenMarker returns the open marker of a SQLite database, or "" for
+// in-memory databases
+func sqliteOpenMarker(dbPath string) string {
+	if lockPath(dbPath) == "" {
+		return ""
+	}
+	return dbPath + ".open"
+}
+
+// markOpen creates the open marker at path, noting whether it was there
+// already
+func (r *recoveryState) markOpen(path string) error {
+	if path == "" {
+		return nil
+	}
+	_, err := os.Stat(path)
+	r.unclean = err == nil
+	r.report.Unclean = r.unclean
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return fmt.Errorf("create open marker: %w", err)
+	}
+	r.marker = path
+	return nil
+}
+
+// markClosed removes the open marker once the store has closed cleanly
+func (r *recoveryState) markClosed() error {
+	if r.marker == "" {
+		return nil
+	}
+	if err := os.Remove(r.marker); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove open marker: %w", err)
+	}
+	return nil
+}
+
+// recover runs scan over the last n positions up to head if the store was
+// not closed cleanly, and keeps the report
+func (r *recoveryState) recover(n, head int64, scan func(from int64, report *Recovery) error) (Recovery, error) {
+	report := Recovery{Unclean: r.unclean, Head: head}
+	if r.unclean && n > 0 && head > 0 {
+		started := time.Now()
+		report.From = max(head-n+1, 1)
+		if err := scan(report.From, &report); err != nil {
+			return report, err
+		}
+		report.Missing = head - report.From + 1 - report.Scanned
+		report.Duration = float64(time.Since(started).Microseconds()) / 1000
+		report.RecoveredAt = time.Now().UTC()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report = report
+	return report, nil
+}
+
+// last returns the kept report
+func (r *recoveryState) last() Recovery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report
+}
+
+// Recovery implements Recoverer
+func (s *PebbleStore) Recovery() Recovery {
+	return s.recovery.last()
+}
+
+// Recovery implements Recoverer
+func (s *SQLiteStore) Recovery() Recovery {
+	return s.recovery.last()
+}
+
+// Recover implements Recoverer. Events that do not decode, or whose key
+// disagrees with their position, are ignored; /admin/repair can replace
+// them. Missing stream, type and time index entries are restored.
+func (s *PebbleStore) Recover(ctx context.Context, n int64) (Recovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.recovery.recover(n, s.position.Load(), func(from int64, report *Recovery) error {
+		iter, err := s.db.NewIter(&pebble.IterOptions{
+			LowerBound: eventKey(from),
+			UpperBound: []byte{eventPrefix + 1},
+		})
+		if err != nil {
+			return fmt.Errorf("create iterator: %w", err)
+		}
+		defer iter.Close()
+
+		batch := s.db.NewBatch()
+		defer batch.Close()
+
+		for iter.First(); iter.Valid(); iter.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			report.Scanned++
+			position := int64(binary.BigEndian.Uint64(iter.Key()[1:]))
+			var event StoredEvent
+			if err := json.Unmarshal(iter.Value(), &event); err != nil || event.Position != position || event.Type == "" {
+				report.ignore(position)
+				continue
+			}
+
+			missing, err := s.missingIndexKeys(&event)
+			if err != nil {
+				return err
+			}
+			for _, set := range missing {
+				if err := set(batch, &event); err != nil {
+					return err
+				}
+			}
+			if len(missing) > 0 {
+				report.Repaired++
+			}
+		}
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("iterator error: %w", err)
+		}
+
+		if batch.Count() > 0 {
+			if err := batch.Commit(pebble.Sync); err != nil {
+				return fmt.Errorf("commit recovered index entries: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// indexEntry is an index key of an event and the setter that adds it
+type indexEntry struct {
+	key []byte
+	set func(*pebble.Batch, *StoredEvent) error
+}
+
+// missingIndexKeys returns the setters of the index entries event lacks
+func (s *PebbleStore) missingIndexKeys(event *StoredEvent) ([]func(*pebble.Batch, *StoredEvent) error, error) {
+	entries := []indexEntry{
+		{typeKey(event.Type, event.Position), setTypeKey},
+		{timeKey(unixNano(event.Timestamp), event.Position), setTimeKey},
+	}
+	if event.StreamID != "" && event.StreamVersion > 0 {
+		entries = append(entries, indexEntry{streamKey(event.StreamID, event.StreamVersion), setStreamKey})
+	}
+
+	var missing []func(*pebble.Batch, *StoredEvent) error
+	for _, entry := range entries {
+		_, closer, err := s.db.Get(entry.key)
+		if err == pebble.ErrNotFound {
+			missing = append(missing, entry.set)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read index entry: %w", err)
+		}
+		closer.Close()
+	}
+	return missing, nil
+}
+
+// Recover implements Recoverer. SQLite rolls back incomplete transactions
+// itself, so the scan checks what it cannot: rows whose type is empty or
+// whose data or metadata is not JSON are ignored, and an AUTOINCREMENT
+// counter behind the newest row is moved up to it, so no position is
+// handed out twice.
+func (s *SQLiteStore) Recover(ctx context.Context, n int64) (Recovery, error) {
+	head, err := s.GetPosition(ctx)
+	if err != nil {
+		return Recovery{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.recovery.recover(n, head, func(from int64, report *Recovery) error {
+		return s.busy.retryBusy(ctx, func() error {
+			*report = Recovery{Unclean: report.Unclean, Head: report.Head, From: report.From}
+
+			rows, err := s.db.QueryContext(ctx, `SELECT position, type != '' AND json_valid(CAST(data AS TEXT))
+				AND (metadata IS NULL OR json_valid(metadata)) FROM events WHERE position >= ? ORDER BY position`, from)
+			if err != nil {
+				return fmt.Errorf("query events: %w", err)
+			}
+			defer rows.Close()
+			var last int64
+			for rows.Next() {
+				var valid bool
+				if err := rows.Scan(&last, &valid); err != nil {
+					return fmt.Errorf("scan event: %w", err)
+				}
+				report.Scanned++
+				if !valid {
+					report.ignore(last)
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterate events: %w", err)
+			}
+
+			var seq sql.NullInt64
+			err = s.db.QueryRowContext(ctx, "SELECT seq FROM sqlite_sequence WHERE name = 'events'").Scan(&seq)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("read sequence: %w", err)
+			}
+			if seq.Int64 < last {
+				if _, err := s.db.ExecContext(ctx, "DELETE FROM sqlite_sequence WHERE name = 'events'"); err != nil {
+					return fmt.Errorf("reset sequence: %w", err)
+				}
+				if _, err := s.db.ExecContext(ctx, "INSERT INTO sqlite_sequence (name, seq) VALUES ('events', ?)", last); err != nil {
+					return fmt.Errorf("repair sequence: %w", err)
+				}
+				report.Repaired++
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// crash leaves the open marker behind as a killed process would
+func crash(t *testing.T, marker string) {
+	t.Helper()
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write open marker: %v", err)
+	}
+}
+
+func TestRecover_Pebble(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "pebble")
+	st, err := NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := range 5 {
+		event := &StoredEvent{Type: "Created", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+		if i == 3 {
+			event.StreamID = "orders/1"
+		}
+		if err := st.Save(ctx, event); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// Position 5 lost its type entry, 4 its stream entry and 3 is unreadable
+	events, _ := st.Load(ctx, 5, 5)
+	st.db.Delete(typeKey("Created", 5), pebble.Sync)
+	st.db.Delete(streamKey("orders/1", 1), pebble.Sync)
+	st.db.Set(eventKey(3), []byte("{"), pebble.Sync)
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	crash(t, filepath.Join(dir, pebbleOpenMarker))
+
+	st, err = NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	report, err := st.Recover(ctx, 4)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if !report.Unclean || report.Head != 5 || report.From != 2 || report.Scanned != 4 || report.Repaired != 2 ||
+		!reflect.DeepEqual(report.IgnoredPositions, []int64{3}) || report.Missing != 0 {
+		t.Errorf("Report = %+v", report)
+	}
+	if byType, _ := st.LoadByTypes(ctx, []string{"Created"}, 5, 5); len(byType) != 1 || byType[0].Position != events[0].Position {
+		t.Errorf("Type index not repaired, got %v", byType)
+	}
+	if version, _ := st.StreamVersion(ctx, "orders/1"); version != 1 {
+		t.Errorf("Stream index not repaired, version %d", version)
+	}
+	if st.Recovery().Repaired != 2 {
+		t.Errorf("Recovery() = %+v, want the report of Recover", st.Recovery())
+	}
+	st.Close()
+
+	// A clean shutdown is not scanned
+	st, err = NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer st.Close()
+	if report, _ := st.Recover(ctx, 4); report.Unclean || report.Scanned != 0 || report.Head != 5 {
+		t.Errorf("Report after a clean shutdown = %+v", report)
+	}
+}
+
+func TestRecover_SQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	st, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for range 5 {
+		if err := st.Save(ctx, &StoredEvent{Type: "Created", Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// Position 4 holds no JSON and the AUTOINCREMENT counter fell behind
+	st.db.Exec("UPDATE events SET data = 'not json' WHERE position = 4")
+	st.db.Exec("UPDATE sqlite_sequence SET seq = 2 WHERE name = 'events'")
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	crash(t, path+".open")
+
+	st, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer st.Close()
+	report, ok, err := Recover(ctx, st, 100)
+	if err != nil || !ok {
+		t.Fatalf("Recover = %v, %v", ok, err)
+	}
+	if !report.Unclean || report.Head != 5 || report.From != 1 || report.Scanned != 5 || report.Repaired != 1 ||
+		!reflect.DeepEqual(report.IgnoredPositions, []int64{4}) {
+		t.Errorf("Report = %+v", report)
+	}
+	// The next event does not reuse a position
+	if err := st.Save(ctx, &StoredEvent{Type: "Created", Data: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if head, _ := st.GetPosition(ctx); head != 6 {
+		t.Errorf("Head after recovery = %d, want 6", head)
+	}
+}
+
+func TestRecover_Memory(t *testing.T) {
+	if _, ok, err := Recover(context.Background(), NewMemoryStore(), 100); ok || err != nil {
+		t.Errorf("Recover of a memory store = %v, %v; want no recoverer", ok, err)
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Repairer is implemented by stores that can overwrite events in place,
+// which anti-entropy repair uses to fix a replica that diverged. Events keep
+// the positions they carry; positions past the head are rejected, so repairs
+// never extend the log.
+type Repairer interface {
+	ReplaceEvents(ctx context.Context, events []*StoredEvent) error
+}
+
+// checkRepairPositions rejects events outside [1, head]
+func checkRepairPositions(events []*StoredEvent, head int64) error {
+	for _, event := range events {
+		if event.Position < 1 || event.Position > head {
+			return fmt.Errorf("cannot repair position %d: outside 1-%d", event.Position, head)
+		}
+	}
+	return nil
+}
+
+// ReplaceEvents implements Repairer
+func (s *PebbleStore) ReplaceEvents(ctx context.Context, events []*StoredEvent) error {
+	if err := checkRepairPositions(events, s.position.Load()); err != nil {
+		return err
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	rollups := rollupDeltas{}
+	for _, event := range events {
+		// The event's count moves from the one it replaces
+		old, err := s.getEvent(event.Position)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			rollups.add(old, -1)
+		}
+		rollups.add(event, 1)
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		if err := batch.Set(eventKey(event.Position), data, nil); err != nil {
+			return fmt.Errorf("batch set: %w", err)
+		}
+		if err := setStreamKey(batch, event); err != nil {
+			return err
+		}
+		if err := setTypeKey(batch, event); err != nil {
+			return err
+		}
+		if err := setTimeKey(batch, event); err != nil {
+			return err
+		}
+	}
+
+	// Repairs are rare and deliberate, so make them durable right away
+	if err := s.commitWithRollups(batch, rollups, pebble.Sync); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
+	return nil
+}
+
+// ReplaceEvents implements Repairer. Missing positions below the head are
+// inserted, so gaps can be repaired too.
+func (s *SQLiteStore) ReplaceEvents(ctx context.Context, events []*StoredEvent) error {
+	head, err := s.GetPosition(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkRepairPositions(events, head); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.busy.retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		for _, event := range events {
+			metadata, err := encodeMetadata(event.Metadata)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO events (position, type, data, timestamp, metadata, stream_id, stream_version, time_ns) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(position) DO UPDATE SET
+					type = excluded.type, data = excluded.data,
+					timestamp = excluded.timestamp, metadata = excluded.metadata,
+					stream_id = excluded.stream_id, stream_version = excluded.stream_version,
+					time_ns = excluded.time_ns`,
+				event.Position, event.Type, event.Data, event.Timestamp, metadata, nullString(event.StreamID), nullInt64(event.StreamVersion), unixNano(event.Timestamp))
+			if err != nil {
+				return fmt.Errorf("replace event %d: %w", event.Position, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestReplaceEvents(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/repair.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/repair")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		Repairer
+	}{sqliteStore, pebbleStore} {
+		for range 3 {
+			if err := st.Save(ctx, &StoredEvent{Type: "Wrong", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+				t.Fatalf("%T.Save failed: %v", st, err)
+			}
+		}
+
+		fixed := &StoredEvent{Position: 2, Type: "Right", Data: json.RawMessage(`{"ok":true}`), Timestamp: time.Now()}
+		if err := st.ReplaceEvents(ctx, []*StoredEvent{fixed}); err != nil {
+			t.Fatalf("%T.ReplaceEvents failed: %v", st, err)
+		}
+
+		events, err := st.Load(ctx, 1, 3)
+		if err != nil || len(events) != 3 {
+			t.Fatalf("%T.Load failed: %v", st, err)
+		}
+		if events[1].Type != "Right" || events[0].Type != "Wrong" || events[2].Type != "Wrong" {
+			t.Errorf("%T: expected only position 2 to change, got %s %s %s", st, events[0].Type, events[1].Type, events[2].Type)
+		}
+		if head, _ := st.GetPosition(ctx); head != 3 {
+			t.Errorf("%T: expected head to stay at 3, got %d", st, head)
+		}
+
+		// Repairs never extend the log
+		if err := st.ReplaceEvents(ctx, []*StoredEvent{{Position: 4, Type: "New", Data: json.RawMessage(`{}`)}}); err == nil {
+			t.Errorf("%T: expected error for a position past the head", st)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// deleteBatchSize is how many events DeleteBefore deletes per transaction,
+// so writers are not held up for the whole deletion
+const deleteBatchSize = 10000
+
+// deleteLimit returns the position before which DeleteBefore may delete:
+// position, short of the head so the head event stays
+func deleteLimit(position, head int64) int64 {
+	return min(position, head)
+}
+
+// sqlDeletable selects events DeleteBefore may delete: all but the last of
+// each stream, which keeps stream versions counting up
+const sqlDeletable = "(stream_id IS NULL OR stream_version < (SELECT MAX(s.stream_version) FROM %[1]s s WHERE s.stream_id = e.stream_id))"
+
+// DeleteBefore implements EventStore.DeleteBefore. Rollups keep counting
+// the deleted events, so throughput history outlives them.
+func (s *SQLiteStore) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	head, err := s.GetPosition(ctx)
+	if err != nil {
+		return 0, err
+	}
+	before := deleteLimit(position, head)
+
+	query := "DELETE FROM events WHERE position IN (SELECT position FROM events e WHERE position < ? AND " + fmt.Sprintf(sqlDeletable, "events") + " ORDER BY position LIMIT ?)"
+	var deleted int64
+	for {
+		var n int64
+		s.mu.Lock()
+		err := s.busy.retryBusy(ctx, func() error {
+			result, err := s.db.ExecContext(ctx, query, before, deleteBatchSize)
+			if err != nil {
+				return fmt.Errorf("delete events: %w", err)
+			}
+			n, err = result.RowsAffected()
+			return err
+		})
+		s.mu.Unlock()
+		deleted += n
+		if err != nil || n < deleteBatchSize {
+			return deleted, err
+		}
+	}
+}
+
+// DeleteBefore implements EventStore.DeleteBefore. Rollups keep counting
+// the deleted events, so throughput history outlives them.
+func (s *PostgresStore) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	head, err := s.GetPosition(ctx)
+	if err != nil {
+		return 0, err
+	}
+	before := deleteLimit(position, head)
+
+	query := "DELETE FROM " + s.schema + ".events WHERE position IN (SELECT position FROM " + s.schema + ".events e WHERE position < $1 AND " +
+		fmt.Sprintf(sqlDeletable, s.schema+".events") + " ORDER BY position LIMIT $2)"
+	var deleted int64
+	for {
+		result, err := s.db.ExecContext(ctx, query, before, deleteBatchSize)
+		if err != nil {
+			return deleted, fmt.Errorf("delete events: %w", err)
+		}
+		n, err := result.RowsAffected()
+		deleted += n
+		if err != nil || n < deleteBatchSize {
+			return deleted, err
+		}
+	}
+}
+
+// DeleteBefore implements EventStore.DeleteBefore
+func (s *MemoryStore) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errMemoryClosed
+	}
+	n := s.index(deleteLimit(position, s.position))
+	if n == 0 {
+		return 0, nil
+	}
+	kept := make([]*StoredEvent, 0, len(s.events)-n)
+	for i, event := range s.events {
+		if i >= n || s.streamLast(event) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(s.events) - len(kept)
+	s.events = kept
+	clear(s.streams)
+	for i, event := range s.events {
+		if event.StreamID != "" && event.StreamVersion > 0 {
+			s.streams[event.StreamID] = append(s.streams[event.StreamID], i)
+		}
+	}
+	return int64(deleted), nil
+}
+
+// streamLast reports whether event is the last of its stream
+func (s *MemoryStore) streamLast(event *StoredEvent) bool {
+	if event.StreamID == "" || event.StreamVersion <= 0 {
+		return false
+	}
+	version, _ := s.streamHead(event.StreamID)
+	return event.StreamVersion == version
+}
+
+// DeleteBefore implements EventStore.DeleteBefore, removing the index
+// entries of deleted events with them. Rollups keep counting the deleted
+// events, so throughput history outlives them.
+func (s *PebbleStore) DeleteBefore(ctx context.Context, position int64) (int64, error) {
+	before := deleteLimit(position, s.position.Load())
+
+	var deleted int64
+	for from := int64(1); from < before; {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		n, next, err := s.deleteEvents(from, before)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+		from = next
+	}
+	return deleted, nil
+}
+
+// deleteEvents deletes up to deleteBatchSize events in [from, before) but
+// the last of each stream, and returns the position to continue at
+func (s *PebbleStore) deleteEvents(from, before int64) (int64, int64, error) {
+	// Stream heads are read from the stream index
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: eventKey(from),
+		UpperBound: eventKey(before),
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	var n int64
+	next := before
+	for iter.First(); iter.Valid(); iter.Next() {
+		if n == deleteBatchSize {
+			next = int64(binary.BigEndian.Uint64(iter.Key()[1:]))
+			break
+		}
+		// Index entries only need these fields
+		var event struct {
+			Position      int64     `json:"position"`
+			Type          string    `json:"type"`
+			Timestamp     time.Time `json:"timestamp"`
+			StreamID      string    `json:"stream_id"`
+			StreamVersion int64     `json:"stream_version"`
+		}
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			return 0, 0, fmt.Errorf("unmarshal event: %w", err)
+		}
+		if event.StreamID != "" && event.StreamVersion > 0 {
+			version, err := s.streamHead(event.StreamID)
+			if err != nil {
+				return 0, 0, err
+			}
+			if event.StreamVersion == version {
+				continue
+			}
+		}
+		keys := [][]byte{
+			eventKey(event.Position),
+			typeKey(event.Type, event.Position),
+			timeKey(unixNano(event.Timestamp), event.Position),
+		}
+		if event.StreamID != "" && event.StreamVersion > 0 {
+			keys = append(keys, streamKey(event.StreamID, event.StreamVersion))
+		}
+		for _, key := range keys {
+			if err := batch.Delete(key, nil); err != nil {
+				return 0, 0, fmt.Errorf("batch delete: %w", err)
+			}
+		}
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		return 0, 0, fmt.Errorf("iterator error: %w", err)
+	}
+	if n > 0 {
+		if err := batch.Commit(pebble.Sync); err != nil {
+			return 0, 0, fmt.Errorf("commit batch: %w", err)
+		}
+	}
+	return n, next, nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDeleteBefore(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	stores := map[string]func() (EventStore, error){
+		"sqlite": func() (EventStore, error) { return NewSQLiteStore(dir + "/retention.db") },
+		"pebble": func() (EventStore, error) { return NewPebbleStore(dir + "/retention") },
+		"memory": func() (EventStore, error) { return NewMemoryStore(), nil },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			st, err := open()
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			for i := range 10 {
+				event := &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Duration(i) * time.Minute)}
+				if i%2 == 0 {
+					event.StreamID = "clock"
+				}
+				if err := st.Save(ctx, event); err != nil {
+					t.Fatalf("save failed: %v", err)
+				}
+			}
+
+			deleted, err := st.DeleteBefore(ctx, 5)
+			if err != nil || deleted != 4 {
+				t.Fatalf("DeleteBefore(5) = %d, %v, want 4", deleted, err)
+			}
+			events, _ := st.Load(ctx, 1, -1)
+			if len(events) != 6 || events[0].Position != 5 {
+				t.Fatalf("Expected events 5-10, got %d from %d", len(events), events[0].Position)
+			}
+			if events, _ := st.LoadByTime(ctx, base, time.Time{}, 1, -1); len(events) != 6 {
+				t.Errorf("LoadByTime returned %d events, want 6", len(events))
+			}
+			if index, ok := st.(TypeIndex); ok {
+				if events, _ := index.LoadByTypes(ctx, []string{"Tick"}, 1, -1); len(events) != 6 {
+					t.Errorf("LoadByTypes returned %d events, want 6", len(events))
+				}
+			}
+			if streams, ok := st.(StreamIndex); ok {
+				events, _ := streams.LoadByStream(ctx, "clock", 1, 100)
+				if len(events) != 3 || events[0].StreamVersion != 3 {
+					t.Errorf("LoadByStream returned %d events, want versions 3-5", len(events))
+				}
+			}
+
+			// The head event and the last of the stream stay, so positions
+			// and versions continue after a restart
+			if deleted, err := st.DeleteBefore(ctx, 100); err != nil || deleted != 4 {
+				t.Fatalf("DeleteBefore(100) = %d, %v, want 4", deleted, err)
+			}
+			if name != "memory" {
+				st.Close()
+				if st, err = open(); err != nil {
+					t.Fatalf("failed to reopen store: %v", err)
+				}
+			}
+			defer st.Close()
+			if head, _ := st.GetPosition(ctx); head != 10 {
+				t.Errorf("Head = %d after deleting, want 10", head)
+			}
+			if events, _ := st.Load(ctx, 1, -1); len(events) != 2 || events[0].Position != 9 {
+				t.Errorf("Expected events 9 and 10 to stay, got %d", len(events))
+			}
+			event := &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`), StreamID: "clock"}
+			if err := st.Save(ctx, event); err != nil || event.Position != 11 || event.StreamVersion != 6 {
+				t.Errorf("Save after deleting: position %d, version %d, %v", event.Position, event.StreamVersion, err)
+			}
+			if deleted, _ := st.DeleteBefore(ctx, 5); deleted != 0 {
+				t.Errorf("Deleting again removed %d events", deleted)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,30 +15,69 @@ import (
 
 // StoredEvent represents an event in storage (copied from ebu)
 type StoredEvent struct {
-	Position  int64           `json:"position"`
-	Type      string          `json:"type"`
-	Data      json.RawMessage `json:"data"`
-	Timestamp time.Time       `json:"timestamp"`
+	Position  int64             `json:"position"`
+	Type      string            `json:"type"`
+	Data      json.RawMessage   `json:"data"`
+	Timestamp time.Time         `json:"timestamp"`
+	Metadata  map[string]string `json:"metadata,omitempty"` // Caller identity, trace IDs and other provenance
+
+	// Optional aggregate the event belongs to; the store numbers the events
+	// of each stream 1, 2, 3, ... in StreamVersion
+	StreamID      string `json:"stream_id,omitempty"`
+	StreamVersion int64  `json:"stream_version,omitempty"`
 }
 
 // SQLiteStore implements EventStore using SQLite
 type SQLiteStore struct {
-	db              *sql.DB
-	mu              sync.RWMutex
-	saveStmt        *sql.Stmt
-	loadStmt        *sql.Stmt
-	loadRangeStmt   *sql.Stmt
-	positionStmt    *sql.Stmt
-	saveSubStmt     *sql.Stmt
-	loadSubStmt     *sql.Stmt
+	db             *sql.DB
+	mu             sync.RWMutex
+	saveStmt       *sql.Stmt
+	saveAtStmt     *sql.Stmt // Insert at an explicit position, in strict mode
+	loadStmt       *sql.Stmt
+	loadRangeStmt  *sql.Stmt
+	positionStmt   *sql.Stmt
+	saveSubStmt    *sql.Stmt
+	loadSubStmt    *sql.Stmt
+	streamStmt     *sql.Stmt
+	streamHeadStmt *sql.Stmt
+	loadTimeStmt   *sql.Stmt
+	busy           busyCounters
+	path           string
+	wal            *walMonitor
+	maint          *maintenance
+	lock           io.Closer // Single-writer lock; nil for in-memory databases
+	strict         bool      // Assign dense positions; guarded by mu
+	gaps           gapTracker
+	recovery       recoveryState // Open marker and the report of Recover
+
+	coldTier // Serves positions moved out of the store
 }
 
 // NewSQLiteStore creates a new SQLite-based event store
-func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
+func NewSQLiteStore(dbPath string) (store *SQLiteStore, err error) {
+	// SQLite lets a second server open the same file and write to it, so
+	// hold a single-writer lock for as long as the store is open
+	lock, err := lockSQLite(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if lock != nil {
+		defer func() {
+			if err != nil {
+				lock.Close()
+			}
+		}()
+	}
+
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			db.Close()
+		}
+	}()
 
 	// Connection pool settings for high throughput
 	db.SetMaxOpenConns(25)
@@ -46,13 +87,13 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 
 	// Production-ready SQLite performance tuning
 	pragmas := []string{
-		"PRAGMA journal_mode=WAL",           // Better concurrency
-		"PRAGMA synchronous=NORMAL",         // Good balance of safety/performance
-		"PRAGMA cache_size=-64000",          // 64MB cache
-		"PRAGMA busy_timeout=5000",          // 5s busy timeout
-		"PRAGMA wal_autocheckpoint=1000",    // Checkpoint every 1000 pages
-		"PRAGMA temp_store=MEMORY",          // Keep temp tables in memory
-		"PRAGMA mmap_size=268435456",        // 256MB mmap
+		"PRAGMA journal_mode=WAL",        // Better concurrency
+		"PRAGMA synchronous=NORMAL",      // Good balance of safety/performance
+		"PRAGMA cache_size=-64000",       // 64MB cache
+		"PRAGMA busy_timeout=5000",       // 5s busy timeout
+		"PRAGMA wal_autocheckpoint=1000", // Checkpoint every 1000 pages
+		"PRAGMA temp_store=MEMORY",       // Keep temp tables in memory
+		"PRAGMA mmap_size=268435456",     // 256MB mmap
 	}
 
 	for _, pragma := range pragmas {
@@ -66,35 +107,53 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		return nil, fmt.Errorf("create tables: %w", err)
 	}
 
+	// Bring databases created by older versions up to date
+	if err := migrateTables(db); err != nil {
+		return nil, fmt.Errorf("migrate tables: %w", err)
+	}
+
 	// Prepare statements for better performance
-	store := &SQLiteStore{db: db}
+	store = &SQLiteStore{db: db, path: dbPath, lock: lock}
 	if err := store.prepareStatements(); err != nil {
-		db.Close()
 		return nil, fmt.Errorf("prepare statements: %w", err)
 	}
 
+	// Gaps are detected against the head found on open
+	if store.gaps.head, err = store.GetPosition(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := store.recovery.markOpen(sqliteOpenMarker(dbPath)); err != nil {
+		return nil, err
+	}
+
 	return store, nil
 }
 
 func (s *SQLiteStore) prepareStatements() error {
 	var err error
 
-	s.saveStmt, err = s.db.Prepare("INSERT INTO events (type, data, timestamp) VALUES (?, ?, ?)")
+	s.saveStmt, err = s.db.Prepare("INSERT INTO events (type, data, timestamp, metadata, stream_id, stream_version, time_ns) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("prepare save: %w", err)
 	}
 
-	s.loadStmt, err = s.db.Prepare("SELECT position, type, data, timestamp FROM events WHERE position >= ? ORDER BY position LIMIT ?")
+	s.saveAtStmt, err = s.db.Prepare("INSERT INTO events (position, type, data, timestamp, metadata, stream_id, stream_version, time_ns) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare save at: %w", err)
+	}
+
+	s.loadStmt, err = s.db.Prepare("SELECT " + eventColumns + " FROM events WHERE position >= ? ORDER BY position LIMIT ?")
 	if err != nil {
 		return fmt.Errorf("prepare load: %w", err)
 	}
 
-	s.loadRangeStmt, err = s.db.Prepare("SELECT position, type, data, timestamp FROM events WHERE position >= ? AND position <= ? ORDER BY position")
+	s.loadRangeStmt, err = s.db.Prepare("SELECT " + eventColumns + " FROM events WHERE position >= ? AND position <= ? ORDER BY position")
 	if err != nil {
 		return fmt.Errorf("prepare load range: %w", err)
 	}
 
-	s.positionStmt, err = s.db.Prepare("SELECT MAX(position) FROM events")
+	// Fresh stores report the start position AUTOINCREMENT continues from
+	s.positionStmt, err = s.db.Prepare("SELECT COALESCE(MAX(position), (SELECT seq FROM sqlite_sequence WHERE name = 'events')) FROM events")
 	if err != nil {
 		return fmt.Errorf("prepare position: %w", err)
 	}
@@ -109,6 +168,21 @@ func (s *SQLiteStore) prepareStatements() error {
 		return fmt.Errorf("prepare load subscription: %w", err)
 	}
 
+	s.streamStmt, err = s.db.Prepare("SELECT " + eventColumns + " FROM events WHERE stream_id = ? AND stream_version >= ? ORDER BY stream_version LIMIT ?")
+	if err != nil {
+		return fmt.Errorf("prepare load stream: %w", err)
+	}
+
+	s.streamHeadStmt, err = s.db.Prepare("SELECT COALESCE(MAX(stream_version), 0) FROM events WHERE stream_id = ?")
+	if err != nil {
+		return fmt.Errorf("prepare stream version: %w", err)
+	}
+
+	s.loadTimeStmt, err = s.db.Prepare("SELECT " + eventColumns + " FROM events WHERE time_ns >= ? AND time_ns <= ? AND position >= ? AND position <= ? ORDER BY position LIMIT ?")
+	if err != nil {
+		return fmt.Errorf("prepare load time: %w", err)
+	}
+
 	return nil
 }
 
@@ -118,7 +192,8 @@ func createTables(db *sql.DB) error {
 		position INTEGER PRIMARY KEY AUTOINCREMENT,
 		type TEXT NOT NULL,
 		data BLOB NOT NULL,
-		timestamp DATETIME NOT NULL
+		timestamp DATETIME NOT NULL,
+		metadata TEXT
 	);
 
 	-- Composite index for type-based queries with position range
@@ -143,12 +218,220 @@ func createTables(db *sql.DB) error {
 	return err
 }
 
+// eventColumns are the columns selected for events, in the order
+// eventScanner scans them
+const eventColumns = "position, type, data, timestamp, metadata, stream_id, stream_version"
+
+// migrateTables adds columns introduced after the initial schema, and the
+// indexes on them
+func migrateTables(db *sql.DB) error {
+	columns := []struct {
+		name string
+		ddl  string
+	}{
+		{"metadata", "ALTER TABLE events ADD COLUMN metadata TEXT"},
+		{"stream_id", "ALTER TABLE events ADD COLUMN stream_id TEXT"},
+		{"stream_version", "ALTER TABLE events ADD COLUMN stream_version INTEGER"},
+		{"time_ns", "ALTER TABLE events ADD COLUMN time_ns INTEGER"},
+	}
+
+	rows, err := db.Query("SELECT name FROM pragma_table_info('events')")
+	if err != nil {
+		return fmt.Errorf("read table info: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan table info: %w", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate table info: %w", err)
+	}
+
+	for _, col := range columns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(col.ddl); err != nil {
+			return fmt.Errorf("add column %s: %w", col.name, err)
+		}
+	}
+
+	// Only events of a stream are indexed
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_events_stream ON events(stream_id, stream_version) WHERE stream_id IS NOT NULL"); err != nil {
+		return fmt.Errorf("create stream index: %w", err)
+	}
+
+	// The timestamp column holds the driver's text encoding of time.Time,
+	// which does not sort across time zones, so time ranges are served by
+	// Unix nanoseconds instead
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_events_time ON events(time_ns)"); err != nil {
+		return fmt.Errorf("create time index: %w", err)
+	}
+	if err := migrateRollups(db); err != nil {
+		return fmt.Errorf("create rollups: %w", err)
+	}
+	if err := indexTimes(db); err != nil {
+		return fmt.Errorf("build time index: %w", err)
+	}
+
+	return nil
+}
+
+// nullString maps "" to NULL, so events without a stream stay out of the
+// stream index
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt64 maps 0 to NULL
+func nullInt64(n int64) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// encodeMetadata converts event metadata to a nullable JSON column value
+func encodeMetadata(metadata map[string]string) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("marshal metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// sqliteTime scans the timestamp column. The driver stores time.Time as
+// its String form and parses it back, except for zones without a name,
+// such as the numeric offsets of RFC 3339 input, which come back as text.
+type sqliteTime time.Time
+
+// Scan implements sql.Scanner
+func (t *sqliteTime) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = sqliteTime(v)
+		return nil
+	case string:
+		// "2006-01-02 15:04:05.999999999 -0700 -0700", maybe with a
+		// monotonic clock reading after it; the offset is all that matters
+		fields := strings.Fields(v)
+		if len(fields) >= 3 {
+			parsed, err := time.Parse("2006-01-02 15:04:05.999999999 -0700", strings.Join(fields[:3], " "))
+			if err == nil {
+				*t = sqliteTime(parsed)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unsupported timestamp %v", src)
+}
+
+// Allocation chunk sizes for eventScanner
+const (
+	scanSlabEvents = 1024     // Events allocated per slab
+	scanArenaBytes = 64 << 10 // Payload bytes allocated per arena
+)
+
+// eventScanner scans rows selected as eventColumns.
+// Instead of allocating every event and payload separately, events are carved
+// out of shared slabs, payloads are copied into a shared arena, and type
+// strings are interned, which brings replay scans down to roughly one
+// allocation per event. Slabs and arenas are never reused, so returned events
+// stay valid for as long as callers hold them.
+type eventScanner struct {
+	slab  []StoredEvent
+	arena []byte
+	types map[string]string
+
+	// Driver-owned buffers, valid until the next rows.Next
+	typ      sql.RawBytes
+	data     sql.RawBytes
+	metadata sql.RawBytes
+	streamID sql.RawBytes
+	version  sql.NullInt64
+}
+
+func newEventScanner() *eventScanner {
+	return &eventScanner{types: make(map[string]string)}
+}
+
+// scanAll appends every remaining row to dst; sizeHint sizes the first slab
+func (sc *eventScanner) scanAll(rows *sql.Rows, sizeHint int, dst []*StoredEvent) ([]*StoredEvent, error) {
+	for rows.Next() {
+		if len(sc.slab) == cap(sc.slab) {
+			sc.slab = make([]StoredEvent, 0, min(max(sizeHint, 16), scanSlabEvents))
+		}
+		sc.slab = sc.slab[:len(sc.slab)+1]
+		event := &sc.slab[len(sc.slab)-1]
+
+		if err := rows.Scan(&event.Position, &sc.typ, &sc.data, (*sqliteTime)(&event.Timestamp), &sc.metadata, &sc.streamID, &sc.version); err != nil {
+			return dst, err
+		}
+
+		// Map lookup with string(bytes) does not allocate
+		typ, ok := sc.types[string(sc.typ)]
+		if !ok {
+			typ = string(sc.typ)
+			sc.types[typ] = typ
+		}
+		event.Type = typ
+
+		if len(sc.arena)+len(sc.data) > cap(sc.arena) {
+			sc.arena = make([]byte, 0, max(scanArenaBytes, len(sc.data)))
+		}
+		start := len(sc.arena)
+		sc.arena = append(sc.arena, sc.data...)
+		event.Data = sc.arena[start:len(sc.arena):len(sc.arena)]
+
+		if len(sc.metadata) > 0 {
+			if err := json.Unmarshal(sc.metadata, &event.Metadata); err != nil {
+				return dst, fmt.Errorf("unmarshal metadata: %w", err)
+			}
+		}
+		if len(sc.streamID) > 0 {
+			event.StreamID = string(sc.streamID)
+			event.StreamVersion = sc.version.Int64
+		}
+
+		dst = append(dst, event)
+	}
+	return dst, nil
+}
+
 // Save implements EventStore.Save
 func (s *SQLiteStore) Save(ctx context.Context, event *StoredEvent) error {
+	metadata, err := encodeMetadata(event.Metadata)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	result, err := s.saveStmt.ExecContext(ctx, event.Type, event.Data, event.Timestamp)
+	if s.strict {
+		return s.saveBatch(ctx, []*StoredEvent{event}, Expectation{})
+	}
+
+	var result sql.Result
+	err = s.busy.retryBusy(ctx, func() (err error) {
+		if err := streamVersions([]*StoredEvent{event}, s.streamHead(ctx, s.streamHeadStmt)); err != nil {
+			return err
+		}
+		result, err = s.saveStmt.ExecContext(ctx, event.Type, event.Data, event.Timestamp, metadata, nullString(event.StreamID), nullInt64(event.StreamVersion), unixNano(event.Timestamp))
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("insert event: %w", err)
 	}
@@ -159,6 +442,8 @@ func (s *SQLiteStore) Save(ctx context.Context, event *StoredEvent) error {
 	}
 
 	event.Position = position
+	s.gaps.observe([]*StoredEvent{event})
+	s.noteWrites(1)
 	return nil
 }
 
@@ -171,16 +456,81 @@ func (s *SQLiteStore) SaveBatch(ctx context.Context, events []*StoredEvent) erro
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.saveBatch(ctx, events, Expectation{})
+}
+
+// SaveBatchIf implements ConditionalAppender
+func (s *SQLiteStore) SaveBatchIf(ctx context.Context, events []*StoredEvent, expect Expectation) error {
+	if len(events) == 0 {
+		return expect.Validate(events)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveBatch(ctx, events, expect)
+}
+
+// saveBatch inserts events with mu held
+func (s *SQLiteStore) saveBatch(ctx context.Context, events []*StoredEvent, expect Expectation) error {
+	// The whole transaction is retried, so positions are reassigned on success
+	err := s.busy.retryBusy(ctx, func() error {
+		return s.saveBatchTx(ctx, events, expect)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.gaps.observe(events)
+	s.noteWrites(len(events))
+	return nil
+}
+
+// saveBatchTx inserts events in one transaction, if expect holds
+func (s *SQLiteStore) saveBatchTx(ctx context.Context, events []*StoredEvent, expect Expectation) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	var head sql.NullInt64
+	getHead := func() (int64, error) {
+		if err := tx.StmtContext(ctx, s.positionStmt).QueryRowContext(ctx).Scan(&head); err != nil {
+			return 0, fmt.Errorf("get max position: %w", err)
+		}
+		return head.Int64, nil
+	}
+	streamHead := s.streamHead(ctx, tx.StmtContext(ctx, s.streamHeadStmt))
+	if err := expect.check(events, getHead, streamHead); err != nil {
+		return err
+	}
+
 	stmt := tx.StmtContext(ctx, s.saveStmt)
+	if err := streamVersions(events, streamHead); err != nil {
+		return err
+	}
+
+	// In strict mode positions continue from the current maximum, whatever
+	// AUTOINCREMENT has handed out before
+	if s.strict {
+		if _, err := getHead(); err != nil {
+			return err
+		}
+		stmt = tx.StmtContext(ctx, s.saveAtStmt)
+	}
 
-	for _, event := range events {
-		result, err := stmt.ExecContext(ctx, event.Type, event.Data, event.Timestamp)
+	for i, event := range events {
+		metadata, err := encodeMetadata(event.Metadata)
+		if err != nil {
+			return err
+		}
+
+		args := []any{event.Type, event.Data, event.Timestamp, metadata, nullString(event.StreamID), nullInt64(event.StreamVersion), unixNano(event.Timestamp)}
+		if s.strict {
+			args = append([]any{head.Int64 + int64(i) + 1}, args...)
+		}
+		result, err := stmt.ExecContext(ctx, args...)
 		if err != nil {
 			return fmt.Errorf("insert event: %w", err)
 		}
@@ -203,36 +553,49 @@ func (s *SQLiteStore) SaveBatch(ctx context.Context, events []*StoredEvent) erro
 // Load implements EventStore.Load with pagination for large datasets
 // For production use with large event counts, use LoadStream instead
 func (s *SQLiteStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent, error) {
+	if tier, ok := s.below(from); ok {
+		return loadCold(ctx, tier, from, to, s.Load)
+	}
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return []*StoredEvent{}, nil
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var rows *sql.Rows
-	var err error
+	scanner := newEventScanner()
 
-	if to == -1 {
-		// Default limit to prevent OOM on huge datasets
-		rows, err = s.loadStmt.QueryContext(ctx, from, 10000)
-	} else {
-		rows, err = s.loadRangeStmt.QueryContext(ctx, from, to)
-	}
+	var events []*StoredEvent
+	err := s.busy.retryBusy(ctx, func() error {
+		var rows *sql.Rows
+		var err error
 
-	if err != nil {
-		return nil, fmt.Errorf("query events: %w", err)
-	}
-	defer rows.Close()
+		if to == -1 {
+			// Default limit to prevent OOM on huge datasets
+			rows, err = s.loadStmt.QueryContext(ctx, from, MaxLoadEvents)
+		} else {
+			rows, err = s.loadRangeStmt.QueryContext(ctx, from, to)
+		}
 
-	// Pre-allocate slice with reasonable capacity
-	events := make([]*StoredEvent, 0, 1000)
-	for rows.Next() {
-		var event StoredEvent
-		if err := rows.Scan(&event.Position, &event.Type, &event.Data, &event.Timestamp); err != nil {
-			return nil, fmt.Errorf("scan event: %w", err)
+		if err != nil {
+			return fmt.Errorf("query events: %w", err)
 		}
-		events = append(events, &event)
-	}
+		defer rows.Close()
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate events: %w", err)
+		// Pre-allocate slice with reasonable capacity
+		events, err = scanner.scanAll(rows, 1000, make([]*StoredEvent, 0, 1000))
+		if err != nil {
+			return fmt.Errorf("scan event: %w", err)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return events, nil
@@ -241,33 +604,39 @@ func (s *SQLiteStore) Load(ctx context.Context, from, to int64) ([]*StoredEvent,
 // LoadStream loads events in batches and calls handler for each batch
 // This prevents loading huge datasets into memory at once
 func (s *SQLiteStore) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error {
-	if batchSize <= 0 {
-		batchSize = 1000
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, sameEvent, handler, s.LoadStream)
 	}
+	batchSize = streamBatchSize(batchSize)
 
-	position := from
-	for {
-		s.mu.RLock()
-		rows, err := s.loadStmt.QueryContext(ctx, position, batchSize)
-		s.mu.RUnlock()
+	// One scanner for the whole stream keeps interned types across batches
+	scanner := newEventScanner()
 
-		if err != nil {
-			return fmt.Errorf("query events: %w", err)
-		}
+	position := streamStart(from)
+	for {
+		var batch []*StoredEvent
+		err := s.busy.retryBusy(ctx, func() error {
+			s.mu.RLock()
+			rows, err := s.loadStmt.QueryContext(ctx, position, batchSize)
+			s.mu.RUnlock()
+
+			if err != nil {
+				return fmt.Errorf("query events: %w", err)
+			}
 
-		batch := make([]*StoredEvent, 0, batchSize)
-		for rows.Next() {
-			var event StoredEvent
-			if err := rows.Scan(&event.Position, &event.Type, &event.Data, &event.Timestamp); err != nil {
-				rows.Close()
+			batch, err = scanner.scanAll(rows, batchSize, make([]*StoredEvent, 0, batchSize))
+			rows.Close()
+			if err != nil {
 				return fmt.Errorf("scan event: %w", err)
 			}
-			batch = append(batch, &event)
-		}
-		rows.Close()
 
-		if err := rows.Err(); err != nil {
-			return fmt.Errorf("iterate events: %w", err)
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterate events: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
 		if len(batch) == 0 {
@@ -290,13 +659,103 @@ func (s *SQLiteStore) LoadStream(ctx context.Context, from int64, batchSize int,
 	return nil
 }
 
+// LoadStreamRaw implements RawStreamer. Rows are encoded straight from the
+// driver's buffers, so event payloads are never unmarshaled.
+func (s *SQLiteStore) LoadStreamRaw(ctx context.Context, from int64, batchSize int, handler func([]json.RawMessage) error) error {
+	if tier, ok := s.below(from); ok {
+		return streamCold(ctx, tier, from, batchSize, rawEvent, handler, s.LoadStreamRaw)
+	}
+	batchSize = streamBatchSize(batchSize)
+
+	var (
+		buf      []byte
+		offsets  []int
+		typ      sql.RawBytes
+		metadata sql.NullString
+		streamID sql.NullString
+		version  sql.NullInt64
+		event    StoredEvent
+	)
+
+	position := streamStart(from)
+	for {
+		err := s.busy.retryBusy(ctx, func() error {
+			buf, offsets = nil, nil
+
+			s.mu.RLock()
+			rows, err := s.loadStmt.QueryContext(ctx, position, batchSize)
+			s.mu.RUnlock()
+
+			if err != nil {
+				return fmt.Errorf("query events: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				if err := rows.Scan(&event.Position, &typ, (*sql.RawBytes)(&event.Data), (*sqliteTime)(&event.Timestamp), &metadata, &streamID, &version); err != nil {
+					return fmt.Errorf("scan event: %w", err)
+				}
+				event.Type = string(typ)
+				event.StreamID, event.StreamVersion = streamID.String, version.Int64
+				event.Metadata = nil
+				if metadata.Valid && metadata.String != "" {
+					if err := json.Unmarshal([]byte(metadata.String), &event.Metadata); err != nil {
+						return fmt.Errorf("unmarshal metadata: %w", err)
+					}
+				}
+
+				offsets = append(offsets, len(buf))
+				if buf, err = appendEventJSON(buf, &event); err != nil {
+					return fmt.Errorf("encode event: %w", err)
+				}
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterate events: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(offsets) == 0 {
+			break
+		}
+
+		// Slice the shared buffer into one message per event
+		batch := make([]json.RawMessage, len(offsets))
+		for i, start := range offsets {
+			end := len(buf)
+			if i+1 < len(offsets) {
+				end = offsets[i+1]
+			}
+			batch[i] = buf[start:end:end]
+		}
+
+		if err := handler(batch); err != nil {
+			return fmt.Errorf("handle batch: %w", err)
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+
+		position = event.Position + 1
+	}
+
+	return nil
+}
+
 // GetPosition implements EventStore.GetPosition
 func (s *SQLiteStore) GetPosition(ctx context.Context) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var position sql.NullInt64
-	err := s.positionStmt.QueryRowContext(ctx).Scan(&position)
+	err := s.busy.retryBusy(ctx, func() error {
+		return s.positionStmt.QueryRowContext(ctx).Scan(&position)
+	})
 	if err != nil {
 		return 0, fmt.Errorf("get max position: %w", err)
 	}
@@ -313,7 +772,10 @@ func (s *SQLiteStore) SaveSubscriptionPosition(ctx context.Context, subscription
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.saveSubStmt.ExecContext(ctx, subscriptionID, position)
+	err := s.busy.retryBusy(ctx, func() error {
+		_, err := s.saveSubStmt.ExecContext(ctx, subscriptionID, position)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("save subscription position: %w", err)
 	}
@@ -327,7 +789,9 @@ func (s *SQLiteStore) LoadSubscriptionPosition(ctx context.Context, subscription
 	defer s.mu.RUnlock()
 
 	var position sql.NullInt64
-	err := s.loadSubStmt.QueryRowContext(ctx, subscriptionID).Scan(&position)
+	err := s.busy.retryBusy(ctx, func() error {
+		return s.loadSubStmt.QueryRowContext(ctx, subscriptionID).Scan(&position)
+	})
 
 	if err == sql.ErrNoRows {
 		return 0, nil
@@ -345,10 +809,16 @@ func (s *SQLiteStore) LoadSubscriptionPosition(ctx context.Context, subscription
 
 // Close closes the database connection and prepared statements
 func (s *SQLiteStore) Close() error {
+	s.stopWALMonitor()
+	s.stopMaintenance()
+
 	// Close prepared statements
 	if s.saveStmt != nil {
 		s.saveStmt.Close()
 	}
+	if s.saveAtStmt != nil {
+		s.saveAtStmt.Close()
+	}
 	if s.loadStmt != nil {
 		s.loadStmt.Close()
 	}
@@ -364,6 +834,18 @@ func (s *SQLiteStore) Close() error {
 	if s.loadSubStmt != nil {
 		s.loadSubStmt.Close()
 	}
+	if s.loadTimeStmt != nil {
+		s.loadTimeStmt.Close()
+	}
 
-	return s.db.Close()
+	err := s.db.Close()
+	if err == nil {
+		err = s.recovery.markClosed()
+	}
+	if s.lock != nil {
+		if lockErr := s.lock.Close(); err == nil {
+			err = lockErr
+		}
+	}
+	return err
 }
@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,6 +15,7 @@ func TestSQLiteStore(t *testing.T) {
 	// Create temporary database
 	dbPath := "test_events.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -109,6 +113,7 @@ func TestSQLiteStore(t *testing.T) {
 func TestEmptyStore(t *testing.T) {
 	dbPath := "test_empty.db"
 	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".lock")
 
 	store, err := NewSQLiteStore(dbPath)
 	if err != nil {
@@ -151,3 +156,107 @@ func TestEmptyStore(t *testing.T) {
 		}
 	})
 }
+
+func TestSQLiteStore_Metadata(t *testing.T) {
+	store, err := NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	events := []*StoredEvent{
+		{Type: "WithMetadata", Data: json.RawMessage(`{}`), Metadata: map[string]string{"caller": "svc"}},
+		{Type: "WithoutMetadata", Data: json.RawMessage(`{}`)},
+	}
+	if err := store.SaveBatch(ctx, events); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	loaded, err := store.Load(ctx, 1, -1)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded[0].Metadata["caller"] != "svc" {
+		t.Errorf("Expected metadata caller=svc, got %v", loaded[0].Metadata)
+	}
+	if loaded[1].Metadata != nil {
+		t.Errorf("Expected nil metadata, got %v", loaded[1].Metadata)
+	}
+}
+
+func TestSQLiteStore_MigratesOldSchema(t *testing.T) {
+	dbPath := t.TempDir() + "/old.db"
+
+	// Create a database with the original schema (no metadata column)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE events (
+		position INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		data BLOB NOT NULL,
+		timestamp DATETIME NOT NULL
+	);
+	INSERT INTO events (type, data, timestamp) VALUES ('Old', CAST('{}' AS BLOB), '2024-01-01T00:00:00Z');`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer store.Close()
+
+	events, err := store.Load(context.Background(), 1, -1)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "Old" {
+		t.Errorf("Expected the old event to survive migration, got %v", events)
+	}
+}
+
+func TestSQLiteStore_LoadStreamRetainedBatches(t *testing.T) {
+	store, err := NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 1; i <= 50; i++ {
+		event := &StoredEvent{
+			Type:      fmt.Sprintf("Type%d", i%3),
+			Data:      json.RawMessage(fmt.Sprintf(`{"index":%d,"pad":"%s"}`, i, strings.Repeat("x", i*100))),
+			Timestamp: time.Now(),
+		}
+		if err := store.Save(ctx, event); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// Batches share slabs internally; retained events must not be overwritten
+	var all []*StoredEvent
+	err = store.LoadStream(ctx, 1, 7, func(batch []*StoredEvent) error {
+		all = append(all, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream failed: %v", err)
+	}
+
+	if len(all) != 50 {
+		t.Fatalf("Expected 50 events, got %d", len(all))
+	}
+	for i, event := range all {
+		n := i + 1
+		want := fmt.Sprintf(`{"index":%d,"pad":"%s"}`, n, strings.Repeat("x", n*100))
+		if event.Position != int64(n) || string(event.Data) != want || event.Type != fmt.Sprintf("Type%d", n%3) {
+			t.Fatalf("Event %d corrupted: position=%d type=%s", n, event.Position, event.Type)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// errNotFresh is returned when a start position is set on a store that has
+// a position already
+var errNotFresh = errors.New("store is not empty")
+
+// StartPositioner is implemented by stores whose numbering can start after a
+// given head, so a store rebuilt after a migration continues the positions
+// of the old one and consumers' checkpoints stay valid. A fresh store then
+// reports head as its position and gives its first event head+1. Stores
+// with a position other than 0 refuse.
+type StartPositioner interface {
+	SetStartPosition(ctx context.Context, head int64) error
+}
+
+// StartAt sets the start position of st if it is fresh and reports whether
+// it did. Stores with a position are left alone, so StartAt can run on
+// every start of a server.
+func StartAt(ctx context.Context, st EventStore, head int64) (bool, error) {
+	if head <= 0 {
+		return false, nil
+	}
+	position, err := st.GetPosition(ctx)
+	if err != nil || position != 0 {
+		return false, err
+	}
+	starter, ok := As[StartPositioner](st)
+	if !ok {
+		return false, fmt.Errorf("%T does not support start positions", st)
+	}
+	if err := starter.SetStartPosition(ctx, head); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetStartPosition implements StartPositioner
+func (s *MemoryStore) SetStartPosition(ctx context.Context, head int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errMemoryClosed
+	}
+	if s.position != 0 {
+		return errNotFresh
+	}
+	s.position = head
+	return nil
+}
+
+// SetStartPosition implements StartPositioner. The head is kept under
+// positionKey, which initializePosition reads while there are no events.
+func (s *PebbleStore) SetStartPosition(ctx context.Context, head int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.position.Load() != 0 {
+		return errNotFresh
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(head))
+	if err := s.db.Set([]byte(positionKey), value, pebble.Sync); err != nil {
+		return fmt.Errorf("save start position: %w", err)
+	}
+	s.position.Store(head)
+	return nil
+}
+
+// SetStartPosition implements StartPositioner by moving the AUTOINCREMENT
+// counter, which GetPosition falls back to while there are no events
+func (s *SQLiteStore) SetStartPosition(ctx context.Context, head int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.busy.retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var position *int64
+		if err := tx.StmtContext(ctx, s.positionStmt).QueryRowContext(ctx).Scan(&position); err != nil {
+			return fmt.Errorf("get max position: %w", err)
+		}
+		if position != nil && *position != 0 {
+			return errNotFresh
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM sqlite_sequence WHERE name = 'events'"); err != nil {
+			return fmt.Errorf("reset sequence: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO sqlite_sequence (name, seq) VALUES ('events', ?)", head); err != nil {
+			return fmt.Errorf("save start position: %w", err)
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return err
+	}
+
+	s.gaps.mu.Lock()
+	s.gaps.head = head
+	s.gaps.mu.Unlock()
+	return nil
+}
+
+// SetStartPosition implements StartPositioner. The head is kept in the
+// start_position table, which positionQuery falls back to while there are
+// no events.
+func (s *PostgresStore) SetStartPosition(ctx context.Context, head int64) error {
+	return s.write(ctx, func(tx *sql.Tx, position int64) error {
+		if position != 0 {
+			return errNotFresh
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+s.schema+".start_position (head) VALUES ($1)", head); err != nil {
+			return fmt.Errorf("save start position: %w", err)
+		}
+		return nil
+	})
+}
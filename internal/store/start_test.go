@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartAt(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := map[string]func(t *testing.T) EventStore{
+		"sqlite": func(t *testing.T) EventStore {
+			st, err := NewSQLiteStore(filepath.Join(dir, "events.db"))
+			if err != nil {
+				t.Fatalf("failed to open sqlite store: %v", err)
+			}
+			return st
+		},
+		"sqlite-strict": func(t *testing.T) EventStore {
+			st, err := NewSQLiteStore(filepath.Join(dir, "strict.db"))
+			if err != nil {
+				t.Fatalf("failed to open sqlite store: %v", err)
+			}
+			st.SetStrictPositions(true)
+			return st
+		},
+		"pebble": func(t *testing.T) EventStore {
+			st, err := NewPebbleStore(filepath.Join(dir, "pebble"))
+			if err != nil {
+				t.Fatalf("failed to open pebble store: %v", err)
+			}
+			return st
+		},
+		"postgres": func(t *testing.T) EventStore {
+			st, _, _ := newTestPostgresStore(t)
+			return st
+		},
+	}
+
+	for name, openStore := range open {
+		t.Run(name, func(t *testing.T) {
+			st := openStore(t)
+			defer st.Close()
+
+			started, err := StartAt(ctx, st, 1_000_000)
+			if err != nil || !started {
+				t.Fatalf("StartAt() = %v, %v", started, err)
+			}
+			if pos, _ := st.GetPosition(ctx); pos != 1_000_000 {
+				t.Errorf("position = %d, want 1000000", pos)
+			}
+
+			// A second start leaves the store alone
+			if started, err := StartAt(ctx, st, 5); err != nil || started {
+				t.Errorf("second StartAt() = %v, %v", started, err)
+			}
+			if err := st.(StartPositioner).SetStartPosition(ctx, 5); !errors.Is(err, errNotFresh) {
+				t.Errorf("SetStartPosition() on a started store error = %v", err)
+			}
+
+			event := &StoredEvent{Type: "Placed", Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+			if err := st.Save(ctx, event); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if event.Position != 1_000_001 {
+				t.Errorf("first position = %d, want 1000001", event.Position)
+			}
+			if sqliteStore, ok := st.(*SQLiteStore); ok && sqliteStore.GapStats().Detected != 0 {
+				t.Errorf("the start was reported as a gap: %+v", sqliteStore.GapStats())
+			}
+		})
+	}
+
+	// The start survives reopening a store that has no events yet
+	path := filepath.Join(t.TempDir(), "fresh")
+	st, err := NewPebbleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartAt(ctx, st, 42); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+	if st, err = NewPebbleStore(path); err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if pos, _ := st.GetPosition(ctx); pos != 42 {
+		t.Errorf("position after reopening = %d, want 42", pos)
+	}
+
+	memory := NewMemoryStore()
+	if _, err := StartAt(ctx, memory, 7); err != nil {
+		t.Fatal(err)
+	}
+	events := []*StoredEvent{{Type: "A", Data: json.RawMessage(`{}`)}}
+	if err := memory.SaveBatch(ctx, events); err != nil || events[0].Position != 8 {
+		t.Errorf("memory store first position = %d, %v", events[0].Position, err)
+	}
+}
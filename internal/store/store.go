@@ -1,15 +1,39 @@
 package store
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
 
 // EventStore defines the interface for event storage backends
 type EventStore interface {
 	Save(ctx context.Context, event *StoredEvent) error
+	// SaveBatch assigns events one contiguous range of positions, in
+	// order, so the range is known from its first position. Batches saved
+	// concurrently never interleave.
 	SaveBatch(ctx context.Context, events []*StoredEvent) error
 	Load(ctx context.Context, from, to int64) ([]*StoredEvent, error)
+	// LoadByTime is Load restricted to events with since <= Timestamp <
+	// until; a zero since or until leaves that side open. Timestamps need
+	// not follow positions, so results stay in position order and from/to
+	// page through them as with Load.
+	LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error)
 	LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*StoredEvent) error) error
 	GetPosition(ctx context.Context) (int64, error)
 	SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error
 	LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error)
+	// DeleteBefore deletes the events with positions before position and
+	// returns how many it deleted. The event at the head and the last event
+	// of every stream are always kept, so new events continue their
+	// positions and stream versions.
+	DeleteBefore(ctx context.Context, position int64) (int64, error)
 	Close() error
 }
+
+// RawStreamer is implemented by stores that can stream events as ready-made
+// JSON, skipping the unmarshal/re-marshal round trip of LoadStream. Each
+// message is the same encoding json.Marshal produces for a StoredEvent.
+type RawStreamer interface {
+	LoadStreamRaw(ctx context.Context, from int64, batchSize int, handler func([]json.RawMessage) error) error
+}
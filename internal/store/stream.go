@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// MaxStreamIDBytes limits the length of StoredEvent.StreamID
+const MaxStreamIDBytes = 256
+
+// StreamIndex is implemented by stores that index events by StreamID, so an
+// aggregate can be rehydrated without reading the whole log. Save and
+// SaveBatch assign each event with a StreamID the next version of its
+// stream, overwriting any StreamVersion it carries.
+type StreamIndex interface {
+	// LoadByStream returns up to limit events of the stream, starting at
+	// fromVersion, in version order
+	LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*StoredEvent, error)
+
+	// StreamVersion returns the version of the stream's last event, or 0
+	// for a stream without events
+	StreamVersion(ctx context.Context, streamID string) (int64, error)
+}
+
+// ValidateStreamID rejects stream IDs the stores cannot index
+func ValidateStreamID(streamID string) error {
+	if len(streamID) > MaxStreamIDBytes {
+		return fmt.Errorf("stream_id is %d bytes, max %d", len(streamID), MaxStreamIDBytes)
+	}
+	return nil
+}
+
+// streamVersions assigns stream versions to events in order, starting after
+// the head returned by head for each stream's first event
+func streamVersions(events []*StoredEvent, head func(streamID string) (int64, error)) error {
+	var versions map[string]int64
+	for _, event := range events {
+		if event.StreamID == "" {
+			event.StreamVersion = 0
+			continue
+		}
+		if err := ValidateStreamID(event.StreamID); err != nil {
+			return err
+		}
+		if versions == nil {
+			versions = make(map[string]int64)
+		}
+		version, ok := versions[event.StreamID]
+		if !ok {
+			var err error
+			if version, err = head(event.StreamID); err != nil {
+				return err
+			}
+		}
+		version++
+		versions[event.StreamID] = version
+		event.StreamVersion = version
+	}
+	return nil
+}
+
+// streamLimit applies the default and maximum to a LoadByStream limit
+func streamLimit(limit int) int {
+	if limit <= 0 || limit > 10000 {
+		return 10000
+	}
+	return limit
+}
+
+// streamHead returns a stream version lookup for streamVersions using
+// stmt, the prepared stream head statement or its transaction copy
+func (s *SQLiteStore) streamHead(ctx context.Context, stmt *sql.Stmt) func(string) (int64, error) {
+	return func(streamID string) (int64, error) {
+		var version int64
+		if err := stmt.QueryRowContext(ctx, streamID).Scan(&version); err != nil {
+			return 0, fmt.Errorf("get stream version: %w", err)
+		}
+		return version, nil
+	}
+}
+
+// LoadByStream implements StreamIndex
+func (s *SQLiteStore) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scanner := newEventScanner()
+
+	var events []*StoredEvent
+	err := s.busy.retryBusy(ctx, func() error {
+		rows, err := s.streamStmt.QueryContext(ctx, streamID, fromVersion, streamLimit(limit))
+		if err != nil {
+			return fmt.Errorf("query stream: %w", err)
+		}
+		defer rows.Close()
+
+		events, err = scanner.scanAll(rows, 64, nil)
+		if err != nil {
+			return fmt.Errorf("scan event: %w", err)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// StreamVersion implements StreamIndex
+func (s *SQLiteStore) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var version int64
+	err := s.busy.retryBusy(ctx, func() (err error) {
+		version, err = s.streamHead(ctx, s.streamHeadStmt)(streamID)
+		return err
+	})
+	return version, err
+}
+
+// streamKey orders a stream's index entries by version. The length prefix
+// keeps one stream's keys from interleaving with a longer stream ID that
+// shares its prefix.
+func streamKey(streamID string, version int64) []byte {
+	key := make([]byte, 3+len(streamID)+8)
+	key[0] = streamPrefix
+	binary.BigEndian.PutUint16(key[1:], uint16(len(streamID)))
+	copy(key[3:], streamID)
+	binary.BigEndian.PutUint64(key[3+len(streamID):], uint64(version))
+	return key
+}
+
+// setStreamKey adds the stream index entry of event to batch, if it has one
+func setStreamKey(batch *pebble.Batch, event *StoredEvent) error {
+	if event.StreamID == "" || event.StreamVersion <= 0 {
+		return nil
+	}
+	if err := ValidateStreamID(event.StreamID); err != nil {
+		return err
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(event.Position))
+	if err := batch.Set(streamKey(event.StreamID, event.StreamVersion), value, nil); err != nil {
+		return fmt.Errorf("batch set stream index: %w", err)
+	}
+	return nil
+}
+
+// streamHead returns the version of the stream's last index entry
+func (s *PebbleStore) streamHead(streamID string) (int64, error) {
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: streamKey(streamID, 0),
+		UpperBound: streamKey(streamID, math.MaxInt64),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	if !iter.Last() {
+		return 0, iter.Error()
+	}
+	key := iter.Key()
+	return int64(binary.BigEndian.Uint64(key[len(key)-8:])), nil
+}
+
+// LoadByStream implements StreamIndex. Index entries left behind by a
+// repair that moved an event to another stream are skipped.
+func (s *PebbleStore) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*StoredEvent, error) {
+	limit = streamLimit(limit)
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: streamKey(streamID, max(fromVersion, 1)),
+		UpperBound: streamKey(streamID, math.MaxInt64),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	var events []*StoredEvent
+	for iter.First(); iter.Valid() && len(events) < limit; iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		key := iter.Key()
+		version := int64(binary.BigEndian.Uint64(key[len(key)-8:]))
+		position := int64(binary.BigEndian.Uint64(iter.Value()))
+
+		data, closer, err := s.db.Get(eventKey(position))
+		if err == pebble.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get event %d: %w", position, err)
+		}
+		var event StoredEvent
+		err = json.Unmarshal(data, &event)
+		closer.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal event: %w", err)
+		}
+		if event.StreamID != streamID || event.StreamVersion != version {
+			continue
+		}
+		events = append(events, &event)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return events, nil
+}
+
+// StreamVersion implements StreamIndex
+func (s *PebbleStore) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	return s.streamHead(streamID)
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamIndex(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/streams.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/streams")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		StreamIndex
+	}{sqliteStore, pebbleStore, NewMemoryStore()} {
+		event := func(typ, streamID string) *StoredEvent {
+			// Versions sent by callers are overwritten
+			return &StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: time.Now(), StreamID: streamID, StreamVersion: 99}
+		}
+
+		// "order-1" and "order-10" share a prefix but are separate streams
+		if err := st.Save(ctx, event("Created", "order-1")); err != nil {
+			t.Fatalf("%T.Save failed: %v", st, err)
+		}
+		batch := []*StoredEvent{event("Paid", "order-1"), event("Created", "order-10"), event("Tick", ""), event("Shipped", "order-1")}
+		if err := st.SaveBatch(ctx, batch); err != nil {
+			t.Fatalf("%T.SaveBatch failed: %v", st, err)
+		}
+		if batch[2].StreamVersion != 0 || batch[3].StreamVersion != 3 {
+			t.Errorf("%T: expected versions 0 and 3, got %d and %d", st, batch[2].StreamVersion, batch[3].StreamVersion)
+		}
+
+		events, err := st.LoadByStream(ctx, "order-1", 2, 0)
+		if err != nil {
+			t.Fatalf("%T.LoadByStream failed: %v", st, err)
+		}
+		if len(events) != 2 || events[0].Type != "Paid" || events[1].Type != "Shipped" {
+			t.Fatalf("%T: expected Paid and Shipped, got %d events", st, len(events))
+		}
+		if events[0].StreamVersion != 2 || events[0].Position != 2 || events[1].Position != 5 {
+			t.Errorf("%T: unexpected version or positions: %+v, %+v", st, events[0], events[1])
+		}
+		if events, _ := st.LoadByStream(ctx, "order-1", 1, 1); len(events) != 1 || events[0].Type != "Created" {
+			t.Errorf("%T: expected the limit to apply, got %d events", st, len(events))
+		}
+
+		for streamID, want := range map[string]int64{"order-1": 3, "order-10": 1, "unknown": 0} {
+			if version, err := st.StreamVersion(ctx, streamID); err != nil || version != want {
+				t.Errorf("%T: expected %s at version %d, got %d, %v", st, streamID, want, version, err)
+			}
+		}
+
+		// Stream fields survive a plain load
+		if loaded, _ := st.Load(ctx, 3, 3); len(loaded) != 1 || loaded[0].StreamID != "order-10" || loaded[0].StreamVersion != 1 {
+			t.Errorf("%T: expected order-10 version 1 at position 3, got %+v", st, loaded)
+		}
+
+		if err := st.Save(ctx, event("Huge", strings.Repeat("x", MaxStreamIDBytes+1))); err == nil {
+			t.Errorf("%T: expected error for an oversized stream ID", st)
+		}
+	}
+}
+
+func TestStreamIndex_Import(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/streams.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/streams")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		Importer
+		StreamIndex
+	}{sqliteStore, pebbleStore, NewMemoryStore()} {
+		imported := []*StoredEvent{
+			{Position: 1, Type: "Created", Data: json.RawMessage(`{}`), StreamID: "cart", StreamVersion: 1},
+			{Position: 3, Type: "Added", Data: json.RawMessage(`{}`), StreamID: "cart", StreamVersion: 2},
+		}
+		if err := st.ImportEvents(ctx, imported); err != nil {
+			t.Fatalf("%T.ImportEvents failed: %v", st, err)
+		}
+
+		// Imported versions are kept and new events continue the stream
+		event := &StoredEvent{Type: "Removed", Data: json.RawMessage(`{}`), StreamID: "cart"}
+		if err := st.Save(ctx, event); err != nil || event.StreamVersion != 3 {
+			t.Errorf("%T: expected version 3 after import, got %d, %v", st, event.StreamVersion, err)
+		}
+		if events, _ := st.LoadByStream(ctx, "cart", 0, 0); len(events) != 3 || events[1].Position != 3 {
+			t.Errorf("%T: expected 3 stream events, got %d", st, len(events))
+		}
+	}
+}
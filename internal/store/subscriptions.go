@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"maps"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// SubscriptionLister is implemented by stores that can list the positions
+// of all their subscriptions, so consumer checkpoints can be exported and
+// carried over to another store alongside the events
+type SubscriptionLister interface {
+	Subscriptions(ctx context.Context) (map[string]int64, error)
+}
+
+// Subscriptions implements SubscriptionLister
+func (s *MemoryStore) Subscriptions(ctx context.Context) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, errMemoryClosed
+	}
+	return maps.Clone(s.subscriptions), nil
+}
+
+// Subscriptions implements SubscriptionLister
+func (s *PebbleStore) Subscriptions(ctx context.Context) (map[string]int64, error) {
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte{subscriptionPrefix},
+		UpperBound: []byte{subscriptionPrefix + 1},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+	defer iter.Close()
+
+	positions := make(map[string]int64)
+	for iter.First(); iter.Valid(); iter.Next() {
+		if value := iter.Value(); len(value) == 8 {
+			positions[string(iter.Key()[1:])] = int64(binary.BigEndian.Uint64(value))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+	return positions, nil
+}
+
+// Subscriptions implements SubscriptionLister
+func (s *SQLiteStore) Subscriptions(ctx context.Context) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var positions map[string]int64
+	err := s.busy.retryBusy(ctx, func() error {
+		positions = make(map[string]int64)
+		return scanSubscriptions(ctx, s.db, "SELECT subscription_id, position FROM subscriptions", positions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// Subscriptions implements SubscriptionLister
+func (s *PostgresStore) Subscriptions(ctx context.Context) (map[string]int64, error) {
+	positions := make(map[string]int64)
+	if err := scanSubscriptions(ctx, s.db, "SELECT subscription_id, position FROM "+s.schema+".subscriptions", positions); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// scanSubscriptions reads subscription IDs and positions from query into
+// positions
+func scanSubscriptions(ctx context.Context, db *sql.DB, query string, positions map[string]int64) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var position int64
+		if err := rows.Scan(&id, &position); err != nil {
+			return fmt.Errorf("scan subscription: %w", err)
+		}
+		positions[id] = position
+	}
+	return rows.Err()
+}
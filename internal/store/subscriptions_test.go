@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := map[string]func(t *testing.T) EventStore{
+		"memory": func(t *testing.T) EventStore { return NewMemoryStore() },
+		"sqlite": func(t *testing.T) EventStore {
+			st, err := NewSQLiteStore(filepath.Join(dir, "events.db"))
+			if err != nil {
+				t.Fatalf("failed to open sqlite store: %v", err)
+			}
+			return st
+		},
+		"pebble": func(t *testing.T) EventStore {
+			st, err := NewPebbleStore(filepath.Join(dir, "pebble"))
+			if err != nil {
+				t.Fatalf("failed to open pebble store: %v", err)
+			}
+			return st
+		},
+		"postgres": func(t *testing.T) EventStore {
+			st, _, _ := newTestPostgresStore(t)
+			return st
+		},
+	}
+
+	for name, openStore := range open {
+		t.Run(name, func(t *testing.T) {
+			st := openStore(t)
+			defer st.Close()
+			lister := st.(SubscriptionLister)
+
+			positions, err := lister.Subscriptions(ctx)
+			if err != nil || len(positions) != 0 {
+				t.Fatalf("Subscriptions() of a new store = %v, %v", positions, err)
+			}
+
+			want := map[string]int64{"billing": 42, "search": 0, "emails:eu": 7}
+			for id, position := range want {
+				if err := st.SaveSubscriptionPosition(ctx, id, position); err != nil {
+					t.Fatal(err)
+				}
+			}
+			st.SaveSubscriptionPosition(ctx, "billing", 43)
+			want["billing"] = 43
+
+			positions, err = lister.Subscriptions(ctx)
+			if err != nil {
+				t.Fatalf("Subscriptions() error = %v", err)
+			}
+			if !maps.Equal(positions, want) {
+				t.Errorf("Subscriptions() = %v, want %v", positions, want)
+			}
+		})
+	}
+}
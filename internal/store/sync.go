@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Syncer is implemented by stores whose writes may be acknowledged before
+// they are durable. Sync returns once every event up to position upTo (or
+// all events, if upTo is -1) would survive a crash.
+type Syncer interface {
+	Sync(ctx context.Context, upTo int64) error
+}
+
+// Sync implements Syncer. Writes use NoSync, so the WAL is fsynced here;
+// the sync covers every write committed before it, whatever upTo is.
+func (s *PebbleStore) Sync(ctx context.Context, upTo int64) error {
+	if err := s.db.LogData(nil, pebble.Sync); err != nil {
+		return fmt.Errorf("sync wal: %w", err)
+	}
+	return nil
+}
+
+// Sync implements Syncer. With synchronous=NORMAL, WAL commits are only
+// fsynced by checkpoints; a passive checkpoint syncs the WAL without
+// waiting for readers or blocking writers.
+func (s *SQLiteStore) Sync(ctx context.Context, upTo int64) error {
+	err := s.busy.retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("wal checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/sync.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/sync")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		Syncer
+	}{sqliteStore, pebbleStore} {
+		if err := st.Save(ctx, &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("%T.Save failed: %v", st, err)
+		}
+		if err := st.Sync(ctx, 1); err != nil {
+			t.Errorf("%T.Sync failed: %v", st, err)
+		}
+	}
+}
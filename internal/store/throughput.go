@@ -0,0 +1,390 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// ThroughputPoint counts the events of one type stamped within one minute
+type ThroughputPoint struct {
+	Minute time.Time `json:"minute"`
+	Type   string    `json:"type"`
+	Count  int64     `json:"count"`
+}
+
+// ThroughputSeries is implemented by stores that keep per-minute event
+// counts per type up to date with every write, so throughput is read from
+// these rollups in time proportional to the range asked for rather than the
+// size of the log. Throughput returns the counts of the minutes in [since,
+// until), both multiples of a minute, restricted to types unless empty, by
+// minute, then type. Minutes without events are left out.
+type ThroughputSeries interface {
+	Throughput(ctx context.Context, since, until time.Time, types []string) ([]ThroughputPoint, error)
+}
+
+// LoadThroughput returns the per-minute counts of the minutes overlapping
+// [since, until) from the rollups of st, or by aggregating its events if it
+// keeps none
+func LoadThroughput(ctx context.Context, st EventStore, since, until time.Time, types []string) ([]ThroughputPoint, error) {
+	since = since.Truncate(time.Minute)
+	if rounded := until.Truncate(time.Minute); rounded.Before(until) {
+		until = rounded.Add(time.Minute)
+	}
+	if !since.Before(until) {
+		return []ThroughputPoint{}, nil
+	}
+
+	if series, ok := As[ThroughputSeries](st); ok {
+		return series.Throughput(ctx, since, until, types)
+	}
+
+	result, err := AggregateEvents(ctx, st, AggregateQuery{
+		From:        1,
+		To:          -1,
+		Since:       since,
+		Until:       until,
+		Filter:      Filter{Types: types},
+		GroupByType: true,
+		Bucket:      time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+	points := make([]ThroughputPoint, len(result.Groups))
+	for i, group := range result.Groups {
+		points[i] = ThroughputPoint{Minute: group.Bucket, Type: group.Type, Count: group.Count}
+	}
+	return points, nil
+}
+
+// rollupKey identifies the count of one type in one minute
+type rollupKey struct {
+	minute int64 // Minutes since the Unix epoch
+	typ    string
+}
+
+// rollupDeltas are the changes a write makes to the per-minute counts
+type rollupDeltas map[rollupKey]int64
+
+// add counts n more events like event
+func (d rollupDeltas) add(event *StoredEvent, n int64) {
+	d[rollupKey{eventMinute(event.Timestamp), event.Type}] += n
+}
+
+// eventMinute returns the minute t falls into, rounding down for times
+// before the epoch too
+func eventMinute(t time.Time) int64 {
+	nanos, minute := unixNano(t), int64(time.Minute)
+	m := nanos / minute
+	if nanos%minute < 0 {
+		m--
+	}
+	return m
+}
+
+// minuteRange converts [since, until) to inclusive bounds in minutes
+func minuteRange(since, until time.Time) (int64, int64) {
+	lo, hi, _ := timeRange(since, until)
+	return eventMinute(time.Unix(0, lo)), eventMinute(time.Unix(0, hi))
+}
+
+// throughputPoint converts a rollup to a point
+func throughputPoint(minute int64, typ string, count int64) ThroughputPoint {
+	return ThroughputPoint{Minute: time.Unix(minute*60, 0).UTC(), Type: typ, Count: count}
+}
+
+// sqliteRollups keeps the rollups table in step with events through
+// triggers, so every write path, imports and repairs included, updates it
+// in the same transaction. Rows without time_ns are counted once
+// indexTimes fills it in.
+var sqliteRollups = []string{
+	`CREATE TABLE IF NOT EXISTS rollups (
+		minute INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		count INTEGER NOT NULL,
+		PRIMARY KEY (minute, type)
+	) WITHOUT ROWID`,
+	`CREATE TRIGGER IF NOT EXISTS events_rollup_insert AFTER INSERT ON events WHEN NEW.time_ns IS NOT NULL BEGIN
+		INSERT INTO rollups (minute, type, count) VALUES (` + sqliteMinute("NEW") + `, NEW.type, 1)
+			ON CONFLICT (minute, type) DO UPDATE SET count = count + 1;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS events_rollup_update AFTER UPDATE OF type, time_ns ON events BEGIN
+		UPDATE rollups SET count = count - 1
+			WHERE OLD.time_ns IS NOT NULL AND minute = ` + sqliteMinute("OLD") + ` AND type = OLD.type;
+		INSERT INTO rollups (minute, type, count) SELECT ` + sqliteMinute("NEW") + `, NEW.type, 1 WHERE NEW.time_ns IS NOT NULL
+			ON CONFLICT (minute, type) DO UPDATE SET count = count + 1;
+	END`,
+}
+
+// sqliteMinute is the floored minute of a row's time_ns; the comparison
+// adds up to -1 for times before the epoch
+func sqliteMinute(row string) string {
+	return fmt.Sprintf("(%[1]s.time_ns / 60000000000 - (%[1]s.time_ns %% 60000000000 < 0))", row)
+}
+
+// migrateRollups creates the rollups table and its triggers, counting the
+// events already stored when the table is new
+func migrateRollups(db *sql.DB) error {
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'rollups'").Scan(&exists); err != nil {
+		return fmt.Errorf("read schema: %w", err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range sqliteRollups {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	backfill := "INSERT INTO rollups (minute, type, count) SELECT " + sqliteMinute("events") +
+		" AS m, type, COUNT(*) FROM events WHERE time_ns IS NOT NULL GROUP BY m, type"
+	if _, err := tx.Exec(backfill); err != nil {
+		return fmt.Errorf("count events: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Throughput implements ThroughputSeries
+func (s *SQLiteStore) Throughput(ctx context.Context, since, until time.Time, types []string) ([]ThroughputPoint, error) {
+	lo, hi := minuteRange(since, until)
+	query := "SELECT minute, type, count FROM rollups WHERE minute >= ? AND minute <= ? AND count > 0"
+	args := []any{lo, hi}
+	if len(types) > 0 {
+		query += " AND type IN (?" + strings.Repeat(", ?", len(types)-1) + ")"
+		for _, typ := range types {
+			args = append(args, typ)
+		}
+	}
+	query += " ORDER BY minute, type"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var points []ThroughputPoint
+	err := s.busy.retryBusy(ctx, func() error {
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("query rollups: %w", err)
+		}
+		defer rows.Close()
+
+		points, err = scanRollups(rows)
+		return err
+	})
+	return points, err
+}
+
+// scanRollups reads rows of minute, type and count
+func scanRollups(rows *sql.Rows) ([]ThroughputPoint, error) {
+	points := []ThroughputPoint{}
+	for rows.Next() {
+		var minute, count int64
+		var typ string
+		if err := rows.Scan(&minute, &typ, &count); err != nil {
+			return nil, fmt.Errorf("scan rollup: %w", err)
+		}
+		points = append(points, throughputPoint(minute, typ, count))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rollups: %w", err)
+	}
+	return points, nil
+}
+
+// Throughput implements ThroughputSeries on the rollups table, which a
+// trigger keeps in step with events
+func (s *PostgresStore) Throughput(ctx context.Context, since, until time.Time, types []string) ([]ThroughputPoint, error) {
+	lo, hi := minuteRange(since, until)
+	query := "SELECT minute, type, count FROM " + s.schema + ".rollups WHERE minute >= $1 AND minute <= $2 AND count > 0"
+	args := []any{lo, hi}
+	if len(types) > 0 {
+		query += " AND type = ANY($3)"
+		args = append(args, types)
+	}
+	query += " ORDER BY minute, type"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rollups: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRollups(rows)
+}
+
+// Throughput implements ThroughputSeries
+func (s *MemoryStore) Throughput(ctx context.Context, since, until time.Time, types []string) ([]ThroughputPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, errMemoryClosed
+	}
+	lo, hi := minuteRange(since, until)
+	points := []ThroughputPoint{}
+	for key, count := range s.rollups {
+		if key.minute >= lo && key.minute <= hi && count > 0 && (len(types) == 0 || slices.Contains(types, key.typ)) {
+			points = append(points, throughputPoint(key.minute, key.typ, count))
+		}
+	}
+	sortThroughput(points)
+	return points, nil
+}
+
+// sortThroughput orders points by minute, then type
+func sortThroughput(points []ThroughputPoint) {
+	slices.SortFunc(points, func(a, b ThroughputPoint) int {
+		return cmp.Or(a.Minute.Compare(b.Minute), cmp.Compare(a.Type, b.Type))
+	})
+}
+
+// rollupKeyOf orders the Pebble rollups by minute, then type. Flipping the
+// sign bit makes minutes before the epoch sort first.
+func rollupKeyOf(minute int64, typ string) []byte {
+	key := make([]byte, 0, 9+len(typ))
+	key = append(key, rollupPrefix)
+	key = binary.BigEndian.AppendUint64(key, uint64(minute)^(1<<63))
+	return append(key, typ...)
+}
+
+// commitWithRollups adds deltas to the stored counts within batch and
+// commits it. rollupMu is held from reading the counts until the commit, so
+// concurrent appends do not overwrite each other's counts.
+func (s *PebbleStore) commitWithRollups(batch *pebble.Batch, deltas rollupDeltas, opts *pebble.WriteOptions) error {
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+
+	for key, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		k := rollupKeyOf(key.minute, key.typ)
+		count, err := s.rollupCount(k)
+		if err != nil {
+			return err
+		}
+		if count += delta; count <= 0 {
+			if err := batch.Delete(k, nil); err != nil {
+				return fmt.Errorf("batch delete rollup: %w", err)
+			}
+			continue
+		}
+		if err := batch.Set(k, binary.BigEndian.AppendUint64(nil, uint64(count)), nil); err != nil {
+			return fmt.Errorf("batch set rollup: %w", err)
+		}
+	}
+	return batch.Commit(opts)
+}
+
+// rollupCount returns the count stored under key, or 0
+func (s *PebbleStore) rollupCount(key []byte) (int64, error) {
+	value, closer, err := s.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read rollup: %w", err)
+	}
+	defer closer.Close()
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+// buildRollups counts the events stored before rollups existed, once
+func (s *PebbleStore) buildRollups() error {
+	_, closer, err := s.db.Get([]byte(rollupIndexKey))
+	if err == nil {
+		return closer.Close()
+	}
+	if err != pebble.ErrNotFound {
+		return fmt.Errorf("read rollup marker: %w", err)
+	}
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte{eventPrefix},
+		UpperBound: []byte{eventPrefix + 1},
+	})
+	if err != nil {
+		return fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	deltas := make(rollupDeltas)
+	for iter.First(); iter.Valid(); iter.Next() {
+		var event struct {
+			Type      string    `json:"type"`
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			return fmt.Errorf("unmarshal event: %w", err)
+		}
+		deltas.add(&StoredEvent{Type: event.Type, Timestamp: event.Timestamp}, 1)
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+	for key, count := range deltas {
+		if err := batch.Set(rollupKeyOf(key.minute, key.typ), binary.BigEndian.AppendUint64(nil, uint64(count)), nil); err != nil {
+			return fmt.Errorf("batch set rollup: %w", err)
+		}
+	}
+	if err := batch.Set([]byte(rollupIndexKey), []byte{1}, nil); err != nil {
+		return fmt.Errorf("batch set rollup marker: %w", err)
+	}
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return fmt.Errorf("commit rollups: %w", err)
+	}
+	return nil
+}
+
+// Throughput implements ThroughputSeries
+func (s *PebbleStore) Throughput(ctx context.Context, since, until time.Time, types []string) ([]ThroughputPoint, error) {
+	lo, hi := minuteRange(since, until)
+	upper := []byte{rollupPrefix + 1}
+	if hi < math.MaxInt64 {
+		upper = rollupKeyOf(hi+1, "")
+	}
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: rollupKeyOf(lo, ""),
+		UpperBound: upper,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	points := []ThroughputPoint{}
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		minute := int64(binary.BigEndian.Uint64(key[1:9]) ^ (1 << 63))
+		typ := string(key[9:])
+		if len(types) > 0 && !slices.Contains(types, typ) {
+			continue
+		}
+		points = append(points, throughputPoint(minute, typ, int64(binary.BigEndian.Uint64(iter.Value()))))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return points, nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// aggregateOnly hides the rollups of a store, so LoadThroughput aggregates
+type aggregateOnly struct{ EventStore }
+
+func TestLoadThroughput(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/throughput.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/throughput")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 3600)
+	offsets := []time.Duration{0, 30 * time.Second, time.Minute, 90 * time.Second, 3 * time.Minute, -time.Second}
+
+	memoryStore := NewMemoryStore()
+	for _, st := range []EventStore{sqliteStore, pebbleStore, memoryStore, aggregateOnly{NewMemoryStore()}} {
+		for i, offset := range offsets {
+			typ := "OrderPlaced"
+			if i%2 == 1 {
+				typ = "OrderShipped"
+			}
+			event := &StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: base.Add(offset).In(berlin)}
+			if err := st.Save(ctx, event); err != nil {
+				t.Fatalf("%T: save failed: %v", st, err)
+			}
+		}
+		batch := []*StoredEvent{
+			{Type: "OrderPlaced", Data: json.RawMessage(`{}`), Timestamp: base.Add(10 * time.Second)},
+			{Type: "UserCreated", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Minute)},
+		}
+		if err := st.SaveBatch(ctx, batch); err != nil {
+			t.Fatalf("%T: save batch failed: %v", st, err)
+		}
+
+		tests := []struct {
+			since, until time.Time
+			types        []string
+			want         string
+		}{
+			{base.Add(-time.Hour), base.Add(time.Hour), nil, "[-1:OrderShipped:1 0:OrderPlaced:2 0:OrderShipped:1 1:OrderPlaced:1 1:OrderShipped:1 1:UserCreated:1 3:OrderPlaced:1]"},
+			{base.Add(-time.Hour), base.Add(time.Hour), []string{"OrderPlaced"}, "[0:OrderPlaced:2 1:OrderPlaced:1 3:OrderPlaced:1]"},
+			{base.Add(20 * time.Second), base.Add(61 * time.Second), []string{"OrderShipped", "UserCreated"}, "[0:OrderShipped:1 1:OrderShipped:1 1:UserCreated:1]"},
+			{base.Add(2 * time.Minute), base.Add(3 * time.Minute), nil, "[]"},
+			{base, base, nil, "[]"},
+		}
+		for _, tt := range tests {
+			points, err := LoadThroughput(ctx, st, tt.since, tt.until, tt.types)
+			if err != nil {
+				t.Fatalf("%T: LoadThroughput failed: %v", st, err)
+			}
+			if got := renderThroughput(base, points); got != tt.want {
+				t.Errorf("%T: LoadThroughput(%v, %v, %v) = %s, want %s", st, tt.since, tt.until, tt.types, got, tt.want)
+			}
+		}
+	}
+
+	// Imports and repairs keep the rollups in step
+	for _, st := range []EventStore{sqliteStore, pebbleStore, memoryStore} {
+		imported := []*StoredEvent{{Position: 20, Type: "UserCreated", Data: json.RawMessage(`{}`), Timestamp: base.Add(3 * time.Minute)}}
+		if err := st.(Importer).ImportEvents(ctx, imported); err != nil {
+			t.Fatalf("%T: import failed: %v", st, err)
+		}
+		if repairer, ok := st.(Repairer); ok {
+			repaired := []*StoredEvent{{Position: 1, Type: "OrderCancelled", Data: json.RawMessage(`{}`), Timestamp: base.Add(3 * time.Minute)}}
+			if err := repairer.ReplaceEvents(ctx, repaired); err != nil {
+				t.Fatalf("%T: repair failed: %v", st, err)
+			}
+		}
+		points, err := LoadThroughput(ctx, st, base.Add(3*time.Minute), base.Add(4*time.Minute), nil)
+		if err != nil {
+			t.Fatalf("%T: LoadThroughput failed: %v", st, err)
+		}
+		want := "[3:OrderCancelled:1 3:OrderPlaced:1 3:UserCreated:1]"
+		if _, ok := st.(Repairer); !ok {
+			want = "[3:OrderPlaced:1 3:UserCreated:1]"
+		}
+		if got := renderThroughput(base, points); got != want {
+			t.Errorf("%T: after import and repair = %s, want %s", st, got, want)
+		}
+		points, _ = LoadThroughput(ctx, st, base, base.Add(time.Minute), []string{"OrderPlaced"})
+		if _, ok := st.(Repairer); ok && renderThroughput(base, points) != "[0:OrderPlaced:1]" {
+			t.Errorf("%T: repaired event still counted: %s", st, renderThroughput(base, points))
+		}
+	}
+}
+
+func TestRollupsBackfill(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	events := func() []*StoredEvent {
+		return []*StoredEvent{
+			{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: base},
+			{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Second)},
+			{Type: "Tock", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Minute)},
+		}
+	}
+	want := "[0:Tick:2 1:Tock:1]"
+
+	t.Run("sqlite", func(t *testing.T) {
+		path := t.TempDir() + "/backfill.db"
+		st, err := NewSQLiteStore(path)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+		st.SaveBatch(ctx, events())
+		// A store from before rollups
+		for _, stmt := range []string{"DROP TRIGGER events_rollup_insert", "DROP TRIGGER events_rollup_update", "DROP TABLE rollups"} {
+			if _, err := st.db.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+		st.Close()
+
+		st, err = NewSQLiteStore(path)
+		if err != nil {
+			t.Fatalf("failed to reopen store: %v", err)
+		}
+		defer st.Close()
+		points, err := st.Throughput(ctx, base, base.Add(time.Hour), nil)
+		if err != nil || renderThroughput(base, points) != want {
+			t.Errorf("Throughput after backfill = %s, %v, want %s", renderThroughput(base, points), err, want)
+		}
+	})
+
+	t.Run("pebble", func(t *testing.T) {
+		path := t.TempDir() + "/backfill"
+		st, err := NewPebbleStore(path)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+		st.SaveBatch(ctx, events())
+		// A store from before rollups
+		if err := st.db.DeleteRange([]byte{rollupPrefix}, []byte{rollupPrefix + 1}, pebble.Sync); err != nil {
+			t.Fatalf("delete rollups: %v", err)
+		}
+		st.db.Delete([]byte(rollupIndexKey), pebble.Sync)
+		st.Close()
+
+		st, err = NewPebbleStore(path)
+		if err != nil {
+			t.Fatalf("failed to reopen store: %v", err)
+		}
+		defer st.Close()
+		points, err := st.Throughput(ctx, base, base.Add(time.Hour), nil)
+		if err != nil || renderThroughput(base, points) != want {
+			t.Errorf("Throughput after backfill = %s, %v, want %s", renderThroughput(base, points), err, want)
+		}
+	})
+}
+
+// renderThroughput renders points as minute:type:count, minutes relative
+// to base
+func renderThroughput(base time.Time, points []ThroughputPoint) string {
+	rendered := make([]string, len(points))
+	for i, p := range points {
+		rendered[i] = fmt.Sprintf("%d:%s:%d", int(p.Minute.Sub(base).Minutes()), p.Type, p.Count)
+	}
+	return fmt.Sprint(rendered)
+}
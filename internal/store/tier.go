@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ColdTier holds events moved out of a store, e.g. into archive segments,
+// so they stay readable after the store deleted them
+type ColdTier interface {
+	// Before returns the first position the store serves itself; reads of
+	// earlier positions go to the cold tier
+	Before() int64
+	// Scan calls fn for the events in [from, to] in position order
+	Scan(ctx context.Context, from, to int64, fn func(*StoredEvent) error) error
+}
+
+// Tiered is implemented by stores whose Load, LoadStream and LoadStreamRaw
+// read positions before a cold tier's boundary from it. Index queries
+// (LoadByTime, LoadByTypes, LoadByStream) only see the store's own events.
+type Tiered interface {
+	SetColdTier(tier ColdTier)
+}
+
+// coldTier is embedded by stores to implement Tiered
+type coldTier struct {
+	tier atomic.Pointer[ColdTier]
+}
+
+// SetColdTier implements Tiered
+func (c *coldTier) SetColdTier(tier ColdTier) {
+	c.tier.Store(&tier)
+}
+
+// below returns the cold tier if reads from position start in it
+func (c *coldTier) below(position int64) (ColdTier, bool) {
+	tier := c.tier.Load()
+	if tier == nil || streamStart(position) >= (*tier).Before() {
+		return nil, false
+	}
+	return *tier, true
+}
+
+// errScanFull stops a scan once Load has MaxLoadEvents events
+var errScanFull = errors.New("load full")
+
+// loadCold implements Load for ranges starting in the cold tier: events
+// before its boundary come from the tier, the rest from hot
+func loadCold(ctx context.Context, tier ColdTier, from, to int64, hot func(context.Context, int64, int64) ([]*StoredEvent, error)) ([]*StoredEvent, error) {
+	events := []*StoredEvent{}
+	from, to, ok := loadRange(from, to)
+	if !ok {
+		return events, nil
+	}
+
+	before := tier.Before()
+	last := before - 1
+	if to != -1 {
+		last = min(last, to)
+	}
+	err := tier.Scan(ctx, from, last, func(event *StoredEvent) error {
+		if to == -1 && len(events) == MaxLoadEvents {
+			return errScanFull
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errScanFull) {
+		return nil, fmt.Errorf("load cold events: %w", err)
+	}
+	if to != -1 && to < before || to == -1 && len(events) == MaxLoadEvents {
+		return events, nil
+	}
+
+	rest, err := hot(ctx, before, to)
+	if err != nil {
+		return nil, err
+	}
+	if to == -1 {
+		rest = rest[:min(len(rest), MaxLoadEvents-len(events))]
+	}
+	return append(events, rest...), nil
+}
+
+// streamCold implements LoadStream and LoadStreamRaw for streams starting
+// in the cold tier: events before its boundary come from the tier in
+// batches, then hot streams the rest
+func streamCold[T any](ctx context.Context, tier ColdTier, from int64, batchSize int, encode func(*StoredEvent) (T, error), handler func([]T) error, hot func(context.Context, int64, int, func([]T) error) error) error {
+	from, batchSize = streamStart(from), streamBatchSize(batchSize)
+	before := tier.Before()
+
+	var handlerErr error
+	batch := make([]T, 0, batchSize)
+	flush := func() error {
+		if handlerErr = handler(batch); handlerErr != nil {
+			return handlerErr
+		}
+		batch = make([]T, 0, batchSize)
+		return nil
+	}
+	err := tier.Scan(ctx, from, before-1, func(event *StoredEvent) error {
+		value, err := encode(event)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, value)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	switch {
+	case handlerErr != nil:
+		return fmt.Errorf("handle batch: %w", handlerErr)
+	case err != nil:
+		return fmt.Errorf("load cold events: %w", err)
+	}
+	return hot(ctx, before, batchSize, handler)
+}
+
+// rawEvent encodes an event from the cold tier for LoadStreamRaw
+func rawEvent(event *StoredEvent) (json.RawMessage, error) {
+	return json.Marshal(event)
+}
+
+// sameEvent passes an event from the cold tier to LoadStream
+func sameEvent(event *StoredEvent) (*StoredEvent, error) {
+	return event, nil
+}
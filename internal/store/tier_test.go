@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// sliceTier is a cold tier holding events in memory
+type sliceTier struct {
+	events []*StoredEvent
+	before int64
+}
+
+func (t *sliceTier) Before() int64 { return t.before }
+
+func (t *sliceTier) Scan(ctx context.Context, from, to int64, fn func(*StoredEvent) error) error {
+	for _, event := range t.events {
+		if event.Position >= from && event.Position <= to {
+			if err := fn(copyEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestColdTier(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	stores := map[string]func() (EventStore, error){
+		"sqlite": func() (EventStore, error) { return NewSQLiteStore(dir + "/tier.db") },
+		"pebble": func() (EventStore, error) { return NewPebbleStore(dir + "/tier") },
+		"memory": func() (EventStore, error) { return NewMemoryStore(), nil },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			st, err := open()
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			defer st.Close()
+
+			for range 10 {
+				if err := st.Save(ctx, &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`)}); err != nil {
+					t.Fatalf("save failed: %v", err)
+				}
+			}
+			cold, _ := st.Load(ctx, 1, 5)
+			if _, err := st.DeleteBefore(ctx, 6); err != nil {
+				t.Fatalf("DeleteBefore failed: %v", err)
+			}
+			st.(Tiered).SetColdTier(&sliceTier{events: cold, before: 6})
+
+			check := func(what string, got []int64, want ...int64) {
+				t.Helper()
+				if len(got) != len(want) || len(got) > 0 && (got[0] != want[0] || got[len(got)-1] != want[len(want)-1]) {
+					t.Errorf("%s = %v, want %d-%d", what, got, want[0], want[len(want)-1])
+				}
+			}
+			positions := func(events []*StoredEvent) []int64 {
+				var p []int64
+				for _, event := range events {
+					p = append(p, event.Position)
+				}
+				return p
+			}
+
+			events, err := st.Load(ctx, 1, -1)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			check("Load(1, -1)", positions(events), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+			events, _ = st.Load(ctx, 2, 4)
+			check("Load(2, 4)", positions(events), 2, 3, 4)
+			events, _ = st.Load(ctx, 4, 7)
+			check("Load(4, 7)", positions(events), 4, 5, 6, 7)
+			events, _ = st.Load(ctx, 7, -1)
+			check("Load(7, -1)", positions(events), 7, 8, 9, 10)
+
+			var streamed []int64
+			var batches int
+			err = st.LoadStream(ctx, 3, 2, func(batch []*StoredEvent) error {
+				batches++
+				streamed = append(streamed, positions(batch)...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("LoadStream failed: %v", err)
+			}
+			check("LoadStream(3)", streamed, 3, 4, 5, 6, 7, 8, 9, 10)
+			if batches != 5 {
+				t.Errorf("LoadStream delivered %d batches, want 5", batches)
+			}
+
+			if rs, ok := st.(RawStreamer); ok {
+				var raw []int64
+				err := rs.LoadStreamRaw(ctx, 1, 0, func(batch []json.RawMessage) error {
+					for _, msg := range batch {
+						var event StoredEvent
+						if err := json.Unmarshal(msg, &event); err != nil {
+							return err
+						}
+						raw = append(raw, event.Position)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("LoadStreamRaw failed: %v", err)
+				}
+				check("LoadStreamRaw(1)", raw, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+var (
+	minUnixNano = time.Unix(0, math.MinInt64)
+	maxUnixNano = time.Unix(0, math.MaxInt64)
+)
+
+// unixNano is t.UnixNano, clamped to the int64 range instead of undefined
+// for times before 1678 or after 2262 (such as the zero time)
+func unixNano(t time.Time) int64 {
+	switch {
+	case t.Before(minUnixNano):
+		return math.MinInt64
+	case t.After(maxUnixNano):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// timeRange converts [since, until) to inclusive bounds in nanoseconds, and
+// whether any timestamp falls into them
+func timeRange(since, until time.Time) (int64, int64, bool) {
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	if !since.IsZero() {
+		lo = unixNano(since)
+	}
+	if !until.IsZero() {
+		end := unixNano(until)
+		if end == math.MinInt64 {
+			return 0, 0, false
+		}
+		hi = end - 1
+	}
+	return lo, hi, lo <= hi
+}
+
+// loadTimeFunc returns up to limit events of the filtered time range with
+// positions in [from, to], in position order
+type loadTimeFunc func(from, to int64, limit int) ([]*StoredEvent, error)
+
+// loadByTime implements EventStore.LoadByTime on top of load
+func loadByTime(since, until time.Time, from, to int64, load loadTimeFunc) ([]*StoredEvent, error) {
+	from, to, ok := loadRange(from, to)
+	if _, _, inTime := timeRange(since, until); !ok || !inTime {
+		return []*StoredEvent{}, nil
+	}
+
+	limit := math.MaxInt
+	if to == -1 {
+		to, limit = math.MaxInt64, MaxLoadEvents
+	}
+	events, err := load(from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []*StoredEvent{}
+	}
+	return events, nil
+}
+
+// LoadByTime implements EventStore.LoadByTime
+func (s *SQLiteStore) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error) {
+	return loadByTime(since, until, from, to, s.loadTime(ctx, since, until))
+}
+
+// loadTime queries events through the time_ns index
+func (s *SQLiteStore) loadTime(ctx context.Context, since, until time.Time) loadTimeFunc {
+	lo, hi, _ := timeRange(since, until)
+	scanner := newEventScanner()
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		var events []*StoredEvent
+		err := s.busy.retryBusy(ctx, func() error {
+			rows, err := s.loadTimeStmt.QueryContext(ctx, lo, hi, from, to, limit)
+			if err != nil {
+				return fmt.Errorf("query events: %w", err)
+			}
+			defer rows.Close()
+
+			events, err = scanner.scanAll(rows, min(limit, 1000), nil)
+			if err != nil {
+				return fmt.Errorf("scan event: %w", err)
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterate events: %w", err)
+			}
+			return nil
+		})
+		return events, err
+	}
+}
+
+// indexTimes fills time_ns for rows written before the column existed,
+// parsing the text encoding of their timestamps
+func indexTimes(db *sql.DB) error {
+	for {
+		rows, err := db.Query("SELECT position, timestamp FROM events WHERE time_ns IS NULL LIMIT 1000")
+		if err != nil {
+			return fmt.Errorf("query unindexed events: %w", err)
+		}
+		var positions []int64
+		var times []int64
+		for rows.Next() {
+			var position int64
+			var timestamp time.Time
+			if err := rows.Scan(&position, (*sqliteTime)(&timestamp)); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan event %d: %w", position, err)
+			}
+			positions = append(positions, position)
+			times = append(times, unixNano(timestamp))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate unindexed events: %w", err)
+		}
+		if len(positions) == 0 {
+			return nil
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		for i, position := range positions {
+			if _, err := tx.Exec("UPDATE events SET time_ns = ? WHERE position = ?", times[i], position); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("index event %d: %w", position, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit time index: %w", err)
+		}
+	}
+}
+
+// LoadByTime implements EventStore.LoadByTime
+func (s *PostgresStore) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error) {
+	return loadByTime(since, until, from, to, s.loadTime(ctx, since, until))
+}
+
+// loadTime queries events through the timestamp index. Open bounds are left
+// out of the query rather than passed as extreme times.
+func (s *PostgresStore) loadTime(ctx context.Context, since, until time.Time) loadTimeFunc {
+	var conds []string
+	var bounds []any
+	if !since.IsZero() {
+		bounds = append(bounds, since)
+		conds = append(conds, "timestamp >= $"+strconv.Itoa(len(bounds)))
+	}
+	if !until.IsZero() {
+		bounds = append(bounds, until)
+		conds = append(conds, "timestamp < $"+strconv.Itoa(len(bounds)))
+	}
+	n := len(bounds)
+	conds = append(conds, fmt.Sprintf("position >= $%d AND position <= $%d", n+1, n+2))
+	query := "SELECT " + eventColumns + " FROM " + s.schema + ".events WHERE " + strings.Join(conds, " AND ") +
+		fmt.Sprintf(" ORDER BY position LIMIT $%d", n+3)
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		rows, err := s.db.QueryContext(ctx, query, append(bounds, from, to, limit)...)
+		if err != nil {
+			return nil, fmt.Errorf("query events: %w", err)
+		}
+		defer rows.Close()
+
+		return scanPostgresEvents(rows, nil)
+	}
+}
+
+// LoadByTime implements EventStore.LoadByTime
+func (s *MemoryStore) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error) {
+	return loadByTime(since, until, from, to, s.loadTime(since, until))
+}
+
+// loadTime scans the events for the time range
+func (s *MemoryStore) loadTime(since, until time.Time) loadTimeFunc {
+	lo, hi, _ := timeRange(since, until)
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		if s.closed {
+			return nil, errMemoryClosed
+		}
+		var events []*StoredEvent
+		for i := s.index(from); i < len(s.events) && len(events) < limit; i++ {
+			event := s.events[i]
+			if event.Position > to {
+				break
+			}
+			if t := unixNano(event.Timestamp); t >= lo && t <= hi {
+				events = append(events, copyEvent(event))
+			}
+		}
+		return events, nil
+	}
+}
+
+// timeKey orders the time index by timestamp, then position. Flipping the
+// sign bit makes negative timestamps sort before positive ones.
+func timeKey(nanos, position int64) []byte {
+	key := make([]byte, 0, 17)
+	key = append(key, timePrefix)
+	key = binary.BigEndian.AppendUint64(key, uint64(nanos)^(1<<63))
+	return binary.BigEndian.AppendUint64(key, uint64(position))
+}
+
+// setTimeKey adds the time index entry of event to batch
+func setTimeKey(batch *pebble.Batch, event *StoredEvent) error {
+	if err := batch.Set(timeKey(unixNano(event.Timestamp), event.Position), nil, nil); err != nil {
+		return fmt.Errorf("batch set time index: %w", err)
+	}
+	return nil
+}
+
+// LoadByTime implements EventStore.LoadByTime
+func (s *PebbleStore) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*StoredEvent, error) {
+	return loadByTime(since, until, from, to, s.loadTime(ctx, since, until))
+}
+
+// loadTime collects the positions of the time range from the index, then
+// reads their events in position order. Entries left behind by a repair
+// that changed an event's timestamp are skipped.
+func (s *PebbleStore) loadTime(ctx context.Context, since, until time.Time) loadTimeFunc {
+	lo, hi, _ := timeRange(since, until)
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		upper := []byte{timePrefix + 1}
+		if hi < math.MaxInt64 {
+			upper = timeKey(hi+1, 0)
+		}
+		iter, err := s.db.NewIter(&pebble.IterOptions{
+			LowerBound: timeKey(lo, 0),
+			UpperBound: upper,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create iterator: %w", err)
+		}
+		defer iter.Close()
+
+		var positions []int64
+		for iter.First(); iter.Valid(); iter.Next() {
+			key := iter.Key()
+			if position := int64(binary.BigEndian.Uint64(key[9:])); position >= from && position <= to {
+				positions = append(positions, position)
+			}
+		}
+		if err := iter.Error(); err != nil {
+			return nil, fmt.Errorf("iterator error: %w", err)
+		}
+		slices.Sort(positions)
+
+		var events []*StoredEvent
+		for _, position := range positions {
+			if len(events) == limit {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			event, err := s.getEvent(position)
+			if err != nil {
+				return nil, err
+			}
+			if event != nil {
+				if t := unixNano(event.Timestamp); t >= lo && t <= hi {
+					events = append(events, event)
+				}
+			}
+		}
+		return events, nil
+	}
+}
+
+// positionAtWindow is how many positions PositionAt searches per query
+const positionAtWindow = 1000
+
+// PositionAt returns the head of st as of t: the position before the first
+// event stamped after t, or the current head if there is none. Timestamps
+// are set by writers, so this assumes events are stamped about when they are
+// appended; either way the result marks a prefix of the log, and events
+// appended later with backdated timestamps stay outside it.
+func PositionAt(ctx context.Context, st EventStore, t time.Time) (int64, error) {
+	return PositionAtFrom(ctx, st, t, 1)
+}
+
+// PositionAtFrom is PositionAt for a log whose events before position from
+// were deleted, which it skips searching; the result is at least from-1
+func PositionAtFrom(ctx context.Context, st EventStore, t time.Time, from int64) (int64, error) {
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		return 0, err
+	}
+	after := t.Add(time.Nanosecond)
+	for from = max(from, 1); from <= head; from += positionAtWindow {
+		events, err := st.LoadByTime(ctx, after, time.Time{}, from, min(from+positionAtWindow-1, head))
+		if err != nil {
+			return 0, err
+		}
+		if len(events) > 0 {
+			return events[0].Position - 1, nil
+		}
+	}
+	return head, nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLoadByTime(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/time.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/time")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 3600)
+
+	// Timestamps are out of position order and in different zones; the
+	// zero timestamp sorts before everything
+	offsets := []time.Duration{0, 2 * time.Hour, time.Hour, -time.Hour, 3 * time.Hour}
+	for _, st := range []EventStore{sqliteStore, pebbleStore, NewMemoryStore()} {
+		for i, offset := range offsets {
+			ts := base.Add(offset)
+			if i%2 == 1 {
+				ts = ts.In(berlin)
+			}
+			event := &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: ts}
+			if err := st.Save(ctx, event); err != nil {
+				t.Fatalf("%T: save failed: %v", st, err)
+			}
+		}
+		if err := st.Save(ctx, &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("%T: save failed: %v", st, err)
+		}
+
+		tests := []struct {
+			since, until time.Time
+			from, to     int64
+			want         string
+		}{
+			{base, time.Time{}, 1, -1, "[1 2 3 5]"},
+			{base.In(berlin), base.Add(2 * time.Hour), 0, -1, "[1 3]"},
+			{base.Add(time.Hour), base.Add(time.Hour + 1), 1, -1, "[3]"},
+			{time.Time{}, base, 1, -1, "[4 6]"},
+			{time.Time{}, time.Time{}, 2, 4, "[2 3 4]"},
+			{base, time.Time{}, 3, -1, "[3 5]"},
+			{base, base, 1, -1, "[]"},
+			{base.Add(time.Hour), base, 1, -1, "[]"},
+			{base, time.Time{}, 5, 4, "[]"},
+		}
+		for _, tt := range tests {
+			events, err := st.LoadByTime(ctx, tt.since, tt.until, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("%T: LoadByTime failed: %v", st, err)
+			}
+			if got := typePositions(events); got != tt.want || events == nil {
+				t.Errorf("%T: [%v, %v) in %d-%d: expected %s, got %s", st, tt.since, tt.until, tt.from, tt.to, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestPositionAt(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+
+	// The fourth event is a backfill stamped before the third
+	offsets := []time.Duration{0, time.Hour, 2 * time.Hour, 30 * time.Minute, 3 * time.Hour}
+	for _, offset := range offsets {
+		if err := st.Save(ctx, &StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`), Timestamp: base.Add(offset)}); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		at   time.Time
+		want int64
+	}{
+		{base.Add(-time.Second), 0},
+		{base, 1},
+		{base.Add(90 * time.Minute), 2},
+		{base.Add(2 * time.Hour), 4},
+		{base.Add(time.Hour * 24), 5},
+	}
+	for _, tt := range tests {
+		position, err := PositionAt(ctx, st, tt.at)
+		if err != nil {
+			t.Fatalf("PositionAt failed: %v", err)
+		}
+		if position != tt.want {
+			t.Errorf("as of %v: expected %d, got %d", tt.at, tt.want, position)
+		}
+	}
+}
+
+func TestSQLiteStore_TimeIndexMigration(t *testing.T) {
+	path := t.TempDir() + "/time.db"
+	st, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.FixedZone("CET", 3600))
+	batch := []*StoredEvent{
+		{Type: "A", Data: json.RawMessage(`{}`), Timestamp: base},
+		{Type: "A", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Hour).UTC()},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	// Rows written before time_ns existed are indexed on open
+	if _, err := st.db.Exec("UPDATE events SET time_ns = NULL"); err != nil {
+		t.Fatalf("clear time_ns failed: %v", err)
+	}
+	st.Close()
+
+	st, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen sqlite store: %v", err)
+	}
+	defer st.Close()
+
+	events, err := st.LoadByTime(ctx, base.Add(time.Minute), time.Time{}, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTime failed: %v", err)
+	}
+	if got := typePositions(events); got != "[2]" {
+		t.Errorf("expected the migrated index to return [2], got %s", got)
+	}
+}
+
+func TestPebbleStore_TimeIndexRepairAndRebuild(t *testing.T) {
+	dir := t.TempDir() + "/time"
+	st, err := NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	batch := []*StoredEvent{
+		{Type: "A", Data: json.RawMessage(`{}`), Timestamp: base},
+		{Type: "A", Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Hour)},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	// A repair moving position 2 back in time leaves a stale entry behind
+	if err := st.ReplaceEvents(ctx, []*StoredEvent{{Position: 2, Type: "A", Data: json.RawMessage(`{}`), Timestamp: base.Add(-time.Hour)}}); err != nil {
+		t.Fatalf("ReplaceEvents failed: %v", err)
+	}
+	if events, _ := st.LoadByTime(ctx, base, time.Time{}, 1, -1); typePositions(events) != "[1]" {
+		t.Errorf("expected the stale entry to be skipped, got %s", typePositions(events))
+	}
+	if events, _ := st.LoadByTime(ctx, time.Time{}, base, 1, -1); typePositions(events) != "[2]" {
+		t.Errorf("expected [2] after the repair, got %s", typePositions(events))
+	}
+
+	// Stores written before the time index existed are indexed on open
+	if err := st.db.DeleteRange([]byte{timePrefix}, []byte{timePrefix + 1}, nil); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := st.db.Delete([]byte(timeIndexKey), nil); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	st.Close()
+
+	st, err = NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen pebble store: %v", err)
+	}
+	defer st.Close()
+
+	if events, _ := st.LoadByTime(ctx, time.Time{}, time.Time{}, 1, -1); typePositions(events) != "[1 2]" {
+		t.Errorf("expected the rebuilt index to return [1 2], got %s", typePositions(events))
+	}
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// MaxFilterTypes limits the number of types one type-filtered read may ask for
+const MaxFilterTypes = 100
+
+// TypeIndex is implemented by stores that can read the events of a few
+// types without scanning the whole log, e.g. to replay one projection.
+// Both methods follow the semantics of Load and LoadStream, counting only
+// events of the given types towards limits and batch sizes.
+type TypeIndex interface {
+	// LoadByTypes is Load restricted to events whose Type is one of types
+	LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*StoredEvent, error)
+
+	// LoadStreamByTypes is LoadStream restricted to events whose Type is
+	// one of types
+	LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*StoredEvent) error) error
+}
+
+// ValidateTypes rejects type filters the stores do not serve
+func ValidateTypes(types []string) error {
+	if len(types) > MaxFilterTypes {
+		return fmt.Errorf("%d types requested, max %d", len(types), MaxFilterTypes)
+	}
+	if slices.Contains(types, "") {
+		return fmt.Errorf("event types cannot be empty")
+	}
+	return nil
+}
+
+// loadTypesFunc returns up to limit events of the filtered types with
+// positions in [from, to], in position order
+type loadTypesFunc func(from, to int64, limit int) ([]*StoredEvent, error)
+
+// loadByTypes implements TypeIndex.LoadByTypes on top of load
+func loadByTypes(types []string, from, to int64, load loadTypesFunc) ([]*StoredEvent, error) {
+	if len(types) == 0 {
+		return []*StoredEvent{}, nil
+	}
+	return loadLimited(from, to, load)
+}
+
+// streamByTypes implements TypeIndex.LoadStreamByTypes on top of load, one
+// call per batch
+func streamByTypes(types []string, from int64, batchSize int, load loadTypesFunc, handler func([]*StoredEvent) error) error {
+	if len(types) == 0 {
+		return nil
+	}
+	return streamLimited(from, batchSize, load, handler)
+}
+
+// LoadByTypes implements TypeIndex
+func (s *SQLiteStore) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*StoredEvent, error) {
+	return loadByTypes(types, from, to, s.loadTypes(ctx, types))
+}
+
+// LoadStreamByTypes implements TypeIndex
+func (s *SQLiteStore) LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	return streamByTypes(types, from, batchSize, s.loadTypes(ctx, types), handler)
+}
+
+// loadTypes queries events of types through the (type, position) index
+func (s *SQLiteStore) loadTypes(ctx context.Context, types []string) loadTypesFunc {
+	query := "SELECT " + eventColumns + " FROM events WHERE type IN (?" + strings.Repeat(", ?", max(len(types)-1, 0)) +
+		") AND position >= ? AND position <= ? ORDER BY position LIMIT ?"
+	scanner := newEventScanner()
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		args := make([]any, 0, len(types)+3)
+		for _, typ := range types {
+			args = append(args, typ)
+		}
+		args = append(args, from, to, limit)
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		var events []*StoredEvent
+		err := s.busy.retryBusy(ctx, func() error {
+			rows, err := s.db.QueryContext(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("query events: %w", err)
+			}
+			defer rows.Close()
+
+			events, err = scanner.scanAll(rows, min(limit, 1000), nil)
+			if err != nil {
+				return fmt.Errorf("scan event: %w", err)
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterate events: %w", err)
+			}
+			return nil
+		})
+		return events, err
+	}
+}
+
+// LoadByTypes implements TypeIndex
+func (s *PostgresStore) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*StoredEvent, error) {
+	return loadByTypes(types, from, to, s.loadTypes(ctx, types))
+}
+
+// LoadStreamByTypes implements TypeIndex
+func (s *PostgresStore) LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	return streamByTypes(types, from, batchSize, s.loadTypes(ctx, types), handler)
+}
+
+// loadTypes queries events of types through the (type, position) index
+func (s *PostgresStore) loadTypes(ctx context.Context, types []string) loadTypesFunc {
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		rows, err := s.db.QueryContext(ctx, s.typesQuery, types, from, to, limit)
+		if err != nil {
+			return nil, fmt.Errorf("query events: %w", err)
+		}
+		defer rows.Close()
+
+		return scanPostgresEvents(rows, nil)
+	}
+}
+
+// LoadByTypes implements TypeIndex
+func (s *MemoryStore) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*StoredEvent, error) {
+	return loadByTypes(types, from, to, s.loadTypes(types))
+}
+
+// LoadStreamByTypes implements TypeIndex. As with LoadStream, the lock is
+// released while handler runs.
+func (s *MemoryStore) LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	return streamByTypes(types, from, batchSize, s.loadTypes(types), handler)
+}
+
+// loadTypes scans the events for the given types
+func (s *MemoryStore) loadTypes(types []string) loadTypesFunc {
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		if s.closed {
+			return nil, errMemoryClosed
+		}
+		var events []*StoredEvent
+		for i := s.index(from); i < len(s.events) && len(events) < limit; i++ {
+			event := s.events[i]
+			if event.Position > to {
+				break
+			}
+			if slices.Contains(types, event.Type) {
+				events = append(events, copyEvent(event))
+			}
+		}
+		return events, nil
+	}
+}
+
+// typeKey orders a type's index entries by position. The length prefix
+// keeps one type's keys from interleaving with a longer type that shares
+// its prefix.
+func typeKey(typ string, position int64) []byte {
+	key := make([]byte, 0, 1+binary.MaxVarintLen64+len(typ)+8)
+	key = append(key, typePrefix)
+	key = binary.AppendUvarint(key, uint64(len(typ)))
+	key = append(key, typ...)
+	return binary.BigEndian.AppendUint64(key, uint64(position))
+}
+
+// setTypeKey adds the type index entry of event to batch
+func setTypeKey(batch *pebble.Batch, event *StoredEvent) error {
+	if err := batch.Set(typeKey(event.Type, event.Position), nil, nil); err != nil {
+		return fmt.Errorf("batch set type index: %w", err)
+	}
+	return nil
+}
+
+// indexTypes builds the type index of a store written before it existed
+func (s *PebbleStore) indexTypes() error {
+	return s.buildIndex(typeIndexKey, "type", setTypeKey)
+}
+
+// LoadByTypes implements TypeIndex
+func (s *PebbleStore) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*StoredEvent, error) {
+	return loadByTypes(types, from, to, s.loadTypes(ctx, types))
+}
+
+// LoadStreamByTypes implements TypeIndex
+func (s *PebbleStore) LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*StoredEvent) error) error {
+	return streamByTypes(types, from, batchSize, s.loadTypes(ctx, types), handler)
+}
+
+// loadTypes merges the index entries of every type in position order.
+// Entries left behind by a repair that changed an event's type are skipped.
+func (s *PebbleStore) loadTypes(ctx context.Context, types []string) loadTypesFunc {
+	types = slices.Compact(slices.Sorted(slices.Values(types)))
+
+	return func(from, to int64, limit int) ([]*StoredEvent, error) {
+		iters := make([]*pebble.Iterator, 0, len(types))
+		defer func() {
+			for _, iter := range iters {
+				iter.Close()
+			}
+		}()
+		for _, typ := range types {
+			iter, err := s.db.NewIter(&pebble.IterOptions{
+				LowerBound: typeKey(typ, from),
+				UpperBound: append(typeKey(typ, to), 0), // Just past to
+			})
+			if err != nil {
+				return nil, fmt.Errorf("create iterator: %w", err)
+			}
+			iters = append(iters, iter)
+			iter.First()
+		}
+		position := func(iter *pebble.Iterator) int64 {
+			key := iter.Key()
+			return int64(binary.BigEndian.Uint64(key[len(key)-8:]))
+		}
+
+		var events []*StoredEvent
+		for len(events) < limit {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			// Few types are filtered at once, so a linear scan finds the next one
+			next := -1
+			for i, iter := range iters {
+				if iter.Valid() && (next == -1 || position(iter) < position(iters[next])) {
+					next = i
+				}
+			}
+			if next == -1 {
+				break
+			}
+
+			event, err := s.getEvent(position(iters[next]))
+			if err != nil {
+				return nil, err
+			}
+			if event != nil && event.Type == types[next] {
+				events = append(events, event)
+			}
+			iters[next].Next()
+		}
+
+		for _, iter := range iters {
+			if err := iter.Error(); err != nil {
+				return nil, fmt.Errorf("iterator error: %w", err)
+			}
+		}
+		return events, nil
+	}
+}
+
+// getEvent returns the event at position, or nil if there is none
+func (s *PebbleStore) getEvent(position int64) (*StoredEvent, error) {
+	data, closer, err := s.db.Get(eventKey(position))
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get event %d: %w", position, err)
+	}
+	defer closer.Close()
+
+	var event StoredEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("unmarshal event: %w", err)
+	}
+	return &event, nil
+}
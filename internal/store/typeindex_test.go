@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// typePositions returns the positions of events as a string
+func typePositions(events []*StoredEvent) string {
+	positions := make([]int64, len(events))
+	for i, event := range events {
+		positions[i] = event.Position
+	}
+	return fmt.Sprint(positions)
+}
+
+func TestTypeIndex(t *testing.T) {
+	sqliteStore, err := NewSQLiteStore(t.TempDir() + "/types.db")
+	if err != nil {
+		t.Fatalf("failed to create sqlite store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := NewPebbleStore(t.TempDir() + "/types")
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	ctx := context.Background()
+	for _, st := range []interface {
+		EventStore
+		TypeIndex
+	}{sqliteStore, pebbleStore, NewMemoryStore()} {
+		// "Order" and "OrderPaid" share a prefix but are separate types
+		for i, typ := range []string{"Order", "OrderPaid", "Tick", "Order", "Tick", "OrderPaid", "Order"} {
+			event := &StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: time.Now()}
+			if i%2 == 0 {
+				err = st.Save(ctx, event)
+			} else {
+				err = st.SaveBatch(ctx, []*StoredEvent{event})
+			}
+			if err != nil {
+				t.Fatalf("%T: save failed: %v", st, err)
+			}
+		}
+
+		tests := []struct {
+			types    []string
+			from, to int64
+			want     string
+		}{
+			{[]string{"Order"}, 1, -1, "[1 4 7]"},
+			{[]string{"OrderPaid", "Order"}, 0, -1, "[1 2 4 6 7]"},
+			{[]string{"Order", "Order"}, 2, 4, "[4]"},
+			{[]string{"Order", "Tick"}, 3, 5, "[3 4 5]"},
+			{[]string{"Unknown"}, 1, -1, "[]"},
+			{nil, 1, -1, "[]"},
+			{[]string{"Order"}, 5, 4, "[]"},
+		}
+		for _, tt := range tests {
+			events, err := st.LoadByTypes(ctx, tt.types, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("%T: LoadByTypes failed: %v", st, err)
+			}
+			if got := typePositions(events); got != tt.want || events == nil {
+				t.Errorf("%T: %v in %d-%d: expected %s, got %s", st, tt.types, tt.from, tt.to, tt.want, got)
+			}
+		}
+
+		var batches []string
+		err := st.LoadStreamByTypes(ctx, []string{"Order", "OrderPaid"}, 2, 2, func(batch []*StoredEvent) error {
+			batches = append(batches, typePositions(batch))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%T: LoadStreamByTypes failed: %v", st, err)
+		}
+		if got := fmt.Sprint(batches); got != "[[2 4] [6 7]]" {
+			t.Errorf("%T: expected batches [[2 4] [6 7]], got %s", st, got)
+		}
+
+		errStop := errors.New("stop")
+		if err := st.LoadStreamByTypes(ctx, []string{"Tick"}, 1, 1, func([]*StoredEvent) error { return errStop }); !errors.Is(err, errStop) {
+			t.Errorf("%T: expected the handler error, got %v", st, err)
+		}
+	}
+}
+
+func TestPebbleStore_TypeIndexRepairAndRebuild(t *testing.T) {
+	dir := t.TempDir() + "/types"
+	st, err := NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create pebble store: %v", err)
+	}
+
+	ctx := context.Background()
+	batch := []*StoredEvent{
+		{Type: "A", Data: json.RawMessage(`{}`)},
+		{Type: "B", Data: json.RawMessage(`{}`)},
+		{Type: "A", Data: json.RawMessage(`{}`)},
+	}
+	if err := st.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	// A repair moving position 3 to type B leaves a stale A entry behind
+	if err := st.ReplaceEvents(ctx, []*StoredEvent{{Position: 3, Type: "B", Data: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatalf("ReplaceEvents failed: %v", err)
+	}
+	if events, _ := st.LoadByTypes(ctx, []string{"A", "B"}, 1, -1); typePositions(events) != "[1 2 3]" {
+		t.Errorf("expected [1 2 3] after the repair, got %s", typePositions(events))
+	}
+	if events, _ := st.LoadByTypes(ctx, []string{"A"}, 1, -1); typePositions(events) != "[1]" {
+		t.Errorf("expected the stale entry to be skipped, got %s", typePositions(events))
+	}
+
+	// Stores written before the type index existed are indexed on open
+	if err := st.db.DeleteRange([]byte{typePrefix}, []byte{typePrefix + 1}, nil); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := st.db.Delete([]byte(typeIndexKey), nil); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	st.Close()
+
+	st, err = NewPebbleStore(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen pebble store: %v", err)
+	}
+	defer st.Close()
+
+	if events, _ := st.LoadByTypes(ctx, []string{"B"}, 1, -1); typePositions(events) != "[2 3]" {
+		t.Errorf("expected the rebuilt index to return [2 3], got %s", typePositions(events))
+	}
+}
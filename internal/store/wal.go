@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WALStats describes the write-ahead log and automatic checkpoint activity
+type WALStats struct {
+	SizeBytes      int64     `json:"size_bytes"`
+	ThresholdBytes int64     `json:"threshold_bytes"` // 0 when the monitor is not running
+	Checkpoints    int64     `json:"checkpoints"`     // TRUNCATE checkpoints triggered by the monitor
+	Incomplete     int64     `json:"incomplete"`      // Checkpoints blocked by active readers
+	LastCheckpoint time.Time `json:"last_checkpoint,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// walMonitor periodically truncates the WAL once it exceeds a threshold
+type walMonitor struct {
+	mu        sync.Mutex
+	threshold int64
+	stats     WALStats
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// walPath returns the WAL file of a SQLite database path, or "" for
+// in-memory databases
+func walPath(dbPath string) string {
+	path := strings.TrimPrefix(dbPath, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path + "-wal"
+}
+
+// WALSize returns the current size of the write-ahead log in bytes
+func (s *SQLiteStore) WALSize() (int64, error) {
+	path := walPath(s.path)
+	if path == "" {
+		return 0, nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("stat wal: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Checkpoint runs a TRUNCATE checkpoint, copying the WAL into the database
+// and resetting it to zero bytes. complete is false when active readers
+// prevented the WAL from being fully checkpointed.
+func (s *SQLiteStore) Checkpoint(ctx context.Context) (complete bool, err error) {
+	// Block writers so the checkpoint is not starved by new frames
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var busy, logFrames, checkpointed int
+	err = s.busy.retryBusy(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logFrames, &checkpointed)
+	})
+	if err != nil {
+		return false, fmt.Errorf("wal checkpoint: %w", err)
+	}
+
+	return busy == 0, nil
+}
+
+// StartWALMonitor checks the WAL size every interval and triggers a TRUNCATE
+// checkpoint when it exceeds thresholdBytes. Long-running readers stall
+// SQLite's passive auto-checkpoints, letting the WAL grow without bound.
+// The monitor stops when the store is closed.
+func (s *SQLiteStore) StartWALMonitor(interval time.Duration, thresholdBytes int64) {
+	if interval <= 0 || thresholdBytes <= 0 || s.wal != nil {
+		return
+	}
+
+	m := &walMonitor{
+		threshold: thresholdBytes,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	m.stats.ThresholdBytes = thresholdBytes
+	s.wal = m
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				s.checkWAL(m)
+			}
+		}
+	}()
+}
+
+// checkWAL checkpoints the WAL if it has grown past the monitor threshold
+func (s *SQLiteStore) checkWAL(m *walMonitor) {
+	size, err := s.WALSize()
+	if err != nil || size < m.threshold {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	complete, err := s.Checkpoint(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.stats.LastError = err.Error()
+		slog.Error("WAL checkpoint failed", "error", err, "wal_bytes", size)
+		return
+	}
+
+	m.stats.Checkpoints++
+	m.stats.LastCheckpoint = time.Now()
+	m.stats.LastError = ""
+	if !complete {
+		m.stats.Incomplete++
+		slog.Warn("WAL checkpoint incomplete, readers still active", "wal_bytes", size)
+		return
+	}
+	slog.Info("WAL checkpointed", "wal_bytes", size)
+}
+
+// WALStats returns the current WAL size and checkpoint counters
+func (s *SQLiteStore) WALStats() WALStats {
+	var stats WALStats
+	if s.wal != nil {
+		s.wal.mu.Lock()
+		stats = s.wal.stats
+		s.wal.mu.Unlock()
+	}
+	stats.SizeBytes, _ = s.WALSize()
+	return stats
+}
+
+// stopWALMonitor stops the monitor goroutine, if running
+func (s *SQLiteStore) stopWALMonitor() {
+	if s.wal == nil {
+		return
+	}
+	close(s.wal.stop)
+	<-s.wal.done
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWALPath(t *testing.T) {
+	tests := map[string]string{
+		"events.db":                  "events.db-wal",
+		"file:events.db?mode=rwc":    "events.db-wal",
+		":memory:":                   "",
+		"file::memory:?cache=shared": "",
+	}
+	for in, want := range tests {
+		if got := walPath(in); got != want {
+			t.Errorf("walPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSQLiteStore_Checkpoint(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "wal.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	for range 100 {
+		s.Save(ctx, &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{"n":1}`), Timestamp: time.Now()})
+	}
+
+	size, err := s.WALSize()
+	if err != nil {
+		t.Fatalf("WALSize failed: %v", err)
+	}
+	if size == 0 {
+		t.Fatal("Expected a non-empty WAL after writes")
+	}
+
+	complete, err := s.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if !complete {
+		t.Error("Expected checkpoint to complete without readers")
+	}
+	if size, _ := s.WALSize(); size != 0 {
+		t.Errorf("Expected WAL to be truncated, got %d bytes", size)
+	}
+}
+
+func TestSQLiteStore_WALMonitor(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "wal.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	s.StartWALMonitor(10*time.Millisecond, 1)
+
+	ctx := context.Background()
+	s.Save(ctx, &StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.WALStats().Checkpoints == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("WAL monitor did not checkpoint")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := s.WALStats()
+	if stats.ThresholdBytes != 1 || stats.LastCheckpoint.IsZero() || stats.LastError != "" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,72 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", addr)
+	sent, err := Notify("READY=1")
+	if err != nil || !sent {
+		t.Fatalf("Expected notification to be sent, got %v, %v", sent, err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("Expected READY=1, got %q", got)
+	}
+}
+
+func TestNotifyOutsideSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	sent, err := Notify("READY=1")
+	if sent || err != nil {
+		t.Errorf("Expected no-op, got %v, %v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if d := WatchdogInterval(); d != 0 {
+		t.Errorf("Expected watchdog disabled, got %v", d)
+	}
+
+	t.Setenv("WATCHDOG_USEC", "20000000")
+	if d := WatchdogInterval(); d != 10*time.Second {
+		t.Errorf("Expected 10s, got %v", d)
+	}
+
+	// The watchdog is meant for another process
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d := WatchdogInterval(); d != 0 {
+		t.Errorf("Expected watchdog disabled for other pid, got %v", d)
+	}
+}
+
+func TestListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := Listeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Expected no listeners for another pid, got %v, %v", listeners, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package throttle paces heavy history operations, such as replays,
+// exports and archiving, so they stay out of the way of regular traffic:
+// they run only within a daily time window and read at most a number of
+// events per second, shared by all operations using the same Throttle.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Window is a daily time span, such as 02:00-05:00. A window whose end is
+// before its start spans midnight.
+type Window struct {
+	start, end time.Duration // Since midnight
+	location   *time.Location
+}
+
+// ParseWindow parses "HH:MM-HH:MM" in loc (nil = time.Local)
+func ParseWindow(s string, loc *time.Location) (*Window, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	var sh, sm, eh, em int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &sh, &sm, &eh, &em); err != nil {
+		return nil, fmt.Errorf("invalid window %q, want HH:MM-HH:MM", s)
+	}
+	for _, part := range []struct{ h, m int }{{sh, sm}, {eh, em}} {
+		if part.h < 0 || part.h > 24 || part.m < 0 || part.m > 59 || part.h == 24 && part.m != 0 {
+			return nil, fmt.Errorf("invalid window %q, want HH:MM-HH:MM", s)
+		}
+	}
+	w := &Window{
+		start:    time.Duration(sh)*time.Hour + time.Duration(sm)*time.Minute,
+		end:      time.Duration(eh)*time.Hour + time.Duration(em)*time.Minute,
+		location: loc,
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("invalid window %q: empty", s)
+	}
+	return w, nil
+}
+
+// String returns the window as HH:MM-HH:MM
+func (w *Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+// Until returns how long after t the window opens, 0 if it is open at t
+func (w *Window) Until(t time.Time) time.Duration {
+	t = t.In(w.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.location)
+	offset := t.Sub(midnight)
+
+	open := offset >= w.start && offset < w.end
+	if w.end < w.start {
+		open = offset >= w.start || offset < w.end
+	}
+	if open {
+		return 0
+	}
+	opens := midnight.Add(w.start)
+	if !opens.After(t) {
+		opens = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, w.location).Add(w.start)
+	}
+	return opens.Sub(t)
+}
+
+// Throttle combines a window and a rate cap; a nil Throttle allows
+// everything
+type Throttle struct {
+	window  *Window
+	limiter *rate.Limiter
+	now     func() time.Time
+}
+
+// New returns a Throttle for window (nil = any time) and eventsPerSecond
+// (0 = unlimited), or nil if neither limits anything
+func New(window *Window, eventsPerSecond int) *Throttle {
+	if window == nil && eventsPerSecond <= 0 {
+		return nil
+	}
+	t := &Throttle{window: window, now: time.Now}
+	if eventsPerSecond > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(eventsPerSecond), eventsPerSecond)
+	}
+	return t
+}
+
+// Closed returns how long until the window opens, 0 while it is open
+func (t *Throttle) Closed() time.Duration {
+	if t == nil || t.window == nil {
+		return 0
+	}
+	return t.window.Until(t.now())
+}
+
+// Window returns the window of t, nil if it has none
+func (t *Throttle) Window() *Window {
+	if t == nil {
+		return nil
+	}
+	return t.window
+}
+
+// Pace waits until n more events may be read under the rate cap
+func (t *Throttle) Pace(ctx context.Context, n int) error {
+	if t == nil || t.limiter == nil {
+		return nil
+	}
+	for n > 0 {
+		chunk := min(n, t.limiter.Burst())
+		if err := t.limiter.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// Wait waits until the window is open and n more events may be read
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	for {
+		closed := t.Closed()
+		if closed == 0 {
+			break
+		}
+		timer := time.NewTimer(closed)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return t.Pace(ctx, n)
+}
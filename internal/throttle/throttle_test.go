@@ -0,0 +1,81 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow("02:00-05:30", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseWindow failed: %v", err)
+	}
+	if w.String() != "02:00-05:30" {
+		t.Errorf("String() = %q", w.String())
+	}
+
+	for _, s := range []string{"", "2-5", "02:00", "25:00-05:00", "02:60-05:00", "03:00-03:00", "24:30-01:00"} {
+		if _, err := ParseWindow(s, time.UTC); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded", s)
+		}
+	}
+}
+
+func TestWindow_Until(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2026, 3, 10, h, m, 0, 0, time.UTC) }
+
+	nightly, _ := ParseWindow("02:00-05:00", time.UTC)
+	overnight, _ := ParseWindow("22:00-04:00", time.UTC)
+	for _, tc := range []struct {
+		w    *Window
+		at   time.Time
+		want time.Duration
+	}{
+		{nightly, day(2, 0), 0},
+		{nightly, day(4, 59), 0},
+		{nightly, day(5, 0), 21 * time.Hour},
+		{nightly, day(1, 30), 30 * time.Minute},
+		{overnight, day(23, 0), 0},
+		{overnight, day(3, 0), 0},
+		{overnight, day(4, 0), 18 * time.Hour},
+		{overnight, day(21, 0), time.Hour},
+	} {
+		if got := tc.w.Until(tc.at); got != tc.want {
+			t.Errorf("%s at %s: Until = %v, want %v", tc.w, tc.at.Format("15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	if New(nil, 0) != nil {
+		t.Error("Expected nil without a window or rate")
+	}
+	var unlimited *Throttle
+	if unlimited.Closed() != 0 || unlimited.Wait(context.Background(), 1_000_000) != nil {
+		t.Error("A nil Throttle must allow everything")
+	}
+
+	// The window is closed for another hour
+	w, _ := ParseWindow("02:00-05:00", time.UTC)
+	th := New(w, 0)
+	th.now = func() time.Time { return time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC) }
+	if th.Closed() != time.Hour {
+		t.Errorf("Closed() = %v, want 1h", th.Closed())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := th.Wait(ctx, 1); err == nil {
+		t.Error("Expected Wait to block until the window opens")
+	}
+
+	// 1000 events/s: 1300 events take 0.3s after the first 1000
+	th = New(nil, 1000)
+	start := time.Now()
+	if err := th.Pace(context.Background(), 1300); err != nil {
+		t.Fatalf("Pace failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("1300 events at 1000/s took %v", elapsed)
+	}
+}
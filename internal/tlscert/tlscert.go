@@ -0,0 +1,48 @@
+// Package tlscert serves a TLS certificate from PEM files that can be
+// replaced while the server runs, e.g. by certbot or a secrets agent.
+package tlscert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+)
+
+// Reloader holds the certificate of a certificate and key file pair
+type Reloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// NewReloader loads certFile and keyFile, which fails if they do not hold a
+// matching certificate and private key
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On failure the current certificate is kept,
+// so a half-written renewal does not take the server down.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// Certificate returns the current certificate
+func (r *Reloader) Certificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// GetCertificate is a tls.Config.GetCertificate returning the current
+// certificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
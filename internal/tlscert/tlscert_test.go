@@ -0,0 +1,88 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate with serial to certFile and its
+// key to keyFile
+func writeCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := NewReloader(certFile, keyFile); err == nil {
+		t.Error("Expected missing files to fail")
+	}
+
+	writeCert(t, certFile, keyFile, 1)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	cert, err := r.GetCertificate(nil)
+	if err != nil || cert.Leaf.SerialNumber.Int64() != 1 {
+		t.Fatalf("Expected serial 1, got %v", err)
+	}
+
+	// A renewed certificate is served after Reload
+	writeCert(t, certFile, keyFile, 2)
+	if got := r.Certificate().Leaf.SerialNumber.Int64(); got != 1 {
+		t.Errorf("Expected serial 1 before reload, got %d", got)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := r.Certificate().Leaf.SerialNumber.Int64(); got != 2 {
+		t.Errorf("Expected serial 2 after reload, got %d", got)
+	}
+
+	// A certificate whose key has not been replaced yet is rejected
+	keyData, _ := os.ReadFile(keyFile)
+	writeCert(t, certFile, keyFile, 3)
+	os.WriteFile(keyFile, keyData, 0600)
+	if err := r.Reload(); err == nil {
+		t.Error("Expected a mismatched key to fail")
+	}
+	if got := r.Certificate().Leaf.SerialNumber.Int64(); got != 2 {
+		t.Errorf("Expected serial 2 to be kept, got %d", got)
+	}
+}
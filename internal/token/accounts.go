@@ -0,0 +1,180 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAccountExists is returned by Create for a name already in use
+var ErrAccountExists = errors.New("service account already exists")
+
+// validName is the charset of account names, as for tenant names
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,100}$`)
+
+// Account is a service account: a name and secret that are exchanged for
+// tokens of one tenant
+type Account struct {
+	Name       string    `json:"name"`
+	Tenant     string    `json:"tenant"`
+	Scopes     []string  `json:"scopes"`
+	SecretHash string    `json:"secret_hash,omitempty"` // Hex SHA-256 of the secret
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Accounts holds the service accounts, persisted to a JSON file
+type Accounts struct {
+	path string // Empty keeps accounts in memory only
+
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+// OpenAccounts loads the accounts stored at path, which may not exist yet.
+// With path empty, accounts are lost on restart.
+func OpenAccounts(path string) (*Accounts, error) {
+	a := &Accounts{path: path, accounts: make(map[string]Account)}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read service accounts: %w", err)
+	}
+	var accounts []Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("parse service accounts: %w", err)
+	}
+	for _, account := range accounts {
+		a.accounts[account.Name] = account
+	}
+	return a, nil
+}
+
+// Create adds an account and returns it with its secret, which is not
+// stored and cannot be retrieved again
+func (a *Accounts) Create(name, tenant string, scopes []string) (Account, string, error) {
+	if !validName.MatchString(name) {
+		return Account{}, "", fmt.Errorf("invalid name %q, only alphanumeric characters, hyphens, and underscores are allowed", name)
+	}
+	if len(scopes) == 0 {
+		return Account{}, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeAppend {
+			return Account{}, "", fmt.Errorf("invalid scope %q, expected %s or %s", scope, ScopeRead, ScopeAppend)
+		}
+	}
+
+	var raw [32]byte
+	rand.Read(raw[:])
+	secret := base64.RawURLEncoding.EncodeToString(raw[:])
+	account := Account{
+		Name:       name,
+		Tenant:     tenant,
+		Scopes:     slices.Compact(slices.Sorted(slices.Values(scopes))),
+		SecretHash: hashSecret(secret),
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.accounts[name]; ok {
+		return Account{}, "", ErrAccountExists
+	}
+	a.accounts[name] = account
+	if err := a.save(); err != nil {
+		delete(a.accounts, name)
+		return Account{}, "", err
+	}
+	return account, secret, nil
+}
+
+// Delete removes an account, reporting whether it existed. Tokens already
+// issued to it stay valid until they expire.
+func (a *Accounts) Delete(name string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	account, ok := a.accounts[name]
+	if !ok {
+		return false, nil
+	}
+	delete(a.accounts, name)
+	if err := a.save(); err != nil {
+		a.accounts[name] = account
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns the accounts by name, without their secret hashes
+func (a *Accounts) List() []Account {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	accounts := make([]Account, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		account.SecretHash = ""
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return accounts
+}
+
+// Authenticate returns the account of name if secret is its secret
+func (a *Accounts) Authenticate(name, secret string) (Account, bool) {
+	a.mu.RLock()
+	account, ok := a.accounts[name]
+	a.mu.RUnlock()
+
+	// Compared even for unknown names, so timing does not reveal them
+	match := subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(account.SecretHash)) == 1
+	return account, ok && match
+}
+
+// save writes all accounts to the file. Must be called with mu held.
+func (a *Accounts) save() error {
+	if a.path == "" {
+		return nil
+	}
+	accounts := make([]Account, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Replaced in one rename, so a crash never leaves a truncated file
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write service accounts: %w", err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("write service accounts: %w", err)
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,62 @@
+package token
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	accounts, err := OpenAccounts(path)
+	if err != nil {
+		t.Fatalf("OpenAccounts failed: %v", err)
+	}
+
+	account, secret, err := accounts.Create("projector", "alice", []string{"read", "read"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(account.Scopes) != 1 || secret == "" {
+		t.Errorf("Unexpected account %+v", account)
+	}
+	if _, _, err := accounts.Create("projector", "alice", []string{"read"}); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("Expected ErrAccountExists, got %v", err)
+	}
+	for _, scopes := range [][]string{nil, {"admin"}} {
+		if _, _, err := accounts.Create("other", "alice", scopes); err == nil {
+			t.Errorf("Expected an error for scopes %v", scopes)
+		}
+	}
+	if _, _, err := accounts.Create("../etc", "alice", []string{"read"}); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+
+	// Accounts survive a restart
+	reopened, err := OpenAccounts(path)
+	if err != nil {
+		t.Fatalf("OpenAccounts failed: %v", err)
+	}
+	if got, ok := reopened.Authenticate("projector", secret); !ok || got.Tenant != "alice" {
+		t.Errorf("Expected the secret to authenticate, got %+v", got)
+	}
+	if _, ok := reopened.Authenticate("projector", "wrong"); ok {
+		t.Error("Expected a wrong secret to fail")
+	}
+	if _, ok := reopened.Authenticate("unknown", secret); ok {
+		t.Error("Expected an unknown account to fail")
+	}
+	if list := reopened.List(); len(list) != 1 || list[0].SecretHash != "" {
+		t.Errorf("Expected one account without its hash, got %+v", list)
+	}
+
+	if deleted, err := reopened.Delete("projector"); !deleted || err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := reopened.Authenticate("projector", secret); ok {
+		t.Error("Expected a deleted account to fail")
+	}
+	if reopened, _ = OpenAccounts(path); len(reopened.List()) != 0 {
+		t.Error("Expected the deletion to be saved")
+	}
+}
@@ -0,0 +1,130 @@
+// Package token issues and verifies the short-lived bearer tokens of
+// service accounts. Tokens are JWTs signed with HMAC-SHA256 (HS256), so any
+// replica sharing the signing secret verifies them without shared state.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes a token can carry
+const (
+	ScopeRead   = "read"   // Everything but appending events
+	ScopeAppend = "append" // POST /events and /events/batch
+)
+
+// MinSecretBytes is the shortest signing secret accepted
+const MinSecretBytes = 32
+
+var (
+	// ErrInvalid is returned for tokens that are malformed or not signed by
+	// any of the Signer's secrets
+	ErrInvalid = errors.New("invalid token")
+
+	// ErrExpired is returned for correctly signed tokens past their expiry
+	ErrExpired = errors.New("token expired")
+)
+
+// header is the only JOSE header tokens are issued and accepted with
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the contents of a token
+type Claims struct {
+	Subject   string `json:"sub"`    // Service account name
+	Tenant    string `json:"tenant"` // "default" in single-tenant mode
+	Scope     string `json:"scope"`  // Space-separated scopes
+	IssuedAt  int64  `json:"iat"`    // Unix seconds
+	ExpiresAt int64  `json:"exp"`    // Unix seconds
+}
+
+// HasScope reports whether the claims grant scope
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// Signer signs tokens with its first secret and accepts tokens signed with
+// any of them, so secrets can be rotated without rejecting issued tokens
+type Signer struct {
+	secrets [][]byte
+}
+
+// NewSigner returns a Signer on secrets, newest first
+func NewSigner(secrets ...string) (*Signer, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("no token secret")
+	}
+	s := &Signer{}
+	for _, secret := range secrets {
+		if len(secret) < MinSecretBytes {
+			return nil, fmt.Errorf("token secret must be at least %d bytes", MinSecretBytes)
+		}
+		s.secrets = append(s.secrets, []byte(secret))
+	}
+	return s, nil
+}
+
+// Sign returns the token of claims
+func (s *Signer) Sign(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac(s.secrets[0], signed)), nil
+}
+
+// Verify returns the claims of token if it is signed by one of the secrets
+// and not expired at now
+func (s *Signer) Verify(token string, now time.Time) (Claims, error) {
+	signed, sig, ok := cutLast(token, ".")
+	if !ok || !strings.HasPrefix(signed, header+".") {
+		return Claims{}, ErrInvalid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	if !slices.ContainsFunc(s.secrets, func(secret []byte) bool { return hmac.Equal(got, mac(secret, signed)) }) {
+		return Claims{}, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(signed, header+"."))
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+// Looks reports whether s has the shape of a token, to tell tokens from API
+// keys before verifying them
+func Looks(s string) bool {
+	return strings.HasPrefix(s, header+".") && strings.Count(s, ".") == 2
+}
+
+func mac(secret []byte, signed string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	secret1 = "0123456789abcdef0123456789abcdef"
+	secret2 = "fedcba9876543210fedcba9876543210"
+)
+
+func TestSignVerify(t *testing.T) {
+	signer, err := NewSigner(secret1)
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	token, err := signer.Sign(Claims{Subject: "projector", Tenant: "alice", Scope: "read", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !Looks(token) || Looks("plain-api-key") {
+		t.Error("Expected Looks to tell tokens from API keys")
+	}
+
+	claims, err := signer.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.Subject != "projector" || claims.Tenant != "alice" || !claims.HasScope(ScopeRead) || claims.HasScope(ScopeAppend) {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := signer.Verify(token, now.Add(time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+
+	// Changed claims break the signature
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+	for _, bad := range []string{forged, token + "x", "a.b.c", ""} {
+		if _, err := signer.Verify(bad, now); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected ErrInvalid for %q, got %v", bad, err)
+		}
+	}
+
+	// Rotation: the new secret signs, the old one still verifies
+	rotated, _ := NewSigner(secret2, secret1)
+	if _, err := rotated.Verify(token, now); err != nil {
+		t.Errorf("Expected the old secret to verify, got %v", err)
+	}
+	newToken, _ := rotated.Sign(Claims{ExpiresAt: now.Add(time.Minute).Unix()})
+	if _, err := signer.Verify(newToken, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected tokens of another secret to be invalid, got %v", err)
+	}
+
+	if _, err := NewSigner("short"); err == nil {
+		t.Error("Expected short secrets to be rejected")
+	}
+}
@@ -0,0 +1,416 @@
+// Package websocket implements the parts of RFC 6455 that ebuse needs: the
+// opening handshake on both sides, text messages, ping/pong and the closing
+// handshake. Extensions such as compression are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close codes used by ebuse
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseTooBig        = 1009
+	CloseInternalError = 1011
+)
+
+// MaxMessageBytes bounds messages read from the peer
+const MaxMessageBytes = 1 << 20
+
+// handshakeGUID is appended to the client key to derive the accept key
+const handshakeGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// dialTransport never negotiates HTTP/2, which cannot upgrade
+var dialTransport = &http.Transport{
+	Proxy:             http.ProxyFromEnvironment,
+	ForceAttemptHTTP2: false,
+	TLSNextProto:      map[string]func(string, *tls.Conn) http.RoundTripper{},
+}
+
+// ErrBadHandshake is returned by Dial when the server refuses the upgrade
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// CloseError is returned by ReadMessage once the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. Reads must come from one goroutine;
+// writes may come from any.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // Clients mask the frames they send
+
+	wmu    sync.Mutex
+	closed bool // A close frame was sent
+}
+
+// acceptKey derives Sec-WebSocket-Accept from Sec-WebSocket-Key
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + handshakeGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains reports whether a comma-separated header holds token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUpgrade reports whether r asks for a WebSocket connection
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the opening handshake of r. On failure an error
+// response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: hijack: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: write handshake: %w", err)
+	}
+	// Clear the deadlines of the HTTP server, the connection is ours now
+	conn.SetDeadline(time.Time{})
+
+	return &Conn{conn: conn, br: brw.Reader}, nil
+}
+
+// Dial opens a WebSocket connection to url (ws:// or wss://, or http:// and
+// https:// for the same servers), sending header with the handshake. When
+// the server refuses the upgrade, its response is returned with
+// ErrBadHandshake.
+func Dial(ctx context.Context, url string, header http.Header) (*Conn, *http.Response, error) {
+	return DialTLS(ctx, url, header, nil)
+}
+
+// DialTLS is Dial with the TLS configuration of wss:// and https:// URLs
+// (nil uses the defaults)
+func DialTLS(ctx context.Context, url string, header http.Header, config *tls.Config) (*Conn, *http.Response, error) {
+	url = strings.Replace(url, "ws://", "http://", 1)
+	url = strings.Replace(url, "wss://", "https://", 1)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	transport := dialTransport
+	if config != nil {
+		transport = dialTransport.Clone()
+		transport.TLSClientConfig = config
+	}
+
+	// http.Transport returns the connection of a 101 response as the body
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, resp, ErrBadHandshake
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, resp, ErrBadHandshake
+	}
+
+	return &Conn{conn: &bodyConn{rwc}, br: bufio.NewReader(rwc), client: true}, resp, nil
+}
+
+// bodyConn adapts the body of a 101 response, which carries the
+// connection, to the parts of net.Conn a Conn uses
+type bodyConn struct {
+	io.ReadWriteCloser
+}
+
+func (bodyConn) LocalAddr() net.Addr              { return nil }
+func (bodyConn) RemoteAddr() net.Addr             { return nil }
+func (bodyConn) SetDeadline(time.Time) error      { return nil }
+func (bodyConn) SetReadDeadline(time.Time) error  { return nil }
+func (bodyConn) SetWriteDeadline(time.Time) error { return nil }
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// while waiting; a close from the peer is echoed and returned as
+// *CloseError.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, op, payload, err := c.readFrame(MaxMessageBytes - len(message))
+		if err != nil {
+			if errors.Is(err, errTooBig) {
+				c.Close(CloseTooBig, "message too big")
+			}
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &CloseError{Code: 1005}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			echo := closeErr.Code
+			if echo == 1005 { // "No status" must not be sent
+				echo = CloseNormal
+			}
+			c.Close(echo, "")
+			return nil, closeErr
+		case opText, opBinary:
+			if fragmented {
+				c.Close(CloseProtocolError, "expected continuation")
+				return nil, errors.New("websocket: expected continuation frame")
+			}
+		case opContinuation:
+			if !fragmented {
+				c.Close(CloseProtocolError, "unexpected continuation")
+				return nil, errors.New("websocket: unexpected continuation frame")
+			}
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+		fragmented = true
+	}
+}
+
+// ReadJSON reads the next message into v
+func (c *Conn) ReadJSON(v any) error {
+	data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends data as one text message
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// WriteJSON sends v as one text message
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(data)
+}
+
+// Ping sends a ping; the peer's pong is consumed by ReadMessage
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame, unless one was sent already, and closes the
+// connection
+func (c *Conn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	c.wmu.Lock()
+	alreadyClosed := c.closed
+	c.wmu.Unlock()
+	if !alreadyClosed {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(opClose, payload)
+	}
+	return c.conn.Close()
+}
+
+// SetReadDeadline bounds the wait for the next frame
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+var errTooBig = errors.New("websocket: message too big")
+
+func (c *Conn) readFrame(limit int) (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		c.Close(CloseProtocolError, "reserved bits set")
+		return false, 0, nil, errors.New("websocket: reserved bits set")
+	}
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (length > 125 || !fin) {
+		c.Close(CloseProtocolError, "invalid control frame")
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+	if op < opClose && length > uint64(max(limit, 0)) {
+		return false, 0, nil, errTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return errors.New("websocket: close sent")
+	}
+	if op == opClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoServer echoes messages until the client closes
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(CloseNormal, "")
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(data) == "bye" {
+				conn.Close(CloseGoingAway, "done")
+				return
+			}
+			if err := conn.WriteMessage(data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRoundTrip(t *testing.T) {
+	srv := echoServer(t)
+
+	conn, _, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close(CloseNormal, "")
+
+	// Small, 16-bit and 64-bit payload lengths
+	for _, size := range []int{5, 300, 70000} {
+		sent := strings.Repeat("x", size)
+		if err := conn.WriteMessage([]byte(sent)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if string(got) != sent {
+			t.Errorf("Expected %d bytes back, got %d", size, len(got))
+		}
+	}
+
+	if err := conn.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if err := conn.WriteJSON(map[string]int{"a": 1}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var echoed map[string]int
+	if err := conn.ReadJSON(&echoed); err != nil || echoed["a"] != 1 {
+		t.Fatalf("Expected the JSON message back after the pong, got %v (%v)", echoed, err)
+	}
+
+	conn.WriteMessage([]byte("bye"))
+	_, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Reason != "done" {
+		t.Errorf("Expected close 1001 done, got %v", err)
+	}
+}
+
+func TestMessageTooBig(t *testing.T) {
+	srv := echoServer(t)
+
+	conn, _, err := Dial(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close(CloseNormal, "")
+
+	if err := conn.WriteMessage(make([]byte, MaxMessageBytes+1)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseTooBig {
+		t.Errorf("Expected close 1009, got %v", err)
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	srv := echoServer(t)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Expected status %d for a plain request, got %d", http.StatusUpgradeRequired, resp.StatusCode)
+	}
+
+	notWS := httptest.NewServer(http.NotFoundHandler())
+	defer notWS.Close()
+	_, resp, err = Dial(context.Background(), notWS.URL, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if !errors.Is(err, ErrBadHandshake) || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected ErrBadHandshake with the 404 response, got %v", err)
+	}
+}
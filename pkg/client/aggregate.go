@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// AggregateQuery and Aggregate are the request and result of Aggregate, see
+// store.AggregateQuery
+type (
+	AggregateQuery = store.AggregateQuery
+	Aggregate      = store.Aggregate
+)
+
+// Aggregate counts the events matching q on the server, optionally grouped
+// by type and time bucket, without transferring them. Buckets must be at
+// least a second.
+func (c *HTTPClient) Aggregate(ctx context.Context, q AggregateQuery) (*Aggregate, error) {
+	query := filterQuery(q.Filter)
+	if q.From != 0 {
+		query.Set("from", strconv.FormatInt(q.From, 10))
+	}
+	if q.To != 0 && q.To != -1 {
+		query.Set("to", strconv.FormatInt(q.To, 10))
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339Nano))
+	}
+	if q.GroupByType {
+		query.Set("group_by", "type")
+	}
+	if q.Bucket > 0 {
+		query.Set("bucket", q.Bucket.String())
+	}
+
+	ctx, cancel := withTimeout(ctx, c.loadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/events/aggregate?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result Aggregate
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestAggregate(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/aggregate" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query().Encode()
+		json.NewEncoder(w).Encode(Aggregate{
+			Count:  3,
+			Groups: []store.AggregateGroup{{Type: "OrderPlaced", Count: 3}},
+		})
+	}))
+	defer server.Close()
+
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	result, err := New(server.URL, "test-key").Aggregate(context.Background(), AggregateQuery{
+		From:        10,
+		To:          -1,
+		Since:       since,
+		Filter:      Filter{Types: []string{"Order*"}},
+		GroupByType: true,
+		Bucket:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if result.Count != 3 || len(result.Groups) != 1 || result.Groups[0].Type != "OrderPlaced" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	want := "bucket=1h0m0s&from=10&group_by=type&since=2026-03-01T00%3A00%3A00Z&type=Order%2A"
+	if query != want {
+		t.Errorf("Query = %s, want %s", query, want)
+	}
+}
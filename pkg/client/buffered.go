@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Write buffering defaults
+const (
+	DefaultBufferEvents = 500                    // Events per /events/batch request
+	DefaultBufferDelay  = 100 * time.Millisecond // Longest wait before a partial batch is sent
+	maxBufferEvents     = 1000                   // Largest batch the server accepts
+)
+
+// ErrBufferClosed is returned by Save on a closed BufferedClient
+var ErrBufferClosed = errors.New("buffered client is closed")
+
+// BufferOptions tune a BufferedClient; zero values select the defaults
+type BufferOptions struct {
+	// MaxEvents sends a batch once this many events wait (default
+	// DefaultBufferEvents, at most 1000)
+	MaxEvents int
+
+	// MaxDelay sends the waiting events at most this long after the first
+	// of them was saved (default DefaultBufferDelay)
+	MaxDelay time.Duration
+
+	// MaxPending makes Save block once this many events are buffered or
+	// being sent, so a slow server slows producers down instead of growing
+	// the buffer (default 10 * MaxEvents)
+	MaxPending int
+
+	// OnError is called with the events of every batch that failed, after
+	// the client's retries. Without it failures are only returned by Flush
+	// and Close for the batches they send.
+	OnError func(events []*store.StoredEvent, err error)
+}
+
+// BufferedClient collects saved events and writes them in /events/batch
+// requests, once MaxEvents are waiting or MaxDelay has passed. Save only
+// buffers the event, so it neither returns write errors nor sets the
+// event's position; use OnError, Flush and Close for those.
+//
+// Batches are sent one at a time in Save order. All other methods are those
+// of the wrapped HTTPClient and go to the server directly, so Flush before
+// reading events that were just saved.
+type BufferedClient struct {
+	*HTTPClient
+	opts BufferOptions
+
+	mu      sync.Mutex
+	pending []*store.StoredEvent
+	timer   *time.Timer // Sends pending events after MaxDelay
+	closed  bool
+
+	slots   chan struct{}   // One per buffered or in-flight event, bounded by MaxPending
+	kick    chan struct{}   // Wakes the sender
+	flushes chan chan error // Flush requests, answered once everything is sent
+	stop    chan struct{}   // Closed by Close to stop the sender
+	done    chan struct{}   // Closed when the sender exits
+	once    sync.Once
+}
+
+// NewBuffered returns a BufferedClient writing through c. Close it to send
+// the remaining events.
+func NewBuffered(c *HTTPClient, opts BufferOptions) *BufferedClient {
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = DefaultBufferEvents
+	}
+	opts.MaxEvents = min(opts.MaxEvents, maxBufferEvents)
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultBufferDelay
+	}
+	if opts.MaxPending < opts.MaxEvents {
+		opts.MaxPending = 10 * opts.MaxEvents
+	}
+
+	b := &BufferedClient{
+		HTTPClient: c,
+		opts:       opts,
+		slots:      make(chan struct{}, opts.MaxPending),
+		kick:       make(chan struct{}, 1),
+		flushes:    make(chan chan error),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Save buffers a copy of event for the next batch. It blocks while
+// MaxPending events are waiting, until ctx is done.
+func (b *BufferedClient) Save(ctx context.Context, event *store.StoredEvent) error {
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		<-b.slots
+		return ErrBufferClosed
+	}
+
+	copied := *event
+	b.pending = append(b.pending, &copied)
+	switch {
+	case len(b.pending) >= b.opts.MaxEvents:
+		b.wake()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.opts.MaxDelay, b.wake)
+	}
+	return nil
+}
+
+// Flush sends all buffered events and waits until they are written. It
+// returns the first error of the batches it sent.
+func (b *BufferedClient) Flush(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case b.flushes <- result:
+	case <-b.done:
+		return ErrBufferClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close sends the remaining events and stops the client. Later Saves return
+// ErrBufferClosed. It returns the first error of the last batches.
+func (b *BufferedClient) Close() error {
+	var err error
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+
+		err = b.Flush(context.Background())
+		close(b.stop)
+		<-b.done
+	})
+	return err
+}
+
+// wake asks the sender to send the pending events
+func (b *BufferedClient) wake() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run sends batches until Close
+func (b *BufferedClient) run() {
+	defer close(b.done)
+	for {
+		select {
+		case <-b.kick:
+			b.send()
+		case result := <-b.flushes:
+			result <- b.send()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// send writes the pending events in batches of MaxEvents and returns the
+// first error
+func (b *BufferedClient) send() error {
+	var first error
+	for {
+		b.mu.Lock()
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		n := min(len(b.pending), b.opts.MaxEvents)
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		b.mu.Unlock()
+
+		if n == 0 {
+			return first
+		}
+
+		if err := b.HTTPClient.SaveBatch(context.Background(), batch); err != nil {
+			if b.opts.OnError != nil {
+				b.opts.OnError(batch, err)
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		for range n {
+			<-b.slots
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestBufferedClient(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string // Event types per request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []*store.StoredEvent
+		if r.URL.Path != "/events/batch" || json.NewDecoder(r.Body).Decode(&events) != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		var types []string
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		batches = append(batches, types)
+		mu.Unlock()
+
+		if types[0] == "Rejected" {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"count": len(events)})
+	}))
+	defer server.Close()
+
+	sent := func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), batches...)
+	}
+
+	var failed []*store.StoredEvent
+	b := NewBuffered(New(server.URL, "test-key"), BufferOptions{
+		MaxEvents: 3,
+		MaxDelay:  time.Hour,
+		OnError: func(events []*store.StoredEvent, err error) {
+			failed = append(failed, events...)
+		},
+	})
+	ctx := context.Background()
+
+	// Full batches are sent right away, the rest on Flush
+	for _, typ := range []string{"A", "B", "C", "D", "E", "F", "G"} {
+		if err := b.Save(ctx, &store.StoredEvent{Type: typ, Data: json.RawMessage(`{}`)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	got := sent()
+	if len(got) != 3 || len(got[0]) != 3 || got[0][0] != "A" || got[1][0] != "D" || len(got[2]) != 1 || got[2][0] != "G" {
+		t.Errorf("Expected batches [A B C] [D E F] [G], got %v", got)
+	}
+
+	// Failed batches reach OnError and Flush
+	b.Save(ctx, &store.StoredEvent{Type: "Rejected", Data: json.RawMessage(`{}`)})
+	if err := b.Flush(ctx); err == nil {
+		t.Error("Expected Flush to return the batch error")
+	}
+	if len(failed) != 1 || failed[0].Type != "Rejected" {
+		t.Errorf("Expected the rejected event in OnError, got %v", failed)
+	}
+
+	b.Save(ctx, &store.StoredEvent{Type: "H", Data: json.RawMessage(`{}`)})
+	if err := b.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if got := sent(); got[len(got)-1][0] != "H" {
+		t.Errorf("Expected Close to send H, got %v", got)
+	}
+	if err := b.Save(ctx, &store.StoredEvent{Type: "I"}); !errors.Is(err, ErrBufferClosed) {
+		t.Errorf("Expected ErrBufferClosed, got %v", err)
+	}
+}
+
+func TestBufferedClient_MaxDelay(t *testing.T) {
+	requests := make(chan int, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []*store.StoredEvent
+		json.NewDecoder(r.Body).Decode(&events)
+		requests <- len(events)
+		json.NewEncoder(w).Encode(map[string]any{"count": len(events)})
+	}))
+	defer server.Close()
+
+	b := NewBuffered(New(server.URL, "test-key"), BufferOptions{MaxDelay: 10 * time.Millisecond})
+	defer b.Close()
+
+	b.Save(context.Background(), &store.StoredEvent{Type: "A", Data: json.RawMessage(`{}`)})
+	b.Save(context.Background(), &store.StoredEvent{Type: "B", Data: json.RawMessage(`{}`)})
+	select {
+	case n := <-requests:
+		if n != 2 {
+			t.Errorf("Expected one batch of 2 events, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the events to be sent after MaxDelay")
+	}
+}
+
+func TestBufferedClient_Backpressure(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"count": 1})
+	}))
+	defer server.Close()
+
+	b := NewBuffered(New(server.URL, "test-key"), BufferOptions{MaxEvents: 1, MaxPending: 2})
+
+	// One event in flight and one waiting fill MaxPending
+	ctx := context.Background()
+	b.Save(ctx, &store.StoredEvent{Type: "A", Data: json.RawMessage(`{}`)})
+	b.Save(ctx, &store.StoredEvent{Type: "B", Data: json.RawMessage(`{}`)})
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := b.Save(timeout, &store.StoredEvent{Type: "C"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Save to block until the deadline, got %v", err)
+	}
+
+	close(release)
+	if err := b.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// rangeKey identifies a closed [from, to] range returned by Load
+type rangeKey struct {
+	from, to int64
+}
+
+type rangeEntry struct {
+	key    rangeKey
+	events []*store.StoredEvent
+}
+
+// rangeCache is an LRU cache of immutable event ranges bounded by the
+// total number of cached events
+type rangeCache struct {
+	mu        sync.Mutex
+	maxEvents int
+	size      int
+	ll        *list.List
+	items     map[rangeKey]*list.Element
+}
+
+func newRangeCache(maxEvents int) *rangeCache {
+	return &rangeCache{
+		maxEvents: maxEvents,
+		ll:        list.New(),
+		items:     make(map[rangeKey]*list.Element),
+	}
+}
+
+// get returns a copy of the cached range so callers can't mutate cached events
+func (rc *rangeCache) get(from, to int64) ([]*store.StoredEvent, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.items[rangeKey{from, to}]
+	if !ok {
+		return nil, false
+	}
+	rc.ll.MoveToFront(elem)
+	return cloneEvents(elem.Value.(*rangeEntry).events), true
+}
+
+// put stores a range, evicting the least recently used ranges until it fits
+func (rc *rangeCache) put(from, to int64, events []*store.StoredEvent) {
+	if len(events) == 0 || len(events) > rc.maxEvents {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	key := rangeKey{from, to}
+	if _, exists := rc.items[key]; exists {
+		return
+	}
+
+	for rc.size+len(events) > rc.maxEvents {
+		oldest := rc.ll.Back()
+		if oldest == nil {
+			break
+		}
+		entry := rc.ll.Remove(oldest).(*rangeEntry)
+		delete(rc.items, entry.key)
+		rc.size -= len(entry.events)
+	}
+
+	rc.items[key] = rc.ll.PushFront(&rangeEntry{key: key, events: cloneEvents(events)})
+	rc.size += len(events)
+}
+
+// len returns the number of cached events
+func (rc *rangeCache) len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.size
+}
+
+func cloneEvents(events []*store.StoredEvent) []*store.StoredEvent {
+	cloned := make([]*store.StoredEvent, len(events))
+	for i, e := range events {
+		c := *e
+		cloned[i] = &c
+	}
+	return cloned
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestLoad_RangeCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+
+		// Pretend the head is at position 5
+		var events []*store.StoredEvent
+		for p := from; p <= to && p <= 5; p++ {
+			events = append(events, &store.StoredEvent{Position: p, Type: "Event"})
+		}
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRangeCache(100))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		events, err := client.Load(ctx, 1, 5)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(events) != 5 {
+			t.Fatalf("expected 5 events, got %d", len(events))
+		}
+		// Mutating a returned event must not affect the cache
+		events[0].Type = "Mutated"
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request for a closed range, got %d", got)
+	}
+
+	events, _ := client.Load(ctx, 1, 5)
+	if events[0].Type != "Event" {
+		t.Errorf("cached event was mutated: %s", events[0].Type)
+	}
+
+	// Range beyond the head is still open and must not be cached
+	client.Load(ctx, 1, 10)
+	client.Load(ctx, 1, 10)
+	if got := requests.Load(); got != 3 {
+		t.Errorf("expected open range to bypass cache, got %d requests", got)
+	}
+
+	// to=-1 is always open
+	client.Load(ctx, 1, -1)
+	if got := requests.Load(); got != 4 {
+		t.Errorf("expected to=-1 to bypass cache, got %d requests", got)
+	}
+}
+
+func TestRangeCache_Eviction(t *testing.T) {
+	rc := newRangeCache(4)
+
+	mk := func(from, to int64) []*store.StoredEvent {
+		var events []*store.StoredEvent
+		for p := from; p <= to; p++ {
+			events = append(events, &store.StoredEvent{Position: p})
+		}
+		return events
+	}
+
+	rc.put(1, 2, mk(1, 2))
+	rc.put(3, 4, mk(3, 4))
+	rc.get(1, 2) // Mark 1-2 as recently used
+	rc.put(5, 6, mk(5, 6))
+
+	if _, ok := rc.get(3, 4); ok {
+		t.Error("expected least recently used range to be evicted")
+	}
+	if _, ok := rc.get(1, 2); !ok {
+		t.Error("expected recently used range to be kept")
+	}
+	if rc.len() != 4 {
+		t.Errorf("expected 4 cached events, got %d", rc.len())
+	}
+
+	// Ranges larger than the whole cache are ignored
+	rc.put(10, 20, mk(10, 20))
+	if _, ok := rc.get(10, 20); ok {
+		t.Error("expected oversized range not to be cached")
+	}
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Catch-up defaults
+const (
+	DefaultCatchUpPoll = time.Second      // Delay between polls without SSE
+	maxCatchUpRetry    = 30 * time.Second // Longest delay between failed attempts
+)
+
+// CatchUpOptions tune CatchUp; zero values select the defaults
+type CatchUpOptions struct {
+	// BatchSize caps the events per handler call while catching up
+	// (default store.DefaultStreamBatchSize)
+	BatchSize int
+
+	// PollInterval is the delay between polls for new events when the server
+	// has no /events/subscribe, and the first delay after an error (default
+	// DefaultCatchUpPoll). Delays after errors double up to 30s.
+	PollInterval time.Duration
+
+	// Poll checks for new events every PollInterval instead of tailing
+	// them over Server-Sent Events
+	Poll bool
+
+	// Filter restricts the events handler gets; the position saved is that
+	// of the last event delivered
+	Filter Filter
+
+	// Workers above 1 runs handler on that many goroutines, so it must be
+	// safe for concurrent use. Events are split by PartitionKey, and all
+	// events of one key go to the same worker in position order: events of
+	// a key are handled in order, different keys in parallel. The position
+	// saved is the last one up to which every event was handled.
+	Workers int
+
+	// PartitionKey is the dotted path into event data of the key that
+	// Workers keep the order of, e.g. "order_id" or "customer.id". Empty
+	// keys events by stream ID. Events without the key share one partition.
+	PartitionKey string
+}
+
+// statusError is an unexpected response status
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.code, e.body)
+}
+
+// retryable reports whether a later attempt may get a different answer
+func (e *statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// handlerError carries an error returned by a CatchUp handler
+type handlerError struct{ err error }
+
+func (e *handlerError) Error() string { return e.err.Error() }
+func (e *handlerError) Unwrap() error { return e.err }
+
+// CatchUp runs a client-side subscription: it loads subscriptionID's saved
+// position, streams the events written since, then tails new ones over
+// /events/subscribe (or polls for them, see CatchUpOptions.Poll and servers
+// without the endpoint). After every batch handler returns nil for, the
+// subscription's position is saved, so a later CatchUp resumes after it.
+// CatchUpOptions.Workers handles events in parallel, in order per key.
+//
+// Delivery is at least once: a batch is delivered again if saving its
+// position fails. Connection errors and 429/5xx responses are retried with
+// backoff, resuming from the last saved position. CatchUp returns ctx.Err()
+// when ctx ends, handler's error when it fails, and other server errors
+// such as a rejected API key.
+func (c *HTTPClient) CatchUp(ctx context.Context, subscriptionID string, handler func([]*store.StoredEvent) error, opts CatchUpOptions) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultCatchUpPoll
+	}
+
+	position, err := c.LoadSubscriptionPosition(ctx, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("load position: %w", err)
+	}
+
+	save := func(last int64) error {
+		if err := c.SaveSubscriptionPosition(ctx, subscriptionID, last); err != nil {
+			return fmt.Errorf("save position: %w", err)
+		}
+		return nil
+	}
+
+	// Workers end the reads below when a handler fails
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var workers *keyedWorkers
+	if opts.Workers > 1 {
+		if workers, err = newKeyedWorkers(opts.Workers, opts.PartitionKey, handler, save, position, cancel); err != nil {
+			return err
+		}
+		defer workers.stop()
+	}
+
+	// With workers, position is the last event dispatched rather than saved
+	deliver := func(events []*store.StoredEvent) error {
+		if workers != nil {
+			if err := workers.dispatch(readCtx, events); err != nil {
+				return err
+			}
+			position = events[len(events)-1].Position
+			return nil
+		}
+		if err := handler(events); err != nil {
+			return &handlerError{err}
+		}
+		last := events[len(events)-1].Position
+		if err := save(last); err != nil {
+			return err
+		}
+		position = last
+		return nil
+	}
+	tail := func(event *store.StoredEvent) error {
+		return deliver([]*store.StoredEvent{event})
+	}
+
+	poll := opts.Poll
+	retry := opts.PollInterval
+	for {
+		err := c.loadStream(readCtx, position+1, opts.BatchSize, opts.Filter, deliver)
+		if err == nil {
+			retry = opts.PollInterval
+			if !poll {
+				err = c.subscribe(readCtx, position+1, opts.Filter, tail)
+
+				// Servers without the endpoint are polled instead
+				var status *statusError
+				if errors.As(err, &status) && (status.code == http.StatusNotFound || status.code == http.StatusMethodNotAllowed) {
+					poll = true
+					continue
+				}
+			}
+		}
+
+		// Events in flight are finished before reading again from the
+		// last saved position
+		if workers != nil && err != nil {
+			var failed error
+			if position, failed = workers.drain(); failed != nil {
+				return failed
+			}
+		}
+
+		var failed *handlerError
+		var status *statusError
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.As(err, &failed):
+			return failed.err
+		case errors.As(err, &status) && !status.retryable():
+			return err
+		}
+
+		// Polled servers are asked again after PollInterval, failures later
+		// and later
+		delay := opts.PollInterval
+		if err != nil {
+			delay = retry
+			retry = min(2*retry, maxCatchUpRetry)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
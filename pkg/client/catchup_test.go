@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// catchUpServer serves what CatchUp needs from a memory store. Every
+// /events/subscribe connection appends one event, sends it and closes;
+// without sse the endpoint is missing.
+type catchUpServer struct {
+	t   *testing.T
+	st  *store.MemoryStore
+	sse bool
+
+	mu    sync.Mutex
+	saved []int64
+}
+
+func (s *catchUpServer) append(eventType string) {
+	if err := s.st.Save(context.Background(), &store.StoredEvent{Type: eventType, Data: json.RawMessage(`{}`)}); err != nil {
+		s.t.Errorf("Save failed: %v", err)
+	}
+}
+
+func (s *catchUpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-API-Key") != "test-key" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := r.Context()
+	from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+
+	switch {
+	case r.URL.Path == "/subscriptions/projector/position" && r.Method == http.MethodGet:
+		position, _ := s.st.LoadSubscriptionPosition(ctx, "projector")
+		json.NewEncoder(w).Encode(map[string]int64{"position": position})
+	case r.URL.Path == "/subscriptions/projector/position":
+		var body struct{ Position int64 }
+		json.NewDecoder(r.Body).Decode(&body)
+		s.st.SaveSubscriptionPosition(ctx, "projector", body.Position)
+		s.mu.Lock()
+		s.saved = append(s.saved, body.Position)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/events/stream":
+		events, _ := s.st.Load(ctx, from, -1)
+		enc := json.NewEncoder(w)
+		for _, event := range events {
+			enc.Encode(event)
+		}
+	case r.URL.Path == "/events/subscribe" && s.sse:
+		s.append("Live")
+		events, _ := s.st.Load(ctx, from, -1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Position, data)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *catchUpServer) savedPositions() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.saved...)
+}
+
+// collect records the positions of every batch and stops at position stopAt
+func collect(batches *[][]int64, stopAt int64) func([]*store.StoredEvent) error {
+	return func(events []*store.StoredEvent) error {
+		var positions []int64
+		for _, event := range events {
+			positions = append(positions, event.Position)
+		}
+		*batches = append(*batches, positions)
+		if positions[len(positions)-1] >= stopAt {
+			return errStop
+		}
+		return nil
+	}
+}
+
+var errStop = errors.New("stop")
+
+func TestCatchUp_SSE(t *testing.T) {
+	fake := &catchUpServer{t: t, st: store.NewMemoryStore(), sse: true}
+	for range 3 {
+		fake.append("Old")
+	}
+	fake.st.SaveSubscriptionPosition(context.Background(), "projector", 1)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var batches [][]int64
+	opts := CatchUpOptions{BatchSize: 10, PollInterval: 10 * time.Millisecond}
+	err := New(server.URL, "test-key").CatchUp(context.Background(), "projector", collect(&batches, 5), opts)
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Expected the handler's error, got %v", err)
+	}
+
+	// History after the saved position, then one live event per connection
+	if got := fmt.Sprint(batches); got != "[[2 3] [4] [5]]" {
+		t.Errorf("Unexpected batches %s", got)
+	}
+	// The failed batch is not checkpointed
+	if got := fmt.Sprint(fake.savedPositions()); got != "[3 4]" {
+		t.Errorf("Unexpected checkpoints %s", got)
+	}
+}
+
+func TestCatchUp_Poll(t *testing.T) {
+	fake := &catchUpServer{t: t, st: store.NewMemoryStore()}
+	fake.append("Old")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// New events are found by polling once /events/subscribe turns out missing
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fake.append("New")
+	}()
+
+	var batches [][]int64
+	opts := CatchUpOptions{PollInterval: 10 * time.Millisecond}
+	err := New(server.URL, "test-key").CatchUp(context.Background(), "projector", collect(&batches, 2), opts)
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Expected the handler's error, got %v", err)
+	}
+	if got := fmt.Sprint(batches); got != "[[1] [2]]" {
+		t.Errorf("Unexpected batches %s", got)
+	}
+
+	// Client errors are not retried
+	err = New(server.URL, "wrong-key").CatchUp(context.Background(), "projector", collect(&batches, 2), opts)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := New(server.URL, "test-key").CatchUp(ctx, "projector", collect(&batches, 99), opts); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+}
+
+func TestCatchUp_Workers(t *testing.T) {
+	fake := &catchUpServer{t: t, st: store.NewMemoryStore()}
+	for i := range 40 {
+		data := fmt.Sprintf(`{"order":{"id":%d},"seq":%d}`, i%8, i/8)
+		fake.st.Save(context.Background(), &store.StoredEvent{Type: "OrderEvent", Data: json.RawMessage(data)})
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	seqs := make(map[string][]int)
+	running, parallel := 0, 0
+	handler := func(events []*store.StoredEvent) error {
+		mu.Lock()
+		running++
+		parallel = max(parallel, running)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		for _, event := range events {
+			var data struct {
+				Order struct{ ID int }
+				Seq   int
+			}
+			json.Unmarshal(event.Data, &data)
+			key := fmt.Sprint(data.Order.ID)
+			seqs[key] = append(seqs[key], data.Seq)
+		}
+		return nil
+	}
+
+	// Stop once every event is checkpointed
+	go func() {
+		for ctx.Err() == nil {
+			if position, _ := fake.st.LoadSubscriptionPosition(ctx, "projector"); position == 40 {
+				cancel()
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	opts := CatchUpOptions{BatchSize: 10, PollInterval: 10 * time.Millisecond, Poll: true, Workers: 4, PartitionKey: "order.id"}
+	if err := New(server.URL, "test-key").CatchUp(ctx, "projector", handler, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seqs) != 8 {
+		t.Fatalf("Expected 8 keys, got %v", seqs)
+	}
+	for key, got := range seqs {
+		if fmt.Sprint(got) != "[0 1 2 3 4]" {
+			t.Errorf("Key %s: expected its events in order, got %v", key, got)
+		}
+	}
+	if parallel < 2 {
+		t.Errorf("Expected keys to be handled in parallel")
+	}
+	// Checkpoints only move forward
+	saved := fake.savedPositions()
+	for i := 1; i < len(saved); i++ {
+		if saved[i] <= saved[i-1] {
+			t.Errorf("Checkpoints went backwards: %v", saved)
+			break
+		}
+	}
+}
+
+func TestCatchUp_WorkersStopAtFailure(t *testing.T) {
+	fake := &catchUpServer{t: t, st: store.NewMemoryStore()}
+	for i := range 6 {
+		fake.st.Save(context.Background(), &store.StoredEvent{Type: "OrderEvent", StreamID: fmt.Sprintf("order-%d", i%2), Data: json.RawMessage(`{}`)})
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var handled []int64
+	handler := func(events []*store.StoredEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, event := range events {
+			if event.Position == 3 {
+				return errStop
+			}
+			handled = append(handled, event.Position)
+		}
+		return nil
+	}
+
+	// Batches of one event: order-0 is 1, 3, 5 and order-1 is 2, 4, 6
+	opts := CatchUpOptions{BatchSize: 1, PollInterval: 10 * time.Millisecond, Poll: true, Workers: 2}
+	if err := New(server.URL, "test-key").CatchUp(context.Background(), "projector", handler, opts); !errors.Is(err, errStop) {
+		t.Fatalf("Expected the handler's error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, position := range handled {
+		if position == 5 {
+			t.Errorf("Event 5 was handled after event 3 of its stream failed: %v", handled)
+		}
+	}
+	if position, _ := fake.st.LoadSubscriptionPosition(context.Background(), "projector"); position >= 3 {
+		t.Errorf("Expected the checkpoint before the failed event, got %d", position)
+	}
+
+	if err := New(server.URL, "test-key").CatchUp(context.Background(), "projector", handler, CatchUpOptions{Workers: 2, PartitionKey: "a..b"}); err == nil {
+		t.Error("Expected an invalid partition key to fail")
+	}
+}
@@ -1,12 +1,16 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"time"
 
 	"github.com/jilio/ebuse/internal/store"
@@ -17,26 +21,134 @@ type HTTPClient struct {
 	baseURL string
 	apiKey  string
 	client  *http.Client
+	cache   *rangeCache
+	stats   *clientStats
+
+	// Per-call deadlines (0 disables the deadline)
+	quickTimeout time.Duration
+	writeTimeout time.Duration
+	loadTimeout  time.Duration
+
+	// Retry policy for transport errors and 429/5xx responses
+	maxRetries   int
+	retryBackoff time.Duration
+
+	// Read replicas for Load (nil: everything goes to baseURL)
+	replicas    *replicaSet
+	replicaKeys map[string]string // Replica URL -> its own API key
+
+	// Service account or identity provider tokens replacing apiKey (nil:
+	// API key auth)
+	tokens *tokenSource
+
+	// Sent with every request, including Consume's WebSocket handshake
+	userAgent string
+	tlsConfig *tls.Config
+
+	// Connection pool and TLS settings, applied to the transport by
+	// configureTransport
+	transportOpts []func(*http.Transport)
+
+	// Payload codecs applied to saved events, and those decoding read
+	// events by name (nil: events are sent and returned as they are)
+	codecs   []Codec
+	decoders map[string]Codec
+}
+
+// Default per-call deadlines. Quick calls (position, subscription checkpoints)
+// should fail fast, while large loads legitimately take minutes.
+const (
+	DefaultQuickTimeout = 10 * time.Second
+	DefaultWriteTimeout = 30 * time.Second
+	DefaultLoadTimeout  = 10 * time.Minute
+)
+
+// Transport defaults tuned for parallel replays against a single server.
+// http.DefaultTransport keeps only 2 idle connections per host, so concurrent
+// loads keep re-dialing.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// newTransport returns a keep-alive transport with pool defaults for ebuse
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = DefaultMaxIdleConns
+	t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	t.IdleConnTimeout = DefaultIdleConnTimeout
+	t.DisableKeepAlives = false
+	return t
 }
 
 // New creates a new HTTP event store client
-func New(baseURL, apiKey string) *HTTPClient {
-	return &HTTPClient{
+func New(baseURL, apiKey string, opts ...Option) *HTTPClient {
+	c := &HTTPClient{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: newTransport(),
 		},
+		quickTimeout: DefaultQuickTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		loadTimeout:  DefaultLoadTimeout,
+		retryBackoff: DefaultRetryBackoff,
+		stats:        &clientStats{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.configureTransport()
+	c.wrapTransport()
+
+	return c
+}
+
+// wrapTransport layers the User-Agent and service account token handling
+// over the configured transport once all options are applied, so they work
+// with WithHTTPClient in any order
+func (c *HTTPClient) wrapTransport() {
+	base := c.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if c.userAgent != "" {
+		base = &userAgentTransport{base: base, userAgent: c.userAgent}
+	}
+	if c.tokens != nil {
+		c.tokens.url = c.baseURL + "/token"
+		c.tokens.client = &http.Client{Transport: base}
+		base = &tokenTransport{base: base, tokens: c.tokens}
+	}
+	if base != http.DefaultTransport {
+		c.client.Transport = base
 	}
 }
 
+// withTimeout bounds ctx by the given per-call deadline, if any
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
 // Save implements EventStore.Save
 func (c *HTTPClient) Save(ctx context.Context, event *store.StoredEvent) error {
-	data, err := json.Marshal(event)
+	encoded, err := c.encodeEvents([]*store.StoredEvent{event})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(encoded[0])
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
 
+	ctx, cancel := withTimeout(ctx, c.writeTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/events", bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
@@ -44,8 +156,11 @@ func (c *HTTPClient) Save(ctx context.Context, event *store.StoredEvent) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set(IdempotencyKeyHeader, idempotencyKey(ctx))
+	setMetadataHeaders(req)
+	setTraceHeader(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
 	}
@@ -60,25 +175,188 @@ func (c *HTTPClient) Save(ctx context.Context, event *store.StoredEvent) error {
 	if err := json.NewDecoder(resp.Body).Decode(event); err != nil {
 		return fmt.Errorf("decode response: %w", err)
 	}
+	if err := c.decodeEvents([]*store.StoredEvent{event}); err != nil {
+		return err
+	}
+	c.observe(event.Position)
+
+	return nil
+}
+
+// SaveBatch implements EventStore.SaveBatch, saving up to 1000 events in one
+// request. Events are updated with their server-assigned positions.
+func (c *HTTPClient) SaveBatch(ctx context.Context, events []*store.StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return c.saveBatch(ctx, events, nil)
+}
+
+// ImportEvents implements store.Importer, saving up to 1000 events at the
+// positions they carry, which must increase past the server's head; gaps
+// between them are kept. It needs the tenant's main API key.
+func (c *HTTPClient) ImportEvents(ctx context.Context, events []*store.StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return c.saveBatch(ctx, events, neturl.Values{"positions": {"keep"}})
+}
+
+// saveBatch posts events to /events/batch with the given query parameters
+func (c *HTTPClient) saveBatch(ctx context.Context, events []*store.StoredEvent, query neturl.Values) error {
+	encoded, err := c.encodeEvents(events)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.writeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/events/batch?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set(IdempotencyKeyHeader, idempotencyKey(ctx))
+	setMetadataHeaders(req)
+	setTraceHeader(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusConflict {
+			return fmt.Errorf("%w: %s", ErrConflict, bytes.TrimSpace(body))
+		}
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		FirstPosition int64 `json:"first_position"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	// Batches are assigned contiguous positions; imports keep their own
+	if query.Get("positions") != "keep" {
+		for i, event := range events {
+			event.Position = result.FirstPosition + int64(i)
+		}
+	}
+	c.observe(events[len(events)-1].Position)
 
 	return nil
 }
 
 // Load implements EventStore.Load
 func (c *HTTPClient) Load(ctx context.Context, from, to int64) ([]*store.StoredEvent, error) {
-	url := fmt.Sprintf("%s/events?from=%d", c.baseURL, from)
+	durable := durableReads(ctx)
+
+	// Cached ranges may have been read before they were durable
+	if c.cache != nil && to != -1 && !durable {
+		if events, ok := c.cache.get(from, to); ok {
+			c.stats.cacheHits.Add(1)
+			return events, nil
+		}
+		c.stats.cacheMisses.Add(1)
+	}
+
+	events, err := c.loadReplica(ctx, from, to)
+	if err != nil {
+		if events, err = c.load(ctx, c.baseURL, from, to, durable, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only ranges that already reach their upper bound are immutable
+	if c.cache != nil && to != -1 && len(events) > 0 && events[len(events)-1].Position == to {
+		c.cache.put(from, to, events)
+	}
+
+	return events, nil
+}
+
+// LoadByTypes is Load restricted to events of the given types, filtered by
+// the server. Filtered reads always go to the primary and bypass the range
+// cache.
+func (c *HTTPClient) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*store.StoredEvent, error) {
+	if len(types) == 0 {
+		return []*store.StoredEvent{}, nil
+	}
+	return c.load(ctx, c.baseURL, from, to, durableReads(ctx), neturl.Values{"type": types})
+}
+
+// Filter selects events by type patterns and metadata, see store.Filter
+type Filter = store.Filter
+
+// LoadFiltered is Load restricted to the events matching filter, matched by
+// the server. Like LoadByTypes it reads from the primary and bypasses the
+// range cache.
+func (c *HTTPClient) LoadFiltered(ctx context.Context, filter Filter, from, to int64) ([]*store.StoredEvent, error) {
+	return c.load(ctx, c.baseURL, from, to, durableReads(ctx), filterQuery(filter))
+}
+
+// filterQuery encodes filter as the query parameters of /events
+func filterQuery(filter Filter) neturl.Values {
+	query := neturl.Values{}
+	if len(filter.Types) > 0 {
+		query["type"] = filter.Types
+	}
+	for key, values := range filter.Metadata {
+		query["meta."+key] = values
+	}
+	return query
+}
+
+// LoadByTime implements EventStore.LoadByTime, filtered by the server. Like
+// LoadByTypes it reads from the primary and bypasses the range cache.
+func (c *HTTPClient) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*store.StoredEvent, error) {
+	filter := neturl.Values{}
+	if !since.IsZero() {
+		filter.Set("since", since.Format(time.RFC3339Nano))
+	}
+	if !until.IsZero() {
+		filter.Set("until", until.Format(time.RFC3339Nano))
+	}
+	return c.load(ctx, c.baseURL, from, to, durableReads(ctx), filter)
+}
+
+// load fetches [from, to] from the server at baseURL, narrowed by the query
+// parameters of filter if any are given
+func (c *HTTPClient) load(ctx context.Context, baseURL string, from, to int64, durable bool, filter neturl.Values) ([]*store.StoredEvent, error) {
+	url := fmt.Sprintf("%s/events?from=%d", baseURL, from)
 	if to != -1 {
 		url += fmt.Sprintf("&to=%d", to)
 	}
+	if len(filter) > 0 {
+		url += "&" + filter.Encode()
+	}
+
+	ctx, cancel := withTimeout(ctx, c.loadTimeout)
+	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("X-API-Key", c.keyFor(baseURL))
+	if durable {
+		req.Header.Set(ConsistencyHeader, ConsistencyDurable)
+	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -93,12 +371,99 @@ func (c *HTTPClient) Load(ctx context.Context, from, to int64) ([]*store.StoredE
 	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	if err := c.decodeEvents(events); err != nil {
+		return nil, err
+	}
 
 	return events, nil
 }
 
+// LoadStream implements EventStore.LoadStream over /events/stream. The
+// response is read as NDJSON, so handler gets batches of up to batchSize
+// events as they arrive instead of after the whole body is parsed. Like
+// Replicate, the stream has no deadline besides ctx and is not retried.
+func (c *HTTPClient) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*store.StoredEvent) error) error {
+	return c.loadStream(ctx, from, batchSize, Filter{}, handler)
+}
+
+// LoadStreamFiltered is LoadStream restricted to the events matching filter
+func (c *HTTPClient) LoadStreamFiltered(ctx context.Context, filter Filter, from int64, batchSize int, handler func([]*store.StoredEvent) error) error {
+	return c.loadStream(ctx, from, batchSize, filter, handler)
+}
+
+func (c *HTTPClient) loadStream(ctx context.Context, from int64, batchSize int, filter Filter, handler func([]*store.StoredEvent) error) error {
+	if batchSize <= 0 {
+		batchSize = store.DefaultStreamBatchSize
+	}
+	query := filterQuery(filter)
+	query.Set("from", strconv.FormatInt(from, 10))
+	query.Set("batch_size", strconv.Itoa(batchSize))
+	query.Set("checksum", "true") // For the completeness trailer
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/events/stream?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Accept", "application/x-ndjson")
+	if durableReads(ctx) {
+		req.Header.Set(ConsistencyHeader, ConsistencyDurable)
+	}
+	if replayJob(ctx) {
+		req.Header.Set(JobHeader, JobReplay)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	batch := make([]*store.StoredEvent, 0, batchSize)
+	for {
+		var event store.StoredEvent
+		if err := dec.Decode(&event); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		if err := c.decodeEvents([]*store.StoredEvent{&event}); err != nil {
+			return err
+		}
+		batch = append(batch, &event)
+
+		if len(batch) == batchSize {
+			if err := handler(batch); err != nil {
+				return fmt.Errorf("handle batch: %w", err)
+			}
+			batch = make([]*store.StoredEvent, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		if err := handler(batch); err != nil {
+			return fmt.Errorf("handle batch: %w", err)
+		}
+	}
+
+	// The server cannot change the status once streaming, so a failure
+	// after the first event only shows in the trailer
+	if resp.Trailer.Get("X-Ebuse-Complete") == "false" {
+		return fmt.Errorf("stream ended early on the server")
+	}
+	return nil
+}
+
 // GetPosition implements EventStore.GetPosition
 func (c *HTTPClient) GetPosition(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, c.quickTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/position", nil)
 	if err != nil {
 		return 0, fmt.Errorf("create request: %w", err)
@@ -106,7 +471,7 @@ func (c *HTTPClient) GetPosition(ctx context.Context) (int64, error) {
 
 	req.Header.Set("X-API-Key", c.apiKey)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0, fmt.Errorf("send request: %w", err)
 	}
@@ -123,10 +488,45 @@ func (c *HTTPClient) GetPosition(ctx context.Context) (int64, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return 0, fmt.Errorf("decode response: %w", err)
 	}
+	c.observe(result.Position)
 
 	return result.Position, nil
 }
 
+// PositionAt returns the server's head as of t, the position before the
+// first event stamped after t. Loading up to it reproduces the log as
+// projections saw it then, however many events were appended since.
+func (c *HTTPClient) PositionAt(ctx context.Context, t time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, c.quickTimeout)
+	defer cancel()
+
+	// An empty range resolves as_of without loading events
+	url := c.baseURL + "/events?from=1&to=0&as_of=" + neturl.QueryEscape(t.Format(time.RFC3339Nano))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	position, err := strconv.ParseInt(resp.Header.Get("X-Ebuse-As-Of"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("server did not resolve as_of: %w", err)
+	}
+	return position, nil
+}
+
 // SaveSubscriptionPosition implements EventStore.SaveSubscriptionPosition
 func (c *HTTPClient) SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error {
 	data, err := json.Marshal(map[string]int64{"position": position})
@@ -134,6 +534,9 @@ func (c *HTTPClient) SaveSubscriptionPosition(ctx context.Context, subscriptionI
 		return fmt.Errorf("marshal request: %w", err)
 	}
 
+	ctx, cancel := withTimeout(ctx, c.quickTimeout)
+	defer cancel()
+
 	url := fmt.Sprintf("%s/subscriptions/%s/position", c.baseURL, subscriptionID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
@@ -143,7 +546,7 @@ func (c *HTTPClient) SaveSubscriptionPosition(ctx context.Context, subscriptionI
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-API-Key", c.apiKey)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
 	}
@@ -151,7 +554,7 @@ func (c *HTTPClient) SaveSubscriptionPosition(ctx context.Context, subscriptionI
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	return nil
@@ -159,6 +562,9 @@ func (c *HTTPClient) SaveSubscriptionPosition(ctx context.Context, subscriptionI
 
 // LoadSubscriptionPosition implements EventStore.LoadSubscriptionPosition
 func (c *HTTPClient) LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, c.quickTimeout)
+	defer cancel()
+
 	url := fmt.Sprintf("%s/subscriptions/%s/position", c.baseURL, subscriptionID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -167,7 +573,7 @@ func (c *HTTPClient) LoadSubscriptionPosition(ctx context.Context, subscriptionI
 
 	req.Header.Set("X-API-Key", c.apiKey)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0, fmt.Errorf("send request: %w", err)
 	}
@@ -175,7 +581,7 @@ func (c *HTTPClient) LoadSubscriptionPosition(ctx context.Context, subscriptionI
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+		return 0, &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	var result struct {
@@ -187,3 +593,70 @@ func (c *HTTPClient) LoadSubscriptionPosition(ctx context.Context, subscriptionI
 
 	return result.Position, nil
 }
+
+// Subscriptions implements store.SubscriptionLister, returning the position
+// of every subscription on the server
+func (c *HTTPClient) Subscriptions(ctx context.Context) (map[string]int64, error) {
+	ctx, cancel := withTimeout(ctx, c.loadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/subscriptions", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	var result struct {
+		Subscriptions map[string]int64 `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return result.Subscriptions, nil
+}
+
+// ImportSubscriptions saves the positions of many subscriptions at once, as
+// exported by Subscriptions. Subscriptions not named keep their positions.
+func (c *HTTPClient) ImportSubscriptions(ctx context.Context, positions map[string]int64) error {
+	data, err := json.Marshal(map[string]any{"subscriptions": positions})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.writeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/subscriptions", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	return nil
+}
@@ -2,9 +2,13 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,8 +23,156 @@ func TestNew(t *testing.T) {
 	if c.apiKey != "test-key" {
 		t.Errorf("expected apiKey test-key, got %s", c.apiKey)
 	}
-	if c.client.Timeout != 30*time.Second {
-		t.Errorf("expected timeout 30s, got %v", c.client.Timeout)
+	if c.client.Timeout != 0 {
+		t.Errorf("expected no global client timeout, got %v", c.client.Timeout)
+	}
+	if c.quickTimeout != DefaultQuickTimeout || c.writeTimeout != DefaultWriteTimeout || c.loadTimeout != DefaultLoadTimeout {
+		t.Errorf("unexpected default timeouts: quick=%v write=%v load=%v", c.quickTimeout, c.writeTimeout, c.loadTimeout)
+	}
+
+	transport := c.transport()
+	if transport == nil {
+		t.Fatal("expected *http.Transport")
+	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("expected MaxIdleConnsPerHost %d, got %d", DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport.DisableKeepAlives {
+		t.Error("expected keep-alives to be enabled")
+	}
+}
+
+func TestNew_TransportOptions(t *testing.T) {
+	c := New("http://localhost:8080", "test-key",
+		WithMaxIdleConnsPerHost(200),
+		WithMaxConnsPerHost(64),
+		WithIdleConnTimeout(time.Minute),
+	)
+
+	transport := c.transport()
+	if transport.MaxIdleConnsPerHost != 200 {
+		t.Errorf("expected MaxIdleConnsPerHost 200, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxIdleConns < 200 {
+		t.Errorf("expected MaxIdleConns to grow to at least 200, got %d", transport.MaxIdleConns)
+	}
+	if transport.MaxConnsPerHost != 64 {
+		t.Errorf("expected MaxConnsPerHost 64, got %d", transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != time.Minute {
+		t.Errorf("expected IdleConnTimeout 1m, got %v", transport.IdleConnTimeout)
+	}
+
+	// Clients must not share the global default transport
+	other := New("http://localhost:8080", "test-key")
+	if other.transport() == transport || transport == http.DefaultTransport {
+		t.Error("expected each client to own its transport")
+	}
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNew_ClientOptions(t *testing.T) {
+	var userAgent string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		json.NewEncoder(w).Encode(map[string]int64{"position": 1})
+	}))
+	defer server.Close()
+
+	// A custom client with its own transport, in any order with the options
+	custom := &countingTransport{}
+	hc := &http.Client{Transport: custom}
+	c := New(server.URL, "test-key", WithUserAgent("billing/1.2"), WithHTTPClient(hc), WithTimeout(time.Hour))
+	if _, err := c.GetPosition(context.Background()); err == nil {
+		t.Error("expected the test server's certificate to be rejected")
+	}
+	if custom.requests != 1 || hc.Transport != custom {
+		t.Errorf("expected 1 request through the custom transport, left in place, got %d", custom.requests)
+	}
+	if c.quickTimeout != time.Hour || c.writeTimeout != time.Hour || c.loadTimeout != time.Hour {
+		t.Errorf("unexpected timeouts: quick=%v write=%v load=%v", c.quickTimeout, c.writeTimeout, c.loadTimeout)
+	}
+
+	// Trusting the server's certificate
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	c = New(server.URL, "test-key", WithTLSConfig(&tls.Config{RootCAs: roots}), WithUserAgent("billing/1.2"))
+	if _, err := c.GetPosition(context.Background()); err != nil {
+		t.Fatalf("GetPosition failed: %v", err)
+	}
+	if userAgent != "billing/1.2" {
+		t.Errorf("expected User-Agent billing/1.2, got %q", userAgent)
+	}
+	if c.transport() == nil || c.transport().TLSClientConfig.RootCAs != roots {
+		t.Error("expected the TLS config on the client's transport")
+	}
+}
+
+func TestWithHTTPClient_Transport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int64{"position": 1})
+	}))
+	defer server.Close()
+
+	// The caller's transport is copied, not changed, whatever the order
+	shared := &http.Transport{MaxIdleConnsPerHost: 2}
+	hc := &http.Client{Transport: shared}
+	config := &tls.Config{ServerName: "events.internal"}
+	c := New(server.URL, "test-key", WithMaxIdleConnsPerHost(50), WithHTTPClient(hc), WithTLSConfig(config))
+	if shared.MaxIdleConnsPerHost != 2 || shared.TLSClientConfig == config || hc.Transport != shared {
+		t.Errorf("expected the caller's transport unchanged, got %+v", shared)
+	}
+	if transport := c.transport(); transport == shared || transport.MaxIdleConnsPerHost != 50 || transport.TLSClientConfig != config {
+		t.Errorf("expected the options on a copy of the transport, got %+v", transport)
+	}
+
+	// A nil transport gets the pool defaults
+	c = New(server.URL, "test-key", WithHTTPClient(&http.Client{}))
+	if transport := c.transport(); transport == nil || transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("expected the pool defaults, got %+v", transport)
+	}
+	if _, err := c.GetPosition(context.Background()); err != nil {
+		t.Errorf("GetPosition failed: %v", err)
+	}
+
+	// Other round trippers can't take transport options
+	c = New(server.URL, "test-key", WithHTTPClient(&http.Client{Transport: &countingTransport{}}), WithMaxConnsPerHost(8))
+	if _, err := c.GetPosition(context.Background()); err == nil || !strings.Contains(err.Error(), "need an *http.Transport") {
+		t.Errorf("expected transport options on a custom round tripper to fail, got %v", err)
+	}
+}
+
+func TestPerCallTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		if r.URL.Path == "/position" {
+			json.NewEncoder(w).Encode(map[string]int64{"position": 1})
+			return
+		}
+		json.NewEncoder(w).Encode([]*store.StoredEvent{})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key",
+		WithQuickTimeout(20*time.Millisecond),
+		WithLoadTimeout(time.Second),
+	)
+	ctx := context.Background()
+
+	if _, err := client.GetPosition(ctx); err == nil {
+		t.Error("expected quick call to exceed its deadline")
+	}
+	if _, err := client.Load(ctx, 0, -1); err != nil {
+		t.Errorf("expected load to finish within its deadline: %v", err)
 	}
 }
 
@@ -80,6 +232,42 @@ func TestSave_ServerError(t *testing.T) {
 	}
 }
 
+func TestSaveBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/batch" {
+			t.Errorf("expected /events/batch, got %s", r.URL.Path)
+		}
+
+		var events []*store.StoredEvent
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"saved":          len(events),
+			"first_position": 10,
+			"last_position":  10 + len(events) - 1,
+		})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	events := []*store.StoredEvent{
+		{Type: "A", Data: []byte(`{}`)},
+		{Type: "B", Data: []byte(`{}`)},
+	}
+	if err := client.SaveBatch(context.Background(), events); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+	if events[0].Position != 10 || events[1].Position != 11 {
+		t.Errorf("expected positions 10 and 11, got %d and %d", events[0].Position, events[1].Position)
+	}
+
+	if err := client.SaveBatch(context.Background(), nil); err != nil {
+		t.Errorf("empty batch should be a no-op, got %v", err)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -273,6 +461,46 @@ func TestLoadSubscriptionPosition(t *testing.T) {
 	}
 }
 
+func TestSubscriptions(t *testing.T) {
+	var imported map[string]int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions" {
+			t.Errorf("expected /subscriptions, got %s", r.URL.Path)
+		}
+		var body struct {
+			Subscriptions map[string]int64 `json:"subscriptions"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			body.Subscriptions = map[string]int64{"billing": 42}
+			json.NewEncoder(w).Encode(body)
+		case http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&body)
+			imported = body.Subscriptions
+			json.NewEncoder(w).Encode(map[string]int{"imported": len(imported)})
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	ctx := context.Background()
+
+	positions, err := client.Subscriptions(ctx)
+	if err != nil {
+		t.Fatalf("Subscriptions failed: %v", err)
+	}
+	if len(positions) != 1 || positions["billing"] != 42 {
+		t.Errorf("expected billing at 42, got %v", positions)
+	}
+
+	if err := client.ImportSubscriptions(ctx, map[string]int64{"search": 7}); err != nil {
+		t.Fatalf("ImportSubscriptions failed: %v", err)
+	}
+	if len(imported) != 1 || imported["search"] != 7 {
+		t.Errorf("expected search at 7 imported, got %v", imported)
+	}
+}
+
 func TestLoadSubscriptionPosition_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -287,3 +515,175 @@ func TestLoadSubscriptionPosition_ServerError(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/position" {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode([]*store.StoredEvent{{Position: 1}, {Position: 2}})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRangeCache(10), WithRetries(1, time.Millisecond))
+	ctx := context.Background()
+
+	client.Load(ctx, 1, 2)
+	client.Load(ctx, 1, 2)
+	client.GetPosition(ctx)
+
+	stats := client.Stats()
+	if stats.Requests != 3 {
+		t.Errorf("expected 3 requests, got %d", stats.Requests)
+	}
+	if stats.Retries != 1 {
+		t.Errorf("expected 1 retry, got %d", stats.Retries)
+	}
+	if stats.Errors != 2 {
+		t.Errorf("expected 2 errors, got %d", stats.Errors)
+	}
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Errorf("expected 1 cache hit and 1 miss, got %d/%d", stats.CacheHits, stats.CacheMisses)
+	}
+}
+
+func TestLoadByTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if types := r.URL.Query()["type"]; r.URL.Path != "/events" || len(types) != 2 || types[0] != "Paid" || types[1] != "a,b" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[{"position":3,"type":"Paid","data":{}}]`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	events, err := client.LoadByTypes(context.Background(), []string{"Paid", "a,b"}, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTypes failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Position != 3 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestLoadFiltered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if types := query["type"]; len(types) != 1 || types[0] != "Order.*" || len(query["meta.region"]) != 2 || query.Get("from") != "2" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/events/stream" {
+			w.Write([]byte(`{"position":4,"type":"Order.Paid","data":{}}` + "\n"))
+			return
+		}
+		w.Write([]byte(`[{"position":3,"type":"Order.Placed","data":{}}]`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	filter := Filter{Types: []string{"Order.*"}, Metadata: map[string][]string{"region": {"eu", "uk"}}}
+	events, err := client.LoadFiltered(context.Background(), filter, 2, -1)
+	if err != nil {
+		t.Fatalf("LoadFiltered failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Position != 3 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	var streamed []*store.StoredEvent
+	err = client.LoadStreamFiltered(context.Background(), filter, 2, 10, func(batch []*store.StoredEvent) error {
+		streamed = append(streamed, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStreamFiltered failed: %v", err)
+	}
+	if len(streamed) != 1 || streamed[0].Position != 4 {
+		t.Errorf("unexpected streamed events: %+v", streamed)
+	}
+}
+
+func TestLoadByTime(t *testing.T) {
+	since := time.Date(2026, 1, 2, 14, 0, 0, 0, time.FixedZone("CET", 3600))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("since") != "2026-01-02T14:00:00+01:00" || query.Has("until") || query.Get("from") != "1" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[{"position":2,"type":"Paid","data":{}}]`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	events, err := client.LoadByTime(context.Background(), since, time.Time{}, 1, -1)
+	if err != nil {
+		t.Fatalf("LoadByTime failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Position != 2 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestPositionAt(t *testing.T) {
+	at := time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("as_of") != "2026-01-02T14:00:00Z" || r.URL.Query().Get("to") != "0" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Ebuse-As-Of", "42")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	position, err := client.PositionAt(context.Background(), at)
+	if err != nil {
+		t.Fatalf("PositionAt failed: %v", err)
+	}
+	if position != 42 {
+		t.Errorf("expected position 42, got %d", position)
+	}
+}
+
+func TestLoadStream(t *testing.T) {
+	complete := "true"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/stream" || r.Header.Get("Accept") != "application/x-ndjson" || r.URL.Query().Get("from") != "2" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Trailer", "X-Ebuse-Complete")
+		for position := 2; position <= 6; position++ {
+			fmt.Fprintf(w, `{"position":%d,"type":"Tick","data":{}}`+"\n", position)
+		}
+		w.Header().Set("X-Ebuse-Complete", complete)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	var batches []int
+	var last int64
+	err := client.LoadStream(context.Background(), 2, 2, func(batch []*store.StoredEvent) error {
+		batches = append(batches, len(batch))
+		last = batch[len(batch)-1].Position
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream failed: %v", err)
+	}
+	if fmt.Sprint(batches) != "[2 2 1]" || last != 6 {
+		t.Errorf("expected batches [2 2 1] ending at 6, got %v ending at %d", batches, last)
+	}
+
+	// A stream the server could not finish is an error, after the events it sent
+	complete = "false"
+	err = client.LoadStream(context.Background(), 2, 10, func([]*store.StoredEvent) error { return nil })
+	if err == nil {
+		t.Error("expected an error for an incomplete stream")
+	}
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// MetadataCodec lists the codecs an event's data went through, in the order
+// they were applied, e.g. "gzip,aes-gcm/2026-10". The data of such events is
+// a JSON string holding the base64 of the encoded payload.
+const MetadataCodec = "codec"
+
+// Codec transforms event data on the client, so compression and encryption
+// happen end to end and the server only ever stores the result. Name is
+// recorded in MetadataCodec and must identify the codec and its settings,
+// e.g. the encryption key, since events are decoded by it.
+type Codec interface {
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// WithCodecs encodes the data of saved events with codecs, in order, and
+// decodes events read back by Load, LoadStream, Subscribe, Consume and
+// LoadByStream. Events saved without codecs are returned as stored, so
+// codecs can be introduced on an existing log. Replicate passes events on
+// encoded.
+//
+// The server cannot see encoded data, so write pipeline rules on data,
+// such as strip and PII, do not apply to it. Type and metadata filters and
+// CatchUp partition keys, read after decoding, work as before.
+func WithCodecs(codecs ...Codec) Option {
+	return func(c *HTTPClient) {
+		c.codecs = codecs
+		for _, codec := range codecs {
+			c.addDecoder(codec)
+		}
+	}
+}
+
+// WithDecoders adds codecs that only decode, e.g. the previous key after a
+// rotation, so events written with it stay readable
+func WithDecoders(codecs ...Codec) Option {
+	return func(c *HTTPClient) {
+		for _, codec := range codecs {
+			c.addDecoder(codec)
+		}
+	}
+}
+
+func (c *HTTPClient) addDecoder(codec Codec) {
+	if c.decoders == nil {
+		c.decoders = make(map[string]Codec)
+	}
+	c.decoders[codec.Name()] = codec
+}
+
+// encodeEvents returns copies of events with their data encoded, or events
+// themselves without codecs
+func (c *HTTPClient) encodeEvents(events []*store.StoredEvent) ([]*store.StoredEvent, error) {
+	if len(c.codecs) == 0 {
+		return events, nil
+	}
+	names := make([]string, len(c.codecs))
+	for i, codec := range c.codecs {
+		names[i] = codec.Name()
+	}
+
+	encoded := make([]*store.StoredEvent, len(events))
+	for i, event := range events {
+		data := []byte(event.Data)
+		for _, codec := range c.codecs {
+			var err error
+			if data, err = codec.Encode(data); err != nil {
+				return nil, fmt.Errorf("encode event data with %s: %w", codec.Name(), err)
+			}
+		}
+		wrapped, err := json.Marshal(base64.StdEncoding.EncodeToString(data))
+		if err != nil {
+			return nil, err
+		}
+
+		copied := *event
+		copied.Data = wrapped
+		copied.Metadata = maps.Clone(event.Metadata)
+		if copied.Metadata == nil {
+			copied.Metadata = make(map[string]string, 1)
+		}
+		copied.Metadata[MetadataCodec] = strings.Join(names, ",")
+		encoded[i] = &copied
+	}
+	return encoded, nil
+}
+
+// decodeEvents decodes the data of events in place. Events without
+// MetadataCodec are left alone.
+func (c *HTTPClient) decodeEvents(events []*store.StoredEvent) error {
+	for _, event := range events {
+		chain, ok := event.Metadata[MetadataCodec]
+		if !ok {
+			continue
+		}
+
+		var text string
+		if err := json.Unmarshal(event.Data, &text); err != nil {
+			return fmt.Errorf("decode event %d: data is not encoded: %w", event.Position, err)
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return fmt.Errorf("decode event %d: %w", event.Position, err)
+		}
+		names := strings.Split(chain, ",")
+		for i := len(names) - 1; i >= 0; i-- {
+			codec, ok := c.decoders[names[i]]
+			if !ok {
+				return fmt.Errorf("decode event %d: no codec %q", event.Position, names[i])
+			}
+			if data, err = codec.Decode(data); err != nil {
+				return fmt.Errorf("decode event %d with %s: %w", event.Position, names[i], err)
+			}
+		}
+
+		event.Data = data
+		delete(event.Metadata, MetadataCodec)
+		if len(event.Metadata) == 0 {
+			event.Metadata = nil
+		}
+	}
+	return nil
+}
+
+// GzipCodec compresses event data
+func GzipCodec() Codec {
+	return gzipCodec{}
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// AESGCMCodec encrypts event data with AES-GCM under a 16, 24 or 32 byte
+// key. keyID names the key in MetadataCodec, so after a rotation events
+// written with the old key are decoded by a codec for it given to
+// WithDecoders.
+func AESGCMCodec(keyID string, key []byte) (Codec, error) {
+	if keyID == "" || strings.ContainsAny(keyID, ",") {
+		return nil, fmt.Errorf("invalid key ID %q", keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMCodec{name: "aes-gcm/" + keyID, aead: aead}, nil
+}
+
+type aesGCMCodec struct {
+	name string
+	aead cipher.AEAD
+}
+
+func (a *aesGCMCodec) Name() string { return a.name }
+
+// Encode returns the nonce followed by the sealed data
+func (a *aesGCMCodec) Encode(data []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(data)+a.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (a *aesGCMCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < a.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := data[:a.aead.NonceSize()], data[a.aead.NonceSize():]
+	return a.aead.Open(nil, nonce, sealed, nil)
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// codecServer stores what clients send in a memory store, like a server
+// that never looks at event data
+func codecServer(t *testing.T, st *store.MemoryStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		switch {
+		case r.URL.Path == "/events" && r.Method == http.MethodPost:
+			var event store.StoredEvent
+			json.NewDecoder(r.Body).Decode(&event)
+			st.Save(ctx, &event)
+			json.NewEncoder(w).Encode(event)
+		case r.URL.Path == "/events/batch":
+			var events []*store.StoredEvent
+			json.NewDecoder(r.Body).Decode(&events)
+			st.SaveBatch(ctx, events)
+			json.NewEncoder(w).Encode(map[string]int64{"first_position": events[0].Position})
+		case r.URL.Path == "/events":
+			events, _ := st.Load(ctx, from, -1)
+			json.NewEncoder(w).Encode(events)
+		case r.URL.Path == "/events/stream":
+			events, _ := st.Load(ctx, from, -1)
+			enc := json.NewEncoder(w)
+			for _, event := range events {
+				enc.Encode(event)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCodecs(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	server := codecServer(t, st)
+	defer server.Close()
+
+	oldKey, err := AESGCMCodec("2026-01", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("AESGCMCodec failed: %v", err)
+	}
+	newKey, _ := AESGCMCodec("2026-10", bytes.Repeat([]byte{2}, 32))
+
+	// Events from before codecs, and from before the key rotation
+	New(server.URL, "test-key").Save(ctx, &store.StoredEvent{Type: "Plain", Data: json.RawMessage(`{"n":1}`)})
+	New(server.URL, "test-key", WithCodecs(oldKey)).Save(ctx, &store.StoredEvent{Type: "Old", Data: json.RawMessage(`{"n":2}`)})
+
+	client := New(server.URL, "test-key", WithCodecs(GzipCodec(), newKey), WithDecoders(oldKey))
+	event := &store.StoredEvent{Type: "Secret", Data: json.RawMessage(`{"card":"4111"}`), Metadata: map[string]string{"caller": "billing"}}
+	if err := client.Save(ctx, event); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if event.Position != 3 || string(event.Data) != `{"card":"4111"}` || len(event.Metadata) != 1 {
+		t.Errorf("Save changed the caller's event: %+v", event)
+	}
+	batch := []*store.StoredEvent{{Type: "Secret", Data: json.RawMessage(`{"card":"5500"}`)}}
+	if err := client.SaveBatch(ctx, batch); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	// The server only holds ciphertext
+	stored, _ := st.Load(ctx, 3, 4)
+	for _, event := range stored {
+		if strings.Contains(string(event.Data), "card") || event.Metadata[MetadataCodec] != "gzip,aes-gcm/2026-10" {
+			t.Errorf("Stored event %d: data %s, metadata %v", event.Position, event.Data, event.Metadata)
+		}
+	}
+
+	want := []string{`{"n":1}`, `{"n":2}`, `{"card":"4111"}`, `{"card":"5500"}`}
+	events, err := client.Load(ctx, 1, -1)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for i, event := range events {
+		if string(event.Data) != want[i] {
+			t.Errorf("Load event %d: data %s, want %s", event.Position, event.Data, want[i])
+		}
+		if _, ok := event.Metadata[MetadataCodec]; ok {
+			t.Errorf("Load event %d kept %s metadata", event.Position, MetadataCodec)
+		}
+	}
+	if len(events) != 4 || events[2].Metadata["caller"] != "billing" {
+		t.Errorf("Expected 4 events with their metadata, got %d", len(events))
+	}
+
+	var streamed []string
+	err = client.LoadStream(ctx, 1, 2, func(events []*store.StoredEvent) error {
+		for _, event := range events {
+			streamed = append(streamed, string(event.Data))
+		}
+		return nil
+	})
+	if err != nil || strings.Join(streamed, " ") != strings.Join(want, " ") {
+		t.Errorf("LoadStream = %v, %v", streamed, err)
+	}
+
+	// Without the key, encoded events cannot be read
+	if _, err := New(server.URL, "test-key").Load(ctx, 3, 3); err == nil || !strings.Contains(err.Error(), "no codec") {
+		t.Errorf("Expected a missing codec error, got %v", err)
+	}
+	wrongKey, _ := AESGCMCodec("2026-10", bytes.Repeat([]byte{3}, 32))
+	if _, err := New(server.URL, "test-key", WithCodecs(GzipCodec(), wrongKey)).Load(ctx, 3, 3); err == nil {
+		t.Error("Expected a wrong key to fail")
+	}
+}
+
+func TestAESGCMCodec_Invalid(t *testing.T) {
+	if _, err := AESGCMCodec("k1", []byte("short")); err == nil {
+		t.Error("Expected an invalid key length to fail")
+	}
+	if _, err := AESGCMCodec("a,b", bytes.Repeat([]byte{1}, 16)); err == nil {
+		t.Error("Expected a key ID with a comma to fail")
+	}
+}
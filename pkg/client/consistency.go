@@ -0,0 +1,23 @@
+package client
+
+import "context"
+
+// ConsistencyHeader and ConsistencyDurable request durable reads: the server
+// flushes writes to stable storage before answering a Load
+const (
+	ConsistencyHeader  = "X-Ebuse-Consistency"
+	ConsistencyDurable = "durable"
+)
+
+type durableCtxKey struct{}
+
+// WithDurableReads returns a context whose Load calls only return events
+// that would survive a server crash. Such loads bypass the range cache.
+func WithDurableReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, durableCtxKey{}, true)
+}
+
+func durableReads(ctx context.Context) bool {
+	durable, _ := ctx.Value(durableCtxKey{}).(bool)
+	return durable
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestWithDurableReads(t *testing.T) {
+	var requests, durable atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get(ConsistencyHeader) == ConsistencyDurable {
+			durable.Add(1)
+		}
+		json.NewEncoder(w).Encode([]*store.StoredEvent{{Position: 1, Type: "Event"}})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRangeCache(100))
+	ctx := context.Background()
+
+	// Populate the cache with a closed range
+	if _, err := client.Load(ctx, 1, 1); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if durable.Load() != 0 {
+		t.Error("plain loads must not request durability")
+	}
+
+	// Durable loads always reach the server
+	if _, err := client.Load(WithDurableReads(ctx), 1, 1); err != nil {
+		t.Fatalf("durable Load failed: %v", err)
+	}
+	if requests.Load() != 2 || durable.Load() != 1 {
+		t.Errorf("expected durable load to bypass cache, got %d requests (%d durable)", requests.Load(), durable.Load())
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/websocket"
+)
+
+// wsMessage is one message of the server's /ws protocol
+type wsMessage struct {
+	Type         string               `json:"type"`
+	Subscription string               `json:"subscription,omitempty"`
+	Position     int64                `json:"position,omitempty"`
+	Events       []*store.StoredEvent `json:"events,omitempty"`
+	Message      string               `json:"message,omitempty"`
+}
+
+// Consume delivers the events of a server-side subscription over /ws,
+// starting after its saved position. Every batch handler returns nil for is
+// acknowledged, and the server saves its last position as the
+// subscription's, so a later Consume with the same subscriptionID resumes
+// after it. Batches are redelivered if the handler fails or the connection
+// breaks before the ack.
+//
+// It returns when ctx is done, handler fails or the connection breaks.
+// Like Replicate, the connection has no deadline and is not retried.
+func (c *HTTPClient) Consume(ctx context.Context, subscriptionID string, handler func([]*store.StoredEvent) error) error {
+	header := http.Header{"X-Api-Key": {c.apiKey}}
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		header = http.Header{"Authorization": {"Bearer " + token}}
+	}
+	if c.userAgent != "" {
+		header.Set("User-Agent", c.userAgent)
+	}
+	conn, resp, err := websocket.DialTLS(ctx, c.baseURL+"/ws", header, c.tlsConfig)
+	if errors.Is(err, websocket.ErrBadHandshake) {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+
+	// Closing the connection ends the read below once ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close(websocket.CloseNormal, "") })
+	defer stop()
+
+	if err := conn.WriteJSON(wsMessage{Type: "subscribe", Subscription: subscriptionID}); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read message: %w", err)
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("decode message: %w", err)
+		}
+
+		switch msg.Type {
+		case "error":
+			return fmt.Errorf("subscription %s: %s", subscriptionID, msg.Message)
+		case "events":
+			if len(msg.Events) == 0 {
+				continue
+			}
+			if err := c.decodeEvents(msg.Events); err != nil {
+				return err
+			}
+			if err := handler(msg.Events); err != nil {
+				return err
+			}
+			last := msg.Events[len(msg.Events)-1].Position
+			if err := conn.WriteJSON(wsMessage{Type: "ack", Subscription: subscriptionID, Position: last}); err != nil {
+				return fmt.Errorf("ack: %w", err)
+			}
+		}
+	}
+}
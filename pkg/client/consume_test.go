@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/websocket"
+)
+
+func TestConsume(t *testing.T) {
+	acks := make(chan int64, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" || r.Header.Get("X-API-Key") != "test-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.CloseNormal, "")
+
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil || msg.Type != "subscribe" || msg.Subscription != "billing" {
+			conn.WriteJSON(wsMessage{Type: "error", Message: "expected subscribe"})
+			return
+		}
+		conn.WriteJSON(wsMessage{Type: "subscribed", Subscription: "billing", Position: 2})
+		conn.WriteJSON(wsMessage{Type: "events", Subscription: "billing", Events: []*store.StoredEvent{{Position: 3, Type: "A"}, {Position: 4, Type: "B"}}})
+		for {
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			acks <- msg.Position
+			conn.WriteJSON(wsMessage{Type: "error", Subscription: "billing", Message: "gone"})
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+
+	var events []*store.StoredEvent
+	err := client.Consume(context.Background(), "billing", func(batch []*store.StoredEvent) error {
+		events = append(events, batch...)
+		return nil
+	})
+	if err == nil || err.Error() != "subscription billing: gone" {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+	if len(events) != 2 || events[1].Position != 4 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	select {
+	case position := <-acks:
+		if position != 4 {
+			t.Errorf("expected ack of position 4, got %d", position)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an ack")
+	}
+
+	// handler errors stop consuming without an ack
+	stop := errors.New("stop")
+	if err := client.Consume(context.Background(), "billing", func([]*store.StoredEvent) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+
+	if err := New(server.URL, "wrong").Consume(context.Background(), "billing", nil); err == nil {
+		t.Error("expected an error for a refused upgrade")
+	}
+
+	// ctx ends an idle connection
+	idle := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.CloseNormal, "")
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer idle.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := New(idle.URL, "test-key").Consume(ctx, "billing", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Digest returns digests of [from, to] split into chunks ranges, along with
+// the server's head position. Comparing them with another server's digests
+// finds diverged ranges without transferring events.
+func (c *HTTPClient) Digest(ctx context.Context, from, to int64, chunks int) (int64, []store.RangeDigest, error) {
+	ctx, cancel := withTimeout(ctx, c.loadTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/digest?from=%d&to=%d&chunks=%d", c.baseURL, from, to, chunks)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Head   int64               `json:"head"`
+		Ranges []store.RangeDigest `json:"ranges"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return result.Head, result.Ranges, nil
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/digest" || q.Get("from") != "1" || q.Get("to") != "10" || q.Get("chunks") != "2" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"head":12,"ranges":[{"from":1,"to":5,"count":5,"hash":"aa"},{"from":6,"to":10,"count":5,"hash":"bb"}]}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+
+	head, ranges, err := client.Digest(context.Background(), 1, 10, 2)
+	if err != nil {
+		t.Fatalf("Digest failed: %v", err)
+	}
+	if head != 12 || len(ranges) != 2 || ranges[1].From != 6 || ranges[1].Hash != "bb" {
+		t.Errorf("unexpected digest: head %d, ranges %+v", head, ranges)
+	}
+
+	if _, _, err := client.Digest(context.Background(), 1, 10, 3); err == nil {
+		t.Error("expected an error for a non-200 response")
+	}
+}
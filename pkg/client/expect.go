@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ErrConflict is wrapped by the error of a save whose expected version no
+// longer matches the server
+var ErrConflict = store.ErrConflict
+
+// SaveWithExpectedVersion appends events to one stream only if the stream
+// is still at expectedVersion (0 for a new stream), for optimistic
+// concurrency. All events must carry the same StreamID. On success events
+// are updated with their positions; if another writer got there first the
+// error wraps ErrConflict and nothing is saved.
+func (c *HTTPClient) SaveWithExpectedVersion(ctx context.Context, events []*store.StoredEvent, expectedVersion int64) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if expectedVersion < 0 {
+		return fmt.Errorf("invalid expected version %d", expectedVersion)
+	}
+
+	query := url.Values{}
+	query.Set("expected_stream_version", fmt.Sprint(expectedVersion))
+	return c.saveBatch(ctx, events, query)
+}
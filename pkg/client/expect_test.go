@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSaveWithExpectedVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/batch" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("expected_stream_version") {
+		case "2":
+			w.Write([]byte(`{"saved":2,"first_position":10,"last_position":11}`))
+		default:
+			http.Error(w, `expected stream "orders/42" at version 1, stream is at 2`, http.StatusConflict)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	ctx := context.Background()
+	events := func() []*store.StoredEvent {
+		return []*store.StoredEvent{
+			{Type: "Paid", Data: json.RawMessage(`{}`), StreamID: "orders/42"},
+			{Type: "Shipped", Data: json.RawMessage(`{}`), StreamID: "orders/42"},
+		}
+	}
+
+	batch := events()
+	if err := client.SaveWithExpectedVersion(ctx, batch, 2); err != nil {
+		t.Fatalf("SaveWithExpectedVersion failed: %v", err)
+	}
+	if batch[0].Position != 10 || batch[1].Position != 11 {
+		t.Errorf("expected positions 10-11, got %d-%d", batch[0].Position, batch[1].Position)
+	}
+
+	if err := client.SaveWithExpectedVersion(ctx, events(), 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package client
+
+import "context"
+
+// JobHeader and JobReplay mark LoadStream calls as replay jobs, which a
+// server with HISTORY_WINDOW or HISTORY_RATE_LIMIT runs only within its
+// window and at its read rate
+const (
+	JobHeader = "X-Ebuse-Job"
+	JobReplay = "replay"
+)
+
+type replayJobCtxKey struct{}
+
+// WithReplayJob returns a context whose LoadStream calls are replay jobs.
+// Outside the server's window they fail with a 503 status error.
+func WithReplayJob(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayJobCtxKey{}, true)
+}
+
+func replayJob(ctx context.Context) bool {
+	job, _ := ctx.Value(replayJobCtxKey{}).(bool)
+	return job
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestWithReplayJob(t *testing.T) {
+	var jobs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jobs = append(jobs, r.Header.Get(JobHeader))
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "History jobs run only within 02:00-05:00", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	handler := func([]*store.StoredEvent) error { return nil }
+
+	if err := client.LoadStream(WithReplayJob(context.Background()), 1, 100, handler); err == nil {
+		t.Error("Expected the job to be refused outside the window")
+	}
+	if len(jobs) == 0 || jobs[0] != JobReplay {
+		t.Errorf("Expected %s: %s, got %q", JobHeader, JobReplay, jobs)
+	}
+
+	jobs = nil
+	client.LoadStream(context.Background(), 1, 100, handler)
+	if len(jobs) == 0 || jobs[0] != "" {
+		t.Errorf("Plain streams must not be jobs, got %q", jobs)
+	}
+}
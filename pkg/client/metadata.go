@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// MetadataHeaderPrefix marks headers carrying caller metadata. Servers with
+// metadata recording enabled store these values on every saved event.
+const MetadataHeaderPrefix = "X-Ebuse-Meta-"
+
+// Conventional metadata keys
+const (
+	MetadataCaller  = "caller"   // Identity of the producing service or user
+	MetadataTraceID = "trace-id" // Distributed trace ID
+)
+
+type metadataCtxKey struct{}
+
+// WithMetadata returns a context carrying an additional metadata entry that
+// is sent with writes made using the context. Keys are case-insensitive.
+func WithMetadata(ctx context.Context, key, value string) context.Context {
+	parent, _ := ctx.Value(metadataCtxKey{}).(map[string]string)
+	metadata := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	return context.WithValue(ctx, metadataCtxKey{}, metadata)
+}
+
+// setMetadataHeaders copies context metadata onto the request headers
+func setMetadataHeaders(req *http.Request) {
+	metadata, _ := req.Context().Value(metadataCtxKey{}).(map[string]string)
+	for k, v := range metadata {
+		req.Header.Set(MetadataHeaderPrefix+k, v)
+	}
+}
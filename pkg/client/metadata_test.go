@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSave_MetadataHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(MetadataHeaderPrefix + MetadataCaller); got != "billing" {
+			t.Errorf("expected caller billing, got %q", got)
+		}
+		if got := r.Header.Get(MetadataHeaderPrefix + MetadataTraceID); got != "trace-1" {
+			t.Errorf("expected trace id trace-1, got %q", got)
+		}
+		w.Write([]byte(`{"position":1}`))
+	}))
+	defer server.Close()
+
+	ctx := WithMetadata(context.Background(), MetadataCaller, "billing")
+	child := WithMetadata(ctx, MetadataTraceID, "trace-1")
+
+	client := New(server.URL, "test-key")
+	if err := client.Save(child, &store.StoredEvent{Type: "TestEvent"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Deriving a child context must not modify the parent's metadata
+	parent, _ := ctx.Value(metadataCtxKey{}).(map[string]string)
+	if len(parent) != 1 {
+		t.Errorf("expected parent metadata to be unchanged, got %v", parent)
+	}
+}
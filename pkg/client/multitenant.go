@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MultiTenantClient hands out per-tenant clients for one ebuse server.
+// All tenant clients share a single transport (connection pool) and Stats,
+// which suits platform services that write into many tenants.
+type MultiTenantClient struct {
+	template *HTTPClient
+	keys     map[string]string // tenant name -> API key
+
+	mu      sync.Mutex
+	clients map[string]*HTTPClient
+}
+
+// NewMultiTenant creates a client for several tenants of the server at baseURL.
+// tenantKeys maps tenant names to their API keys; opts apply to every tenant.
+func NewMultiTenant(baseURL string, tenantKeys map[string]string, opts ...Option) *MultiTenantClient {
+	keys := make(map[string]string, len(tenantKeys))
+	for name, key := range tenantKeys {
+		keys[name] = key
+	}
+
+	return &MultiTenantClient{
+		template: New(baseURL, "", opts...),
+		keys:     keys,
+		clients:  make(map[string]*HTTPClient),
+	}
+}
+
+// ForTenant returns the client for the named tenant
+func (m *MultiTenantClient) ForTenant(name string) (*HTTPClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.clients[name]; ok {
+		return c, nil
+	}
+
+	key, ok := m.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant: %s", name)
+	}
+
+	// Copy shares the http.Client and stats; caches hold tenant data, and
+	// replica heads and keys differ per tenant, so none may be shared
+	c := *m.template
+	c.apiKey = key
+	if m.template.cache != nil {
+		c.cache = newRangeCache(m.template.cache.maxEvents)
+	}
+	if m.template.replicas != nil {
+		c.replicas = m.template.replicas.clone()
+	}
+	c.replicaKeys = nil
+
+	m.clients[name] = &c
+	return &c, nil
+}
+
+// Tenants returns the configured tenant names in sorted order
+func (m *MultiTenantClient) Tenants() []string {
+	names := make([]string, 0, len(m.keys))
+	for name := range m.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the request counters aggregated over all tenants
+func (m *MultiTenantClient) Stats() Stats {
+	return m.template.Stats()
+}
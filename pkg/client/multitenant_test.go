@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMultiTenantClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		position := map[string]int64{"alice-key": 1, "bob-key": 2}[r.Header.Get("X-API-Key")]
+		json.NewEncoder(w).Encode(map[string]int64{"position": position})
+	}))
+	defer server.Close()
+
+	mc := NewMultiTenant(server.URL, map[string]string{
+		"alice": "alice-key",
+		"bob":   "bob-key",
+	}, WithRangeCache(10))
+
+	alice, err := mc.ForTenant("alice")
+	if err != nil {
+		t.Fatalf("ForTenant failed: %v", err)
+	}
+	bob, err := mc.ForTenant("bob")
+	if err != nil {
+		t.Fatalf("ForTenant failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if pos, _ := alice.GetPosition(ctx); pos != 1 {
+		t.Errorf("expected alice position 1, got %d", pos)
+	}
+	if pos, _ := bob.GetPosition(ctx); pos != 2 {
+		t.Errorf("expected bob position 2, got %d", pos)
+	}
+
+	if again, _ := mc.ForTenant("alice"); again != alice {
+		t.Error("expected ForTenant to return the same client for a tenant")
+	}
+	if alice.client != bob.client {
+		t.Error("expected tenants to share the HTTP client")
+	}
+	if alice.cache == bob.cache {
+		t.Error("expected tenants to have separate range caches")
+	}
+	if mc.Stats().Requests != 2 {
+		t.Errorf("expected 2 shared requests, got %d", mc.Stats().Requests)
+	}
+
+	if _, err := mc.ForTenant("charlie"); err == nil {
+		t.Error("expected error for unknown tenant")
+	}
+
+	tenants := mc.Tenants()
+	if len(tenants) != 2 || tenants[0] != "alice" || tenants[1] != "bob" {
+		t.Errorf("unexpected tenants: %v", tenants)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Option configures an HTTPClient
+type Option func(*HTTPClient)
+
+// WithRangeCache enables an in-memory LRU cache of closed ranges returned by
+// Load. Events are append-only, so a range whose upper bound has already been
+// reached never changes and can be served without another round trip.
+// maxEvents bounds the total number of cached events.
+func WithRangeCache(maxEvents int) Option {
+	return func(c *HTTPClient) {
+		if maxEvents > 0 {
+			c.cache = newRangeCache(maxEvents)
+		}
+	}
+}
+
+// WithQuickTimeout sets the deadline for quick calls: GetPosition and
+// subscription checkpoints (default 10s, 0 disables)
+func WithQuickTimeout(d time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.quickTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the deadline for Save calls (default 30s, 0 disables)
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.writeTimeout = d
+	}
+}
+
+// WithLoadTimeout sets the deadline for Load calls (default 10m, 0 disables)
+func WithLoadTimeout(d time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.loadTimeout = d
+	}
+}
+
+// WithTimeout sets the deadline of every call that has one: quick calls,
+// Save and Load (0 disables them all). Streaming calls such as LoadStream,
+// Replicate and Subscribe never have a deadline; bound them with ctx.
+func WithTimeout(d time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.quickTimeout = d
+		c.writeTimeout = d
+		c.loadTimeout = d
+	}
+}
+
+// WithRetries retries transport errors and 429/5xx responses up to maxRetries
+// times, starting at backoff and doubling per attempt (default: no retries).
+// Save reuses the same idempotency key across retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.maxRetries = maxRetries
+		if backoff > 0 {
+			c.retryBackoff = backoff
+		}
+	}
+}
+
+// WithMaxIdleConnsPerHost sets how many idle keep-alive connections are kept
+// per host (default 32)
+func WithMaxIdleConnsPerHost(n int) Option {
+	return transportOption(func(t *http.Transport) {
+		t.MaxIdleConnsPerHost = n
+		if t.MaxIdleConns > 0 && t.MaxIdleConns < n {
+			t.MaxIdleConns = n
+		}
+	})
+}
+
+// WithMaxConnsPerHost limits the total number of connections per host,
+// including those in use (default 0, unlimited)
+func WithMaxConnsPerHost(n int) Option {
+	return transportOption(func(t *http.Transport) {
+		t.MaxConnsPerHost = n
+	})
+}
+
+// WithIdleConnTimeout sets how long idle connections stay in the pool
+// (default 90s)
+func WithIdleConnTimeout(d time.Duration) Option {
+	return transportOption(func(t *http.Transport) {
+		t.IdleConnTimeout = d
+	})
+}
+
+// WithHTTPClient sends requests through hc, e.g. for a proxy, custom
+// transport or cookie jar. Connection pool and TLS options, in any order,
+// apply to a copy of hc's transport, which must then be an *http.Transport:
+// with another RoundTripper every call fails. A nil transport gets New's pool
+// defaults. hc.Timeout applies on top of the per-call deadlines, so leave it
+// 0 for long replays.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *HTTPClient) {
+		if hc != nil {
+			client := *hc
+			c.client = &client
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for HTTPS servers, e.g. a private
+// CA or client certificates, including Consume's WebSocket connections
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *HTTPClient) {
+		c.tlsConfig = config
+		transportOption(func(t *http.Transport) {
+			t.TLSClientConfig = config
+		})(c)
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *HTTPClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// transportOption returns an option changing the client's *http.Transport
+// once all options are applied
+func transportOption(apply func(*http.Transport)) Option {
+	return func(c *HTTPClient) {
+		c.transportOpts = append(c.transportOpts, apply)
+	}
+}
+
+// configureTransport applies the transport options to a copy of the
+// client's transport, so one passed to WithHTTPClient is never changed
+func (c *HTTPClient) configureTransport() {
+	t, ok := c.client.Transport.(*http.Transport)
+	switch {
+	case c.client.Transport == nil:
+		t = newTransport()
+	case !ok:
+		if len(c.transportOpts) > 0 {
+			err := fmt.Errorf("connection pool and TLS options need an *http.Transport, got %T", c.client.Transport)
+			c.client.Transport = errTransport{err: err}
+		}
+		return
+	case len(c.transportOpts) > 0:
+		t = t.Clone()
+	}
+	for _, apply := range c.transportOpts {
+		apply(t)
+	}
+	c.client.Transport = t
+}
+
+// errTransport fails every request of a misconfigured client
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// userAgentTransport sets the User-Agent of requests
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) unwrap() http.RoundTripper { return t.base }
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+// transport returns the client's *http.Transport beneath the layers New
+// adds, or nil if a custom RoundTripper is in use
+func (c *HTTPClient) transport() *http.Transport {
+	rt := c.client.Transport
+	for {
+		wrapper, ok := rt.(interface{ unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		rt = wrapper.unwrap()
+	}
+	t, _ := rt.(*http.Transport)
+	return t
+}
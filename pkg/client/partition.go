@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// keyedWorkers runs a CatchUp handler on several goroutines. Every event
+// goes to the worker its key hashes to, so the events of one key are
+// handled one batch after another in position order while other keys
+// proceed in parallel. The position saved is the last one up to which every
+// event was handled.
+type keyedWorkers struct {
+	key     func(*store.StoredEvent) string
+	handler func([]*store.StoredEvent) error
+	save    func(position int64) error
+	cancel  context.CancelFunc // Ends the CatchUp once a handler fails
+	queues  []chan []*store.StoredEvent
+	wg      sync.WaitGroup
+	saveMu  sync.Mutex // Keeps saves in order
+
+	mu      sync.Mutex
+	idle    *sync.Cond     // Signalled as batches finish
+	pending []int64        // Positions dispatched and not yet saved, in order
+	done    map[int64]bool // Pending positions handled
+	queued  int            // Batches queued or being handled
+	saved   int64          // Last position saved
+	err     error          // First handler error
+	stopped bool
+}
+
+// newKeyedWorkers starts n workers for events after position saved.
+// partitionKey is a dotted path into event data; empty keys events by
+// stream ID.
+func newKeyedWorkers(n int, partitionKey string, handler func([]*store.StoredEvent) error, save func(int64) error, saved int64, cancel context.CancelFunc) (*keyedWorkers, error) {
+	key := func(event *store.StoredEvent) string { return event.StreamID }
+	if partitionKey != "" {
+		path := strings.Split(partitionKey, ".")
+		for _, segment := range path {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid partition key %q", partitionKey)
+			}
+		}
+		key = func(event *store.StoredEvent) string { return dataKey(event.Data, path) }
+	}
+
+	w := &keyedWorkers{
+		key:     key,
+		handler: handler,
+		save:    save,
+		cancel:  cancel,
+		queues:  make([]chan []*store.StoredEvent, n),
+		done:    make(map[int64]bool),
+		saved:   saved,
+	}
+	w.idle = sync.NewCond(&w.mu)
+	for i := range w.queues {
+		w.queues[i] = make(chan []*store.StoredEvent, 1)
+		w.wg.Add(1)
+		go w.run(w.queues[i])
+	}
+	return w, nil
+}
+
+// dataKey returns the value at path in JSON data as a string; events
+// without it share the key ""
+func dataKey(data json.RawMessage, path []string) string {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ""
+	}
+	for _, segment := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = object[segment]
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// dispatch queues events for their workers. It returns the error of a
+// handler that failed earlier, or ctx's error if the queues stay full.
+func (w *keyedWorkers) dispatch(ctx context.Context, events []*store.StoredEvent) error {
+	parts := make([][]*store.StoredEvent, len(w.queues))
+	for _, event := range events {
+		h := fnv.New32a()
+		h.Write([]byte(w.key(event)))
+		i := h.Sum32() % uint32(len(parts))
+		parts[i] = append(parts[i], event)
+	}
+
+	w.mu.Lock()
+	if w.err != nil {
+		w.mu.Unlock()
+		return &handlerError{w.err}
+	}
+	for _, event := range events {
+		w.pending = append(w.pending, event.Position)
+	}
+	w.mu.Unlock()
+
+	for i, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		w.mu.Lock()
+		w.queued++
+		w.mu.Unlock()
+		select {
+		case w.queues[i] <- part:
+		case <-ctx.Done():
+			w.finish()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// run handles the batches of one queue until it is closed. Once a handler
+// has failed, the remaining batches are dropped so no key gets events past
+// one that failed.
+func (w *keyedWorkers) run(queue chan []*store.StoredEvent) {
+	defer w.wg.Done()
+	for batch := range queue {
+		w.mu.Lock()
+		skip := w.err != nil || w.stopped
+		w.mu.Unlock()
+		if skip {
+			w.finish()
+			continue
+		}
+
+		if err := w.handler(batch); err != nil {
+			w.mu.Lock()
+			if w.err == nil {
+				w.err = err
+				w.cancel()
+			}
+			w.mu.Unlock()
+			w.finish()
+			continue
+		}
+
+		w.mu.Lock()
+		for _, event := range batch {
+			w.done[event.Position] = true
+		}
+		var handled int64
+		for len(w.pending) > 0 && w.done[w.pending[0]] {
+			handled = w.pending[0]
+			delete(w.done, handled)
+			w.pending = w.pending[1:]
+		}
+		w.mu.Unlock()
+
+		// A failed save is covered by the next one; until then the events
+		// are delivered again after a restart
+		if handled > 0 {
+			w.saveMu.Lock()
+			if handled > w.position() && w.save(handled) == nil {
+				w.mu.Lock()
+				w.saved = handled
+				w.mu.Unlock()
+			}
+			w.saveMu.Unlock()
+		}
+		w.finish()
+	}
+}
+
+func (w *keyedWorkers) position() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.saved
+}
+
+// finish records that a queued batch is done with
+func (w *keyedWorkers) finish() {
+	w.mu.Lock()
+	w.queued--
+	w.idle.Broadcast()
+	w.mu.Unlock()
+}
+
+// drain waits for the queued batches and returns the last position saved,
+// after which dispatching continues, and the first handler error
+func (w *keyedWorkers) drain() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.queued > 0 {
+		w.idle.Wait()
+	}
+	w.pending = nil
+	clear(w.done)
+	return w.saved, w.err
+}
+
+// stop ends the workers once the batches being handled return; queued
+// batches are dropped
+func (w *keyedWorkers) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+	for _, queue := range w.queues {
+		close(queue)
+	}
+	w.wg.Wait()
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Defaults for replica reads
+const (
+	// DefaultReplicaRefresh is how long a replica's head position is trusted
+	// before it is polled again
+	DefaultReplicaRefresh = time.Second
+
+	// DefaultReplicaCooldown is how long a failed replica is skipped
+	DefaultReplicaCooldown = 30 * time.Second
+)
+
+// replica is a read-only server following the primary, e.g. a mirror target
+type replica struct {
+	baseURL string
+
+	mu        sync.Mutex
+	head      int64     // Last polled head position
+	checked   time.Time // When head was polled
+	downUntil time.Time // Skipped until then after a failure
+}
+
+// replicaSet routes reads to replicas as long as they are fresh enough.
+// Each tenant needs its own set, since heads differ between tenants.
+type replicaSet struct {
+	replicas []*replica
+	maxLag   int64
+	refresh  time.Duration
+	cooldown time.Duration
+
+	next atomic.Uint64
+	seen atomic.Int64 // Highest primary position observed by this client
+
+	mu      sync.Mutex
+	checked time.Time // When the primary's head was last polled into seen
+}
+
+func newReplicaSet(urls []string, maxLag int64) *replicaSet {
+	rs := &replicaSet{
+		maxLag:   maxLag,
+		refresh:  DefaultReplicaRefresh,
+		cooldown: DefaultReplicaCooldown,
+	}
+	for _, u := range urls {
+		rs.replicas = append(rs.replicas, &replica{baseURL: u})
+	}
+	return rs
+}
+
+// clone returns a set with the same replicas and bounds but no state
+func (rs *replicaSet) clone() *replicaSet {
+	urls := make([]string, len(rs.replicas))
+	for i, r := range rs.replicas {
+		urls[i] = r.baseURL
+	}
+	clone := newReplicaSet(urls, rs.maxLag)
+	clone.refresh, clone.cooldown = rs.refresh, rs.cooldown
+	return clone
+}
+
+// observe records a position known to exist on the primary
+func (rs *replicaSet) observe(position int64) {
+	for {
+		seen := rs.seen.Load()
+		if position <= seen || rs.seen.CompareAndSwap(seen, position) {
+			return
+		}
+	}
+}
+
+// WithReplicas sends Load calls to the given read replicas, e.g. mirror
+// targets, while writes, positions and subscription checkpoints stay on the
+// primary. A replica serves a closed range only once it holds all of it,
+// and an open range only while it is at most maxLag events behind the
+// primary's head, so maxLag 0 keeps reading your own writes. The head is
+// the highest position this client saw on the primary, polled from the
+// primary at most once a second for writes by other clients. Replicas that
+// fail are skipped for 30s and the read falls back to the primary. Durable
+// reads always go to the primary. Replicas get the client's API key unless
+// WithReplicaAPIKey gives them their own.
+func WithReplicas(maxLag int64, urls ...string) Option {
+	return func(c *HTTPClient) {
+		if len(urls) > 0 {
+			c.replicas = newReplicaSet(urls, max(maxLag, 0))
+		}
+	}
+}
+
+// WithReplicaAPIKey sets the API key sent to the replica at url, for
+// replicas such as mirror targets whose tenant has a key of its own.
+// MultiTenantClient tenants use their own key on every replica.
+func WithReplicaAPIKey(url, apiKey string) Option {
+	return func(c *HTTPClient) {
+		if c.replicaKeys == nil {
+			c.replicaKeys = make(map[string]string)
+		}
+		c.replicaKeys[url] = apiKey
+	}
+}
+
+// keyFor returns the API key for requests to baseURL: a replica's own key if
+// it has one, otherwise the client's
+func (c *HTTPClient) keyFor(baseURL string) string {
+	if key, ok := c.replicaKeys[baseURL]; ok {
+		return key
+	}
+	return c.apiKey
+}
+
+// pickReplica returns a replica fresh enough to serve [from, to], or nil to
+// read from the primary
+func (c *HTTPClient) pickReplica(ctx context.Context, to int64) *replica {
+	rs := c.replicas
+	if rs == nil || durableReads(ctx) {
+		return nil
+	}
+
+	need := to
+	if to == -1 {
+		head, ok := c.primaryHead(ctx)
+		if !ok {
+			return nil
+		}
+		need = head - rs.maxLag
+	}
+
+	start := rs.next.Add(1)
+	for i := range rs.replicas {
+		r := rs.replicas[(start+uint64(i))%uint64(len(rs.replicas))]
+		if c.replicaHead(ctx, r, need) >= need {
+			return r
+		}
+	}
+	return nil
+}
+
+// primaryHead returns the highest position known on the primary. Writes by
+// other clients are never observed, and a read-only client observes none,
+// so the primary is polled once the last poll is older than the refresh
+// interval. ok is false if that poll failed.
+func (c *HTTPClient) primaryHead(ctx context.Context) (int64, bool) {
+	rs := c.replicas
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if now := time.Now(); now.Sub(rs.checked) >= rs.refresh {
+		head, err := c.fetchPosition(ctx, c.baseURL)
+		if err != nil {
+			return 0, false
+		}
+		rs.observe(head)
+		rs.checked = now
+	}
+	return rs.seen.Load(), true
+}
+
+// replicaHead returns r's head position, polling it when the cached one is
+// older than the refresh interval and too low for need. Down replicas
+// report -1.
+func (c *HTTPClient) replicaHead(ctx context.Context, r *replica, need int64) int64 {
+	rs := c.replicas
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(r.downUntil) {
+		return -1
+	}
+	if r.head >= need || now.Sub(r.checked) < rs.refresh {
+		return r.head
+	}
+
+	head, err := c.fetchPosition(ctx, r.baseURL)
+	if err != nil {
+		r.downUntil = now.Add(rs.cooldown)
+		return -1
+	}
+	r.head, r.checked = head, now
+	return head
+}
+
+// markDown skips r for the cooldown after a failed read
+func (rs *replicaSet) markDown(r *replica) {
+	r.mu.Lock()
+	r.downUntil = time.Now().Add(rs.cooldown)
+	r.mu.Unlock()
+}
+
+// fetchPosition asks a server for its head without retries, so a slow or
+// dead replica costs one quick timeout before falling back to the primary
+func (c *HTTPClient) fetchPosition(ctx context.Context, baseURL string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, c.quickTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/position", nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.keyFor(baseURL))
+
+	c.stats.requests.Add(1)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.stats.errors.Add(1)
+		return 0, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.stats.errors.Add(1)
+		return 0, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Position int64 `json:"position"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+	return result.Position, nil
+}
+
+// errNoReplica makes Load read from the primary
+var errNoReplica = errors.New("no replica is fresh enough")
+
+// loadReplica loads [from, to] from a fresh replica. A replica that fails is
+// marked down and the error returned, so the caller falls back to the primary.
+func (c *HTTPClient) loadReplica(ctx context.Context, from, to int64) ([]*store.StoredEvent, error) {
+	r := c.pickReplica(ctx, to)
+	if r == nil {
+		return nil, errNoReplica
+	}
+
+	events, err := c.load(ctx, r.baseURL, from, to, false, nil)
+	if err != nil {
+		c.replicas.markDown(r)
+		c.stats.replicaFailovers.Add(1)
+		return nil, err
+	}
+	c.stats.replicaReads.Add(1)
+	return events, nil
+}
+
+// observe records a position known to exist on the primary
+func (c *HTTPClient) observe(position int64) {
+	if c.replicas != nil {
+		c.replicas.observe(position)
+	}
+}
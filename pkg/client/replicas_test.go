@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// fakeNode serves /position and /events for a log of head events and counts
+// the loads it answered
+type fakeNode struct {
+	head   atomic.Int64
+	loads  atomic.Int64
+	down   atomic.Bool
+	apiKey string // Required when set
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if n.down.Load() {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if n.apiKey != "" && r.Header.Get("X-API-Key") != n.apiKey {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/position":
+		fmt.Fprintf(w, `{"position":%d}`, n.head.Load())
+	case "/events":
+		if r.Method == http.MethodPost {
+			fmt.Fprintf(w, `{"position":%d,"type":"A","data":{}}`, n.head.Add(1))
+			return
+		}
+		n.loads.Add(1)
+		var events []map[string]any
+		for pos := int64(1); pos <= n.head.Load(); pos++ {
+			events = append(events, map[string]any{"position": pos, "type": "A", "data": map[string]any{}})
+		}
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+func TestReplicaReads(t *testing.T) {
+	primary, replica := &fakeNode{}, &fakeNode{}
+	primary.head.Store(10)
+	replica.head.Store(8)
+	primaryServer := httptest.NewServer(primary)
+	defer primaryServer.Close()
+	replicaServer := httptest.NewServer(replica)
+	defer replicaServer.Close()
+
+	ctx := context.Background()
+	client := New(primaryServer.URL, "test-key", WithReplicas(0, replicaServer.URL))
+
+	// Closed ranges the replica fully holds are served by it
+	if _, err := client.Load(ctx, 1, 8); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if replica.loads.Load() != 1 || primary.loads.Load() != 0 {
+		t.Errorf("Expected the replica to serve 1-8, got replica %d, primary %d", replica.loads.Load(), primary.loads.Load())
+	}
+
+	// Ranges past the replica's head go to the primary
+	if _, err := client.Load(ctx, 1, 10); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if primary.loads.Load() != 1 {
+		t.Errorf("Expected the primary to serve 1-10, got %d loads", primary.loads.Load())
+	}
+
+	// Having seen position 11 written, an open range must not come from a
+	// replica that lacks it
+	if err := client.Save(ctx, &store.StoredEvent{Type: "A", Data: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	events, err := client.Load(ctx, 1, -1)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(events) != 11 {
+		t.Errorf("Expected to read the own write, got %d events", len(events))
+	}
+
+	// A replica within the lag bound serves open ranges
+	lagging := New(primaryServer.URL, "test-key", WithReplicas(5, replicaServer.URL))
+	lagging.GetPosition(ctx)
+	if _, err := lagging.Load(ctx, 1, -1); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if replica.loads.Load() != 2 {
+		t.Errorf("Expected the replica to serve an open range within the lag bound, got %d loads", replica.loads.Load())
+	}
+
+	// Failed replicas fall back to the primary
+	replica.down.Store(true)
+	if _, err := lagging.Load(ctx, 1, 5); err != nil {
+		t.Fatalf("Expected failover to the primary, got %v", err)
+	}
+	stats := lagging.Stats()
+	if stats.ReplicaReads != 1 || stats.ReplicaFailovers != 1 {
+		t.Errorf("Expected 1 replica read and 1 failover, got %+v", stats)
+	}
+
+	// Durable reads never use replicas
+	replica.down.Store(false)
+	before := primary.loads.Load()
+	if _, err := client.Load(WithDurableReads(ctx), 1, 5); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if primary.loads.Load() != before+1 {
+		t.Error("Expected a durable read from the primary")
+	}
+}
+
+func TestReplicaReads_ReadOnly(t *testing.T) {
+	primary, replica := &fakeNode{apiKey: "primary-key"}, &fakeNode{apiKey: "replica-key"}
+	primary.head.Store(100)
+	replica.head.Store(10)
+	primaryServer := httptest.NewServer(primary)
+	defer primaryServer.Close()
+	replicaServer := httptest.NewServer(replica)
+	defer replicaServer.Close()
+
+	ctx := context.Background()
+	client := New(primaryServer.URL, "primary-key",
+		WithReplicas(5, replicaServer.URL),
+		WithReplicaAPIKey(replicaServer.URL, "replica-key"))
+	client.replicas.refresh = 0 // Poll heads on every read
+
+	// A client that never wrote still knows how far behind the replica is
+	if _, err := client.Load(ctx, 1, -1); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if replica.loads.Load() != 0 || primary.loads.Load() != 1 {
+		t.Errorf("Expected the primary to serve an open range the replica is 90 behind on, got replica %d, primary %d", replica.loads.Load(), primary.loads.Load())
+	}
+
+	// Once caught up, the replica serves it with its own key
+	replica.head.Store(98)
+	if _, err := client.Load(ctx, 1, -1); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if replica.loads.Load() != 1 {
+		t.Errorf("Expected the replica to serve an open range within the lag bound, got %d loads", replica.loads.Load())
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ReplicationFrame is one message of a /replicate stream
+type ReplicationFrame struct {
+	Type    string               `json:"type"` // "events" or "heartbeat"
+	Events  []*store.StoredEvent `json:"events,omitempty"`
+	Cursor  string               `json:"cursor"`  // Resumes the stream after this frame
+	Head    int64                `json:"head"`    // Primary's position when the frame was sent
+	Durable int64                `json:"durable"` // Highest durable position (0 if unknown)
+	Time    int64                `json:"time"`
+}
+
+// ErrCursorAhead is returned by Replicate when the cursor points past the
+// primary's log, meaning the follower holds events the primary has lost
+var ErrCursorAhead = errors.New("replication cursor is ahead of the primary")
+
+// Replicate follows the server's log starting at cursor (empty for the first
+// event), calling fn for every frame. It returns when ctx is done, fn fails
+// or the connection breaks; store each frame's Cursor after applying it and
+// call Replicate again with it to resume without gaps or duplicates.
+//
+// The stream has no deadline and is not retried, since heartbeats already
+// reveal stalled connections.
+func (c *HTTPClient) Replicate(ctx context.Context, cursor string, fn func(*ReplicationFrame) error) error {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/replicate?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		return ErrCursorAhead
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var frame ReplicationFrame
+		if err := dec.Decode(&frame); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return fmt.Errorf("replication stream closed by server")
+			}
+			return fmt.Errorf("decode frame: %w", err)
+		}
+		if err := fn(&frame); err != nil {
+			return err
+		}
+	}
+}
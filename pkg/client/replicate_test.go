@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReplicate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/replicate" || r.Header.Get("X-API-Key") != "test-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("cursor") == "1-99" {
+			http.Error(w, "Cursor is ahead of the log", http.StatusConflict)
+			return
+		}
+		w.Write([]byte(`{"type":"heartbeat","cursor":"1-3","head":4,"time":1}` + "\n"))
+		w.Write([]byte(`{"type":"events","events":[{"position":3,"type":"A","data":{}},{"position":4,"type":"B","data":{}}],"cursor":"1-5","head":4,"durable":4,"time":1}` + "\n"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+
+	var frames []*ReplicationFrame
+	err := client.Replicate(context.Background(), "1-3", func(frame *ReplicationFrame) error {
+		frames = append(frames, frame)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error when the server closes the stream")
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if frames[1].Type != "events" || len(frames[1].Events) != 2 || frames[1].Events[1].Position != 4 {
+		t.Errorf("unexpected events frame: %+v", frames[1])
+	}
+	if frames[1].Cursor != "1-5" || frames[1].Durable != 4 {
+		t.Errorf("unexpected cursor or durable position: %+v", frames[1])
+	}
+
+	// fn errors stop the stream
+	stop := errors.New("stop")
+	if err := client.Replicate(context.Background(), "", func(*ReplicationFrame) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected fn error, got %v", err)
+	}
+
+	if err := client.Replicate(context.Background(), "1-99", func(*ReplicationFrame) error { return nil }); !errors.Is(err, ErrCursorAhead) {
+		t.Errorf("expected ErrCursorAhead, got %v", err)
+	}
+}
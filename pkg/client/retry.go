@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader carries the per-Save idempotency key. The same key is
+// sent on every retry of one Save so the server can deduplicate writes.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultRetryBackoff is the initial delay between retries; it doubles per attempt
+const DefaultRetryBackoff = 100 * time.Millisecond
+
+type idempotencyKeyCtxKey struct{}
+
+// WithIdempotencyKey returns a context that makes Save use the given key
+// instead of generating one. Use it when the producer retries Save itself,
+// so its own retries stay deduplicated too.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtxKey{}, key)
+}
+
+// idempotencyKey returns the key set on ctx or a fresh random UUID
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyCtxKey{}).(string); ok && key != "" {
+		return key
+	}
+	return newUUID()
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// do sends the request, retrying transport errors and 429/5xx responses up to
+// maxRetries times with exponential backoff. Request bodies are replayed via
+// GetBody, so headers such as the idempotency key are identical on each attempt.
+func (c *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		c.stats.requests.Add(1)
+		if attempt > 0 {
+			c.stats.retries.Add(1)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil || resp.StatusCode >= 300 {
+			c.stats.errors.Add(1)
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(c.retryBackoff << attempt):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		req = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSave_IdempotencyKeyReusedAcrossRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+
+		var event store.StoredEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode retried body: %v", err)
+		}
+
+		if attempts.Add(1) < 3 {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		event.Position = 7
+		json.NewEncoder(w).Encode(event)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRetries(3, time.Millisecond))
+	event := &store.StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`)}
+
+	if err := client.Save(context.Background(), event); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if event.Position != 7 {
+		t.Errorf("expected position 7, got %d", event.Position)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Errorf("expected the same idempotency key on every attempt, got %v", keys)
+	}
+}
+
+func TestSave_IdempotencyKeyFromContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(IdempotencyKeyHeader); got != "my-key" {
+			t.Errorf("expected idempotency key my-key, got %q", got)
+		}
+		w.Write([]byte(`{"position":1}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	ctx := WithIdempotencyKey(context.Background(), "my-key")
+	if err := client.Save(ctx, &store.StoredEvent{Type: "TestEvent"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+func TestRetries_GiveUp(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRetries(2, time.Millisecond))
+	if _, err := client.GetPosition(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetries_NotOnClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key", WithRetries(3, time.Millisecond))
+	client.Save(context.Background(), &store.StoredEvent{Type: "TestEvent"})
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("expected 4xx not to be retried, got %d attempts", got)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	a, b := newUUID(), newUUID()
+	if len(a) != 36 || a[14] != '4' {
+		t.Errorf("expected version 4 UUID, got %s", a)
+	}
+	if a == b {
+		t.Error("expected unique UUIDs")
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import "sync/atomic"
+
+// Stats is a snapshot of a client's request counters
+type Stats struct {
+	Requests    int64 // HTTP requests sent, including retries
+	Retries     int64 // Requests that were retries of a failed attempt
+	Errors      int64 // Requests that failed with a transport error or non-2xx status
+	CacheHits   int64 // Load calls served from the range cache
+	CacheMisses int64 // Load calls on closed ranges that missed the range cache
+
+	ReplicaReads     int64 // Load calls served by a read replica
+	ReplicaFailovers int64 // Replica loads that failed and fell back to the primary
+}
+
+// clientStats holds the live counters; it may be shared by several clients
+type clientStats struct {
+	requests    atomic.Int64
+	retries     atomic.Int64
+	errors      atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	replicaReads     atomic.Int64
+	replicaFailovers atomic.Int64
+}
+
+func (s *clientStats) snapshot() Stats {
+	return Stats{
+		Requests:    s.requests.Load(),
+		Retries:     s.retries.Load(),
+		Errors:      s.errors.Load(),
+		CacheHits:   s.cacheHits.Load(),
+		CacheMisses: s.cacheMisses.Load(),
+
+		ReplicaReads:     s.replicaReads.Load(),
+		ReplicaFailovers: s.replicaFailovers.Load(),
+	}
+}
+
+// Stats returns a snapshot of the client's request counters
+func (c *HTTPClient) Stats() Stats {
+	return c.stats.snapshot()
+}
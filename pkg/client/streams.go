@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// LoadByStream returns up to limit events of the stream starting at
+// fromVersion, in version order. limit <= 0 uses the server's maximum.
+func (c *HTTPClient) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*store.StoredEvent, error) {
+	query := url.Values{}
+	if fromVersion > 0 {
+		query.Set("from_version", fmt.Sprint(fromVersion))
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+
+	var events []*store.StoredEvent
+	if err := c.getStream(ctx, streamID, "events?"+query.Encode(), &events); err != nil {
+		return nil, err
+	}
+	if err := c.decodeEvents(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// StreamVersion returns the version of the stream's last event, or 0 for a
+// stream without events
+func (c *HTTPClient) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	var result struct {
+		Version int64 `json:"version"`
+	}
+	if err := c.getStream(ctx, streamID, "version", &result); err != nil {
+		return 0, err
+	}
+	return result.Version, nil
+}
+
+// getStream decodes the response to GET /streams/{streamID}/{action}
+func (c *HTTPClient) getStream(ctx context.Context, streamID, action string, v any) error {
+	ctx, cancel := withTimeout(ctx, c.loadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/streams/"+url.PathEscape(streamID)+"/"+action, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/streams/orders/42/events" && r.URL.Query().Get("from_version") == "2" && r.URL.Query().Get("limit") == "10":
+			w.Write([]byte(`[{"position":7,"type":"Paid","data":{},"stream_id":"orders/42","stream_version":2}]`))
+		case r.URL.Path == "/streams/orders/42/version":
+			w.Write([]byte(`{"stream_id":"orders/42","version":3}`))
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	ctx := context.Background()
+
+	events, err := client.LoadByStream(ctx, "orders/42", 2, 10)
+	if err != nil {
+		t.Fatalf("LoadByStream failed: %v", err)
+	}
+	if len(events) != 1 || events[0].StreamID != "orders/42" || events[0].StreamVersion != 2 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if version, err := client.StreamVersion(ctx, "orders/42"); err != nil || version != 3 {
+		t.Errorf("expected version 3, got %d, %v", version, err)
+	}
+
+	if _, err := client.LoadByStream(ctx, "orders/42", 1, 0); err == nil {
+		t.Error("expected an error for a non-200 response")
+	}
+}
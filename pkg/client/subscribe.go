@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Subscribe tails the server's log over /events/subscribe, calling fn for
+// every event from position from on (0 for only events written after the
+// call). It returns when ctx is done, fn fails or the connection breaks;
+// call Subscribe again with the last event's position + 1 to resume.
+//
+// Like Replicate, the stream has no deadline and is not retried.
+func (c *HTTPClient) Subscribe(ctx context.Context, from int64, fn func(*store.StoredEvent) error) error {
+	return c.subscribe(ctx, from, Filter{}, fn)
+}
+
+// SubscribeFiltered is Subscribe for the events matching filter. Skipped
+// events are not sent, so resume after the last event fn got; the server
+// skips the others again.
+func (c *HTTPClient) SubscribeFiltered(ctx context.Context, filter Filter, from int64, fn func(*store.StoredEvent) error) error {
+	return c.subscribe(ctx, from, filter, fn)
+}
+
+func (c *HTTPClient) subscribe(ctx context.Context, from int64, filter Filter, fn func(*store.StoredEvent) error) error {
+	query := filterQuery(filter)
+	if from > 0 {
+		query.Set("from", strconv.FormatInt(from, 10))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/events/subscribe?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 16<<20) // Events are sent on a single line
+	var data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimPrefix(value, " ")
+			continue
+		}
+		if line != "" || data == "" {
+			continue
+		}
+
+		var event store.StoredEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		data = ""
+		if err := c.decodeEvents([]*store.StoredEvent{&event}); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return fmt.Errorf("subscription closed by server")
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSubscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/subscribe" || r.Header.Get("X-API-Key") != "test-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("from") == "99" {
+			http.Error(w, "Position is ahead of the log", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("retry: 1000\n\n"))
+		w.Write([]byte(": heartbeat\n\n"))
+		w.Write([]byte("id: 3\ndata: {\"position\":3,\"type\":\"A\",\"data\":{}}\n\n"))
+		w.Write([]byte("id: 4\ndata: {\"position\":4,\"type\":\"B\",\"data\":{}}\n\n"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+
+	var events []*store.StoredEvent
+	err := client.Subscribe(context.Background(), 3, func(event *store.StoredEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error when the server closes the stream")
+	}
+	if len(events) != 2 || events[0].Position != 3 || events[1].Type != "B" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// fn errors stop the stream
+	stop := errors.New("stop")
+	if err := client.Subscribe(context.Background(), 0, func(*store.StoredEvent) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected fn error, got %v", err)
+	}
+
+	if err := client.Subscribe(context.Background(), 99, func(*store.StoredEvent) error { return nil }); err == nil {
+		t.Error("expected an error for a position ahead of the log")
+	}
+}
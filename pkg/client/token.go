@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithServiceAccount authenticates as a service account instead of with an
+// API key. The client exchanges name and secret for a short-lived token at
+// /token, sends it as a Bearer token and fetches a new one before it
+// expires. A request rejected with 401 gets a fresh token and is sent once
+// more, so a rotated server secret costs one round trip.
+func WithServiceAccount(name, secret string) Option {
+	return func(c *HTTPClient) {
+		c.tokens = &tokenSource{name: name, secret: secret}
+	}
+}
+
+// WithBearerToken authenticates with tokens from an identity provider the
+// server accepts JWTs of, instead of an API key. token is called for every
+// request and should cache tokens itself, like an oauth2.TokenSource; after
+// a 401 it is called once more and the request retried if the token changed.
+func WithBearerToken(token func(ctx context.Context) (string, error)) Option {
+	return func(c *HTTPClient) {
+		c.tokens = &tokenSource{fetch: token}
+	}
+}
+
+// tokenSource caches a service account token and fetches a new one once
+// most of its lifetime has passed, or hands out the tokens of fetch
+type tokenSource struct {
+	name   string
+	secret string
+	url    string       // Set by New
+	client *http.Client // Set by New
+	fetch  func(ctx context.Context) (string, error)
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time // When the cached token is replaced
+}
+
+// Token returns a valid token, fetching one if needed
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	if s.fetch != nil {
+		return s.fetch(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.refresh) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create token request: %w", err)
+	}
+	req.SetBasicAuth(s.name, s.secret)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("request token: server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode token: %w", err)
+	}
+
+	// Refresh at 80% of the lifetime so slow requests never carry an
+	// expired token
+	s.token = result.AccessToken
+	s.refresh = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second * 4 / 5)
+	return s.token, nil
+}
+
+// Invalidate drops token if it is still the cached one, so the next Token
+// call fetches a new one
+func (s *tokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// tokenTransport authenticates requests with the token of a service account
+type tokenTransport struct {
+	base   http.RoundTripper
+	tokens *tokenSource
+}
+
+func (t *tokenTransport) unwrap() http.RoundTripper { return t.base }
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	// The server may have rotated its secret; retry once with a new token
+	t.tokens.Invalidate(token)
+	fresh, err := t.tokens.Token(req.Context())
+	if err != nil || fresh == token {
+		return resp, nil
+	}
+	retry := authorize(req, fresh)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// authorize returns a copy of req carrying token instead of an API key.
+// RoundTrippers must not modify the request they are given.
+func authorize(req *http.Request, token string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Del("X-API-Key")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
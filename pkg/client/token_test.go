@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestWithServiceAccount(t *testing.T) {
+	var issued atomic.Int64 // Tokens handed out; only the newest is accepted
+	var expiresIn atomic.Int64
+	expiresIn.Store(3600)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if name, secret, _ := r.BasicAuth(); name != "ingest" || secret != "s3cret" {
+				http.Error(w, "Invalid service account credentials", http.StatusUnauthorized)
+				return
+			}
+			token := "token-" + strconv.FormatInt(issued.Add(1), 10)
+			json.NewEncoder(w).Encode(map[string]any{"access_token": token, "token_type": "Bearer", "expires_in": expiresIn.Load()})
+			return
+		}
+
+		if r.Header.Get("X-API-Key") != "" || r.Header.Get("Authorization") != "Bearer token-"+strconv.FormatInt(issued.Load(), 10) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/position":
+			json.NewEncoder(w).Encode(map[string]any{"position": 7})
+		case "/events":
+			body, _ := io.ReadAll(r.Body)
+			var event store.StoredEvent
+			if err := json.Unmarshal(body, &event); err != nil {
+				http.Error(w, "bad body", http.StatusBadRequest)
+				return
+			}
+			event.Position = 8
+			json.NewEncoder(w).Encode(event)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "", WithServiceAccount("ingest", "s3cret"), WithMaxIdleConnsPerHost(4))
+	ctx := context.Background()
+
+	// One token serves every request until it is due for a refresh
+	for range 3 {
+		if _, err := client.GetPosition(ctx); err != nil {
+			t.Fatalf("GetPosition failed: %v", err)
+		}
+	}
+	if issued.Load() != 1 {
+		t.Errorf("Expected 1 token, got %d", issued.Load())
+	}
+
+	// A rejected token is replaced and the request, body included, resent
+	issued.Add(1)
+	event := &store.StoredEvent{Type: "OrderPaid", Data: json.RawMessage(`{}`)}
+	if err := client.Save(ctx, event); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if event.Position != 8 || issued.Load() != 3 {
+		t.Errorf("Expected position 8 with 3 tokens, got %d with %d", event.Position, issued.Load())
+	}
+
+	// Tokens past 80% of their lifetime are refreshed up front
+	expiresIn.Store(0)
+	client.tokens.Invalidate("token-3")
+	client.GetPosition(ctx)
+	client.GetPosition(ctx)
+	if issued.Load() != 5 {
+		t.Errorf("Expected 5 tokens, got %d", issued.Load())
+	}
+
+	if client.transport() == nil || client.transport().MaxIdleConnsPerHost != 4 {
+		t.Error("Expected transport options to apply beneath the token transport")
+	}
+
+	// Bad credentials surface as errors
+	bad := New(server.URL, "", WithServiceAccount("ingest", "wrong"))
+	if _, err := bad.GetPosition(ctx); err == nil {
+		t.Error("Expected an error for a wrong secret")
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	var accepted atomic.Value // The token the server accepts
+	accepted.Store("jwt-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "" || r.Header.Get("Authorization") != "Bearer "+accepted.Load().(string) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"position": 7})
+	}))
+	defer server.Close()
+
+	// The provider hands out jwt-1 twice, then jwt-2 for good
+	tokens := []string{"jwt-1", "jwt-1", "jwt-2"}
+	var calls atomic.Int64
+	client := New(server.URL, "", WithBearerToken(func(ctx context.Context) (string, error) {
+		n := int(calls.Add(1)) - 1
+		return tokens[min(n, len(tokens)-1)], nil
+	}))
+	ctx := context.Background()
+
+	if pos, err := client.GetPosition(ctx); err != nil || pos != 7 {
+		t.Fatalf("GetPosition() = %d, %v", pos, err)
+	}
+
+	// After a 401 the request is retried with the provider's new token
+	accepted.Store("jwt-2")
+	if _, err := client.GetPosition(ctx); err != nil {
+		t.Fatalf("GetPosition after rotation failed: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 token calls, got %d", calls.Load())
+	}
+
+	// Unchanged tokens are not retried
+	accepted.Store("jwt-3")
+	if _, err := client.GetPosition(ctx); err == nil {
+		t.Error("GetPosition with a rejected token succeeded")
+	}
+	if calls.Load() != 5 {
+		t.Errorf("Expected 5 token calls, got %d", calls.Load())
+	}
+}
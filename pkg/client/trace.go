@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// TraceHeader asks the server to log every stage of a write (validation,
+// store call, fsync) under the given trace ID
+const TraceHeader = "X-Ebuse-Trace"
+
+type traceCtxKey struct{}
+
+// WithWriteTrace returns a context whose Save and SaveBatch calls are traced
+// by the server under id, for debugging a single write in production. Traced
+// writes are synced before they are acknowledged, so use it sparingly.
+func WithWriteTrace(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, id)
+}
+
+// setTraceHeader copies the context's trace ID onto the request headers
+func setTraceHeader(req *http.Request) {
+	if id, _ := req.Context().Value(traceCtxKey{}).(string); id != "" {
+		req.Header.Set(TraceHeader, id)
+	}
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSave_WriteTrace(t *testing.T) {
+	var traces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traces = append(traces, r.Header.Get(TraceHeader))
+		w.Write([]byte(`{"position":1}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+	client.Save(WithWriteTrace(context.Background(), "checkout-4711"), &store.StoredEvent{Type: "TestEvent"})
+	client.Save(context.Background(), &store.StoredEvent{Type: "TestEvent"})
+
+	if len(traces) != 2 || traces[0] != "checkout-4711" || traces[1] != "" {
+		t.Errorf("Expected only the first save to be traced, got %q", traces)
+	}
+}
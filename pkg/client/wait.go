@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPositionWait is how long one /position/wait long-poll blocks on the
+// server before WaitForPosition asks again
+const DefaultPositionWait = 30 * time.Second
+
+// WaitForPosition blocks until the server's log has reached position and
+// returns the position it found, which may be higher. Waiting happens on
+// the server, so read-your-writes checks don't need a polling loop; pass the
+// position a Save returned to wait until another client can read it.
+//
+// WaitForPosition returns ctx.Err() when ctx ends first.
+func (c *HTTPClient) WaitForPosition(ctx context.Context, position int64) (int64, error) {
+	for {
+		wait := DefaultPositionWait
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = min(wait, time.Until(deadline))
+		}
+		if wait <= 0 {
+			return 0, context.DeadlineExceeded
+		}
+
+		current, reached, err := c.waitPosition(ctx, position, wait)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		if reached {
+			c.observe(current)
+			return current, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// waitPosition sends one long-poll of up to wait
+func (c *HTTPClient) waitPosition(ctx context.Context, position int64, wait time.Duration) (int64, bool, error) {
+	ctx, cancel := withTimeout(ctx, wait+c.quickTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("min", strconv.FormatInt(position, 10))
+	query.Set("timeout", wait.Round(time.Millisecond).String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/position/wait?"+query.Encode(), nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, false, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Position int64 `json:"position"`
+		Reached  bool  `json:"reached"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, false, fmt.Errorf("decode response: %w", err)
+	}
+	return result.Position, result.Reached, nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForPosition(t *testing.T) {
+	var head atomic.Int64
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minPosition, err := strconv.ParseInt(r.URL.Query().Get("min"), 10, 64)
+		timeout, terr := time.ParseDuration(r.URL.Query().Get("timeout"))
+		if r.URL.Path != "/position/wait" || r.Header.Get("X-API-Key") != "test-key" || err != nil || terr != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		polls.Add(1)
+
+		// A long-poll that times out, after which one event is written
+		position := head.Load()
+		if position < minPosition {
+			time.Sleep(min(timeout, 10*time.Millisecond))
+			head.Add(1)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"position": position, "reached": position >= minPosition})
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "test-key")
+
+	position, err := client.WaitForPosition(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("WaitForPosition failed: %v", err)
+	}
+	if position != 2 || polls.Load() != 3 {
+		t.Errorf("Expected position 2 after 3 polls, got %d after %d", position, polls.Load())
+	}
+
+	// The context ends the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.WaitForPosition(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
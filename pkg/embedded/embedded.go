@@ -0,0 +1,245 @@
+// Package embedded runs an ebuse store inside the application process.
+//
+// A Bus persists events like the server does and fans them out to
+// same-process subscribers through callbacks or channels, without HTTP.
+// Subscribers catch up from the store and then receive live events in
+// position order, so a subscriber never misses or repeats an event.
+//
+// Bus implements the store interface and can be used wherever a store is
+// expected; every write made through it is fanned out.
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Event is a stored event as delivered to subscribers
+type Event = store.StoredEvent
+
+// ErrClosed ends subscriptions when the bus is closed
+var ErrClosed = errors.New("bus closed")
+
+// DefaultMaxPending is the default for Config.MaxPending
+const DefaultMaxPending = 1024
+
+// Config configures a Bus
+type Config struct {
+	Backend string // "pebble" (default), "sqlite" or "memory" (path is ignored)
+
+	// MaxPending bounds the live events queued per subscriber. A subscriber
+	// that falls further behind re-reads the missed events from the store.
+	MaxPending int
+}
+
+// Bus is an event store with in-process subscriptions
+type Bus struct {
+	st         store.EventStore
+	maxPending int
+
+	ctx    context.Context // Cancelled by Close, ending subscriptions
+	cancel context.CancelFunc
+	active sync.WaitGroup // Running subscriptions
+
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+// Open opens or creates the store at path
+func Open(path string, config Config) (*Bus, error) {
+	var st store.EventStore
+	var err error
+	switch config.Backend {
+	case "", "pebble":
+		st, err = store.NewPebbleStore(path)
+	case "sqlite":
+		st, err = store.NewSQLiteStore(path)
+	case "memory":
+		st = store.NewMemoryStore()
+	default:
+		return nil, fmt.Errorf("invalid backend: %s (must be 'sqlite', 'pebble' or 'memory')", config.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newBus(st, config), nil
+}
+
+func newBus(st store.EventStore, config Config) *Bus {
+	if config.MaxPending <= 0 {
+		config.MaxPending = DefaultMaxPending
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bus{
+		st:         st,
+		maxPending: config.MaxPending,
+		ctx:        ctx,
+		cancel:     cancel,
+		subs:       make(map[*subscriber]struct{}),
+	}
+}
+
+// Save persists event and fans it out to subscribers
+func (b *Bus) Save(ctx context.Context, event *Event) error {
+	if err := b.st.Save(ctx, event); err != nil {
+		return err
+	}
+	b.publish([]*Event{event})
+	return nil
+}
+
+// SaveBatch persists events and fans them out to subscribers
+func (b *Bus) SaveBatch(ctx context.Context, events []*Event) error {
+	if err := b.st.SaveBatch(ctx, events); err != nil {
+		return err
+	}
+	b.publish(events)
+	return nil
+}
+
+// Load returns events in [from, to]; to = -1 loads through the head
+func (b *Bus) Load(ctx context.Context, from, to int64) ([]*Event, error) {
+	return b.st.Load(ctx, from, to)
+}
+
+// LoadStream streams events from position from in batches
+func (b *Bus) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*Event) error) error {
+	return b.st.LoadStream(ctx, from, batchSize, handler)
+}
+
+// LoadStreamRaw streams events as stored JSON
+func (b *Bus) LoadStreamRaw(ctx context.Context, from int64, batchSize int, handler func([]json.RawMessage) error) error {
+	raw, ok := b.st.(store.RawStreamer)
+	if !ok {
+		return errors.New("store does not support raw streaming")
+	}
+	return raw.LoadStreamRaw(ctx, from, batchSize, handler)
+}
+
+// LoadByTime returns the events in [from, to] with since <= Timestamp <
+// until. A zero since or until leaves that side open.
+func (b *Bus) LoadByTime(ctx context.Context, since, until time.Time, from, to int64) ([]*Event, error) {
+	return b.st.LoadByTime(ctx, since, until, from, to)
+}
+
+// LoadByTypes returns the events of the given types in [from, to]
+func (b *Bus) LoadByTypes(ctx context.Context, types []string, from, to int64) ([]*Event, error) {
+	index, ok := b.st.(store.TypeIndex)
+	if !ok {
+		return nil, errors.New("store does not support type filters")
+	}
+	return index.LoadByTypes(ctx, types, from, to)
+}
+
+// LoadStreamByTypes streams the events of the given types from position
+// from in batches
+func (b *Bus) LoadStreamByTypes(ctx context.Context, types []string, from int64, batchSize int, handler func([]*Event) error) error {
+	index, ok := b.st.(store.TypeIndex)
+	if !ok {
+		return errors.New("store does not support type filters")
+	}
+	return index.LoadStreamByTypes(ctx, types, from, batchSize, handler)
+}
+
+// LoadByStream returns up to limit events of the stream, starting at
+// fromVersion, in version order
+func (b *Bus) LoadByStream(ctx context.Context, streamID string, fromVersion int64, limit int) ([]*Event, error) {
+	index, ok := b.st.(store.StreamIndex)
+	if !ok {
+		return nil, errors.New("store does not support streams")
+	}
+	return index.LoadByStream(ctx, streamID, fromVersion, limit)
+}
+
+// StreamVersion returns the version of the stream's last event
+func (b *Bus) StreamVersion(ctx context.Context, streamID string) (int64, error) {
+	index, ok := b.st.(store.StreamIndex)
+	if !ok {
+		return 0, errors.New("store does not support streams")
+	}
+	return index.StreamVersion(ctx, streamID)
+}
+
+// Sync returns once every event up to position upTo (or all events, if upTo
+// is -1) would survive a crash
+func (b *Bus) Sync(ctx context.Context, upTo int64) error {
+	syncer, ok := b.st.(store.Syncer)
+	if !ok {
+		return nil
+	}
+	return syncer.Sync(ctx, upTo)
+}
+
+// GetPosition returns the head position
+func (b *Bus) GetPosition(ctx context.Context) (int64, error) {
+	return b.st.GetPosition(ctx)
+}
+
+// SaveSubscriptionPosition stores a subscription checkpoint
+func (b *Bus) SaveSubscriptionPosition(ctx context.Context, subscriptionID string, position int64) error {
+	return b.st.SaveSubscriptionPosition(ctx, subscriptionID, position)
+}
+
+// LoadSubscriptionPosition returns a subscription checkpoint
+func (b *Bus) LoadSubscriptionPosition(ctx context.Context, subscriptionID string) (int64, error) {
+	return b.st.LoadSubscriptionPosition(ctx, subscriptionID)
+}
+
+// Close ends all subscriptions with ErrClosed and closes the store. It
+// waits for running Subscribe callbacks to return.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if b.ctx.Err() != nil {
+		b.mu.Unlock()
+		return nil
+	}
+	b.cancel()
+	b.mu.Unlock()
+
+	b.active.Wait()
+	return b.st.Close()
+}
+
+// publish queues saved events for every subscriber. Subscribers get copies,
+// since callers may reuse their events after Save returns.
+func (b *Bus) publish(events []*Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	copies := make([]*Event, len(events))
+	for i, event := range events {
+		c := *event
+		copies[i] = &c
+	}
+	for sub := range b.subs {
+		sub.push(copies)
+	}
+}
+
+// register adds a subscriber, or returns false once the bus is closed
+func (b *Bus) register(sub *subscriber) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx.Err() != nil {
+		return false
+	}
+	b.subs[sub] = struct{}{}
+	b.active.Add(1)
+	return true
+}
+
+func (b *Bus) unregister(sub *subscriber) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+	b.active.Done()
+}
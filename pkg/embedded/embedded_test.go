@@ -0,0 +1,181 @@
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openBus(t *testing.T, config Config) *Bus {
+	t.Helper()
+	bus, err := Open(filepath.Join(t.TempDir(), "events"), config)
+	if err != nil {
+		t.Fatalf("Failed to open bus: %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+func saveEvents(t *testing.T, bus *Bus, n int) {
+	t.Helper()
+	for range n {
+		if err := bus.Save(context.Background(), &Event{Type: "Created", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+			t.Errorf("Save failed: %v", err)
+		}
+	}
+}
+
+// collect subscribes from position from until want events arrived
+func collect(t *testing.T, bus *Bus, from int64, want int) <-chan []int64 {
+	t.Helper()
+	result := make(chan []int64, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	go func() {
+		defer cancel()
+		var positions []int64
+		err := bus.Subscribe(ctx, from, func(event *Event) error {
+			positions = append(positions, event.Position)
+			if len(positions) == want {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("Expected the subscription to end by the callback, got %v", err)
+		}
+		result <- positions
+	}()
+	return result
+}
+
+var errStop = errors.New("stop")
+
+// checkSequence verifies positions are exactly from, from+1, ... without
+// gaps or repeats
+func checkSequence(t *testing.T, positions []int64, from int64, n int) {
+	t.Helper()
+	if len(positions) != n {
+		t.Fatalf("Expected %d events, got %d", n, len(positions))
+	}
+	for i, pos := range positions {
+		if pos != from+int64(i) {
+			t.Fatalf("Expected position %d at index %d, got %d", from+int64(i), i, pos)
+		}
+	}
+}
+
+func TestSubscribe_CatchUpAndLive(t *testing.T) {
+	for _, backend := range []string{"pebble", "sqlite", "memory"} {
+		t.Run(backend, func(t *testing.T) {
+			bus := openBus(t, Config{Backend: backend})
+			saveEvents(t, bus, 10)
+
+			result := collect(t, bus, 4, 97)
+
+			// Concurrent writers publish out of order
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					saveEvents(t, bus, 9)
+				}()
+			}
+			wg.Wait()
+			if err := bus.SaveBatch(context.Background(), []*Event{
+				{Type: "Batch", Data: json.RawMessage(`{}`)},
+				{Type: "Batch", Data: json.RawMessage(`{}`)},
+			}); err != nil {
+				t.Fatalf("SaveBatch failed: %v", err)
+			}
+
+			checkSequence(t, <-result, 4, 97)
+		})
+	}
+}
+
+func TestSubscribe_SlowSubscriberOverflow(t *testing.T) {
+	bus := openBus(t, Config{MaxPending: 2})
+
+	var positions []int64
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(context.Background(), 1, func(event *Event) error {
+			time.Sleep(time.Millisecond)
+			positions = append(positions, event.Position)
+			if len(positions) == 50 {
+				return errStop
+			}
+			return nil
+		})
+	}()
+
+	saveEvents(t, bus, 50)
+	if err := <-done; !errors.Is(err, errStop) {
+		t.Fatalf("Expected the subscription to end by the callback, got %v", err)
+	}
+	checkSequence(t, positions, 1, 50)
+}
+
+func TestSubscribeChan(t *testing.T) {
+	bus := openBus(t, Config{})
+	saveEvents(t, bus, 3)
+
+	sub := bus.SubscribeChan(context.Background(), 2, 0)
+	saveEvents(t, bus, 1)
+
+	for want := int64(2); want <= 4; want++ {
+		select {
+		case event := <-sub.C:
+			if event.Position != want {
+				t.Fatalf("Expected position %d, got %d", want, event.Position)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for position %d", want)
+		}
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Errorf("Expected Close to end the subscription cleanly, got %v", err)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected C to be closed")
+	}
+}
+
+func TestSubscribe_BusClosed(t *testing.T) {
+	bus := openBus(t, Config{})
+	saveEvents(t, bus, 1)
+
+	// Nobody reads C, so the subscription is blocked sending the first event
+	sub := bus.SubscribeChan(context.Background(), 1, 0)
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Close")
+	}
+
+	for range sub.C {
+		// Drain C until it is closed
+	}
+	if err := sub.Err(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestOpen_InvalidBackend(t *testing.T) {
+	if _, err := Open(t.TempDir(), Config{Backend: "mysql"}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
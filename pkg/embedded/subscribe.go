@@ -0,0 +1,205 @@
+package embedded
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// gapWait is how long a subscriber holds live events back while an earlier
+// position is missing. Concurrent saves can be published out of order; a
+// position still missing after gapWait is read from the store instead.
+const gapWait = 100 * time.Millisecond
+
+// catchUpBatch is the batch size for reading missed events from the store
+const catchUpBatch = 1000
+
+// subscriber queues live events for one subscription
+type subscriber struct {
+	maxPending int
+	wake       chan struct{}
+
+	mu       sync.Mutex
+	pending  []*Event
+	overflow bool // Events were dropped; re-read them from the store
+}
+
+// push queues events without blocking the writer
+func (s *subscriber) push(events []*Event) {
+	s.mu.Lock()
+	if len(s.pending)+len(events) > s.maxPending {
+		s.overflow = true
+	} else {
+		s.pending = append(s.pending, events...)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns and clears the queued events
+func (s *subscriber) take() ([]*Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, overflow := s.pending, s.overflow
+	s.pending, s.overflow = nil, false
+	return events, overflow
+}
+
+// Subscribe calls fn for every event from position from on, first from the
+// store and then as events are saved, until ctx is done, the bus is closed
+// (ErrClosed) or fn returns an error. Events are delivered in position
+// order, one at a time; a slow fn never blocks writers. fn must not modify
+// the event.
+func (b *Bus) Subscribe(ctx context.Context, from int64, fn func(*Event) error) error {
+	// Register before catching up so nothing saved meanwhile is missed
+	sub := &subscriber{maxPending: b.maxPending, wake: make(chan struct{}, 1)}
+	if !b.register(sub) {
+		return ErrClosed
+	}
+	defer b.unregister(sub)
+
+	// Closing the bus cancels the subscription's store reads and callbacks
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(b.ctx, cancel)
+	defer stop()
+
+	next := max(from, 1)
+	var fnErr error
+	deliver := func(events []*Event) error {
+		for _, event := range events {
+			if event.Position < next {
+				continue
+			}
+			if fnErr = fn(event); fnErr != nil {
+				return fnErr
+			}
+			next = event.Position + 1
+		}
+		return nil
+	}
+	// fail reports why the subscription ended
+	fail := func(err error) error {
+		switch {
+		case b.ctx.Err() != nil:
+			return ErrClosed
+		case parent.Err() != nil:
+			return parent.Err()
+		case fnErr != nil:
+			return fnErr
+		}
+		return err
+	}
+
+	if err := b.st.LoadStream(ctx, next, catchUpBatch, deliver); err != nil {
+		return fail(err)
+	}
+
+	var held []*Event // Live events waiting for an earlier position
+	var gap <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		case <-sub.wake:
+		case <-gap:
+			// Whatever the store has up to the held events is all there is
+			events, err := b.st.Load(ctx, next, held[0].Position-1)
+			if err != nil {
+				return fail(err)
+			}
+			if err := deliver(events); err != nil {
+				return fail(err)
+			}
+			next = held[0].Position
+		}
+
+		events, overflow := sub.take()
+		if overflow {
+			if err := b.st.LoadStream(ctx, next, catchUpBatch, deliver); err != nil {
+				return fail(err)
+			}
+		}
+
+		held = append(held, events...)
+		slices.SortFunc(held, func(a, b *Event) int { return cmp.Compare(a.Position, b.Position) })
+		i := 0
+		for ; i < len(held) && held[i].Position <= next; i++ {
+			if err := deliver(held[i : i+1]); err != nil {
+				return fail(err)
+			}
+		}
+		held = held[i:]
+
+		switch {
+		case len(held) == 0:
+			gap = nil
+		case gap == nil:
+			gap = time.After(gapWait)
+		}
+	}
+}
+
+// Subscription delivers events on C until it ends; C is then closed
+type Subscription struct {
+	C <-chan *Event
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// SubscribeChan is Subscribe with events delivered on a channel holding up
+// to buffer events. Call Close when done.
+func (b *Bus) SubscribeChan(ctx context.Context, from int64, buffer int) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan *Event, max(buffer, 0))
+	s := &Subscription{C: ch, cancel: cancel, done: make(chan struct{})}
+
+	// A blocked send must not keep Close waiting
+	stop := context.AfterFunc(b.ctx, cancel)
+
+	go func() {
+		defer close(ch)
+		defer close(s.done)
+		defer stop()
+		s.err = b.Subscribe(ctx, from, func(event *Event) error {
+			select {
+			case ch <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return s
+}
+
+// Err returns why the subscription ended once C is closed. It is nil before
+// that and when the subscription was ended by Close or by cancelling ctx.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		if errors.Is(s.err, context.Canceled) {
+			return nil
+		}
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close ends the subscription and returns Err
+func (s *Subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return s.Err()
+}
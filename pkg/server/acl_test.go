@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/acl"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// scopedTenants adds stream-limited keys to namedTenants
+type scopedTenants struct {
+	namedTenants
+	keys map[string]*acl.ACL // API key -> limits; the key's tenant is "alice"
+}
+
+func (s scopedTenants) GetStore(apiKey string) (store.EventStore, string, bool) {
+	if _, ok := s.keys[apiKey]; ok {
+		return s.namedTenants["alice"], "alice", true
+	}
+	return s.namedTenants.GetStore(apiKey)
+}
+
+func (s scopedTenants) KeyScope(apiKey string) (string, *acl.ACL, bool) {
+	a, ok := s.keys[apiKey]
+	return "alice/" + apiKey, a, ok
+}
+
+func TestStreamACL(t *testing.T) {
+	billing, err := acl.New([]string{"billing/*"}, []string{"billing/invoices/*"})
+	if err != nil {
+		t.Fatalf("acl.New failed: %v", err)
+	}
+	tenants := scopedTenants{
+		namedTenants: namedTenants{"alice": store.NewMemoryStore()},
+		keys:         map[string]*acl.ACL{"billing": billing},
+	}
+	srv := NewMultiTenant(tenants, DefaultConfig())
+	defer srv.Close()
+
+	serve := func(key, method, target, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for _, tc := range []struct {
+		key, method, target, body string
+		want                      int
+	}{
+		{"billing", "POST", "/events", `{"type":"InvoiceSent","data":{},"stream_id":"billing/invoices/1"}`, http.StatusOK},
+		{"billing", "POST", "/events/batch", `[{"type":"InvoicePaid","data":{},"stream_id":"billing/invoices/1"}]`, http.StatusOK},
+		{"billing", "GET", "/streams/billing/invoices/1/events", "", http.StatusOK},
+		{"billing", "GET", "/streams/billing/invoices/1/version", "", http.StatusOK},
+
+		// Other streams, events without a stream and whole-log reads
+		{"billing", "POST", "/events", `{"type":"OrderPaid","data":{},"stream_id":"orders/1"}`, http.StatusForbidden},
+		{"billing", "POST", "/events", `{"type":"InvoiceSent","data":{}}`, http.StatusForbidden},
+		{"billing", "POST", "/events/batch", `[{"type":"InvoicePaid","data":{},"stream_id":"billing/invoices/1"},{"type":"OrderPaid","data":{},"stream_id":"orders/1"}]`, http.StatusForbidden},
+		{"billing", "POST", "/events", `{"type":"CustomerAdded","data":{},"stream_id":"billing/customers/1"}`, http.StatusForbidden},
+		{"billing", "GET", "/streams/orders/1/events", "", http.StatusForbidden},
+		{"billing", "GET", "/events?from=0", "", http.StatusForbidden},
+		{"billing", "GET", "/events/stream", "", http.StatusForbidden},
+		{"billing", "GET", "/stats/types", "", http.StatusForbidden},
+		{"billing", "POST", "/events", `{"stream_id":`, http.StatusBadRequest},
+		{"billing", "POST", "/events/batch?positions=keep", `[{"position":1000,"type":"InvoicePaid","data":{},"stream_id":"billing/invoices/1"}]`, http.StatusForbidden},
+
+		// The tenant's main key is unlimited
+		{"alice", "GET", "/events?from=0", "", http.StatusOK},
+		{"alice", "POST", "/events", `{"type":"OrderPaid","data":{},"stream_id":"orders/1"}`, http.StatusOK},
+	} {
+		if got := serve(tc.key, tc.method, tc.target, tc.body); got != tc.want {
+			t.Errorf("%s %s %s with key %s: expected %d, got %d", tc.method, tc.target, tc.body, tc.key, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/token"
+)
+
+// adminCaller is who made an admin request
+type adminCaller struct {
+	role    string
+	session *token.Claims // Login session, nil for admin keys
+}
+
+// adminCallerKey is the context key of an admin request's adminCaller
+type adminCallerKey struct{}
+
+// adminMiddleware guards /admin endpoints with the admin keys, provided via
+// the X-Admin-Key header or as a bearer token, or with the session cookie of
+// an admin login. The key or login grants a role, which must allow the
+// request. Wrong keys count towards lockout.
+func adminMiddleware(config *Config, lockout *authLockout, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-Admin-Key")
+		if key == "" {
+			if after, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				key = after
+			}
+		}
+		if lockout.blocked(w, r, key) {
+			return
+		}
+
+		caller := adminCaller{role: keyRole(config, key)}
+		if session, ok := config.AdminLogin.session(r); ok && key == "" {
+			if !sessionAllowed(w, r, session) {
+				return
+			}
+			caller = adminCaller{role: session.Scope, session: &session}
+		}
+
+		if caller.role == "" {
+			ip := r.RemoteAddr
+			if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+				ip = strings.Split(forwarded, ",")[0]
+			}
+
+			logger(r).Warn("Admin authentication failed",
+				"audit", true,
+				"ip", ip,
+				"key_fingerprint", keyFingerprint(key),
+				"path", r.URL.Path,
+				"method", r.Method)
+			if key != "" {
+				lockout.fail(r, key)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user := ""
+		if caller.session != nil {
+			user = caller.session.Subject
+		}
+		setLogAdmin(r, user, caller.role)
+		if required := requiredRole(r); !roleAllows(caller.role, required) {
+			logger(r).Warn("Admin permission denied",
+				"audit", true,
+				"required_role", required,
+				"path", r.URL.Path,
+				"method", r.Method)
+			http.Error(w, fmt.Sprintf("Forbidden: requires the %s role", required), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), adminCallerKey{}, caller)))
+	}
+}
+
+// connectionsHandler reports open connections and active streams
+func connectionsHandler(w http.ResponseWriter, r *http.Request, ct *ConnTracker) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ct.Snapshot(true))
+}
+
+// compactionHandler reports compaction status (GET) or starts a manual
+// compaction (POST); with ?wait=true the response is sent once it finishes
+func compactionHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	compactor, ok := store.As[store.Compactor](st)
+	if !ok {
+		http.Error(w, "Compaction not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		done, err := compactor.StartCompaction()
+		if errors.Is(err, store.ErrCompactionRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger(r).Info("Manual compaction started", "audit", true)
+
+		if r.URL.Query().Get("wait") != "true" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(compactor.CompactionStatus())
+			return
+		}
+
+		// The compaction keeps running if the client gives up waiting
+		select {
+		case <-done:
+		case <-r.Context().Done():
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compactor.CompactionStatus())
+}
+
+// backupHandler snapshots st while it keeps serving. The snapshot is
+// streamed back as a zstd-compressed tar, or with ?upload=true written to
+// the backup store of sched, whose status is returned.
+func backupHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, sched *backup.Scheduler) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Get("upload") == "true" {
+		if sched == nil {
+			http.Error(w, "Scheduled backups not configured", http.StatusNotImplemented)
+			return
+		}
+		status, err := sched.Backup(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger(r).Info("Backup uploaded", "audit", true, "key", status.LastKey, "position", status.LastPosition)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	snap, err := backup.Take(r.Context(), st)
+	if errors.Is(err, backup.ErrNoFiles) {
+		http.Error(w, "Backup not supported by this store", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer snap.Close()
+	logger(r).Info("Backup downloaded", "audit", true, "position", snap.Position)
+
+	w.Header().Set("Content-Type", "application/zstd")
+	w.Header().Set("Content-Disposition", `attachment; filename="ebuse-backup.tar.zst"`)
+	w.Header().Set("X-Ebuse-Position", strconv.FormatInt(snap.Position, 10))
+	if _, err := snap.WriteTo(w); err != nil {
+		// Too late for an error status; the client sees a truncated body
+		logger(r).Warn("Backup download failed", "error", err)
+	}
+}
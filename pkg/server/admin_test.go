@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/blob"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// namedTenants is a minimal TenantManager with lookup by name
+type namedTenants map[string]store.EventStore
+
+func (n namedTenants) GetStore(apiKey string) (store.EventStore, string, bool) {
+	st, ok := n[apiKey]
+	return st, apiKey, ok
+}
+
+func (n namedTenants) GetAllTenants() []string {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (n namedTenants) GetStoreByName(name string) (store.EventStore, bool) {
+	st, ok := n[name]
+	return st, ok
+}
+
+func (n namedTenants) Close() error { return nil }
+
+func TestAdminCompaction(t *testing.T) {
+	pebbleStore, err := store.NewPebbleStore(t.TempDir() + "/alice")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	srv := NewMultiTenant(namedTenants{"alice": pebbleStore}, config)
+	defer srv.rateLimiter.Stop()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/admin/compaction"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d without tenant, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/admin/compaction?tenant=bob"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for unknown tenant, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr := serve(http.MethodPost, "/admin/compaction?tenant=alice&wait=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var status store.CompactionStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Manual.Running || status.Manual.Progress != 1 {
+		t.Errorf("Expected finished compaction, got %+v", status.Manual)
+	}
+}
+
+func TestAdminCompactionUnsupported(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/admin.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	srv := NewWithConfig(sqliteStore, config, "test-key-123")
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/compaction", nil)
+	req.Header.Set("X-Admin-Key", "admin-secret")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
+
+func TestAdminBackup(t *testing.T) {
+	ctx := context.Background()
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/alice.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+	for range 3 {
+		sqliteStore.Save(ctx, &store.StoredEvent{Type: "Tick", Data: json.RawMessage(`{}`)})
+	}
+	bs, err := blob.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	config.Backups = map[string]*backup.Scheduler{"alice": backup.New(sqliteStore, bs, backup.Config{Name: "alice"})}
+	srv := NewMultiTenant(namedTenants{"alice": sqliteStore, "bob": store.NewMemoryStore()}, config)
+	defer srv.rateLimiter.Stop()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/admin/backup?tenant=alice"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d for GET, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/admin/backup?tenant=bob"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected %d for a memory store, got %d", http.StatusNotImplemented, rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/admin/backup?tenant=bob&upload=true"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected %d without scheduled backups, got %d", http.StatusNotImplemented, rr.Code)
+	}
+
+	rr := serve(http.MethodPost, "/admin/backup?tenant=alice")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Ebuse-Position") != "3" {
+		t.Errorf("Expected X-Ebuse-Position 3, got %q", rr.Header().Get("X-Ebuse-Position"))
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Error("Expected a zstd stream")
+	}
+
+	rr = serve(http.MethodPost, "/admin/backup?tenant=alice&upload=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var status backup.Status
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if keys, _ := bs.List(ctx, ""); len(keys) != 1 || keys[0] != status.LastKey || status.LastPosition != 3 {
+		t.Errorf("Expected the uploaded backup in the store, got %v and %+v", keys, status)
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/oidc"
+	"github.com/jilio/ebuse/internal/token"
+)
+
+// DefaultAdminSessionTTL is how long an admin login lasts
+const DefaultAdminSessionTTL = 8 * time.Hour
+
+const (
+	adminSessionCookie = "ebuse_admin"       // Signed session of a logged-in admin
+	adminLoginCookie   = "ebuse_admin_login" // PKCE verifier and return path of a login in progress
+	adminLoginTimeout  = 10 * time.Minute    // Time to complete a login at the provider
+)
+
+// AdminLoginConfig configures OpenID Connect login for the /admin
+// endpoints. Users are granted the highest role of the groups in their ID
+// token.
+type AdminLoginConfig struct {
+	Issuer       string // Provider URL, e.g. https://accounts.example.com
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Public URL of /admin/callback, registered at the provider
+
+	GroupsClaim    string   // ID token claim listing the user's groups (default "groups")
+	OwnerGroups    []string // Groups granted the owner role
+	OperatorGroups []string // Groups granted the operator role
+	ViewerGroups   []string // Groups granted the viewer role
+
+	SessionSecrets []string      // Sign session cookies, newest first (at least 32 bytes each)
+	SessionTTL     time.Duration // Lifetime of a login (0 = DefaultAdminSessionTTL)
+}
+
+// oidcProvider is the part of *oidc.Provider used for login
+type oidcProvider interface {
+	AuthCodeURL(state, nonce, verifier string) string
+	Exchange(ctx context.Context, code, verifier, nonce string) (map[string]any, error)
+}
+
+// AdminLogin signs users in at an OpenID Connect provider with the
+// authorization code flow and keeps their role in a signed session cookie,
+// which the /admin endpoints accept in place of the admin key. Sessions are
+// not stored, so any replica sharing the session secrets accepts them; they
+// end when they expire or the user logs out.
+type AdminLogin struct {
+	config   AdminLoginConfig
+	provider oidcProvider
+	sessions *token.Signer
+	secure   bool // Mark cookies Secure, when served over HTTPS
+}
+
+// NewAdminLogin discovers the provider of config
+func NewAdminLogin(ctx context.Context, config AdminLoginConfig) (*AdminLogin, error) {
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("admin login needs a client ID and redirect URL")
+	}
+	if len(config.OwnerGroups) == 0 && len(config.OperatorGroups) == 0 && len(config.ViewerGroups) == 0 {
+		return nil, errors.New("admin login needs owner, operator or viewer groups")
+	}
+	sessions, err := token.NewSigner(config.SessionSecrets...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       config.Issuer,
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newAdminLogin(config, provider, sessions), nil
+}
+
+func newAdminLogin(config AdminLoginConfig, provider oidcProvider, sessions *token.Signer) *AdminLogin {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultAdminSessionTTL
+	}
+	return &AdminLogin{
+		config:   config,
+		provider: provider,
+		sessions: sessions,
+		secure:   strings.HasPrefix(config.RedirectURL, "https://"),
+	}
+}
+
+// handleLogin sends the browser to the provider. The optional "next"
+// parameter, an /admin path, is where the browser returns after login.
+func (l *AdminLogin) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/admin/") {
+		next = "/admin/session"
+	}
+
+	// The state, nonce and PKCE verifier all derive from one secret kept in
+	// a cookie, so a callback only succeeds in the browser that started it
+	verifier := oidc.RandomString()
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminLoginCookie,
+		Value:    verifier + "." + base64.RawURLEncoding.EncodeToString([]byte(next)),
+		Path:     "/admin/",
+		MaxAge:   int(adminLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   l.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, l.provider.AuthCodeURL(derive("state", verifier), derive("nonce", verifier), verifier), http.StatusFound)
+}
+
+// handleCallback completes a login: it redeems the provider's code, maps the
+// user's groups to a role and starts a session
+func (l *AdminLogin) handleCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+
+	cookie, err := r.Cookie(adminLoginCookie)
+	if err != nil {
+		http.Error(w, "Login expired, start again at /admin/login", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: adminLoginCookie, Path: "/admin/", MaxAge: -1, HttpOnly: true, Secure: l.secure})
+
+	verifier, encodedNext, _ := strings.Cut(cookie.Value, ".")
+	next, err := base64.RawURLEncoding.DecodeString(encodedNext)
+	if err != nil || verifier == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(derive("state", verifier))) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	if reason := query.Get("error"); reason != "" {
+		logger(r).Warn("Admin login refused by provider", "error", reason, "description", query.Get("error_description"))
+		http.Error(w, "Login failed: "+reason, http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := l.provider.Exchange(r.Context(), query.Get("code"), verifier, derive("nonce", verifier))
+	if err != nil {
+		logger(r).Warn("Admin login failed", "error", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	subject, _ := claims["email"].(string)
+	if subject == "" {
+		subject, _ = claims["sub"].(string)
+	}
+	groups := oidc.Strings(claims, l.config.GroupsClaim)
+	role := l.role(groups)
+	if role == "" {
+		logger(r).Warn("Admin login denied",
+			"audit", true,
+			"user", subject,
+			"groups", groups)
+		http.Error(w, "Forbidden: not a member of an admin group", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	session, err := l.sessions.Sign(token.Claims{
+		Subject:   subject,
+		Scope:     role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(l.config.SessionTTL).Unix(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(l.config.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   l.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	logger(r).Info("Admin login",
+		"audit", true,
+		"user", subject,
+		"role", role)
+	http.Redirect(w, r, string(next), http.StatusSeeOther)
+}
+
+// handleLogout ends the browser's session
+func (l *AdminLogin) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: adminSessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: l.secure})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// role returns the role granted to members of groups, or "" for none
+func (l *AdminLogin) role(groups []string) string {
+	member := func(allowed []string) bool {
+		return slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(allowed, g) })
+	}
+	switch {
+	case member(l.config.OwnerGroups):
+		return roleOwner
+	case member(l.config.OperatorGroups):
+		return roleOperator
+	case member(l.config.ViewerGroups):
+		return roleViewer
+	}
+	return ""
+}
+
+// session returns the valid session of r. Servers without login have none.
+func (l *AdminLogin) session(r *http.Request) (token.Claims, bool) {
+	if l == nil {
+		return token.Claims{}, false
+	}
+	cookie, err := r.Cookie(adminSessionCookie)
+	if err != nil {
+		return token.Claims{}, false
+	}
+	claims, err := l.sessions.Verify(cookie.Value, time.Now())
+	if err != nil || roleRanks[claims.Scope] == 0 {
+		return token.Claims{}, false
+	}
+	return claims, true
+}
+
+// sessionAllowed reports whether session may make request r, and answers
+// with 403 otherwise. Requests that change state must come from the server's
+// own pages, as cookies are also sent with requests other sites trigger.
+func sessionAllowed(w http.ResponseWriter, r *http.Request, session token.Claims) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	if !sameOrigin(r) {
+		logger(r).Warn("Cross-site admin request refused",
+			"audit", true,
+			"user", session.Subject,
+			"origin", r.Header.Get("Origin"),
+			"path", r.URL.Path,
+			"method", r.Method)
+		http.Error(w, "Forbidden: cross-site request", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sameOrigin reports whether the browser sent r from the server's own origin
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && origin.Host != "" && origin.Host == r.Host
+}
+
+// adminSessionHandler reports how the request was authenticated and its role
+func adminSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	caller, _ := r.Context().Value(adminCallerKey{}).(adminCaller)
+	if caller.session == nil {
+		json.NewEncoder(w).Encode(map[string]any{"method": "key", "role": caller.role})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"method":     "login",
+		"user":       caller.session.Subject,
+		"role":       caller.role,
+		"expires_at": time.Unix(caller.session.ExpiresAt, 0).UTC(),
+	})
+}
+
+// derive returns a value bound to a login's verifier
+func derive(label, verifier string) string {
+	sum := sha256.Sum256([]byte(label + ":" + verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
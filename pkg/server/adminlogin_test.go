@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/token"
+)
+
+// stubProvider logs in whoever is named by the code, with the groups given
+type stubProvider struct {
+	groups map[string][]string // By code
+}
+
+func (p stubProvider) AuthCodeURL(state, nonce, verifier string) string {
+	return "https://idp.example.com/authorize?" + url.Values{"state": {state}, "nonce": {nonce}}.Encode()
+}
+
+func (p stubProvider) Exchange(ctx context.Context, code, verifier, nonce string) (map[string]any, error) {
+	groups, ok := p.groups[code]
+	if !ok || derive("nonce", verifier) != nonce {
+		return nil, errors.New("invalid_grant")
+	}
+	claims := map[string]any{"sub": code, "email": code + "@example.com", "roles": []any{}}
+	for _, g := range groups {
+		claims["roles"] = append(claims["roles"].([]any), g)
+	}
+	return claims, nil
+}
+
+func TestAdminLogin(t *testing.T) {
+	sessions, err := token.NewSigner(strings.Repeat("s", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := newAdminLogin(AdminLoginConfig{
+		RedirectURL:  "https://ebuse.example.com/admin/callback",
+		GroupsClaim:  "roles",
+		OwnerGroups:  []string{"ebuse-admins"},
+		ViewerGroups: []string{"ebuse-viewers"},
+	}, stubProvider{groups: map[string][]string{
+		"alice":   {"staff", "ebuse-admins"},
+		"bob":     {"ebuse-viewers"},
+		"mallory": {"staff"},
+	}}, sessions)
+
+	config := DefaultConfig()
+	config.AdminLogin = login
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	// logIn follows /admin/login and the provider's redirect back, returning
+	// the callback response
+	logIn := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/login?next=/admin/connections", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected a redirect to the provider, got %d", w.Code)
+		}
+		provider, _ := url.Parse(w.Header().Get("Location"))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/callback?"+url.Values{"code": {code}, "state": {provider.Query().Get("state")}}.Encode(), nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == adminSessionCookie && c.MaxAge > 0 {
+				return c
+			}
+		}
+		return nil
+	}
+	request := func(method, path string, cookie *http.Cookie, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	// Admins are sent back to where they started, with a Secure session
+	w := logIn("alice")
+	admin := sessionCookie(w)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/connections" || admin == nil || !admin.Secure || !admin.HttpOnly {
+		t.Fatalf("Expected a session and a redirect to /admin/connections, got %d %v %v", w.Code, w.Header(), admin)
+	}
+	w = request(http.MethodGet, "/admin/session", admin, nil)
+	var session map[string]any
+	json.NewDecoder(w.Body).Decode(&session)
+	if w.Code != http.StatusOK || session["user"] != "alice@example.com" || session["role"] != roleOwner {
+		t.Errorf("Expected alice's owner session, got %d %v", w.Code, session)
+	}
+
+	// Cookie-authenticated changes must come from the same origin
+	if w := request(http.MethodPost, "/admin/compaction", admin, map[string]string{"Origin": "https://evil.example.com"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a cross-site POST to be refused, got %d", w.Code)
+	}
+	if w := request(http.MethodPost, "/admin/compaction", admin, map[string]string{"Sec-Fetch-Site": "same-origin"}); w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized {
+		t.Errorf("Expected a same-origin POST to pass auth, got %d", w.Code)
+	}
+
+	// Viewers only read
+	viewer := sessionCookie(logIn("bob"))
+	if viewer == nil {
+		t.Fatal("Expected bob to get a viewer session")
+	}
+	if w := request(http.MethodGet, "/admin/connections", viewer, nil); w.Code != http.StatusOK {
+		t.Errorf("Expected a viewer to read connections, got %d", w.Code)
+	}
+	if w := request(http.MethodPost, "/admin/compaction", viewer, map[string]string{"Sec-Fetch-Site": "same-origin"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a viewer POST to be refused, got %d", w.Code)
+	}
+
+	// Users outside the admin groups, forged state and tampered sessions
+	if w := logIn("mallory"); w.Code != http.StatusForbidden || sessionCookie(w) != nil {
+		t.Errorf("Expected mallory to be refused, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/admin/callback?code=alice&state=forged", &http.Cookie{Name: adminLoginCookie, Value: "verifier.L2FkbWluL3Nlc3Npb24"}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a forged state to be refused, got %d", w.Code)
+	}
+	tampered := &http.Cookie{Name: adminSessionCookie, Value: viewer.Value + "x"}
+	if w := request(http.MethodGet, "/admin/connections", tampered, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a tampered session to be refused, got %d", w.Code)
+	}
+
+	// Logging out clears the cookie
+	w = request(http.MethodPost, "/admin/logout", admin, nil)
+	cleared := w.Result().Cookies()
+	if w.Code != http.StatusNoContent || len(cleared) != 1 || cleared[0].Name != adminSessionCookie || cleared[0].MaxAge >= 0 {
+		t.Errorf("Expected logout to clear the session cookie, got %d %v", w.Code, cleared)
+	}
+}
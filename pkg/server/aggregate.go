@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// minAggregateBucket is the smallest time bucket /events/aggregate accepts
+const minAggregateBucket = time.Second
+
+// aggregateHandler summarizes events without returning them: their count
+// and first and last timestamp, optionally grouped by type (group_by=type)
+// and by time buckets (bucket=1h). Events are selected like /events reads
+// them, by from/to, since/until, type and meta.<key> parameters, all of
+// which may be combined here.
+func aggregateHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	q := store.AggregateQuery{From: 1, To: -1}
+	var err error
+	if s := query.Get("from"); s != "" {
+		if q.From, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("to"); s != "" {
+		if q.To, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "Invalid 'to' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if q.Since, q.Until, _, err = parseTimeRange(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Filter, err = parseFilter(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch query.Get("group_by") {
+	case "":
+	case "type":
+		q.GroupByType = true
+	default:
+		http.Error(w, "Invalid 'group_by' parameter, expected 'type'", http.StatusBadRequest)
+		return
+	}
+	if s := query.Get("bucket"); s != "" {
+		if q.Bucket, err = time.ParseDuration(s); err != nil || q.Bucket < minAggregateBucket {
+			http.Error(w, fmt.Sprintf("Invalid 'bucket' parameter, expected a duration of at least %v", minAggregateBucket), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := store.AggregateEvents(ctx, st, q)
+	if errors.Is(err, store.ErrTooManyGroups) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to aggregate events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestAggregateHandler(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/aggregate.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, typ := range []string{"OrderPlaced", "OrderPlaced", "OrderShipped", "OrderPlaced"} {
+		event := &store.StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: base.Add(time.Duration(i) * 40 * time.Minute)}
+		if i == 3 {
+			event.Metadata = map[string]string{"region": "eu"}
+		}
+		if err := st.Save(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := NewWithStore(st, DefaultConfig(), "test-key-123")
+	get := func(target string) (*httptest.ResponseRecorder, store.Aggregate) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		var result store.Aggregate
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+				t.Fatalf("%s: decode failed: %v", target, err)
+			}
+		}
+		return rr, result
+	}
+
+	_, all := get("/events/aggregate")
+	if all.Count != 4 || !all.MinTimestamp.Equal(base) || !all.MaxTimestamp.Equal(base.Add(2*time.Hour)) || all.Groups != nil {
+		t.Errorf("Unexpected totals: %+v", all)
+	}
+
+	// Events at 09:00, 09:40, 10:20 and 11:00, per hour and type
+	_, hourly := get("/events/aggregate?group_by=type&bucket=1h")
+	want := []struct {
+		typ   string
+		hour  int
+		count int64
+	}{{"OrderPlaced", 9, 2}, {"OrderShipped", 10, 1}, {"OrderPlaced", 11, 1}}
+	if len(hourly.Groups) != len(want) {
+		t.Fatalf("Expected %d groups, got %+v", len(want), hourly.Groups)
+	}
+	for i, w := range want {
+		g := hourly.Groups[i]
+		if g.Type != w.typ || g.Bucket.Hour() != w.hour || g.Count != w.count {
+			t.Errorf("Group %d = %+v, want %s at %d:00 x%d", i, g, w.typ, w.hour, w.count)
+		}
+	}
+
+	// Filters combine with time and position ranges
+	since := base.Add(30 * time.Minute).Format(time.RFC3339)
+	if _, r := get("/events/aggregate?type=Order*&since=" + since + "&to=3"); r.Count != 2 {
+		t.Errorf("Expected 2 events in range, got %+v", r)
+	}
+	if _, r := get("/events/aggregate?meta.region=eu"); r.Count != 1 {
+		t.Errorf("Expected 1 event in the eu region, got %+v", r)
+	}
+
+	for _, target := range []string{
+		"/events/aggregate?group_by=region",
+		"/events/aggregate?bucket=10ms",
+		"/events/aggregate?bucket=hourly",
+		"/events/aggregate?from=x",
+		"/events/aggregate?since=yesterday",
+	} {
+		if rr, _ := get(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rr.Code)
+		}
+	}
+}
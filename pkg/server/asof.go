@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// AsOfHeader reports the position an as_of read was answered at, so the
+// same read can be repeated with as_of set to it
+const AsOfHeader = "X-Ebuse-As-Of"
+
+// errAsOfReached stops a stream at the as_of position
+var errAsOfReached = errors.New("as_of position reached")
+
+// asOfPosition resolves the as_of parameter of a read to the last position
+// it may return: a position, capped at the head, or an RFC 3339 timestamp,
+// resolved with store.PositionAt. It returns -1 without as_of, and writes an
+// error response and returns false on failure.
+func asOfPosition(w http.ResponseWriter, r *http.Request, st store.EventStore) (int64, bool) {
+	s := r.URL.Query().Get("as_of")
+	if s == "" {
+		return -1, true
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var position int64
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		head, err := st.GetPosition(ctx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+			return 0, false
+		}
+		position = min(n, head)
+	} else if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		if position, err = store.PositionAt(ctx, st, t); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to resolve 'as_of': %v", err), http.StatusInternalServerError)
+			return 0, false
+		}
+	} else {
+		http.Error(w, "Invalid 'as_of' parameter, expected a position or an RFC 3339 timestamp", http.StatusBadRequest)
+		return 0, false
+	}
+
+	w.Header().Set(AsOfHeader, strconv.FormatInt(position, 10))
+	return position, true
+}
+
+// truncateAsOf drops the events after position asOf from events in position
+// order
+func truncateAsOf(events []*store.StoredEvent, asOf int64) []*store.StoredEvent {
+	i := 0
+	for i < len(events) && events[i].Position <= asOf {
+		i++
+	}
+	return events[:i]
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestAsOf(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The fourth event is a backfill, appended after 14:00 but stamped before
+	do("/events/batch", `[
+		{"type":"A","data":{},"timestamp":"2026-01-02T12:00:00Z","stream_id":"order-1"},
+		{"type":"A","data":{},"timestamp":"2026-01-02T13:00:00Z","stream_id":"order-1"},
+		{"type":"A","data":{},"timestamp":"2026-01-02T14:30:00Z","stream_id":"order-1"},
+		{"type":"A","data":{},"timestamp":"2026-01-02T13:30:00Z"}
+	]`)
+
+	tests := []struct {
+		path   string
+		want   string
+		header string
+	}{
+		{"/events?from=1&as_of=2", "[1 2]", "2"},
+		{"/events?from=2&to=4&as_of=3", "[2 3]", "3"},
+		{"/events?from=1&as_of=99", "[1 2 3 4]", "4"},
+		{"/events?from=1&as_of=2026-01-02T14:00:00Z", "[1 2]", "2"},
+		{"/events?as_of=2026-01-02T13:00:00Z&type=A&from=1", "[1 2]", "2"},
+		{"/events?as_of=2026-01-02T11:00:00Z&from=1", "[]", "0"},
+		{"/events/stream?from=1&as_of=3", "[1 2 3]", "3"},
+		{"/streams/order-1/events?as_of=2026-01-02T14:00:00Z", "[1 2]", "2"},
+	}
+	for _, tt := range tests {
+		rr := do(tt.path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.path, rr.Code, rr.Body.String())
+		}
+		var events []*store.StoredEvent
+		if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.path, err)
+		}
+		positions := make([]int64, len(events))
+		for i, event := range events {
+			positions[i] = event.Position
+		}
+		if got := fmt.Sprint(positions); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+		if got := rr.Header().Get(AsOfHeader); got != tt.header {
+			t.Errorf("%s: expected %s %s, got %q", tt.path, AsOfHeader, tt.header, got)
+		}
+	}
+
+	for _, path := range []string{"/events?from=1&as_of=yesterday", "/events?from=1&as_of=-1"} {
+		if rr := do(path, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// End-to-end benchmarks of the HTTP layer: every request goes through the
+// full middleware chain (logging, rate limiting, auth, compression) so
+// middleware regressions show up next to handler and store costs.
+
+func setupBenchServer(b *testing.B) *Server {
+	b.Helper()
+
+	// Request logging would dominate the output otherwise
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+
+	st, err := store.NewSQLiteStore(b.TempDir() + "/bench.db")
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	b.Cleanup(func() { st.Close() })
+
+	// Rate limits high enough never to trigger
+	config := DefaultConfig()
+	config.RateLimit = 1 << 30
+	config.RateBurst = 1 << 30
+
+	srv := NewWithConfig(st, config, "bench-key")
+	b.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func benchRequest(method, target string, body []byte) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("X-API-Key", "bench-key")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func seedBenchEvents(b *testing.B, srv *Server, n int) {
+	b.Helper()
+	events := make([]*store.StoredEvent, n)
+	for i := range events {
+		events[i] = &store.StoredEvent{
+			Type:      "BenchEvent",
+			Data:      json.RawMessage(fmt.Sprintf(`{"index":%d,"message":"benchmark payload"}`, i)),
+			Timestamp: time.Now(),
+		}
+	}
+	if err := srv.store.SaveBatch(context.Background(), events); err != nil {
+		b.Fatalf("Seed failed: %v", err)
+	}
+}
+
+func BenchmarkHTTP_Save(b *testing.B) {
+	srv := setupBenchServer(b)
+	body := []byte(`{"type":"BenchEvent","data":{"message":"benchmark payload"},"timestamp":"2024-01-01T00:00:00Z"}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, benchRequest(http.MethodPost, "/events", body))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func BenchmarkHTTP_Batch100(b *testing.B) {
+	srv := setupBenchServer(b)
+
+	events := make([]map[string]any, 100)
+	for i := range events {
+		events[i] = map[string]any{
+			"type":      "BenchEvent",
+			"data":      map[string]any{"index": i},
+			"timestamp": time.Now(),
+		}
+	}
+	body, _ := json.Marshal(events)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, benchRequest(http.MethodPost, "/events/batch", body))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	b.ReportMetric(float64(b.N*100)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkHTTP_Stream10k(b *testing.B) {
+	srv := setupBenchServer(b)
+	seedBenchEvents(b, srv, 10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, benchRequest(http.MethodGet, "/events/stream?from=1&batch_size=1000", nil))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rr.Code)
+		}
+	}
+	b.ReportMetric(float64(b.N*10000)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkHTTP_Load1k(b *testing.B) {
+	srv := setupBenchServer(b)
+	seedBenchEvents(b, srv, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, benchRequest(http.MethodGet, "/events?from=1&to=1000", nil))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rr.Code)
+		}
+	}
+}
+
+func BenchmarkHTTP_SubscriptionCheckpoint(b *testing.B) {
+	srv := setupBenchServer(b)
+	body := []byte(`{"position":42}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, benchRequest(http.MethodPost, "/subscriptions/bench/position", body))
+		if rr.Code != http.StatusNoContent {
+			b.Fatalf("unexpected status %d", rr.Code)
+		}
+	}
+}
+
+func BenchmarkHTTP_Position_Parallel(b *testing.B) {
+	srv := setupBenchServer(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, benchRequest(http.MethodGet, "/position", nil))
+			if rr.Code != http.StatusOK {
+				b.Errorf("unexpected status %d", rr.Code)
+				return
+			}
+		}
+	})
+}
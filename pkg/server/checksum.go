@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+)
+
+// Trailers sent after a stream requested with ?checksum=true. The checksum
+// is the CRC-32C (Castagnoli) of the uncompressed response body, as
+// 8 hex digits; the count is the number of events in the body.
+const (
+	ChecksumTrailer = "X-Ebuse-Checksum"
+	CountTrailer    = "X-Ebuse-Count"
+	CompleteTrailer = "X-Ebuse-Complete" // "false" when the stream ended on an error
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// streamChecksum accumulates a running CRC over the bytes written to a stream
+type streamChecksum struct {
+	hash.Hash32
+}
+
+// newStreamChecksum announces the trailers; it must be called before the
+// response body is written
+func newStreamChecksum(w http.ResponseWriter) *streamChecksum {
+	w.Header().Add("Trailer", ChecksumTrailer)
+	w.Header().Add("Trailer", CountTrailer)
+	w.Header().Add("Trailer", CompleteTrailer)
+	return &streamChecksum{Hash32: crc32.New(castagnoli)}
+}
+
+// finish sets the trailer values once the body is complete
+func (c *streamChecksum) finish(w http.ResponseWriter, count int, complete bool) {
+	w.Header().Set(ChecksumTrailer, fmt.Sprintf("%08x", c.Sum32()))
+	w.Header().Set(CountTrailer, strconv.Itoa(count))
+	w.Header().Set(CompleteTrailer, strconv.FormatBool(complete))
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestStreamChecksumTrailer(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		srv.store.Save(ctx, &store.StoredEvent{
+			Type:      "TestEvent",
+			Data:      json.RawMessage(`{"message":"test"}`),
+			Timestamp: time.Now(),
+		})
+	}
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	for _, gzip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gzip=%v", gzip), func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events/stream?from=1&batch_size=3&checksum=true", nil)
+			req.Header.Set("X-API-Key", "test-key-123")
+			if !gzip {
+				req.Header.Set("Accept-Encoding", "identity")
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+
+			want := fmt.Sprintf("%08x", crc32.Checksum(body, crc32.MakeTable(crc32.Castagnoli)))
+			if got := resp.Trailer.Get(ChecksumTrailer); got != want {
+				t.Errorf("Expected checksum %s, got %q", want, got)
+			}
+			if got := resp.Trailer.Get(CountTrailer); got != "7" {
+				t.Errorf("Expected count 7, got %q", got)
+			}
+			if got := resp.Trailer.Get(CompleteTrailer); got != "true" {
+				t.Errorf("Expected complete stream, got %q", got)
+			}
+
+			var events []*store.StoredEvent
+			if err := json.Unmarshal(body, &events); err != nil || len(events) != 7 {
+				t.Errorf("Expected 7 events, got %d (%v)", len(events), err)
+			}
+		})
+	}
+}
+
+func TestStreamWithoutChecksum(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/events/stream?from=1", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Header().Get("Trailer") != "" {
+		t.Errorf("Expected no trailers, got %q", rr.Header().Get("Trailer"))
+	}
+}
+
+func TestExportChecksumTrailer(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		srv.store.Save(ctx, &store.StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events/export?checksum=true", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	want := fmt.Sprintf("%08x", crc32.Checksum(rr.Body.Bytes(), crc32.MakeTable(crc32.Castagnoli)))
+	if got := resp.Trailer.Get(ChecksumTrailer); got != want {
+		t.Errorf("Expected checksum %s, got %q", want, got)
+	}
+	if got := resp.Trailer.Get(CountTrailer); got != "3" {
+		t.Errorf("Expected count 3, got %q", got)
+	}
+}
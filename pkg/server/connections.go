@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnTracker tracks open connections, bytes transferred per connection and
+// active streaming requests per tenant
+type ConnTracker struct {
+	mu       sync.Mutex
+	conns    map[uint64]*trackedConn
+	streams  map[string]int            // tenant -> active streams
+	ending   map[string]*tenantStreams // tenant -> streams EndStreams can end
+	nextID   atomic.Uint64
+	accepted atomic.Int64
+
+	maxStreams int // Per-tenant concurrent stream limit (0 = unlimited)
+
+	drainOnce sync.Once
+	drainCh   chan struct{} // Closed when the server starts draining
+}
+
+// ConnInfo describes one open connection
+type ConnInfo struct {
+	ID           uint64    `json:"id"`
+	RemoteAddr   string    `json:"remote_addr"`
+	OpenedAt     time.Time `json:"opened_at"`
+	BytesRead    int64     `json:"bytes_read"`
+	BytesWritten int64     `json:"bytes_written"`
+}
+
+// ConnSnapshot is a point-in-time view of connection state
+type ConnSnapshot struct {
+	Open          int            `json:"open_connections"`
+	Accepted      int64          `json:"accepted_connections"`
+	ActiveStreams map[string]int `json:"active_streams"`
+	Connections   []ConnInfo     `json:"connections,omitempty"`
+}
+
+// tenantStreams are the active streams of a tenant, ended together when
+// the tenant is removed
+type tenantStreams struct {
+	wg   sync.WaitGroup
+	done chan struct{} // Closed by EndStreams
+}
+
+func newConnTracker(maxStreamsPerTenant int) *ConnTracker {
+	return &ConnTracker{
+		conns:      make(map[uint64]*trackedConn),
+		streams:    make(map[string]int),
+		ending:     make(map[string]*tenantStreams),
+		maxStreams: maxStreamsPerTenant,
+		drainCh:    make(chan struct{}),
+	}
+}
+
+// Listener wraps ln so accepted connections are tracked
+func (ct *ConnTracker) Listener(ln net.Listener) net.Listener {
+	return &trackedListener{Listener: ln, tracker: ct}
+}
+
+// acquireStream reserves a streaming slot for the tenant; the stream must
+// end once the returned streams are ended
+func (ct *ConnTracker) acquireStream(tenant string) (*tenantStreams, bool) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.maxStreams > 0 && ct.streams[tenant] >= ct.maxStreams {
+		return nil, false
+	}
+	ct.streams[tenant]++
+	streams := ct.ending[tenant]
+	if streams == nil {
+		streams = &tenantStreams{done: make(chan struct{})}
+		ct.ending[tenant] = streams
+	}
+	streams.wg.Add(1)
+	return streams, true
+}
+
+func (ct *ConnTracker) releaseStream(tenant string, streams *tenantStreams) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	streams.wg.Done()
+	ct.streams[tenant]--
+	if ct.streams[tenant] <= 0 {
+		delete(ct.streams, tenant)
+	}
+}
+
+// activeStreams returns the number of active streams for a tenant
+func (ct *ConnTracker) activeStreams(tenant string) int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.streams[tenant]
+}
+
+// EndStreams ends the active streams of tenant at their next batch
+// boundary and waits until they have returned or ctx is done, e.g. before
+// the store of a removed tenant is closed. Streams started later are not
+// affected.
+func (ct *ConnTracker) EndStreams(ctx context.Context, tenant string) error {
+	ct.mu.Lock()
+	streams := ct.ending[tenant]
+	delete(ct.ending, tenant)
+	ct.mu.Unlock()
+	if streams == nil {
+		return nil
+	}
+
+	close(streams.done)
+	ended := make(chan struct{})
+	go func() {
+		streams.wg.Wait()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain asks active streams to stop at their next batch boundary and
+// refuses new ones, so clients can resume elsewhere before shutdown
+func (ct *ConnTracker) Drain() {
+	ct.drainOnce.Do(func() { close(ct.drainCh) })
+}
+
+// Draining reports whether Drain has been called
+func (ct *ConnTracker) Draining() bool {
+	select {
+	case <-ct.drainCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitStreams blocks until no streams are active or ctx is done
+func (ct *ConnTracker) WaitStreams(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		ct.mu.Lock()
+		active := len(ct.streams)
+		ct.mu.Unlock()
+		if active == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot returns the current connection state; per-connection details are
+// included only when withConns is set
+func (ct *ConnTracker) Snapshot(withConns bool) ConnSnapshot {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	snap := ConnSnapshot{
+		Open:          len(ct.conns),
+		Accepted:      ct.accepted.Load(),
+		ActiveStreams: make(map[string]int, len(ct.streams)),
+	}
+	for tenant, n := range ct.streams {
+		snap.ActiveStreams[tenant] = n
+	}
+
+	if withConns {
+		snap.Connections = make([]ConnInfo, 0, len(ct.conns))
+		for _, c := range ct.conns {
+			snap.Connections = append(snap.Connections, ConnInfo{
+				ID:           c.id,
+				RemoteAddr:   c.RemoteAddr().String(),
+				OpenedAt:     c.openedAt,
+				BytesRead:    c.bytesRead.Load(),
+				BytesWritten: c.bytesWritten.Load(),
+			})
+		}
+		sort.Slice(snap.Connections, func(i, j int) bool {
+			return snap.Connections[i].ID < snap.Connections[j].ID
+		})
+	}
+
+	return snap
+}
+
+// streamLimitMiddleware enforces the per-tenant concurrent stream limit and
+// ends streams early when the server drains or EndStreams ends the tenant's
+func (ct *ConnTracker) streamLimitMiddleware(tenant func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ct.Draining() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := tenant(r)
+		streams, ok := ct.acquireStream(name)
+		if !ok {
+			http.Error(w, "Too many concurrent streams", http.StatusTooManyRequests)
+			return
+		}
+		defer ct.releaseStream(name, streams)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-ct.drainCh:
+				cancel()
+			case <-streams.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+type trackedListener struct {
+	net.Listener
+	tracker *ConnTracker
+}
+
+func (l *trackedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	ct := l.tracker
+	tc := &trackedConn{
+		Conn:     conn,
+		id:       ct.nextID.Add(1),
+		openedAt: time.Now(),
+		tracker:  ct,
+	}
+
+	ct.accepted.Add(1)
+	ct.mu.Lock()
+	ct.conns[tc.id] = tc
+	ct.mu.Unlock()
+
+	return tc, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	id           uint64
+	openedAt     time.Time
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	tracker      *ConnTracker
+	closeOnce    sync.Once
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.conns, c.id)
+		c.tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
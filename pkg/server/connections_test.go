@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestStreamLimitMiddleware(t *testing.T) {
+	ct := newConnTracker(1)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := ct.streamLimitMiddleware(singleTenant, func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+		close(done)
+	}()
+	<-entered
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if n := ct.activeStreams("default"); n != 1 {
+		t.Errorf("Expected 1 active stream, got %d", n)
+	}
+
+	close(release)
+	<-done
+
+	if n := ct.activeStreams("default"); n != 0 {
+		t.Errorf("Expected no active streams after release, got %d", n)
+	}
+}
+
+func TestDrainEndsStreams(t *testing.T) {
+	ct := newConnTracker(0)
+
+	entered := make(chan struct{})
+	handler := ct.streamLimitMiddleware(singleTenant, func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+		close(done)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ct.WaitStreams(ctx); err == nil {
+		t.Error("Expected WaitStreams to time out while a stream is active")
+	}
+
+	ct.Drain()
+	ct.Drain() // idempotent
+	<-done
+
+	if err := ct.WaitStreams(context.Background()); err != nil {
+		t.Errorf("Expected no active streams after drain, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected new streams to be refused with %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+}
+
+func TestEndStreams(t *testing.T) {
+	ct := newConnTracker(0)
+
+	entered := make(chan string, 2)
+	handler := ct.streamLimitMiddleware(func(r *http.Request) string { return r.URL.Query().Get("tenant") }, func(w http.ResponseWriter, r *http.Request) {
+		entered <- r.URL.Query().Get("tenant")
+		<-r.Context().Done()
+	})
+
+	var done sync.WaitGroup
+	for _, tenant := range []string{"acme", "globex"} {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events/stream?tenant="+tenant, nil))
+		}()
+	}
+	<-entered
+	<-entered
+
+	if err := ct.EndStreams(context.Background(), "acme"); err != nil {
+		t.Fatalf("EndStreams failed: %v", err)
+	}
+	if n := ct.activeStreams("acme"); n != 0 {
+		t.Errorf("Expected the streams of acme to have ended, got %d", n)
+	}
+	if n := ct.activeStreams("globex"); n != 1 {
+		t.Errorf("Expected the stream of globex to go on, got %d", n)
+	}
+
+	// Streams started later are not ended
+	go handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events/stream?tenant=acme", nil))
+	<-entered
+	if n := ct.activeStreams("acme"); n != 1 {
+		t.Errorf("Expected a new stream of acme, got %d", n)
+	}
+
+	ct.Drain()
+	done.Wait()
+}
+
+func TestDrainFailsHealth(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected healthy server, got %d", rr.Code)
+	}
+
+	srv.Drain()
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d while draining, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	var body struct {
+		Status string                `json:"status"`
+		Checks map[string]probeCheck `json:"checks"`
+	}
+	json.NewDecoder(rr.Body).Decode(&body)
+	if body.Status != "not_ready" || body.Checks["drain"].Status != "fail" {
+		t.Errorf("Expected the drain check to fail, got %+v", body)
+	}
+
+	// Regular requests are still served while draining
+	req := httptest.NewRequest(http.MethodGet, "/position", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected position request to succeed while draining, got %d", rr.Code)
+	}
+}
+
+func TestTrackedListener(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(srv)
+	ts.Listener = srv.Listener(ln)
+	ts.Start()
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/position", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	snap := srv.conns.Snapshot(true)
+	if snap.Open != 1 || snap.Accepted != 1 {
+		t.Fatalf("Expected 1 open and 1 accepted connection, got %+v", snap)
+	}
+	if c := snap.Connections[0]; c.BytesRead == 0 || c.BytesWritten == 0 {
+		t.Errorf("Expected bytes to be counted, got %+v", c)
+	}
+
+	http.DefaultClient.CloseIdleConnections()
+}
+
+func TestAdminConnections(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/admin.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	srv := NewWithConfig(sqliteStore, config, "test-key-123")
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"Admin key header", "X-Admin-Key", "admin-secret", http.StatusOK},
+		{"Bearer token", "Authorization", "Bearer admin-secret", http.StatusOK},
+		{"API key is not enough", "X-API-Key", "test-key-123", http.StatusUnauthorized},
+		{"Wrong admin key", "X-Admin-Key", "wrong", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/connections", nil)
+			req.Header.Set(tt.header, tt.value)
+
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var snap ConnSnapshot
+			if err := json.NewDecoder(rr.Body).Decode(&snap); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminDisabledWithoutKey(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/connections", nil)
+	req.Header.Set("X-Admin-Key", "")
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// ConsistencyHeader selects the read consistency of /events and
+// /events/stream; the "consistency" query parameter is equivalent
+const ConsistencyHeader = "X-Ebuse-Consistency"
+
+// ConsistencyDurable makes the server flush writes to stable storage before
+// answering, so readers never observe events that could be lost on crash
+const ConsistencyDurable = "durable"
+
+// requiresDurableRead reports whether the request asked for durable reads
+func requiresDurableRead(r *http.Request) bool {
+	level := r.URL.Query().Get("consistency")
+	if level == "" {
+		level = r.Header.Get(ConsistencyHeader)
+	}
+	return strings.EqualFold(level, ConsistencyDurable)
+}
+
+// syncForRead flushes the store up to position upTo when the request asked
+// for durable reads. It writes an error response and returns false on failure.
+func syncForRead(w http.ResponseWriter, r *http.Request, st store.EventStore, upTo int64) bool {
+	if !requiresDurableRead(r) {
+		return true
+	}
+
+	syncer, ok := store.As[store.Syncer](st)
+	if !ok {
+		http.Error(w, "Durable reads not supported by this store", http.StatusNotImplemented)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := syncer.Sync(ctx, upTo); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sync store: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
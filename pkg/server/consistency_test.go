@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// plainStore hides optional interfaces of the wrapped store
+type plainStore struct {
+	store.EventStore
+}
+
+// countingSyncer records Sync calls
+type countingSyncer struct {
+	store.EventStore
+	syncs []int64
+}
+
+func (c *countingSyncer) Sync(ctx context.Context, upTo int64) error {
+	c.syncs = append(c.syncs, upTo)
+	return nil
+}
+
+func TestDurableReads(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/durable.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		syncs  []int64
+	}{
+		{"default is not synced", "/events?from=1", "", nil},
+		{"query parameter", "/events?from=1&to=5&consistency=durable", "", []int64{5}},
+		{"header", "/events?from=1", "durable", []int64{-1}},
+		{"stream", "/events/stream?from=1&consistency=durable", "", []int64{-1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &countingSyncer{EventStore: sqliteStore}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(ConsistencyHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			if req.URL.Path == "/events/stream" {
+				streamEventsHandler(rr, req, st, nil)
+			} else {
+				loadEventsHandler(rr, req, st)
+			}
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			if len(st.syncs) != len(tt.syncs) || (len(tt.syncs) > 0 && st.syncs[0] != tt.syncs[0]) {
+				t.Errorf("Expected syncs %v, got %v", tt.syncs, st.syncs)
+			}
+		})
+	}
+
+	t.Run("unsupported store", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/events?from=1&consistency=durable", nil)
+		rr := httptest.NewRecorder()
+		loadEventsHandler(rr, req, plainStore{sqliteStore})
+
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for CORS settings left empty
+var (
+	DefaultCORSHeaders = []string{
+		"Content-Type", "Authorization", "X-API-Key", "X-Admin-Key", "Last-Event-ID", "Range",
+		RequestIDHeader, TraceHeader, ConsistencyHeader, MetadataHeaderPrefix + "*",
+	}
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}
+)
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response
+const DefaultCORSMaxAge = 10 * time.Minute
+
+// corsExposedHeaders are the response headers scripts may read besides the
+// CORS-safelisted ones
+var corsExposedHeaders = strings.Join([]string{"Content-Range", "Accept-Ranges", "Retry-After", "Server-Timing", RequestIDHeader, TraceHeader, AsOfHeader}, ", ")
+
+// cors answers preflight requests and adds CORS headers to responses for
+// browsers calling the API from allowed origins. Credentials (cookies) are
+// never allowed; browser clients authenticate with API or admin key headers.
+type cors struct {
+	origins []string            // Allowed for every request
+	tenants map[string][]string // Allowed for requests of a tenant
+	headers []string            // Lowercase; a trailing "*" matches any suffix
+	methods []string
+	maxAge  string
+}
+
+// newCORS returns the CORS handling of config, or nil if no origin is allowed
+func newCORS(config *Config) *cors {
+	if len(config.CORSOrigins) == 0 && len(config.TenantCORSOrigins) == 0 {
+		return nil
+	}
+	c := &cors{
+		origins: normalizeOrigins(config.CORSOrigins),
+		tenants: make(map[string][]string, len(config.TenantCORSOrigins)),
+		methods: config.CORSMethods,
+		maxAge:  strconv.Itoa(int(DefaultCORSMaxAge.Seconds())),
+	}
+	for tenant, origins := range config.TenantCORSOrigins {
+		c.tenants[tenant] = normalizeOrigins(origins)
+	}
+	headers := config.CORSHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+	for _, header := range headers {
+		c.headers = append(c.headers, strings.ToLower(header))
+	}
+	if len(c.methods) == 0 {
+		c.methods = DefaultCORSMethods
+	}
+	if config.CORSMaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.CORSMaxAge.Seconds()))
+	}
+	return c
+}
+
+// ParseCORSOrigins parses a comma-separated list of origins for
+// Config.CORSOrigins: "*", origins such as "https://app.example.com" or
+// subdomain patterns such as "https://*.example.com"
+func ParseCORSOrigins(list string) ([]string, error) {
+	var origins []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry != "*" {
+			u, err := url.Parse(strings.Replace(entry, "*.", "", 1))
+			if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+				return nil, fmt.Errorf("invalid CORS origin %q, want scheme://host[:port]", entry)
+			}
+		}
+		origins = append(origins, entry)
+	}
+	return origins, nil
+}
+
+func normalizeOrigins(origins []string) []string {
+	normalized := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		normalized = append(normalized, strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/"))
+	}
+	return normalized
+}
+
+// originAllowed reports whether origin matches one of patterns: "*", an
+// exact origin such as "https://app.example.com", or "https://*.example.com"
+// for its subdomains
+func originAllowed(patterns []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(pattern, "*."); ok && strings.HasPrefix(origin, prefix) &&
+			strings.HasSuffix(origin, "."+suffix) && len(origin) > len(prefix)+len(suffix)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedAnywhere reports whether origin may call the API as some tenant
+func (c *cors) allowedAnywhere(origin string) bool {
+	if originAllowed(c.origins, origin) {
+		return true
+	}
+	for _, origins := range c.tenants {
+		if originAllowed(origins, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin sets the header granting origin access to the response
+func (c *cors) allowOrigin(w http.ResponseWriter, origin string) {
+	if slices.Contains(c.origins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+}
+
+// middleware answers preflights and grants allowed origins access before
+// authentication, so failed requests can be read by scripts too. Tenant
+// origins are checked again by tenantMiddleware once the tenant is known.
+func (c *cors) middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	preflight := loggingMiddleware(c.handlePreflight)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			preflight(w, r)
+			return
+		}
+		if c.allowedAnywhere(origin) {
+			c.allowOrigin(w, origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePreflight answers a preflight request, which carries no credentials
+func (c *cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !c.allowedAnywhere(origin) || !slices.Contains(c.methods, method) {
+		http.Error(w, "CORS request not allowed", http.StatusForbidden)
+		return
+	}
+
+	var headers []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && c.headerAllowed(header) {
+			headers = append(headers, header)
+		}
+	}
+
+	c.allowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// headerAllowed reports whether a lowercase request header is allowed
+func (c *cors) headerAllowed(header string) bool {
+	for _, allowed := range c.headers {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(header, prefix) || allowed == header {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantMiddleware withdraws the access middleware granted when the
+// request's origin is allowed only for other tenants
+func (c *cors) tenantMiddleware(tenantFor func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil || len(c.tenants) == 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && !originAllowed(c.origins, origin) && !originAllowed(c.tenants[tenantFor(r)], origin) {
+			w.Header().Del("Access-Control-Allow-Origin")
+			w.Header().Del("Access-Control-Expose-Headers")
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestCORS(t *testing.T) {
+	config := DefaultConfig()
+	config.CORSOrigins = []string{"https://app.example.com", "https://*.example.org"}
+	srv := NewWithStore(store.NewMemoryStore(), config, "secret")
+	defer srv.Close()
+
+	request := func(method, origin string, header map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/position", nil)
+		req.Header.Set("Origin", origin)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	preflight := map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-API-Key, X-Ebuse-Meta-Region, X-Unknown"}
+	w := request(http.MethodOptions, "https://app.example.com", preflight)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected preflight 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "x-api-key, x-ebuse-meta-region" {
+		t.Errorf("Expected the known headers only, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Expected max age 600, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Expected credentials never to be allowed")
+	}
+
+	if w := request(http.MethodOptions, "https://evil.example.com", preflight); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an unknown origin's preflight to be 403, got %d", w.Code)
+	}
+	if w := request(http.MethodOptions, "https://app.example.com", map[string]string{"Access-Control-Request-Method": "PATCH"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a disallowed method's preflight to be 403, got %d", w.Code)
+	}
+
+	// Actual requests are authenticated as usual
+	w = request(http.MethodGet, "https://eu.example.org", map[string]string{"X-API-Key": "secret"})
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://eu.example.org" {
+		t.Errorf("Expected a subdomain to be allowed, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") == "" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected exposed headers and Vary, got %v", w.Header())
+	}
+	w = request(http.MethodGet, "https://app.example.com", nil)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("Expected a readable 401, got %d %v", w.Code, w.Header())
+	}
+	if w := request(http.MethodGet, "https://example.org", map[string]string{"X-API-Key": "secret"}); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected the bare domain not to match a subdomain pattern")
+	}
+}
+
+func TestCORSTenantOrigins(t *testing.T) {
+	config := DefaultConfig()
+	config.TenantCORSOrigins = map[string][]string{"alice": {"https://alice.example.com"}}
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore(), "bob": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	request := func(key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/position", nil)
+		req.Header.Set("Origin", "https://alice.example.com")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("alice"); w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://alice.example.com" {
+		t.Errorf("Expected alice's origin to be allowed, got %d %v", w.Code, w.Header())
+	}
+	if w := request("bob"); w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected alice's origin to be withdrawn for bob, got %v", w.Header())
+	}
+}
+
+func TestParseCORSOrigins(t *testing.T) {
+	origins, err := ParseCORSOrigins(" https://app.example.com, *,https://*.example.org ,")
+	if err != nil || len(origins) != 3 {
+		t.Fatalf("Expected 3 origins, got %v (%v)", origins, err)
+	}
+	for _, invalid := range []string{"app.example.com", "https://app.example.com/path", "https://"} {
+		if _, err := ParseCORSOrigins(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
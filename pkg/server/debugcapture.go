@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugBodyBytes is how much of each request and response body a debug
+// capture keeps
+const debugBodyBytes = 4096
+
+// redactedHeaders are request headers whose values are never captured
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"X-Admin-Key":         true,
+	"X-Api-Key":           true,
+}
+
+// envelopeFields are the event fields whose string values survive body
+// redaction; everything else an event carries may be personal data
+var envelopeFields = map[string]bool{
+	"type":      true,
+	"stream_id": true,
+	"timestamp": true,
+}
+
+// capturedError is a failed request kept for /admin/debug/recent-errors
+type capturedError struct {
+	Time              time.Time         `json:"time"`
+	RequestID         string            `json:"request_id"`
+	Tenant            string            `json:"tenant,omitempty"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Query             string            `json:"query,omitempty"`
+	Status            int               `json:"status"`
+	DurationMs        int64             `json:"duration_ms"`
+	RequestHeaders    map[string]string `json:"request_headers"`
+	RequestBody       string            `json:"request_body,omitempty"`
+	RequestBytes      int64             `json:"request_bytes"` // Read by the server, which may stop early
+	RequestTruncated  bool              `json:"request_truncated,omitempty"`
+	ResponseBody      string            `json:"response_body,omitempty"`
+	ResponseBytes     int               `json:"response_bytes"`
+	ResponseTruncated bool              `json:"response_truncated,omitempty"`
+}
+
+// errorCapture keeps the most recent failed requests in a ring buffer. A nil
+// *errorCapture captures nothing.
+type errorCapture struct {
+	mu      sync.Mutex
+	entries []capturedError
+	next    int
+	full    bool
+}
+
+// newErrorCapture returns a capture holding up to size requests, or nil when
+// size is not positive
+func newErrorCapture(size int) *errorCapture {
+	if size <= 0 {
+		return nil
+	}
+	return &errorCapture{entries: make([]capturedError, size)}
+}
+
+func (c *errorCapture) add(entry capturedError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.next] = entry
+	c.next = (c.next + 1) % len(c.entries)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// recent returns the captured requests, newest first
+func (c *errorCapture) recent() []capturedError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.next
+	if c.full {
+		n = len(c.entries)
+	}
+	recent := make([]capturedError, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, c.entries[(c.next-i+len(c.entries))%len(c.entries)])
+	}
+	return recent
+}
+
+// middleware records requests answered with 4xx or 5xx. It has to run
+// inside the logging middleware, which assigns the request ID.
+func (c *errorCapture) middleware(next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		body := &captureReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		cw := &captureWriter{ResponseWriter: w}
+
+		next(cw, r)
+
+		if cw.status < http.StatusBadRequest {
+			return
+		}
+
+		entry := capturedError{
+			Time:              start.UTC(),
+			RequestID:         requestID(r),
+			Method:            r.Method,
+			Path:              r.URL.Path,
+			Query:             r.URL.RawQuery,
+			Status:            cw.status,
+			DurationMs:        time.Since(start).Milliseconds(),
+			RequestHeaders:    redactHeaders(r.Header),
+			RequestBody:       redactJSON(body.buf),
+			RequestBytes:      body.total,
+			RequestTruncated:  body.total > int64(len(body.buf)),
+			ResponseBody:      responseText(cw),
+			ResponseBytes:     cw.written,
+			ResponseTruncated: cw.written > len(cw.buf),
+		}
+		if l := getRequestLog(r); l != nil {
+			entry.Tenant = l.tenant
+		}
+		c.add(entry)
+	}
+}
+
+// captureReader keeps the start of a request body as the handler reads it
+type captureReader struct {
+	io.ReadCloser
+	buf   []byte
+	total int64
+}
+
+func (cr *captureReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	if room := debugBodyBytes - len(cr.buf); room > 0 {
+		cr.buf = append(cr.buf, p[:min(n, room)]...)
+	}
+	cr.total += int64(n)
+	return n, err
+}
+
+// captureWriter keeps the status and the start of a response body
+type captureWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	written int
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if room := debugBodyBytes - len(cw.buf); room > 0 {
+		cw.buf = append(cw.buf, b[:min(len(b), room)]...)
+	}
+	n, err := cw.ResponseWriter.Write(b)
+	cw.written += n
+	return n, err
+}
+
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Hijack passes upgrades through; they are never captured
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// responseText returns the captured response body, decompressed when the
+// compression middleware encoded it
+func responseText(cw *captureWriter) string {
+	if cw.Header().Get("Content-Encoding") != "gzip" {
+		return string(cw.buf)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(cw.buf))
+	if err != nil {
+		return ""
+	}
+	// The capture may end mid-stream; keep what decompresses
+	text, _ := io.ReadAll(io.LimitReader(zr, debugBodyBytes))
+	return string(text)
+}
+
+// redactedHeaderWords mark other headers that likely carry credentials,
+// e.g. X-Auth-Token or X-Client-Secret
+var redactedHeaderWords = []string{"Auth", "Token", "Secret", "Password", "Credential"}
+
+// redactHeaders flattens request headers, hiding credentials
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[name] || slices.ContainsFunc(redactedHeaderWords, func(word string) bool { return strings.Contains(name, word) }) {
+			headers[name] = "[REDACTED]"
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// redactJSON replaces the string values of a JSON body with "[REDACTED]",
+// except for the event envelope fields. Keys, numbers and structure are kept,
+// so a malformed request stays diagnosable. The body may be truncated or not
+// JSON at all, so it is scanned rather than parsed.
+func redactJSON(body []byte) string {
+	var out strings.Builder
+	depth := 0 // Object nesting; events are at depth 1, alone or in a batch
+	keep := false
+	for i := 0; i < len(body); {
+		switch body[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if body[i] != '"' {
+			out.WriteByte(body[i])
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(body) && body[end] != '"' {
+			if body[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end = min(end+1, len(body))
+		str := body[i:end]
+
+		colon := end
+		for colon < len(body) && strings.IndexByte(" \t\r\n", body[colon]) >= 0 {
+			colon++
+		}
+		switch {
+		case colon < len(body) && body[colon] == ':':
+			var key string
+			json.Unmarshal(str, &key)
+			keep = depth == 1 && envelopeFields[key]
+			out.Write(str)
+		case keep:
+			out.Write(str)
+		default:
+			out.WriteString(`"[REDACTED]"`)
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// recentErrorsHandler lists the captured failed requests, newest first,
+// optionally of the tenant given by ?tenant= only
+func recentErrorsHandler(w http.ResponseWriter, r *http.Request, c *errorCapture) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c == nil {
+		http.Error(w, "Debug capture is disabled (set DEBUG_CAPTURE)", http.StatusNotFound)
+		return
+	}
+
+	recent := c.recent()
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		filtered := recent[:0]
+		for _, entry := range recent {
+			if entry.Tenant == tenant {
+				filtered = append(filtered, entry)
+			}
+		}
+		recent = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": recent,
+	})
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			"event",
+			`{"type":"UserCreated","stream_id":"user-1","data":{"email":"a@b.c","age":42,"type":"admin"}}`,
+			`{"type":"UserCreated","stream_id":"user-1","data":{"email":"[REDACTED]","age":42,"type":"[REDACTED]"}}`,
+		},
+		{
+			"batch",
+			`[{"type":"A","data":["x", "y"]}, {"type" : "B","metadata":{"k":"v"}}]`,
+			`[{"type":"A","data":["[REDACTED]", "[REDACTED]"]}, {"type" : "B","metadata":{"k":"[REDACTED]"}}]`,
+		},
+		{"escaped quote", `{"data":"say \"hi\"","type":"A"}`, `{"data":"[REDACTED]","type":"A"}`},
+		{"truncated", `{"type":"A","data":{"name":"Jo`, `{"type":"A","data":{"name":"[REDACTED]"`},
+		{"not JSON", `hello`, `hello`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactJSON([]byte(tt.body)); got != tt.want {
+				t.Errorf("redactJSON(%s)\n got %s\nwant %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCapture_Ring(t *testing.T) {
+	if c := newErrorCapture(0); c != nil {
+		t.Fatal("Expected no capture for size 0")
+	}
+
+	c := newErrorCapture(2)
+	if got := c.recent(); len(got) != 0 {
+		t.Fatalf("Expected no entries, got %d", len(got))
+	}
+	for _, status := range []int{400, 401, 500} {
+		c.add(capturedError{Status: status})
+	}
+	got := c.recent()
+	if len(got) != 2 || got[0].Status != 500 || got[1].Status != 401 {
+		t.Errorf("Expected the last two entries newest first, got %+v", got)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/capture.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	config.DebugCapture = 10
+	srv := NewMultiTenant(namedTenants{"alice": st}, config)
+	defer srv.rateLimiter.Stop()
+
+	serve := func(method, target, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/events", "alice", `{"type":"A","data":{"secret":"s3cr3t"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/events?from=abc", "mallory", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/position", "alice", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	recentErrors := func(query string) []capturedError {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin/debug/recent-errors"+query, nil)
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Errors []capturedError `json:"errors"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Errors
+	}
+
+	captured := recentErrors("")
+	if len(captured) != 2 {
+		t.Fatalf("Expected 2 failed requests, got %+v", captured)
+	}
+
+	unauthorized, invalid := captured[0], captured[1]
+	if unauthorized.Status != http.StatusUnauthorized || unauthorized.Query != "from=abc" || unauthorized.Tenant != "" {
+		t.Errorf("Unexpected unauthorized entry: %+v", unauthorized)
+	}
+	if unauthorized.RequestHeaders["X-Api-Key"] != "[REDACTED]" {
+		t.Errorf("Expected the API key to be redacted, got %q", unauthorized.RequestHeaders["X-Api-Key"])
+	}
+
+	if invalid.Tenant != "alice" || invalid.Method != http.MethodPost || invalid.RequestID == "" {
+		t.Errorf("Unexpected invalid request entry: %+v", invalid)
+	}
+	if want := `{"type":"A","data":{"secret":"[REDACTED]"}`; invalid.RequestBody != want {
+		t.Errorf("Expected request body %s, got %s", want, invalid.RequestBody)
+	}
+	if !strings.Contains(invalid.ResponseBody, "Invalid") {
+		t.Errorf("Expected the decompressed error message, got %q", invalid.ResponseBody)
+	}
+
+	if got := recentErrors("?tenant=alice"); len(got) != 1 || got[0].Status != http.StatusBadRequest {
+		t.Errorf("Expected alice's failed request only, got %+v", got)
+	}
+}
+
+func TestRecentErrors_Disabled(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/capture.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	srv := NewWithStore(st, config, "test-key-123")
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/debug/recent-errors", nil)
+	req.Header.Set("X-Admin-Key", "admin-secret")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// maxDigestChunks bounds the ranges per /digest request
+const maxDigestChunks = 256
+
+// digestHandler returns digests of a position range split into ?chunks=
+// parts (default 1), so replicas can be compared without shipping events.
+// The range defaults to 1 through the current head.
+func digestHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	head, err := st.GetPosition(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to, chunks := int64(1), head, 1
+	if s := query.Get("from"); s != "" {
+		if from, err = strconv.ParseInt(s, 10, 64); err != nil || from < 1 {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("to"); s != "" {
+		if to, err = strconv.ParseInt(s, 10, 64); err != nil || to < from {
+			http.Error(w, "Invalid 'to' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("chunks"); s != "" {
+		if chunks, err = strconv.Atoi(s); err != nil || chunks < 1 || chunks > maxDigestChunks {
+			http.Error(w, fmt.Sprintf("Invalid 'chunks' parameter (1-%d)", maxDigestChunks), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var ranges []store.RangeDigest
+	if to >= from {
+		if ranges, err = store.DigestRanges(r.Context(), st, from, to, chunks); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to digest events: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"head":   head,
+		"ranges": ranges,
+	})
+}
+
+// repairHandler overwrites events at their positions with the ones posted,
+// for fixing a replica that anti-entropy found diverged. Repairs never
+// append, so the log's head is unchanged.
+func repairHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repairer, ok := store.As[store.Repairer](st)
+	if !ok {
+		http.Error(w, "Repair not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	var events []*store.StoredEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(events) > 1000 {
+		http.Error(w, "Batch size limited to 1000 events", http.StatusBadRequest)
+		return
+	}
+
+	if err := repairer.ReplaceEvents(r.Context(), events); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to repair events: %v", err), http.StatusBadRequest)
+		return
+	}
+	logger(r).Info("Events repaired", "audit", true, "events", len(events))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"repaired": len(events),
+	})
+}
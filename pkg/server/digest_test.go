@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestDigestAndRepair(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/digest.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 10)
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	srv := NewMultiTenant(namedTenants{"alice": st}, config)
+	defer srv.Close()
+
+	serve := func(method, target, body string, header, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(header, key)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+	digest := func() store.RangeDigest {
+		t.Helper()
+		rr := serve(http.MethodGet, "/digest?from=1&to=10&chunks=2", "", "X-API-Key", "alice")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var result struct {
+			Head   int64               `json:"head"`
+			Ranges []store.RangeDigest `json:"ranges"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Head != 10 || len(result.Ranges) != 2 || result.Ranges[1].From != 6 || result.Ranges[1].Count != 5 {
+			t.Fatalf("Unexpected digest: %+v", result)
+		}
+		return result.Ranges[1]
+	}
+
+	before := digest()
+
+	if rr := serve(http.MethodGet, "/digest?chunks=1000", "", "X-API-Key", "alice"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for too many chunks, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// Repairing is an admin operation
+	patch := `[{"position":7,"type":"Event","data":{"fixed":true},"timestamp":"2024-01-01T00:00:00Z"}]`
+	if rr := serve(http.MethodPost, "/admin/repair?tenant=alice", patch, "X-API-Key", "alice"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d without the admin key, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/admin/repair?tenant=alice", patch, "X-Admin-Key", "admin-secret"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if after := digest(); after.Hash == before.Hash {
+		t.Error("Expected the repaired range's digest to change")
+	}
+
+	// Repairs never extend the log
+	past := `[{"position":11,"type":"Event","data":{}}]`
+	if rr := serve(http.MethodPost, "/admin/repair?tenant=alice", past, "X-Admin-Key", "admin-secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for a position past the head, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
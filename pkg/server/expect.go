@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// errConditionalUnsupported is returned for expectations on a store that
+// cannot append conditionally
+var errConditionalUnsupported = errors.New("conditional appends not supported by this store")
+
+// errImportUnsupported is returned for positions=keep on a store that cannot
+// import events at explicit positions
+var errImportUnsupported = errors.New("importing at explicit positions not supported by this store")
+
+// parseExpectation reads the expected_position and expected_stream_version
+// query parameters of a write
+func parseExpectation(r *http.Request) (expect store.Expectation, set bool, err error) {
+	query := r.URL.Query()
+	parse := func(name string) (*int64, error) {
+		s := query.Get(name)
+		if s == "" {
+			return nil, nil
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 0 {
+			return nil, errors.New("Invalid '" + name + "' parameter")
+		}
+		set = true
+		return &v, nil
+	}
+
+	if expect.Position, err = parse("expected_position"); err != nil {
+		return expect, false, err
+	}
+	if expect.StreamVersion, err = parse("expected_stream_version"); err != nil {
+		return expect, false, err
+	}
+	return expect, set, nil
+}
+
+// saveIf appends events if expect holds
+func saveIf(ctx context.Context, st store.EventStore, events []*store.StoredEvent, expect store.Expectation) error {
+	appender, ok := store.As[store.ConditionalAppender](st)
+	if !ok {
+		return errConditionalUnsupported
+	}
+	return appender.SaveBatchIf(ctx, events, expect)
+}
+
+// keepPositions reports whether r asks for its events to be imported at the
+// positions they carry, as mirrors do, rather than appended
+func keepPositions(r *http.Request) bool {
+	return r.URL.Query().Get("positions") == "keep"
+}
+
+// importEvents saves events at their own positions, which must increase
+// past the head; gaps between them are kept
+func importEvents(ctx context.Context, st store.EventStore, events []*store.StoredEvent) error {
+	importer, ok := store.As[store.Importer](st)
+	if !ok {
+		return errImportUnsupported
+	}
+	return importer.ImportEvents(ctx, events)
+}
+
+// saveError answers a failed append: 409 when an expectation failed, 501
+// when the store cannot check it
+func saveError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errConditionalUnsupported), errors.Is(err, errImportUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
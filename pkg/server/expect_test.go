@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestExpectedVersions(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"new stream", "/events?expected_stream_version=0", `{"type":"Created","data":{},"stream_id":"orders/1"}`, http.StatusOK},
+		{"stale stream version", "/events?expected_stream_version=0", `{"type":"Paid","data":{},"stream_id":"orders/1"}`, http.StatusConflict},
+		{"batch at stream version", "/events/batch?expected_stream_version=1", `[{"type":"Paid","data":{},"stream_id":"orders/1"},{"type":"Shipped","data":{},"stream_id":"orders/1"}]`, http.StatusOK},
+		{"head position", "/events?expected_position=3", `{"type":"Tick","data":{}}`, http.StatusOK},
+		{"stale position", "/events/batch?expected_position=3", `[{"type":"Tick","data":{}}]`, http.StatusConflict},
+		{"both", "/events/batch?expected_position=4&expected_stream_version=3", `[{"type":"Closed","data":{},"stream_id":"orders/1"}]`, http.StatusOK},
+		{"invalid position", "/events?expected_position=x", `{"type":"Tick","data":{}}`, http.StatusBadRequest},
+		{"negative version", "/events?expected_stream_version=-1", `{"type":"Tick","data":{},"stream_id":"orders/1"}`, http.StatusBadRequest},
+		{"version without stream", "/events?expected_stream_version=0", `{"type":"Tick","data":{}}`, http.StatusBadRequest},
+		{"version across streams", "/events/batch?expected_stream_version=0", `[{"type":"A","data":{},"stream_id":"a"},{"type":"B","data":{},"stream_id":"b"}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.path, tt.body); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/position", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if body := rr.Body.String(); !strings.Contains(body, `"position":5`) {
+		t.Errorf("Expected rejected writes to save nothing, got %s", body)
+	}
+}
+
+func TestExpectedVersions_Unsupported(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/events?expected_position=0", strings.NewReader(`{"type":"A","data":{}}`))
+	rr := httptest.NewRecorder()
+	saveEventHandler(rr, req, plainStore{sqliteStore}, nil, nil, nil)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
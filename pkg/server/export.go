@@ -0,0 +1,328 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
+)
+
+// errExportDone stops a store stream once the export range is complete
+var errExportDone = errors.New("export done")
+
+// exportRange is a parsed Range header
+type exportRange struct {
+	unit       string // "bytes" or "events"
+	start, end int64  // inclusive; end is -1 when open
+}
+
+// parseExportRange parses a single "bytes=" or "events=" range. Multiple
+// ranges and unknown units are reported as !ok, so the full export is served.
+func parseExportRange(header string) (exportRange, bool) {
+	unit, spec, found := strings.Cut(header, "=")
+	if !found || (unit != "bytes" && unit != "events") || strings.Contains(spec, ",") {
+		return exportRange{}, false
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found || startStr == "" {
+		// Suffix ranges ("bytes=-500") need the total length up front
+		return exportRange{}, false
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return exportRange{}, false
+	}
+
+	end := int64(-1)
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return exportRange{}, false
+		}
+	}
+
+	return exportRange{unit: unit, start: start, end: end}, true
+}
+
+// exportHandler downloads events as NDJSON, one event per line. Range
+// requests are supported either by position ("Range: events=100-200") or
+// by byte offset ("Range: bytes=1048576-"), so interrupted downloads can be
+// resumed. Pruning or tiering can remove events from the start of an export,
+// so its ETag names the first and last position; a Range with an If-Range
+// that no longer matches gets the whole export. Byte ranges cost a pass to
+// size the export first, so resuming by position is cheaper.
+func exportHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, history *throttle.Throttle) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Large exports, and sizing them for byte ranges, outlast any write
+	// timeout
+	keepStreaming(w)
+
+	from := int64(1)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		var err error
+		if from, err = strconv.ParseInt(fromStr, 10, 64); err != nil {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pace, ok := historyJob(w, r, history, true)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	// Pin the end of the export so byte ranges and totals are stable
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+		return
+	}
+	to := head
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = strconv.ParseInt(toStr, 10, 64); err != nil {
+			http.Error(w, "Invalid 'to' parameter", http.StatusBadRequest)
+			return
+		}
+		to = min(to, head)
+	}
+
+	if !syncForRead(w, r, st, to) {
+		return
+	}
+
+	first, err := store.FirstPosition(ctx, st)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get first position: %v", err), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf(`"%d-%d"`, max(from, first), to)
+
+	w.Header().Set("Accept-Ranges", "bytes, events")
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("ETag", etag)
+
+	var out io.Writer = w
+	status := http.StatusOK
+
+	rangeHeader := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+		rangeHeader = ""
+	}
+	if rng, ok := parseExportRange(rangeHeader); ok {
+		switch rng.unit {
+		case "events":
+			if rng.start > to || (rng.end != -1 && rng.end < from) {
+				w.Header().Set("Content-Range", fmt.Sprintf("events */%d", head))
+				http.Error(w, "Range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			from = max(from, rng.start)
+			if rng.end != -1 {
+				to = min(to, rng.end)
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("events %d-%d/%d", from, to, head))
+
+		case "bytes":
+			// Size the export first; within the pinned range, the second
+			// pass produces the same bytes
+			var size countingWriter
+			if _, err := writeExport(ctx, st, from, to, &size, nil, pace); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to size export: %v", err), http.StatusInternalServerError)
+				return
+			}
+			total := int64(size)
+			if rng.start >= total {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+				http.Error(w, "Range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			end := total - 1
+			if rng.end != -1 {
+				end = min(end, rng.end)
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, end, total))
+			w.Header().Set("Content-Length", strconv.FormatInt(end-rng.start+1, 10))
+			out = &rangeWriter{w: w, skip: rng.start, remain: end - rng.start + 1}
+		}
+		status = http.StatusPartialContent
+	}
+
+	var sum *streamChecksum
+	if r.URL.Query().Get("checksum") == "true" {
+		sum = newStreamChecksum(w)
+		out = io.MultiWriter(out, sum)
+	}
+
+	w.WriteHeader(status)
+
+	flush := func() {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	count, err := writeExport(ctx, st, from, to, out, flush, pace)
+	if errors.Is(err, errRangeComplete) {
+		err = nil
+	}
+	if err != nil {
+		logger(r).Error("Export failed", "error", err)
+	}
+
+	if sum != nil {
+		sum.finish(w, count, err == nil)
+	}
+}
+
+// writeExport writes events in [from, to] as NDJSON and returns how many
+// were written; flush, if set, is called after every batch, and pace, if
+// set, caps the read rate
+func writeExport(ctx context.Context, st store.EventStore, from, to int64, out io.Writer, flush func(), pace *throttle.Throttle) (int, error) {
+	if from > to {
+		return 0, nil
+	}
+	if flush == nil {
+		flush = func() {}
+	}
+
+	const batchSize = 1000
+	count := 0
+	var err error
+
+	if rs, ok := store.As[store.RawStreamer](st); ok {
+		err = rs.LoadStreamRaw(ctx, from, batchSize, func(batch []json.RawMessage) error {
+			if err := pace.Pace(ctx, len(batch)); err != nil {
+				return err
+			}
+			for _, data := range batch {
+				position, err := rawPosition(data)
+				if err != nil {
+					return err
+				}
+				if position > to {
+					return errExportDone
+				}
+				if err := writeLine(out, data); err != nil {
+					return err
+				}
+				count++
+			}
+			flush()
+			return nil
+		})
+	} else {
+		err = st.LoadStream(ctx, from, batchSize, func(batch []*store.StoredEvent) error {
+			if err := pace.Pace(ctx, len(batch)); err != nil {
+				return err
+			}
+			for _, event := range batch {
+				if event.Position > to {
+					return errExportDone
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				if err := writeLine(out, data); err != nil {
+					return err
+				}
+				count++
+			}
+			flush()
+			return nil
+		})
+	}
+
+	if errors.Is(err, errExportDone) {
+		err = nil
+	}
+	return count, err
+}
+
+func writeLine(out io.Writer, data []byte) error {
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	_, err := out.Write([]byte("\n"))
+	return err
+}
+
+// rawPosition reads the position of an encoded event; stores encode it as
+// the first field, so the fast path avoids decoding the payload
+func rawPosition(data []byte) (int64, error) {
+	const prefix = `{"position":`
+	if rest, ok := bytes.CutPrefix(data, []byte(prefix)); ok {
+		if end := bytes.IndexByte(rest, ','); end > 0 {
+			if position, err := strconv.ParseInt(string(rest[:end]), 10, 64); err == nil {
+				return position, nil
+			}
+		}
+	}
+
+	var event struct {
+		Position int64 `json:"position"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return 0, fmt.Errorf("decode position: %w", err)
+	}
+	return event.Position, nil
+}
+
+// countingWriter counts bytes and discards them
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+// errRangeComplete stops the export once a byte range has been written
+var errRangeComplete = errors.New("range complete")
+
+// rangeWriter passes through only the bytes of [skip, skip+remain)
+type rangeWriter struct {
+	w      io.Writer
+	skip   int64
+	remain int64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if rw.skip > 0 {
+		if int64(len(p)) <= rw.skip {
+			rw.skip -= int64(len(p))
+			return n, nil
+		}
+		p = p[rw.skip:]
+		rw.skip = 0
+	}
+
+	if rw.remain <= 0 {
+		return 0, errRangeComplete
+	}
+	if int64(len(p)) > rw.remain {
+		p = p[:rw.remain]
+	}
+	if _, err := rw.w.Write(p); err != nil {
+		return 0, err
+	}
+	rw.remain -= int64(len(p))
+	return n, nil
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestParseExportRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   exportRange
+		ok     bool
+	}{
+		{"bytes=100-", exportRange{"bytes", 100, -1}, true},
+		{"bytes=0-99", exportRange{"bytes", 0, 99}, true},
+		{"events=5-10", exportRange{"events", 5, 10}, true},
+		{"events=5-", exportRange{"events", 5, -1}, true},
+		{"bytes=-500", exportRange{}, false},
+		{"bytes=0-1,5-6", exportRange{}, false},
+		{"bytes=10-5", exportRange{}, false},
+		{"lines=1-2", exportRange{}, false},
+		{"", exportRange{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseExportRange(tt.header)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseExportRange(%q) = %+v, %v; want %+v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/export.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		sqliteStore.Save(ctx, &store.StoredEvent{
+			Type:      "TestEvent",
+			Data:      json.RawMessage(fmt.Sprintf(`{"index":%d}`, i)),
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Both the raw and the decoding stream paths must produce the same bytes
+	stores := map[string]store.EventStore{
+		"raw":    sqliteStore,
+		"decode": plainStore{sqliteStore},
+	}
+
+	for name, st := range stores {
+		t.Run(name, func(t *testing.T) {
+			export := func(target, rangeHeader string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				if rangeHeader != "" {
+					req.Header.Set("Range", rangeHeader)
+				}
+				rr := httptest.NewRecorder()
+				exportHandler(rr, req, st, nil)
+				return rr
+			}
+
+			full := export("/events/export", "")
+			if full.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, full.Code)
+			}
+			if got := full.Header().Get("Accept-Ranges"); got != "bytes, events" {
+				t.Errorf("Unexpected Accept-Ranges %q", got)
+			}
+			lines := strings.Split(strings.TrimSuffix(full.Body.String(), "\n"), "\n")
+			if len(lines) != 10 {
+				t.Fatalf("Expected 10 lines, got %d", len(lines))
+			}
+			var event store.StoredEvent
+			if err := json.Unmarshal([]byte(lines[9]), &event); err != nil || event.Position != 10 {
+				t.Errorf("Unexpected last line %q (%v)", lines[9], err)
+			}
+			body := full.Body.Bytes()
+
+			// Resume an interrupted download
+			offset := len(body) / 3
+			rr := export("/events/export", fmt.Sprintf("bytes=%d-", offset))
+			if rr.Code != http.StatusPartialContent {
+				t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, rr.Code)
+			}
+			if !bytes.Equal(rr.Body.Bytes(), body[offset:]) {
+				t.Error("Resumed bytes do not match the full export")
+			}
+			want := fmt.Sprintf("bytes %d-%d/%d", offset, len(body)-1, len(body))
+			if got := rr.Header().Get("Content-Range"); got != want {
+				t.Errorf("Expected Content-Range %q, got %q", want, got)
+			}
+
+			// Bounded byte range
+			rr = export("/events/export", "bytes=10-19")
+			if !bytes.Equal(rr.Body.Bytes(), body[10:20]) {
+				t.Errorf("Expected bytes 10-19, got %q", rr.Body.String())
+			}
+
+			// Position range
+			rr = export("/events/export", "events=3-5")
+			if rr.Code != http.StatusPartialContent {
+				t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Range"); got != "events 3-5/10" {
+				t.Errorf("Unexpected Content-Range %q", got)
+			}
+			if want := strings.Join(lines[2:5], "\n") + "\n"; rr.Body.String() != want {
+				t.Errorf("Expected events 3-5, got %q", rr.Body.String())
+			}
+
+			// Unsatisfiable ranges
+			if rr := export("/events/export", fmt.Sprintf("bytes=%d-", len(body))); rr.Code != http.StatusRequestedRangeNotSatisfiable {
+				t.Errorf("Expected status %d past the end, got %d", http.StatusRequestedRangeNotSatisfiable, rr.Code)
+			}
+			if rr := export("/events/export", "events=11-"); rr.Code != http.StatusRequestedRangeNotSatisfiable {
+				t.Errorf("Expected status %d past the head, got %d", http.StatusRequestedRangeNotSatisfiable, rr.Code)
+			}
+
+			// from/to query parameters
+			rr = export("/events/export?from=9&to=9", "")
+			if rr.Body.String() != lines[8]+"\n" {
+				t.Errorf("Expected event 9 only, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestExport_IfRange(t *testing.T) {
+	st := store.NewMemoryStore()
+	saveTestEvents(t, st, 10)
+
+	export := func(target, rangeHeader, ifRange string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Range", rangeHeader)
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		}
+		rr := httptest.NewRecorder()
+		exportHandler(rr, req, st, nil)
+		return rr
+	}
+
+	full := export("/events/export?to=10", "", "")
+	etag := full.Header().Get("ETag")
+	if etag != `"1-10"` {
+		t.Fatalf("Expected ETag \"1-10\", got %q", etag)
+	}
+	if rr := export("/events/export?to=10", "bytes=100-", etag); rr.Code != http.StatusPartialContent || !bytes.Equal(rr.Body.Bytes(), full.Body.Bytes()[100:]) {
+		t.Errorf("Expected the rest of the export for a matching If-Range, got %d", rr.Code)
+	}
+
+	// Once retention removed the start, the bytes at an offset are others
+	if _, err := st.DeleteBefore(context.Background(), 4); err != nil {
+		t.Fatalf("DeleteBefore failed: %v", err)
+	}
+	rr := export("/events/export?to=10", "bytes=100-", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"4-10"` {
+		t.Fatalf("Expected the whole export with a new ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if lines := strings.Count(rr.Body.String(), "\n"); lines != 7 {
+		t.Errorf("Expected 7 events, got %d", lines)
+	}
+}
+
+// slowStore takes delay for every stream it loads, like a large store
+type slowStore struct {
+	store.EventStore
+	delay time.Duration
+}
+
+func (s slowStore) LoadStream(ctx context.Context, from int64, batchSize int, fn func([]*store.StoredEvent) error) error {
+	time.Sleep(s.delay)
+	return s.EventStore.LoadStream(ctx, from, batchSize, fn)
+}
+
+func TestExport_OutlivesWriteTimeout(t *testing.T) {
+	st := store.NewMemoryStore()
+	saveTestEvents(t, st, 10)
+
+	config := DefaultConfig()
+	config.DebugCapture = 10
+	srv := NewWithStore(slowStore{st, 300 * time.Millisecond}, config, "test-key-123")
+	defer srv.Close()
+	ts := httptest.NewUnstartedServer(srv)
+	ts.Config.WriteTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	// A byte range is sized before the first byte is sent
+	for _, rangeHeader := range []string{"", "bytes=10-"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events/export", nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%q: export cut off: %v", rangeHeader, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%q: export cut off after %d bytes: %v", rangeHeader, len(body), err)
+		}
+		if lines := bytes.Count(body, []byte("\n")); lines < 9 {
+			t.Errorf("%q: expected the whole export, got %d lines", rangeHeader, lines)
+		}
+	}
+}
+
+func TestExportRoute(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/events/export", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Unexpected Content-Type %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// localBroker connects the replicas of a test
+type localBroker struct {
+	mu   sync.Mutex
+	subs []func(string)
+}
+
+func (b *localBroker) Publish(ctx context.Context, messages []string) error {
+	b.mu.Lock()
+	subs := append([]func(string){}, b.subs...)
+	b.mu.Unlock()
+	for _, message := range messages {
+		for _, fn := range subs {
+			fn(message)
+		}
+	}
+	return nil
+}
+
+func (b *localBroker) Subscribe(ctx context.Context, fn func(string)) error {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAppendFanout(t *testing.T) {
+	// Two replicas on one shared store, as with Postgres
+	st := store.NewMemoryStore()
+	broker := &localBroker{}
+	config := DefaultConfig()
+	config.AppendBroker = broker
+	writer := NewWithStore(st, config, "test-key-123")
+	defer writer.Close()
+	reader := NewWithStore(st, config, "test-key-123")
+	defer reader.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		broker.mu.Lock()
+		subscribed := len(broker.subs)
+		broker.mu.Unlock()
+		if subscribed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Replicas did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	appended := reader.appends.Wait("default")
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"OrderPlaced","data":{}}`))
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	writer.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Save failed: %d %s", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case <-appended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the write to wake streams on the other replica")
+	}
+}
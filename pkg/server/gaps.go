@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// maxGapsListed bounds the gaps returned per /stats/gaps request
+const maxGapsListed = 10000
+
+// gapsHandler serves GET /stats/gaps: ranges of missing positions between
+// ?from= (default the oldest event) and ?to= (default the head), up to
+// ?limit= (default 100). Positions before the oldest event were pruned or
+// skipped by a start position and are not reported. Consumers assume dense
+// positions, so any gap deserves a look.
+func gapsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	head, err := st.GetPosition(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	first, err := store.FirstPosition(r.Context(), st)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get first position: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to, limit := max(first, 1), head, 100
+	if s := query.Get("from"); s != "" {
+		if from, err = strconv.ParseInt(s, 10, 64); err != nil || from < 1 {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("to"); s != "" {
+		if to, err = strconv.ParseInt(s, 10, 64); err != nil || to < 1 || query.Has("from") && to < from {
+			http.Error(w, "Invalid 'to' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxGapsListed {
+			http.Error(w, fmt.Sprintf("Invalid 'limit' parameter (1-%d)", maxGapsListed), http.StatusBadRequest)
+			return
+		}
+	}
+
+	gaps := []store.Gap{}
+	if to >= from {
+		found, err := store.FindGaps(r.Context(), st, from, to, limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to find gaps: %v", err), http.StatusInternalServerError)
+			return
+		}
+		gaps = append(gaps, found...)
+	}
+
+	var missing int64
+	for _, gap := range gaps {
+		missing += gap.To - gap.From + 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"head":              head,
+		"from":              from,
+		"to":                to,
+		"gaps":              gaps,
+		"missing_positions": missing,
+		"truncated":         len(gaps) == limit,
+	})
+}
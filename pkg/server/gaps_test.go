@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestGapsEndpoint(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	var imported []*store.StoredEvent
+	for _, position := range []int64{1, 2, 5, 6, 9} {
+		imported = append(imported, &store.StoredEvent{Position: position, Type: "A", Data: json.RawMessage(`{}`)})
+	}
+	if err := sqliteStore.ImportEvents(context.Background(), imported); err != nil {
+		t.Fatalf("ImportEvents failed: %v", err)
+	}
+
+	srv := NewWithStore(sqliteStore, DefaultConfig(), "test-key-123")
+	defer srv.rateLimiter.Stop()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/stats/gaps")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Head      int64       `json:"head"`
+		Gaps      []store.Gap `json:"gaps"`
+		Missing   int64       `json:"missing_positions"`
+		Truncated bool        `json:"truncated"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := []store.Gap{{From: 3, To: 4}, {From: 7, To: 8}}; result.Head != 9 || !reflect.DeepEqual(result.Gaps, want) || result.Missing != 4 || result.Truncated {
+		t.Errorf("Expected gaps %v with 4 missing positions, got %+v", want, result)
+	}
+
+	result.Gaps = nil
+	if err := json.NewDecoder(get("/stats/gaps?limit=1").Body).Decode(&result); err != nil || len(result.Gaps) != 1 || !result.Truncated {
+		t.Errorf("Expected 1 gap and truncated with limit=1, got %+v, %v", result, err)
+	}
+	for _, path := range []string{"/stats/gaps?from=0", "/stats/gaps?from=5&to=2", "/stats/gaps?limit=0"} {
+		if rr := get(path); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+
+	// Pruned positions are not gaps
+	if _, err := sqliteStore.DeleteBefore(context.Background(), 6); err != nil {
+		t.Fatalf("DeleteBefore failed: %v", err)
+	}
+	result.Gaps = nil
+	if err := json.NewDecoder(get("/stats/gaps").Body).Decode(&result); err != nil || !reflect.DeepEqual(result.Gaps, []store.Gap{{From: 7, To: 8}}) {
+		t.Errorf("Expected only the gap after the pruned positions, got %+v, %v", result, err)
+	}
+
+	var metrics struct {
+		Gaps store.GapStats `json:"position_gaps"`
+	}
+	if err := json.NewDecoder(get("/metrics").Body).Decode(&metrics); err != nil {
+		t.Fatalf("Failed to decode metrics: %v", err)
+	}
+	if metrics.Gaps.Detected != 2 || metrics.Gaps.Missing != 4 {
+		t.Errorf("Expected 2 detected gaps in metrics, got %+v", metrics.Gaps)
+	}
+}
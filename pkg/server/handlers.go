@@ -3,47 +3,112 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"maps"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/pipeline"
 	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
 )
 
 // Shared handler implementations used by both single-tenant and multi-tenant servers
 
-func saveEventHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+func saveEventHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, p *pipeline.Pipeline, stats *typeStats, appends *fanout.Hub) {
+	trace := startTrace(r)
+
+	expect, conditional, err := parseExpectation(r)
+	if err != nil {
+		trace.stage("rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var event store.StoredEvent
 	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		trace.stage("rejected", "error", err)
 		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
+	if err := store.ValidateStreamID(event.StreamID); err != nil {
+		trace.stage("rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := expect.Validate([]*store.StoredEvent{&event}); err != nil {
+		trace.stage("rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := pipelineRequest(r)
+	applyRequestMetadata(r.Context(), &event)
+	stampTimestamp(&event, req.Time)
+	if err := applyPipeline(logger(r), p, &event, req); err != nil {
+		trace.stage("rejected", "error", err)
+		pipelineError(w, err)
+		return
+	}
+	trace.stage("validated", "type", event.Type, "data_bytes", len(event.Data))
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := st.Save(ctx, &event); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to save event: %v", err), http.StatusInternalServerError)
+	if conditional {
+		err = saveIf(ctx, st, []*store.StoredEvent{&event}, expect)
+	} else {
+		err = st.Save(ctx, &event)
+	}
+	if err != nil {
+		trace.stage("store", "error", err)
+		saveError(w, "Failed to save event", err)
 		return
 	}
+	trace.stage("store", "position", event.Position)
+	stats.record(req.Tenant, []*store.StoredEvent{&event}, req.Time)
+	appends.Notify(req.Tenant)
+	trace.sync(ctx, st, event.Position)
+	trace.finish(w)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(event)
 }
 
+// stampTimestamp gives an event sent without a timestamp the time the
+// server received it, so time ranges and retention see it at its age
+func stampTimestamp(event *store.StoredEvent, received time.Time) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = received.UTC()
+	}
+}
+
 func loadEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
 	fromStr := r.URL.Query().Get("from")
 	toStr := r.URL.Query().Get("to")
 
-	from, err := strconv.ParseInt(fromStr, 10, 64)
+	since, until, timed, err := parseTimeRange(r)
 	if err != nil {
-		http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Time ranges start at the first event unless from narrows them
+	var from int64
+	if fromStr != "" || !timed {
+		from, err = strconv.ParseInt(fromStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
 	to := int64(-1)
 	if toStr != "" {
 		to, err = strconv.ParseInt(toStr, 10, 64)
@@ -53,53 +118,149 @@ func loadEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventSto
 		}
 	}
 
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !filter.Empty() && timed {
+		http.Error(w, "Type and time filters cannot be combined", http.StatusBadRequest)
+		return
+	}
+	if !filterIndex(w, st, filter) {
+		return
+	}
+
+	// Open ranges stay open so filters still return up to MaxLoadEvents
+	// matches; events past as_of are dropped after loading
+	asOf, ok := asOfPosition(w, r, st)
+	if !ok {
+		return
+	}
+	if asOf >= 0 && to != -1 {
+		to = min(to, asOf)
+	}
+
+	if !syncForRead(w, r, st, to) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	events, err := st.Load(ctx, from, to)
+	var events []*store.StoredEvent
+	switch {
+	case !filter.Empty():
+		events, err = store.LoadFiltered(ctx, st, filter, from, to)
+	case timed:
+		events, err = st.LoadByTime(ctx, since, until, from, to)
+	default:
+		events, err = st.Load(ctx, from, to)
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to load events: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if asOf >= 0 {
+		events = truncateAsOf(events, asOf)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
 }
 
-func batchEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+func batchEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, p *pipeline.Pipeline, stats *typeStats, appends *fanout.Hub) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	trace := startTrace(r)
+
+	expect, conditional, err := parseExpectation(r)
+	if err != nil {
+		trace.stage("rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	keep := keepPositions(r)
+	if keep && conditional {
+		http.Error(w, "Expectations cannot be combined with positions=keep", http.StatusBadRequest)
+		return
+	}
+
 	var events []*store.StoredEvent
 	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		trace.stage("rejected", "error", err)
 		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	if len(events) > 1000 {
+		trace.stage("rejected", "events", len(events))
 		http.Error(w, "Batch size limited to 1000 events", http.StatusBadRequest)
 		return
 	}
 
+	req := pipelineRequest(r)
+	for i, event := range events {
+		if err := store.ValidateStreamID(event.StreamID); err != nil {
+			trace.stage("rejected", "index", i, "error", err)
+			http.Error(w, fmt.Sprintf("event %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		applyRequestMetadata(r.Context(), event)
+		stampTimestamp(event, req.Time)
+		if err := applyPipeline(logger(r), p, event, req); err != nil {
+			trace.stage("rejected", "index", i, "error", err)
+			pipelineError(w, fmt.Errorf("event %d: %w", i, err))
+			return
+		}
+	}
+	if err := expect.Validate(events); err != nil {
+		trace.stage("rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	trace.stage("validated", "events", len(events))
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	if err := st.SaveBatch(ctx, events); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to save batch: %v", err), http.StatusInternalServerError)
+	switch {
+	case keep:
+		err = importEvents(ctx, st, events)
+	case conditional:
+		err = saveIf(ctx, st, events, expect)
+	default:
+		err = st.SaveBatch(ctx, events)
+	}
+	if err != nil {
+		trace.stage("store", "error", err)
+		saveError(w, "Failed to save batch", err)
 		return
 	}
-
+	stats.record(req.Tenant, events, req.Time)
+	appends.Notify(req.Tenant)
+	if len(events) > 0 {
+		last := events[len(events)-1].Position
+		trace.stage("store", "first_position", events[0].Position, "last_position", last)
+		trace.sync(ctx, st, last)
+	}
+	trace.finish(w)
+
+	// Batches get a contiguous range, so clients derive each position from
+	// the first
+	response := map[string]any{"saved": len(events)}
+	if len(events) > 0 {
+		response["first_position"] = events[0].Position
+		response["last_position"] = events[len(events)-1].Position
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"saved":          len(events),
-		"first_position": events[0].Position,
-		"last_position":  events[len(events)-1].Position,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
-func streamEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+func streamEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, history *throttle.Throttle) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -122,39 +283,134 @@ func streamEventsHandler(w http.ResponseWriter, r *http.Request, st store.EventS
 		}
 	}
 
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !filterIndex(w, st, filter) {
+		return
+	}
+
+	asOf, ok := asOfPosition(w, r, st)
+	if !ok {
+		return
+	}
+
+	pace, ok := historyJob(w, r, history, false)
+	if !ok {
+		return
+	}
+
+	if !syncForRead(w, r, st, asOf) {
+		return
+	}
+
 	ctx := r.Context()
 
-	w.Header().Set("Content-Type", "application/json")
+	ndjson := wantsNDJSON(r)
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.Header().Set("Transfer-Encoding", "chunked")
 
-	w.Write([]byte("["))
+	// Everything written to out is covered by the optional checksum trailer
+	var out io.Writer = w
+	var sum *streamChecksum
+	if r.URL.Query().Get("checksum") == "true" {
+		sum = newStreamChecksum(w)
+		out = io.MultiWriter(w, sum)
+	}
+
+	// A JSON array, or one event per line for NDJSON
+	if !ndjson {
+		out.Write([]byte("["))
+	}
 	first := true
+	count := 0
+	write := func(data []byte) {
+		if ndjson {
+			out.Write(data)
+			out.Write([]byte("\n"))
+			return
+		}
+		if !first {
+			out.Write([]byte(","))
+		}
+		first = false
+		out.Write(data)
+	}
 
-	err = st.LoadStream(ctx, from, batchSize, func(batch []*store.StoredEvent) error {
-		for _, event := range batch {
-			if !first {
-				w.Write([]byte(","))
-			}
-			first = false
+	load := st.LoadStream
+	if !filter.Empty() {
+		load = func(ctx context.Context, from int64, batchSize int, handler func([]*store.StoredEvent) error) error {
+			return store.StreamFiltered(ctx, st, filter, from, batchSize, handler)
+		}
+	}
 
-			data, err := json.Marshal(event)
-			if err != nil {
+	// Stores that keep events as JSON can skip the decode/re-encode per
+	// event, unless as_of needs their positions
+	if rs, ok := store.As[store.RawStreamer](st); ok && filter.Empty() && asOf < 0 {
+		err = rs.LoadStreamRaw(ctx, from, batchSize, func(batch []json.RawMessage) error {
+			if err := pace.Pace(ctx, len(batch)); err != nil {
 				return err
 			}
-			w.Write(data)
+			for _, data := range batch {
+				write(data)
+			}
+			count += len(batch)
 
 			if flusher, ok := w.(http.Flusher); ok {
 				flusher.Flush()
 			}
-		}
-		return nil
-	})
+			return nil
+		})
+	} else {
+		err = load(ctx, from, batchSize, func(batch []*store.StoredEvent) error {
+			if err := pace.Pace(ctx, len(batch)); err != nil {
+				return err
+			}
+			for _, event := range batch {
+				if asOf >= 0 && event.Position > asOf {
+					return errAsOfReached
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				write(data)
+				count++
+
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
+			}
+			return nil
+		})
+	}
 
+	if errors.Is(err, errAsOfReached) {
+		err = nil
+	}
 	if err != nil {
-		log.Printf("Stream error: %v", err)
+		logger(r).Error("Stream failed", "error", err)
 	}
 
-	w.Write([]byte("]"))
+	if !ndjson {
+		out.Write([]byte("]"))
+	}
+
+	if sum != nil {
+		sum.finish(w, count, err == nil)
+	}
+}
+
+// wantsNDJSON reports whether a stream should be sent as NDJSON, one event
+// per line, rather than as a JSON array
+func wantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
 }
 
 func positionHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
@@ -177,6 +433,11 @@ func positionHandler(w http.ResponseWriter, r *http.Request, st store.EventStore
 }
 
 func subscriptionsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.URL.Path == "/subscriptions" {
+		checkpointsHandler(w, r, st)
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/subscriptions/")
 	parts := strings.Split(path, "/")
 
@@ -197,6 +458,58 @@ func subscriptionsHandler(w http.ResponseWriter, r *http.Request, st store.Event
 	}
 }
 
+// checkpointsHandler exports (GET) and imports (POST, PUT) the positions of
+// all subscriptions as {"subscriptions": {"id": position, ...}}, so consumer
+// checkpoints can follow the events to another store. Imports overwrite the
+// positions of the subscriptions they name and leave others alone.
+func checkpointsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		lister, ok := store.As[store.SubscriptionLister](st)
+		if !ok {
+			http.Error(w, "Store cannot list subscriptions", http.StatusNotImplemented)
+			return
+		}
+		positions, err := lister.Subscriptions(ctx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list subscriptions: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"subscriptions": positions})
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Subscriptions map[string]int64 `json:"subscriptions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		for id, position := range req.Subscriptions {
+			if id == "" || strings.Contains(id, "/") || position < 0 {
+				http.Error(w, fmt.Sprintf("Invalid subscription %q at position %d", id, position), http.StatusBadRequest)
+				return
+			}
+		}
+		for _, id := range slices.Sorted(maps.Keys(req.Subscriptions)) {
+			if err := st.SaveSubscriptionPosition(ctx, id, req.Subscriptions[id]); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to save subscription position of %s: %v", id, err), http.StatusInternalServerError)
+				return
+			}
+		}
+		logger(r).Info("Subscription positions imported", "audit", true, "subscriptions", len(req.Subscriptions))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"imported": len(req.Subscriptions)})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func saveSubscriptionPositionHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, subscriptionID string) {
 	var req struct {
 		Position int64 `json:"position"`
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/throttle"
+)
+
+// JobHeader and JobReplay mark an /events/stream request as a replay job,
+// which Config.HistoryThrottle keeps within its window and rate cap.
+// Exports from /events/export are always jobs.
+const (
+	JobHeader = "X-Ebuse-Job"
+	JobReplay = "replay"
+)
+
+// historyJob returns the throttle that paces the request, nil if it is not
+// a job. Jobs outside the window are answered with 503 and a Retry-After
+// of when it opens, and false is returned; jobs started within the window
+// run to completion.
+func historyJob(w http.ResponseWriter, r *http.Request, history *throttle.Throttle, always bool) (*throttle.Throttle, bool) {
+	if history == nil || !always && !strings.EqualFold(r.Header.Get(JobHeader), JobReplay) {
+		return nil, true
+	}
+	if closed := history.Closed(); closed > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(closed.Seconds()))))
+		http.Error(w, fmt.Sprintf("History jobs run only within %s", history.Window()), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return history, true
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
+)
+
+// windowFrom returns a window from now+start to now+end
+func windowFrom(t *testing.T, start, end time.Duration) *throttle.Window {
+	t.Helper()
+	now := time.Now()
+	w, err := throttle.ParseWindow(now.Add(start).Format("15:04")+"-"+now.Add(end).Format("15:04"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestHistoryJobs(t *testing.T) {
+	st := store.NewMemoryStore()
+	saveTestEvents(t, st, 5)
+
+	serve := func(history *throttle.Throttle, target string, job bool) *httptest.ResponseRecorder {
+		config := DefaultConfig()
+		config.HistoryThrottle = history
+		srv := NewWithStore(st, config, "test-key-123")
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		if job {
+			req.Header.Set(JobHeader, JobReplay)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	closed := throttle.New(windowFrom(t, time.Hour, 2*time.Hour), 1000)
+	rr := serve(closed, "/events/export", false)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("export outside the window: status %d, want 503", rr.Code)
+	}
+	if retry, _ := strconv.Atoi(rr.Header().Get("Retry-After")); retry < 3500 || retry > 3660 {
+		t.Errorf("Retry-After = %q, want about an hour", rr.Header().Get("Retry-After"))
+	}
+	if rr := serve(closed, "/events/stream?from=1", true); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("replay job outside the window: status %d, want 503", rr.Code)
+	}
+	// Streams that are not marked as jobs are regular traffic
+	if rr := serve(closed, "/events/stream?from=1", false); rr.Code != http.StatusOK {
+		t.Errorf("plain stream outside the window: status %d, want 200", rr.Code)
+	}
+
+	open := throttle.New(windowFrom(t, -time.Hour, time.Hour), 1000)
+	if rr := serve(open, "/events/export", false); rr.Code != http.StatusOK {
+		t.Errorf("export within the window: status %d, want 200", rr.Code)
+	}
+	if rr := serve(open, "/events/stream?from=1", true); rr.Code != http.StatusOK {
+		t.Errorf("replay job within the window: status %d, want 200", rr.Code)
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/staging"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Staged import lifetimes and limits
+const (
+	importIdleTTL       = 24 * time.Hour // Imports not staged to for this long are discarded
+	maxImports          = 10
+	maxImportChunkBytes = 64 << 20
+)
+
+// errTooManyImports is returned when maxImports are open
+var errTooManyImports = errors.New("too many staged imports")
+
+// stagedImport is an import being staged for a tenant at /admin/imports
+type stagedImport struct {
+	ID        string         `json:"id"`
+	Tenant    string         `json:"tenant"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Status    staging.Status `json:"status"`
+
+	area  *staging.Area
+	timer *time.Timer
+}
+
+// stagedImports holds the open imports of a server. Staging areas live in
+// subdirectories of dir, which is emptied on first use since imports do not
+// survive restarts.
+type stagedImports struct {
+	dir  string // Empty = a temporary directory
+	mu   sync.Mutex
+	byID map[string]*stagedImport
+	once sync.Once
+	err  error // From preparing dir
+}
+
+func newStagedImports(dir string) *stagedImports {
+	return &stagedImports{dir: dir, byID: make(map[string]*stagedImport)}
+}
+
+// prepare removes staging areas left behind by an earlier process and
+// creates dir
+func (si *stagedImports) prepare() error {
+	si.once.Do(func() {
+		if si.dir == "" {
+			si.dir, si.err = os.MkdirTemp("", "ebuse-imports-")
+			return
+		}
+		if si.err = os.RemoveAll(si.dir); si.err == nil {
+			si.err = os.MkdirAll(si.dir, 0700)
+		}
+	})
+	return si.err
+}
+
+// create opens an empty import for tenant
+func (si *stagedImports) create(tenant string) (*stagedImport, error) {
+	if err := si.prepare(); err != nil {
+		return nil, fmt.Errorf("prepare staging directory: %w", err)
+	}
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	if len(si.byID) >= maxImports {
+		return nil, errTooManyImports
+	}
+
+	var raw [4]byte
+	rand.Read(raw[:])
+	imp := &stagedImport{ID: tenant + "-import-" + hex.EncodeToString(raw[:]), Tenant: tenant}
+	area, err := staging.New(filepath.Join(si.dir, imp.ID))
+	if err != nil {
+		return nil, err
+	}
+	imp.area = area
+	imp.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	imp.ExpiresAt = imp.CreatedAt.Add(importIdleTTL)
+	imp.Status = area.Status()
+	si.byID[imp.ID] = imp
+	imp.timer = time.AfterFunc(importIdleTTL, func() {
+		if si.remove(imp.ID) {
+			slog.Info("Staged import expired", "audit", true, "import", imp.ID)
+		}
+	})
+	return imp, nil
+}
+
+// get returns the import with id
+func (si *stagedImports) get(id string) (*stagedImport, bool) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	imp, ok := si.byID[id]
+	return imp, ok
+}
+
+// view returns a copy of imp safe to encode
+func (si *stagedImports) view(imp *stagedImport) stagedImport {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return *imp
+}
+
+// touch records the import's status after staging and pushes its expiry
+// back, returning a copy safe to encode
+func (si *stagedImports) touch(imp *stagedImport, status staging.Status) stagedImport {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	imp.Status = status
+	imp.ExpiresAt = time.Now().UTC().Truncate(time.Second).Add(importIdleTTL)
+	imp.timer.Reset(importIdleTTL)
+	return *imp
+}
+
+// list returns copies of the open imports by ID
+func (si *stagedImports) list() []stagedImport {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	list := make([]stagedImport, 0, len(si.byID))
+	for _, imp := range si.byID {
+		list = append(list, *imp)
+	}
+	slices.SortFunc(list, func(a, b stagedImport) int { return strings.Compare(a.ID, b.ID) })
+	return list
+}
+
+// remove discards an import and its staged events
+func (si *stagedImports) remove(id string) bool {
+	si.mu.Lock()
+	imp, ok := si.byID[id]
+	if ok {
+		delete(si.byID, id)
+		imp.timer.Stop()
+	}
+	si.mu.Unlock()
+	if !ok {
+		return false
+	}
+	imp.area.Close()
+	return true
+}
+
+// close removes all imports
+func (si *stagedImports) close() {
+	for _, imp := range si.list() {
+		si.remove(imp.ID)
+	}
+	if si.dir != "" && si.err == nil {
+		os.RemoveAll(si.dir)
+	}
+}
+
+// importsHandler serves two-phase imports under /admin/imports. POST
+// creates an import for the tenant in the body, POST .../{id}/events stages
+// an NDJSON chunk (optionally with its CRC-32C in X-Ebuse-Checksum), and
+// POST .../{id}/publish imports everything staged into the tenant's store
+// at once, optionally checked against ?count= and ?checksum=. GET reports
+// imports and DELETE discards one. lookup finds a tenant's store, writing
+// the error response if there is none.
+func importsHandler(w http.ResponseWriter, r *http.Request, imports *stagedImports, lookup func(http.ResponseWriter, string) (store.EventStore, bool)) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/imports"), "/"), "/")
+
+	var imp *stagedImport
+	if id != "" {
+		var ok bool
+		if imp, ok = imports.get(id); !ok {
+			http.Error(w, "Import not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"imports": imports.list()})
+
+	case id == "" && r.Method == http.MethodPost:
+		var req struct {
+			Tenant string `json:"tenant"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, ok := lookup(w, req.Tenant); !ok {
+			return
+		}
+		if req.Tenant == "" {
+			req.Tenant = "default"
+		}
+
+		imp, err := imports.create(req.Tenant)
+		switch {
+		case errors.Is(err, errTooManyImports):
+			http.Error(w, fmt.Sprintf("Too many staged imports (max %d), publish or delete one first", maxImports), http.StatusConflict)
+			return
+		case err != nil:
+			logger(r).Error("Failed to create staged import", "tenant", req.Tenant, "error", err)
+			http.Error(w, "Failed to create staging area", http.StatusInternalServerError)
+			return
+		}
+		logger(r).Info("Staged import created", "import", imp.ID, "tenant", imp.Tenant)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(imports.view(imp))
+
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(imports.view(imp))
+
+	case action == "events" && r.Method == http.MethodPost:
+		chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportChunkBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Chunk larger than %d bytes, split it", maxImportChunkBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read chunk: %v", err), http.StatusBadRequest)
+			return
+		}
+		status, err := imp.area.Stage(r.Context(), chunk, r.Header.Get(ChecksumTrailer))
+		if err != nil {
+			importError(w, r, imp, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(imports.touch(imp, status))
+
+	case action == "publish" && r.Method == http.MethodPost:
+		var expect staging.Expect
+		if count := r.URL.Query().Get("count"); count != "" {
+			n, err := strconv.ParseInt(count, 10, 64)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid count parameter", http.StatusBadRequest)
+				return
+			}
+			expect.Events = n
+		}
+		expect.Checksum = strings.ToLower(r.URL.Query().Get("checksum"))
+
+		tenantStore, ok := lookup(w, imp.Tenant)
+		if !ok {
+			return
+		}
+		status, err := imp.area.Publish(r.Context(), tenantStore, expect)
+		if err != nil {
+			importError(w, r, imp, err)
+			return
+		}
+		imports.remove(imp.ID)
+		logger(r).Info("Staged import published", "audit", true, "import", imp.ID, "tenant", imp.Tenant,
+			"events", status.Events, "from", status.First, "to", status.Last, "checksum", status.Checksum)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"id": imp.ID, "tenant": imp.Tenant, "published": status})
+
+	case action == "" && r.Method == http.MethodDelete:
+		if !imports.remove(imp.ID) {
+			http.Error(w, "Import not found", http.StatusNotFound)
+			return
+		}
+		logger(r).Info("Staged import deleted", "audit", true, "import", imp.ID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// importError answers a failed stage or publish: 400 for rejected chunks
+// or staged histories, 409 when the store has moved past them, 404 when the
+// import was discarded meanwhile
+func importError(w http.ResponseWriter, r *http.Request, imp *stagedImport, err error) {
+	switch {
+	case errors.Is(err, staging.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, staging.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, store.ErrImportTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, staging.ErrClosed):
+		http.Error(w, "Import not found", http.StatusNotFound) // Deleted or expired meanwhile
+	default:
+		logger(r).Error("Staged import failed", "import", imp.ID, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
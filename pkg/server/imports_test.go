@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestStagedImports(t *testing.T) {
+	ctx := context.Background()
+	alice := store.NewMemoryStore()
+	bob, err := store.NewPebbleStore(filepath.Join(t.TempDir(), "bob"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer bob.Close()
+	for i := range 5 {
+		alice.Save(ctx, &store.StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)), Timestamp: time.Now()})
+	}
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	config.AdminViewerKey = "viewer-secret"
+	config.StagingDir = filepath.Join(t.TempDir(), ".imports")
+	srv := NewMultiTenant(namedTenants{"alice": alice, "bob": bob}, config)
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// alice's export, with its checksum and count trailers, is imported into bob
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events/export?checksum=true", nil)
+	req.Header.Set("X-API-Key", "alice")
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	export, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	checksum, count := resp.Trailer.Get(ChecksumTrailer), resp.Trailer.Get(CountTrailer)
+
+	request := func(method, path, key string, body []byte) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("X-Admin-Key", key)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var result map[string]any
+		json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+
+	if code, _ := request(http.MethodPost, "/admin/imports", "viewer-secret", []byte(`{"tenant":"bob"}`)); code != http.StatusForbidden {
+		t.Errorf("Expected viewers to be denied, got %d", code)
+	}
+	if code, _ := request(http.MethodPost, "/admin/imports", "admin-secret", []byte(`{"tenant":"carol"}`)); code != http.StatusNotFound {
+		t.Errorf("Expected an unknown tenant to be 404, got %d", code)
+	}
+	code, created := request(http.MethodPost, "/admin/imports", "admin-secret", []byte(`{"tenant":"bob"}`))
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	id := created["id"].(string)
+
+	// Uploaded in two chunks split at a line
+	split := bytes.IndexByte(export, '\n') + 1
+	for _, chunk := range [][]byte{export[:split], export[split:]} {
+		if code, body := request(http.MethodPost, "/admin/imports/"+id+"/events", "admin-secret", chunk); code != http.StatusOK {
+			t.Fatalf("Expected staging to succeed, got %d: %v", code, body)
+		}
+	}
+	// A chunk repeating staged positions is rejected without touching the import
+	if code, _ := request(http.MethodPost, "/admin/imports/"+id+"/events", "admin-secret", export[:split]); code != http.StatusBadRequest {
+		t.Errorf("Expected a chunk out of order to be 400, got %d", code)
+	}
+
+	if head, _ := bob.GetPosition(ctx); head != 0 {
+		t.Fatalf("Staged events reached the store before publishing, head %d", head)
+	}
+	if code, _ := request(http.MethodPost, "/admin/imports/"+id+"/publish?count=6", "admin-secret", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected a wrong count to be 400, got %d", code)
+	}
+	code, published := request(http.MethodPost, "/admin/imports/"+id+"/publish?count="+count+"&checksum="+checksum, "admin-secret", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected publish to succeed, got %d: %v", code, published)
+	}
+	events, err := bob.Load(ctx, 1, -1)
+	if err != nil || len(events) != 5 || string(events[4].Data) != `{"n":4}` {
+		t.Fatalf("Expected alice's 5 events in bob, got %d, %v", len(events), err)
+	}
+
+	// Published imports are gone
+	if code, _ := request(http.MethodGet, "/admin/imports/"+id, "admin-secret", nil); code != http.StatusNotFound {
+		t.Errorf("Expected a published import to be 404, got %d", code)
+	}
+	_, created = request(http.MethodPost, "/admin/imports", "admin-secret", []byte(`{"tenant":"bob"}`))
+	if code, _ := request(http.MethodDelete, "/admin/imports/"+created["id"].(string), "admin-secret", nil); code != http.StatusNoContent {
+		t.Errorf("Expected delete to be 204, got %d", code)
+	}
+	if _, list := request(http.MethodGet, "/admin/imports", "viewer-secret", nil); len(list["imports"].([]any)) != 0 {
+		t.Errorf("Expected no imports left, got %v", list)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jilio/ebuse/internal/jwtauth"
+)
+
+// jwtIdentity verifies credential as a JWT from the identity provider.
+// Servers without a verifier accept no JWTs; errNotToken is returned for
+// them and for credentials that are not JWTs.
+func jwtIdentity(ctx context.Context, v *jwtauth.Verifier, credential string) (jwtauth.Identity, error) {
+	if v == nil || !jwtauth.Looks(credential) {
+		return jwtauth.Identity{}, errNotToken
+	}
+	id, err := v.Verify(ctx, credential, time.Now())
+	if err != nil && !errors.Is(err, jwtauth.ErrInvalid) {
+		slog.Warn("Failed to verify JWT", "error", err)
+	}
+	return id, err
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/jwtauth"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+const jwtSecret = "0123456789abcdef0123456789abcdef"
+
+// signJWT returns an HS256 JWT for tenant, signed with jwtSecret
+func signJWT(tenant string, exp time.Time) string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	payload, _ := json.Marshal(map[string]any{"sub": "user-1", "tenant": tenant, "exp": exp.Unix()})
+	signed := b64([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + b64(payload)
+	h := hmac.New(sha256.New, []byte(jwtSecret))
+	h.Write([]byte(signed))
+	return signed + "." + b64(h.Sum(nil))
+}
+
+func newJWTConfig(t *testing.T) *Config {
+	t.Helper()
+	v, err := jwtauth.New(jwtauth.Config{Secrets: []string{jwtSecret}}, nil)
+	if err != nil {
+		t.Fatalf("jwtauth.New failed: %v", err)
+	}
+	config := DefaultConfig()
+	config.JWTAuth = v
+	return config
+}
+
+func TestJWTAuth(t *testing.T) {
+	alice, bob := store.NewMemoryStore(), store.NewMemoryStore()
+	srv := NewMultiTenant(namedTenants{"alice": alice, "bob": bob}, newJWTConfig(t))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		credential string
+		want       int
+	}{
+		{"tenant claim", signJWT("alice", time.Now().Add(time.Hour)), http.StatusOK},
+		{"static API key", "bob", http.StatusOK},
+		{"expired", signJWT("alice", time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+		{"unknown tenant", signJWT("carol", time.Now().Add(time.Hour)), http.StatusUnauthorized},
+		{"tampered", signJWT("alice", time.Now().Add(time.Hour)) + "x", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"type":"OrderPaid","data":{}}`))
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	// The JWT reached alice's store, the key bob's
+	for name, st := range map[string]store.EventStore{"alice": alice, "bob": bob} {
+		if pos, _ := st.GetPosition(t.Context()); pos != 1 {
+			t.Errorf("Expected 1 event for %s, got position %d", name, pos)
+		}
+	}
+}
+
+func TestJWTAuth_SingleTenant(t *testing.T) {
+	srv := NewWithStore(store.NewMemoryStore(), newJWTConfig(t), "test-key-123")
+	defer srv.Close()
+
+	for tenant, want := range map[string]int{"default": http.StatusOK, "alice": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/events?from=0", nil)
+		req.Header.Set("Authorization", "Bearer "+signJWT(tenant, time.Now().Add(time.Hour)))
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Tenant %s: expected %d, got %d", tenant, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// fingerprintLen is the number of hex digits of a key's SHA-256 digest
+// logged in its place
+const fingerprintLen = 12
+
+// keysEqual compares a presented key with the expected one in constant time.
+// Comparing digests hides the expected key's length too.
+func keysEqual(presented, expected string) bool {
+	a := sha256.Sum256([]byte(presented))
+	b := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+// keyFingerprint identifies a key in logs without revealing it: the start of
+// its hex SHA-256 digest, as printed by
+//
+//	printf %s "$API_KEY" | sha256sum | cut -c1-12
+//
+// Empty keys have no fingerprint.
+func keyFingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:fingerprintLen]
+}
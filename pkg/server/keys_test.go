@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestKeysEqual(t *testing.T) {
+	if !keysEqual("secret-key", "secret-key") {
+		t.Error("Expected equal keys to match")
+	}
+	for _, presented := range []string{"", "secret", "secret-kez", "secret-key-2"} {
+		if keysEqual(presented, "secret-key") {
+			t.Errorf("Expected %q not to match", presented)
+		}
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	// printf %s test | sha256sum | cut -c1-12
+	if got := keyFingerprint("test"); got != "9f86d081884c" {
+		t.Errorf("Expected fingerprint 9f86d081884c, got %q", got)
+	}
+	if got := keyFingerprint(""); got != "" {
+		t.Errorf("Expected no fingerprint for an empty key, got %q", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-Api-Key", "secret")
+	header.Set("X-Auth-Token", "secret")
+	header.Set("X-Client-Secret", "secret")
+	header.Set("Idempotency-Key", "3f2a")
+	header.Set("Content-Type", "application/json")
+
+	redacted := redactHeaders(header)
+	for _, name := range []string{"Authorization", "X-Api-Key", "X-Auth-Token", "X-Client-Secret"} {
+		if redacted[name] != "[REDACTED]" {
+			t.Errorf("Expected %s to be redacted, got %q", name, redacted[name])
+		}
+	}
+	if redacted["Idempotency-Key"] != "3f2a" || redacted["Content-Type"] != "application/json" {
+		t.Errorf("Expected other headers to be kept, got %v", redacted)
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lockout defaults: 10 failures lock an address out for a minute, doubling
+// with every further lockout up to an hour
+const (
+	DefaultAuthLockoutThreshold = 10
+	DefaultAuthLockoutBase      = time.Minute
+	DefaultAuthLockoutMax       = time.Hour
+)
+
+// maxLockoutEntries is the number of tracked addresses and key prefixes
+// above which stale ones are swept
+const maxLockoutEntries = 10000
+
+// lockoutPrefixLen is the length of the key prefix failures are also
+// counted for; shorter credentials have no prefix
+const lockoutPrefixLen = 8
+
+// lockoutEntry tracks the failed authentications of one client address or
+// key prefix
+type lockoutEntry struct {
+	failures int       // Failures since the last lockout
+	lockouts int       // Lockouts in a row, doubling the next one
+	until    time.Time // End of the current lockout
+	last     time.Time // Last failure
+}
+
+// authLockout locks client addresses out after repeated authentication
+// failures, and optionally key prefixes, against guessing from many
+// addresses. Lockouts grow exponentially and are forgotten once an address
+// or prefix has stayed quiet for the longest lockout. Successful requests do
+// not reset the count, so a tenant's valid key cannot be used to keep
+// guessing others.
+type authLockout struct {
+	threshold       int
+	prefixThreshold int // 0 = key prefixes are not locked out
+	base            time.Duration
+	max             time.Duration
+	trusted         []*net.IPNet // Proxies whose X-Forwarded-For is believed
+	now             func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*lockoutEntry // By address, or "key:" and prefix
+	failures  int64                    // Failed authentications seen
+	lockouts  int64                    // Lockouts started
+	rejected  int64                    // Requests rejected while locked out
+	lastSweep time.Time
+}
+
+// newAuthLockout returns nil when config.AuthLockoutThreshold is not
+// positive, disabling lockouts
+func newAuthLockout(config *Config) *authLockout {
+	if config.AuthLockoutThreshold <= 0 {
+		return nil
+	}
+	base, longest := config.AuthLockoutBase, config.AuthLockoutMax
+	if base <= 0 {
+		base = DefaultAuthLockoutBase
+	}
+	if longest <= 0 {
+		longest = DefaultAuthLockoutMax
+	}
+	return &authLockout{
+		threshold:       config.AuthLockoutThreshold,
+		prefixThreshold: max(config.AuthLockoutPrefixThreshold, 0),
+		base:            base,
+		max:             max(longest, base),
+		trusted:         config.TrustedProxies,
+		now:             time.Now,
+		entries:         make(map[string]*lockoutEntry),
+	}
+}
+
+// addr returns the client address failures are counted for. It is the
+// connection's address, unless that is a trusted proxy: then it is the
+// right-most X-Forwarded-For hop that is not, since the hops left of it were
+// written by the client.
+func (l *authLockout) addr(r *http.Request) string {
+	addr := hostOnly(r.RemoteAddr)
+	if !containsIP(l.trusted, addr) {
+		return addr
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr = hostOnly(hop)
+		if !containsIP(l.trusted, addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// prefixKey returns the entry of credential's prefix, or "" when prefixes
+// are not locked out or credential is too short to have one
+func (l *authLockout) prefixKey(credential string) string {
+	if l.prefixThreshold == 0 || len(credential) <= lockoutPrefixLen {
+		return ""
+	}
+	return "key:" + credential[:lockoutPrefixLen]
+}
+
+// hostOnly strips the port from addr, if any
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// blocked rejects r with 429 if its address, or the prefix of the
+// credential it presents, is locked out
+func (l *authLockout) blocked(w http.ResponseWriter, r *http.Request, credential string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	var wait time.Duration
+	for _, key := range []string{l.addr(r), l.prefixKey(credential)} {
+		if e, ok := l.entries[key]; ok && key != "" && now.Before(e.until) {
+			wait = max(wait, e.until.Sub(now))
+		}
+	}
+	if wait > 0 {
+		l.rejected++
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(int((wait+time.Second-1)/time.Second)))
+	http.Error(w, "Too many failed authentication attempts", http.StatusTooManyRequests)
+	return true
+}
+
+// fail records a rejected credential for r's address and the credential's
+// prefix, starting a lockout of either once it reaches its threshold
+func (l *authLockout) fail(r *http.Request, credential string) {
+	if l == nil {
+		return
+	}
+	addr := l.addr(r)
+	prefix := l.prefixKey(credential)
+
+	l.mu.Lock()
+	now := l.now()
+	l.failures++
+	l.sweep(now)
+	failures, lockout := l.record(addr, l.threshold, now)
+	var prefixFailures int
+	var prefixLockout time.Duration
+	if prefix != "" {
+		prefixFailures, prefixLockout = l.record(prefix, l.prefixThreshold, now)
+	}
+	l.mu.Unlock()
+
+	if lockout > 0 {
+		logger(r).Warn("Authentication lockout",
+			"audit", true,
+			"ip", addr,
+			"key_fingerprint", keyFingerprint(credential),
+			"failures", failures,
+			"lockout", lockout.String(),
+			"path", r.URL.Path,
+			"method", r.Method)
+	}
+	if prefixLockout > 0 {
+		logger(r).Warn("Authentication lockout",
+			"audit", true,
+			"ip", addr,
+			"key_prefix_fingerprint", keyFingerprint(strings.TrimPrefix(prefix, "key:")),
+			"failures", prefixFailures,
+			"lockout", prefixLockout.String(),
+			"path", r.URL.Path,
+			"method", r.Method)
+	}
+}
+
+// record counts a failure for the entry key and starts its lockout once it
+// reaches threshold. It returns the failures counted and the lockout
+// started, if any. Callers hold l.mu.
+func (l *authLockout) record(key string, threshold int, now time.Time) (int, time.Duration) {
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.last) > l.max && !now.Before(e.until) {
+		e = &lockoutEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+
+	failures := e.failures
+	if e.failures < threshold {
+		return failures, 0
+	}
+	lockout := l.base
+	for i := 0; i < e.lockouts && lockout < l.max; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, l.max)
+	e.lockouts++
+	e.failures = 0
+	e.until = now.Add(lockout)
+	l.lockouts++
+	return failures, lockout
+}
+
+// sweep forgets quiet addresses and prefixes once many are tracked, at most once a
+// minute. Callers hold l.mu.
+func (l *authLockout) sweep(now time.Time) {
+	if len(l.entries) < maxLockoutEntries || now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, e := range l.entries {
+		if now.Sub(e.last) > l.max && !now.Before(e.until) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// stats reports failure and lockout counts and the addresses and key
+// prefixes locked out now
+func (l *authLockout) stats() map[string]any {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	locked, lockedPrefixes := 0, 0
+	for key, e := range l.entries {
+		switch {
+		case !now.Before(e.until):
+		case strings.HasPrefix(key, "key:"):
+			lockedPrefixes++
+		default:
+			locked++
+		}
+	}
+	return map[string]any{
+		"failures":        l.failures,
+		"lockouts":        l.lockouts,
+		"rejected":        l.rejected,
+		"locked":          locked,
+		"locked_prefixes": lockedPrefixes,
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestAuthLockout(t *testing.T) {
+	config := DefaultConfig()
+	config.AuthLockoutThreshold = 3
+	config.AuthLockoutBase = time.Minute
+	config.AuthLockoutMax = 3 * time.Minute
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	now := time.Now()
+	srv.lockout.now = func() time.Time { return now }
+
+	serve := func(key, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/position", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Valid requests in between do not reset the count
+	serve("guess-1", "192.0.2.1:1000")
+	serve("guess-2", "192.0.2.1:1001")
+	if rr := serve("test-key-123", "192.0.2.1:1002"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 before the lockout, got %d", rr.Code)
+	}
+	if rr := serve("guess-3", "192.0.2.1:1003"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for the third failure, got %d", rr.Code)
+	}
+
+	// The address is locked out, even with the right key; others are not
+	rr := serve("test-key-123", "192.0.2.1:1004")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 429 with Retry-After 60, got %d with %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := serve("test-key-123", "192.0.2.2:1000"); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 from another address, got %d", rr.Code)
+	}
+
+	// Lockouts double up to the maximum
+	for _, want := range []string{"120", "180", "180"} {
+		now = now.Add(time.Hour / 20)
+		for range 3 {
+			serve("guess", "192.0.2.1:1000")
+		}
+		if rr := serve("test-key-123", "192.0.2.1:1000"); rr.Header().Get("Retry-After") != want {
+			t.Errorf("Expected Retry-After %s, got %q", want, rr.Header().Get("Retry-After"))
+		}
+	}
+
+	// Quiet addresses are forgotten
+	now = now.Add(time.Hour)
+	serve("guess", "192.0.2.1:1000")
+	if rr := serve("test-key-123", "192.0.2.1:1000"); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 after a quiet hour, got %d", rr.Code)
+	}
+
+	stats := srv.lockout.stats()
+	if stats["lockouts"] != int64(4) || stats["failures"] != int64(13) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestAuthLockout_Admin(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminKey = "admin-key"
+	config.AuthLockoutThreshold = 2
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	serve := func(header, key string) int {
+		req := httptest.NewRequest("GET", "/admin/connections", nil)
+		req.Header.Set(header, key)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Admin and tenant key failures count together
+	serve("X-Admin-Key", "wrong")
+	req := httptest.NewRequest("GET", "/position", nil)
+	req.Header.Set("X-API-Key", "mallory")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	if code := serve("X-Admin-Key", "admin-key"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, got %d", code)
+	}
+}
+
+func TestAuthLockout_ForwardedFor(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+	config := DefaultConfig()
+	config.AuthLockoutThreshold = 3
+	config.TrustedProxies = proxies
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	serve := func(key, addr, forwarded string) int {
+		req := httptest.NewRequest("GET", "/position", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("X-API-Key", key)
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// A client rotating X-Forwarded-For is still counted by its connection
+	for i := range 3 {
+		serve("guess", "192.0.2.1:1000", fmt.Sprintf("198.51.100.%d", i))
+	}
+	if code := serve("test-key-123", "192.0.2.1:1000", "198.51.100.99"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected rotating X-Forwarded-For to be locked out, got %d", code)
+	}
+
+	// Behind a trusted proxy, the right-most untrusted hop counts; entries
+	// the client wrote to the left of it do not
+	for i := range 3 {
+		serve("guess", "10.0.0.1:1000", fmt.Sprintf("198.51.100.%d, 203.0.113.7, 10.0.0.2", i))
+	}
+	if code := serve("test-key-123", "10.0.0.1:1000", "203.0.113.7"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the client behind the proxy to be locked out, got %d", code)
+	}
+	if code := serve("test-key-123", "10.0.0.1:1000", "203.0.113.8"); code != http.StatusOK {
+		t.Errorf("Expected other clients behind the proxy to pass, got %d", code)
+	}
+
+	// Spoofing a victim's address does not lock the victim out
+	for range 3 {
+		serve("guess", "192.0.2.5:1000", "203.0.113.9")
+	}
+	if code := serve("test-key-123", "10.0.0.1:1000", "203.0.113.9"); code != http.StatusOK {
+		t.Errorf("Expected the spoofed address to pass, got %d", code)
+	}
+}
+
+func TestAuthLockout_KeyPrefix(t *testing.T) {
+	config := DefaultConfig()
+	config.AuthLockoutThreshold = 3
+	config.AuthLockoutPrefixThreshold = 4
+	srv := NewWithStore(store.NewMemoryStore(), config, "acme_live_0123456789")
+	defer srv.Close()
+
+	serve := func(key, addr string) int {
+		req := httptest.NewRequest("GET", "/position", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Guesses spread over addresses lock out the prefix they share
+	for i := range 4 {
+		serve(fmt.Sprintf("acme_live_guess%d", i), fmt.Sprintf("192.0.2.%d:1000", i))
+	}
+	if code := serve("acme_live_guess9", "192.0.2.9:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the key prefix to be locked out, got %d", code)
+	}
+	if code := serve("other_key_guess", "192.0.2.9:1000"); code != http.StatusUnauthorized {
+		t.Errorf("Expected other prefixes to be checked, got %d", code)
+	}
+	if stats := srv.lockout.stats(); stats["locked_prefixes"] != 1 || stats["locked"] != 0 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// requestLogKey is the context key of a request's *requestLog
+type requestLogKey struct{}
+
+// requestLog is the logger of one request, tagged with its request ID and
+// route. The tenant is only known once auth has run, inside the logging
+// middleware, so it is added in place.
+type requestLog struct {
+	id     string
+	tenant string
+	logger *slog.Logger
+}
+
+// withRequestLog attaches a request log to r and echoes its request ID in
+// the response
+func withRequestLog(w http.ResponseWriter, r *http.Request) (*http.Request, *requestLog) {
+	id := newRequestID(r)
+
+	// The mux pattern groups requests of one endpoint, e.g. "/subscriptions/"
+	route := r.Pattern
+	if route == "" {
+		route = r.URL.Path
+	}
+
+	l := &requestLog{id: id, logger: slog.With("request_id", id, "route", route)}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestLogKey{}, l)), l
+}
+
+// getRequestLog returns the request log of r, or nil outside the logging
+// middleware
+func getRequestLog(r *http.Request) *requestLog {
+	l, _ := r.Context().Value(requestLogKey{}).(*requestLog)
+	return l
+}
+
+// logger returns the logger for everything logged while handling r
+func logger(r *http.Request) *slog.Logger {
+	if l := getRequestLog(r); l != nil {
+		return l.logger
+	}
+	return slog.Default()
+}
+
+// setLogTenant adds the authenticated tenant to the request's log lines
+func setLogTenant(r *http.Request, tenant string) {
+	if l := getRequestLog(r); l != nil {
+		l.tenant = tenant
+		l.logger = l.logger.With("tenant", tenant)
+	}
+}
+
+// setLogAdmin adds the admin role and, for logins, the user to the
+// request's log lines
+func setLogAdmin(r *http.Request, user, role string) {
+	if l := getRequestLog(r); l != nil {
+		l.logger = l.logger.With("admin_role", role)
+		if user != "" {
+			l.logger = l.logger.With("admin_user", user)
+		}
+	}
+}
+
+// requestID returns the ID logged for r
+func requestID(r *http.Request) string {
+	if l := getRequestLog(r); l != nil {
+		return l.id
+	}
+	return newRequestID(r)
+}
+
+// newRequestID takes the client's request ID, or generates one when the
+// client sends none
+func newRequestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if len(id) > maxMetadataValueBytes {
+		id = id[:maxMetadataValueBytes]
+	}
+	if id == "" {
+		var b [16]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	return id
+}
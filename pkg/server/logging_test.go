@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// failingStream fails every LoadStream
+type failingStream struct {
+	store.EventStore
+}
+
+func (failingStream) LoadStream(ctx context.Context, from int64, batchSize int, handler func([]*store.StoredEvent) error) error {
+	return errors.New("disk on fire")
+}
+
+func TestRequestLogContext(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/alice.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	srv := NewMultiTenant(namedTenants{"alice": failingStream{sqliteStore}}, DefaultConfig())
+	defer srv.rateLimiter.Stop()
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	req := httptest.NewRequest(http.MethodGet, "/events/stream?from=1", nil)
+	req.Header.Set("X-API-Key", "alice")
+	req.Header.Set(RequestIDHeader, "req-42")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("Expected the request ID echoed, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a stream error and a request line, got %q", logs.String())
+	}
+	for _, line := range lines {
+		for _, attr := range []string{"request_id=req-42", "route=/events/stream", "tenant=alice"} {
+			if !strings.Contains(line, attr) {
+				t.Errorf("Expected %s in %q", attr, line)
+			}
+		}
+	}
+	if !strings.Contains(lines[0], `msg="Stream failed"`) || !strings.Contains(lines[0], `error="disk on fire"`) {
+		t.Errorf("Expected the stream error first, got %q", lines[0])
+	}
+
+	// Requests without an ID get one, and failed auth is logged without a tenant
+	logs.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions/projector/position", nil)
+	req.Header.Set("X-API-Key", "mallory")
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	id := rr.Header().Get(RequestIDHeader)
+	if len(id) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+	if line := logs.String(); !strings.Contains(line, "request_id="+id) || !strings.Contains(line, "route=/subscriptions/") || strings.Contains(line, "tenant=") {
+		t.Errorf("Unexpected log lines %q", line)
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// MetadataHeaderPrefix marks request headers that carry caller metadata,
+// e.g. "X-Ebuse-Meta-Caller: billing-service" or "X-Ebuse-Meta-Trace-Id: abc".
+// When Config.RecordMetadata is enabled the values are stored on saved events.
+const MetadataHeaderPrefix = "X-Ebuse-Meta-"
+
+// Limits protecting the store from oversized metadata headers
+const (
+	maxMetadataEntries    = 32
+	maxMetadataValueBytes = 256
+)
+
+type metadataCtxKey struct{}
+
+// metadataMiddleware collects metadata headers into the request context
+func metadataMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if metadata := metadataFromHeaders(r.Header); len(metadata) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), metadataCtxKey{}, metadata))
+		}
+		next(w, r)
+	}
+}
+
+// metadataFromHeaders extracts metadata headers, keyed by the lowercased
+// header suffix ("X-Ebuse-Meta-Trace-Id" -> "trace-id")
+func metadataFromHeaders(header http.Header) map[string]string {
+	var metadata map[string]string
+	for name, values := range header {
+		key, ok := strings.CutPrefix(name, MetadataHeaderPrefix)
+		if !ok || key == "" || len(values) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		if len(metadata) >= maxMetadataEntries {
+			break
+		}
+		value := values[0]
+		if len(value) > maxMetadataValueBytes {
+			value = value[:maxMetadataValueBytes]
+		}
+		metadata[strings.ToLower(key)] = value
+	}
+	return metadata
+}
+
+// applyRequestMetadata merges request metadata into the event; values set
+// explicitly on the event take precedence
+func applyRequestMetadata(ctx context.Context, event *store.StoredEvent) {
+	metadata, ok := ctx.Value(metadataCtxKey{}).(map[string]string)
+	if !ok {
+		return
+	}
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string, len(metadata))
+	}
+	for k, v := range metadata {
+		if _, exists := event.Metadata[k]; !exists {
+			event.Metadata[k] = v
+		}
+	}
+}
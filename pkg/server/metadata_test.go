@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestRecordMetadata(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	config := DefaultConfig()
+	config.RecordMetadata = true
+	srv := NewWithConfig(sqliteStore, config, "test-key-123")
+	defer srv.Close()
+
+	body := []byte(`{"type":"TestEvent","data":{},"metadata":{"caller":"explicit"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+	req.Header.Set("X-API-Key", "test-key-123")
+	req.Header.Set(MetadataHeaderPrefix+"Caller", "from-header")
+	req.Header.Set(MetadataHeaderPrefix+"Trace-Id", "trace-1")
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	events, err := sqliteStore.Load(req.Context(), 1, -1)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("Load failed: %v (%d events)", err, len(events))
+	}
+
+	metadata := events[0].Metadata
+	if metadata["trace-id"] != "trace-1" {
+		t.Errorf("Expected trace-id from header, got %v", metadata)
+	}
+	if metadata["caller"] != "explicit" {
+		t.Errorf("Expected explicit metadata to win over headers, got %v", metadata)
+	}
+}
+
+func TestRecordMetadata_Disabled(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader([]byte(`{"type":"TestEvent","data":{}}`)))
+	req.Header.Set("X-API-Key", "test-key-123")
+	req.Header.Set(MetadataHeaderPrefix+"Caller", "from-header")
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	var saved store.StoredEvent
+	if err := json.NewDecoder(rr.Body).Decode(&saved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(saved.Metadata) != 0 {
+		t.Errorf("Expected no metadata when recording is disabled, got %v", saved.Metadata)
+	}
+}
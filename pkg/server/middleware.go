@@ -1,14 +1,18 @@
 package server
 
 import (
+	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
-	"log/slog"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/jilio/ebuse/internal/logging"
 	"golang.org/x/time/rate"
 )
 
@@ -33,6 +37,28 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush lets streaming handlers push data through the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift
+// the write deadline of a stream
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack hands the connection to WebSocket handlers, logging the upgrade
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
 // loggingMiddleware logs all HTTP requests with structured logging
 func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +66,7 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		// Wrap response writer to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: 0}
+		r, reqLog := withRequestLog(wrapped, r)
 
 		// Call next handler
 		next(wrapped, r)
@@ -53,7 +80,7 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			ip = strings.Split(forwarded, ",")[0]
 		}
 
-		slog.Info("HTTP request",
+		attrs := []any{
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", wrapped.statusCode,
@@ -61,7 +88,11 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			"bytes", wrapped.written,
 			"ip", ip,
 			"user_agent", r.UserAgent(),
-		)
+		}
+		if id := traceID(r); id != "" {
+			attrs = append(attrs, "trace_id", id)
+		}
+		reqLog.logger.Info(logging.AccessMessage, attrs...)
 	}
 }
 
@@ -75,6 +106,20 @@ func (w gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// Flush sends the data compressed so far to the client
+func (w gzipResponseWriter) Flush() {
+	if gz, ok := w.Writer.(*gzip.Writer); ok {
+		gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // compressionMiddleware adds gzip compression for large responses
 func compressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -98,7 +143,7 @@ func compressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// rateLimiter implements per-IP rate limiting
+// rateLimiter implements per-client rate limiting, see rateKey
 type rateLimiter struct {
 	mu       sync.RWMutex
 	limiters map[string]*rate.Limiter
@@ -152,17 +197,33 @@ func (rl *rateLimiter) getLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// rateKey returns what the rate limiter counts a request by, and the
+// client IP. Requests with an API key or bearer token are counted by a hash
+// of it, so tenants behind one NAT do not share a limit; others by IP.
+// Guessing keys to get fresh limits runs into the authentication lockout.
+func rateKey(r *http.Request) (string, string) {
+	ip := r.RemoteAddr
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip = strings.Split(forwarded, ",")[0]
+	}
+
+	credential := r.Header.Get("X-API-Key")
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && credential == "" {
+		credential = token
+	}
+	if credential == "" {
+		return "ip:" + ip, ip
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return "key:" + hex.EncodeToString(sum[:16]), ip
+}
+
 func (rl *rateLimiter) middleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Extract IP from request
-		ip := r.RemoteAddr
-		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-			ip = strings.Split(forwarded, ",")[0]
-		}
-
-		limiter := rl.getLimiter(ip)
+		key, ip := rateKey(r)
+		limiter := rl.getLimiter(key)
 		if !limiter.Allow() {
-			slog.Warn("Rate limit exceeded",
+			logger(r).Warn("Rate limit exceeded",
 				"ip", ip,
 				"path", r.URL.Path,
 				"method", r.Method)
@@ -3,11 +3,21 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"log/slog"
+	"errors"
+	"iter"
+	"maps"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jilio/ebuse/internal/archive"
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/mirror"
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/retention"
 	"github.com/jilio/ebuse/internal/store"
 )
 
@@ -16,7 +26,33 @@ type MultiTenantServer struct {
 	tenantManager TenantManager
 	mux           *http.ServeMux
 	rateLimiter   *rateLimiter
+	tenantLimit   *tenantLimiter
 	config        *Config
+	conns         *ConnTracker
+	shedder       *loadShedder
+	lockout       *authLockout
+	shards        *shardProxy
+	typeStats     *typeStats
+	latency       *storeLatency
+	errors        *errorCapture
+	appends       *fanout.Hub
+	sandboxes     *sandboxes
+	imports       *stagedImports
+	cors          *cors
+	handler       http.Handler // mux behind CORS handling
+
+	settingsMu sync.RWMutex
+	pipelines  map[string]*pipeline.Pipeline // Config.Pipelines until SetTenantSettings
+	jobs       map[string]TenantJobs         // From Config until SetTenantJobs
+}
+
+// TenantJobs are the background jobs of a tenant; nil fields are not
+// running
+type TenantJobs struct {
+	Mirror   *mirror.Mirror
+	Archiver *archive.Archiver
+	Pruner   *retention.Pruner
+	Backups  *backup.Scheduler
 }
 
 // TenantManager interface for managing multiple tenants
@@ -26,6 +62,12 @@ type TenantManager interface {
 	Close() error
 }
 
+// tenantLookup is implemented by tenant managers that can resolve a tenant
+// by name, which admin endpoints need since they are not tenant-authenticated
+type tenantLookup interface {
+	GetStoreByName(name string) (store.EventStore, bool)
+}
+
 // NewMultiTenant creates a new multi-tenant server
 func NewMultiTenant(tenantManager TenantManager, config *Config) *MultiTenantServer {
 	if config == nil {
@@ -36,33 +78,111 @@ func NewMultiTenant(tenantManager TenantManager, config *Config) *MultiTenantSer
 		tenantManager: tenantManager,
 		mux:           http.NewServeMux(),
 		rateLimiter:   newRateLimiter(config.RateLimit, config.RateBurst),
+		tenantLimit:   newTenantLimiter(config.TenantRateLimits, config.TenantRateBursts, config.RateLimitStore),
 		config:        config,
+		conns:         newConnTracker(config.MaxStreamsPerTenant),
+		shedder:       newLoadShedder(config.MaxInFlight),
+		lockout:       newAuthLockout(config),
+		shards:        newShardProxy(),
+		typeStats:     newTypeStats(),
+		latency:       newStoreLatency(),
+		errors:        newErrorCapture(config.DebugCapture),
+		appends:       fanout.NewHub(config.AppendBroker),
+		sandboxes:     newSandboxes(config.SandboxDir),
+		imports:       newStagedImports(config.StagingDir),
+		cors:          newCORS(config),
+		pipelines:     config.Pipelines,
+		jobs:          make(map[string]TenantJobs),
+	}
+	for _, tenants := range []iter.Seq[string]{maps.Keys(config.Mirrors), maps.Keys(config.Archivers), maps.Keys(config.Pruners), maps.Keys(config.Backups)} {
+		for tenant := range tenants {
+			s.jobs[tenant] = TenantJobs{
+				Mirror:   config.Mirrors[tenant],
+				Archiver: config.Archivers[tenant],
+				Pruner:   config.Pruners[tenant],
+				Backups:  config.Backups[tenant],
+			}
+		}
 	}
 
 	s.setupRoutes()
+	s.handler = s.cors.middleware(s.mux)
 	return s
 }
 
 func (s *MultiTenantServer) setupRoutes() {
-	// Apply middleware chain: logging -> rate limit -> auth -> compression -> handler
+	// Apply middleware chain: logging -> debug capture -> load shedding -> rate limit -> auth -> tenant rate limit -> compression -> handler
 	s.mux.HandleFunc("/events", s.chain(s.handleEvents, s.config.EnableGzip))
 	s.mux.HandleFunc("/events/batch", s.chain(s.handleBatchEvents, s.config.EnableGzip))
-	s.mux.HandleFunc("/events/stream", s.chain(s.handleStreamEvents, s.config.EnableGzip))
+	s.mux.HandleFunc("/events/stream", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handleStreamEvents), s.config.EnableGzip))
+	s.mux.HandleFunc("/events/export", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handleExport), false))
+	s.mux.HandleFunc("/replicate", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handleReplicate), false))
+	s.mux.HandleFunc("/events/subscribe", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handleSubscribe), false))
+	s.mux.HandleFunc("/ws", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handleWS), false))
 	s.mux.HandleFunc("/position", s.chain(s.handlePosition, false))
+	s.mux.HandleFunc("/position/wait", s.chain(s.conns.streamLimitMiddleware(tenantName, s.handlePositionWait), false))
+	s.mux.HandleFunc("/digest", s.chain(s.handleDigest, false))
+	s.mux.HandleFunc("/events/aggregate", s.chain(s.handleAggregate, s.config.EnableGzip))
+	s.mux.HandleFunc("/subscriptions", s.chain(s.handleSubscriptions, false))
 	s.mux.HandleFunc("/subscriptions/", s.chain(s.handleSubscriptions, false))
-	s.mux.HandleFunc("/health", loggingMiddleware(s.handleHealth))
-	s.mux.HandleFunc("/metrics", loggingMiddleware(s.authMiddleware(s.handleMetrics)))
-	s.mux.HandleFunc("/tenants", loggingMiddleware(s.authMiddleware(s.handleTenants)))
+	s.mux.HandleFunc("/streams/", s.chain(s.handleStreams, s.config.EnableGzip))
+	s.mux.HandleFunc("/healthz", probeChain(s.config, s.shedder, s.rateLimiter, nil, livenessHandler))
+	s.mux.HandleFunc("/readyz", probeChain(s.config, s.shedder, s.rateLimiter, healthAuth(s.config, s.lockout), s.handleReady))
+	s.mux.HandleFunc("/health", probeChain(s.config, s.shedder, s.rateLimiter, healthAuth(s.config, s.lockout), s.handleReady))
+	s.mux.HandleFunc("/metrics", probeChain(s.config, s.shedder, s.rateLimiter, s.authMiddleware, s.handleMetrics))
+	s.mux.HandleFunc("/stats/types", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleTypeStats))))
+	s.mux.HandleFunc("/stats/gaps", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleGaps))))
+	s.mux.HandleFunc("/stats/recovery", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleRecovery))))
+	s.mux.HandleFunc("/stats/timeseries", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleTimeseries))))
+	s.mux.HandleFunc("/tenants", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleTenants))))
+
+	if s.config.AdminLogin != nil {
+		s.mux.HandleFunc("/admin/login", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleLogin))))
+		s.mux.HandleFunc("/admin/callback", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleCallback))))
+		s.mux.HandleFunc("/admin/logout", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleLogout))))
+	}
+
+	if s.config.adminEnabled() {
+		s.mux.HandleFunc("/admin/session", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, adminSessionHandler))))
+		s.mux.HandleFunc("/admin/connections", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleConnections))))
+		s.mux.HandleFunc("/admin/compaction", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleCompaction))))
+		s.mux.HandleFunc("/admin/repair", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleRepair))))
+		s.mux.HandleFunc("/admin/schema/infer", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleSchemaInfer))))
+		s.mux.HandleFunc("/admin/backup", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleBackup))))
+		s.mux.HandleFunc("/admin/imports", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleImports))))
+		s.mux.HandleFunc("/admin/imports/", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleImports))))
+		s.mux.HandleFunc("/admin/debug/recent-errors", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleRecentErrors))))
+		s.mux.HandleFunc("/admin/sandboxes", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleSandboxes))))
+		s.mux.HandleFunc("/admin/sandboxes/", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleSandboxes))))
+		if s.config.TenantSpecs != nil {
+			s.mux.HandleFunc("/admin/tenants/spec", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleTenantSpec))))
+		}
+	}
+
+	if s.config.TokenSigner != nil && s.config.ServiceAccounts != nil {
+		s.mux.HandleFunc("/token", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.handleToken))))
+		if s.config.adminEnabled() {
+			s.mux.HandleFunc("/admin/service-accounts", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleServiceAccounts))))
+			s.mux.HandleFunc("/admin/service-accounts/", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleServiceAccounts))))
+		}
+	}
 }
 
-// chain applies middleware in order: logging -> rate limit -> auth -> optional compression
+// chain applies middleware in order: logging -> debug capture -> load shedding -> rate limit -> auth -> tenant rate limit -> optional compression
 func (s *MultiTenantServer) chain(handler http.HandlerFunc, enableCompression bool) http.HandlerFunc {
 	h := handler
 	if enableCompression {
 		h = compressionMiddleware(h)
 	}
+	if s.config.RecordMetadata {
+		h = metadataMiddleware(h)
+	}
+	h = s.tenantLimit.middleware(tenantName, h)
+	h = s.cors.tenantMiddleware(tenantName, h)
 	h = s.authMiddleware(h)
 	h = s.rateLimiter.middleware(h)
+	h = s.shedder.middleware(h)
+	h = s.errors.middleware(h)
 	h = loggingMiddleware(h)
 	return h
 }
@@ -77,6 +197,9 @@ func (s *MultiTenantServer) authMiddleware(next http.HandlerFunc) http.HandlerFu
 				apiKey = after
 			}
 		}
+		if s.lockout.blocked(w, r, apiKey) {
+			return
+		}
 
 		// Extract IP for logging
 		ip := r.RemoteAddr
@@ -85,7 +208,8 @@ func (s *MultiTenantServer) authMiddleware(next http.HandlerFunc) http.HandlerFu
 		}
 
 		if apiKey == "" {
-			slog.Warn("Authentication failed - no API key provided",
+			logger(r).Warn("Authentication failed - no API key provided",
+				"audit", true,
 				"ip", ip,
 				"path", r.URL.Path,
 				"method", r.Method)
@@ -95,19 +219,73 @@ func (s *MultiTenantServer) authMiddleware(next http.HandlerFunc) http.HandlerFu
 
 		// Get store for this API key
 		tenantStore, tenantName, ok := s.tenantManager.GetStore(apiKey)
+		handler := next
+
+		// Sandbox keys reach the sandbox's clone, named by its ID
+		sandbox := false
+		if !ok {
+			if sb, found := s.sandboxes.lookup(apiKey); found {
+				tenantStore, tenantName, ok, sandbox = sb.store, sb.ID, true, true
+			}
+		}
+
+		// Service account tokens name their tenant instead
+		if claims, err := tokenClaims(s.config.TokenSigner, apiKey); !ok && err == nil {
+			if lookup, isLookup := s.tenantManager.(tenantLookup); isLookup {
+				tenantStore, ok = lookup.GetStoreByName(claims.Tenant)
+				tenantName = claims.Tenant
+				handler = tokenScopeMiddleware(claims, next)
+			}
+		}
+
+		// As do identity provider JWTs, by claim
+		if !ok {
+			if id, err := jwtIdentity(r.Context(), s.config.JWTAuth, apiKey); err == nil {
+				if lookup, isLookup := s.tenantManager.(tenantLookup); isLookup {
+					tenantStore, ok = lookup.GetStoreByName(id.Tenant)
+					tenantName = id.Tenant
+				}
+			}
+		}
+
 		if !ok {
-			slog.Warn("Authentication failed - invalid API key",
+			// In sharded mode, tenants owned by another node are proxied there
+			if router, isRouter := s.tenantManager.(tenantRouter); isRouter {
+				if name, baseURL, remote := router.RouteTenant(apiKey); remote {
+					setLogTenant(r, name)
+					s.forward(w, r, name, baseURL)
+					return
+				}
+			}
+
+			logger(r).Warn("Authentication failed - invalid API key",
+				"audit", true,
 				"ip", ip,
+				"key_fingerprint", keyFingerprint(apiKey),
 				"path", r.URL.Path,
 				"method", r.Method)
+			s.lockout.fail(r, apiKey)
 			http.Error(w, "Invalid API key", http.StatusUnauthorized)
 			return
 		}
 
+		// Keys limited to some streams only reach the endpoints of those streams
+		if scoper, isScoper := s.tenantManager.(keyScoper); isScoper {
+			if keyName, keyACL, scoped := scoper.KeyScope(apiKey); scoped {
+				handler = streamACLMiddleware(keyName, keyACL, next)
+			}
+		}
+
+		// Tenant store calls are timed for /metrics; sandboxes are short-lived
+		if !sandbox {
+			tenantStore = s.latency.wrap(tenantName, tenantStore)
+		}
+
 		// Inject tenant info into context
+		setLogTenant(r, tenantName)
 		ctx := context.WithValue(r.Context(), "tenant_store", tenantStore)
 		ctx = context.WithValue(ctx, "tenant_name", tenantName)
-		next(w, r.WithContext(ctx))
+		handler(w, r.WithContext(ctx))
 	}
 }
 
@@ -124,6 +302,21 @@ func getTenantStore(r *http.Request) (store.EventStore, string, bool) {
 	return tenantStore, tenantName, true
 }
 
+// tenantName returns the authenticated tenant of the request
+func tenantName(r *http.Request) string {
+	_, name, _ := getTenantStore(r)
+	return name
+}
+
+// requestTenant names the tenant of an authenticated request in either
+// mode: "default" on a single-tenant server
+func requestTenant(r *http.Request) string {
+	if name := tenantName(r); name != "" {
+		return name
+	}
+	return "default"
+}
+
 // Event handlers (same as single-tenant but use tenant-specific store)
 
 func (s *MultiTenantServer) handleEvents(w http.ResponseWriter, r *http.Request) {
@@ -138,12 +331,12 @@ func (s *MultiTenantServer) handleEvents(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *MultiTenantServer) saveEvent(w http.ResponseWriter, r *http.Request) {
-	tenantStore, _, ok := getTenantStore(r)
+	tenantStore, tenantName, ok := getTenantStore(r)
 	if !ok {
 		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
 		return
 	}
-	saveEventHandler(w, r, tenantStore)
+	saveEventHandler(w, r, tenantStore, s.pipeline(tenantName), s.typeStats, s.appends)
 }
 
 func (s *MultiTenantServer) loadEvents(w http.ResponseWriter, r *http.Request) {
@@ -156,12 +349,12 @@ func (s *MultiTenantServer) loadEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *MultiTenantServer) handleBatchEvents(w http.ResponseWriter, r *http.Request) {
-	tenantStore, _, ok := getTenantStore(r)
+	tenantStore, tenantName, ok := getTenantStore(r)
 	if !ok {
 		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
 		return
 	}
-	batchEventsHandler(w, r, tenantStore)
+	batchEventsHandler(w, r, tenantStore, s.pipeline(tenantName), s.typeStats, s.appends)
 }
 
 func (s *MultiTenantServer) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
@@ -170,7 +363,43 @@ func (s *MultiTenantServer) handleStreamEvents(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
 		return
 	}
-	streamEventsHandler(w, r, tenantStore)
+	streamEventsHandler(w, r, tenantStore, s.config.HistoryThrottle)
+}
+
+func (s *MultiTenantServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	exportHandler(w, r, tenantStore, s.config.HistoryThrottle)
+}
+
+func (s *MultiTenantServer) handleReplicate(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	replicateHandler(w, r, tenantStore, s.appends)
+}
+
+func (s *MultiTenantServer) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	subscribeHandler(w, r, tenantStore, s.appends)
+}
+
+func (s *MultiTenantServer) handleWS(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	wsHandler(w, r, tenantStore, s.appends)
 }
 
 func (s *MultiTenantServer) handlePosition(w http.ResponseWriter, r *http.Request) {
@@ -182,6 +411,15 @@ func (s *MultiTenantServer) handlePosition(w http.ResponseWriter, r *http.Reques
 	positionHandler(w, r, tenantStore)
 }
 
+func (s *MultiTenantServer) handlePositionWait(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	positionWaitHandler(w, r, tenantStore, s.appends)
+}
+
 func (s *MultiTenantServer) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
 	tenantStore, _, ok := getTenantStore(r)
 	if !ok {
@@ -191,13 +429,47 @@ func (s *MultiTenantServer) handleSubscriptions(w http.ResponseWriter, r *http.R
 	subscriptionsHandler(w, r, tenantStore)
 }
 
-func (s *MultiTenantServer) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
+// handleStreams loads events of the tenant by stream ID
+func (s *MultiTenantServer) handleStreams(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	streamsHandler(w, r, tenantStore)
+}
+
+// handleToken issues service account tokens
+func (s *MultiTenantServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	tokenHandler(w, r, s.lockout, s.config.ServiceAccounts, s.config.TokenSigner, s.config.TokenTTL)
+}
+
+// handleServiceAccounts manages service accounts of this node's tenants
+func (s *MultiTenantServer) handleServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	serviceAccountsHandler(w, r, s.config.ServiceAccounts, func(tenant string) bool {
+		lookup, ok := s.tenantManager.(tenantLookup)
+		if !ok {
+			return false
+		}
+		_, ok = lookup.GetStoreByName(tenant)
+		return ok
 	})
 }
 
+// handleReady reports whether every local tenant's store answers and the
+// replica is not draining. Tenants owned by other shards are not checked.
+func (s *MultiTenantServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	stores := make(map[string]store.EventStore)
+	if lookup, ok := s.tenantManager.(tenantLookup); ok {
+		for _, name := range s.tenantManager.GetAllTenants() {
+			if st, ok := lookup.GetStoreByName(name); ok {
+				stores[name] = st
+			}
+		}
+	}
+	readinessHandler(w, r, s.conns, s.config.DataDir, stores)
+}
+
 func (s *MultiTenantServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	tenantStore, tenantName, ok := getTenantStore(r)
 	if !ok {
@@ -210,12 +482,201 @@ func (s *MultiTenantServer) handleMetrics(w http.ResponseWriter, r *http.Request
 
 	position, _ := tenantStore.GetPosition(ctx)
 
+	conns := s.conns.Snapshot(false)
+
+	metrics := map[string]any{
+		"tenant":           tenantName,
+		"total_events":     position,
+		"open_connections": conns.Open,
+		"active_streams":   conns.ActiveStreams[tenantName],
+		"timestamp":        time.Now().Unix(),
+	}
+	if sqliteStore, ok := store.As[*store.SQLiteStore](tenantStore); ok {
+		metrics["sqlite_busy"] = sqliteStore.BusyStats()
+		metrics["sqlite_wal"] = sqliteStore.WALStats()
+		metrics["sqlite_analyze"] = sqliteStore.MaintenanceStats()
+		metrics["position_gaps"] = sqliteStore.GapStats()
+	}
+	if compactor, ok := store.As[store.Compactor](tenantStore); ok {
+		metrics["compaction"] = compactor.CompactionStatus()
+	}
+	if reporter, ok := store.As[store.HealthReporter](tenantStore); ok {
+		if health, err := reporter.StorageHealth(ctx); err == nil {
+			metrics["storage"] = health
+		}
+	}
+	if shedding := s.shedder.stats(); shedding != nil {
+		metrics["load_shedding"] = shedding
+	}
+	if lockout := s.lockout.stats(); lockout != nil {
+		metrics["auth_lockout"] = lockout
+	}
+	jobs := s.tenantJobs(tenantName)
+	if jobs.Mirror != nil {
+		metrics["mirror"] = jobs.Mirror.Status()
+	}
+	if jobs.Archiver != nil {
+		metrics["archive"] = jobs.Archiver.Status()
+	}
+	if jobs.Pruner != nil {
+		metrics["retention"] = jobs.Pruner.Status()
+	}
+	if jobs.Backups != nil {
+		metrics["backup"] = jobs.Backups.Status()
+	}
+	if latencies := s.latency.latencies(tenantName); latencies != nil {
+		metrics["store_latency"] = latencies
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"tenant":       tenantName,
-		"total_events": position,
-		"timestamp":    time.Now().Unix(),
-	})
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// handleTypeStats reports write counts per event type of the request's tenant
+func (s *MultiTenantServer) handleTypeStats(w http.ResponseWriter, r *http.Request) {
+	tenantName := tenantName(r)
+	if tenantName == "" {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	typeStatsHandler(w, r, s.typeStats, tenantName)
+}
+
+// handleTimeseries reports events per minute and type of the request's tenant
+func (s *MultiTenantServer) handleTimeseries(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	timeseriesHandler(w, r, tenantStore)
+}
+
+// handleGaps lists ranges of missing positions of the request's tenant
+func (s *MultiTenantServer) handleGaps(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	gapsHandler(w, r, tenantStore)
+}
+
+// handleRecovery reports the recovery scan run when the request's tenant's
+// store was opened
+func (s *MultiTenantServer) handleRecovery(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	recoveryHandler(w, r, tenantStore)
+}
+
+// handleConnections lists open connections and active streams of all tenants
+func (s *MultiTenantServer) handleConnections(w http.ResponseWriter, r *http.Request) {
+	connectionsHandler(w, r, s.conns)
+}
+
+// handleCompaction reports or triggers compaction of the tenant given by ?tenant=
+func (s *MultiTenantServer) handleCompaction(w http.ResponseWriter, r *http.Request) {
+	tenantStore, ok := s.storeByName(w, r.URL.Query().Get("tenant"))
+	if !ok {
+		return
+	}
+	compactionHandler(w, r, tenantStore)
+}
+
+// handleBackup snapshots the store of the tenant given by ?tenant=
+func (s *MultiTenantServer) handleBackup(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("tenant")
+	tenantStore, ok := s.storeByName(w, name)
+	if !ok {
+		return
+	}
+	backupHandler(w, r, tenantStore, s.tenantJobs(name).Backups)
+}
+
+// handleImports stages and publishes imports into the tenants' stores
+func (s *MultiTenantServer) handleImports(w http.ResponseWriter, r *http.Request) {
+	importsHandler(w, r, s.imports, s.storeByName)
+}
+
+func (s *MultiTenantServer) handleDigest(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	digestHandler(w, r, tenantStore)
+}
+
+func (s *MultiTenantServer) handleAggregate(w http.ResponseWriter, r *http.Request) {
+	tenantStore, _, ok := getTenantStore(r)
+	if !ok {
+		http.Error(w, "Internal server error: tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	aggregateHandler(w, r, tenantStore)
+}
+
+// handleRepair overwrites diverged events of the tenant given by ?tenant=
+func (s *MultiTenantServer) handleRepair(w http.ResponseWriter, r *http.Request) {
+	tenantStore, ok := s.storeByName(w, r.URL.Query().Get("tenant"))
+	if !ok {
+		return
+	}
+	repairHandler(w, r, tenantStore)
+}
+
+// handleSchemaInfer infers a JSON Schema from stored events of a type of
+// the ?tenant= tenant
+func (s *MultiTenantServer) handleSchemaInfer(w http.ResponseWriter, r *http.Request) {
+	tenantStore, ok := s.storeByName(w, r.URL.Query().Get("tenant"))
+	if !ok {
+		return
+	}
+	schemaInferHandler(w, r, tenantStore)
+}
+
+// handleRecentErrors lists failed requests kept by the debug capture
+func (s *MultiTenantServer) handleRecentErrors(w http.ResponseWriter, r *http.Request) {
+	recentErrorsHandler(w, r, s.errors)
+}
+
+// storeByName resolves a tenant for admin endpoints, writing an error response on failure
+func (s *MultiTenantServer) storeByName(w http.ResponseWriter, name string) (store.EventStore, bool) {
+	lookup, ok := s.tenantManager.(tenantLookup)
+	if !ok {
+		http.Error(w, "Tenant lookup not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+	if name == "" {
+		http.Error(w, "Missing tenant parameter", http.StatusBadRequest)
+		return nil, false
+	}
+	tenantStore, ok := lookup.GetStoreByName(name)
+	if !ok {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return nil, false
+	}
+	return tenantStore, true
+}
+
+// Drain fails health checks and ends active streams, so load balancers and
+// clients move to other replicas before the server shuts down
+func (s *MultiTenantServer) Drain() {
+	s.conns.Drain()
+}
+
+// WaitStreams blocks until all streams have ended or ctx is done
+func (s *MultiTenantServer) WaitStreams(ctx context.Context) error {
+	return s.conns.WaitStreams(ctx)
+}
+
+// Listener wraps ln so the server can track connections accepted from it
+func (s *MultiTenantServer) Listener(ln net.Listener) net.Listener {
+	return s.conns.Listener(ln)
 }
 
 func (s *MultiTenantServer) handleTenants(w http.ResponseWriter, r *http.Request) {
@@ -228,13 +689,69 @@ func (s *MultiTenantServer) handleTenants(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// pipeline returns the write pipeline of tenant, nil if it has none
+func (s *MultiTenantServer) pipeline(tenant string) *pipeline.Pipeline {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.pipelines[tenant]
+}
+
+// tenantJobs returns the background jobs of tenant
+func (s *MultiTenantServer) tenantJobs(tenant string) TenantJobs {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.jobs[tenant]
+}
+
+// SetTenantJobs replaces the background jobs of tenant reported in
+// /metrics, e.g. once a reload added the tenant; nil removes them
+func (s *MultiTenantServer) SetTenantJobs(tenant string, jobs *TenantJobs) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	if jobs == nil {
+		delete(s.jobs, tenant)
+		return
+	}
+	s.jobs[tenant] = *jobs
+}
+
+// EndStreams ends the streams of tenant and waits until they have returned
+// or ctx is done, e.g. before the store of a removed tenant is closed
+func (s *MultiTenantServer) EndStreams(ctx context.Context, tenant string) error {
+	return s.conns.EndStreams(ctx, tenant)
+}
+
+// SetTenantSettings replaces the write pipelines, request limits and
+// bursts of all tenants, e.g. once tenants were added or changed by
+// reloading their config. Limits only take effect if some tenant had one
+// when the server was created; otherwise the pipelines are replaced and an
+// error returned.
+func (s *MultiTenantServer) SetTenantSettings(pipelines map[string]*pipeline.Pipeline, rateLimits, rateBursts map[string]int) error {
+	s.settingsMu.Lock()
+	s.pipelines = pipelines
+	s.settingsMu.Unlock()
+
+	if s.tenantLimit == nil {
+		if len(rateLimits) > 0 {
+			return errors.New("tenant rate limits need a restart when none were set at start")
+		}
+		return nil
+	}
+	s.tenantLimit.setLimits(rateLimits, rateBursts)
+	return nil
+}
+
 func (s *MultiTenantServer) Close() error {
 	if s.rateLimiter != nil {
 		s.rateLimiter.Stop()
 	}
+	s.tenantLimit.Stop()
+	s.appends.Close()
+	s.sandboxes.close()
+	s.imports.close()
 	return s.tenantManager.Close()
 }
 
 func (s *MultiTenantServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
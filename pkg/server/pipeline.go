@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// RequestIDHeader carries the request ID recorded by the write pipeline's
+// request_id enrichment; one is generated when the client sends none
+const RequestIDHeader = "X-Request-Id"
+
+// pipelineRequest describes a write request to the write pipeline
+func pipelineRequest(r *http.Request) pipeline.Request {
+	return pipeline.Request{Tenant: requestTenant(r), RequestID: requestID(r), Time: time.Now()}
+}
+
+// applyPipeline runs the write pipeline on event and writes an audit log
+// record for every PII rule action. Records carry the tenant and request
+// ID through log, the request's logger.
+func applyPipeline(log *slog.Logger, p *pipeline.Pipeline, event *store.StoredEvent, req pipeline.Request) error {
+	records, err := p.Apply(event, req)
+	for _, record := range records {
+		log.Info("PII policy applied",
+			"audit", true,
+			"event_type", event.Type,
+			"rule", record.Rule,
+			"action", record.Action,
+			"path", record.Path,
+			"matches", record.Matches,
+		)
+	}
+	return err
+}
+
+// pipelineError answers a write rejected by the pipeline: 422 for events
+// rejected by policy, 400 for data the pipeline cannot parse
+func pipelineError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pipeline.ErrDenied) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, "Invalid event: "+err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestWritePipeline(t *testing.T) {
+	aliceStore, err := store.NewPebbleStore(t.TempDir() + "/alice")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer aliceStore.Close()
+	bobStore, err := store.NewPebbleStore(t.TempDir() + "/bob")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer bobStore.Close()
+
+	p, err := pipeline.New(pipeline.Config{
+		Deny:   []pipeline.Rule{{Type: "Debug*", Reason: "no debug events"}},
+		Strip:  []string{"password"},
+		Enrich: pipeline.EnrichConfig{Tenant: true, RequestID: true},
+	})
+	if err != nil {
+		t.Fatalf("pipeline.New failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Pipelines = map[string]*pipeline.Pipeline{"alice": p}
+	srv := NewMultiTenant(namedTenants{"alice": aliceStore, "bob": bobStore}, config)
+	defer srv.rateLimiter.Stop()
+
+	post := func(apiKey, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set(RequestIDHeader, "req-42")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post("alice", "/events", `{"type":"DebugPing","data":{}}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected %d for a denied event, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	rr := post("alice", "/events/batch", `[{"type":"A","data":{}},{"type":"DebugPing","data":{}}]`)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "event 1") {
+		t.Errorf("Expected the batch rejected at event 1, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if head, _ := aliceStore.GetPosition(context.Background()); head != 0 {
+		t.Fatalf("Expected nothing stored for rejected writes, head is %d", head)
+	}
+
+	if rr := post("alice", "/events", `{"type":"Login","data":{"user":"ann","password":"x"}}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	events, _ := aliceStore.Load(context.Background(), 1, 1)
+	if len(events) != 1 || string(events[0].Data) != `{"user":"ann"}` {
+		t.Fatalf("Expected password stripped, got %+v", events)
+	}
+	if events[0].Metadata[pipeline.MetadataTenant] != "alice" || events[0].Metadata[pipeline.MetadataRequestID] != "req-42" {
+		t.Errorf("Expected tenant and request ID metadata, got %v", events[0].Metadata)
+	}
+
+	// Tenants without a pipeline are unaffected
+	if rr := post("bob", "/events", `{"type":"DebugPing","data":{}}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected bob's write accepted, got %d", rr.Code)
+	}
+}
+
+func TestWritePipeline_PIIAudit(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	p, err := pipeline.New(pipeline.Config{PII: []pipeline.PIIRule{{Builtin: "email"}}})
+	if err != nil {
+		t.Fatalf("pipeline.New failed: %v", err)
+	}
+	srv.config.Pipelines = map[string]*pipeline.Pipeline{"default": p}
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"Signup","data":{"email":"ann@example.com"}}`))
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if strings.Contains(rr.Body.String(), "ann@example.com") {
+		t.Errorf("Expected the email masked, got %s", rr.Body.String())
+	}
+	line := logs.String()
+	if !strings.Contains(line, "audit=true") || !strings.Contains(line, "rule=email") || !strings.Contains(line, "path=email") {
+		t.Errorf("Expected an audit record, got %q", line)
+	}
+	if strings.Contains(line, "ann@example.com") {
+		t.Error("Audit records must not contain the matched value")
+	}
+}
+
+func TestWritePipeline_Allowlist(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	p, err := pipeline.New(pipeline.Config{Allow: []pipeline.TypeRule{{Type: "Order*", MaxBytes: 64}}})
+	if err != nil {
+		t.Fatalf("pipeline.New failed: %v", err)
+	}
+	srv.config.Pipelines = map[string]*pipeline.Pipeline{"default": p}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post(`{"type":"OrderPlaced","data":{"id":1}}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for an allowed type, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := post(`{"type":"UserCreated","data":{}}`); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"UserCreated" is not allowed`) {
+		t.Errorf("Expected 422 for an unknown type, got %d: %s", rr.Code, rr.Body.String())
+	}
+	large := `{"type":"OrderPlaced","data":{"note":"` + strings.Repeat("x", 100) + `"}}`
+	if rr := post(large); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for oversized data, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Long-poll limits of /position/wait. maxPositionWait stays below the
+// default WRITE_TIMEOUT, so the answer isn't cut off by the server.
+const (
+	DefaultPositionWait = 30 * time.Second
+
+	maxPositionWait  = 50 * time.Second
+	positionWaitPoll = 50 * time.Millisecond
+)
+
+// positionWaitHandler blocks until the log reaches position ?min= or
+// ?timeout= expires, then answers with the current position and whether it
+// reached min. Like other streams, a waiting request ends early when the
+// server drains; the client then simply asks again.
+func positionWaitHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, appends *fanout.Hub) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	minPosition, err := strconv.ParseInt(query.Get("min"), 10, 64)
+	if err != nil || minPosition < 0 {
+		http.Error(w, "Invalid 'min' parameter", http.StatusBadRequest)
+		return
+	}
+
+	timeout := DefaultPositionWait
+	if timeoutStr := query.Get("timeout"); timeoutStr != "" {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil || timeout < 0 {
+			http.Error(w, "Invalid 'timeout' parameter", http.StatusBadRequest)
+			return
+		}
+		timeout = min(timeout, maxPositionWait)
+	}
+
+	ctx := r.Context()
+	tenant := requestTenant(r)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(positionWaitPoll)
+	defer poll.Stop()
+
+	var position int64
+	for {
+		appended := appends.Wait(tenant)
+		current, err := st.GetPosition(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break // Draining; answer with the last position seen
+			}
+			http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if position = current; position >= minPosition {
+			break
+		}
+
+		select {
+		case <-appended:
+			continue
+		case <-poll.C:
+			continue
+		case <-deadline.C:
+		case <-ctx.Done():
+		}
+		break
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Position int64 `json:"position"`
+		Reached  bool  `json:"reached"`
+	}{position, position >= minPosition})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestPositionWait(t *testing.T) {
+	st := store.NewMemoryStore()
+	saveTestEvents(t, st, 2)
+	srv := NewWithStore(st, DefaultConfig(), "test-key-123")
+	defer srv.Close()
+
+	wait := func(query string) (*httptest.ResponseRecorder, int64, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/position/wait?"+query, nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		var result struct {
+			Position int64 `json:"position"`
+			Reached  bool  `json:"reached"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+				t.Fatalf("Bad response: %v", err)
+			}
+		}
+		return rr, result.Position, result.Reached
+	}
+
+	// Reached positions answer at once
+	if rr, position, reached := wait("min=1"); rr.Code != http.StatusOK || position != 2 || !reached {
+		t.Errorf("Expected position 2 reached, got %d %d %v", rr.Code, position, reached)
+	}
+
+	// A write during the wait releases it
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		saveTestEvents(t, st, 1)
+	}()
+	start := time.Now()
+	if _, position, reached := wait("min=3&timeout=10s"); position != 3 || !reached {
+		t.Errorf("Expected position 3 reached, got %d %v", position, reached)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the wait to end with the write, took %v", elapsed)
+	}
+
+	if _, position, reached := wait("min=10&timeout=100ms"); position != 3 || reached {
+		t.Errorf("Expected a timeout at position 3, got %d %v", position, reached)
+	}
+
+	for _, query := range []string{"", "min=x", "min=-1", "min=1&timeout=soon", "min=1&timeout=-1s"} {
+		if rr, _, _ := wait(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rr.Code)
+		}
+	}
+}
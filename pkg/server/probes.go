@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// readyTimeout bounds the store checks of /readyz
+const readyTimeout = 2 * time.Second
+
+// probeCheck is the outcome of one /readyz check
+type probeCheck struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+// livenessHandler answers /healthz: the process is up and serving HTTP. It
+// checks nothing else, so an orchestrator only restarts a replica that is
+// stuck, not one waiting for its store.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "alive",
+	})
+}
+
+// readinessHandler answers /readyz: whether the replica should get traffic.
+// It is not ready while draining, when one of stores does not answer or when
+// dataDir (if set) is not writable. Every check is reported, so a failing
+// probe tells which one failed.
+func readinessHandler(w http.ResponseWriter, r *http.Request, conns *ConnTracker, dataDir string, stores map[string]store.EventStore) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]probeCheck{
+		"drain": {Status: "ok"},
+		"store": checkStores(ctx, stores),
+	}
+	if conns.Draining() {
+		checks["drain"] = probeCheck{Status: "fail", Error: "shutting down"}
+	}
+	if dataDir != "" {
+		checks["disk"] = checkDisk(dataDir)
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		if check.Status != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// checkStores asks every store for its position
+func checkStores(ctx context.Context, stores map[string]store.EventStore) probeCheck {
+	var failed []string
+	for name, st := range stores {
+		if _, err := st.GetPosition(ctx); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return probeCheck{Status: "fail", Error: fmt.Sprintf("%d of %d stores failed: %s", len(failed), len(stores), strings.Join(failed, "; "))}
+	}
+	return probeCheck{Status: "ok"}
+}
+
+// checkDisk creates, writes and removes a file in dir, which fails once the
+// disk is full or has been remounted read-only
+func checkDisk(dir string) probeCheck {
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return probeCheck{Status: "fail", Error: err.Error()}
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write([]byte("ok"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return probeCheck{Status: "fail", Error: err.Error()}
+	}
+	return probeCheck{Status: "ok"}
+}
+
+// ParseProbeNets parses a comma-separated list of CIDRs or single IPs for
+// Config.ProbeNets
+func ParseProbeNets(list string) ([]*net.IPNet, error) {
+	return parseNets(list, "probe")
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or single IPs
+// for Config.TrustedProxies
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	return parseNets(list, "proxy")
+}
+
+// parseNets parses a comma-separated list of CIDRs or single IPs; what names
+// the list in errors
+func parseNets(list, what string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid %s address %q", what, entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s network %q: %w", what, entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isProbe reports whether r comes from one of the probe networks. Only the
+// connection's address counts, since X-Forwarded-For is client-controlled.
+func isProbe(nets []*net.IPNet, r *http.Request) bool {
+	return containsIP(nets, hostOnly(r.RemoteAddr))
+}
+
+// containsIP reports whether host is an IP in one of nets
+func containsIP(nets []*net.IPNet, host string) bool {
+	if len(nets) == 0 {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeChain applies the middleware of the probe endpoints, which is the
+// same for both server types: logging -> load shedding -> rate limit ->
+// auth. Requests from Config.ProbeNets skip shedding and rate limiting, so
+// infrastructure probes keep passing while the server is under load.
+func probeChain(config *Config, shedder *loadShedder, limiter *rateLimiter, auth func(http.HandlerFunc) http.HandlerFunc, handler http.HandlerFunc) http.HandlerFunc {
+	h := handler
+	if auth != nil {
+		h = auth(h)
+	}
+	limited := shedder.middleware(limiter.middleware(h))
+
+	return loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if isProbe(config.ProbeNets, r) {
+			h(w, r)
+			return
+		}
+		limited(w, r)
+	})
+}
+
+// healthAuth returns the auth middleware of /readyz and /health: none,
+// unless Config.HealthAdminAuth asks for the admin key
+func healthAuth(config *Config, lockout *authLockout) func(http.HandlerFunc) http.HandlerFunc {
+	if !config.HealthAdminAuth || config.AdminKey == "" {
+		return nil
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return adminMiddleware(config, lockout, next)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestParseProbeNets(t *testing.T) {
+	nets, err := ParseProbeNets(" 10.0.0.0/8, 192.168.1.7,::1 ,")
+	if err != nil {
+		t.Fatalf("ParseProbeNets failed: %v", err)
+	}
+	if len(nets) != 3 || nets[1].String() != "192.168.1.7/32" || nets[2].String() != "::1/128" {
+		t.Errorf("unexpected networks: %v", nets)
+	}
+
+	for _, list := range []string{"10.0.0.0/33", "localhost"} {
+		if _, err := ParseProbeNets(list); err == nil {
+			t.Errorf("%q: expected an error", list)
+		}
+	}
+}
+
+func TestProbeEndpoints(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	probeNets, _ := ParseProbeNets("10.1.0.0/16")
+	config := &Config{RateLimit: 1, RateBurst: 1, ProbeNets: probeNets, AdminKey: "admin-secret", HealthAdminAuth: true}
+
+	single := NewWithStore(sqliteStore, config, "alice")
+	defer single.rateLimiter.Stop()
+	multi := NewMultiTenant(namedTenants{"alice": sqliteStore}, config)
+	defer multi.rateLimiter.Stop()
+
+	for name, srv := range map[string]http.Handler{"single-tenant": single, "multi-tenant": multi} {
+		// Clients are told apart by API key, which /health does not need,
+		// and by address without one
+		serve := func(path, remoteAddr string) int {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = remoteAddr
+			if path != "/health" {
+				req.Header.Set("X-API-Key", "alice")
+			}
+			req.Header.Set("X-Admin-Key", "admin-secret")
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		for _, path := range []string{"/health", "/metrics"} {
+			// Each path has its own client address, so limits do not carry over
+			client := "203.0.113.1:1234"
+			if path == "/metrics" {
+				client = "203.0.113.2:1234"
+			}
+			if code := serve(path, client); code != http.StatusOK {
+				t.Errorf("%s %s: expected 200, got %d", name, path, code)
+			}
+			if code := serve(path, client); code != http.StatusTooManyRequests {
+				t.Errorf("%s %s: expected clients to be rate limited, got %d", name, path, code)
+			}
+			for i := 0; i < 3; i++ {
+				if code := serve(path, "10.1.2.3:5678"); code != http.StatusOK {
+					t.Errorf("%s %s: expected probes to be exempt, got %d", name, path, code)
+				}
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = "10.1.2.3:5678"
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected /health to require the admin key, got %d", name, rr.Code)
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	healthy, err := store.NewSQLiteStore(t.TempDir() + "/healthy.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer healthy.Close()
+	broken, err := store.NewSQLiteStore(t.TempDir() + "/broken.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	broken.Close()
+
+	probe := func(srv http.Handler, path string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		json.NewDecoder(rr.Body).Decode(&body)
+		return rr.Code, body
+	}
+	failed := func(body map[string]any, check string) bool {
+		checks, _ := body["checks"].(map[string]any)
+		result, _ := checks[check].(map[string]any)
+		return result["status"] == "fail"
+	}
+
+	config := DefaultConfig()
+	config.DataDir = t.TempDir()
+	single := NewWithStore(healthy, config, "alice")
+	defer single.rateLimiter.Stop()
+	if code, body := probe(single, "/readyz"); code != http.StatusOK || body["status"] != "ready" || failed(body, "disk") {
+		t.Errorf("Expected a ready server, got %d %v", code, body)
+	}
+
+	// The multi-tenant check covers every tenant's store
+	multi := NewMultiTenant(namedTenants{"alice": healthy, "bob": broken}, config)
+	defer multi.rateLimiter.Stop()
+	if code, body := probe(multi, "/readyz"); code != http.StatusServiceUnavailable || !failed(body, "store") || failed(body, "drain") {
+		t.Errorf("Expected bob's store to fail readiness, got %d %v", code, body)
+	}
+
+	unwritable := DefaultConfig()
+	unwritable.DataDir = t.TempDir() + "/missing"
+	noDisk := NewWithStore(healthy, unwritable, "alice")
+	defer noDisk.rateLimiter.Stop()
+	if code, body := probe(noDisk, "/readyz"); code != http.StatusServiceUnavailable || !failed(body, "disk") {
+		t.Errorf("Expected the disk check to fail, got %d %v", code, body)
+	}
+
+	// Draining replicas are alive but not ready; /health follows /readyz
+	single.conns.Drain()
+	for _, path := range []string{"/readyz", "/health"} {
+		if code, body := probe(single, path); code != http.StatusServiceUnavailable || !failed(body, "drain") {
+			t.Errorf("%s: expected a draining server not to be ready, got %d %v", path, code, body)
+		}
+	}
+	if code, body := probe(single, "/healthz"); code != http.StatusOK || body["status"] != "alive" {
+		t.Errorf("Expected a draining server to be alive, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// recoveryHandler serves GET /stats/recovery: whether the store was closed
+// cleanly before this process opened it and, if not, what the recovery
+// scan of its newest events repaired and ignored and the head it came back
+// at
+func recoveryHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	recoverer, ok := store.As[store.Recoverer](st)
+	if !ok {
+		http.Error(w, "Recovery reports not supported by this store", http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recoverer.Recovery())
+}
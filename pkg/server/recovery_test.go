@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestRecoveryEndpoint(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	sqliteStore, err := store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	sqliteStore.Save(ctx, &store.StoredEvent{Type: "A", Data: json.RawMessage(`{}`)})
+	sqliteStore.Close()
+	os.WriteFile(path+".open", nil, 0644) // Left behind by a crash
+
+	sqliteStore, err = store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer sqliteStore.Close()
+	if _, _, err := store.Recover(ctx, sqliteStore, 100); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	get := func(st store.EventStore) *httptest.ResponseRecorder {
+		srv := NewWithStore(st, DefaultConfig(), "test-key-123")
+		defer srv.rateLimiter.Stop()
+		req := httptest.NewRequest(http.MethodGet, "/stats/recovery", nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(sqliteStore)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report store.Recovery
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil || !report.Unclean || report.Head != 1 || report.Scanned != 1 {
+		t.Errorf("Report = %+v, %v", report, err)
+	}
+
+	if rr := get(store.NewMemoryStore()); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 for a memory store, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Replication stream defaults. Followers that are caught up see a heartbeat
+// every DefaultReplicationHeartbeat, so a silent connection can be told
+// apart from an idle primary.
+const (
+	DefaultReplicationHeartbeat = 5 * time.Second
+	DefaultReplicationBatch     = 1000
+
+	maxReplicationBatch   = 5000
+	minReplicationBeat    = 100 * time.Millisecond
+	replicationPollPeriod = 200 * time.Millisecond
+)
+
+// replicationFrame is one NDJSON line of a /replicate stream. Cursor resumes
+// the stream right after the frame; Durable is the highest position known to
+// be on stable storage (0 when the store cannot sync).
+type replicationFrame struct {
+	Type    string            `json:"type"` // "events" or "heartbeat"
+	Events  []json.RawMessage `json:"events,omitempty"`
+	Cursor  string            `json:"cursor"`
+	Head    int64             `json:"head"`
+	Durable int64             `json:"durable,omitempty"`
+	Time    int64             `json:"time"`
+}
+
+// cursorVersion prefixes cursors so their encoding can change later
+const cursorVersion = "1-"
+
+// encodeCursor returns the cursor that resumes a stream at position next
+func encodeCursor(next int64) string {
+	return cursorVersion + strconv.FormatInt(next, 10)
+}
+
+// decodeCursor returns the position a cursor resumes at
+func decodeCursor(cursor string) (int64, error) {
+	rest, ok := strings.CutPrefix(cursor, cursorVersion)
+	if !ok {
+		return 0, fmt.Errorf("unsupported cursor %q", cursor)
+	}
+	next, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || next < 1 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return next, nil
+}
+
+// keepStreaming lifts the server's write timeout for a response that lasts
+// as long as the client stays connected. Writers without a deadline, e.g. in
+// tests, are left as they are.
+func keepStreaming(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// replicateHandler streams the log to a follower as NDJSON frames. Unlike
+// /events/stream it never ends on its own: once caught up it tails new events
+// and sends heartbeats until the client disconnects or the server drains.
+// Events are only shipped after they are durable on stores that can sync, so
+// a follower never gets ahead of a primary that crashes.
+//
+// The stream starts at ?cursor= (from a previous frame) or ?from= (a
+// position, default 1). A cursor beyond the primary's head is rejected with
+// 409, since the follower holds events the primary has lost.
+func replicateHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, appends *fanout.Hub) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	next := int64(1)
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		if next, err = decodeCursor(cursor); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if fromStr := query.Get("from"); fromStr != "" {
+		from, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil || from < 1 {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+		next = from
+	}
+
+	batchSize := DefaultReplicationBatch
+	if bsStr := query.Get("batch_size"); bsStr != "" {
+		bs, err := strconv.Atoi(bsStr)
+		if err != nil || bs <= 0 {
+			http.Error(w, "Invalid 'batch_size' parameter", http.StatusBadRequest)
+			return
+		}
+		batchSize = min(bs, maxReplicationBatch)
+	}
+
+	heartbeat := DefaultReplicationHeartbeat
+	if hbStr := query.Get("heartbeat"); hbStr != "" {
+		hb, err := time.ParseDuration(hbStr)
+		if err != nil || hb <= 0 {
+			http.Error(w, "Invalid 'heartbeat' parameter", http.StatusBadRequest)
+			return
+		}
+		heartbeat = max(hb, minReplicationBeat)
+	}
+
+	ctx := r.Context()
+	tenant := requestTenant(r)
+
+	appended := appends.Wait(tenant)
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if next > head+1 {
+		http.Error(w, fmt.Sprintf("Cursor is ahead of the log (head %d)", head), http.StatusConflict)
+		return
+	}
+
+	syncer, _ := store.As[store.Syncer](st)
+
+	keepStreaming(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	send := func(frame replicationFrame) error {
+		frame.Cursor = encodeCursor(next)
+		frame.Head = head
+		frame.Time = time.Now().Unix()
+		if err := enc.Encode(frame); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	var durable int64
+	lastSent := time.Now()
+
+	// Tell the follower where the primary stands before any catch-up
+	if err := send(replicationFrame{Type: "heartbeat"}); err != nil {
+		return
+	}
+
+	for {
+		if next <= head {
+			to := min(head, next+int64(batchSize)-1)
+			if syncer != nil && to > durable {
+				if err := syncer.Sync(ctx, to); err != nil {
+					logger(r).Error("Replication sync failed", "error", err)
+					return
+				}
+				durable = head
+			}
+
+			events, err := loadReplicationBatch(ctx, st, next, to)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger(r).Error("Replication load failed", "error", err)
+				}
+				return
+			}
+			next = to + 1
+			if err := send(replicationFrame{Type: "events", Events: events, Durable: durable}); err != nil {
+				return
+			}
+			lastSent = time.Now()
+			continue
+		}
+
+		if time.Since(lastSent) >= heartbeat {
+			if err := send(replicationFrame{Type: "heartbeat", Durable: durable}); err != nil {
+				return
+			}
+			lastSent = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-appended:
+		case <-time.After(replicationPollPeriod):
+		}
+
+		appended = appends.Wait(tenant)
+		if head, err = st.GetPosition(ctx); err != nil {
+			if ctx.Err() == nil {
+				logger(r).Error("Replication position read failed", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// errBatchDone stops a raw store stream after the first batch
+var errBatchDone = errors.New("batch done")
+
+// loadReplicationBatch returns the encoded events in [from, to]
+func loadReplicationBatch(ctx context.Context, st store.EventStore, from, to int64) ([]json.RawMessage, error) {
+	var events []json.RawMessage
+
+	if rs, ok := store.As[store.RawStreamer](st); ok {
+		err := rs.LoadStreamRaw(ctx, from, int(to-from+1), func(batch []json.RawMessage) error {
+			for _, data := range batch {
+				position, err := rawPosition(data)
+				if err != nil {
+					return err
+				}
+				if position > to {
+					break
+				}
+				// The store may reuse its buffers once the handler returns
+				events = append(events, bytes.Clone(data))
+			}
+			return errBatchDone
+		})
+		if err != nil && !errors.Is(err, errBatchDone) {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	loaded, err := st.Load(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range loaded {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, data)
+	}
+	return events, nil
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// replicationReader reads frames from a /replicate response
+type replicationReader struct {
+	t   *testing.T
+	dec *json.Decoder
+}
+
+func startReplication(t *testing.T, st store.EventStore, query string) *replicationReader {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replicateHandler(w, r, st, nil)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/replicate?"+query, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	return &replicationReader{t: t, dec: json.NewDecoder(bufio.NewReader(resp.Body))}
+}
+
+func (rr *replicationReader) next() replicationFrame {
+	rr.t.Helper()
+	var frame replicationFrame
+	if err := rr.dec.Decode(&frame); err != nil {
+		rr.t.Fatalf("Failed to read frame: %v", err)
+	}
+	return frame
+}
+
+func (rr *replicationReader) positions(frame replicationFrame) []int64 {
+	rr.t.Helper()
+	var positions []int64
+	for _, data := range frame.Events {
+		position, err := rawPosition(data)
+		if err != nil {
+			rr.t.Fatalf("Bad event: %v", err)
+		}
+		positions = append(positions, position)
+	}
+	return positions
+}
+
+func saveTestEvents(t *testing.T, st store.EventStore, n int) {
+	t.Helper()
+	for range n {
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: "Event", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestReplicate_CatchUpAndTail(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/replicate.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 5)
+
+	rr := startReplication(t, st, "batch_size=2&heartbeat=100ms")
+
+	first := rr.next()
+	if first.Type != "heartbeat" || first.Head != 5 || first.Cursor != "1-1" {
+		t.Fatalf("Expected initial heartbeat at head 5, got %+v", first)
+	}
+
+	want := [][]int64{{1, 2}, {3, 4}, {5}}
+	var last replicationFrame
+	for _, positions := range want {
+		last = rr.next()
+		if last.Type != "events" {
+			t.Fatalf("Expected events frame, got %+v", last)
+		}
+		if got := rr.positions(last); len(got) != len(positions) || got[0] != positions[0] {
+			t.Fatalf("Expected positions %v, got %v", positions, got)
+		}
+	}
+	if last.Cursor != "1-6" || last.Durable != 5 {
+		t.Errorf("Expected cursor 1-6 and durable 5, got %s and %d", last.Cursor, last.Durable)
+	}
+
+	// Caught up: heartbeats keep the connection alive
+	if frame := rr.next(); frame.Type != "heartbeat" || frame.Cursor != "1-6" {
+		t.Errorf("Expected heartbeat while idle, got %+v", frame)
+	}
+
+	// New events are tailed
+	saveTestEvents(t, st, 1)
+	for {
+		frame := rr.next()
+		if frame.Type == "heartbeat" {
+			continue
+		}
+		if got := rr.positions(frame); len(got) != 1 || got[0] != 6 || frame.Cursor != "1-7" {
+			t.Errorf("Expected event 6 with cursor 1-7, got %v and %s", got, frame.Cursor)
+		}
+		break
+	}
+}
+
+func TestReplicate_ResumeFromCursor(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/replicate.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 5)
+
+	rr := startReplication(t, st, "cursor=1-4")
+	rr.next() // heartbeat
+	if got := rr.positions(rr.next()); len(got) != 2 || got[0] != 4 || got[1] != 5 {
+		t.Errorf("Expected events 4 and 5, got %v", got)
+	}
+
+	// Stores that cannot sync report no durable position
+	rr = startReplication(t, plainStore{st}, "from=5")
+	rr.next()
+	if frame := rr.next(); frame.Durable != 0 || len(frame.Events) != 1 {
+		t.Errorf("Expected one event without a durable position, got %+v", frame)
+	}
+}
+
+func TestReplicate_InvalidRequests(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/replicate.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 2)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"malformed cursor", "/replicate?cursor=abc", http.StatusBadRequest},
+		{"unknown cursor version", "/replicate?cursor=9-1", http.StatusBadRequest},
+		{"invalid from", "/replicate?from=0", http.StatusBadRequest},
+		{"invalid heartbeat", "/replicate?heartbeat=soon", http.StatusBadRequest},
+		{"cursor ahead of log", "/replicate?cursor=1-10", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			replicateHandler(rr, httptest.NewRequest(http.MethodGet, tt.target, nil), st, nil)
+			if rr.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import "net/http"
+
+// Admin roles; each may do everything the roles before it may
+const (
+	roleViewer   = "viewer"   // Inspects: GET and HEAD requests
+	roleOperator = "operator" // Also runs maintenance: manual compactions and backups
+	roleOwner    = "owner"    // Also changes data and credentials: repairs, service accounts
+)
+
+// roleRanks orders the roles; unknown roles rank 0 and may do nothing
+var roleRanks = map[string]int{
+	roleViewer:   1,
+	roleOperator: 2,
+	roleOwner:    3,
+}
+
+// roleAllows reports whether role includes required
+func roleAllows(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// requiredRole returns the least role that may make admin request r.
+// Requests that change state need the owner role unless listed here.
+func requiredRole(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return roleViewer
+	}
+	if r.URL.Path == "/admin/compaction" || r.URL.Path == "/admin/backup" {
+		return roleOperator
+	}
+	return roleOwner
+}
+
+// keyRole returns the role of an admin key, or "" if it is none of config's.
+// All keys are compared so the time taken does not tell which one matched.
+func keyRole(config *Config, key string) string {
+	role := ""
+	for _, k := range []struct{ key, role string }{
+		{config.AdminViewerKey, roleViewer},
+		{config.AdminOperatorKey, roleOperator},
+		{config.AdminKey, roleOwner},
+	} {
+		if k.key != "" && keysEqual(key, k.key) {
+			role = k.role
+		}
+	}
+	return role
+}
+
+// adminEnabled reports whether config has any admin credential, which
+// registers the /admin endpoints
+func (c *Config) adminEnabled() bool {
+	return c.AdminKey != "" || c.AdminOperatorKey != "" || c.AdminViewerKey != "" || c.AdminLogin != nil
+}
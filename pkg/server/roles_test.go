@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestAdminRoles(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminKey = "owner-key"
+	config.AdminOperatorKey = "operator-key"
+	config.AdminViewerKey = "viewer-key"
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	tests := []struct {
+		key    string
+		method string
+		path   string
+		denied bool
+	}{
+		{"viewer-key", http.MethodGet, "/admin/connections", false},
+		{"viewer-key", http.MethodGet, "/admin/compaction", false},
+		{"viewer-key", http.MethodPost, "/admin/compaction", true},
+		{"viewer-key", http.MethodPost, "/admin/repair", true},
+		{"operator-key", http.MethodPost, "/admin/compaction", false},
+		{"viewer-key", http.MethodPost, "/admin/backup", true},
+		{"operator-key", http.MethodPost, "/admin/backup", false},
+		{"operator-key", http.MethodPost, "/admin/repair", true},
+		{"owner-key", http.MethodPost, "/admin/repair", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("[]"))
+		req.Header.Set("X-Admin-Key", tt.key)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if denied := w.Code == http.StatusForbidden; denied != tt.denied || w.Code == http.StatusUnauthorized {
+			t.Errorf("%s %s %s: expected denied=%v, got %d", tt.key, tt.method, tt.path, tt.denied, w.Code)
+		}
+	}
+
+	// The session endpoint reports the key's role
+	req := httptest.NewRequest(http.MethodGet, "/admin/session", nil)
+	req.Header.Set("Authorization", "Bearer operator-key")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var session map[string]any
+	json.NewDecoder(w.Body).Decode(&session)
+	if session["method"] != "key" || session["role"] != roleOperator {
+		t.Errorf("Expected the operator role, got %v", session)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	if !roleAllows(roleOwner, roleViewer) || !roleAllows(roleOperator, roleOperator) {
+		t.Error("Expected higher roles to include lower ones")
+	}
+	if roleAllows(roleViewer, roleOperator) || roleAllows("admin", roleViewer) || roleAllows("", roleViewer) {
+		t.Error("Expected lower and unknown roles to be refused")
+	}
+}
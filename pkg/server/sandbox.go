@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Sandbox lifetimes and limits
+const (
+	defaultSandboxTTL = 24 * time.Hour
+	maxSandboxTTL     = 7 * 24 * time.Hour
+	maxSandboxes      = 10
+)
+
+// errTooManySandboxes is returned when maxSandboxes are open
+var errTooManySandboxes = errors.New("too many sandboxes")
+
+// sandbox is a writable clone of a tenant's store, reached with its own key
+type sandbox struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Position  int64     `json:"position"` // Head of the tenant when cloned
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	store  store.EventStore
+	dir    string
+	digest [32]byte // SHA-256 of the sandbox key
+	timer  *time.Timer
+}
+
+// sandboxes holds the open sandboxes of a server. Clones live in
+// subdirectories of dir, which is emptied on first use since sandboxes do
+// not survive restarts.
+type sandboxes struct {
+	dir   string // Empty = a temporary directory
+	mu    sync.Mutex
+	byID  map[string]*sandbox
+	slots int // Sandboxes open or being cloned
+	once  sync.Once
+	err   error // From preparing dir
+}
+
+func newSandboxes(dir string) *sandboxes {
+	return &sandboxes{dir: dir, byID: make(map[string]*sandbox)}
+}
+
+// prepare removes clones left behind by an earlier process and creates dir
+func (sb *sandboxes) prepare() error {
+	sb.once.Do(func() {
+		if sb.dir == "" {
+			sb.dir, sb.err = os.MkdirTemp("", "ebuse-sandboxes-")
+			return
+		}
+		if sb.err = os.RemoveAll(sb.dir); sb.err == nil {
+			sb.err = os.MkdirAll(sb.dir, 0700)
+		}
+	})
+	return sb.err
+}
+
+// create clones src, the store of tenant, into a sandbox that is removed
+// after ttl, and returns the sandbox with its key
+func (sb *sandboxes) create(ctx context.Context, tenant string, src store.EventStore, ttl time.Duration) (*sandbox, string, error) {
+	if err := sb.prepare(); err != nil {
+		return nil, "", fmt.Errorf("prepare sandbox directory: %w", err)
+	}
+	sb.mu.Lock()
+	if sb.slots >= maxSandboxes {
+		sb.mu.Unlock()
+		return nil, "", errTooManySandboxes
+	}
+	sb.slots++
+	sb.mu.Unlock()
+
+	var raw [4]byte
+	rand.Read(raw[:])
+	s := &sandbox{ID: tenant + "-sandbox-" + hex.EncodeToString(raw[:]), Tenant: tenant}
+	s.dir = filepath.Join(sb.dir, s.ID)
+
+	clone, err := store.Clone(ctx, src, s.dir)
+	if err == nil {
+		s.Position, err = clone.GetPosition(ctx)
+		if err != nil {
+			clone.Close()
+			os.RemoveAll(s.dir)
+		}
+	}
+	if err != nil {
+		sb.mu.Lock()
+		sb.slots--
+		sb.mu.Unlock()
+		return nil, "", err
+	}
+
+	var secret [32]byte
+	rand.Read(secret[:])
+	key := base64.RawURLEncoding.EncodeToString(secret[:])
+	s.store = clone
+	s.digest = sha256.Sum256([]byte(key))
+	s.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	s.ExpiresAt = s.CreatedAt.Add(ttl)
+
+	sb.mu.Lock()
+	sb.byID[s.ID] = s
+	s.timer = time.AfterFunc(ttl, func() {
+		if sb.remove(s.ID) {
+			slog.Info("Sandbox expired", "audit", true, "sandbox", s.ID)
+		}
+	})
+	sb.mu.Unlock()
+	return s, key, nil
+}
+
+// lookup returns the sandbox reached with apiKey
+func (sb *sandboxes) lookup(apiKey string) (*sandbox, bool) {
+	digest := sha256.Sum256([]byte(apiKey))
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	for _, s := range sb.byID {
+		if s.digest == digest {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// list returns the open sandboxes by ID
+func (sb *sandboxes) list() []*sandbox {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	list := make([]*sandbox, 0, len(sb.byID))
+	for _, s := range sb.byID {
+		list = append(list, s)
+	}
+	slices.SortFunc(list, func(a, b *sandbox) int { return strings.Compare(a.ID, b.ID) })
+	return list
+}
+
+// remove closes a sandbox and deletes its clone
+func (sb *sandboxes) remove(id string) bool {
+	sb.mu.Lock()
+	s, ok := sb.byID[id]
+	if ok {
+		delete(sb.byID, id)
+		sb.slots--
+		s.timer.Stop()
+	}
+	sb.mu.Unlock()
+	if !ok {
+		return false
+	}
+	s.store.Close()
+	os.RemoveAll(s.dir)
+	return true
+}
+
+// close removes all sandboxes
+func (sb *sandboxes) close() {
+	for _, s := range sb.list() {
+		sb.remove(s.ID)
+	}
+	if sb.dir != "" && sb.err == nil {
+		os.RemoveAll(sb.dir)
+	}
+}
+
+// handleSandboxes lists (GET) and creates (POST) sandboxes under
+// /admin/sandboxes, and deletes them (DELETE) under /admin/sandboxes/{id}.
+// A sandbox is a writable clone of a tenant's store for trying projection
+// changes against real history; the tenant itself is never written to.
+func (s *MultiTenantServer) handleSandboxes(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/sandboxes"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"sandboxes": s.sandboxes.list()})
+
+	case id == "" && r.Method == http.MethodPost:
+		var req struct {
+			Tenant string `json:"tenant"`
+			TTL    string `json:"ttl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		ttl := defaultSandboxTTL
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxSandboxTTL {
+				http.Error(w, fmt.Sprintf("Invalid ttl %q, must be a duration up to %v", req.TTL, maxSandboxTTL), http.StatusBadRequest)
+				return
+			}
+		}
+		tenantStore, ok := s.storeByName(w, req.Tenant)
+		if !ok {
+			return
+		}
+
+		sb, key, err := s.sandboxes.create(r.Context(), req.Tenant, tenantStore, ttl)
+		switch {
+		case errors.Is(err, errTooManySandboxes):
+			http.Error(w, fmt.Sprintf("Too many sandboxes (max %d), delete one first", maxSandboxes), http.StatusConflict)
+			return
+		case err != nil:
+			logger(r).Error("Failed to create sandbox", "tenant", req.Tenant, "error", err)
+			http.Error(w, "Failed to clone tenant store", http.StatusInternalServerError)
+			return
+		}
+
+		logger(r).Info("Sandbox created", "audit", true, "sandbox", sb.ID, "tenant", sb.Tenant,
+			"position", sb.Position, "expires_at", sb.ExpiresAt, "key_fingerprint", keyFingerprint(key))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":         sb.ID,
+			"tenant":     sb.Tenant,
+			"position":   sb.Position,
+			"created_at": sb.CreatedAt,
+			"expires_at": sb.ExpiresAt,
+			"api_key":    key,
+		})
+
+	case id != "" && r.Method == http.MethodDelete:
+		if !s.sandboxes.remove(id) {
+			http.Error(w, "Sandbox not found", http.StatusNotFound)
+			return
+		}
+		logger(r).Info("Sandbox deleted", "audit", true, "sandbox", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
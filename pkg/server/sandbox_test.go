@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSandboxes(t *testing.T) {
+	ctx := context.Background()
+	alice, err := store.NewPebbleStore(filepath.Join(t.TempDir(), "alice"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer alice.Close()
+	for range 3 {
+		alice.Save(ctx, &store.StoredEvent{Type: "OrderPlaced", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+	}
+
+	// Clones of an earlier process are removed
+	dir := filepath.Join(t.TempDir(), ".sandboxes")
+	os.MkdirAll(filepath.Join(dir, "stale"), 0700)
+
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	config.AdminViewerKey = "viewer-secret"
+	config.SandboxDir = dir
+	srv := NewMultiTenant(namedTenants{"alice": alice}, config)
+	defer srv.Close()
+
+	request := func(method, path, header, key, body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(header, key)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var result map[string]any
+		json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+
+	if code, _ := request(http.MethodPost, "/admin/sandboxes", "X-Admin-Key", "viewer-secret", `{"tenant":"alice"}`); code != http.StatusForbidden {
+		t.Errorf("Expected viewers to be denied, got %d", code)
+	}
+	if code, _ := request(http.MethodPost, "/admin/sandboxes", "X-Admin-Key", "admin-secret", `{"tenant":"bob"}`); code != http.StatusNotFound {
+		t.Errorf("Expected an unknown tenant to be 404, got %d", code)
+	}
+	if code, _ := request(http.MethodPost, "/admin/sandboxes", "X-Admin-Key", "admin-secret", `{"tenant":"alice","ttl":"30d"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid ttl to be 400, got %d", code)
+	}
+
+	code, created := request(http.MethodPost, "/admin/sandboxes", "X-Admin-Key", "admin-secret", `{"tenant":"alice","ttl":"1h"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	id, key := created["id"].(string), created["api_key"].(string)
+	if !strings.HasPrefix(id, "alice-sandbox-") || key == "" || created["position"] != float64(3) {
+		t.Fatalf("Unexpected sandbox: %v", created)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale")); !os.IsNotExist(err) {
+		t.Error("Expected the stale clone to be removed")
+	}
+
+	// The sandbox key reads the history and writes to the clone only
+	if code, result := request(http.MethodGet, "/position", "X-API-Key", key, ""); code != http.StatusOK || result["position"] != float64(3) {
+		t.Errorf("Expected position 3 in the sandbox, got %d %v", code, result)
+	}
+	if code, _ := request(http.MethodPost, "/events", "X-API-Key", key, `{"type":"ProjectionTest","data":{}}`); code != http.StatusOK {
+		t.Errorf("Expected the sandbox to accept writes, got %d", code)
+	}
+	if code, result := request(http.MethodGet, "/position", "X-API-Key", key, ""); code != http.StatusOK || result["position"] != float64(4) {
+		t.Errorf("Expected position 4 in the sandbox, got %v", result)
+	}
+	if head, _ := alice.GetPosition(ctx); head != 3 {
+		t.Errorf("Expected the tenant to stay at 3, got %d", head)
+	}
+
+	_, list := request(http.MethodGet, "/admin/sandboxes", "X-Admin-Key", "viewer-secret", "")
+	sandboxes, _ := list["sandboxes"].([]any)
+	if len(sandboxes) != 1 || sandboxes[0].(map[string]any)["id"] != id || sandboxes[0].(map[string]any)["api_key"] != nil {
+		t.Errorf("Expected the sandbox listed without its key, got %v", list)
+	}
+
+	// Deleting removes the key and the clone
+	if code, _ := request(http.MethodDelete, "/admin/sandboxes/"+id, "X-Admin-Key", "admin-secret", ""); code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", code)
+	}
+	if code, _ := request(http.MethodGet, "/position", "X-API-Key", key, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected the sandbox key to be rejected, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, id)); !os.IsNotExist(err) {
+		t.Error("Expected the clone to be removed")
+	}
+	if code, _ := request(http.MethodDelete, "/admin/sandboxes/"+id, "X-Admin-Key", "admin-secret", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+}
+
+func TestSandboxExpiry(t *testing.T) {
+	sb := newSandboxes(t.TempDir())
+	defer sb.close()
+
+	s, key, err := sb.create(context.Background(), "alice", store.NewMemoryStore(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, ok := sb.lookup(key); !ok {
+		t.Fatal("Expected the sandbox to be found")
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, ok := sb.lookup(key); ok; _, ok = sb.lookup(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected sandbox %s to expire", s.ID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for range maxSandboxes {
+		if _, _, err := sb.create(context.Background(), "alice", store.NewMemoryStore(), time.Hour); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+	if _, _, err := sb.create(context.Background(), "alice", store.NewMemoryStore(), time.Hour); err != errTooManySandboxes {
+		t.Errorf("Expected errTooManySandboxes, got %v", err)
+	}
+}
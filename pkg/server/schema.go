@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/schema"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// Samples read by /admin/schema/infer
+const (
+	defaultSchemaSamples = 1000
+	maxSchemaSamples     = 10000
+)
+
+// errSampled ends the scan once enough events are sampled
+var errSampled = errors.New("sampled")
+
+// schemaInferHandler serves GET /admin/schema/infer?type=: a JSON Schema
+// inferred from up to ?limit= events of the type from ?from= on, with each
+// field's types and whether some samples lack it. Payloads that are not
+// JSON are counted and skipped.
+func schemaInferHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	typ := query.Get("type")
+	if typ == "" || strings.HasSuffix(typ, "*") {
+		http.Error(w, "Missing or wildcard 'type' parameter", http.StatusBadRequest)
+		return
+	}
+	if err := store.ValidateTypes([]string{typ}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, limit := int64(1), defaultSchemaSamples
+	var err error
+	if s := query.Get("from"); s != "" {
+		if from, err = strconv.ParseInt(s, 10, 64); err != nil || from < 1 {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxSchemaSamples {
+			http.Error(w, fmt.Sprintf("Invalid 'limit' parameter (1-%d)", maxSchemaSamples), http.StatusBadRequest)
+			return
+		}
+	}
+
+	index, ok := typeIndex(w, st)
+	if !ok {
+		return
+	}
+	inferrer := schema.New()
+	var first, last, invalid int64
+	err = index.LoadStreamByTypes(r.Context(), []string{typ}, from, min(limit, 1000), func(events []*store.StoredEvent) error {
+		for _, event := range events {
+			if inferrer.Samples()+invalid == int64(limit) {
+				return errSampled
+			}
+			if first == 0 {
+				first = event.Position
+			}
+			last = event.Position
+			if inferrer.Add(event.Data) != nil {
+				invalid++
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSampled) {
+		http.Error(w, fmt.Sprintf("Failed to load events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":           typ,
+		"sampled":        inferrer.Samples(),
+		"invalid":        invalid,
+		"from":           first,
+		"to":             last,
+		"dropped_fields": inferrer.Dropped(),
+		"schema":         inferrer.Schema(typ),
+		"fields":         inferrer.Fields(),
+	})
+}
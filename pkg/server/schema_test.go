@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestSchemaInfer(t *testing.T) {
+	ctx := context.Background()
+	alice := store.NewMemoryStore()
+	for _, event := range []struct{ typ, data string }{
+		{"OrderPlaced", `{"id": 1, "total": 9.5}`},
+		{"UserCreated", `{"name": "a"}`},
+		{"OrderPlaced", `{"id": 2, "total": 12, "coupon": "SPRING"}`},
+		{"OrderPlaced", `{"id": 3, "total": 3.25}`},
+	} {
+		alice.Save(ctx, &store.StoredEvent{Type: event.typ, Data: json.RawMessage(event.data)})
+	}
+
+	config := DefaultConfig()
+	config.AdminViewerKey = "viewer-secret"
+	srv := NewMultiTenant(namedTenants{"alice": alice}, config)
+	defer srv.Close()
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Admin-Key", "viewer-secret")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/admin/schema/infer?tenant=alice", "/admin/schema/infer?tenant=alice&type=Order*", "/admin/schema/infer?tenant=alice&type=OrderPlaced&limit=0"} {
+		if rr := get(path); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+
+	rr := get("/admin/schema/infer?tenant=alice&type=OrderPlaced&limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Sampled int64 `json:"sampled"`
+		From    int64 `json:"from"`
+		To      int64 `json:"to"`
+		Schema  struct {
+			Title      string                    `json:"title"`
+			Required   []string                  `json:"required"`
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"schema"`
+		Fields []struct {
+			Path     string `json:"path"`
+			Optional bool   `json:"optional"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if result.Sampled != 2 || result.From != 1 || result.To != 3 || result.Schema.Title != "OrderPlaced" {
+		t.Errorf("Result = %+v", result)
+	}
+	if result.Schema.Properties["total"]["type"] != "number" || len(result.Schema.Required) != 2 {
+		t.Errorf("Schema = %+v", result.Schema)
+	}
+	if len(result.Fields) != 3 || result.Fields[0].Path != "coupon" || !result.Fields[0].Optional {
+		t.Errorf("Fields = %+v", result.Fields)
+	}
+}
@@ -4,36 +4,109 @@ import (
 	"context"
 	"encoding/json"
 	"log"
-	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/jilio/ebuse/internal/archive"
+	"github.com/jilio/ebuse/internal/backup"
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/jwtauth"
+	"github.com/jilio/ebuse/internal/mirror"
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/ratelimit"
+	"github.com/jilio/ebuse/internal/retention"
 	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/throttle"
+	"github.com/jilio/ebuse/internal/token"
 )
 
 // Server provides HTTP API for remote event storage
 type Server struct {
-	store       *store.SQLiteStore
+	store       store.EventStore // The store passed in, wrapped by latency
+	latency     *store.Instrumented
 	apiKey      string
 	mux         *http.ServeMux
 	rateLimiter *rateLimiter
+	tenantLimit *tenantLimiter
+	config      *Config
+	conns       *ConnTracker
+	shedder     *loadShedder
+	lockout     *authLockout
+	typeStats   *typeStats
+	errors      *errorCapture
+	appends     *fanout.Hub
+	imports     *stagedImports
+	cors        *cors
+	handler     http.Handler // mux behind CORS handling
 }
 
 // Config holds server configuration
 type Config struct {
-	RateLimit      int  // Requests per second per IP
+	RateLimit      int  // Requests per second per API key, or per IP without one
 	RateBurst      int  // Burst size for rate limiter
 	EnableGzip     bool // Enable gzip compression
+	RecordMetadata bool // Store X-Ebuse-Meta-* request headers as event metadata
+
+	MaxStreamsPerTenant int    // Concurrent /events/stream requests per tenant (0 = unlimited)
+	AdminKey            string // Key for /admin endpoints with the owner role
+	AdminOperatorKey    string // Key for /admin endpoints with the operator role
+	AdminViewerKey      string // Key for /admin endpoints with the viewer role (without any admin key or AdminLogin, /admin is disabled)
+	MaxInFlight         int    // In-flight requests before lower priorities are shed (0 = disabled)
+
+	ProbeNets       []*net.IPNet // Networks whose probe (/healthz, /readyz, /health) and /metrics requests skip rate limiting and load shedding
+	HealthAdminAuth bool         // Require AdminKey on /readyz and /health
+	DataDir         string       // Directory whose writability /readyz checks (empty = not checked)
+	DebugCapture    int          // Failed requests kept for /admin/debug/recent-errors (0 = disabled)
+
+	Mirrors   map[string]*mirror.Mirror     // Mirrors by tenant ("default" in single-tenant mode), reported in /metrics
+	Archivers map[string]*archive.Archiver  // Archivers by tenant, like Mirrors
+	Pruners   map[string]*retention.Pruner  // Retention pruners by tenant, like Mirrors
+	Backups   map[string]*backup.Scheduler  // Scheduled backups by tenant, like Mirrors
+	Pipelines map[string]*pipeline.Pipeline // Write pipelines by tenant, like Mirrors
+
+	TenantRateLimits map[string]int  // Requests per second by tenant, like Mirrors (missing = unlimited)
+	TenantRateBursts map[string]int  // Requests one second may reach after idling, by tenant (missing = the limit)
+	RateLimitStore   ratelimit.Store // Shares tenant rate limit counts between replicas (nil = per replica)
+	AppendBroker     fanout.Broker   // Wakes streams on all replicas when events are written (nil = this replica only)
+
+	HistoryThrottle *throttle.Throttle // Window and read rate of exports and replay jobs, shared by all tenants (nil = unlimited)
+
+	TokenSigner     *token.Signer   // Signs and verifies service account tokens (nil = tokens disabled)
+	ServiceAccounts *token.Accounts // Accounts exchanged for tokens at /token, managed under /admin/service-accounts
+	TokenTTL        time.Duration   // Lifetime of issued tokens (0 = DefaultTokenTTL)
+
+	JWTAuth *jwtauth.Verifier // Accepts JWTs from an identity provider, mapped to tenants by claim (nil = disabled)
+
+	AuthLockoutThreshold       int           // Failed authentications from one address before it is locked out (0 = disabled)
+	AuthLockoutPrefixThreshold int           // Failed authentications with one key prefix before the prefix is locked out (0 = disabled)
+	AuthLockoutBase            time.Duration // First lockout, doubled by every further one (0 = DefaultAuthLockoutBase)
+	AuthLockoutMax             time.Duration // Longest lockout (0 = DefaultAuthLockoutMax)
+	TrustedProxies             []*net.IPNet  // Proxies whose X-Forwarded-For names the client for lockouts (nil = the connection's address counts)
+
+	AdminLogin *AdminLogin // OpenID Connect login for /admin endpoints (nil = admin key only)
+
+	TenantSpecs TenantSpecStore // Tenant registry managed at /admin/tenants/spec, multi-tenant only (nil = disabled)
+	SandboxDir  string          // Directory for clones made at /admin/sandboxes, emptied on first use (empty = a temporary directory)
+	StagingDir  string          // Directory for imports staged at /admin/imports, emptied on first use (empty = a temporary directory)
+
+	CORSOrigins       []string            // Origins browsers may call the API from: "*", exact origins or "https://*.example.com" (empty = no CORS headers)
+	CORSHeaders       []string            // Request headers browsers may send; a trailing "*" matches any suffix (empty = DefaultCORSHeaders)
+	CORSMethods       []string            // Methods browsers may use (empty = DefaultCORSMethods)
+	CORSMaxAge        time.Duration       // How long browsers cache preflight responses (0 = DefaultCORSMaxAge)
+	TenantCORSOrigins map[string][]string // More origins by tenant, like Mirrors
 }
 
 // DefaultConfig returns production-ready defaults
 func DefaultConfig() *Config {
 	return &Config{
-		RateLimit:  100, // 100 req/s per IP
+		RateLimit:  100, // 100 req/s per API key or IP
 		RateBurst:  200, // Allow bursts up to 200
 		EnableGzip: true,
+
+		AuthLockoutThreshold: DefaultAuthLockoutThreshold,
 	}
 }
 
@@ -48,36 +121,107 @@ func New(store *store.SQLiteStore) *Server {
 
 // NewWithConfig creates a server with custom configuration
 func NewWithConfig(store *store.SQLiteStore, config *Config, apiKey string) *Server {
+	return NewWithStore(store, config, apiKey)
+}
+
+// NewWithStore creates a server on any store backend, e.g. Postgres. Calls
+// of st are timed and reported in /metrics.
+func NewWithStore(st store.EventStore, config *Config, apiKey string) *Server {
+	latency := store.Instrument(st)
 	s := &Server{
-		store:       store,
+		store:       latency,
+		latency:     latency,
 		apiKey:      apiKey,
 		mux:         http.NewServeMux(),
 		rateLimiter: newRateLimiter(config.RateLimit, config.RateBurst),
+		tenantLimit: newTenantLimiter(config.TenantRateLimits, config.TenantRateBursts, config.RateLimitStore),
+		config:      config,
+		conns:       newConnTracker(config.MaxStreamsPerTenant),
+		shedder:     newLoadShedder(config.MaxInFlight),
+		lockout:     newAuthLockout(config),
+		typeStats:   newTypeStats(),
+		errors:      newErrorCapture(config.DebugCapture),
+		appends:     fanout.NewHub(config.AppendBroker),
+		cors:        newCORS(config),
+		imports:     newStagedImports(config.StagingDir),
 	}
 
-	s.setupRoutes(config)
+	s.setupRoutes()
+	s.handler = s.cors.middleware(s.mux)
 	return s
 }
 
-func (s *Server) setupRoutes(config *Config) {
-	// Apply middleware chain: logging -> rate limit -> auth -> compression -> handler
-	s.mux.HandleFunc("/events", s.chain(s.handleEvents, config.EnableGzip))
-	s.mux.HandleFunc("/events/batch", s.chain(s.handleBatchEvents, config.EnableGzip))
-	s.mux.HandleFunc("/events/stream", s.chain(s.handleStreamEvents, config.EnableGzip))
+func (s *Server) setupRoutes() {
+	// Apply middleware chain: logging -> debug capture -> load shedding -> rate limit -> auth -> tenant rate limit -> compression -> handler
+	s.mux.HandleFunc("/events", s.chain(s.handleEvents, s.config.EnableGzip))
+	s.mux.HandleFunc("/events/batch", s.chain(s.handleBatchEvents, s.config.EnableGzip))
+	s.mux.HandleFunc("/events/stream", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handleStreamEvents), s.config.EnableGzip))
+	s.mux.HandleFunc("/events/export", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handleExport), false))
+	s.mux.HandleFunc("/replicate", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handleReplicate), false))
+	s.mux.HandleFunc("/events/subscribe", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handleSubscribe), false))
+	s.mux.HandleFunc("/ws", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handleWS), false))
 	s.mux.HandleFunc("/position", s.chain(s.handlePosition, false))
+	s.mux.HandleFunc("/position/wait", s.chain(s.conns.streamLimitMiddleware(singleTenant, s.handlePositionWait), false))
+	s.mux.HandleFunc("/digest", s.chain(s.handleDigest, false))
+	s.mux.HandleFunc("/events/aggregate", s.chain(s.handleAggregate, s.config.EnableGzip))
+	s.mux.HandleFunc("/subscriptions", s.chain(s.handleSubscriptions, false))
 	s.mux.HandleFunc("/subscriptions/", s.chain(s.handleSubscriptions, false))
-	s.mux.HandleFunc("/health", loggingMiddleware(s.handleHealth))
-	s.mux.HandleFunc("/metrics", loggingMiddleware(s.authMiddleware(s.handleMetrics)))
+	s.mux.HandleFunc("/streams/", s.chain(s.handleStreams, s.config.EnableGzip))
+	s.mux.HandleFunc("/healthz", probeChain(s.config, s.shedder, s.rateLimiter, nil, livenessHandler))
+	s.mux.HandleFunc("/readyz", probeChain(s.config, s.shedder, s.rateLimiter, healthAuth(s.config, s.lockout), s.handleReady))
+	s.mux.HandleFunc("/health", probeChain(s.config, s.shedder, s.rateLimiter, healthAuth(s.config, s.lockout), s.handleReady))
+	s.mux.HandleFunc("/metrics", probeChain(s.config, s.shedder, s.rateLimiter, s.authMiddleware, s.handleMetrics))
+	s.mux.HandleFunc("/stats/types", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleTypeStats))))
+	s.mux.HandleFunc("/stats/gaps", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleGaps))))
+	s.mux.HandleFunc("/stats/recovery", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleRecovery))))
+	s.mux.HandleFunc("/stats/timeseries", loggingMiddleware(s.shedder.middleware(s.authMiddleware(s.handleTimeseries))))
+
+	if s.config.AdminLogin != nil {
+		s.mux.HandleFunc("/admin/login", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleLogin))))
+		s.mux.HandleFunc("/admin/callback", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleCallback))))
+		s.mux.HandleFunc("/admin/logout", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.config.AdminLogin.handleLogout))))
+	}
+
+	if s.config.adminEnabled() {
+		s.mux.HandleFunc("/admin/session", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, adminSessionHandler))))
+		s.mux.HandleFunc("/admin/connections", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleConnections))))
+		s.mux.HandleFunc("/admin/compaction", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleCompaction))))
+		s.mux.HandleFunc("/admin/repair", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleRepair))))
+		s.mux.HandleFunc("/admin/schema/infer", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleSchemaInfer))))
+		s.mux.HandleFunc("/admin/backup", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleBackup))))
+		s.mux.HandleFunc("/admin/imports", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleImports))))
+		s.mux.HandleFunc("/admin/imports/", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleImports))))
+		s.mux.HandleFunc("/admin/debug/recent-errors", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleRecentErrors))))
+	}
+
+	if s.config.TokenSigner != nil && s.config.ServiceAccounts != nil {
+		s.mux.HandleFunc("/token", loggingMiddleware(s.shedder.middleware(s.rateLimiter.middleware(s.handleToken))))
+		if s.config.adminEnabled() {
+			s.mux.HandleFunc("/admin/service-accounts", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleServiceAccounts))))
+			s.mux.HandleFunc("/admin/service-accounts/", loggingMiddleware(s.shedder.middleware(adminMiddleware(s.config, s.lockout, s.handleServiceAccounts))))
+		}
+	}
 }
 
-// chain applies middleware in order: logging -> rate limit -> auth -> optional compression
+// singleTenant names the only tenant of a single-tenant server
+func singleTenant(*http.Request) string {
+	return "default"
+}
+
+// chain applies middleware in order: logging -> debug capture -> load shedding -> rate limit -> auth -> tenant rate limit -> optional compression
 func (s *Server) chain(handler http.HandlerFunc, enableCompression bool) http.HandlerFunc {
 	h := handler
 	if enableCompression {
 		h = compressionMiddleware(h)
 	}
+	if s.config.RecordMetadata {
+		h = metadataMiddleware(h)
+	}
+	h = s.tenantLimit.middleware(singleTenant, h)
 	h = s.authMiddleware(h)
 	h = s.rateLimiter.middleware(h)
+	h = s.shedder.middleware(h)
+	h = s.errors.middleware(h)
 	h = loggingMiddleware(h)
 	return h
 }
@@ -92,22 +236,45 @@ func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				apiKey = after
 			}
 		}
+		if s.lockout.blocked(w, r, apiKey) {
+			return
+		}
+
+		if !keysEqual(apiKey, s.apiKey) {
+			// Service account tokens of the default tenant
+			if claims, err := tokenClaims(s.config.TokenSigner, apiKey); err == nil && claims.Tenant == "default" {
+				setLogTenant(r, "default")
+				tokenScopeMiddleware(claims, next)(w, r)
+				return
+			}
+
+			// Identity provider JWTs of the default tenant
+			if id, err := jwtIdentity(r.Context(), s.config.JWTAuth, apiKey); err == nil && id.Tenant == "default" {
+				setLogTenant(r, "default")
+				next(w, r)
+				return
+			}
 
-		if apiKey != s.apiKey {
 			// Extract IP for logging
 			ip := r.RemoteAddr
 			if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
 				ip = strings.Split(forwarded, ",")[0]
 			}
 
-			slog.Warn("Authentication failed",
+			logger(r).Warn("Authentication failed",
+				"audit", true,
 				"ip", ip,
+				"key_fingerprint", keyFingerprint(apiKey),
 				"path", r.URL.Path,
 				"method", r.Method)
+			if apiKey != "" {
+				s.lockout.fail(r, apiKey)
+			}
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		setLogTenant(r, "default")
 		next(w, r)
 	}
 }
@@ -125,7 +292,7 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) saveEvent(w http.ResponseWriter, r *http.Request) {
-	saveEventHandler(w, r, s.store)
+	saveEventHandler(w, r, s.store, s.config.Pipelines["default"], s.typeStats, s.appends)
 }
 
 func (s *Server) loadEvents(w http.ResponseWriter, r *http.Request) {
@@ -134,43 +301,66 @@ func (s *Server) loadEvents(w http.ResponseWriter, r *http.Request) {
 
 // handleBatchEvents handles batch event insertion
 func (s *Server) handleBatchEvents(w http.ResponseWriter, r *http.Request) {
-	batchEventsHandler(w, r, s.store)
+	batchEventsHandler(w, r, s.store, s.config.Pipelines["default"], s.typeStats, s.appends)
 }
 
 // handleStreamEvents streams events for large replays
 func (s *Server) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
-	streamEventsHandler(w, r, s.store)
+	streamEventsHandler(w, r, s.store, s.config.HistoryThrottle)
+}
+
+// handleExport downloads events as NDJSON with Range support
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	exportHandler(w, r, s.store, s.config.HistoryThrottle)
+}
+
+// handleReplicate streams the log to a follower
+func (s *Server) handleReplicate(w http.ResponseWriter, r *http.Request) {
+	replicateHandler(w, r, s.store, s.appends)
+}
+
+// handleSubscribe tails new events as Server-Sent Events
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	subscribeHandler(w, r, s.store, s.appends)
+}
+
+// handleWS serves subscriptions with acknowledgements over a WebSocket
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	wsHandler(w, r, s.store, s.appends)
 }
 
 func (s *Server) handlePosition(w http.ResponseWriter, r *http.Request) {
 	positionHandler(w, r, s.store)
 }
 
+// handlePositionWait long-polls until the position reaches ?min=
+func (s *Server) handlePositionWait(w http.ResponseWriter, r *http.Request) {
+	positionWaitHandler(w, r, s.store, s.appends)
+}
+
 func (s *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
 	subscriptionsHandler(w, r, s.store)
 }
 
+// handleStreams loads events by stream ID
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	streamsHandler(w, r, s.store)
+}
 
-// handleHealth provides health check endpoint
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+// handleToken issues service account tokens
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	tokenHandler(w, r, s.lockout, s.config.ServiceAccounts, s.config.TokenSigner, s.config.TokenTTL)
+}
 
-	// Check database connectivity
-	_, err := s.store.GetPosition(ctx)
-	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "unhealthy",
-			"error":  err.Error(),
-		})
-		return
-	}
+// handleServiceAccounts manages service accounts of the default tenant
+func (s *Server) handleServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	serviceAccountsHandler(w, r, s.config.ServiceAccounts, func(tenant string) bool { return tenant == "default" })
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
-	})
+// handleReady reports whether the store answers and the replica is not
+// draining
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	readinessHandler(w, r, s.conns, s.config.DataDir, map[string]store.EventStore{"default": s.store})
 }
 
 // handleMetrics provides basic metrics
@@ -180,22 +370,148 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 
 	position, _ := s.store.GetPosition(ctx)
 
+	conns := s.conns.Snapshot(false)
+
+	metrics := map[string]any{
+		"total_events":     position,
+		"open_connections": conns.Open,
+		"active_streams":   conns.ActiveStreams["default"],
+		"timestamp":        time.Now().Unix(),
+	}
+	if sqliteStore, ok := store.As[*store.SQLiteStore](s.store); ok {
+		metrics["sqlite_busy"] = sqliteStore.BusyStats()
+		metrics["sqlite_wal"] = sqliteStore.WALStats()
+		metrics["sqlite_analyze"] = sqliteStore.MaintenanceStats()
+		metrics["position_gaps"] = sqliteStore.GapStats()
+	}
+	if reporter, ok := store.As[store.HealthReporter](s.store); ok {
+		if health, err := reporter.StorageHealth(ctx); err == nil {
+			metrics["storage"] = health
+		}
+	}
+	if shedding := s.shedder.stats(); shedding != nil {
+		metrics["load_shedding"] = shedding
+	}
+	if lockout := s.lockout.stats(); lockout != nil {
+		metrics["auth_lockout"] = lockout
+	}
+	if m, ok := s.config.Mirrors["default"]; ok {
+		metrics["mirror"] = m.Status()
+	}
+	if a, ok := s.config.Archivers["default"]; ok {
+		metrics["archive"] = a.Status()
+	}
+	if p, ok := s.config.Pruners["default"]; ok {
+		metrics["retention"] = p.Status()
+	}
+	if b, ok := s.config.Backups["default"]; ok {
+		metrics["backup"] = b.Status()
+	}
+	metrics["store_latency"] = s.latency.Latencies()
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"total_events": position,
-		"timestamp":    time.Now().Unix(),
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// handleTypeStats reports write counts per event type
+func (s *Server) handleTypeStats(w http.ResponseWriter, r *http.Request) {
+	typeStatsHandler(w, r, s.typeStats, "default")
+}
+
+// handleTimeseries reports events per minute and type from the store's rollups
+func (s *Server) handleTimeseries(w http.ResponseWriter, r *http.Request) {
+	timeseriesHandler(w, r, s.store)
+}
+
+// handleGaps lists ranges of missing positions
+func (s *Server) handleGaps(w http.ResponseWriter, r *http.Request) {
+	gapsHandler(w, r, s.store)
+}
+
+// handleRecovery reports the recovery scan run when the store was opened
+func (s *Server) handleRecovery(w http.ResponseWriter, r *http.Request) {
+	recoveryHandler(w, r, s.store)
+}
+
+// handleConnections lists open connections and active streams
+func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	connectionsHandler(w, r, s.conns)
+}
+
+// handleCompaction reports or triggers store compaction
+func (s *Server) handleCompaction(w http.ResponseWriter, r *http.Request) {
+	compactionHandler(w, r, s.store)
+}
+
+// handleBackup snapshots the store
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	backupHandler(w, r, s.store, s.config.Backups["default"])
+}
+
+// handleImports stages and publishes imports into the store
+func (s *Server) handleImports(w http.ResponseWriter, r *http.Request) {
+	importsHandler(w, r, s.imports, func(w http.ResponseWriter, name string) (store.EventStore, bool) {
+		if name != "" && name != "default" {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return nil, false
+		}
+		return s.store, true
 	})
 }
 
+// handleDigest returns range digests for anti-entropy checks
+func (s *Server) handleDigest(w http.ResponseWriter, r *http.Request) {
+	digestHandler(w, r, s.store)
+}
+
+// handleAggregate counts events by type and time bucket
+func (s *Server) handleAggregate(w http.ResponseWriter, r *http.Request) {
+	aggregateHandler(w, r, s.store)
+}
+
+// handleRepair overwrites diverged events
+func (s *Server) handleRepair(w http.ResponseWriter, r *http.Request) {
+	repairHandler(w, r, s.store)
+}
+
+// handleSchemaInfer infers a JSON Schema from stored events of a type
+func (s *Server) handleSchemaInfer(w http.ResponseWriter, r *http.Request) {
+	schemaInferHandler(w, r, s.store)
+}
+
+// handleRecentErrors lists failed requests kept by the debug capture
+func (s *Server) handleRecentErrors(w http.ResponseWriter, r *http.Request) {
+	recentErrorsHandler(w, r, s.errors)
+}
+
+// Drain fails health checks and ends active streams, so load balancers and
+// clients move to other replicas before the server shuts down
+func (s *Server) Drain() {
+	s.conns.Drain()
+}
+
+// WaitStreams blocks until all streams have ended or ctx is done
+func (s *Server) WaitStreams(ctx context.Context) error {
+	return s.conns.WaitStreams(ctx)
+}
+
+// Listener wraps ln so the server can track connections accepted from it
+func (s *Server) Listener(ln net.Listener) net.Listener {
+	return s.conns.Listener(ln)
+}
+
 // Close stops the server and cleans up resources
 func (s *Server) Close() error {
 	if s.rateLimiter != nil {
 		s.rateLimiter.Stop()
 	}
+	s.tenantLimit.Stop()
+	s.appends.Close()
+	s.imports.close()
 	return nil
 }
 
 // ServeHTTP implements http.Handler
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
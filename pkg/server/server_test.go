@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,6 +32,7 @@ func setupTestServer(t *testing.T) (*Server, func()) {
 	cleanup := func() {
 		sqliteStore.Close()
 		os.Remove(dbPath)
+		os.Remove(dbPath + ".lock")
 		os.Unsetenv("API_KEY")
 	}
 
@@ -112,6 +116,18 @@ func TestSaveEvent(t *testing.T) {
 	if savedEvent.Position == 0 {
 		t.Error("Expected position to be set")
 	}
+
+	// Events sent without a timestamp are stamped on arrival
+	req = httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"TestEvent","data":{}}`))
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if err := json.NewDecoder(rr.Body).Decode(&savedEvent); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if time.Since(savedEvent.Timestamp) > time.Minute {
+		t.Errorf("Expected the event to be stamped on arrival, got %v", savedEvent.Timestamp)
+	}
 }
 
 func TestLoadEvents(t *testing.T) {
@@ -149,6 +165,58 @@ func TestLoadEvents(t *testing.T) {
 	}
 }
 
+func TestLoadEvents_OpenRange(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	pebbleStore, err := store.NewPebbleStore(t.TempDir() + "/events")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	for name, st := range map[string]store.EventStore{"sqlite": sqliteStore, "pebble": pebbleStore} {
+		srv := NewWithStore(st, DefaultConfig(), "test-key-123")
+		defer srv.rateLimiter.Stop()
+
+		for i := 0; i < 5; i++ {
+			st.Save(context.Background(), &store.StoredEvent{Type: "TestEvent", Data: json.RawMessage(`{}`), Timestamp: time.Now()})
+		}
+
+		tests := []struct {
+			query       string
+			first, last int64
+		}{
+			{"from=2", 2, 5},
+			{"from=2&to=-1", 2, 5},
+			{"from=0&to=-1", 1, 5},
+			{"from=4&to=9223372036854775807", 4, 5},
+			{"from=6&to=-1", 0, 0},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, "/events?"+tt.query, nil)
+			req.Header.Set("X-API-Key", "test-key-123")
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, req)
+
+			var events []*store.StoredEvent
+			if err := json.NewDecoder(rr.Body).Decode(&events); err != nil || events == nil {
+				t.Fatalf("%s: %s: expected a JSON list, got %d: %v", name, tt.query, rr.Code, err)
+			}
+			var first, last int64
+			if len(events) > 0 {
+				first, last = events[0].Position, events[len(events)-1].Position
+			}
+			if first != tt.first || last != tt.last {
+				t.Errorf("%s: %s: expected %d-%d, got %d-%d", name, tt.query, tt.first, tt.last, first, last)
+			}
+		}
+	}
+}
+
 func TestGetPosition(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -216,3 +284,248 @@ func TestSubscriptionPosition(t *testing.T) {
 		}
 	})
 }
+
+func TestSubscriptionsExportImport(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/subscriptions", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, `{"subscriptions":{"billing":42,"search":7}}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"imported":2`) {
+		t.Fatalf("import: %d %s", rr.Code, rr.Body.String())
+	}
+	if pos, _ := srv.store.LoadSubscriptionPosition(context.Background(), "billing"); pos != 42 {
+		t.Errorf("billing position = %d, want 42", pos)
+	}
+
+	rr = do(http.MethodGet, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Subscriptions map[string]int64 `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Subscriptions) != 2 || result.Subscriptions["search"] != 7 {
+		t.Errorf("Expected billing and search, got %v", result.Subscriptions)
+	}
+
+	// Invalid imports save nothing
+	for _, body := range []string{`{"subscriptions":{"a":1,"b/c":2}}`, `{"subscriptions":{"a":-1}}`, `not json`} {
+		if rr := do(http.MethodPut, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("import of %s: status %d, want 400", body, rr.Code)
+		}
+	}
+	if pos, _ := srv.store.LoadSubscriptionPosition(context.Background(), "a"); pos != 0 {
+		t.Errorf("Invalid import saved position %d", pos)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		srv.store.Save(ctx, &store.StoredEvent{
+			Type:      "TestEvent",
+			Data:      json.RawMessage(`{"message":"test"}`),
+			Timestamp: time.Now(),
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events/stream?from=1&batch_size=2", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var events []*store.StoredEvent
+	if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+		t.Fatalf("Failed to decode stream: %v", err)
+	}
+	if len(events) != 5 {
+		t.Errorf("Expected 5 events, got %d", len(events))
+	}
+	if events[0].Position != 1 || string(events[0].Data) != `{"message":"test"}` {
+		t.Errorf("Unexpected first event: %+v", events[0])
+	}
+}
+
+func TestStreamEvents_NDJSON(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		srv.store.Save(ctx, &store.StoredEvent{
+			Type:      "TestEvent",
+			Data:      json.RawMessage(fmt.Sprintf(`{"index":%d}`, i)),
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Both the raw and the decoding stream paths write one event per line
+	stores := map[string]store.EventStore{
+		"raw":    srv.store,
+		"decode": plainStore{srv.store},
+	}
+	for name, st := range stores {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events/stream?from=2&batch_size=2", nil)
+			req.Header.Set("Accept", "application/x-ndjson")
+			rr := httptest.NewRecorder()
+			streamEventsHandler(rr, req, st, nil)
+
+			if got := rr.Header().Get("Content-Type"); got != "application/x-ndjson" {
+				t.Errorf("Unexpected Content-Type %q", got)
+			}
+			lines := strings.Split(rr.Body.String(), "\n")
+			if len(lines) != 5 || lines[4] != "" {
+				t.Fatalf("Expected 4 newline-terminated lines, got %q", rr.Body.String())
+			}
+			for i, line := range lines[:4] {
+				var event store.StoredEvent
+				if err := json.Unmarshal([]byte(line), &event); err != nil || event.Position != int64(i+2) {
+					t.Errorf("Unexpected line %q (%v)", line, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithStore(t *testing.T) {
+	pebbleStore, err := store.NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer pebbleStore.Close()
+
+	srv := NewWithStore(pebbleStore, DefaultConfig(), "test-key-123")
+	defer srv.rateLimiter.Stop()
+
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader([]byte(`{"type":"Test","data":{}}`)))
+	req.Header.Set("Authorization", "Bearer test-key-123")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer test-key-123")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var metrics map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&metrics); err != nil {
+		t.Fatalf("Failed to decode metrics: %v", err)
+	}
+	if metrics["total_events"] != float64(1) {
+		t.Errorf("expected 1 event, got %v", metrics["total_events"])
+	}
+	if _, ok := metrics["sqlite_wal"]; ok {
+		t.Error("expected no SQLite stats for a pebble store")
+	}
+	if storage, ok := metrics["storage"].(map[string]any); !ok || storage["backend"] != "pebble" {
+		t.Errorf("expected pebble storage health, got %v", metrics["storage"])
+	}
+	latency, _ := metrics["store_latency"].(map[string]any)
+	if position, ok := latency["get_position"].(map[string]any); !ok || position["count"].(float64) < 1 {
+		t.Errorf("expected GetPosition latencies, got %v", metrics["store_latency"])
+	}
+}
+
+func TestBatchEventsHandler_Range(t *testing.T) {
+	pebbleStore, err := store.NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer pebbleStore.Close()
+	srv := NewWithStore(pebbleStore, DefaultConfig(), "test-key-123")
+	defer srv.rateLimiter.Stop()
+
+	post := func(body string) map[string]int64 {
+		req := httptest.NewRequest(http.MethodPost, "/events/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key-123")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var result map[string]int64
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&result) != nil {
+			t.Errorf("status %d: %s", w.Code, w.Body)
+		}
+		return result
+	}
+
+	batch := "[" + strings.TrimSuffix(strings.Repeat(`{"type":"Test","data":{}},`, 50), ",") + "]"
+	var wg sync.WaitGroup
+	ranges := make([]map[string]int64, 4)
+	for i := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ranges[i] = post(batch)
+		}()
+	}
+	wg.Wait()
+
+	// Each batch owns its range, so the ranges cover the log exactly once
+	var covered int64
+	for _, r := range ranges {
+		if r["last_position"]-r["first_position"]+1 != 50 {
+			t.Errorf("Batch range %d-%d is not 50 positions", r["first_position"], r["last_position"])
+		}
+		covered += r["last_position"] - r["first_position"] + 1
+	}
+	if head, _ := pebbleStore.GetPosition(context.Background()); head != 200 || covered != 200 {
+		t.Errorf("Head %d, ranges cover %d positions, want 200", head, covered)
+	}
+
+	if empty := post("[]"); empty["saved"] != 0 || len(empty) != 1 {
+		t.Errorf("Empty batch response: %v", empty)
+	}
+}
+
+func TestBatchEventsHandler_KeepPositions(t *testing.T) {
+	pebbleStore, err := store.NewPebbleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer pebbleStore.Close()
+	srv := NewWithStore(pebbleStore, DefaultConfig(), "test-key-123")
+	defer srv.rateLimiter.Stop()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events/batch?positions=keep", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key-123")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`[{"position":3,"type":"Test","data":{}},{"position":7,"type":"Test","data":{}}]`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	events, _ := pebbleStore.Load(context.Background(), 1, 10)
+	if len(events) != 2 || events[0].Position != 3 || events[1].Position != 7 {
+		t.Errorf("Expected events kept at 3 and 7, got %+v", events)
+	}
+
+	// A retried import conflicts instead of saving twice
+	if w := post(`[{"position":7,"type":"Test","data":{}}]`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a position at the head, got %d: %s", w.Code, w.Body)
+	}
+}
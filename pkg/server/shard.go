@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+)
+
+// ForwardedHeader marks requests proxied from another node. A node never
+// forwards such a request again, so an inconsistent shard map fails with
+// 421 instead of bouncing a request between nodes.
+const ForwardedHeader = "X-Ebuse-Forwarded"
+
+// tenantRouter is implemented by tenant managers of sharded deployments,
+// which know the node owning tenants that are not served locally
+type tenantRouter interface {
+	RouteTenant(apiKey string) (name, baseURL string, ok bool)
+}
+
+// shardProxy forwards requests to the nodes owning remote tenants
+type shardProxy struct {
+	mu      sync.Mutex
+	proxies map[string]*httputil.ReverseProxy // base URL -> proxy
+}
+
+func newShardProxy() *shardProxy {
+	return &shardProxy{proxies: make(map[string]*httputil.ReverseProxy)}
+}
+
+// proxy returns the reverse proxy for a node, creating it on first use
+func (sp *shardProxy) proxy(baseURL string) (*httputil.ReverseProxy, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if p, ok := sp.proxies[baseURL]; ok {
+		return p, nil
+	}
+
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse shard URL: %w", err)
+	}
+
+	p := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			pr.SetXForwarded()
+			pr.Out.Header.Set(ForwardedHeader, "1")
+			pr.Out.Header.Set(RequestIDHeader, requestID(pr.In)) // Same ID in both nodes' logs
+		},
+		// Streams and exports must reach the client as they are produced
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger(r).Warn("Shard proxy failed", "target", baseURL, "path", r.URL.Path, "error", err)
+			http.Error(w, "Tenant's node is unavailable", http.StatusBadGateway)
+		},
+	}
+	sp.proxies[baseURL] = p
+	return p, nil
+}
+
+// isStreamPath reports whether path serves long-lived responses
+func isStreamPath(path string) bool {
+	return path == "/events/stream" || path == "/events/export" || path == "/replicate" || path == "/events/subscribe" || path == "/ws" || path == "/position/wait"
+}
+
+// forward proxies r to the node owning tenant. Streams are tracked like
+// local ones, so they count towards the tenant's limit and end on drain.
+func (s *MultiTenantServer) forward(w http.ResponseWriter, r *http.Request, tenant, baseURL string) {
+	if r.Header.Get(ForwardedHeader) != "" {
+		http.Error(w, fmt.Sprintf("Tenant %s is not served by this node", tenant), http.StatusMisdirectedRequest)
+		return
+	}
+
+	p, err := s.shards.proxy(baseURL)
+	if err != nil {
+		logger(r).Error("Invalid shard URL", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if isStreamPath(r.URL.Path) {
+		s.conns.streamLimitMiddleware(func(*http.Request) string { return tenant }, p.ServeHTTP)(w, r)
+		return
+	}
+	p.ServeHTTP(w, r)
+}
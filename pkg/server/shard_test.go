@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// shardedTenants serves some tenants locally and routes others by API key
+type shardedTenants struct {
+	namedTenants
+	remote map[string]string // API key -> node URL
+}
+
+func (s shardedTenants) RouteTenant(apiKey string) (string, string, bool) {
+	nodeURL, ok := s.remote[apiKey]
+	return apiKey, nodeURL, ok
+}
+
+func TestShardProxy(t *testing.T) {
+	aliceStore, err := store.NewSQLiteStore(t.TempDir() + "/alice.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer aliceStore.Close()
+	bobStore, err := store.NewSQLiteStore(t.TempDir() + "/bob.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer bobStore.Close()
+
+	// Node B owns bob; it wrongly believes carol lives on node A
+	nodeBTenants := shardedTenants{namedTenants: namedTenants{"bob": bobStore}, remote: map[string]string{}}
+	nodeB := NewMultiTenant(nodeBTenants, DefaultConfig())
+	defer nodeB.Close()
+	serverB := httptest.NewServer(nodeB)
+	defer serverB.Close()
+
+	// Node A owns alice and proxies bob and carol to node B
+	nodeA := NewMultiTenant(shardedTenants{
+		namedTenants: namedTenants{"alice": aliceStore},
+		remote:       map[string]string{"bob": serverB.URL, "carol": serverB.URL},
+	}, DefaultConfig())
+	defer nodeA.Close()
+	serverA := httptest.NewServer(nodeA)
+	defer serverA.Close()
+	nodeBTenants.remote["carol"] = serverA.URL
+
+	request := func(method, path, key string, body []byte) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, serverA.URL+path, bytes.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	event := []byte(`{"type":"Created","data":{}}`)
+	if resp := request(http.MethodPost, "/events", "bob", event); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected proxied save to succeed, got %d", resp.StatusCode)
+	}
+	if pos, _ := bobStore.GetPosition(context.Background()); pos != 1 {
+		t.Errorf("Expected the event in bob's store on node B, got position %d", pos)
+	}
+	if pos, _ := aliceStore.GetPosition(context.Background()); pos != 0 {
+		t.Errorf("Expected alice's store to be untouched, got position %d", pos)
+	}
+
+	if resp := request(http.MethodPost, "/events", "alice", event); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected local save to succeed, got %d", resp.StatusCode)
+	}
+	if resp := request(http.MethodGet, "/events/stream?from=1", "bob", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected proxied stream to succeed, got %d", resp.StatusCode)
+	}
+
+	// An inconsistent shard map is not forwarded in circles
+	if resp := request(http.MethodGet, "/position", "carol", nil); resp.StatusCode != http.StatusMisdirectedRequest {
+		t.Errorf("Expected %d for a forwarding loop, got %d", http.StatusMisdirectedRequest, resp.StatusCode)
+	}
+
+	if resp := request(http.MethodGet, "/position", "mallory", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected %d for an unknown key, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// priority classes requests for load shedding; lower values are shed first
+type priority int
+
+const (
+	priorityAdmin priority = iota
+	priorityRead
+	priorityCheckpoint
+	priorityWrite
+)
+
+func (p priority) String() string {
+	switch p {
+	case priorityWrite:
+		return "write"
+	case priorityCheckpoint:
+		return "checkpoint"
+	case priorityRead:
+		return "read"
+	default:
+		return "admin"
+	}
+}
+
+// Share of MaxInFlight each priority may fill before it is shed; writes may
+// use the full capacity so ingestion survives replay storms
+var priorityShare = [...]int{
+	priorityAdmin:      50,
+	priorityRead:       75,
+	priorityCheckpoint: 90,
+	priorityWrite:      100,
+}
+
+// classifyRequest assigns a load shedding priority to a request
+func classifyRequest(r *http.Request) priority {
+	path := r.URL.Path
+	switch {
+	case path == "/events" && r.Method == http.MethodPost, path == "/events/batch":
+		return priorityWrite
+	case strings.HasPrefix(path, "/subscriptions/"), path == "/subscriptions", path == "/token":
+		// Writers need fresh tokens to keep writing
+		return priorityCheckpoint
+	case path == "/events", path == "/events/stream", path == "/events/export", path == "/replicate", path == "/events/subscribe", path == "/ws", path == "/digest", path == "/events/aggregate", path == "/position", path == "/position/wait",
+		strings.HasPrefix(path, "/streams/"):
+		return priorityRead
+	default:
+		return priorityAdmin
+	}
+}
+
+// loadShedder rejects lower-priority requests once the number of in-flight
+// requests approaches maxInFlight
+type loadShedder struct {
+	inFlight atomic.Int64
+	limits   [len(priorityShare)]int64
+	shed     [len(priorityShare)]atomic.Int64
+}
+
+// newLoadShedder returns nil when maxInFlight is not positive, disabling shedding
+func newLoadShedder(maxInFlight int) *loadShedder {
+	if maxInFlight <= 0 {
+		return nil
+	}
+
+	ls := &loadShedder{}
+	for p, share := range priorityShare {
+		ls.limits[p] = max(int64(maxInFlight)*int64(share)/100, 1)
+	}
+	return ls
+}
+
+func (ls *loadShedder) middleware(next http.HandlerFunc) http.HandlerFunc {
+	if ls == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := classifyRequest(r)
+
+		if n := ls.inFlight.Add(1); n > ls.limits[p] {
+			ls.inFlight.Add(-1)
+			ls.shed[p].Add(1)
+
+			logger(r).Warn("Request shed under load",
+				"priority", p.String(),
+				"in_flight", n-1,
+				"path", r.URL.Path,
+				"method", r.Method)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer ls.inFlight.Add(-1)
+
+		next(w, r)
+	}
+}
+
+// stats reports in-flight requests and shed counts by priority
+func (ls *loadShedder) stats() map[string]any {
+	if ls == nil {
+		return nil
+	}
+
+	shed := make(map[string]int64, len(ls.shed))
+	for p := range ls.shed {
+		shed[priority(p).String()] = ls.shed[p].Load()
+	}
+	return map[string]any{
+		"in_flight": ls.inFlight.Load(),
+		"shed":      shed,
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifyRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   priority
+	}{
+		{http.MethodPost, "/events", priorityWrite},
+		{http.MethodPost, "/events/batch", priorityWrite},
+		{http.MethodPost, "/subscriptions/sub/position", priorityCheckpoint},
+		{http.MethodGet, "/subscriptions/sub/position", priorityCheckpoint},
+		{http.MethodPost, "/token", priorityCheckpoint},
+		{http.MethodGet, "/events", priorityRead},
+		{http.MethodGet, "/events/stream", priorityRead},
+		{http.MethodGet, "/position", priorityRead},
+		{http.MethodGet, "/metrics", priorityAdmin},
+		{http.MethodGet, "/admin/connections", priorityAdmin},
+	}
+
+	for _, tt := range tests {
+		if got := classifyRequest(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestLoadShedder(t *testing.T) {
+	ls := newLoadShedder(4) // limits: admin 2, read 3, checkpoint 3, write 4
+
+	// Hold three read streams in flight
+	release := make(chan struct{})
+	entered := make(chan struct{}, 3)
+	blocking := ls.middleware(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+	done := make(chan struct{})
+	for range 3 {
+		go func() {
+			blocking(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+			done <- struct{}{}
+		}()
+		<-entered
+	}
+
+	ok := ls.middleware(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(method, path string) int {
+		rr := httptest.NewRecorder()
+		ok(rr, httptest.NewRequest(method, path, nil))
+		return rr.Code
+	}
+
+	if code := serve(http.MethodGet, "/events"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected read to be shed, got %d", code)
+	}
+	if code := serve(http.MethodGet, "/metrics"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected admin request to be shed, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/events"); code != http.StatusOK {
+		t.Errorf("Expected write to be admitted, got %d", code)
+	}
+
+	close(release)
+	for range 3 {
+		<-done
+	}
+
+	if code := serve(http.MethodGet, "/events"); code != http.StatusOK {
+		t.Errorf("Expected read to be admitted after load drops, got %d", code)
+	}
+
+	stats := ls.stats()
+	shed := stats["shed"].(map[string]int64)
+	if shed["read"] != 1 || shed["admin"] != 1 || shed["write"] != 0 {
+		t.Errorf("Unexpected shed counts: %v", shed)
+	}
+	if stats["in_flight"].(int64) != 0 {
+		t.Errorf("Expected no requests in flight, got %v", stats["in_flight"])
+	}
+}
+
+func TestLoadShedderDisabled(t *testing.T) {
+	ls := newLoadShedder(0)
+	if ls != nil {
+		t.Fatal("Expected shedding to be disabled")
+	}
+	if ls.stats() != nil {
+		t.Error("Expected no stats when disabled")
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// storeLatency keeps an instrumented wrapper of each tenant's store, so
+// store call latencies accumulate across requests and show in /metrics
+type storeLatency struct {
+	mu      sync.Mutex
+	tenants map[string]*store.Instrumented
+}
+
+func newStoreLatency() *storeLatency {
+	return &storeLatency{tenants: make(map[string]*store.Instrumented)}
+}
+
+// wrap returns the instrumented store of tenant, starting over when the
+// tenant's store was replaced, e.g. by removing and re-adding the tenant
+func (l *storeLatency) wrap(tenant string, st store.EventStore) store.EventStore {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inst, ok := l.tenants[tenant]
+	if !ok || inst.Unwrap() != st {
+		inst = store.Instrument(st)
+		l.tenants[tenant] = inst
+	}
+	return inst
+}
+
+// latencies returns the store call latencies of tenant, or nil before its
+// first request
+func (l *storeLatency) latencies(tenant string) map[string]store.LatencyStats {
+	l.mu.Lock()
+	inst, ok := l.tenants[tenant]
+	l.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return inst.Latencies()
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// streamsHandler serves the events of one stream:
+//
+//	GET /streams/{id}/events?from_version=&limit=&as_of=  events in version order
+//	GET /streams/{id}/version                             the stream's last version
+//
+// Stream IDs may contain slashes, e.g. "orders/42"; clients escape other
+// reserved characters.
+func streamsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	index, ok := store.As[store.StreamIndex](st)
+	if !ok {
+		http.Error(w, "Streams not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/streams/")
+	streamID, action, found := cutLast(path, "/")
+	if !found || streamID == "" || (action != "events" && action != "version") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	if err := store.ValidateStreamID(streamID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if action == "version" {
+		version, err := index.StreamVersion(ctx, streamID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get stream version: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"stream_id": streamID, "version": version})
+		return
+	}
+
+	query := r.URL.Query()
+	fromVersion, limit := int64(1), 0
+	var err error
+	if s := query.Get("from_version"); s != "" {
+		if fromVersion, err = strconv.ParseInt(s, 10, 64); err != nil || fromVersion < 1 {
+			http.Error(w, "Invalid 'from_version' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	asOf, ok := asOfPosition(w, r, st)
+	if !ok {
+		return
+	}
+
+	events, err := index.LoadByStream(ctx, streamID, fromVersion, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load stream: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []*store.StoredEvent{}
+	}
+
+	if asOf >= 0 {
+		events = truncateAsOf(events, asOf)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
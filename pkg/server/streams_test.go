@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestStreamsEndpoints(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do(http.MethodPost, "/events", `{"type":"Created","data":{},"stream_id":"orders/42"}`)
+	do(http.MethodPost, "/events/batch", `[{"type":"Paid","data":{},"stream_id":"orders/42"},{"type":"Tick","data":{}},{"type":"Shipped","data":{},"stream_id":"orders/42"}]`)
+
+	rr := do(http.MethodGet, "/streams/orders/42/events?from_version=2", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var events []*store.StoredEvent
+	if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "Paid" || events[0].StreamVersion != 2 || events[1].Position != 4 {
+		t.Fatalf("Expected Paid and Shipped at versions 2-3, got %+v", events)
+	}
+
+	rr = do(http.MethodGet, "/streams/orders/42/version", "")
+	var version struct {
+		StreamID string `json:"stream_id"`
+		Version  int64  `json:"version"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&version); err != nil || version.StreamID != "orders/42" || version.Version != 3 {
+		t.Errorf("Expected orders/42 at version 3, got %+v, %v", version, err)
+	}
+
+	if rr := do(http.MethodGet, "/streams/unknown/events", ""); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected an empty list for an unknown stream, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, path := range []string{"/streams/orders/42", "/streams/events", "/streams/orders/42/events?limit=0", "/streams/orders/42/events?from_version=x"} {
+		if rr := do(http.MethodGet, path, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+
+	long := strings.Repeat("x", store.MaxStreamIDBytes+1)
+	if rr := do(http.MethodPost, "/events", `{"type":"A","data":{},"stream_id":"`+long+`"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized stream ID, got %d", rr.Code)
+	}
+}
+
+func TestStreamsEndpoint_Unsupported(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/streams/orders/events", nil)
+	rr := httptest.NewRecorder()
+	streamsHandler(rr, req, plainStore{sqliteStore})
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// DefaultSubscribeHeartbeat is how often an idle /events/subscribe stream
+// gets a comment line, so proxies don't close it
+const DefaultSubscribeHeartbeat = 15 * time.Second
+
+// subscribeRetry is the reconnect delay suggested to EventSource clients,
+// e.g. after the server drains
+const subscribeRetry = time.Second
+
+// subscribeHandler tails the log as Server-Sent Events. Each event is sent
+// with its position as the SSE id, so a reconnecting client resumes right
+// after the last event it saw through the Last-Event-ID header.
+//
+// Without Last-Event-ID the stream starts at ?from=, or at the next event to
+// be written when from is not set. The filter parameters of /events select
+// the events sent; skipped events still move the stream on. Like /replicate, a start beyond the head
+// of the log is rejected with 409.
+func subscribeHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, appends *fanout.Hub) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	next := int64(0) // 0 = head + 1
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		last, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil || last < 0 {
+			http.Error(w, "Invalid 'Last-Event-ID' header", http.StatusBadRequest)
+			return
+		}
+		next = last + 1
+	} else if fromStr := query.Get("from"); fromStr != "" {
+		from, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil || from < 1 {
+			http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+			return
+		}
+		next = from
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	heartbeat := DefaultSubscribeHeartbeat
+	if hbStr := query.Get("heartbeat"); hbStr != "" {
+		hb, err := time.ParseDuration(hbStr)
+		if err != nil || hb <= 0 {
+			http.Error(w, "Invalid 'heartbeat' parameter", http.StatusBadRequest)
+			return
+		}
+		heartbeat = max(hb, minReplicationBeat)
+	}
+
+	ctx := r.Context()
+	tenant := requestTenant(r)
+
+	appended := appends.Wait(tenant)
+	head, err := st.GetPosition(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get position: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if next == 0 {
+		next = head + 1
+	}
+	if next > head+1 {
+		http.Error(w, fmt.Sprintf("Position is ahead of the log (head %d)", head), http.StatusConflict)
+		return
+	}
+
+	keepStreaming(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would otherwise hold events back
+	w.WriteHeader(http.StatusOK)
+
+	flush := func() {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", subscribeRetry.Milliseconds()); err != nil {
+		return
+	}
+	flush()
+	lastSent := time.Now()
+
+	for {
+		if next <= head {
+			to := min(head, next+int64(DefaultReplicationBatch)-1)
+			events, err := loadReplicationBatch(ctx, st, next, to)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger(r).Error("Subscription load failed", "error", err)
+				}
+				return
+			}
+			if events, err = filterRaw(filter, events); err != nil {
+				logger(r).Error("Subscription event is unreadable", "error", err)
+				return
+			}
+			for _, data := range events {
+				position, err := rawPosition(data)
+				if err != nil {
+					logger(r).Error("Subscription event is unreadable", "error", err)
+					return
+				}
+				// Each event must fit on one data line; stored payloads keep
+				// the formatting they were written with
+				if bytes.ContainsAny(data, "\r\n") {
+					var compact bytes.Buffer
+					if err := json.Compact(&compact, data); err != nil {
+						logger(r).Error("Subscription event is unreadable", "error", err)
+						return
+					}
+					data = compact.Bytes()
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", position, data); err != nil {
+					return
+				}
+			}
+			next = to + 1
+			flush()
+			lastSent = time.Now()
+			continue
+		}
+
+		if time.Since(lastSent) >= heartbeat {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flush()
+			lastSent = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-appended:
+		case <-time.After(replicationPollPeriod):
+		}
+
+		appended = appends.Wait(tenant)
+		if head, err = st.GetPosition(ctx); err != nil {
+			if ctx.Err() == nil {
+				logger(r).Error("Subscription position read failed", "error", err)
+			}
+			return
+		}
+	}
+}
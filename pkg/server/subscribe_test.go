@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// sseMessage is one message of a text/event-stream, with comments ignored
+type sseMessage struct {
+	id, data, retry string
+}
+
+type sseReader struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+}
+
+func startSubscribe(t *testing.T, st store.EventStore, query string, lastEventID string) *sseReader {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscribeHandler(w, r, st, nil)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events/subscribe?"+query, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+	return &sseReader{t: t, scanner: bufio.NewScanner(resp.Body)}
+}
+
+// next returns the next message, or a message without fields for a comment
+func (sr *sseReader) next() sseMessage {
+	sr.t.Helper()
+	var msg sseMessage
+	for sr.scanner.Scan() {
+		line := sr.scanner.Text()
+		if line == "" {
+			return msg
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			msg.id = value
+		case "data":
+			msg.data = value
+		case "retry":
+			msg.retry = value
+		}
+	}
+	sr.t.Fatalf("Stream ended: %v", sr.scanner.Err())
+	return msg
+}
+
+// nextEvent skips heartbeats and returns the next event message
+func (sr *sseReader) nextEvent() sseMessage {
+	sr.t.Helper()
+	for {
+		if msg := sr.next(); msg.id != "" {
+			return msg
+		}
+	}
+}
+
+func TestSubscribe_TailAndResume(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/subscribe.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 3)
+
+	// Without a start position only new events are sent
+	sr := startSubscribe(t, st, "heartbeat=100ms", "")
+	if msg := sr.next(); msg.retry != "1000" {
+		t.Fatalf("Expected a retry hint first, got %+v", msg)
+	}
+	if msg := sr.next(); msg.id != "" {
+		t.Fatalf("Expected a heartbeat while idle, got %+v", msg)
+	}
+
+	if err := st.Save(context.Background(), &store.StoredEvent{Type: "Pretty", Data: json.RawMessage("{\n  \"a\": 1\n}"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	msg := sr.nextEvent()
+	if msg.id != "4" {
+		t.Fatalf("Expected event 4, got %+v", msg)
+	}
+	var event store.StoredEvent
+	if err := json.Unmarshal([]byte(msg.data), &event); err != nil {
+		t.Fatalf("Bad event data %q: %v", msg.data, err)
+	}
+	if event.Position != 4 || event.Type != "Pretty" {
+		t.Errorf("Expected event 4 of type Pretty, got %+v", event)
+	}
+
+	// Last-Event-ID wins over from
+	sr = startSubscribe(t, st, "from=1", "2")
+	for _, want := range []string{"3", "4"} {
+		if msg := sr.nextEvent(); msg.id != want {
+			t.Errorf("Expected event %s, got %+v", want, msg)
+		}
+	}
+
+	// Stores without raw streaming are served too
+	sr = startSubscribe(t, plainStore{st}, "from=2", "")
+	for _, want := range []string{"2", "3", "4"} {
+		if msg := sr.nextEvent(); msg.id != want {
+			t.Errorf("Expected event %s, got %+v", want, msg)
+		}
+	}
+}
+
+func TestSubscribe_Filter(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/subscribe.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	for _, typ := range []string{"Order.Placed", "Tick", "Order.Paid", "Tick"} {
+		if err := st.Save(context.Background(), &store.StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	sr := startSubscribe(t, st, "from=1&type=Order.*", "")
+	for _, want := range []string{"1", "3"} {
+		if msg := sr.nextEvent(); msg.id != want {
+			t.Errorf("Expected event %s, got %+v", want, msg)
+		}
+	}
+	// Skipped events don't hold the stream back
+	if err := st.Save(context.Background(), &store.StoredEvent{Type: "Order.Shipped", Data: json.RawMessage(`{}`), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if msg := sr.nextEvent(); msg.id != "5" {
+		t.Errorf("Expected event 5, got %+v", msg)
+	}
+}
+
+func TestSubscribe_InvalidRequests(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/subscribe.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 2)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		lastEventID string
+		status      int
+	}{
+		{"invalid from", http.MethodGet, "/events/subscribe?from=0", "", http.StatusBadRequest},
+		{"invalid filter", http.MethodGet, "/events/subscribe?meta.region=", "", http.StatusBadRequest},
+		{"invalid heartbeat", http.MethodGet, "/events/subscribe?heartbeat=soon", "", http.StatusBadRequest},
+		{"invalid last event ID", http.MethodGet, "/events/subscribe", "abc", http.StatusBadRequest},
+		{"from ahead of the log", http.MethodGet, "/events/subscribe?from=4", "", http.StatusConflict},
+		{"last event ID ahead of the log", http.MethodGet, "/events/subscribe", "3", http.StatusConflict},
+		{"wrong method", http.MethodPost, "/events/subscribe", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			subscribeHandler(w, req, st, nil)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSubscribe_FlushesThroughMiddleware(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/subscribe.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	config := DefaultConfig()
+	config.DebugCapture = 10
+	srv := NewWithStore(st, config, "test-key-123")
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events/subscribe", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The retry hint is far below any write buffer, so it only arrives if
+	// every middleware passes Flush on
+	sr := &sseReader{t: t, scanner: bufio.NewScanner(resp.Body)}
+	if msg := sr.next(); msg.retry != "1000" {
+		t.Errorf("Expected a retry hint, got %+v", msg)
+	}
+}
+
+func TestSubscribe_OutlivesWriteTimeout(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/subscribe.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	config := DefaultConfig()
+	config.DebugCapture = 10
+	srv := NewWithStore(st, config, "test-key-123")
+	defer srv.Close()
+	ts := httptest.NewUnstartedServer(srv)
+	ts.Config.WriteTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	for _, path := range []string{"/events/subscribe?heartbeat=100ms", "/replicate?heartbeat=100ms"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", "test-key-123")
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		// Heartbeats keep arriving long after the server's write timeout
+		reader := bufio.NewReader(resp.Body)
+		deadline := time.Now().Add(600 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			if _, err := reader.ReadString('\n'); err != nil {
+				t.Fatalf("%s: stream ended after the write timeout: %v", path, err)
+			}
+		}
+	}
+}
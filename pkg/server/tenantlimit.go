@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jilio/ebuse/internal/ratelimit"
+)
+
+// tenantLimiter enforces per-tenant request limits. With a shared store the
+// limits hold across all replicas; otherwise each replica counts alone.
+// Tenants with a burst above their limit may reach it in one second once
+// they were idle, see ratelimit.Limiter.AllowBurst.
+type tenantLimiter struct {
+	mu      sync.RWMutex
+	limits  map[string]int
+	bursts  map[string]int
+	limiter *ratelimit.Limiter
+}
+
+// newTenantLimiter returns nil when no tenant has a limit
+func newTenantLimiter(limits, bursts map[string]int, store ratelimit.Store) *tenantLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	return &tenantLimiter{
+		limits:  limits,
+		bursts:  bursts,
+		limiter: ratelimit.New(store, ratelimit.DefaultSyncInterval),
+	}
+}
+
+// middleware rejects requests of tenants over their limit. It runs after
+// authentication, which determines the tenant.
+func (tl *tenantLimiter) middleware(tenant func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	if tl == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := tenant(r)
+		if limit, burst := tl.limit(name); limit > 0 && !tl.limiter.AllowBurst(name, limit, burst) {
+			logger(r).Warn("Tenant rate limit exceeded",
+				"limit", limit,
+				"path", r.URL.Path,
+				"method", r.Method)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Tenant rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limit returns the requests per second allowed to tenant (0 = unlimited)
+// and its burst
+func (tl *tenantLimiter) limit(tenant string) (int, int) {
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+	limit := tl.limits[tenant]
+	return limit, max(tl.bursts[tenant], limit)
+}
+
+// setLimits replaces the limits and bursts of all tenants
+func (tl *tenantLimiter) setLimits(limits, bursts map[string]int) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.limits, tl.bursts = limits, bursts
+}
+
+// Stop stops syncing counts with the shared store
+func (tl *tenantLimiter) Stop() {
+	if tl != nil {
+		tl.limiter.Close()
+	}
+}
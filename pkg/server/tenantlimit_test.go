@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// exhaustedStore is a rate limit store whose other replicas have used up
+// every limit
+type exhaustedStore struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (e *exhaustedStore) Add(ctx context.Context, counts map[string]int64, ttl time.Duration) (map[string]int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	totals := make(map[string]int64, len(counts))
+	for key, n := range counts {
+		e.keys = append(e.keys, key)
+		totals[key] = n + 1000000
+	}
+	return totals, nil
+}
+
+// untilLimited sends requests as apiKey every interval until one is
+// rejected, and returns that response, or nil after max requests
+func untilLimited(handler http.Handler, apiKey string, interval time.Duration, max int) *httptest.ResponseRecorder {
+	for range max {
+		req := httptest.NewRequest(http.MethodGet, "/position", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			return rr
+		}
+		time.Sleep(interval)
+	}
+	return nil
+}
+
+func TestTenantRateLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimit = 100000 // Keep the per-IP limit out of the way
+	config.RateBurst = 100000
+	config.TenantRateLimits = map[string]int{"alice": 5}
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore(), "bob": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	rr := untilLimited(srv, "alice", 0, 50)
+	if rr == nil {
+		t.Fatal("Expected alice to be limited")
+	}
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 429 with Retry-After, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := untilLimited(srv, "bob", 0, 50); rr != nil {
+		t.Errorf("Expected bob to be unlimited, got %d", rr.Code)
+	}
+
+	// Failed authentication is answered before the tenant limit applies
+	if rr := untilLimited(srv, "mallory", 0, 1); rr == nil || rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown key, got %v", rr)
+	}
+}
+
+func TestTenantRateLimit_Burst(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimit = 100000
+	config.RateBurst = 100000
+	config.TenantRateLimits = map[string]int{"alice": 2, "bob": 2}
+	config.TenantRateBursts = map[string]int{"bob": 6}
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore(), "bob": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	// Both can send at least their limit, bob up to his burst; a second
+	// boundary may fall between the requests
+	admitted := func(apiKey string) int {
+		for n := range 50 {
+			if untilLimited(srv, apiKey, 0, 1) != nil {
+				return n
+			}
+		}
+		return 50
+	}
+	if n := admitted("alice"); n < 2 || n > 4 {
+		t.Errorf("Expected alice limited to 2 a second, admitted %d", n)
+	}
+	if n := admitted("bob"); n < 6 || n > 12 {
+		t.Errorf("Expected bob to burst to 6, admitted %d", n)
+	}
+}
+
+func TestTenantRateLimit_Shared(t *testing.T) {
+	shared := &exhaustedStore{}
+	config := DefaultConfig()
+	config.TenantRateLimits = map[string]int{"default": 1000}
+	config.RateLimitStore = shared
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	// A few requests a second stay far below the limit on this replica, so
+	// only the counts of the other replicas can reject them
+	if rr := untilLimited(srv, "test-key-123", 20*time.Millisecond, 100); rr == nil || rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the shared counts to limit the tenant, got %v", rr)
+	}
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if len(shared.keys) == 0 || !strings.HasPrefix(shared.keys[0], "default:") {
+		t.Errorf("Expected counts keyed by tenant, got %v", shared.keys)
+	}
+}
+
+func TestSetTenantSettings(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimit = 100000
+	config.RateBurst = 100000
+	config.TenantRateLimits = map[string]int{"alice": 5}
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore(), "bob": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	p, err := pipeline.New(pipeline.Config{Allow: []pipeline.TypeRule{{Type: "OrderPlaced"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetTenantSettings(map[string]*pipeline.Pipeline{"bob": p}, map[string]int{"bob": 5}, nil); err != nil {
+		t.Fatalf("SetTenantSettings failed: %v", err)
+	}
+
+	if rr := untilLimited(srv, "alice", 0, 50); rr != nil {
+		t.Errorf("Expected alice to be unlimited, got %d", rr.Code)
+	}
+	if rr := untilLimited(srv, "bob", 0, 50); rr == nil {
+		t.Error("Expected bob to be limited")
+	}
+
+	time.Sleep(time.Second) // Into a new window
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"DebugPing","data":{}}`))
+	req.Header.Set("X-API-Key", "bob")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected bob's new pipeline to refuse the event, got %d", rr.Code)
+	}
+
+	// Limits cannot be introduced into a server started without any
+	unlimited := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore()}, DefaultConfig())
+	defer unlimited.Close()
+	if err := unlimited.SetTenantSettings(nil, map[string]int{"alice": 5}, nil); err == nil {
+		t.Error("Expected an error for limits without a limiter")
+	}
+}
+
+func TestRateLimiter_PerKey(t *testing.T) {
+	rl := newRateLimiter(1, 1)
+	defer rl.Stop()
+	handler := rl.middleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/position", nil)
+		req.RemoteAddr = "203.0.113.1:1234" // One NAT for everyone
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr.Code
+	}
+
+	if serve("X-API-Key", "alice") != http.StatusOK || serve("X-API-Key", "alice") != http.StatusTooManyRequests {
+		t.Fatal("Expected alice limited after one request")
+	}
+	// Other keys and tokens behind the same address have their own limit
+	if code := serve("X-API-Key", "bob"); code != http.StatusOK {
+		t.Errorf("Expected bob unaffected by alice, got %d", code)
+	}
+	if code := serve("Authorization", "Bearer token-1"); code != http.StatusOK {
+		t.Errorf("Expected a bearer token unaffected by alice, got %d", code)
+	}
+	if serve("", "") != http.StatusOK || serve("", "") != http.StatusTooManyRequests {
+		t.Error("Expected requests without a key limited by address")
+	}
+}
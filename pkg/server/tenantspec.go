@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ErrInvalidTenantSpec is returned by TenantSpecStore.Reconcile for specs
+// that would not load, which /admin/tenants/spec answers with 400
+var ErrInvalidTenantSpec = errors.New("invalid tenant spec")
+
+// TenantSpec is a tenant as declared at /admin/tenants/spec
+type TenantSpec struct {
+	Name         string `json:"name"`
+	APIKey       string `json:"api_key"`
+	Template     string `json:"template,omitempty"`
+	StoreBackend string `json:"store_backend,omitempty"`
+	Shard        string `json:"shard,omitempty"` // Node owning the tenant in sharded mode
+}
+
+// TenantSpecChange is one change reconciling the registered tenants with a spec
+type TenantSpecChange struct {
+	Action string   `json:"action"` // "create", "update" or "delete"
+	Tenant string   `json:"tenant"`
+	Fields []string `json:"fields,omitempty"` // Fields an update changes
+}
+
+// TenantSpecStore is the tenant control plane managed at /admin/tenants/spec
+type TenantSpecStore interface {
+	// Spec returns the registered tenants
+	Spec(ctx context.Context) ([]TenantSpec, error)
+	// Reconcile makes the registered tenants exactly those of spec and
+	// returns the changes; with dryRun nothing is changed
+	Reconcile(ctx context.Context, spec []TenantSpec, dryRun bool) ([]TenantSpecChange, error)
+}
+
+// handleTenantSpec serves the declared tenant set: GET returns it with API
+// keys replaced by fingerprints, PUT replaces it. PUT is idempotent, and
+// with ?dry_run=true only reports what it would change, so deploy pipelines
+// and Terraform-style providers can plan before they apply.
+func (s *MultiTenantServer) handleTenantSpec(w http.ResponseWriter, r *http.Request) {
+	specs := s.config.TenantSpecs
+
+	switch r.Method {
+	case http.MethodGet:
+		tenants, err := specs.Spec(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list := make([]map[string]string, 0, len(tenants))
+		for _, tenant := range tenants {
+			list = append(list, map[string]string{
+				"name":                tenant.Name,
+				"api_key_fingerprint": keyFingerprint(tenant.APIKey),
+				"template":            tenant.Template,
+				"store_backend":       tenant.StoreBackend,
+				"shard":               tenant.Shard,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"tenants": list})
+
+	case http.MethodPut:
+		var req struct {
+			Tenants []TenantSpec `json:"tenants"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		changes, err := specs.Reconcile(r.Context(), req.Tenants, dryRun)
+		switch {
+		case errors.Is(err, ErrInvalidTenantSpec):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logger(r).Error("Tenant spec reconcile failed", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if changes == nil {
+			changes = []TenantSpecChange{}
+		}
+
+		// Tenants are created and deleted at once, and running tenants pick
+		// up new API keys; other changes take effect at the next restart
+		restart := false
+		for _, change := range changes {
+			if change.Action == "update" && slices.ContainsFunc(change.Fields, func(field string) bool { return field != "api_key" }) {
+				restart = true
+			}
+		}
+		if !dryRun && len(changes) > 0 {
+			logger(r).Info("Tenant spec applied",
+				"audit", true,
+				"changes", changes,
+				"restart_required", restart)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"dry_run":          dryRun,
+			"changes":          changes,
+			"restart_required": restart,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
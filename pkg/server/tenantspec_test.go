@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// memorySpecs is a TenantSpecStore kept in memory
+type memorySpecs map[string]TenantSpec
+
+func (m memorySpecs) Spec(ctx context.Context) ([]TenantSpec, error) {
+	var tenants []TenantSpec
+	for _, tenant := range m {
+		tenants = append(tenants, tenant)
+	}
+	slices.SortFunc(tenants, func(a, b TenantSpec) int { return strings.Compare(a.Name, b.Name) })
+	return tenants, nil
+}
+
+func (m memorySpecs) Reconcile(ctx context.Context, spec []TenantSpec, dryRun bool) ([]TenantSpecChange, error) {
+	wanted := make(map[string]TenantSpec, len(spec))
+	var changes []TenantSpecChange
+	for _, tenant := range spec {
+		if tenant.APIKey == "" {
+			return nil, fmt.Errorf("%w: tenant %s: API key cannot be empty", ErrInvalidTenantSpec, tenant.Name)
+		}
+		wanted[tenant.Name] = tenant
+		existing, ok := m[tenant.Name]
+		if !ok {
+			changes = append(changes, TenantSpecChange{Action: "create", Tenant: tenant.Name})
+			continue
+		}
+		var fields []string
+		if existing.APIKey != tenant.APIKey {
+			fields = append(fields, "api_key")
+		}
+		if existing.StoreBackend != tenant.StoreBackend {
+			fields = append(fields, "store_backend")
+		}
+		if fields != nil {
+			changes = append(changes, TenantSpecChange{Action: "update", Tenant: tenant.Name, Fields: fields})
+		}
+	}
+	for name := range m {
+		if _, ok := wanted[name]; !ok {
+			changes = append(changes, TenantSpecChange{Action: "delete", Tenant: name})
+		}
+	}
+	if !dryRun {
+		clear(m)
+		for name, tenant := range wanted {
+			m[name] = tenant
+		}
+	}
+	return changes, nil
+}
+
+func TestTenantSpec(t *testing.T) {
+	specs := memorySpecs{
+		"alice": {Name: "alice", APIKey: "alice"},
+		"bob":   {Name: "bob", APIKey: "bob"},
+	}
+	config := DefaultConfig()
+	config.AdminKey = "admin-secret"
+	config.AdminOperatorKey = "operator-secret"
+	config.TenantSpecs = specs
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore(), "bob": store.NewMemoryStore()}, config)
+	defer srv.Close()
+
+	request := func(method, path, key, body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", key)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var result map[string]any
+		json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+
+	// Reading shows key fingerprints, never the keys
+	code, result := request(http.MethodGet, "/admin/tenants/spec", "operator-secret", "")
+	tenants, _ := result["tenants"].([]any)
+	if code != http.StatusOK || len(tenants) != 2 {
+		t.Fatalf("Expected two tenants, got %d %v", code, result)
+	}
+	if first := tenants[0].(map[string]any); first["name"] != "alice" || first["api_key_fingerprint"] != keyFingerprint("alice") || first["api_key"] != nil {
+		t.Errorf("Expected alice with a key fingerprint, got %v", first)
+	}
+
+	// Only owners change the tenant set
+	spec := `{"tenants": [{"name": "alice", "api_key": "alice-2"}, {"name": "carol", "api_key": "carol"}]}`
+	if code, _ := request(http.MethodPut, "/admin/tenants/spec", "operator-secret", spec); code != http.StatusForbidden {
+		t.Errorf("Expected an operator PUT to be refused, got %d", code)
+	}
+
+	// A dry run plans without applying; created and deleted tenants need no
+	// restart
+	code, result = request(http.MethodPut, "/admin/tenants/spec?dry_run=true", "admin-secret", spec)
+	if changes, _ := result["changes"].([]any); code != http.StatusOK || result["dry_run"] != true || len(changes) != 3 || result["restart_required"] != false {
+		t.Errorf("Expected three planned changes, got %d %v", code, result)
+	}
+	if _, ok := specs["bob"]; !ok {
+		t.Fatal("Expected a dry run to leave bob registered")
+	}
+
+	code, result = request(http.MethodPut, "/admin/tenants/spec", "admin-secret", spec)
+	if changes, _ := result["changes"].([]any); code != http.StatusOK || result["dry_run"] != false || len(changes) != 3 {
+		t.Errorf("Expected three applied changes, got %d %v", code, result)
+	}
+	if specs["alice"].APIKey != "alice-2" || specs["carol"].Name != "carol" || len(specs) != 2 {
+		t.Errorf("Expected the spec to be applied, got %v", specs)
+	}
+
+	// Applying it again changes nothing; a key rotation alone needs no restart
+	code, result = request(http.MethodPut, "/admin/tenants/spec", "admin-secret", spec)
+	if changes, _ := result["changes"].([]any); code != http.StatusOK || changes == nil || len(changes) != 0 || result["restart_required"] != false {
+		t.Errorf("Expected no changes, got %d %v", code, result)
+	}
+	code, result = request(http.MethodPut, "/admin/tenants/spec", "admin-secret", `{"tenants": [{"name": "alice", "api_key": "alice-3"}, {"name": "carol", "api_key": "carol"}]}`)
+	if code != http.StatusOK || result["restart_required"] != false {
+		t.Errorf("Expected a key rotation without restart, got %d %v", code, result)
+	}
+	code, result = request(http.MethodPut, "/admin/tenants/spec?dry_run=true", "admin-secret", `{"tenants": [{"name": "alice", "api_key": "alice-3", "store_backend": "sqlite"}, {"name": "carol", "api_key": "carol"}]}`)
+	if code != http.StatusOK || result["restart_required"] != true {
+		t.Errorf("Expected a store backend change to need a restart, got %d %v", code, result)
+	}
+
+	// Invalid specs and bodies are refused
+	if code, _ := request(http.MethodPut, "/admin/tenants/spec", "admin-secret", `{"tenants": [{"name": "dave"}]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid spec to be refused, got %d", code)
+	}
+	if code, _ := request(http.MethodPut, "/admin/tenants/spec", "admin-secret", `{"tenants": [{"name": "dave", "apikey": "dave"}]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown field to be refused, got %d", code)
+	}
+}
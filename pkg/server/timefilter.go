@@ -0,0 +1,34 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// parseTimeRange reads the since and until parameters of a read as RFC 3339
+// timestamps. Either may be left out for an open side; set reports whether
+// any was given.
+func parseTimeRange(r *http.Request) (since, until time.Time, set bool, err error) {
+	query := r.URL.Query()
+	parse := func(name string) (time.Time, error) {
+		s := query.Get(name)
+		if s == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, errors.New("Invalid '" + name + "' parameter, expected an RFC 3339 timestamp")
+		}
+		set = true
+		return t, nil
+	}
+
+	if since, err = parse("since"); err != nil {
+		return since, until, false, err
+	}
+	if until, err = parse("until"); err != nil {
+		return since, until, false, err
+	}
+	return since, until, set, nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestTimeFilter(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do("/events/batch", `[
+		{"type":"A","data":{},"timestamp":"2026-01-02T13:00:00Z"},
+		{"type":"A","data":{},"timestamp":"2026-01-02T15:30:00+01:00"},
+		{"type":"A","data":{},"timestamp":"2026-01-02T14:00:00Z"},
+		{"type":"A","data":{},"timestamp":"2026-01-03T09:00:00Z"}
+	]`)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/events?since=2026-01-02T14:00:00Z", "[2 3 4]"},
+		{"/events?since=2026-01-02T15:00:00%2B01:00&until=2026-01-03T00:00:00Z", "[2 3]"},
+		{"/events?until=2026-01-02T14:00:00Z", "[1]"},
+		{"/events?from=3&since=2026-01-02T14:00:00Z", "[3 4]"},
+		{"/events?from=1&to=3&since=2026-01-02T14:30:00.5Z", "[]"},
+	}
+	for _, tt := range tests {
+		rr := do(tt.path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.path, rr.Code, rr.Body.String())
+		}
+		var events []*store.StoredEvent
+		if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.path, err)
+		}
+		positions := make([]int64, len(events))
+		for i, event := range events {
+			positions[i] = event.Position
+		}
+		if got := fmt.Sprint(positions); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+
+	for _, path := range []string{
+		"/events?since=yesterday",
+		"/events?until=2026-01-02",
+		"/events?since=2026-01-02T14:00:00Z&type=A",
+		"/events",
+	} {
+		if rr := do(path, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// maxTimeseriesSteps bounds the steps of one /stats/timeseries request
+const maxTimeseriesSteps = 10080
+
+// timeseries is the response of /stats/timeseries
+type timeseries struct {
+	Since  time.Time          `json:"since"`
+	Until  time.Time          `json:"until"`
+	Step   string             `json:"step"`
+	Series []timeseriesSeries `json:"series"`
+}
+
+// timeseriesSeries holds the counts of one event type, one per step from
+// since
+type timeseriesSeries struct {
+	Type   string  `json:"type"`
+	Total  int64   `json:"total"`
+	Counts []int64 `json:"counts"`
+}
+
+// timeseriesHandler serves GET /stats/timeseries: events per type in steps
+// of ?step= (a multiple of a minute, default 1m) between ?since= (default
+// an hour before until) and ?until= (default now), aligned to multiples of
+// the step. Counts come from the per-minute rollups stores keep on write,
+// so graphs cost the same however long the log is. ?type= limits the
+// series to exact types.
+func timeseriesHandler(w http.ResponseWriter, r *http.Request, st store.EventStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	step := time.Minute
+	if s := query.Get("step"); s != "" {
+		var err error
+		if step, err = time.ParseDuration(s); err != nil || step < time.Minute || step%time.Minute != 0 {
+			http.Error(w, "Invalid 'step' parameter, expected a multiple of 1m", http.StatusBadRequest)
+			return
+		}
+	}
+	since, until, _, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if since.IsZero() {
+		since = until.Add(-time.Hour)
+	}
+	since = since.Truncate(step).UTC()
+	if rounded := until.Truncate(step); rounded.Before(until) {
+		until = rounded.Add(step)
+	}
+	until = until.UTC()
+	if !since.Before(until) {
+		http.Error(w, "Invalid time range, 'since' must be before 'until'", http.StatusBadRequest)
+		return
+	}
+	steps := int64(until.Sub(since) / step)
+	if steps > maxTimeseriesSteps {
+		http.Error(w, fmt.Sprintf("Time range too long: at most %d steps, use a larger step", maxTimeseriesSteps), http.StatusBadRequest)
+		return
+	}
+
+	types, err := parseTypes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(types) > 0 && !(store.Filter{Types: types}).ExactTypes() {
+		http.Error(w, "Type patterns are not supported here, list exact types", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	points, err := store.LoadThroughput(ctx, st, since, until, types)
+	if errors.Is(err, store.ErrTooManyGroups) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load throughput: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := timeseries{Since: since, Until: until, Step: step.String(), Series: []timeseriesSeries{}}
+	series := make(map[string]*timeseriesSeries)
+	for _, point := range points {
+		s, ok := series[point.Type]
+		if !ok {
+			s = &timeseriesSeries{Type: point.Type, Counts: make([]int64, steps)}
+			series[point.Type] = s
+		}
+		if i := int64(point.Minute.Sub(since) / step); i >= 0 && i < steps {
+			s.Counts[i] += point.Count
+			s.Total += point.Count
+		}
+	}
+	for _, s := range series {
+		result.Series = append(result.Series, *s)
+	}
+	slices.SortFunc(result.Series, func(a, b timeseriesSeries) int { return cmp.Compare(a.Type, b.Type) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
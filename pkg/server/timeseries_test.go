@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestTimeseriesHandler(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/timeseries.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, 20 * time.Second, 70 * time.Second, 5 * time.Minute, 6 * time.Minute}
+	for i, offset := range offsets {
+		typ := "OrderPlaced"
+		if i == 2 {
+			typ = "OrderShipped"
+		}
+		event := &store.StoredEvent{Type: typ, Data: json.RawMessage(`{}`), Timestamp: base.Add(offset)}
+		if err := st.Save(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The rollups and the aggregation fallback give the same series
+	for _, es := range []store.EventStore{st, plainStore{st}} {
+		srv := NewWithStore(es, DefaultConfig(), "test-key-123")
+		get := func(target string) (*httptest.ResponseRecorder, timeseries) {
+			t.Helper()
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("X-API-Key", "test-key-123")
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, req)
+			var result timeseries
+			if rr.Code == http.StatusOK {
+				if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+					t.Fatalf("%s: decode failed: %v", target, err)
+				}
+			}
+			return rr, result
+		}
+
+		since := base.Format(time.RFC3339)
+		until := base.Add(10 * time.Minute).Format(time.RFC3339)
+		rr, minutes := get("/stats/timeseries?since=" + since + "&until=" + until)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%T: status %d: %s", es, rr.Code, rr.Body)
+		}
+		if got := fmt.Sprint(minutes.Series); got != "[{OrderPlaced 4 [2 0 0 0 0 1 1 0 0 0]} {OrderShipped 1 [0 1 0 0 0 0 0 0 0 0]}]" {
+			t.Errorf("%T: per minute = %s", es, got)
+		}
+		if minutes.Step != "1m0s" || !minutes.Since.Equal(base) {
+			t.Errorf("%T: unexpected range %v + %s", es, minutes.Since, minutes.Step)
+		}
+
+		// Bounds are aligned to the step
+		_, steps := get("/stats/timeseries?step=5m&type=OrderPlaced&since=" + base.Add(time.Minute).Format(time.RFC3339) + "&until=" + until)
+		if got := fmt.Sprint(steps.Series); got != "[{OrderPlaced 4 [2 2]}]" || !steps.Since.Equal(base) {
+			t.Errorf("%T: per 5 minutes from %v = %s", es, steps.Since, got)
+		}
+
+		_, none := get("/stats/timeseries?type=UserCreated&since=" + since + "&until=" + until)
+		if none.Series == nil || len(none.Series) != 0 {
+			t.Errorf("%T: expected no series, got %+v", es, none.Series)
+		}
+
+		for _, target := range []string{
+			"/stats/timeseries?step=30s",
+			"/stats/timeseries?step=90s",
+			"/stats/timeseries?type=Order*",
+			"/stats/timeseries?since=yesterday",
+			"/stats/timeseries?since=" + until + "&until=" + since,
+			"/stats/timeseries?since=2020-01-01T00:00:00Z&until=2026-01-01T00:00:00Z",
+		} {
+			if rr, _ := get(target); rr.Code != http.StatusBadRequest {
+				t.Errorf("%T: %s: status %d, want 400", es, target, rr.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/token"
+)
+
+// DefaultTokenTTL is how long issued tokens are valid
+const DefaultTokenTTL = 15 * time.Minute
+
+// errNotToken is returned by tokenClaims for credentials that are not tokens
+var errNotToken = errors.New("not a token")
+
+// tokenClaims verifies credential as a service account token. Servers
+// without a signer accept no tokens.
+func tokenClaims(signer *token.Signer, credential string) (token.Claims, error) {
+	if signer == nil || !token.Looks(credential) {
+		return token.Claims{}, errNotToken
+	}
+	return signer.Verify(credential, time.Now())
+}
+
+// tokenScopeMiddleware admits the requests a token's scopes cover: appends
+// need the append scope, everything else the read scope
+func tokenScopeMiddleware(claims token.Claims, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope := token.ScopeRead
+		if r.Method == http.MethodPost && (r.URL.Path == "/events" || r.URL.Path == "/events/batch") {
+			scope = token.ScopeAppend
+		}
+		if keepPositions(r) {
+			http.Error(w, "Tokens cannot import events at explicit positions", http.StatusForbidden)
+			return
+		}
+		if !claims.HasScope(scope) {
+			logger(r).Warn("Token scope denied",
+				"audit", true,
+				"account", claims.Subject,
+				"scope", scope,
+				"path", r.URL.Path,
+				"method", r.Method)
+			http.Error(w, fmt.Sprintf("Token lacks the %s scope", scope), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tokenHandler exchanges a service account's name and secret, sent as HTTP
+// Basic credentials, for a token. An optional "scope" parameter narrows the
+// token to some of the account's scopes. Responses follow the OAuth 2.0
+// client credentials grant. Wrong credentials count towards lockout.
+func tokenHandler(w http.ResponseWriter, r *http.Request, lockout *authLockout, accounts *token.Accounts, signer *token.Signer, ttl time.Duration) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, secret, _ := r.BasicAuth()
+	if lockout.blocked(w, r, secret) {
+		return
+	}
+	account, ok := accounts.Authenticate(name, secret)
+	if !ok {
+		ip := r.RemoteAddr
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip = strings.Split(forwarded, ",")[0]
+		}
+		logger(r).Warn("Token request rejected",
+			"audit", true,
+			"account", name,
+			"ip", ip)
+		if name != "" {
+			lockout.fail(r, secret)
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="ebuse"`)
+		http.Error(w, "Invalid service account credentials", http.StatusUnauthorized)
+		return
+	}
+
+	scopes := account.Scopes
+	if requested := strings.Fields(r.FormValue("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(account.Scopes, scope) {
+				http.Error(w, fmt.Sprintf("Scope %q not granted to the service account", scope), http.StatusBadRequest)
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	now := time.Now()
+	claims := token.Claims{
+		Subject:   account.Name,
+		Tenant:    account.Tenant,
+		Scope:     strings.Join(scopes, " "),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setLogTenant(r, account.Tenant)
+	logger(r).Info("Token issued", "audit", true, "account", account.Name, "scope", claims.Scope, "expires_in", int(ttl.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": signed,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+		"scope":        claims.Scope,
+	})
+}
+
+// serviceAccountsHandler lists (GET) and creates (POST) service accounts
+// under /admin/service-accounts, and deletes them (DELETE) under
+// /admin/service-accounts/{name}. tenantExists rejects accounts for unknown
+// tenants.
+func serviceAccountsHandler(w http.ResponseWriter, r *http.Request, accounts *token.Accounts, tenantExists func(string) bool) {
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/service-accounts"), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"service_accounts": accounts.List()})
+
+	case name == "" && r.Method == http.MethodPost:
+		var req struct {
+			Name   string   `json:"name"`
+			Tenant string   `json:"tenant"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Tenant == "" {
+			req.Tenant = "default"
+		}
+		if !tenantExists(req.Tenant) {
+			http.Error(w, fmt.Sprintf("Unknown tenant %q", req.Tenant), http.StatusBadRequest)
+			return
+		}
+
+		account, secret, err := accounts.Create(req.Name, req.Tenant, req.Scopes)
+		switch {
+		case errors.Is(err, token.ErrAccountExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger(r).Info("Service account created", "audit", true, "account", account.Name, "tenant", account.Tenant, "scopes", account.Scopes)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":       account.Name,
+			"tenant":     account.Tenant,
+			"scopes":     account.Scopes,
+			"created_at": account.CreatedAt,
+			"secret":     secret,
+		})
+
+	case name != "" && r.Method == http.MethodDelete:
+		deleted, err := accounts.Delete(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, "Service account not found", http.StatusNotFound)
+			return
+		}
+		logger(r).Info("Service account deleted", "audit", true, "account", name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/token"
+)
+
+func newTokenConfig(t *testing.T) *Config {
+	t.Helper()
+	signer, err := token.NewSigner(strings.Repeat("s", token.MinSecretBytes))
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	accounts, err := token.OpenAccounts("")
+	if err != nil {
+		t.Fatalf("OpenAccounts failed: %v", err)
+	}
+	config := DefaultConfig()
+	config.AdminKey = "admin-key"
+	config.TokenSigner = signer
+	config.ServiceAccounts = accounts
+	return config
+}
+
+func TestServiceAccountTokens(t *testing.T) {
+	srv := NewMultiTenant(namedTenants{"alice": store.NewMemoryStore()}, newTokenConfig(t))
+	defer srv.Close()
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Admin creates a read-only account
+	req := httptest.NewRequest("POST", "/admin/service-accounts", strings.NewReader(`{"name":"reporting","tenant":"alice","scopes":["read"]}`))
+	req.Header.Set("X-Admin-Key", "admin-key")
+	rr := serve(req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Secret string `json:"secret"`
+	}
+	json.NewDecoder(rr.Body).Decode(&created)
+
+	req = httptest.NewRequest("POST", "/admin/service-accounts", strings.NewReader(`{"name":"ghost","tenant":"bob","scopes":["read"]}`))
+	req.Header.Set("X-Admin-Key", "admin-key")
+	if rr := serve(req); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown tenant, got %d", rr.Code)
+	}
+
+	// Wrong secrets get no token
+	req = httptest.NewRequest("POST", "/token", nil)
+	req.SetBasicAuth("reporting", "wrong")
+	if rr := serve(req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong secret, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/token", nil)
+	req.SetBasicAuth("reporting", created.Secret)
+	rr = serve(req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var issued struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+	}
+	json.NewDecoder(rr.Body).Decode(&issued)
+	if issued.Scope != "read" {
+		t.Errorf("Expected scope read, got %q", issued.Scope)
+	}
+
+	// The token reads but may not append
+	req = httptest.NewRequest("GET", "/events?from=0", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken)
+	if rr := serve(req); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a read, got %d: %s", rr.Code, rr.Body.String())
+	}
+	req = httptest.NewRequest("POST", "/events", strings.NewReader(`{"type":"OrderPaid","data":{}}`))
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken)
+	if rr := serve(req); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an append, got %d", rr.Code)
+	}
+
+	// Tampered tokens are rejected
+	req = httptest.NewRequest("GET", "/events?from=0", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken+"x")
+	if rr := serve(req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a tampered token, got %d", rr.Code)
+	}
+
+	// Deleted accounts get no new tokens
+	req = httptest.NewRequest("DELETE", "/admin/service-accounts/reporting", nil)
+	req.Header.Set("X-Admin-Key", "admin-key")
+	if rr := serve(req); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rr.Code)
+	}
+	req = httptest.NewRequest("POST", "/token", nil)
+	req.SetBasicAuth("reporting", created.Secret)
+	if rr := serve(req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 after deletion, got %d", rr.Code)
+	}
+}
+
+func TestServiceAccountTokens_SingleTenant(t *testing.T) {
+	config := newTokenConfig(t)
+	srv := NewWithStore(store.NewMemoryStore(), config, "test-key-123")
+	defer srv.Close()
+
+	_, secret, err := config.ServiceAccounts.Create("ingest", "default", []string{token.ScopeAppend})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/token", nil)
+	req.SetBasicAuth("ingest", secret)
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	var issued struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.NewDecoder(rr.Body).Decode(&issued)
+
+	req = httptest.NewRequest("POST", "/events", strings.NewReader(`{"type":"OrderPaid","data":{}}`))
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken)
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an append, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/events?from=0", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken)
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a read, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// TraceHeader makes the server log every stage of a write at info level,
+// tagged with the header's value, e.g. "X-Ebuse-Trace: checkout-4711".
+// Traced writes are synced before the response so fsync latency is part of
+// the trace. Stage durations are returned in a Server-Timing header.
+const TraceHeader = "X-Ebuse-Trace"
+
+// maxTraceIDBytes limits the trace ID copied into logs and headers
+const maxTraceIDBytes = 64
+
+// writeTrace records the stages of one traced write. A nil trace is valid
+// and records nothing, so handlers call it unconditionally.
+type writeTrace struct {
+	id      string
+	path    string
+	log     *slog.Logger
+	start   time.Time
+	last    time.Time
+	timings []string
+}
+
+// traceID returns the request's trace ID, or "" when it is not traced
+func traceID(r *http.Request) string {
+	id := strings.TrimSpace(r.Header.Get(TraceHeader))
+	if len(id) > maxTraceIDBytes {
+		id = id[:maxTraceIDBytes]
+	}
+	return id
+}
+
+// startTrace begins a trace if the request carries TraceHeader
+func startTrace(r *http.Request) *writeTrace {
+	id := traceID(r)
+	if id == "" {
+		return nil
+	}
+	now := time.Now()
+	t := &writeTrace{id: id, path: r.URL.Path, log: logger(r), start: now, last: now}
+	t.stage("received", "bytes", r.ContentLength)
+	return t
+}
+
+// stage logs the end of a stage with its duration and attributes
+func (t *writeTrace) stage(name string, args ...any) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	took := now.Sub(t.last)
+	t.last = now
+	t.timings = append(t.timings, fmt.Sprintf("%s;dur=%.3f", name, float64(took.Microseconds())/1000))
+
+	attrs := append([]any{
+		"trace_id", t.id,
+		"path", t.path,
+		"stage", name,
+		"stage_us", took.Microseconds(),
+		"elapsed_us", now.Sub(t.start).Microseconds(),
+	}, args...)
+	t.log.Info("Write trace", attrs...)
+}
+
+// sync flushes the store up to upTo and records it as the fsync stage
+func (t *writeTrace) sync(ctx context.Context, st store.EventStore, upTo int64) {
+	if t == nil {
+		return
+	}
+	syncer, ok := store.As[store.Syncer](st)
+	if !ok {
+		t.stage("fsync", "skipped", "store cannot sync")
+		return
+	}
+	if err := syncer.Sync(ctx, upTo); err != nil {
+		t.stage("fsync", "error", err)
+		return
+	}
+	t.stage("fsync", "position", upTo)
+}
+
+// finish sets the trace response headers; call before writing the body
+func (t *writeTrace) finish(w http.ResponseWriter) {
+	if t == nil {
+		return
+	}
+	// Streams are woken in the background and read from the store, so there
+	// is no fan-out to wait for; the event is visible to readers once stored
+	t.stage("visible")
+	w.Header().Set(TraceHeader, t.id)
+	w.Header().Set("Server-Timing", strings.Join(t.timings, ", "))
+}
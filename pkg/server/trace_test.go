@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTrace(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	save := func(trace string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"Traced","data":{}}`))
+		req.Header.Set("X-API-Key", "test-key-123")
+		if trace != "" {
+			req.Header.Set(TraceHeader, trace)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	rec := save("checkout-4711")
+	if rec.Header().Get(TraceHeader) != "checkout-4711" {
+		t.Errorf("Expected the trace ID echoed, got %q", rec.Header().Get(TraceHeader))
+	}
+	timing := rec.Header().Get("Server-Timing")
+	for _, stage := range []string{"received", "validated", "store", "fsync", "visible"} {
+		if !strings.Contains(timing, stage+";dur=") {
+			t.Errorf("Expected stage %s in Server-Timing %q", stage, timing)
+		}
+		if !strings.Contains(logs.String(), "stage="+stage) {
+			t.Errorf("Expected stage %s to be logged", stage)
+		}
+	}
+	if !strings.Contains(logs.String(), "trace_id=checkout-4711") {
+		t.Error("Expected log lines tagged with the trace ID")
+	}
+
+	// Untraced writes log nothing beyond the request line
+	logs.Reset()
+	rec = save("")
+	if rec.Header().Get("Server-Timing") != "" || strings.Contains(logs.String(), "Write trace") {
+		t.Errorf("Expected no trace for an untraced write, got %q", logs.String())
+	}
+}
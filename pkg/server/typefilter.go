@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// parseTypes reads the type filter of a read: any number of type
+// parameters and comma-separated types lists, combined. No filter returns
+// nil.
+func parseTypes(r *http.Request) ([]string, error) {
+	query := r.URL.Query()
+	types := slices.Clone(query["type"])
+	for _, list := range query["types"] {
+		types = append(types, strings.Split(list, ",")...)
+	}
+
+	filter := make([]string, 0, len(types))
+	for _, typ := range types {
+		if typ = strings.TrimSpace(typ); typ != "" && !slices.Contains(filter, typ) {
+			filter = append(filter, typ)
+		}
+	}
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	return filter, store.ValidateTypes(filter)
+}
+
+// typeIndex returns the type index of st for a filtered read, answering
+// 501 if the store has none
+func typeIndex(w http.ResponseWriter, st store.EventStore) (store.TypeIndex, bool) {
+	index, ok := store.As[store.TypeIndex](st)
+	if !ok {
+		http.Error(w, "Type filters not supported by this store", http.StatusNotImplemented)
+	}
+	return index, ok
+}
+
+// parseFilter reads the filter of a read or subscription: the types of
+// parseTypes, which may end in "*" to match a prefix, and any number of
+// meta.<key>=<value> parameters. Values of the same key are alternatives.
+func parseFilter(r *http.Request) (store.Filter, error) {
+	types, err := parseTypes(r)
+	if err != nil {
+		return store.Filter{}, err
+	}
+	filter := store.Filter{Types: types}
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, "meta.")
+		if !ok {
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string][]string)
+		}
+		filter.Metadata[key] = values
+	}
+	return filter, filter.Validate()
+}
+
+// filterIndex checks that st can serve filter, answering 501 if it only
+// asks for exact types and st has no type index. Patterns and metadata are
+// matched while scanning the log.
+func filterIndex(w http.ResponseWriter, st store.EventStore, filter store.Filter) bool {
+	if !filter.ExactTypes() {
+		return true
+	}
+	_, ok := typeIndex(w, st)
+	return ok
+}
+
+// filterRaw returns the events of a batch encoded as JSON that pass filter
+func filterRaw(filter store.Filter, events []json.RawMessage) ([]json.RawMessage, error) {
+	if filter.Empty() {
+		return events, nil
+	}
+	matched := events[:0]
+	for _, data := range events {
+		var fields struct {
+			Type     string            `json:"type"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if filter.MatchFields(fields.Type, fields.Metadata) {
+			matched = append(matched, data)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestTypeFilter(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key-123")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do(http.MethodPost, "/events/batch", `[{"type":"Created","data":{}},{"type":"Tick","data":{}},{"type":"Paid","data":{}},{"type":"Tick","data":{}},{"type":"Shipped","data":{}}]`)
+	do(http.MethodPost, "/events/batch", `[{"type":"Order.Created","data":{},"metadata":{"region":"eu"}},{"type":"Order.Paid","data":{},"metadata":{"region":"us"}},{"type":"Order.Line.Added","data":{},"metadata":{"region":"eu"}}]`)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/events?from=1&type=Tick", "[2 4]"},
+		{"/events?from=1&types=Created,Shipped", "[1 5]"},
+		{"/events?from=2&to=4&type=Paid&types=Created,%20Tick", "[2 3 4]"},
+		{"/events?from=1&types=Unknown", "[]"},
+		{"/events/stream?from=0&batch_size=1&types=Paid,Shipped", "[3 5]"},
+		{"/events/stream?from=0&type=Tick&checksum=true", "[2 4]"},
+		{"/events?from=1&type=Order.*", "[6 7 8]"},
+		{"/events?from=1&to=6&type=Order.*", "[6]"},
+		{"/events?from=1&meta.region=eu", "[6 8]"},
+		{"/events?from=1&types=Order.Line.*,Tick&meta.region=eu", "[8]"},
+		{"/events/stream?from=0&batch_size=1&type=Order.*&meta.region=eu&meta.region=us", "[6 7 8]"},
+		{"/events/stream?from=0&type=*&meta.region=u*", "[7]"},
+	}
+	for _, tt := range tests {
+		rr := do(http.MethodGet, tt.path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.path, rr.Code, rr.Body.String())
+		}
+		var events []*store.StoredEvent
+		if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.path, err)
+		}
+		positions := make([]int64, len(events))
+		for i, event := range events {
+			positions[i] = event.Position
+		}
+		if got := fmt.Sprint(positions); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+
+	if rr := do(http.MethodGet, "/events?from=1&meta.region=", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty metadata value, got %d", rr.Code)
+	}
+
+	many := "/events?from=1&types=" + strings.Repeat("T,", store.MaxFilterTypes) + "Last"
+	if rr := do(http.MethodGet, many, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected duplicate types to be merged, got %d", rr.Code)
+	}
+	many = "/events?from=1&types="
+	for i := 0; i <= store.MaxFilterTypes; i++ {
+		many += fmt.Sprintf("T%d,", i)
+	}
+	if rr := do(http.MethodGet, many, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for too many types, got %d", rr.Code)
+	}
+}
+
+func TestTypeFilter_Unsupported(t *testing.T) {
+	sqliteStore, err := store.NewSQLiteStore(t.TempDir() + "/events.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	for _, path := range []string{"/events?from=1&type=A", "/events/stream?from=1&type=A"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		if strings.HasPrefix(path, "/events/stream") {
+			streamEventsHandler(rr, req, plainStore{sqliteStore}, nil)
+		} else {
+			loadEventsHandler(rr, req, plainStore{sqliteStore})
+		}
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusNotImplemented, rr.Code)
+		}
+	}
+
+	// Patterns and metadata are matched by scanning
+	for _, path := range []string{"/events?from=1&type=A*", "/events/stream?from=1&meta.region=eu"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		if strings.HasPrefix(path, "/events/stream") {
+			streamEventsHandler(rr, req, plainStore{sqliteStore}, nil)
+		} else {
+			loadEventsHandler(rr, req, plainStore{sqliteStore})
+		}
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusOK, rr.Code)
+		}
+	}
+}
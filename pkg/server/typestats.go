@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+// maxTrackedTypes bounds the event types tracked per tenant, so a producer
+// inventing types cannot grow the server's memory without bound. Events of
+// further types are only counted as overflow.
+const maxTrackedTypes = 10000
+
+// typeStats keeps rolling write counts and first/last-seen times per event
+// type and tenant, for spotting unexpected new types and types that stopped
+// flowing. Counts cover writes accepted since the server started.
+type typeStats struct {
+	mu      sync.Mutex
+	since   time.Time
+	tenants map[string]*tenantTypes
+}
+
+type tenantTypes struct {
+	types    map[string]*typeCounter
+	overflow int64 // Events of types beyond maxTrackedTypes
+}
+
+// typeCounter counts one event type in per-minute buckets for the last hour
+// and per-hour buckets for the last day
+type typeCounter struct {
+	total     int64
+	firstSeen time.Time
+	lastSeen  time.Time
+	minutes   [60]countBucket
+	hours     [24]countBucket
+}
+
+// countBucket counts events in one period, identified by its start in
+// minutes or hours since the epoch
+type countBucket struct {
+	period int64
+	count  int64
+}
+
+// addBucket counts n events in the bucket for period, reusing the slot of a
+// bucket that has rolled out of the window
+func addBucket(buckets []countBucket, period, n int64) {
+	b := &buckets[period%int64(len(buckets))]
+	if b.period != period {
+		*b = countBucket{period: period}
+	}
+	b.count += n
+}
+
+// sumBuckets counts the events of buckets within the window ending at period
+func sumBuckets(buckets []countBucket, period int64) int64 {
+	var total int64
+	for _, b := range buckets {
+		if b.period > period-int64(len(buckets)) && b.period <= period {
+			total += b.count
+		}
+	}
+	return total
+}
+
+// TypeStat describes the writes of one event type
+type TypeStat struct {
+	Type      string    `json:"type"`
+	Total     int64     `json:"total"`
+	LastHour  int64     `json:"last_hour"`
+	LastDay   int64     `json:"last_24h"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// TypeStatsSnapshot lists the event types written by a tenant
+type TypeStatsSnapshot struct {
+	Tenant      string     `json:"tenant"`
+	Since       time.Time  `json:"since"`       // Counting started, at server start
+	Cardinality int        `json:"cardinality"` // Distinct types tracked
+	Overflow    int64      `json:"overflow"`    // Events of types beyond the tracking limit
+	Types       []TypeStat `json:"types"`
+}
+
+func newTypeStats() *typeStats {
+	return &typeStats{since: time.Now(), tenants: make(map[string]*tenantTypes)}
+}
+
+// record counts events written by tenant at now
+func (ts *typeStats) record(tenant string, events []*store.StoredEvent, now time.Time) {
+	minute := now.Unix() / 60
+	hour := minute / 60
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	tt := ts.tenants[tenant]
+	if tt == nil {
+		tt = &tenantTypes{types: make(map[string]*typeCounter)}
+		ts.tenants[tenant] = tt
+	}
+	for _, event := range events {
+		c := tt.types[event.Type]
+		if c == nil {
+			if len(tt.types) >= maxTrackedTypes {
+				tt.overflow++
+				continue
+			}
+			c = &typeCounter{firstSeen: now}
+			tt.types[event.Type] = c
+		}
+		c.total++
+		c.lastSeen = now
+		addBucket(c.minutes[:], minute, 1)
+		addBucket(c.hours[:], hour, 1)
+	}
+}
+
+// snapshot returns the tenant's types sorted by name
+func (ts *typeStats) snapshot(tenant string, now time.Time) TypeStatsSnapshot {
+	minute := now.Unix() / 60
+	hour := minute / 60
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	snap := TypeStatsSnapshot{Tenant: tenant, Since: ts.since, Types: []TypeStat{}}
+	tt := ts.tenants[tenant]
+	if tt == nil {
+		return snap
+	}
+	snap.Cardinality = len(tt.types)
+	snap.Overflow = tt.overflow
+	for name, c := range tt.types {
+		snap.Types = append(snap.Types, TypeStat{
+			Type:      name,
+			Total:     c.total,
+			LastHour:  sumBuckets(c.minutes[:], minute),
+			LastDay:   sumBuckets(c.hours[:], hour),
+			FirstSeen: c.firstSeen,
+			LastSeen:  c.lastSeen,
+		})
+	}
+	sort.Slice(snap.Types, func(i, j int) bool {
+		return snap.Types[i].Type < snap.Types[j].Type
+	})
+	return snap
+}
+
+// typeStatsHandler serves GET /stats/types for tenant
+func typeStatsHandler(w http.ResponseWriter, r *http.Request, ts *typeStats, tenant string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ts.snapshot(tenant, time.Now()))
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/store"
+)
+
+func TestTypeStats_RollingWindows(t *testing.T) {
+	ts := newTypeStats()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := func(types ...string) []*store.StoredEvent {
+		var out []*store.StoredEvent
+		for _, typ := range types {
+			out = append(out, &store.StoredEvent{Type: typ})
+		}
+		return out
+	}
+
+	ts.record("alice", events("A", "A", "B"), start)
+	ts.record("alice", events("A"), start.Add(30*time.Minute))
+	ts.record("bob", events("C"), start)
+
+	snap := ts.snapshot("alice", start.Add(45*time.Minute))
+	if snap.Cardinality != 2 || len(snap.Types) != 2 || snap.Types[0].Type != "A" {
+		t.Fatalf("Expected types A and B, got %+v", snap)
+	}
+	a := snap.Types[0]
+	if a.Total != 3 || a.LastHour != 3 || a.LastDay != 3 {
+		t.Errorf("Expected 3 A events in every window, got %+v", a)
+	}
+	if !a.FirstSeen.Equal(start) || !a.LastSeen.Equal(start.Add(30*time.Minute)) {
+		t.Errorf("Unexpected first/last seen: %+v", a)
+	}
+
+	// 80 minutes in, the first writes have left the hourly window
+	snap = ts.snapshot("alice", start.Add(80*time.Minute))
+	if a := snap.Types[0]; a.LastHour != 1 || a.LastDay != 3 {
+		t.Errorf("Expected 1 A event in the last hour and 3 in the last day, got %+v", a)
+	}
+
+	// A day later B has stopped flowing but is still listed
+	ts.record("alice", events("A"), start.Add(25*time.Hour))
+	snap = ts.snapshot("alice", start.Add(25*time.Hour))
+	if a, b := snap.Types[0], snap.Types[1]; a.Total != 4 || a.LastDay != 1 || b.LastDay != 0 || b.Total != 1 {
+		t.Errorf("Expected only the new A event in the last day, got %+v and %+v", a, b)
+	}
+
+	if snap := ts.snapshot("bob", start); snap.Cardinality != 1 || snap.Types[0].Type != "C" {
+		t.Errorf("Expected tenants counted separately, got %+v", snap)
+	}
+	if snap := ts.snapshot("carol", start); snap.Types == nil || len(snap.Types) != 0 {
+		t.Errorf("Expected an empty list for a tenant without writes, got %+v", snap)
+	}
+}
+
+func TestTypeStats_Overflow(t *testing.T) {
+	ts := newTypeStats()
+	now := time.Now()
+	for i := range maxTrackedTypes {
+		ts.record("alice", []*store.StoredEvent{{Type: fmt.Sprintf("T%d", i)}}, now)
+	}
+	ts.record("alice", []*store.StoredEvent{{Type: "OneTooMany"}, {Type: "OneTooMany"}}, now)
+
+	snap := ts.snapshot("alice", now)
+	if snap.Cardinality != maxTrackedTypes || snap.Overflow != 2 {
+		t.Errorf("Expected %d tracked types and 2 overflow events, got %d and %d", maxTrackedTypes, snap.Cardinality, snap.Overflow)
+	}
+}
+
+func TestTypeStatsEndpoint(t *testing.T) {
+	aliceStore := store.NewMemoryStore()
+	bobStore := store.NewMemoryStore()
+	srv := NewMultiTenant(namedTenants{"alice": aliceStore, "bob": bobStore}, DefaultConfig())
+	defer srv.rateLimiter.Stop()
+
+	do := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do(http.MethodPost, "/events", "alice", `{"type":"OrderPlaced","data":{}}`)
+	do(http.MethodPost, "/events/batch", "alice", `[{"type":"OrderPlaced","data":{}},{"type":"OrderShipped","data":{}}]`)
+	do(http.MethodPost, "/events", "bob", `{"type":"Login","data":{}}`)
+
+	rr := do(http.MethodGet, "/stats/types", "alice", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var snap TypeStatsSnapshot
+	if err := json.NewDecoder(rr.Body).Decode(&snap); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if snap.Tenant != "alice" || snap.Cardinality != 2 {
+		t.Fatalf("Expected alice's 2 types, got %+v", snap)
+	}
+	if placed := snap.Types[0]; placed.Type != "OrderPlaced" || placed.Total != 2 || placed.LastHour != 2 {
+		t.Errorf("Expected 2 OrderPlaced events, got %+v", placed)
+	}
+
+	if rr := do(http.MethodGet, "/stats/types", "unknown", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a valid key, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/stats/types", "alice", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jilio/ebuse/internal/fanout"
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/websocket"
+)
+
+// WebSocket subscription limits. A subscription gets events until
+// DefaultWSWindow of them are unacknowledged, so a slow consumer holds back
+// its own stream instead of filling the connection.
+const (
+	DefaultWSWindow = 1000
+
+	maxWSWindow        = 10000
+	maxWSSubscriptions = 100
+)
+
+// wsMessage is one message of the /ws protocol, in either direction.
+//
+// Clients send "subscribe" (Subscription, optional From, Window, Types and
+// Metadata, the filter of /events as a store.Filter), "ack"
+// (Subscription, Position) and "unsubscribe" (Subscription). The server
+// answers with "subscribed" (Position: the position the stream continues
+// after), "events", "unsubscribed" and "error" (Message, and Subscription
+// when the error concerns one).
+type wsMessage struct {
+	Type         string              `json:"type"`
+	Subscription string              `json:"subscription,omitempty"`
+	From         int64               `json:"from,omitempty"`
+	Window       int                 `json:"window,omitempty"`
+	Types        []string            `json:"types,omitempty"`
+	Metadata     map[string][]string `json:"metadata,omitempty"`
+	Position     int64               `json:"position,omitempty"`
+	Events       []json.RawMessage   `json:"events,omitempty"`
+	Message      string              `json:"message,omitempty"`
+}
+
+// wsSubscription is the delivery state of one subscription on a connection
+type wsSubscription struct {
+	next      int64 // Next position to load
+	delivered int64 // Position of the last event sent
+	acked     int64 // Last position acknowledged and saved
+	window    int
+	filter    store.Filter
+}
+
+// wsHandler serves the subscription protocol over a WebSocket. Each
+// subscription starts after its saved position, or at From, and receives
+// batches of events as they are written; acknowledged positions are saved
+// as the subscription's position, so a consumer reconnecting with the same
+// subscription resumes after its last ack. Connections end when the client
+// closes them or the server drains.
+func wsHandler(w http.ResponseWriter, r *http.Request, st store.EventStore, appends *fanout.Hub) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+
+	ctx := r.Context()
+	log := logger(r)
+	tenant := requestTenant(r)
+
+	incoming := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case incoming <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	subs := make(map[string]*wsSubscription)
+	var head int64
+	var appended <-chan struct{} // Set once the head is first read
+
+	// deliver sends every subscription the events it has room for
+	deliver := func() error {
+		for id, sub := range subs {
+			for sub.next <= head && sub.delivered-sub.acked < int64(sub.window) {
+				room := int64(sub.window) - (sub.delivered - sub.acked)
+				to := min(head, sub.next+min(room, DefaultReplicationBatch)-1)
+				events, err := loadReplicationBatch(ctx, st, sub.next, to)
+				if err != nil {
+					return fmt.Errorf("load events: %w", err)
+				}
+				sub.next = to + 1
+				if events, err = filterRaw(sub.filter, events); err != nil {
+					return fmt.Errorf("filter events: %w", err)
+				}
+				if len(events) == 0 {
+					continue
+				}
+				if sub.delivered, err = rawPosition(events[len(events)-1]); err != nil {
+					return err
+				}
+				if err := conn.WriteJSON(wsMessage{Type: "events", Subscription: id, Events: events}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// catchUp reads the head and delivers what subscriptions have room for
+	catchUp := func() error {
+		appended = appends.Wait(tenant)
+		var err error
+		if head, err = st.GetPosition(ctx); err != nil {
+			return err
+		}
+		return deliver()
+	}
+
+	// handle applies one client message, answering protocol errors with an
+	// error message; only failures of the connection or store are returned
+	handle := func(data []byte) error {
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return conn.WriteJSON(wsMessage{Type: "error", Message: fmt.Sprintf("Invalid message: %v", err)})
+		}
+		fail := func(format string, args ...any) error {
+			return conn.WriteJSON(wsMessage{Type: "error", Subscription: msg.Subscription, Message: fmt.Sprintf(format, args...)})
+		}
+		if msg.Subscription == "" {
+			return fail("Missing subscription")
+		}
+		sub := subs[msg.Subscription]
+
+		switch msg.Type {
+		case "subscribe":
+			if sub != nil {
+				return fail("Already subscribed")
+			}
+			if len(subs) >= maxWSSubscriptions {
+				return fail("Too many subscriptions on this connection (max %d)", maxWSSubscriptions)
+			}
+			if msg.From < 0 || msg.Window < 0 {
+				return fail("Invalid from or window")
+			}
+			filter := store.Filter{Types: msg.Types, Metadata: msg.Metadata}
+			if err := filter.Validate(); err != nil {
+				return fail("Invalid filter: %v", err)
+			}
+			window := DefaultWSWindow
+			if msg.Window > 0 {
+				window = min(msg.Window, maxWSWindow)
+			}
+
+			start := msg.From
+			if start == 0 {
+				saved, err := st.LoadSubscriptionPosition(ctx, msg.Subscription)
+				if err != nil {
+					return fmt.Errorf("load subscription position: %w", err)
+				}
+				start = saved + 1
+			}
+			subs[msg.Subscription] = &wsSubscription{next: start, delivered: start - 1, acked: start - 1, window: window, filter: filter}
+			return conn.WriteJSON(wsMessage{Type: "subscribed", Subscription: msg.Subscription, Position: start - 1})
+
+		case "ack":
+			if sub == nil {
+				return fail("Not subscribed")
+			}
+			if msg.Position > sub.delivered {
+				return fail("Ack of position %d, which was not delivered", msg.Position)
+			}
+			if msg.Position <= sub.acked {
+				return nil
+			}
+			saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if err := st.SaveSubscriptionPosition(saveCtx, msg.Subscription, msg.Position); err != nil {
+				return fmt.Errorf("save subscription position: %w", err)
+			}
+			sub.acked = msg.Position
+			return nil
+
+		case "unsubscribe":
+			if sub == nil {
+				return fail("Not subscribed")
+			}
+			delete(subs, msg.Subscription)
+			return conn.WriteJSON(wsMessage{Type: "unsubscribed", Subscription: msg.Subscription})
+
+		default:
+			return fail("Unknown message type %q", msg.Type)
+		}
+	}
+
+	poll := time.NewTicker(replicationPollPeriod)
+	defer poll.Stop()
+	ping := time.NewTicker(DefaultSubscribeHeartbeat)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case err = <-readErr:
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				log.Debug("WebSocket read failed", "error", err)
+			}
+			return
+		case data := <-incoming:
+			if err = handle(data); err == nil {
+				err = catchUp()
+			}
+		case <-appended:
+			err = catchUp()
+		case <-poll.C:
+			if len(subs) == 0 {
+				continue
+			}
+			err = catchUp()
+		case <-ping.C:
+			err = conn.Ping()
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("WebSocket subscription failed", "error", err)
+			}
+			conn.Close(websocket.CloseInternalError, "")
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jilio/ebuse/internal/store"
+	"github.com/jilio/ebuse/internal/websocket"
+)
+
+// wsClient is a /ws connection of a test
+type wsClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialWS(t *testing.T, st store.EventStore) *wsClient {
+	t.Helper()
+	config := DefaultConfig()
+	config.DebugCapture = 10 // Upgrades pass every response writer wrapper
+	srv := NewWithStore(st, config, "test-key-123")
+	t.Cleanup(func() { srv.Close() })
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.Dial(context.Background(), ts.URL+"/ws", http.Header{"X-Api-Key": {"test-key-123"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close(websocket.CloseNormal, "") })
+	return &wsClient{t: t, conn: conn}
+}
+
+func (c *wsClient) send(msg wsMessage) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.t.Fatalf("Write failed: %v", err)
+	}
+}
+
+func (c *wsClient) next() wsMessage {
+	c.t.Helper()
+	var msg wsMessage
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		c.t.Fatalf("Read failed: %v", err)
+	}
+	return msg
+}
+
+// positions returns the positions of an events message
+func (c *wsClient) positions(msg wsMessage) []int64 {
+	c.t.Helper()
+	if msg.Type != "events" {
+		c.t.Fatalf("Expected events, got %+v", msg)
+	}
+	var positions []int64
+	for _, data := range msg.Events {
+		position, err := rawPosition(data)
+		if err != nil {
+			c.t.Fatalf("Bad event: %v", err)
+		}
+		positions = append(positions, position)
+	}
+	return positions
+}
+
+func TestWS_SubscribeAckResume(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/ws.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	saveTestEvents(t, st, 3)
+
+	c := dialWS(t, st)
+	c.send(wsMessage{Type: "subscribe", Subscription: "billing", Window: 2})
+	if msg := c.next(); msg.Type != "subscribed" || msg.Position != 0 {
+		t.Fatalf("Expected subscribed at 0, got %+v", msg)
+	}
+
+	// The window holds back event 3 until the first two are acknowledged
+	if got := c.positions(c.next()); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("Expected events 1 and 2, got %v", got)
+	}
+	c.send(wsMessage{Type: "ack", Subscription: "billing", Position: 2})
+	if got := c.positions(c.next()); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("Expected event 3, got %v", got)
+	}
+
+	if position, err := st.LoadSubscriptionPosition(context.Background(), "billing"); err != nil || position != 2 {
+		t.Errorf("Expected saved position 2, got %d (%v)", position, err)
+	}
+
+	// New events are tailed
+	c.send(wsMessage{Type: "ack", Subscription: "billing", Position: 3})
+	saveTestEvents(t, st, 1)
+	if got := c.positions(c.next()); len(got) != 1 || got[0] != 4 {
+		t.Fatalf("Expected event 4, got %v", got)
+	}
+
+	// Protocol errors keep the connection open
+	c.send(wsMessage{Type: "ack", Subscription: "billing", Position: 9})
+	if msg := c.next(); msg.Type != "error" || msg.Subscription != "billing" {
+		t.Errorf("Expected an error for an ack beyond the delivered events, got %+v", msg)
+	}
+	c.send(wsMessage{Type: "subscribe", Subscription: "billing"})
+	if msg := c.next(); msg.Type != "error" {
+		t.Errorf("Expected an error for a duplicate subscription, got %+v", msg)
+	}
+	c.send(wsMessage{Type: "nudge", Subscription: "billing"})
+	if msg := c.next(); msg.Type != "error" {
+		t.Errorf("Expected an error for an unknown message, got %+v", msg)
+	}
+
+	c.send(wsMessage{Type: "unsubscribe", Subscription: "billing"})
+	if msg := c.next(); msg.Type != "unsubscribed" {
+		t.Errorf("Expected unsubscribed, got %+v", msg)
+	}
+
+	// A new connection resumes after the last ack
+	c = dialWS(t, st)
+	c.send(wsMessage{Type: "subscribe", Subscription: "billing"})
+	if msg := c.next(); msg.Type != "subscribed" || msg.Position != 3 {
+		t.Fatalf("Expected subscribed at 3, got %+v", msg)
+	}
+	if got := c.positions(c.next()); len(got) != 1 || got[0] != 4 {
+		t.Fatalf("Expected event 4 again, got %v", got)
+	}
+
+	// from overrides the saved position
+	c.send(wsMessage{Type: "subscribe", Subscription: "audit", From: 2})
+	if msg := c.next(); msg.Type != "subscribed" || msg.Position != 1 {
+		t.Fatalf("Expected subscribed at 1, got %+v", msg)
+	}
+	if got := c.positions(c.next()); len(got) != 3 || got[0] != 2 {
+		t.Fatalf("Expected events 2 to 4, got %v", got)
+	}
+
+	// Filters select the events delivered
+	c.send(wsMessage{Type: "subscribe", Subscription: "typed", From: 1, Metadata: map[string][]string{"region": {}}})
+	if msg := c.next(); msg.Type != "error" {
+		t.Errorf("Expected an error for an invalid filter, got %+v", msg)
+	}
+	c.send(wsMessage{Type: "subscribe", Subscription: "typed", From: 1, Types: []string{"Ev*"}})
+	if msg := c.next(); msg.Type != "subscribed" {
+		t.Fatalf("Expected subscribed, got %+v", msg)
+	}
+	if got := c.positions(c.next()); len(got) != 4 {
+		t.Fatalf("Expected events 1 to 4, got %v", got)
+	}
+}
+
+func TestWS_RequiresUpgrade(t *testing.T) {
+	st, err := store.NewSQLiteStore(t.TempDir() + "/ws.db")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+
+	srv := NewWithStore(st, DefaultConfig(), "test-key-123")
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("X-API-Key", "test-key-123")
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUpgradeRequired {
+		t.Errorf("Expected status %d, got %d", http.StatusUpgradeRequired, rr.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package ebuse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jilio/ebuse/internal/sigv4"
+)
+
+// secretRef matches ${vault:path#field} and ${aws:secret-id#field}; the
+// #field part is optional for AWS secrets stored as plain strings
+var secretRef = regexp.MustCompile(`\$\{(vault|aws):([^}#]+)(?:#([^}]+))?\}`)
+
+// secretTimeout bounds each secret manager request at startup and rotation
+const secretTimeout = 10 * time.Second
+
+// secretResolver fetches secrets referenced from tenants.yaml, caching each
+// document so several fields of the same secret cost one request
+type secretResolver struct {
+	client *http.Client
+	cache  map[string]map[string]string
+}
+
+func newSecretResolver() *secretResolver {
+	return &secretResolver{
+		client: &http.Client{Timeout: secretTimeout},
+		cache:  make(map[string]map[string]string),
+	}
+}
+
+// resolve returns the value of a single secret reference
+func (sr *secretResolver) resolve(provider, path, field string) (string, error) {
+	key := provider + ":" + path
+	doc, ok := sr.cache[key]
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+		defer cancel()
+
+		var err error
+		switch provider {
+		case "vault":
+			doc, err = sr.fetchVault(ctx, path)
+		case "aws":
+			doc, err = sr.fetchAWS(ctx, path)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s secret %s: %w", provider, path, err)
+		}
+		sr.cache[key] = doc
+	}
+
+	value, ok := doc[field]
+	if !ok {
+		if field == "" {
+			return "", fmt.Errorf("%s secret %s: a #field is required", provider, path)
+		}
+		return "", fmt.Errorf("%s secret %s: no field %q", provider, path, field)
+	}
+	return value, nil
+}
+
+// fetchVault reads a KV secret using VAULT_ADDR and VAULT_TOKEN. Both KV v1
+// and v2 mounts work: for v2 the path includes "data/" and the fields are
+// nested one level deeper.
+func (sr *secretResolver) fetchVault(ctx context.Context, path string) (map[string]string, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	body, err := sr.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	data := resp.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = nested
+		}
+	}
+	return stringFields(data), nil
+}
+
+// fetchAWS reads a secret from AWS Secrets Manager. Credentials and region
+// come from the standard AWS_* environment variables. JSON secrets expose
+// their fields; any secret is also available without a #field.
+func (sr *secretResolver) fetchAWS(ctx context.Context, secretID string) (map[string]string, error) {
+	creds, err := sigv4.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	region := sigv4.RegionFromEnv()
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION must be set")
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+
+	payload, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sigv4.Sign(req, sigv4.PayloadHash(payload), creds, region, "secretsmanager", time.Now())
+
+	body, err := sr.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	doc := map[string]string{"": resp.SecretString}
+	var fields map[string]any
+	if json.Unmarshal([]byte(resp.SecretString), &fields) == nil {
+		for k, v := range stringFields(fields) {
+			doc[k] = v
+		}
+	}
+	return doc, nil
+}
+
+func (sr *secretResolver) do(req *http.Request) ([]byte, error) {
+	resp, err := sr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Error bodies never contain secret values, but keep the log line short
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, msg)
+	}
+	return body, nil
+}
+
+// stringFields keeps the scalar fields of a secret as strings
+func stringFields(data map[string]any) map[string]string {
+	fields := make(map[string]string, len(data))
+	for k, v := range data {
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		case float64, bool:
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,164 @@
+package ebuse
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretResolver_Vault(t *testing.T) {
+	requests := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/ebuse": // KV v2
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"alice": "alice-from-vault", "bob": "bob-from-vault"},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		case "/v1/kv/ebuse": // KV v1
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{"carol": "carol-from-v1"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+
+	sr := newSecretResolver()
+	for field, want := range map[string]string{"alice": "alice-from-vault", "bob": "bob-from-vault"} {
+		got, err := sr.resolve("vault", "secret/data/ebuse", field)
+		if err != nil {
+			t.Fatalf("resolve %s failed: %v", field, err)
+		}
+		if got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected fields of one secret to be fetched once, got %d requests", requests)
+	}
+
+	if got, err := sr.resolve("vault", "kv/ebuse", "carol"); err != nil || got != "carol-from-v1" {
+		t.Errorf("expected KV v1 value, got %q, %v", got, err)
+	}
+	if _, err := sr.resolve("vault", "secret/data/ebuse", "nobody"); err == nil {
+		t.Error("expected error for missing field")
+	}
+	if _, err := sr.resolve("vault", "secret/data/missing", "alice"); err == nil {
+		t.Error("expected error for missing secret")
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := newSecretResolver().resolve("vault", "secret/data/ebuse", "alice"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
+
+func TestSecretResolver_AWS(t *testing.T) {
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			http.Error(w, "unexpected target", http.StatusBadRequest)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "session" {
+			http.Error(w, "missing session token", http.StatusForbidden)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var req struct{ SecretId string }
+		json.Unmarshal(body, &req)
+		switch req.SecretId {
+		case "ebuse/tenants":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"alice":"alice-from-aws"}`})
+		case "ebuse/plain":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "plain-value"})
+		default:
+			http.Error(w, `{"__type":"ResourceNotFoundException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer aws.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", aws.URL)
+
+	sr := newSecretResolver()
+	if got, err := sr.resolve("aws", "ebuse/tenants", "alice"); err != nil || got != "alice-from-aws" {
+		t.Errorf("expected JSON field, got %q, %v", got, err)
+	}
+	if got, err := sr.resolve("aws", "ebuse/plain", ""); err != nil || got != "plain-value" {
+		t.Errorf("expected plain secret, got %q, %v", got, err)
+	}
+	if _, err := sr.resolve("aws", "ebuse/missing", "alice"); err == nil {
+		t.Error("expected error for missing secret")
+	}
+}
+
+func TestLoadTenantsConfig_SecretReferences(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				// Resolved values are not expanded again
+				"data":     map[string]any{"alice": "alice-${NOT_EXPANDED}"},
+				"metadata": map[string]any{},
+			},
+		})
+	}))
+	defer vault.Close()
+
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+	t.Setenv("EBUSE_TEST_BOB_KEY", "bob-from-env")
+
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+tenants:
+  - name: alice
+    api_key: ${vault:secret/data/ebuse#alice}
+  - name: bob
+    api_key: ${EBUSE_TEST_BOB_KEY}
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	if config.Tenants[0].APIKey != "alice-${NOT_EXPANDED}" {
+		t.Errorf("unexpected vault key: %s", config.Tenants[0].APIKey)
+	}
+	if config.Tenants[1].APIKey != "bob-from-env" {
+		t.Errorf("unexpected env key: %s", config.Tenants[1].APIKey)
+	}
+
+	t.Setenv("VAULT_TOKEN", "")
+	if _, err := LoadTenantsConfig(configPath); err == nil || !strings.Contains(err.Error(), "VAULT_TOKEN") {
+		t.Errorf("expected missing VAULT_TOKEN error, got %v", err)
+	}
+}
@@ -1,14 +1,27 @@
 package ebuse
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
 	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/jilio/ebuse/internal/acl"
+	"github.com/jilio/ebuse/internal/pipeline"
+	"github.com/jilio/ebuse/internal/retention"
 	"github.com/jilio/ebuse/internal/store"
 )
 
@@ -17,22 +30,176 @@ var validTenantName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
 // TenantConfig represents a single tenant with their API key and database
 type TenantConfig struct {
-	Name   string `yaml:"name"`
+	Name     string `yaml:"name"`
+	APIKey   string `yaml:"api_key"`
+	Template string `yaml:"template,omitempty"` // Optional: template to inherit settings from
+
+	// Optional: push every event to a remote ebuse server
+	Mirror *MirrorConfig `yaml:"mirror,omitempty"`
+
+	// Sharded mode: the node (a key of TenantsConfig.Shards) owning the tenant
+	Shard string `yaml:"shard,omitempty"`
+
+	// Optional: more API keys, limited to some of the tenant's streams
+	Keys []StreamKey `yaml:"keys,omitempty"`
+
+	TenantSettings `yaml:",inline"`
+}
+
+// StreamKey is an API key of a tenant that may only read and append to the
+// streams matching its patterns (exact stream IDs or prefixes ending in "*")
+type StreamKey struct {
+	Name   string   `yaml:"name"` // Identifies the key in logs, e.g. "billing"
+	APIKey string   `yaml:"api_key"`
+	Read   []string `yaml:"read,omitempty"`
+	Append []string `yaml:"append,omitempty"`
+}
+
+// id names the key in logs and RotateKeys results
+func (k StreamKey) id(tenant string) string {
+	return tenant + "/" + k.Name
+}
+
+// validate checks the key's name, API key and patterns
+func (k StreamKey) validate() (*acl.ACL, error) {
+	if !validTenantName.MatchString(k.Name) {
+		return nil, fmt.Errorf("key %q: invalid name, only alphanumeric characters, hyphens, and underscores are allowed", k.Name)
+	}
+	if k.APIKey == "" {
+		return nil, fmt.Errorf("key %s: API key cannot be empty", k.Name)
+	}
+	a, err := acl.New(k.Read, k.Append)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", k.Name, err)
+	}
+	return a, nil
+}
+
+// MirrorConfig names the remote tenant that receives a copy of a tenant's
+// events. The remote tenant must not be written to by anything else.
+type MirrorConfig struct {
+	URL    string `yaml:"url"`
 	APIKey string `yaml:"api_key"`
 }
 
+// TenantSettings are per-tenant settings that can be inherited from a
+// template. Zero values mean "inherit".
+type TenantSettings struct {
+	StoreBackend string           `yaml:"store_backend,omitempty"` // "sqlite", "pebble", "postgres" or "memory"
+	Pipeline     *pipeline.Config `yaml:"pipeline,omitempty"`      // Write-time type allowlist, deny, strip, PII and enrich rules
+	RateLimit    int              `yaml:"rate_limit,omitempty"`    // Requests per second across all replicas (default: TENANT_RATE_LIMIT)
+	RateBurst    int              `yaml:"rate_burst,omitempty"`    // Requests one second may reach after idling (default: TENANT_RATE_BURST, or rate_limit)
+	CORSOrigins  []string         `yaml:"cors_origins,omitempty"`  // Browser origins allowed besides CORS_ALLOWED_ORIGINS
+
+	// Events to keep (default: RETENTION_MAX_AGE and RETENTION_MAX_EVENTS);
+	// an empty policy keeps everything
+	Retention *retention.Policy `yaml:"retention,omitempty"`
+
+	// Head of a fresh store, so positions continue from the store a tenant
+	// was migrated from; ignored once the store has a position
+	StartPosition int64 `yaml:"start_position,omitempty"`
+}
+
+// inherit fills unset settings from base
+func (s TenantSettings) inherit(base TenantSettings) TenantSettings {
+	if s.StoreBackend == "" {
+		s.StoreBackend = base.StoreBackend
+	}
+	if s.Pipeline == nil {
+		s.Pipeline = base.Pipeline
+	}
+	if s.RateLimit == 0 {
+		s.RateLimit = base.RateLimit
+	}
+	if s.RateBurst == 0 {
+		s.RateBurst = base.RateBurst
+	}
+	if s.CORSOrigins == nil {
+		s.CORSOrigins = base.CORSOrigins
+	}
+	if s.Retention == nil {
+		s.Retention = base.Retention
+	}
+	if s.StartPosition == 0 {
+		s.StartPosition = base.StartPosition
+	}
+	return s
+}
+
 // TenantsConfig holds all tenant configurations
 type TenantsConfig struct {
 	Tenants      []TenantConfig `yaml:"tenants"`
 	DataDir      string         `yaml:"data_dir,omitempty"`      // Optional: directory for databases
-	StoreBackend string         `yaml:"store_backend,omitempty"` // Optional: "sqlite" or "pebble" (default: pebble)
+	StoreBackend string         `yaml:"store_backend,omitempty"` // Optional: "sqlite", "pebble", "postgres" or "memory" (default: pebble)
+
+	// Postgres stores keep each tenant in schema "ebuse_<name>" of one
+	// database; zero values fall back to POSTGRES_DSN / POSTGRES_MAX_CONNS
+	PostgresDSN      string `yaml:"postgres_dsn,omitempty"`
+	PostgresMaxConns int    `yaml:"postgres_max_conns,omitempty"`
+
+	// Optional: named setting templates; tenants pick one with `template`,
+	// otherwise DefaultTemplate (if set) applies
+	Templates       map[string]TenantSettings `yaml:"templates,omitempty"`
+	DefaultTemplate string                    `yaml:"default_template,omitempty"`
+
+	// SQLite WAL checkpointing; zero values fall back to WAL_CHECKPOINT_MB / WAL_CHECK_INTERVAL
+	WALCheckpointMB  int           `yaml:"wal_checkpoint_mb,omitempty"`
+	WALCheckInterval time.Duration `yaml:"wal_check_interval,omitempty"`
+
+	// SQLite statistics refresh; zero values fall back to ANALYZE_INTERVAL / ANALYZE_AFTER_ROWS
+	AnalyzeInterval  time.Duration `yaml:"analyze_interval,omitempty"`
+	AnalyzeAfterRows int           `yaml:"analyze_after_rows,omitempty"`
+
+	// Dense SQLite positions; false falls back to STRICT_POSITIONS
+	StrictPositions bool `yaml:"strict_positions,omitempty"`
+
+	// Newest events checked after an unclean shutdown; 0 falls back to RECOVERY_SCAN_EVENTS
+	RecoveryScanEvents int `yaml:"recovery_scan_events,omitempty"`
+
+	// Optional: sharded mode. Every node loads the same tenants, opens only
+	// those whose shard is Node and proxies requests for the others.
+	Node   string            `yaml:"node,omitempty"`   // This node's name, e.g. ${NODE_NAME}
+	Shards map[string]string `yaml:"shards,omitempty"` // Node name -> base URL
+
+	// Optional: runs on every store opened, after its recovery scan and
+	// before its start position is set, e.g. to hydrate it from an archive
+	Prepare func(tenant string, st store.EventStore) error `yaml:"-"`
+
+	// Tenant name -> API key as written in the file, for keys given as
+	// ${...} references; the registry stores these rather than the secret
+	apiKeyRefs map[string]string
+}
+
+// local reports whether this node serves tenant itself
+func (c *TenantsConfig) local(tenant TenantConfig) bool {
+	return len(c.Shards) == 0 || tenant.Shard == c.Node
 }
 
 // TenantManager manages multiple tenants and their isolated databases
 type TenantManager struct {
-	mu      sync.RWMutex
-	tenants map[string]*TenantStore // API key -> TenantStore
-	dataDir string
+	reloadMu sync.Mutex // Serializes RotateKeys, Reload and Close
+	mu       sync.RWMutex
+	tenants  map[string]*TenantStore  // keyDigest(API key) -> TenantStore
+	remote   map[string]*RemoteTenant // keyDigest(API key) -> tenant owned by another node
+	scoped   map[string]scopedKey     // keyDigest(API key) -> stream limits of the tenant's StreamKeys
+	dataDir  string
+	pg       *sql.DB // Pool shared by Postgres tenants, opened on first use
+
+	// Stores of tenants removed by Reload, closed when their timer fires
+	retired     map[*TenantStore]*time.Timer
+	removeGrace time.Duration
+	onRemove    func(name string, st store.EventStore) // Set by OnRemove
+}
+
+// RemovedTenantGrace is how long Reload keeps the store of a removed tenant
+// open, so requests that got hold of it before the removal can finish
+const RemovedTenantGrace = 30 * time.Second
+
+// TenantChanges lists what Reload changed, by tenant name
+type TenantChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Rotated []string `json:"rotated,omitempty"` // Keys of running tenants, as returned by RotateKeys
 }
 
 // TenantStore holds a tenant's database and metadata
@@ -41,45 +208,396 @@ type TenantStore struct {
 	Store store.EventStore
 }
 
+// keyDigest is the SHA-256 digest API keys are indexed by, so a lookup takes
+// the same time however much of a guessed key is right
+func keyDigest(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return string(sum[:])
+}
+
+// scopedKey is a StreamKey in effect
+type scopedKey struct {
+	id  string // StreamKey.id
+	acl *acl.ACL
+}
+
+// RemoteTenant is a tenant served by another node in sharded mode
+type RemoteTenant struct {
+	Name string
+	URL  string // Base URL of the owning node
+}
+
 // LoadTenantsConfig loads tenant configuration from YAML file
 func LoadTenantsConfig(configPath string) (*TenantsConfig, error) {
+	config, err := parseTenantsConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Tenants) == 0 {
+		return nil, fmt.Errorf("no tenants configured")
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseTenantsConfig reads a tenants.yaml file without validating it
+func parseTenantsConfig(configPath string) (*TenantsConfig, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
 
-	var config TenantsConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("parse yaml: %w", err)
 	}
 
-	if len(config.Tenants) == 0 {
-		return nil, fmt.Errorf("no tenants configured")
+	// Keep the API key references before they resolve
+	var refs struct {
+		Tenants []struct {
+			Name   string `yaml:"name"`
+			APIKey string `yaml:"api_key"`
+		} `yaml:"tenants"`
+	}
+	if err := root.Decode(&refs); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
 	}
 
+	// Resolve ${ENV_VAR} references in values, so secrets stay out of the file
+	if err := expandEnv(&root); err != nil {
+		return nil, err
+	}
+
+	var config TenantsConfig
+	if err := root.Decode(&config); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	for _, tenant := range refs.Tenants {
+		if strings.Contains(tenant.APIKey, "${") {
+			if config.apiKeyRefs == nil {
+				config.apiKeyRefs = make(map[string]string)
+			}
+			config.apiKeyRefs[tenant.Name] = tenant.APIKey
+		}
+	}
+
+	return &config, nil
+}
+
+// validate applies defaults and checks that every tenant's settings resolve
+func (c *TenantsConfig) validate() error {
 	// Default data directory
-	if config.DataDir == "" {
-		config.DataDir = "data"
+	if c.DataDir == "" {
+		c.DataDir = "data"
 	}
 
 	// Default store backend
-	if config.StoreBackend == "" {
-		config.StoreBackend = "pebble"
+	if c.StoreBackend == "" {
+		c.StoreBackend = "pebble"
 	}
 
 	// Validate store backend
-	if config.StoreBackend != "sqlite" && config.StoreBackend != "pebble" {
-		return nil, fmt.Errorf("invalid store_backend: %s (must be 'sqlite' or 'pebble')", config.StoreBackend)
+	if err := validateStoreBackend(c.StoreBackend); err != nil {
+		return err
 	}
 
-	return &config, nil
+	if len(c.Shards) > 0 {
+		if _, ok := c.Shards[c.Node]; !ok {
+			return fmt.Errorf("node %q is not in shards", c.Node)
+		}
+		for node, rawURL := range c.Shards {
+			if u, err := url.Parse(rawURL); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("shard %s: invalid URL %q", node, rawURL)
+			}
+		}
+	}
+
+	// Resolve templates up front so mistakes fail at load time
+	for _, tenant := range c.Tenants {
+		if len(c.Shards) > 0 {
+			if _, ok := c.Shards[tenant.Shard]; !ok {
+				return fmt.Errorf("tenant %s: shard %q is not in shards", tenant.Name, tenant.Shard)
+			}
+		} else if tenant.Shard != "" {
+			return fmt.Errorf("tenant %s: shard set without shards", tenant.Name)
+		}
+
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return err
+		}
+		if err := validateStoreBackend(settings.StoreBackend); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if settings.Pipeline != nil {
+			if _, err := pipeline.New(*settings.Pipeline); err != nil {
+				return fmt.Errorf("tenant %s: pipeline: %w", tenant.Name, err)
+			}
+		}
+		if settings.RateLimit < 0 {
+			return fmt.Errorf("tenant %s: rate_limit must not be negative", tenant.Name)
+		}
+		if settings.RateBurst < 0 {
+			return fmt.Errorf("tenant %s: rate_burst must not be negative", tenant.Name)
+		}
+		if settings.StartPosition < 0 {
+			return fmt.Errorf("tenant %s: start_position must not be negative", tenant.Name)
+		}
+		if settings.Retention != nil {
+			if err := settings.Retention.Validate(); err != nil {
+				return fmt.Errorf("tenant %s: retention: %w", tenant.Name, err)
+			}
+		}
+		for _, origin := range settings.CORSOrigins {
+			if err := validateOrigin(origin); err != nil {
+				return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+			}
+		}
+		if m := tenant.Mirror; m != nil && (m.URL == "" || m.APIKey == "") {
+			return fmt.Errorf("tenant %s: mirror needs url and api_key", tenant.Name)
+		}
+		names := make(map[string]bool, len(tenant.Keys))
+		for _, key := range tenant.Keys {
+			if _, err := key.validate(); err != nil {
+				return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+			}
+			if names[key.Name] {
+				return fmt.Errorf("tenant %s: duplicate key name %s", tenant.Name, key.Name)
+			}
+			names[key.Name] = true
+		}
+	}
+
+	return nil
+}
+
+// envRef matches ${NAME} and ${NAME:-default}
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// anyRef matches secret manager and environment references
+var anyRef = regexp.MustCompile(secretRef.String() + "|" + envRef.String())
+
+// expandEnv replaces environment and secret manager references in all
+// scalar values of a YAML document. Values are expanded after parsing, so
+// their content can never change the document structure. Unset variables
+// without a default are an error rather than silently becoming empty API keys.
+func expandEnv(node *yaml.Node) error {
+	var missing []string
+	var secretErr error
+	var secrets *secretResolver
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode && strings.Contains(n.Value, "${") {
+			// One pass over both kinds, so resolved values are never expanded again
+			n.Value = anyRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+				if m := secretRef.FindStringSubmatch(ref); m != nil {
+					if secretErr != nil {
+						return ""
+					}
+					if secrets == nil {
+						secrets = newSecretResolver()
+					}
+					value, err := secrets.resolve(m[1], m[2], m[3])
+					secretErr = err
+					return value
+				}
+
+				m := envRef.FindStringSubmatch(ref)
+				if value, ok := os.LookupEnv(m[1]); ok {
+					return value
+				}
+				if strings.Contains(ref, ":-") {
+					return m[2]
+				}
+				missing = append(missing, m[1])
+				return ""
+			})
+			// Let unquoted values re-resolve their type (e.g. numbers)
+			if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+				n.Tag = ""
+			}
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	if secretErr != nil {
+		return secretErr
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// expandRefs resolves the ${...} references of a single value, as in
+// tenants.yaml
+func expandRefs(value string) (string, error) {
+	node := yaml.Node{Kind: yaml.ScalarNode, Value: value, Style: yaml.DoubleQuotedStyle}
+	if err := expandEnv(&node); err != nil {
+		return "", err
+	}
+	return node.Value, nil
+}
+
+func validateStoreBackend(backend string) error {
+	switch backend {
+	case "sqlite", "pebble", "postgres", "memory":
+	default:
+		return fmt.Errorf("invalid store_backend: %s (must be 'sqlite', 'pebble', 'postgres' or 'memory')", backend)
+	}
+	return nil
+}
+
+// settingsFor resolves a tenant's settings: explicit tenant values, then
+// its template (or the default template), then the global defaults
+func (c *TenantsConfig) settingsFor(tenant TenantConfig) (TenantSettings, error) {
+	settings := tenant.TenantSettings
+
+	name := tenant.Template
+	if name == "" {
+		name = c.DefaultTemplate
+	}
+	if name != "" {
+		template, ok := c.Templates[name]
+		if !ok {
+			return TenantSettings{}, fmt.Errorf("tenant %s: unknown template %q", tenant.Name, name)
+		}
+		settings = settings.inherit(template)
+	}
+
+	return settings.inherit(TenantSettings{StoreBackend: c.StoreBackend}), nil
+}
+
+// Pipelines returns the write pipelines of all tenants that configure one,
+// directly or through a template
+func (c *TenantsConfig) Pipelines() (map[string]*pipeline.Pipeline, error) {
+	pipelines := make(map[string]*pipeline.Pipeline)
+	for _, tenant := range c.Tenants {
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return nil, err
+		}
+		if settings.Pipeline == nil {
+			continue
+		}
+		p, err := pipeline.New(*settings.Pipeline)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: pipeline: %w", tenant.Name, err)
+		}
+		pipelines[tenant.Name] = p
+	}
+	return pipelines, nil
+}
+
+// RateLimits returns the request limit of every tenant, falling back to
+// defaultLimit for tenants without one; tenants left at 0 are unlimited
+func (c *TenantsConfig) RateLimits(defaultLimit int) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, tenant := range c.Tenants {
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return nil, err
+		}
+		limit := settings.RateLimit
+		if limit == 0 {
+			limit = defaultLimit
+		}
+		if limit > 0 {
+			limits[tenant.Name] = limit
+		}
+	}
+	return limits, nil
+}
+
+// RateBursts returns the request burst of every tenant, falling back to
+// defaultBurst for tenants without one; tenants left at 0 burst up to their
+// limit only
+func (c *TenantsConfig) RateBursts(defaultBurst int) (map[string]int, error) {
+	bursts := make(map[string]int)
+	for _, tenant := range c.Tenants {
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return nil, err
+		}
+		burst := settings.RateBurst
+		if burst == 0 {
+			burst = defaultBurst
+		}
+		if burst > 0 {
+			bursts[tenant.Name] = burst
+		}
+	}
+	return bursts, nil
+}
+
+// RetentionPolicies returns the retention policy of every tenant that
+// prunes, falling back to defaultPolicy for tenants without one
+func (c *TenantsConfig) RetentionPolicies(defaultPolicy retention.Policy) (map[string]retention.Policy, error) {
+	policies := make(map[string]retention.Policy)
+	for _, tenant := range c.Tenants {
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return nil, err
+		}
+		policy := defaultPolicy
+		if settings.Retention != nil {
+			policy = *settings.Retention
+		}
+		if policy.Enabled() {
+			policies[tenant.Name] = policy
+		}
+	}
+	return policies, nil
+}
+
+// CORSOrigins returns the browser origins of every tenant that allows some,
+// directly or through a template
+func (c *TenantsConfig) CORSOrigins() (map[string][]string, error) {
+	origins := make(map[string][]string)
+	for _, tenant := range c.Tenants {
+		settings, err := c.settingsFor(tenant)
+		if err != nil {
+			return nil, err
+		}
+		if len(settings.CORSOrigins) > 0 {
+			origins[tenant.Name] = settings.CORSOrigins
+		}
+	}
+	return origins, nil
+}
+
+// validateOrigin checks a cors_origins entry: "*", an origin such as
+// "https://app.example.com" or a pattern such as "https://*.example.com"
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(origin, "*.", "", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid cors_origins entry %q, want scheme://host[:port]", origin)
+	}
+	return nil
 }
 
 // NewTenantManager creates a new tenant manager from config
 func NewTenantManager(config *TenantsConfig) (*TenantManager, error) {
 	tm := &TenantManager{
 		tenants: make(map[string]*TenantStore),
+		remote:  make(map[string]*RemoteTenant),
+		scoped:  make(map[string]scopedKey),
 		dataDir: config.DataDir,
+
+		retired:     make(map[*TenantStore]*time.Timer),
+		removeGrace: RemovedTenantGrace,
 	}
 
 	// Create data directory if it doesn't exist
@@ -89,54 +607,222 @@ func NewTenantManager(config *TenantsConfig) (*TenantManager, error) {
 
 	// Initialize each tenant's database
 	for _, tenant := range config.Tenants {
-		if tenant.Name == "" {
-			return nil, fmt.Errorf("tenant name cannot be empty")
+		if err := validateTenant(tenant); err != nil {
+			return nil, err
 		}
 
-		// Validate tenant name to prevent path traversal attacks
-		if !validTenantName.MatchString(tenant.Name) {
-			return nil, fmt.Errorf("tenant %s: invalid name, only alphanumeric characters, hyphens, and underscores are allowed", tenant.Name)
+		// Check for duplicate API keys
+		_, exists := tm.tenants[keyDigest(tenant.APIKey)]
+		_, existsRemote := tm.remote[keyDigest(tenant.APIKey)]
+		if exists || existsRemote {
+			return nil, fmt.Errorf("duplicate API key for tenant: %s", tenant.Name)
 		}
 
-		// Prevent excessively long tenant names
-		if len(tenant.Name) > 100 {
-			return nil, fmt.Errorf("tenant %s: name too long (max 100 characters)", tenant.Name)
+		// Tenants of other nodes get no local store
+		if !config.local(tenant) {
+			nodeURL := config.Shards[tenant.Shard]
+			if nodeURL == "" {
+				return nil, fmt.Errorf("tenant %s: unknown shard %q", tenant.Name, tenant.Shard)
+			}
+			remote := &RemoteTenant{Name: tenant.Name, URL: nodeURL}
+			tm.remote[keyDigest(tenant.APIKey)] = remote
+			if err := tm.addStreamKeys(tenant, func(digest string) { tm.remote[digest] = remote }); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		if tenant.APIKey == "" {
-			return nil, fmt.Errorf("tenant %s: API key cannot be empty", tenant.Name)
+		eventStore, err := tm.openStore(config, tenant)
+		if err != nil {
+			return nil, err
 		}
 
-		// Check for duplicate API keys
-		if _, exists := tm.tenants[tenant.APIKey]; exists {
-			return nil, fmt.Errorf("duplicate API key for tenant: %s", tenant.Name)
+		tenantStore := &TenantStore{
+			Name:  tenant.Name,
+			Store: eventStore,
+		}
+		tm.tenants[keyDigest(tenant.APIKey)] = tenantStore
+		if err := tm.addStreamKeys(tenant, func(digest string) { tm.tenants[digest] = tenantStore }); err != nil {
+			return nil, err
 		}
+	}
+
+	return tm, nil
+}
 
-		// Create store for tenant based on backend type
-		var eventStore store.EventStore
-		var err error
+// validateTenant checks the name and API key of tenant
+func validateTenant(tenant TenantConfig) error {
+	if tenant.Name == "" {
+		return fmt.Errorf("tenant name cannot be empty")
+	}
 
-		if config.StoreBackend == "sqlite" {
-			dbPath := filepath.Join(config.DataDir, fmt.Sprintf("%s.db", tenant.Name))
-			eventStore, err = store.NewSQLiteStore(dbPath)
-			if err != nil {
-				return nil, fmt.Errorf("create sqlite store for tenant %s: %w", tenant.Name, err)
+	// Validate tenant name to prevent path traversal attacks
+	if !validTenantName.MatchString(tenant.Name) {
+		return fmt.Errorf("tenant %s: invalid name, only alphanumeric characters, hyphens, and underscores are allowed", tenant.Name)
+	}
+
+	// Prevent excessively long tenant names
+	if len(tenant.Name) > 100 {
+		return fmt.Errorf("tenant %s: name too long (max 100 characters)", tenant.Name)
+	}
+
+	if tenant.APIKey == "" {
+		return fmt.Errorf("tenant %s: API key cannot be empty", tenant.Name)
+	}
+	return nil
+}
+
+// StorePath returns the backend of the tenant called name and the SQLite
+// file or Pebble directory of its store, "" for other backends. A name not
+// in the config gets the default settings, so a store can be restored
+// before its tenant is added.
+func (c *TenantsConfig) StorePath(name string) (backend, path string, err error) {
+	if !validTenantName.MatchString(name) || len(name) > 100 {
+		return "", "", fmt.Errorf("invalid tenant name %q", name)
+	}
+	tenant := TenantConfig{Name: name}
+	for _, t := range c.Tenants {
+		if t.Name == name {
+			tenant = t
+		}
+	}
+	settings, err := c.settingsFor(tenant)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch settings.StoreBackend {
+	case "sqlite":
+		return "sqlite", filepath.Join(c.DataDir, name+".db"), nil
+	case "postgres", "memory":
+		return settings.StoreBackend, "", nil
+	}
+	return "pebble", filepath.Join(c.DataDir, name), nil
+}
+
+// openStore opens the store of a local tenant with its settings in config
+func (tm *TenantManager) openStore(config *TenantsConfig, tenant TenantConfig) (store.EventStore, error) {
+	settings, err := config.settingsFor(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create store for tenant based on backend type
+	var eventStore store.EventStore
+
+	backend, dbPath, err := config.StorePath(tenant.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch settings.StoreBackend {
+	case "sqlite":
+		sqliteStore, err := store.NewSQLiteStore(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("create sqlite store for tenant %s: %w", tenant.Name, err)
+		}
+		sqliteStore.StartWALMonitor(config.WALCheckInterval, int64(config.WALCheckpointMB)<<20)
+		sqliteStore.StartMaintenance(config.AnalyzeInterval, int64(config.AnalyzeAfterRows))
+		sqliteStore.SetStrictPositions(config.StrictPositions)
+		eventStore = sqliteStore
+	case "memory":
+		eventStore = store.NewMemoryStore()
+	case "postgres":
+		if tm.pg == nil {
+			if config.PostgresDSN == "" {
+				return nil, fmt.Errorf("tenant %s: postgres store needs postgres_dsn", tenant.Name)
 			}
-		} else {
-			dbPath := filepath.Join(config.DataDir, tenant.Name)
-			eventStore, err = store.NewPebbleStore(dbPath)
-			if err != nil {
-				return nil, fmt.Errorf("create pebble store for tenant %s: %w", tenant.Name, err)
+			if tm.pg, err = store.OpenPostgres(config.PostgresDSN, config.PostgresMaxConns); err != nil {
+				return nil, err
 			}
 		}
+		// Postgres truncates longer identifiers, which could merge tenants
+		schema := "ebuse_" + tenant.Name
+		if len(schema) > 63 {
+			return nil, fmt.Errorf("tenant %s: name too long for a postgres schema (max 57 characters)", tenant.Name)
+		}
+		eventStore, err = store.NewPostgresStore(tm.pg, schema)
+		if err != nil {
+			return nil, fmt.Errorf("create postgres store for tenant %s: %w", tenant.Name, err)
+		}
+	default:
+		eventStore, err = store.NewPebbleStore(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("create pebble store for tenant %s: %w", tenant.Name, err)
+		}
+	}
 
-		tm.tenants[tenant.APIKey] = &TenantStore{
-			Name:  tenant.Name,
-			Store: eventStore,
+	if err := RecoverStore(tenant.Name, eventStore, config.RecoveryScanEvents); err != nil {
+		eventStore.Close()
+		return nil, fmt.Errorf("recover store of tenant %s: %w", tenant.Name, err)
+	}
+	warnBackendChanged(tenant.Name, backend, dbPath, eventStore)
+	if config.Prepare != nil {
+		if err := config.Prepare(tenant.Name, eventStore); err != nil {
+			eventStore.Close()
+			return nil, fmt.Errorf("prepare store of tenant %s: %w", tenant.Name, err)
 		}
 	}
+	if _, err := store.StartAt(context.Background(), eventStore, settings.StartPosition); err != nil {
+		eventStore.Close()
+		return nil, fmt.Errorf("set start position for tenant %s: %w", tenant.Name, err)
+	}
+	return eventStore, nil
+}
 
-	return tm, nil
+// RecoverStore runs the recovery scan of the store of tenant over its last n
+// events if the store was not closed cleanly, and logs what it found
+func RecoverStore(tenant string, st store.EventStore, n int) error {
+	report, ok, err := store.Recover(context.Background(), st, int64(n))
+	if err != nil || !ok || !report.Unclean {
+		return err
+	}
+	slog.Warn("Store recovered after an unclean shutdown", "tenant", tenant, "head", report.Head,
+		"scanned", report.Scanned, "from", report.From, "repaired", report.Repaired, "ignored", report.Ignored,
+		"ignored_positions", report.IgnoredPositions, "missing_positions", report.Missing, "duration_ms", report.Duration)
+	return nil
+}
+
+// warnBackendChanged logs a warning when the store of tenant at path is
+// empty but a store of the other local backend exists next to it, as after
+// changing store_backend without running `ebuse migrate`
+func warnBackendChanged(tenant, backend, path string, st store.EventStore) {
+	var other string
+	switch backend {
+	case "sqlite":
+		other = strings.TrimSuffix(path, ".db")
+	case "pebble":
+		other = path + ".db"
+	default:
+		return
+	}
+	if _, err := os.Stat(other); err != nil {
+		return
+	}
+	if head, err := st.GetPosition(context.Background()); err == nil && head == 0 {
+		slog.Warn("Store is empty but a store of another backend exists; run ebuse migrate to move its events",
+			"tenant", tenant, "backend", backend, "path", path, "other", other)
+	}
+}
+
+// addStreamKeys registers the StreamKeys of tenant, whose main key add has
+// registered already, by their keyDigest
+func (tm *TenantManager) addStreamKeys(tenant TenantConfig, add func(digest string)) error {
+	for _, key := range tenant.Keys {
+		a, err := key.validate()
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		digest := keyDigest(key.APIKey)
+		_, exists := tm.tenants[digest]
+		_, existsRemote := tm.remote[digest]
+		if exists || existsRemote {
+			return fmt.Errorf("duplicate API key for tenant: %s", key.id(tenant.Name))
+		}
+		add(digest)
+		tm.scoped[digest] = scopedKey{id: key.id(tenant.Name), acl: a}
+	}
+	return nil
 }
 
 // GetStore returns the store for a given API key
@@ -144,7 +830,7 @@ func (tm *TenantManager) GetStore(apiKey string) (store.EventStore, string, bool
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
-	tenant, ok := tm.tenants[apiKey]
+	tenant, ok := tm.tenants[keyDigest(apiKey)]
 	if !ok {
 		return nil, "", false
 	}
@@ -152,29 +838,364 @@ func (tm *TenantManager) GetStore(apiKey string) (store.EventStore, string, bool
 	return tenant.Store, tenant.Name, true
 }
 
+// KeyScope returns the name ("<tenant>/<key>") and stream limits of apiKey
+// if it is one of a tenant's StreamKeys rather than its main key
+func (tm *TenantManager) KeyScope(apiKey string) (string, *acl.ACL, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	key, ok := tm.scoped[keyDigest(apiKey)]
+	return key.id, key.acl, ok
+}
+
+// RouteTenant returns the tenant owning apiKey and the base URL of its node,
+// if the tenant is served by another node
+func (tm *TenantManager) RouteTenant(apiKey string) (string, string, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	tenant, ok := tm.remote[keyDigest(apiKey)]
+	if !ok {
+		return "", "", false
+	}
+	return tenant.Name, tenant.URL, true
+}
+
+// GetStoreByName returns the store of the named tenant
+func (tm *TenantManager) GetStoreByName(name string) (store.EventStore, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	for _, tenant := range tm.tenants {
+		if tenant.Name == name {
+			return tenant.Store, true
+		}
+	}
+	return nil, false
+}
+
+// RotateKeys swaps the API keys of running tenants for those in config,
+// matching tenants by name, and replaces their StreamKeys with those in
+// config. Tenants that are added to or missing from config are left alone;
+// see Reload. Returns the tenants and StreamKeys ("<tenant>/<key>")
+// whose key changed, was added or was removed.
+func (tm *TenantManager) RotateKeys(config *TenantsConfig) ([]string, error) {
+	tm.reloadMu.Lock()
+	defer tm.reloadMu.Unlock()
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	// Keys are identified by their tenant's name, or StreamKey.id
+	byName := make(map[string]*TenantStore, len(tm.tenants))
+	remoteByName := make(map[string]*RemoteTenant, len(tm.remote))
+	current := make(map[string]string, len(tm.tenants)+len(tm.remote)) // ID -> keyDigest(API key)
+	owner := make(map[string]string, len(current))                     // ID -> tenant name
+	for key, tenant := range tm.tenants {
+		byName[tenant.Name] = tenant
+		id := tm.keyID(key, tenant.Name)
+		current[id], owner[id] = key, tenant.Name
+	}
+	for key, tenant := range tm.remote {
+		remoteByName[tenant.Name] = tenant
+		id := tm.keyID(key, tenant.Name)
+		current[id], owner[id] = key, tenant.Name
+	}
+
+	keys := maps.Clone(current)
+	acls := make(map[string]*acl.ACL, len(tm.scoped))
+	for _, scoped := range tm.scoped {
+		acls[scoped.id] = scoped.acl
+	}
+
+	for _, tenant := range config.Tenants {
+		if _, ok := keys[tenant.Name]; !ok {
+			continue
+		}
+		if tenant.APIKey == "" {
+			return nil, fmt.Errorf("tenant %s: API key cannot be empty", tenant.Name)
+		}
+		keys[tenant.Name] = keyDigest(tenant.APIKey)
+
+		for id, name := range owner {
+			if name == tenant.Name && id != tenant.Name {
+				delete(keys, id)
+				delete(acls, id)
+			}
+		}
+		for _, key := range tenant.Keys {
+			a, err := key.validate()
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+			}
+			id := key.id(tenant.Name)
+			keys[id], owner[id], acls[id] = keyDigest(key.APIKey), tenant.Name, a
+		}
+	}
+
+	// Build the new maps completely before swapping, so a bad config changes nothing
+	tenants := make(map[string]*TenantStore, len(byName))
+	remote := make(map[string]*RemoteTenant, len(remoteByName))
+	scoped := make(map[string]scopedKey, len(acls))
+	for id, key := range keys {
+		_, exists := tenants[key]
+		_, existsRemote := remote[key]
+		if exists || existsRemote {
+			return nil, fmt.Errorf("duplicate API key for tenant: %s", id)
+		}
+		if tenant, ok := byName[owner[id]]; ok {
+			tenants[key] = tenant
+		} else {
+			remote[key] = remoteByName[owner[id]]
+		}
+		if a, ok := acls[id]; ok {
+			scoped[key] = scopedKey{id: id, acl: a}
+		}
+	}
+
+	var rotated []string
+	for id, key := range current {
+		if keys[id] != key {
+			rotated = append(rotated, id)
+		}
+	}
+	for id := range keys {
+		if _, ok := current[id]; !ok {
+			rotated = append(rotated, id)
+		}
+	}
+	sort.Strings(rotated)
+
+	tm.tenants = tenants
+	tm.remote = remote
+	tm.scoped = scoped
+	return rotated, nil
+}
+
+// Reload brings the running tenants in line with config: tenants new to it
+// are added, opening their stores, and tenants missing from it removed. A
+// removed tenant's keys stop working at once and its store is closed after
+// RemovedTenantGrace. Running tenants get the API keys and StreamKeys of
+// config, as by RotateKeys; their other settings, such as the store backend,
+// apply from the next restart. A tenant moved to or from another shard is
+// removed and added. If config is invalid or a store fails to open, nothing
+// changes.
+func (tm *TenantManager) Reload(config *TenantsConfig) (*TenantChanges, error) {
+	tm.reloadMu.Lock()
+	defer tm.reloadMu.Unlock()
+
+	tm.mu.RLock()
+	running := make(map[string]*TenantStore, len(tm.tenants))
+	runningRemote := make(map[string]bool, len(tm.remote))
+	current := make(map[string]string, len(tm.tenants)+len(tm.remote)) // Key ID -> keyDigest(API key)
+	for key, tenant := range tm.tenants {
+		running[tenant.Name] = tenant
+		current[tm.keyID(key, tenant.Name)] = key
+	}
+	for key, tenant := range tm.remote {
+		runningRemote[tenant.Name] = true
+		current[tm.keyID(key, tenant.Name)] = key
+	}
+	tm.mu.RUnlock()
+
+	// Build the new maps completely before swapping, so a bad config changes
+	// nothing; stores opened on the way are closed again
+	next := &TenantManager{
+		tenants: make(map[string]*TenantStore),
+		remote:  make(map[string]*RemoteTenant),
+		scoped:  make(map[string]scopedKey),
+	}
+	opened := make(map[string]*TenantStore)
+	fail := func(err error) (*TenantChanges, error) {
+		for _, tenant := range opened {
+			tenant.Store.Close()
+		}
+		return nil, err
+	}
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return fail(fmt.Errorf("create data directory: %w", err))
+	}
+
+	changes := &TenantChanges{}
+	local := make(map[string]bool, len(config.Tenants)) // Tenant name -> served by this node
+	for _, tenant := range config.Tenants {
+		if err := validateTenant(tenant); err != nil {
+			return fail(err)
+		}
+		if _, ok := local[tenant.Name]; ok {
+			return fail(fmt.Errorf("duplicate tenant: %s", tenant.Name))
+		}
+		local[tenant.Name] = config.local(tenant)
+
+		digest := keyDigest(tenant.APIKey)
+		_, exists := next.tenants[digest]
+		_, existsRemote := next.remote[digest]
+		if exists || existsRemote {
+			return fail(fmt.Errorf("duplicate API key for tenant: %s", tenant.Name))
+		}
+
+		var add func(digest string)
+		if local[tenant.Name] {
+			tenantStore := running[tenant.Name]
+			if tenantStore == nil {
+				eventStore, err := tm.openStore(config, tenant)
+				if err != nil {
+					return fail(err)
+				}
+				tenantStore = &TenantStore{Name: tenant.Name, Store: eventStore}
+				opened[tenant.Name] = tenantStore
+				changes.Added = append(changes.Added, tenant.Name)
+			}
+			add = func(digest string) { next.tenants[digest] = tenantStore }
+		} else {
+			nodeURL := config.Shards[tenant.Shard]
+			if nodeURL == "" {
+				return fail(fmt.Errorf("tenant %s: unknown shard %q", tenant.Name, tenant.Shard))
+			}
+			if !runningRemote[tenant.Name] {
+				changes.Added = append(changes.Added, tenant.Name)
+			}
+			remote := &RemoteTenant{Name: tenant.Name, URL: nodeURL}
+			add = func(digest string) { next.remote[digest] = remote }
+		}
+		add(digest)
+		if err := next.addStreamKeys(tenant, add); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Tenants that stay local keep their store; the others lose it
+	var removed []*TenantStore
+	for name, tenant := range running {
+		if isLocal, ok := local[name]; !ok || !isLocal {
+			removed = append(removed, tenant)
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	for name := range runningRemote {
+		if isLocal, ok := local[name]; !ok || isLocal {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+
+	// Rotated keys are those of tenants running before and after
+	moved := make(map[string]bool, len(changes.Added)+len(changes.Removed))
+	for _, name := range slices.Concat(changes.Added, changes.Removed) {
+		moved[name] = true
+	}
+	keys := make(map[string]string, len(current))
+	for key, tenant := range next.tenants {
+		keys[next.keyID(key, tenant.Name)] = key
+	}
+	for key, tenant := range next.remote {
+		keys[next.keyID(key, tenant.Name)] = key
+	}
+	for _, ids := range []map[string]string{current, keys} {
+		for id := range ids {
+			tenant, _, _ := strings.Cut(id, "/")
+			if !moved[tenant] && current[id] != keys[id] && !slices.Contains(changes.Rotated, id) {
+				changes.Rotated = append(changes.Rotated, id)
+			}
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Rotated)
+
+	tm.mu.Lock()
+	tm.tenants = next.tenants
+	tm.remote = next.remote
+	tm.scoped = next.scoped
+	for _, tenant := range removed {
+		tm.retired[tenant] = time.AfterFunc(tm.removeGrace, func() { tm.closeRetired(tenant) })
+	}
+	tm.mu.Unlock()
+	return changes, nil
+}
+
+// OnRemove sets fn to be called for every tenant removed by Reload once
+// RemovedTenantGrace is over, right before its store is closed. fn must
+// return only once nothing uses the store any more, e.g. after stopping
+// the tenant's background jobs and ending its streams.
+func (tm *TenantManager) OnRemove(fn func(name string, st store.EventStore)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.onRemove = fn
+}
+
+// closeRetired closes the store of a tenant removed by Reload, unless Close
+// has closed it already
+func (tm *TenantManager) closeRetired(tenant *TenantStore) {
+	tm.mu.Lock()
+	_, ok := tm.retired[tenant]
+	delete(tm.retired, tenant)
+	onRemove := tm.onRemove
+	tm.mu.Unlock()
+
+	if ok {
+		if onRemove != nil {
+			onRemove(tenant.Name, tenant.Store)
+		}
+		if err := tenant.Store.Close(); err != nil {
+			slog.Error("Failed to close store of removed tenant", "tenant", tenant.Name, "error", err)
+		}
+	}
+}
+
+// keyID identifies the key with digest of tenant for RotateKeys. Must be
+// called with mu held.
+func (tm *TenantManager) keyID(digest, tenant string) string {
+	if scoped, ok := tm.scoped[digest]; ok {
+		return scoped.id
+	}
+	return tenant
+}
+
 // GetAllTenants returns a list of all tenant names
 func (tm *TenantManager) GetAllTenants() []string {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
 	names := make([]string, 0, len(tm.tenants))
-	for _, tenant := range tm.tenants {
-		names = append(names, tenant.Name)
+	for key, tenant := range tm.tenants {
+		if _, ok := tm.scoped[key]; !ok {
+			names = append(names, tenant.Name)
+		}
 	}
 	return names
 }
 
-// Close closes all tenant databases
+// Close closes all tenant databases. Later calls do nothing.
 func (tm *TenantManager) Close() error {
+	tm.reloadMu.Lock()
+	defer tm.reloadMu.Unlock()
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	var lastErr error
-	for _, tenant := range tm.tenants {
+	for key, tenant := range tm.tenants {
+		if _, ok := tm.scoped[key]; ok {
+			continue // The main key's entry closes the store
+		}
+		if err := tenant.Store.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	for tenant, timer := range tm.retired {
+		timer.Stop()
+		delete(tm.retired, tenant)
 		if err := tenant.Store.Close(); err != nil {
 			lastErr = err
 		}
 	}
+	if tm.pg != nil {
+		if err := tm.pg.Close(); err != nil {
+			lastErr = err
+		}
+	}
 
+	clear(tm.tenants)
+	clear(tm.remote)
+	clear(tm.scoped)
+	tm.pg = nil
 	return lastErr
 }
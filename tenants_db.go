@@ -0,0 +1,403 @@
+package ebuse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// TenantRegistry keeps tenant definitions in a control-plane database table,
+// so tenants survive restarts and can be shared by several server replicas.
+// Global settings (data_dir, templates, ...) still come from tenants.yaml or
+// the environment; only the tenant list lives in the database.
+type TenantRegistry struct {
+	db       *sql.DB
+	postgres bool // Postgres uses $N placeholders instead of ?
+}
+
+// OpenTenantRegistry opens the control-plane database and creates the tenants
+// table if needed. driver is a database/sql driver name: "sqlite" is built in,
+// "postgres" or "pgx" work when the binary registers such a driver.
+func OpenTenantRegistry(driver, dsn string) (*TenantRegistry, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open tenants database: %w", err)
+	}
+
+	r := &TenantRegistry{
+		db:       db,
+		postgres: driver == "postgres" || driver == "pgx",
+	}
+
+	if driver == "sqlite" {
+		// Replicas on the same host may share the file; wait instead of failing
+		if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configure tenants database: %w", err)
+		}
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS ebuse_tenants (
+		name TEXT PRIMARY KEY,
+		api_key TEXT NOT NULL UNIQUE,
+		template TEXT NOT NULL DEFAULT '',
+		store_backend TEXT NOT NULL DEFAULT '',
+		shard TEXT NOT NULL DEFAULT '',
+		updated_at BIGINT NOT NULL
+	)`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create tenants table: %w", err)
+	}
+
+	// Tables created before shards were stored lack the column
+	if _, err := db.Exec("SELECT shard FROM ebuse_tenants WHERE 1 = 0"); err != nil {
+		if _, err := db.Exec("ALTER TABLE ebuse_tenants ADD COLUMN shard TEXT NOT NULL DEFAULT ''"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("add shard column: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// bind rewrites ? placeholders for the registry's driver
+func (r *TenantRegistry) bind(query string) string {
+	if !r.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// List returns all tenants ordered by name
+func (r *TenantRegistry) List(ctx context.Context) ([]TenantConfig, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT name, api_key, template, store_backend, shard FROM ebuse_tenants ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []TenantConfig
+	for rows.Next() {
+		var tenant TenantConfig
+		if err := rows.Scan(&tenant.Name, &tenant.APIKey, &tenant.Template, &tenant.StoreBackend, &tenant.Shard); err != nil {
+			return nil, fmt.Errorf("scan tenant: %w", err)
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// Put creates or replaces tenants by name in a single transaction
+func (r *TenantRegistry) Put(ctx context.Context, tenants ...TenantConfig) error {
+	_, err := r.put(ctx, false, tenants)
+	return err
+}
+
+// put saves tenants in a single transaction; with onlyIfEmpty nothing is
+// written to a registry already holding tenants. It reports whether it
+// wrote.
+func (r *TenantRegistry) put(ctx context.Context, onlyIfEmpty bool, tenants []TenantConfig) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if onlyIfEmpty {
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ebuse_tenants").Scan(&count); err != nil {
+			return false, fmt.Errorf("count tenants: %w", err)
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+
+	query := r.bind(`
+	INSERT INTO ebuse_tenants (name, api_key, template, store_backend, shard, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		api_key = excluded.api_key,
+		template = excluded.template,
+		store_backend = excluded.store_backend,
+		shard = excluded.shard,
+		updated_at = excluded.updated_at`)
+
+	now := time.Now().Unix()
+	for _, tenant := range tenants {
+		if !validTenantName.MatchString(tenant.Name) {
+			return false, fmt.Errorf("tenant %s: invalid name, only alphanumeric characters, hyphens, and underscores are allowed", tenant.Name)
+		}
+		if tenant.APIKey == "" {
+			return false, fmt.Errorf("tenant %s: API key cannot be empty", tenant.Name)
+		}
+		if _, err := tx.ExecContext(ctx, query,
+			tenant.Name, tenant.APIKey, tenant.Template, tenant.StoreBackend, tenant.Shard, now); err != nil {
+			return false, fmt.Errorf("save tenant %s: %w", tenant.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a tenant definition, reporting whether it existed. The
+// tenant's event data is left untouched.
+func (r *TenantRegistry) Delete(ctx context.Context, name string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, r.bind("DELETE FROM ebuse_tenants WHERE name = ?"), name)
+	if err != nil {
+		return false, fmt.Errorf("delete tenant %s: %w", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ErrInvalidTenantSpec is returned by Reconcile for desired tenant sets that
+// would not load
+var ErrInvalidTenantSpec = errors.New("invalid tenant spec")
+
+// TenantChange is a difference between the registry and a desired tenant set
+type TenantChange struct {
+	Action string   // "create", "update" or "delete"
+	Tenant string   // Tenant name
+	Fields []string // Fields an update changes: api_key, template, store_backend, shard
+}
+
+// Reconcile makes the registry hold exactly the desired tenants: missing
+// ones are created, differing ones updated and the rest deleted, in a single
+// transaction. The desired set is validated against the global settings of
+// base, whose tenants also supply the mirrors and stream keys kept in YAML,
+// and the shard of desired tenants that name none. It returns the changes ordered by tenant; with dryRun nothing is
+// written, and applying the same set twice changes nothing the second time.
+func (r *TenantRegistry) Reconcile(ctx context.Context, base *TenantsConfig, desired []TenantConfig, dryRun bool) ([]TenantChange, error) {
+	if len(desired) == 0 {
+		return nil, fmt.Errorf("%w: no tenants", ErrInvalidTenantSpec)
+	}
+	fromYAML := make(map[string]TenantConfig, len(base.Tenants))
+	for _, tenant := range base.Tenants {
+		fromYAML[tenant.Name] = tenant
+	}
+	candidate := *base
+	candidate.Tenants = make([]TenantConfig, 0, len(desired))
+	wanted := make(map[string]TenantConfig, len(desired))
+	keys := make(map[string]string, len(desired))
+	for _, tenant := range desired {
+		if !validTenantName.MatchString(tenant.Name) {
+			return nil, fmt.Errorf("%w: tenant %s: invalid name, only alphanumeric characters, hyphens, and underscores are allowed", ErrInvalidTenantSpec, tenant.Name)
+		}
+		if tenant.APIKey == "" {
+			return nil, fmt.Errorf("%w: tenant %s: API key cannot be empty", ErrInvalidTenantSpec, tenant.Name)
+		}
+		if _, ok := wanted[tenant.Name]; ok {
+			return nil, fmt.Errorf("%w: tenant %s: listed twice", ErrInvalidTenantSpec, tenant.Name)
+		}
+		if other, ok := keys[tenant.APIKey]; ok {
+			return nil, fmt.Errorf("%w: tenant %s: API key already used by tenant %s", ErrInvalidTenantSpec, tenant.Name, other)
+		}
+		if tenant.Shard == "" {
+			tenant.Shard = fromYAML[tenant.Name].Shard
+		}
+		wanted[tenant.Name] = tenant
+		keys[tenant.APIKey] = tenant.Name
+
+		tenant.Mirror = fromYAML[tenant.Name].Mirror
+		tenant.Keys = fromYAML[tenant.Name].Keys
+		candidate.Tenants = append(candidate.Tenants, tenant)
+	}
+	if err := candidate.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTenantSpec, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT name, api_key, template, store_backend, shard FROM ebuse_tenants")
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	current := make(map[string]TenantConfig)
+	for rows.Next() {
+		var tenant TenantConfig
+		if err := rows.Scan(&tenant.Name, &tenant.APIKey, &tenant.Template, &tenant.StoreBackend, &tenant.Shard); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan tenant: %w", err)
+		}
+		current[tenant.Name] = tenant
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+
+	var changes []TenantChange
+	for name, tenant := range wanted {
+		existing, ok := current[name]
+		if !ok {
+			changes = append(changes, TenantChange{Action: "create", Tenant: name})
+			continue
+		}
+		var fields []string
+		if existing.APIKey != tenant.APIKey {
+			fields = append(fields, "api_key")
+		}
+		if existing.Template != tenant.Template {
+			fields = append(fields, "template")
+		}
+		if existing.StoreBackend != tenant.StoreBackend {
+			fields = append(fields, "store_backend")
+		}
+		if existing.Shard != tenant.Shard {
+			fields = append(fields, "shard")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, TenantChange{Action: "update", Tenant: name, Fields: fields})
+		}
+	}
+	for name := range current {
+		if _, ok := wanted[name]; !ok {
+			changes = append(changes, TenantChange{Action: "delete", Tenant: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Tenant < changes[j].Tenant })
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	// Deletes go first so a key can move from a removed tenant to a new one
+	// without tripping the UNIQUE constraint; updated keys are cleared before
+	// being set for the same reason
+	upsert := r.bind(`
+	INSERT INTO ebuse_tenants (name, api_key, template, store_backend, shard, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		api_key = excluded.api_key,
+		template = excluded.template,
+		store_backend = excluded.store_backend,
+		shard = excluded.shard,
+		updated_at = excluded.updated_at`)
+	now := time.Now().Unix()
+	for _, change := range changes {
+		switch change.Action {
+		case "delete":
+			if _, err := tx.ExecContext(ctx, r.bind("DELETE FROM ebuse_tenants WHERE name = ?"), change.Tenant); err != nil {
+				return nil, fmt.Errorf("delete tenant %s: %w", change.Tenant, err)
+			}
+		case "update":
+			if _, err := tx.ExecContext(ctx, r.bind("UPDATE ebuse_tenants SET api_key = ? WHERE name = ?"), "\x00"+change.Tenant, change.Tenant); err != nil {
+				return nil, fmt.Errorf("save tenant %s: %w", change.Tenant, err)
+			}
+		}
+	}
+	for _, change := range changes {
+		if change.Action == "delete" {
+			continue
+		}
+		tenant := wanted[change.Tenant]
+		if _, err := tx.ExecContext(ctx, upsert,
+			tenant.Name, tenant.APIKey, tenant.Template, tenant.StoreBackend, tenant.Shard, now); err != nil {
+			return nil, fmt.Errorf("save tenant %s: %w", tenant.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tenants: %w", err)
+	}
+	return changes, nil
+}
+
+// Load builds the tenants configuration from the registry. Global settings
+// are read from configPath when set. The tenants listed there seed a fresh
+// control plane only: once the registry holds tenants, it is the source of
+// truth, so its edits and deletions stand. API keys given as ${...}
+// references are stored as written and resolved here, so the secrets never
+// reach the database.
+func (r *TenantRegistry) Load(ctx context.Context, configPath string) (*TenantsConfig, error) {
+	config := &TenantsConfig{}
+	if configPath != "" {
+		var err error
+		if config, err = parseTenantsConfig(configPath); err != nil {
+			return nil, err
+		}
+		if len(config.Tenants) > 0 {
+			seed := slices.Clone(config.Tenants)
+			for i, tenant := range seed {
+				if ref, ok := config.apiKeyRefs[tenant.Name]; ok {
+					seed[i].APIKey = ref
+				}
+			}
+			if _, err := r.put(ctx, true, seed); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tenants, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants configured")
+	}
+	for i, tenant := range tenants {
+		if !strings.Contains(tenant.APIKey, "${") {
+			continue
+		}
+		if tenants[i].APIKey, err = expandRefs(tenant.APIKey); err != nil {
+			return nil, fmt.Errorf("tenant %s: api_key: %w", tenant.Name, err)
+		}
+	}
+
+	// Mirrors and stream keys are not stored in the registry; keep those
+	// set in YAML, and its shard for tenants stored without one
+	fromYAML := make(map[string]TenantConfig, len(config.Tenants))
+	for _, tenant := range config.Tenants {
+		fromYAML[tenant.Name] = tenant
+	}
+	for i := range tenants {
+		tenants[i].Mirror = fromYAML[tenants[i].Name].Mirror
+		tenants[i].Keys = fromYAML[tenants[i].Name].Keys
+		if tenants[i].Shard == "" {
+			tenants[i].Shard = fromYAML[tenants[i].Name].Shard
+		}
+	}
+	config.Tenants = tenants
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Close closes the control-plane database
+func (r *TenantRegistry) Close() error {
+	return r.db.Close()
+}
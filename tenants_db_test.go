@@ -0,0 +1,367 @@
+package ebuse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openTestRegistry(t *testing.T) *TenantRegistry {
+	t.Helper()
+	registry, err := OpenTenantRegistry("sqlite", filepath.Join(t.TempDir(), "control.db"))
+	if err != nil {
+		t.Fatalf("OpenTenantRegistry failed: %v", err)
+	}
+	t.Cleanup(func() { registry.Close() })
+	return registry
+}
+
+func TestTenantRegistry_PutListDelete(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+
+	err := registry.Put(ctx,
+		TenantConfig{Name: "bob", APIKey: "key-bob"},
+		TenantConfig{Name: "alice", APIKey: "key-alice", TenantSettings: TenantSettings{StoreBackend: "sqlite"}},
+	)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Replacing a tenant updates it in place
+	if err := registry.Put(ctx, TenantConfig{Name: "bob", APIKey: "key-bob-2", Template: "archive"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	tenants, err := registry.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("expected 2 tenants, got %d", len(tenants))
+	}
+	if tenants[0].Name != "alice" || tenants[0].StoreBackend != "sqlite" {
+		t.Errorf("unexpected first tenant: %+v", tenants[0])
+	}
+	if tenants[1].APIKey != "key-bob-2" || tenants[1].Template != "archive" {
+		t.Errorf("expected bob to be updated, got %+v", tenants[1])
+	}
+
+	deleted, err := registry.Delete(ctx, "bob")
+	if err != nil || !deleted {
+		t.Fatalf("expected bob to be deleted, got %v, %v", deleted, err)
+	}
+	if deleted, _ := registry.Delete(ctx, "bob"); deleted {
+		t.Error("expected second delete to report nothing removed")
+	}
+
+	if tenants, _ := registry.List(ctx); len(tenants) != 1 {
+		t.Errorf("expected 1 tenant after delete, got %d", len(tenants))
+	}
+}
+
+func TestTenantRegistry_PutValidates(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+
+	if err := registry.Put(ctx, TenantConfig{Name: "../evil", APIKey: "key"}); err == nil {
+		t.Error("expected invalid tenant name to be rejected")
+	}
+	if err := registry.Put(ctx, TenantConfig{Name: "tenant1"}); err == nil {
+		t.Error("expected empty API key to be rejected")
+	}
+
+	// The whole batch is rejected when one tenant is invalid
+	err := registry.Put(ctx,
+		TenantConfig{Name: "tenant1", APIKey: "key1"},
+		TenantConfig{Name: "tenant2", APIKey: "key1"},
+	)
+	if err == nil {
+		t.Error("expected duplicate API key to be rejected")
+	}
+	if tenants, _ := registry.List(ctx); len(tenants) != 0 {
+		t.Errorf("expected no tenants after failed batch, got %d", len(tenants))
+	}
+}
+
+func TestTenantRegistry_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "control.db")
+
+	registry, err := OpenTenantRegistry("sqlite", path)
+	if err != nil {
+		t.Fatalf("OpenTenantRegistry failed: %v", err)
+	}
+	if err := registry.Put(ctx, TenantConfig{Name: "tenant1", APIKey: "key1"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	registry.Close()
+
+	registry, err = OpenTenantRegistry("sqlite", path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer registry.Close()
+
+	config, err := registry.Load(ctx, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(config.Tenants) != 1 || config.Tenants[0].APIKey != "key1" {
+		t.Errorf("unexpected tenants: %+v", config.Tenants)
+	}
+	if config.DataDir != "data" || config.StoreBackend != "pebble" {
+		t.Errorf("expected defaults, got data_dir=%s store_backend=%s", config.DataDir, config.StoreBackend)
+	}
+}
+
+func TestTenantRegistry_LoadSeedsFromYAML(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+data_dir: /tmp/test-data
+templates:
+  archive:
+    store_backend: sqlite
+tenants:
+  - name: existing
+    api_key: key-existing
+  - name: seeded
+    api_key: key-seeded
+    template: archive
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := registry.Load(ctx, configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if config.DataDir != "/tmp/test-data" {
+		t.Errorf("expected data_dir from yaml, got %s", config.DataDir)
+	}
+	if len(config.Tenants) != 2 {
+		t.Fatalf("expected 2 tenants, got %d", len(config.Tenants))
+	}
+
+	settings, err := config.settingsFor(config.Tenants[1])
+	if err != nil {
+		t.Fatalf("settingsFor failed: %v", err)
+	}
+	if config.Tenants[1].Name != "seeded" || settings.StoreBackend != "sqlite" {
+		t.Errorf("expected seeded tenant to use the archive template, got %+v", config.Tenants[1])
+	}
+
+	// Once seeded, the registry's edits and deletions are not undone by the
+	// YAML tenants
+	if err := registry.Put(ctx, TenantConfig{Name: "existing", APIKey: "key-rotated"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := registry.Delete(ctx, "seeded"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if config, err = registry.Load(ctx, configPath); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(config.Tenants) != 1 || config.Tenants[0].APIKey != "key-rotated" {
+		t.Errorf("expected only the edited tenant, got %+v", config.Tenants)
+	}
+}
+
+func TestTenantRegistry_LoadKeepsReferences(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+	t.Setenv("EBUSE_TEST_TENANT_KEY", "key-from-env")
+
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+tenants:
+  - name: referenced
+    api_key: ${EBUSE_TEST_TENANT_KEY}
+  - name: plain
+    api_key: key-plain
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := registry.Load(ctx, configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Tenants[1].Name != "referenced" || config.Tenants[1].APIKey != "key-from-env" {
+		t.Errorf("expected the resolved key, got %+v", config.Tenants[1])
+	}
+
+	// The database holds the reference, never the secret
+	stored, err := registry.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if stored[0].APIKey != "key-plain" || stored[1].APIKey != "${EBUSE_TEST_TENANT_KEY}" {
+		t.Errorf("expected the reference stored, got %+v", stored)
+	}
+
+	// Rotating the secret takes effect on the next Load
+	t.Setenv("EBUSE_TEST_TENANT_KEY", "key-rotated")
+	if config, err = registry.Load(ctx, ""); err != nil || config.Tenants[1].APIKey != "key-rotated" {
+		t.Errorf("expected the rotated key, got %+v, %v", config, err)
+	}
+}
+
+func TestTenantRegistry_LoadEmpty(t *testing.T) {
+	registry := openTestRegistry(t)
+
+	if _, err := registry.Load(context.Background(), ""); err == nil {
+		t.Error("expected error for empty registry")
+	}
+}
+
+func TestTenantRegistry_Shards(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+node: a
+shards:
+  a: http://a.internal:8080
+  b: http://b.internal:8080
+tenants:
+  - name: seeded
+    api_key: key-seeded
+    shard: a
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	base, err := registry.Load(ctx, configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Tenants without a YAML entry keep the shard they were put with
+	desired := []TenantConfig{
+		{Name: "seeded", APIKey: "key-seeded"},
+		{Name: "created", APIKey: "key-created", Shard: "b"},
+	}
+	if _, err := registry.Reconcile(ctx, base, desired, false); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	config, err := registry.Load(ctx, configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Tenants[0].Name != "created" || config.Tenants[0].Shard != "b" || config.Tenants[1].Shard != "a" {
+		t.Errorf("expected shards b and a, got %+v", config.Tenants)
+	}
+
+	desired[1].Shard = ""
+	if _, err := registry.Reconcile(ctx, base, desired, true); !errors.Is(err, ErrInvalidTenantSpec) {
+		t.Errorf("expected a tenant without a shard to be refused, got %v", err)
+	}
+}
+
+func TestTenantRegistry_AddsShardColumn(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "control.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE ebuse_tenants (name TEXT PRIMARY KEY, api_key TEXT NOT NULL UNIQUE,
+		template TEXT NOT NULL DEFAULT '', store_backend TEXT NOT NULL DEFAULT '', updated_at BIGINT NOT NULL);
+		INSERT INTO ebuse_tenants VALUES ('old', 'key-old', '', '', 0)`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	registry, err := OpenTenantRegistry("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("OpenTenantRegistry failed: %v", err)
+	}
+	defer registry.Close()
+	if tenants, err := registry.List(context.Background()); err != nil || len(tenants) != 1 || tenants[0].Name != "old" {
+		t.Errorf("expected the old tenant, got %+v, %v", tenants, err)
+	}
+}
+
+func TestTenantRegistry_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	registry := openTestRegistry(t)
+	base := &TenantsConfig{Templates: map[string]TenantSettings{"archive": {StoreBackend: "sqlite"}}}
+
+	if err := registry.Put(ctx,
+		TenantConfig{Name: "alice", APIKey: "key-alice"},
+		TenantConfig{Name: "bob", APIKey: "key-bob"},
+		TenantConfig{Name: "dave", APIKey: "key-dave"},
+	); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	desired := []TenantConfig{
+		{Name: "alice", APIKey: "key-alice"},
+		{Name: "bob", APIKey: "key-dave", Template: "archive"}, // Takes over dave's key
+		{Name: "carol", APIKey: "key-carol"},
+	}
+	want := []TenantChange{
+		{Action: "update", Tenant: "bob", Fields: []string{"api_key", "template"}},
+		{Action: "create", Tenant: "carol"},
+		{Action: "delete", Tenant: "dave"},
+	}
+
+	// A dry run reports the changes without making them
+	changes, err := registry.Reconcile(ctx, base, desired, true)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected changes %+v, got %+v", want, changes)
+	}
+	if tenants, _ := registry.List(ctx); len(tenants) != 3 || tenants[2].Name != "dave" {
+		t.Fatalf("Expected a dry run to leave the registry alone, got %+v", tenants)
+	}
+
+	changes, err = registry.Reconcile(ctx, base, desired, false)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected changes %+v, got %+v", want, changes)
+	}
+	tenants, err := registry.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(tenants) != 3 || tenants[1].APIKey != "key-dave" || tenants[1].Template != "archive" || tenants[2].Name != "carol" {
+		t.Errorf("Expected the desired tenants, got %+v", tenants)
+	}
+
+	// Applying the same spec again changes nothing
+	if changes, err := registry.Reconcile(ctx, base, desired, false); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v, %v", changes, err)
+	}
+
+	// Invalid specs are refused as a whole
+	for name, spec := range map[string][]TenantConfig{
+		"empty":          nil,
+		"duplicate name": {{Name: "alice", APIKey: "a"}, {Name: "alice", APIKey: "b"}},
+		"shared key":     {{Name: "alice", APIKey: "a"}, {Name: "bob", APIKey: "a"}},
+		"no key":         {{Name: "alice"}},
+		"bad template":   {{Name: "alice", APIKey: "a", Template: "missing"}},
+	} {
+		if _, err := registry.Reconcile(ctx, base, spec, false); !errors.Is(err, ErrInvalidTenantSpec) {
+			t.Errorf("%s: expected ErrInvalidTenantSpec, got %v", name, err)
+		}
+	}
+	if tenants, _ := registry.List(ctx); len(tenants) != 3 {
+		t.Errorf("Expected refused specs to change nothing, got %+v", tenants)
+	}
+}
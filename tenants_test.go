@@ -1,9 +1,17 @@
 package ebuse
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jilio/ebuse/internal/retention"
+	"github.com/jilio/ebuse/internal/store"
 )
 
 func TestLoadTenantsConfig(t *testing.T) {
@@ -331,6 +339,11 @@ func TestTenantManager_Close(t *testing.T) {
 	if err != nil {
 		t.Errorf("Close returned error: %v", err)
 	}
+
+	// The server closes its tenant manager as well
+	if err := tm.Close(); err != nil {
+		t.Errorf("second Close returned error: %v", err)
+	}
 }
 
 func TestValidTenantName(t *testing.T) {
@@ -364,3 +377,850 @@ func TestValidTenantName(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadTenantsConfig_Templates(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "tenants.yaml")
+
+	configData := `
+store_backend: pebble
+default_template: standard
+templates:
+  standard:
+    store_backend: sqlite
+  fast:
+    store_backend: pebble
+tenants:
+  - name: inherits-default
+    api_key: key1
+  - name: picks-template
+    api_key: key2
+    template: fast
+  - name: overrides
+    api_key: key3
+    store_backend: pebble
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+
+	want := map[string]string{
+		"inherits-default": "sqlite",
+		"picks-template":   "pebble",
+		"overrides":        "pebble",
+	}
+	for _, tenant := range config.Tenants {
+		settings, err := config.settingsFor(tenant)
+		if err != nil {
+			t.Fatalf("settingsFor(%s) failed: %v", tenant.Name, err)
+		}
+		if settings.StoreBackend != want[tenant.Name] {
+			t.Errorf("tenant %s: expected backend %s, got %s", tenant.Name, want[tenant.Name], settings.StoreBackend)
+		}
+	}
+}
+
+func TestLoadTenantsConfig_TemplateErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown template": `
+tenants:
+  - name: tenant1
+    api_key: key1
+    template: missing
+`,
+		"unknown default template": `
+default_template: missing
+tenants:
+  - name: tenant1
+    api_key: key1
+`,
+		"invalid template backend": `
+templates:
+  broken:
+    store_backend: mysql
+tenants:
+  - name: tenant1
+    api_key: key1
+    template: broken
+`,
+	}
+
+	for name, configData := range tests {
+		t.Run(name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+			if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			if _, err := LoadTenantsConfig(configPath); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestNewTenantManager_TemplateBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config := &TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1", Template: "durable"},
+		},
+		DataDir:      tmpDir,
+		StoreBackend: "pebble",
+		Templates: map[string]TenantSettings{
+			"durable": {StoreBackend: "sqlite"},
+		},
+	}
+
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "tenant1.db")); err != nil {
+		t.Errorf("expected template backend sqlite to create tenant1.db: %v", err)
+	}
+}
+
+func TestLoadTenantsConfig_EnvInterpolation(t *testing.T) {
+	t.Setenv("EBUSE_TEST_ALICE_KEY", "alice-from-env")
+	t.Setenv("EBUSE_TEST_WAL_MB", "64")
+	t.Setenv("EBUSE_TEST_TRICKY", "a: b # not a comment")
+
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+wal_checkpoint_mb: ${EBUSE_TEST_WAL_MB}
+data_dir: "${EBUSE_TEST_UNSET_DIR:-fallback-data}"
+tenants:
+  - name: alice
+    api_key: ${EBUSE_TEST_ALICE_KEY}
+  - name: bob
+    api_key: "${EBUSE_TEST_TRICKY}"
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+
+	if config.Tenants[0].APIKey != "alice-from-env" {
+		t.Errorf("expected key from environment, got %q", config.Tenants[0].APIKey)
+	}
+	if config.Tenants[1].APIKey != "a: b # not a comment" {
+		t.Errorf("expected value to be used verbatim, got %q", config.Tenants[1].APIKey)
+	}
+	if config.WALCheckpointMB != 64 {
+		t.Errorf("expected numeric value from environment, got %d", config.WALCheckpointMB)
+	}
+	if config.DataDir != "fallback-data" {
+		t.Errorf("expected default for unset variable, got %q", config.DataDir)
+	}
+}
+
+func TestLoadTenantsConfig_EnvInterpolationMissing(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+tenants:
+  - name: alice
+    api_key: ${EBUSE_TEST_DEFINITELY_UNSET}
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	_, err := LoadTenantsConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "EBUSE_TEST_DEFINITELY_UNSET") {
+		t.Errorf("expected error naming the missing variable, got %v", err)
+	}
+}
+
+func TestTenantManager_RotateKeys(t *testing.T) {
+	config := &TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1"},
+			{Name: "tenant2", APIKey: "key2"},
+		},
+		DataDir:      t.TempDir(),
+		StoreBackend: "sqlite",
+	}
+
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+
+	before, _, _ := tm.GetStore("key1")
+
+	rotated, err := tm.RotateKeys(&TenantsConfig{Tenants: []TenantConfig{
+		{Name: "tenant1", APIKey: "key1-rotated"},
+		{Name: "tenant2", APIKey: "key2"},
+		{Name: "tenant3", APIKey: "key3"}, // new tenants are left to Reload
+	}})
+	if err != nil {
+		t.Fatalf("RotateKeys failed: %v", err)
+	}
+	if len(rotated) != 1 || rotated[0] != "tenant1" {
+		t.Errorf("expected tenant1 to be rotated, got %v", rotated)
+	}
+
+	if _, _, ok := tm.GetStore("key1"); ok {
+		t.Error("expected old key to be rejected")
+	}
+	after, name, ok := tm.GetStore("key1-rotated")
+	if !ok || name != "tenant1" || after != before {
+		t.Error("expected new key to reach the same tenant store")
+	}
+	if _, _, ok := tm.GetStore("key3"); ok {
+		t.Error("expected unknown tenant to be ignored")
+	}
+
+	// A conflicting config leaves the current keys in place
+	if _, err := tm.RotateKeys(&TenantsConfig{Tenants: []TenantConfig{
+		{Name: "tenant1", APIKey: "key2"},
+	}}); err == nil {
+		t.Error("expected duplicate API key to be rejected")
+	}
+	if _, _, ok := tm.GetStore("key1-rotated"); !ok {
+		t.Error("expected keys to be unchanged after a failed rotation")
+	}
+}
+
+func TestTenantManager_Reload(t *testing.T) {
+	dataDir := t.TempDir()
+	config := &TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1"},
+			{Name: "tenant2", APIKey: "key2"},
+		},
+		DataDir:      dataDir,
+		StoreBackend: "sqlite",
+	}
+
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+	tm.removeGrace = time.Millisecond
+
+	kept, _, _ := tm.GetStore("key1")
+	removed, _, _ := tm.GetStore("key2")
+
+	// The hook runs while the removed tenant's store is still open
+	hooked := make(chan error, 1)
+	tm.OnRemove(func(name string, st store.EventStore) {
+		_, err := st.GetPosition(context.Background())
+		if name != "tenant2" || st != removed {
+			err = fmt.Errorf("hook called for %s", name)
+		}
+		hooked <- err
+	})
+
+	changes, err := tm.Reload(&TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1-rotated", Keys: []StreamKey{{Name: "billing", APIKey: "billing-key", Read: []string{"invoice-*"}}}},
+			{Name: "tenant3", APIKey: "key3"},
+		},
+		DataDir:      dataDir,
+		StoreBackend: "sqlite",
+	})
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	want := TenantChanges{Added: []string{"tenant3"}, Removed: []string{"tenant2"}, Rotated: []string{"tenant1", "tenant1/billing"}}
+	if !reflect.DeepEqual(*changes, want) {
+		t.Errorf("Reload() = %+v, want %+v", *changes, want)
+	}
+
+	if st, name, ok := tm.GetStore("key1-rotated"); !ok || name != "tenant1" || st != kept {
+		t.Error("expected tenant1 to keep its store under the new key")
+	}
+	if _, _, ok := tm.GetStore("key2"); ok {
+		t.Error("expected the removed tenant's key to be rejected")
+	}
+	added, _, ok := tm.GetStore("key3")
+	if !ok {
+		t.Fatal("expected the added tenant to be served")
+	}
+	if err := added.Save(context.Background(), &store.StoredEvent{Type: "TenantAdded", Data: []byte(`{}`)}); err != nil {
+		t.Errorf("Save to the added tenant failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "tenant3.db")); err != nil {
+		t.Errorf("expected the added tenant's database: %v", err)
+	}
+
+	// The removed tenant's store is closed after the grace period
+	select {
+	case err := <-hooked:
+		if err != nil {
+			t.Errorf("expected OnRemove before the store is closed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnRemove to be called for the removed tenant")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := removed.GetPosition(context.Background()); err == nil; _, err = removed.GetPosition(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the removed tenant's store to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// An invalid config changes nothing
+	if _, err := tm.Reload(&TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1-rotated"},
+			{Name: "tenant4", APIKey: "key1-rotated"},
+		},
+		DataDir:      dataDir,
+		StoreBackend: "sqlite",
+	}); err == nil {
+		t.Error("expected duplicate API key to be rejected")
+	}
+	if _, _, ok := tm.GetStore("key3"); !ok {
+		t.Error("expected tenants to be unchanged after a failed reload")
+	}
+	if tenants := tm.GetAllTenants(); len(tenants) != 2 {
+		t.Errorf("expected tenant1 and tenant3, got %v", tenants)
+	}
+}
+
+func TestLoadTenantsConfig_Mirror(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    mirror:
+      url: https://dr.example.com
+      api_key: remote-key
+  - name: tenant2
+    api_key: key2
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	if m := config.Tenants[0].Mirror; m == nil || m.URL != "https://dr.example.com" || m.APIKey != "remote-key" {
+		t.Errorf("unexpected mirror: %+v", m)
+	}
+	if config.Tenants[1].Mirror != nil {
+		t.Error("expected no mirror for tenant2")
+	}
+
+	incomplete := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    mirror:
+      url: https://dr.example.com
+`
+	if err := os.WriteFile(configPath, []byte(incomplete), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil {
+		t.Error("expected error for mirror without api_key")
+	}
+}
+
+func TestTenantManager_Sharded(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "tenants.yaml")
+	configData := `
+data_dir: ` + filepath.Join(tmpDir, "data") + `
+node: ${EBUSE_TEST_NODE}
+shards:
+  node-a: http://node-a:8080
+  node-b: http://node-b:8080
+tenants:
+  - name: alice
+    api_key: alice-key
+    shard: node-a
+  - name: bob
+    api_key: bob-key
+    shard: node-b
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("EBUSE_TEST_NODE", "node-a")
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+
+	if names := tm.GetAllTenants(); len(names) != 1 || names[0] != "alice" {
+		t.Errorf("expected only alice to be served locally, got %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "data", "bob")); !os.IsNotExist(err) {
+		t.Error("expected no store to be created for bob")
+	}
+	if name, nodeURL, ok := tm.RouteTenant("bob-key"); !ok || name != "bob" || nodeURL != "http://node-b:8080" {
+		t.Errorf("expected bob to route to node-b, got %s %s %v", name, nodeURL, ok)
+	}
+	if _, _, ok := tm.RouteTenant("alice-key"); ok {
+		t.Error("expected local tenants not to be routed")
+	}
+
+	// Keys of remote tenants rotate too
+	config.Tenants[1].APIKey = "bob-key-2"
+	rotated, err := tm.RotateKeys(config)
+	if err != nil || len(rotated) != 1 || rotated[0] != "bob" {
+		t.Fatalf("expected bob to be rotated, got %v, %v", rotated, err)
+	}
+	if _, _, ok := tm.RouteTenant("bob-key-2"); !ok {
+		t.Error("expected the rotated key to route")
+	}
+
+	for name, bad := range map[string]string{
+		"unknown node":  "node: node-c\nshards:\n  node-a: http://a\ntenants:\n  - name: alice\n    api_key: k\n    shard: node-a\n",
+		"missing shard": "node: node-a\nshards:\n  node-a: http://a\ntenants:\n  - name: alice\n    api_key: k\n",
+		"no shards":     "tenants:\n  - name: alice\n    api_key: k\n    shard: node-a\n",
+		"bad URL":       "node: node-a\nshards:\n  node-a: node-a:8080\ntenants:\n  - name: alice\n    api_key: k\n    shard: node-a\n",
+	} {
+		if err := os.WriteFile(configPath, []byte(bad), 0644); err != nil {
+			t.Fatalf("failed to write test config: %v", err)
+		}
+		if _, err := LoadTenantsConfig(configPath); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadTenantsConfig_Pipeline(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+default_template: standard
+templates:
+  standard:
+    pipeline:
+      strip: [password]
+      enrich:
+        tenant: true
+tenants:
+  - name: inherits
+    api_key: key1
+  - name: overrides
+    api_key: key2
+    pipeline:
+      allow:
+        - type: OrderPlaced
+          max_bytes: 1024
+      deny:
+        - type: Debug*
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	pipelines, err := config.Pipelines()
+	if err != nil {
+		t.Fatalf("Pipelines failed: %v", err)
+	}
+	if len(pipelines) != 2 || pipelines["inherits"] == nil || pipelines["overrides"] == nil {
+		t.Errorf("expected a pipeline per tenant, got %v", pipelines)
+	}
+
+	invalid := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    pipeline:
+      deny:
+        - reason: rejects everything
+`
+	if err := os.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil {
+		t.Error("expected error for a deny rule without type or field")
+	}
+}
+
+func TestLoadTenantsConfig_RateLimit(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+templates:
+  metered:
+    rate_limit: 50
+    rate_burst: 200
+tenants:
+  - name: metered
+    api_key: key1
+    template: metered
+  - name: custom
+    api_key: key2
+    template: metered
+    rate_limit: 500
+  - name: plain
+    api_key: key3
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	limits, err := config.RateLimits(0)
+	if err != nil {
+		t.Fatalf("RateLimits failed: %v", err)
+	}
+	if len(limits) != 2 || limits["metered"] != 50 || limits["custom"] != 500 {
+		t.Errorf("expected limits for metered and custom only, got %v", limits)
+	}
+	if limits, _ := config.RateLimits(10); limits["plain"] != 10 || limits["metered"] != 50 {
+		t.Errorf("expected the default for plain only, got %v", limits)
+	}
+	bursts, err := config.RateBursts(0)
+	if err != nil {
+		t.Fatalf("RateBursts failed: %v", err)
+	}
+	if len(bursts) != 2 || bursts["metered"] != 200 || bursts["custom"] != 200 {
+		t.Errorf("expected the template's burst for metered and custom, got %v", bursts)
+	}
+	if bursts, _ := config.RateBursts(20); bursts["plain"] != 20 {
+		t.Errorf("expected the default burst for plain, got %v", bursts)
+	}
+
+	negative := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    rate_limit: -1
+`
+	if err := os.WriteFile(configPath, []byte(negative), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil {
+		t.Error("expected error for a negative rate_limit")
+	}
+
+	negative = strings.Replace(negative, "rate_limit", "rate_burst", 1)
+	if err := os.WriteFile(configPath, []byte(negative), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil {
+		t.Error("expected error for a negative rate_burst")
+	}
+}
+
+func TestLoadTenantsConfig_Retention(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+templates:
+  short:
+    retention:
+      max_age: 168h
+tenants:
+  - name: short
+    api_key: key1
+    template: short
+  - name: capped
+    api_key: key2
+    retention:
+      max_events: 1000000
+  - name: forever
+    api_key: key3
+    retention: {}
+  - name: plain
+    api_key: key4
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	policies, err := config.RetentionPolicies(retention.Policy{})
+	if err != nil {
+		t.Fatalf("RetentionPolicies failed: %v", err)
+	}
+	if len(policies) != 2 || policies["short"].MaxAge != 168*time.Hour || policies["capped"].MaxEvents != 1000000 {
+		t.Errorf("expected policies for short and capped only, got %v", policies)
+	}
+	policies, _ = config.RetentionPolicies(retention.Policy{MaxAge: time.Hour})
+	if policies["plain"].MaxAge != time.Hour || policies["short"].MaxAge != 168*time.Hour {
+		t.Errorf("expected the default for plain only, got %v", policies)
+	}
+	if _, ok := policies["forever"]; ok {
+		t.Error("an empty retention policy should keep everything")
+	}
+
+	negative := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    retention:
+      max_events: -5
+`
+	if err := os.WriteFile(configPath, []byte(negative), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil || !strings.Contains(err.Error(), "retention") {
+		t.Errorf("expected a retention error, got %v", err)
+	}
+}
+
+func TestLoadTenantsConfig_CORSOrigins(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+templates:
+  browser:
+    cors_origins: ["https://app.example.com"]
+tenants:
+  - name: web
+    api_key: key1
+    template: browser
+  - name: custom
+    api_key: key2
+    template: browser
+    cors_origins: ["https://*.example.org"]
+  - name: plain
+    api_key: key3
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	origins, err := config.CORSOrigins()
+	if err != nil {
+		t.Fatalf("CORSOrigins failed: %v", err)
+	}
+	if len(origins) != 2 || origins["web"][0] != "https://app.example.com" || origins["custom"][0] != "https://*.example.org" {
+		t.Errorf("expected origins for web and custom only, got %v", origins)
+	}
+
+	invalid := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    cors_origins: ["app.example.com"]
+`
+	if err := os.WriteFile(configPath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if _, err := LoadTenantsConfig(configPath); err == nil {
+		t.Error("expected error for an origin without a scheme")
+	}
+}
+
+func TestNewTenantManager_PostgresNeedsDSN(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "tenants.yaml")
+	yaml := `store_backend: postgres
+data_dir: ` + tmpDir + `
+tenants:
+  - name: tenant1
+    api_key: key1
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("expected postgres to be a valid backend: %v", err)
+	}
+
+	_, err = NewTenantManager(config)
+	if err == nil || !strings.Contains(err.Error(), "postgres_dsn") {
+		t.Fatalf("expected error for missing postgres_dsn, got %v", err)
+	}
+}
+
+func TestNewTenantManager_MemoryBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config := &TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "tenant1", APIKey: "key1", TenantSettings: TenantSettings{StoreBackend: "memory"}},
+		},
+		DataDir:      tmpDir,
+		StoreBackend: "pebble",
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("expected memory to be a valid backend: %v", err)
+	}
+
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+
+	st, _, _ := tm.GetStore("key1")
+	if _, ok := st.(*store.MemoryStore); !ok {
+		t.Errorf("expected a memory store, got %T", st)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("expected nothing on disk, found %d entries", len(entries))
+	}
+}
+
+func TestNewTenantManager_StartPosition(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &TenantsConfig{
+		Tenants: []TenantConfig{
+			{Name: "migrated", APIKey: "key1", TenantSettings: TenantSettings{StartPosition: 1000000}},
+			{Name: "fresh", APIKey: "key2"},
+		},
+		DataDir: tmpDir,
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+
+	// Reopening does not move a store that has a position
+	for range 2 {
+		tm, err := NewTenantManager(config)
+		if err != nil {
+			t.Fatalf("NewTenantManager failed: %v", err)
+		}
+		for key, want := range map[string]int64{"key1": 1000000, "key2": 0} {
+			st, _, _ := tm.GetStore(key)
+			if pos, _ := st.GetPosition(context.Background()); pos != want {
+				t.Errorf("%s: position = %d, want %d", key, pos, want)
+			}
+		}
+		tm.Close()
+	}
+
+	config.Tenants[1].StartPosition = -1
+	if err := config.validate(); err == nil {
+		t.Error("expected a negative start_position to be rejected")
+	}
+}
+
+func TestTenantManager_StreamKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "tenants.yaml")
+	configData := `
+tenants:
+  - name: tenant1
+    api_key: key1
+    keys:
+      - name: billing
+        api_key: billing-key
+        read: ["billing/*"]
+        append: ["billing/invoices/*"]
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	config, err := LoadTenantsConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig failed: %v", err)
+	}
+	config.DataDir = t.TempDir()
+	config.StoreBackend = "memory"
+
+	tm, err := NewTenantManager(config)
+	if err != nil {
+		t.Fatalf("NewTenantManager failed: %v", err)
+	}
+	defer tm.Close()
+
+	main, _, _ := tm.GetStore("key1")
+	scoped, name, ok := tm.GetStore("billing-key")
+	if !ok || name != "tenant1" || scoped != main {
+		t.Fatal("expected the stream key to reach the tenant's store")
+	}
+	if tenants := tm.GetAllTenants(); len(tenants) != 1 {
+		t.Errorf("expected one tenant, got %v", tenants)
+	}
+	if _, _, ok := tm.KeyScope("key1"); ok {
+		t.Error("expected the main key to be unlimited")
+	}
+	id, a, ok := tm.KeyScope("billing-key")
+	if !ok || id != "tenant1/billing" || !a.CanRead("billing/customers/1") || a.CanAppend("billing/customers/1") {
+		t.Errorf("unexpected scope %s %v", id, a)
+	}
+
+	// Reloading replaces the tenant's stream keys
+	rotated, err := tm.RotateKeys(&TenantsConfig{Tenants: []TenantConfig{
+		{Name: "tenant1", APIKey: "key1", Keys: []StreamKey{
+			{Name: "reports", APIKey: "reports-key", Read: []string{"*"}},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("RotateKeys failed: %v", err)
+	}
+	if len(rotated) != 2 || rotated[0] != "tenant1/billing" || rotated[1] != "tenant1/reports" {
+		t.Errorf("expected the removed and added keys, got %v", rotated)
+	}
+	if _, _, ok := tm.GetStore("billing-key"); ok {
+		t.Error("expected the removed key to be rejected")
+	}
+	if _, a, ok := tm.KeyScope("reports-key"); !ok || !a.CanRead("orders/1") {
+		t.Error("expected the added key to be limited to reads")
+	}
+
+	for _, keys := range [][]StreamKey{
+		{{Name: "a", APIKey: "key1"}},
+		{{Name: "a", APIKey: "x", Read: []string{"a*b"}}},
+		{{Name: "a/b", APIKey: "x"}},
+	} {
+		if _, err := tm.RotateKeys(&TenantsConfig{Tenants: []TenantConfig{{Name: "tenant1", APIKey: "key1", Keys: keys}}}); err == nil {
+			t.Errorf("expected an error for %+v", keys)
+		}
+	}
+}
+
+func TestTenantsConfig_StorePath(t *testing.T) {
+	config := &TenantsConfig{
+		DataDir:      "data",
+		StoreBackend: "pebble",
+		Tenants: []TenantConfig{
+			{Name: "acme", APIKey: "key1", TenantSettings: TenantSettings{StoreBackend: "sqlite"}},
+			{Name: "globex", APIKey: "key2", TenantSettings: TenantSettings{StoreBackend: "postgres"}},
+		},
+	}
+	tests := []struct {
+		name, backend, path string
+	}{
+		{"acme", "sqlite", filepath.Join("data", "acme.db")},
+		{"globex", "postgres", ""},
+		{"initech", "pebble", filepath.Join("data", "initech")}, // Not configured yet
+	}
+	for _, tt := range tests {
+		backend, path, err := config.StorePath(tt.name)
+		if err != nil || backend != tt.backend || path != tt.path {
+			t.Errorf("StorePath(%q) = %q, %q, %v; want %q, %q", tt.name, backend, path, err, tt.backend, tt.path)
+		}
+	}
+	if _, _, err := config.StorePath("../etc"); err == nil {
+		t.Error("Expected an invalid tenant name to be rejected")
+	}
+}